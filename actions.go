@@ -0,0 +1,207 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/google/subcommands"
+)
+
+// GitHubActionsEnv is the environment variable set to "true" when running in GitHub Actions.
+const GitHubActionsEnv = "GITHUB_ACTIONS"
+
+// GitHubStepSummaryEnv is the environment variable naming the file of the summary of the step
+// running in GitHub Actions.
+const GitHubStepSummaryEnv = "GITHUB_STEP_SUMMARY"
+
+// LocatedError is implemented by the errors about a location in a file, which GitHubActions
+// annotates.
+type LocatedError interface {
+	error
+
+	// Location returns the path of the file and the line, from 1, or 0 for the whole file.
+	Location() (file string, line int)
+}
+
+// failureHookKey is the context key of the function called by printFailure.
+type failureHookKey struct{}
+
+// reportFailure calls the failure hook of ctx, if any, with the failure with status of the command
+// named name.
+func reportFailure(ctx context.Context, name string, err error, status subcommands.ExitStatus) {
+	if hook, ok := ctx.Value(failureHookKey{}).(func(string, error, subcommands.ExitStatus)); ok {
+		hook(name, err, status)
+	}
+}
+
+// GitHubActionsOption is an option of the GitHubActions wrapper.
+type GitHubActionsOption interface {
+	applyGitHubActions(*gitHubActions)
+}
+
+// gitHubActionsOptionFunc is a GitHubActionsOption implemented by a function.
+type gitHubActionsOptionFunc func(*gitHubActions)
+
+// applyGitHubActions implements GitHubActionsOption.
+func (fn gitHubActionsOptionFunc) applyGitHubActions(c *gitHubActions) { fn(c) }
+
+// WithGitHubActionsEnabled enables or disables the annotations of GitHubActions, whatever
+// GitHubActionsEnv is set to.
+func WithGitHubActionsEnabled(enabled bool) GitHubActionsOption {
+	return gitHubActionsOptionFunc(func(c *gitHubActions) {
+		c.enabled = func() bool { return enabled }
+	})
+}
+
+// gitHubActions wraps a subcommands.Command so that its failures are annotated in GitHub Actions.
+type gitHubActions struct {
+	sub     subcommands.Command
+	enabled func() bool
+}
+
+// make sure gitHubActions implements the subcommands.Command interface.
+var _ subcommands.Command = (*gitHubActions)(nil)
+
+// GitHubActions wraps sub so that, when running in GitHub Actions, as reported by
+// GitHubActionsEnv being "true" unless WithGitHubActionsEnabled is given, its failures are printed
+// to Stdout as workflow commands shown in the UI, like:
+//
+//	::error title=push failed::no such remote
+//
+// Each failure printed by PrintError is annotated, with the file and line of a LocatedError, or
+// the status of sub if none was printed; a canceled execution is a ::warning instead. A line per
+// failure is also appended to the step summary, the file named by GitHubStepSummaryEnv, if set.
+// Outside of GitHub Actions, sub is executed unchanged.
+func GitHubActions(sub subcommands.Command, opts ...GitHubActionsOption) subcommands.Command {
+	c := &gitHubActions{
+		sub: sub,
+		enabled: func() bool {
+			enabled, _ := strconv.ParseBool(os.Getenv(GitHubActionsEnv))
+			return enabled
+		},
+	}
+	for _, opt := range opts {
+		opt.applyGitHubActions(c)
+	}
+
+	return c
+}
+
+// Name forwards to the underlying c.sub Command.
+func (c *gitHubActions) Name() string {
+	return c.sub.Name()
+}
+
+// Usage forwards to the underlying c.sub Command.
+func (c *gitHubActions) Usage() string {
+	return c.sub.Usage()
+}
+
+// Synopsis forwards to the underlying c.sub Command.
+func (c *gitHubActions) Synopsis() string {
+	return c.sub.Synopsis()
+}
+
+// Unwrap returns the underlying c.sub Command.
+func (c *gitHubActions) Unwrap() subcommands.Command {
+	return c.sub
+}
+
+// SetFlags forwards to the underlying c.sub Command.
+func (c *gitHubActions) SetFlags(f *flag.FlagSet) {
+	c.sub.SetFlags(f)
+}
+
+// actionsFailure is a failure reported during an execution annotated by GitHubActions.
+type actionsFailure struct {
+	name string
+	err  error
+}
+
+// Execute forwards to the underlying c.sub Command, and annotates its failures in GitHub Actions.
+func (c *gitHubActions) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	if !c.enabled() {
+		return c.sub.Execute(ctx, f, args...)
+	}
+
+	var (
+		mu       sync.Mutex
+		failures []actionsFailure
+	)
+	hook := func(name string, err error, _ subcommands.ExitStatus) {
+		mu.Lock()
+		defer mu.Unlock()
+		failures = append(failures, actionsFailure{name: name, err: err})
+	}
+	status := c.sub.Execute(context.WithValue(ctx, failureHookKey{}, hook), f, args...)
+	if status == subcommands.ExitSuccess {
+		return status
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(failures) == 0 {
+		failures = []actionsFailure{{name: c.sub.Name(), err: fmt.Errorf("exited with %s", StatusString(status))}}
+	}
+
+	canceled := ctx.Err() != nil
+	var summary strings.Builder
+	for _, fl := range failures {
+		command, title := "error", fl.name+" failed"
+		if canceled {
+			command, title = "warning", fl.name+" canceled"
+		}
+		var props []string
+		var located LocatedError
+		if errors.As(fl.err, &located) {
+			file, line := located.Location()
+			props = append(props, "file="+escapeActionsProperty(file))
+			if line > 0 {
+				props = append(props, "line="+strconv.Itoa(line))
+			}
+		}
+		props = append(props, "title="+escapeActionsProperty(title))
+		fmt.Fprintf(Stdout(ctx), "::%s %s::%s\n", command, strings.Join(props, ","), escapeActionsData(fl.err.Error()))
+		fmt.Fprintf(&summary, "- **%s**: %s\n", title, fl.err)
+	}
+	if path := os.Getenv(GitHubStepSummaryEnv); path != "" {
+		if err := appendFile(path, summary.String()); err != nil {
+			fmt.Fprintf(Stderr(ctx), "warning: writing the step summary: %v\n", err)
+		}
+	}
+
+	return status
+}
+
+// escapeActionsData escapes s as the data of a workflow command.
+func escapeActionsData(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+// escapeActionsProperty escapes s as the value of a property of a workflow command.
+func escapeActionsProperty(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C").Replace(s)
+}
+
+// appendFile appends s to the file at path, creating it if needed.
+func appendFile(path, s string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(s); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
@@ -0,0 +1,122 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+// lintError is a subcommandsutil.LocatedError.
+type lintError struct {
+	file string
+	line int
+}
+
+// Error implements error.
+func (e *lintError) Error() string {
+	return "unused variable x\nin function main"
+}
+
+// Location implements subcommandsutil.LocatedError.
+func (e *lintError) Location() (string, int) {
+	return e.file, e.line
+}
+
+func TestGitHubActions(t *testing.T) {
+	tests := map[string]struct {
+		env         string
+		opts        []subcommandsutil.GitHubActionsOption
+		cmd         subcommands.Command
+		wantStdout  string
+		wantSummary string
+	}{
+		"when the command fails with an error": {
+			env:         "true",
+			cmd:         failing(errors.New("no such remote")),
+			wantStdout:  "::error title=push failed::no such remote\n",
+			wantSummary: "- **push failed**: no such remote\n",
+		},
+		"when the error carries a location": {
+			env:         "true",
+			cmd:         failing(&lintError{file: "cmd/main.go", line: 12}),
+			wantStdout:  "::error file=cmd/main.go,line=12,title=push failed::unused variable x%0Ain function main\n",
+			wantSummary: "- **push failed**: unused variable x\nin function main\n",
+		},
+		"when the command fails without an error": {
+			env:         "true",
+			cmd:         testcmd.NewRecording("push", testcmd.WithStatus(subcommands.ExitUsageError)),
+			wantStdout:  "::error title=push failed::exited with ExitUsageError(2)\n",
+			wantSummary: "- **push failed**: exited with ExitUsageError(2)\n",
+		},
+		"when the command succeeds": {
+			env: "true",
+			cmd: failing(nil),
+		},
+		"when not running in GitHub Actions": {
+			cmd: failing(errors.New("no such remote")),
+		},
+		"when the annotations are disabled": {
+			env:  "true",
+			opts: []subcommandsutil.GitHubActionsOption{subcommandsutil.WithGitHubActionsEnabled(false)},
+			cmd:  failing(errors.New("no such remote")),
+		},
+		"when the annotations are enabled": {
+			opts:        []subcommandsutil.GitHubActionsOption{subcommandsutil.WithGitHubActionsEnabled(true)},
+			cmd:         failing(errors.New("no such remote")),
+			wantStdout:  "::error title=push failed::no such remote\n",
+			wantSummary: "- **push failed**: no such remote\n",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			summary := filepath.Join(t.TempDir(), "summary.md")
+			t.Setenv(subcommandsutil.GitHubActionsEnv, tt.env)
+			t.Setenv(subcommandsutil.GitHubStepSummaryEnv, summary)
+
+			var stdout, stderr testcmd.Buffer
+			ctx := subcommandsutil.WithOutput(context.Background(), &stdout, &stderr)
+			testcmd.Run(ctx, subcommandsutil.GitHubActions(tt.cmd, tt.opts...))
+			if got := stdout.String(); got != tt.wantStdout {
+				t.Fatalf("wanted the stdout %q but got %q", tt.wantStdout, got)
+			}
+			data, err := os.ReadFile(summary)
+			if err != nil && !os.IsNotExist(err) {
+				t.Fatal(err)
+			}
+			if got := string(data); got != tt.wantSummary {
+				t.Fatalf("wanted the summary %q but got %q", tt.wantSummary, got)
+			}
+		})
+	}
+}
+
+func TestGitHubActionsCanceled(t *testing.T) {
+	defer testcmd.VerifyNoLeaks(t)
+	t.Setenv(subcommandsutil.GitHubActionsEnv, "true")
+	t.Setenv(subcommandsutil.GitHubStepSummaryEnv, "")
+
+	tcmd := testcmd.NewBlocking("push")
+	defer tcmd.Release(subcommands.ExitSuccess)
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-tcmd.Started()
+		cancel()
+	}()
+
+	var stdout testcmd.Buffer
+	cmd := subcommandsutil.GitHubActions(subcommandsutil.Cancelable(tcmd, subcommandsutil.WithLogger(&testcmd.LogRecorder{})))
+	testcmd.Run(subcommandsutil.WithOutput(ctx, &stdout, &testcmd.Buffer{}), cmd)
+	if want := "::warning title=push canceled::context canceled\n"; stdout.String() != want {
+		t.Fatalf("wanted the stdout %q but got %q", want, stdout.String())
+	}
+}
@@ -0,0 +1,199 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"sort"
+
+	"github.com/google/subcommands"
+)
+
+// AliasFlag registers aliases of the flag named canonical in f. The aliases share the Value of
+// canonical, and PrintDefaults renders them on the line of canonical, like "-o, -output string".
+//
+// AliasFlag is usually called from SetFlags, after canonical is defined. It panics if f does not
+// define canonical.
+func AliasFlag(f *flag.FlagSet, canonical string, aliases ...string) {
+	target := mustLookup(f, canonical)
+
+	for _, alias := range aliases {
+		f.Var(forwardValue{target.Value}, alias, "alias for -"+canonical)
+	}
+
+	updateFlagMeta(f, func(m *flagMeta) {
+		for _, alias := range aliases {
+			m.aliases[alias] = canonical
+		}
+	})
+}
+
+// CanonicalFlagName returns the name of the flag name is an alias or a deprecated name of in f, or
+// name itself.
+func CanonicalFlagName(f *flag.FlagSet, name string) string {
+	readFlagMeta(f, func(m *flagMeta) {
+		if m == nil {
+			return
+		}
+		if canonical, ok := m.aliases[name]; ok {
+			name = canonical
+		} else if canonical, ok := m.deprecated[name]; ok {
+			name = canonical
+		}
+	})
+
+	return name
+}
+
+// IsFlagSet reports whether the flag named name of f was set explicitly on the command line, either
+// by its own name, by one of its aliases, or by a deprecated name.
+func IsFlagSet(f *flag.FlagSet, name string) bool {
+	canonical := CanonicalFlagName(f, name)
+
+	set := false
+	f.Visit(func(fl *flag.Flag) {
+		if CanonicalFlagName(f, fl.Name) == canonical {
+			set = true
+		}
+	})
+
+	return set
+}
+
+// flagNames returns the name of fl and its aliases in f, shortest first.
+func flagNames(f *flag.FlagSet, fl *flag.Flag) []string {
+	names := []string{fl.Name}
+	readFlagMeta(f, func(m *flagMeta) {
+		if m == nil {
+			return
+		}
+		for alias, canonical := range m.aliases {
+			if canonical == fl.Name {
+				names = append(names, alias)
+			}
+		}
+	})
+
+	sort.Slice(names, func(i, j int) bool {
+		if len(names[i]) != len(names[j]) {
+			return len(names[i]) < len(names[j])
+		}
+		return names[i] < names[j]
+	})
+
+	return names
+}
+
+// isAliasFlag reports whether the flag named name of f is an alias registered by AliasFlag.
+func isAliasFlag(f *flag.FlagSet, name string) (alias bool) {
+	readFlagMeta(f, func(m *flagMeta) {
+		_, alias = m.aliasOf(name)
+	})
+
+	return alias
+}
+
+// aliasOf returns the canonical name of the flag alias name is registered for.
+func (m *flagMeta) aliasOf(name string) (string, bool) {
+	if m == nil {
+		return "", false
+	}
+	canonical, ok := m.aliases[name]
+
+	return canonical, ok
+}
+
+// AliasOption is an option of the Alias wrapper.
+type AliasOption interface {
+	applyAlias(*alias)
+}
+
+// aliasOptionFunc is an AliasOption implemented by a function.
+type aliasOptionFunc func(*alias)
+
+// applyAlias implements AliasOption.
+func (fn aliasOptionFunc) applyAlias(c *alias) { fn(c) }
+
+// WithAliasNotice makes the Alias wrapper print "ALIAS is an alias for NAME" to the Stderr of the
+// execution context before each execution.
+func WithAliasNotice() AliasOption {
+	return aliasOptionFunc(func(c *alias) {
+		c.notice = true
+	})
+}
+
+// WithAliasHidden makes the Alias wrapper report itself as hidden, so that it is omitted from the
+// command listing.
+func WithAliasHidden() AliasOption {
+	return aliasOptionFunc(func(c *alias) {
+		c.hidden = true
+	})
+}
+
+// alias wraps a subcommands.Command under another name.
+type alias struct {
+	sub    subcommands.Command
+	name   string
+	notice bool
+	hidden bool
+}
+
+// make sure alias implements the subcommands.Command interface.
+var _ subcommands.Command = (*alias)(nil)
+
+// Alias wraps sub so that it is named name, delegating everything else to sub. The alias and sub
+// can be registered in the same Commander.
+func Alias(sub subcommands.Command, name string, opts ...AliasOption) subcommands.Command {
+	c := &alias{
+		sub:  sub,
+		name: name,
+	}
+	for _, opt := range opts {
+		opt.applyAlias(c)
+	}
+
+	return c
+}
+
+// Name returns the alias name.
+func (c *alias) Name() string {
+	return c.name
+}
+
+// Usage forwards to the underlying c.sub Command.
+func (c *alias) Usage() string {
+	return c.sub.Usage()
+}
+
+// Synopsis forwards to the underlying c.sub Command.
+func (c *alias) Synopsis() string {
+	return c.sub.Synopsis()
+}
+
+// Hidden reports whether the alias was created with WithAliasHidden, or the underlying c.sub
+// Command is hidden.
+func (c *alias) Hidden() bool {
+	return c.hidden || IsHiddenCommand(c.sub)
+}
+
+// Unwrap returns the underlying c.sub Command.
+func (c *alias) Unwrap() subcommands.Command {
+	return c.sub
+}
+
+// SetFlags forwards to the underlying c.sub Command.
+func (c *alias) SetFlags(f *flag.FlagSet) {
+	c.sub.SetFlags(f)
+}
+
+// Execute prints the alias notice if enabled and forwards to the underlying c.sub Command.
+func (c *alias) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	if c.notice {
+		fmt.Fprintf(Stderr(ctx), "%s is an alias for %s\n", c.name, c.sub.Name())
+	}
+
+	return c.sub.Execute(ctx, f, args...)
+}
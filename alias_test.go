@@ -0,0 +1,144 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+func TestAliasFlag(t *testing.T) {
+	tests := map[string]struct {
+		args    []string
+		want    string
+		wantSet bool
+	}{
+		"when set via the alias": {
+			args:    []string{"-o", "a.txt"},
+			want:    "a.txt",
+			wantSet: true,
+		},
+		"when set via the canonical name": {
+			args:    []string{"-output", "b.txt"},
+			want:    "b.txt",
+			wantSet: true,
+		},
+		"when not set": {
+			want:    "out.txt",
+			wantSet: false,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			f := flag.NewFlagSet("test", flag.ContinueOnError)
+			output := f.String("output", "out.txt", "write the result to `file`")
+			subcommandsutil.AliasFlag(f, "output", "o")
+
+			if err := f.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+			if *output != tt.want {
+				t.Fatalf("wanted output to be %q but got %q", tt.want, *output)
+			}
+			for _, name := range []string{"o", "output"} {
+				if got := subcommandsutil.IsFlagSet(f, name); got != tt.wantSet {
+					t.Fatalf("wanted IsFlagSet(%q) to be %t but got %t", name, tt.wantSet, got)
+				}
+			}
+			if got := subcommandsutil.CanonicalFlagName(f, "o"); got != "output" {
+				t.Fatalf("wanted canonical name %q but got %q", "output", got)
+			}
+		})
+	}
+}
+
+func TestAliasFlagUsage(t *testing.T) {
+	var buf bytes.Buffer
+	f := flag.NewFlagSet("test", flag.ContinueOnError)
+	f.SetOutput(&buf)
+	f.String("output", "out.txt", "write the result to `file`")
+	f.Bool("verbose", false, "verbose output")
+	subcommandsutil.AliasFlag(f, "output", "o", "out")
+	subcommandsutil.AliasFlag(f, "verbose", "v")
+
+	subcommandsutil.PrintDefaults(f)
+
+	want := "" +
+		"  -o, -out, -output file\n" +
+		"    \twrite the result to file (default \"out.txt\")\n" +
+		"  -v, -verbose\n" +
+		"    \tverbose output\n"
+	if buf.String() != want {
+		t.Fatalf("wanted\n%s\nbut got\n%s", want, buf.String())
+	}
+	if strings.Contains(buf.String(), "alias for") {
+		t.Fatalf("wanted aliases to be grouped but got %q", buf.String())
+	}
+}
+
+func TestAlias(t *testing.T) {
+	deploy := testcmd.NewRecording("deploy",
+		testcmd.WithSynopsis("deploy the service"),
+		testcmd.WithUsage("deploy [-env ENV] VERSION\n"),
+		testcmd.WithFlags(func(f *flag.FlagSet) {
+			f.String("env", "staging", "target environment")
+		}),
+		testcmd.WithExecute(func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+			fmt.Fprintf(subcommandsutil.Stdout(ctx), "%s to %s\n", f.Arg(0), f.Lookup("env").Value)
+			return subcommands.ExitFailure
+		}),
+	)
+	release := subcommandsutil.Alias(deploy, "release")
+
+	h := testcmd.NewHarness(t)
+	h.Register(deploy, "")
+	h.Register(release, "")
+
+	if release.Name() != "release" || release.Synopsis() != deploy.Synopsis() || release.Usage() != deploy.Usage() {
+		t.Fatalf("wanted the alias to delegate all but its name, got %q %q %q", release.Name(), release.Synopsis(), release.Usage())
+	}
+
+	var outputs []string
+	for _, name := range []string{"deploy", "release"} {
+		h.Stdout.Reset()
+		testcmd.AssertStatus(t, h.Execute(context.Background(), name, "-env", "prod", "v1.2.3"), subcommands.ExitFailure)
+		outputs = append(outputs, h.Stdout.String())
+	}
+	if outputs[0] != "v1.2.3 to prod\n" || outputs[1] != outputs[0] {
+		t.Fatalf("wanted identical outputs but got %q", outputs)
+	}
+	if deploy.CallCount() != 2 {
+		t.Fatalf("wanted both names to run the command but got %d calls", deploy.CallCount())
+	}
+	if got := h.Stderr.String(); got != "" {
+		t.Fatalf("wanted no notice by default but got %q", got)
+	}
+	if hidden, ok := release.(interface{ Hidden() bool }); !ok || hidden.Hidden() {
+		t.Fatal("wanted the alias not to be hidden by default")
+	}
+}
+
+func TestAliasOptions(t *testing.T) {
+	rm := subcommandsutil.Alias(testcmd.NewRecording("remove"), "rm", subcommandsutil.WithAliasNotice(), subcommandsutil.WithAliasHidden())
+
+	var stderr testcmd.Buffer
+	ctx := subcommandsutil.WithOutput(context.Background(), nil, &stderr)
+	testcmd.RequireSuccess(t, rm.Execute(ctx, flag.NewFlagSet("rm", flag.ContinueOnError)))
+	if want := "rm is an alias for remove\n"; stderr.String() != want {
+		t.Fatalf("wanted notice %q but got %q", want, stderr.String())
+	}
+
+	if hidden, ok := rm.(interface{ Hidden() bool }); !ok || !hidden.Hidden() {
+		t.Fatal("wanted the alias to be hidden")
+	}
+}
@@ -0,0 +1,186 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/google/subcommands"
+)
+
+// ArgsSpec specifies the positional arguments of a Command.
+//
+//	subcommandsutil.ArgsSpec{Min: 1, Max: 2, Names: []string{"SRC", "DST"}}
+type ArgsSpec struct {
+	// Min is the minimum number of positional arguments.
+	Min int
+
+	// Max is the maximum number of positional arguments. -1 means unbounded.
+	Max int
+
+	// Names are the names of the positional arguments used in the usage line. Arguments without a
+	// name are named after the last name, or ARG if Names is empty.
+	Names []string
+}
+
+// ArgsSpecifier is implemented by a Command declaring its positional arguments.
+type ArgsSpecifier interface {
+	ArgsSpec() ArgsSpec
+}
+
+// ArgsSpecOf returns the ArgsSpec declared by cmd or by any Command it wraps.
+func ArgsSpecOf(cmd subcommands.Command) (spec ArgsSpec, ok bool) {
+	walkCommand(cmd, func(cmd subcommands.Command) bool {
+		var s ArgsSpecifier
+		s, ok = cmd.(ArgsSpecifier)
+		if ok {
+			spec = s.ArgsSpec()
+		}
+		return ok
+	})
+
+	return spec, ok
+}
+
+// name returns the name of the i-th positional argument.
+func (s ArgsSpec) name(i int) string {
+	switch {
+	case i < len(s.Names):
+		return s.Names[i]
+	case len(s.Names) > 0:
+		return s.Names[len(s.Names)-1]
+	default:
+		return "ARG"
+	}
+}
+
+// String renders the positional arguments, like "SRC [DST]" or "FILE...".
+func (s ArgsSpec) String() string {
+	var parts []string
+	for i := 0; i < s.Min; i++ {
+		parts = append(parts, s.name(i))
+	}
+
+	if s.Max < 0 {
+		switch {
+		case s.Min < len(s.Names):
+			for i := s.Min; i < len(s.Names)-1; i++ {
+				parts = append(parts, "["+s.name(i)+"]")
+			}
+			parts = append(parts, "["+s.name(len(s.Names)-1)+"...]")
+		case s.Min > 0:
+			parts[len(parts)-1] += "..."
+		default:
+			parts = append(parts, "["+s.name(0)+"...]")
+		}
+		return strings.Join(parts, " ")
+	}
+
+	for i := s.Min; i < s.Max; i++ {
+		parts = append(parts, "["+s.name(i)+"]")
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// UsageLine returns the usage line of the command named name, like "usage: cp SRC [DST]".
+func (s ArgsSpec) UsageLine(name string) string {
+	if args := s.String(); args != "" {
+		return "usage: " + name + " " + args
+	}
+
+	return "usage: " + name
+}
+
+// Validate returns an error if the number of args does not satisfy s.
+func (s ArgsSpec) Validate(args []string) error {
+	switch {
+	case len(args) < s.Min:
+		return fmt.Errorf("expected at least %d %s, got %d", s.Min, plural(s.Min, "argument"), len(args))
+	case s.Max >= 0 && len(args) > s.Max:
+		return fmt.Errorf("expected at most %d %s, got %d", s.Max, plural(s.Max, "argument"), len(args))
+	}
+
+	return nil
+}
+
+// plural returns word, pluralized unless n is 1.
+func plural(n int, word string) string {
+	if n == 1 {
+		return word
+	}
+
+	return word + "s"
+}
+
+// argsCommand wraps a subcommands.Command so that its positional arguments are validated.
+type argsCommand struct {
+	sub  subcommands.Command
+	spec ArgsSpec
+}
+
+// make sure argsCommand implements the subcommands.Command and ArgsSpecifier interfaces.
+var (
+	_ subcommands.Command = (*argsCommand)(nil)
+	_ ArgsSpecifier       = (*argsCommand)(nil)
+)
+
+// WithArgs wraps sub so that the positional arguments are validated against spec before Execute.
+// On mismatch, the error and the usage line are printed to the FlagSet's output and
+// subcommands.ExitUsageError is returned.
+func WithArgs(sub subcommands.Command, spec ArgsSpec) subcommands.Command {
+	return &argsCommand{
+		sub:  sub,
+		spec: spec,
+	}
+}
+
+// Name forwards to the underlying c.sub Command.
+func (c *argsCommand) Name() string {
+	return c.sub.Name()
+}
+
+// Usage forwards to the underlying c.sub Command.
+func (c *argsCommand) Usage() string {
+	return c.sub.Usage()
+}
+
+// Synopsis forwards to the underlying c.sub Command.
+func (c *argsCommand) Synopsis() string {
+	return c.sub.Synopsis()
+}
+
+// SetFlags forwards to the underlying c.sub Command, and records c.spec for the names of the
+// arguments reported by ArgString and the other accessors.
+func (c *argsCommand) SetFlags(f *flag.FlagSet) {
+	c.sub.SetFlags(f)
+
+	spec := c.spec
+	updateFlagMeta(f, func(m *flagMeta) {
+		m.args = &spec
+	})
+}
+
+// Unwrap returns the underlying c.sub Command.
+func (c *argsCommand) Unwrap() subcommands.Command {
+	return c.sub
+}
+
+// ArgsSpec implements ArgsSpecifier.
+func (c *argsCommand) ArgsSpec() ArgsSpec {
+	return c.spec
+}
+
+// Execute validates the positional arguments of f and forwards to the underlying c.sub Command.
+func (c *argsCommand) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	if err := c.spec.Validate(f.Args()); err != nil {
+		fmt.Fprintf(f.Output(), "%s: %v\n%s\n", c.sub.Name(), err, c.spec.UsageLine(c.sub.Name()))
+		return subcommands.ExitUsageError
+	}
+
+	return c.sub.Execute(ctx, f, args...)
+}
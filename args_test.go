@@ -0,0 +1,123 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"strings"
+	"testing"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+func TestWithArgs(t *testing.T) {
+	spec := subcommandsutil.ArgsSpec{Min: 1, Max: 2, Names: []string{"SRC", "DST"}}
+
+	tests := map[string]struct {
+		args       []string
+		wantStatus subcommands.ExitStatus
+		wantOutput string
+	}{
+		"when too few arguments are given": {
+			args:       nil,
+			wantStatus: subcommands.ExitUsageError,
+			wantOutput: "cp: expected at least 1 argument, got 0\nusage: cp SRC [DST]\n",
+		},
+		"when too many arguments are given": {
+			args:       []string{"a", "b", "c"},
+			wantStatus: subcommands.ExitUsageError,
+			wantOutput: "cp: expected at most 2 arguments, got 3\nusage: cp SRC [DST]\n",
+		},
+		"when the arguments are in range": {
+			args:       []string{"a", "b"},
+			wantStatus: subcommands.ExitSuccess,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			tcmd := testcmd.NewRecording("cp")
+			cmd := subcommandsutil.WithArgs(tcmd, spec)
+
+			var out bytes.Buffer
+			f := flag.NewFlagSet("cp", flag.ContinueOnError)
+			f.SetOutput(&out)
+			cmd.SetFlags(f)
+			if err := f.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+
+			if status := cmd.Execute(context.Background(), f); status != tt.wantStatus {
+				t.Fatalf("wanted %v but got %v", tt.wantStatus, status)
+			}
+			if out.String() != tt.wantOutput {
+				t.Fatalf("wanted output %q but got %q", tt.wantOutput, out.String())
+			}
+			if tcmd.DidFinish() != (tt.wantStatus == subcommands.ExitSuccess) {
+				t.Fatalf("wanted the command to run only with valid arguments")
+			}
+		})
+	}
+}
+
+func TestArgsSpecString(t *testing.T) {
+	tests := map[string]struct {
+		spec subcommandsutil.ArgsSpec
+		want string
+	}{
+		"required and optional": {
+			spec: subcommandsutil.ArgsSpec{Min: 1, Max: 2, Names: []string{"SRC", "DST"}},
+			want: "SRC [DST]",
+		},
+		"unbounded required": {
+			spec: subcommandsutil.ArgsSpec{Min: 1, Max: -1, Names: []string{"FILE"}},
+			want: "FILE...",
+		},
+		"unbounded optional": {
+			spec: subcommandsutil.ArgsSpec{Min: 0, Max: -1, Names: []string{"FILE"}},
+			want: "[FILE...]",
+		},
+		"unbounded after required": {
+			spec: subcommandsutil.ArgsSpec{Min: 1, Max: -1, Names: []string{"DST", "SRC"}},
+			want: "DST [SRC...]",
+		},
+		"unnamed": {
+			spec: subcommandsutil.ArgsSpec{Min: 2, Max: 2},
+			want: "ARG ARG",
+		},
+		"none": {
+			spec: subcommandsutil.ArgsSpec{},
+			want: "",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := tt.spec.String(); got != tt.want {
+				t.Fatalf("wanted %q but got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestArgsSpecOf(t *testing.T) {
+	spec := subcommandsutil.ArgsSpec{Min: 1, Max: 1, Names: []string{"NAME"}}
+	cmd := subcommandsutil.Cancelable(testcmd.NewRecording("wrapped"))
+	if _, ok := subcommandsutil.ArgsSpecOf(cmd); ok {
+		t.Fatal("wanted no ArgsSpec")
+	}
+
+	inner := subcommandsutil.WithArgs(testcmd.NewRecording("greet"), spec)
+	cmd = subcommandsutil.WithGlobalFlags(inner)
+	got, ok := subcommandsutil.ArgsSpecOf(cmd)
+	if !ok {
+		t.Fatal("wanted the ArgsSpec to be found through the wrapper")
+	}
+	if line := got.UsageLine("greet"); !strings.HasSuffix(line, "greet NAME") {
+		t.Fatalf("wanted usage line to end with %q but got %q", "greet NAME", line)
+	}
+}
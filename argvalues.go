@@ -0,0 +1,104 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"errors"
+	"flag"
+	"io/fs"
+	"os"
+	"strconv"
+	"time"
+)
+
+// ArgString returns the i-th positional argument of f. The error of a missing argument is created by
+// UsageErrorf, so CommandFuncE and StatusFromError map it to subcommands.ExitUsageError:
+//
+//	missing argument NAME
+//
+// The argument is named name in the errors of ArgString and the other accessors. With an empty
+// name, it is named after the ArgsSpec of the WithArgs wrapping the command, or ARG.
+func ArgString(f *flag.FlagSet, i int, name string) (string, error) {
+	if i < 0 || i >= f.NArg() {
+		return "", UsageErrorf("missing argument %s", argName(f, i, name))
+	}
+
+	return f.Arg(i), nil
+}
+
+// ArgInt returns the i-th positional argument of f parsed as a decimal integer, like ArgString.
+// An invalid integer is reported like:
+//
+//	argument PORT: invalid integer "abc"
+func ArgInt(f *flag.FlagSet, i int, name string) (int, error) {
+	s, err := ArgString(f, i, name)
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		if errors.Is(err, strconv.ErrRange) {
+			return 0, UsageErrorf("argument %s: integer %q out of range", argName(f, i, name), s)
+		}
+		return 0, UsageErrorf("argument %s: invalid integer %q", argName(f, i, name), s)
+	}
+
+	return n, nil
+}
+
+// ArgDuration returns the i-th positional argument of f parsed by time.ParseDuration, like
+// ArgString. An invalid duration is reported like:
+//
+//	argument TIMEOUT: invalid duration "soon"
+func ArgDuration(f *flag.FlagSet, i int, name string) (time.Duration, error) {
+	s, err := ArgString(f, i, name)
+	if err != nil {
+		return 0, err
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, UsageErrorf("argument %s: invalid duration %q", argName(f, i, name), s)
+	}
+
+	return d, nil
+}
+
+// ArgExistingFile returns the i-th positional argument of f, like ArgString, if it is the path of
+// an existing file which is not a directory. Otherwise the error is reported like:
+//
+//	argument FILE: file "missing.txt" does not exist
+func ArgExistingFile(f *flag.FlagSet, i int, name string) (string, error) {
+	path, err := ArgString(f, i, name)
+	if err != nil {
+		return "", err
+	}
+	fi, err := os.Stat(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return "", UsageErrorf("argument %s: file %q does not exist", argName(f, i, name), path)
+	case err != nil:
+		return "", UsageErrorf("argument %s: %v", argName(f, i, name), err)
+	case fi.IsDir():
+		return "", UsageErrorf("argument %s: %q is a directory", argName(f, i, name), path)
+	}
+
+	return path, nil
+}
+
+// argName returns the name of the i-th positional argument of f in the errors of the accessors:
+// name, or the name in the ArgsSpec recorded by WithArgs, or ARG.
+func argName(f *flag.FlagSet, i int, name string) string {
+	if name != "" {
+		return name
+	}
+
+	var spec ArgsSpec
+	readFlagMeta(f, func(m *flagMeta) {
+		if m != nil && m.args != nil {
+			spec = *m.args
+		}
+	})
+
+	return spec.name(i)
+}
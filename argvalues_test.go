@@ -0,0 +1,147 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+func TestArgValues(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "config.toml")
+	if err := os.WriteFile(file, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	missing := filepath.Join(dir, "missing.toml")
+
+	tests := map[string]struct {
+		args    []string
+		spec    *subcommandsutil.ArgsSpec
+		get     func(f *flag.FlagSet) (interface{}, error)
+		want    interface{}
+		wantErr string
+	}{
+		"when the string is given": {
+			args: []string{"alice"},
+			get: func(f *flag.FlagSet) (interface{}, error) {
+				return subcommandsutil.ArgString(f, 0, "USER")
+			},
+			want: "alice",
+		},
+		"when the string is missing": {
+			get: func(f *flag.FlagSet) (interface{}, error) {
+				return subcommandsutil.ArgString(f, 0, "USER")
+			},
+			wantErr: "missing argument USER",
+		},
+		"when the missing argument is named by the ArgsSpec": {
+			args: []string{"localhost"},
+			spec: &subcommandsutil.ArgsSpec{Min: 1, Max: 2, Names: []string{"HOST", "PORT"}},
+			get: func(f *flag.FlagSet) (interface{}, error) {
+				return subcommandsutil.ArgInt(f, 1, "")
+			},
+			wantErr: "missing argument PORT",
+		},
+		"when the missing argument has no name": {
+			get: func(f *flag.FlagSet) (interface{}, error) {
+				return subcommandsutil.ArgString(f, 0, "")
+			},
+			wantErr: "missing argument ARG",
+		},
+		"when the integer is valid": {
+			args: []string{"8080"},
+			get: func(f *flag.FlagSet) (interface{}, error) {
+				return subcommandsutil.ArgInt(f, 0, "PORT")
+			},
+			want: 8080,
+		},
+		"when the integer is invalid": {
+			args: []string{"abc"},
+			get: func(f *flag.FlagSet) (interface{}, error) {
+				return subcommandsutil.ArgInt(f, 0, "PORT")
+			},
+			wantErr: `argument PORT: invalid integer "abc"`,
+		},
+		"when the integer is out of range": {
+			args: []string{"99999999999999999999"},
+			get: func(f *flag.FlagSet) (interface{}, error) {
+				return subcommandsutil.ArgInt(f, 0, "PORT")
+			},
+			wantErr: `argument PORT: integer "99999999999999999999" out of range`,
+		},
+		"when the duration is valid": {
+			args: []string{"1m30s"},
+			get: func(f *flag.FlagSet) (interface{}, error) {
+				return subcommandsutil.ArgDuration(f, 0, "TIMEOUT")
+			},
+			want: 90 * time.Second,
+		},
+		"when the duration is invalid": {
+			args: []string{"soon"},
+			get: func(f *flag.FlagSet) (interface{}, error) {
+				return subcommandsutil.ArgDuration(f, 0, "TIMEOUT")
+			},
+			wantErr: `argument TIMEOUT: invalid duration "soon"`,
+		},
+		"when the file exists": {
+			args: []string{file},
+			get: func(f *flag.FlagSet) (interface{}, error) {
+				return subcommandsutil.ArgExistingFile(f, 0, "FILE")
+			},
+			want: file,
+		},
+		"when the file does not exist": {
+			args: []string{missing},
+			get: func(f *flag.FlagSet) (interface{}, error) {
+				return subcommandsutil.ArgExistingFile(f, 0, "FILE")
+			},
+			wantErr: fmt.Sprintf("argument FILE: file %q does not exist", missing),
+		},
+		"when the file is a directory": {
+			args: []string{dir},
+			get: func(f *flag.FlagSet) (interface{}, error) {
+				return subcommandsutil.ArgExistingFile(f, 0, "FILE")
+			},
+			wantErr: fmt.Sprintf("argument FILE: %q is a directory", dir),
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			f := flag.NewFlagSet("serve", flag.ContinueOnError)
+			if tt.spec != nil {
+				subcommandsutil.WithArgs(testcmd.NewRecording("serve"), *tt.spec).SetFlags(f)
+			}
+			if err := f.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := tt.get(f)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("wanted the error %q but got %v", tt.wantErr, err)
+				}
+				if status := subcommandsutil.StatusFromError(err); status != subcommands.ExitUsageError {
+					t.Fatalf("wanted the status %d but got %d", subcommands.ExitUsageError, status)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Fatalf("wanted %v but got %v", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,238 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/subcommands"
+)
+
+// benchmarkSamples is the number of durations Benchmarked keeps for the percentiles. Beyond it, the
+// kept durations are a uniform sample of all of them.
+const benchmarkSamples = 10000
+
+// BenchmarkResult is the summary of the runs of Benchmarked, emitted by EmitResult, like:
+//
+//	{"command":"query","runs":20,"min_ns":1000000,"p50_ns":5000000,"p90_ns":9000000,"max_ns":10000000,"mean_ns":5500000,"statuses":{"0":20}}
+type BenchmarkResult struct {
+	// Command is the name of the command.
+	Command string `json:"command"`
+
+	// Runs is the number of measured runs, without the warmup runs.
+	Runs int `json:"runs"`
+
+	// Min, P50, P90, Max and Mean are the shortest, median, 90th percentile, longest and average
+	// durations of the runs.
+	Min  time.Duration `json:"min_ns"`
+	P50  time.Duration `json:"p50_ns"`
+	P90  time.Duration `json:"p90_ns"`
+	Max  time.Duration `json:"max_ns"`
+	Mean time.Duration `json:"mean_ns"`
+
+	// Statuses counts the runs by their status.
+	Statuses map[subcommands.ExitStatus]int `json:"statuses"`
+}
+
+// BenchmarkOption is an option of the Benchmarked wrapper.
+type BenchmarkOption interface {
+	applyBenchmark(*benchmarked)
+}
+
+// applyBenchmark implements BenchmarkOption.
+func (o LoggerOption) applyBenchmark(c *benchmarked) {
+	c.logger = o.logger
+}
+
+// benchmarked wraps a subcommands.Command so that it can be run repeatedly and timed.
+type benchmarked struct {
+	sub    subcommands.Command
+	logger Logger
+
+	runs   int
+	warmup int
+}
+
+// make sure benchmarked implements the subcommands.Command interface.
+var _ subcommands.Command = (*benchmarked)(nil)
+
+// Benchmarked wraps sub with the -bench and -bench-warmup flags. When -bench is set to n, sub is
+// run -bench-warmup times, then n times measuring the durations of the runs on the Clock of the
+// execution context, with its Stdout and Stderr discarded and no results collected.
+//
+// The shortest, median, 90th percentile, longest and average durations and the count of the
+// statuses are logged to the standard logger unless WithLogger is given, and emitted as a
+// BenchmarkResult by EmitResult. The runs stop when the context is done, and the first status other
+// than subcommands.ExitSuccess is returned; subcommands.ExitFailure if the context is done.
+func Benchmarked(sub subcommands.Command, opts ...BenchmarkOption) subcommands.Command {
+	c := &benchmarked{
+		sub:    sub,
+		logger: stdLogger{},
+	}
+	for _, opt := range opts {
+		opt.applyBenchmark(c)
+	}
+
+	return c
+}
+
+// Name forwards to the underlying c.sub Command.
+func (c *benchmarked) Name() string {
+	return c.sub.Name()
+}
+
+// Usage forwards to the underlying c.sub Command.
+func (c *benchmarked) Usage() string {
+	return c.sub.Usage()
+}
+
+// Synopsis forwards to the underlying c.sub Command.
+func (c *benchmarked) Synopsis() string {
+	return c.sub.Synopsis()
+}
+
+// Unwrap returns the underlying c.sub Command.
+func (c *benchmarked) Unwrap() subcommands.Command {
+	return c.sub
+}
+
+// SetFlags forwards to the underlying c.sub Command and registers the -bench and -bench-warmup
+// flags.
+func (c *benchmarked) SetFlags(f *flag.FlagSet) {
+	c.sub.SetFlags(f)
+	f.IntVar(&c.runs, "bench", 0, "run `n` times silently and print the timing statistics")
+	f.IntVar(&c.warmup, "bench-warmup", 0, "run `n` times before the measured runs of -bench")
+}
+
+// Execute forwards to the underlying c.sub Command, -bench times if set.
+func (c *benchmarked) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	if c.runs <= 0 {
+		return c.sub.Execute(ctx, f, args...)
+	}
+
+	// the runs print nothing and emit no result
+	runCtx := context.WithValue(WithOutput(ctx, io.Discard, io.Discard), emitterKey{}, (*Emitter)(nil))
+	for i := 0; i < c.warmup; i++ {
+		if ctx.Err() != nil {
+			return subcommands.ExitFailure
+		}
+		c.sub.Execute(runCtx, f, args...)
+	}
+
+	clk := ClockFromContext(ctx)
+	status := subcommands.ExitSuccess
+	stats := newBenchmarkStats()
+	for i := 0; i < c.runs; i++ {
+		if ctx.Err() != nil {
+			status = subcommands.ExitFailure
+			break
+		}
+
+		start := clk.Now()
+		s := c.sub.Execute(runCtx, f, args...)
+		stats.add(clk.Now().Sub(start), s)
+		if s != subcommands.ExitSuccess && status == subcommands.ExitSuccess {
+			status = s
+		}
+	}
+
+	r := stats.result(c.sub.Name())
+	contextLogger(ctx, c.logger).Printf("%s: %d runs; min %v, p50 %v, p90 %v, max %v, mean %v; statuses %s", r.Command, r.Runs, r.Min, r.P50, r.P90, r.Max, r.Mean, formatStatuses(r.Statuses))
+	EmitResult(ctx, r)
+
+	return status
+}
+
+// benchmarkStats accumulates the durations and statuses of the runs of Benchmarked.
+type benchmarkStats struct {
+	runs     int
+	total    time.Duration
+	min, max time.Duration
+	samples  []time.Duration // all the durations, or a uniform sample of benchmarkSamples of them
+	statuses map[subcommands.ExitStatus]int
+	rand     *rand.Rand
+}
+
+// newBenchmarkStats returns empty benchmarkStats.
+func newBenchmarkStats() *benchmarkStats {
+	return &benchmarkStats{
+		statuses: make(map[subcommands.ExitStatus]int),
+		rand:     rand.New(rand.NewSource(1)),
+	}
+}
+
+// add records a run of duration d exiting with status.
+func (s *benchmarkStats) add(d time.Duration, status subcommands.ExitStatus) {
+	s.runs++
+	s.total += d
+	if s.runs == 1 || d < s.min {
+		s.min = d
+	}
+	if d > s.max {
+		s.max = d
+	}
+	s.statuses[status]++
+
+	// reservoir sampling, keeping each of the durations with the same probability
+	if len(s.samples) < benchmarkSamples {
+		s.samples = append(s.samples, d)
+	} else if j := s.rand.Intn(s.runs); j < benchmarkSamples {
+		s.samples[j] = d
+	}
+}
+
+// result returns the BenchmarkResult of the runs of the command named name.
+func (s *benchmarkStats) result(name string) BenchmarkResult {
+	r := BenchmarkResult{
+		Command:  name,
+		Runs:     s.runs,
+		Min:      s.min,
+		Max:      s.max,
+		Statuses: s.statuses,
+	}
+	if s.runs == 0 {
+		return r
+	}
+
+	sort.Slice(s.samples, func(i, j int) bool { return s.samples[i] < s.samples[j] })
+	r.P50 = percentile(s.samples, 50)
+	r.P90 = percentile(s.samples, 90)
+	r.Mean = s.total / time.Duration(s.runs)
+
+	return r
+}
+
+// percentile returns the p-th percentile of the sorted durations by the nearest-rank method: the
+// smallest duration at least p percent of the durations are shorter than or equal to.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := (p*len(sorted) + 99) / 100 // ceil(p/100 * n)
+	if rank < 1 {
+		rank = 1
+	}
+
+	return sorted[rank-1]
+}
+
+// formatStatuses renders the counts of statuses, like "0=18 1=2".
+func formatStatuses(statuses map[subcommands.ExitStatus]int) string {
+	keys := make([]int, 0, len(statuses))
+	for s := range statuses {
+		keys = append(keys, int(s))
+	}
+	sort.Ints(keys)
+
+	parts := make([]string, len(keys))
+	for i, s := range keys {
+		parts[i] = fmt.Sprintf("%d=%d", s, statuses[subcommands.ExitStatus(s)])
+	}
+
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,145 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+func TestBenchmarked(t *testing.T) {
+	ms := time.Millisecond
+	tests := map[string]struct {
+		args       []string
+		latencies  []time.Duration
+		failAt     int
+		cancelAt   int
+		wantStatus subcommands.ExitStatus
+		want       subcommandsutil.BenchmarkResult
+		wantLog    string
+	}{
+		"when the runs have variable latencies": {
+			args:       []string{"-bench", "10", "-bench-warmup", "2"},
+			latencies:  []time.Duration{100 * ms, 100 * ms, 3 * ms, 7 * ms, 1 * ms, 10 * ms, 5 * ms, 2 * ms, 9 * ms, 4 * ms, 8 * ms, 6 * ms},
+			failAt:     6,
+			wantStatus: subcommands.ExitFailure,
+			want: subcommandsutil.BenchmarkResult{
+				Command:  "query",
+				Runs:     10,
+				Min:      1 * ms,
+				P50:      5 * ms,
+				P90:      9 * ms,
+				Max:      10 * ms,
+				Mean:     5500 * time.Microsecond,
+				Statuses: map[subcommands.ExitStatus]int{subcommands.ExitSuccess: 9, subcommands.ExitFailure: 1},
+			},
+			wantLog: "query: 10 runs; min 1ms, p50 5ms, p90 9ms, max 10ms, mean 5.5ms; statuses 0=9 1=1",
+		},
+		"when there is a single run": {
+			args:      []string{"-bench", "1"},
+			latencies: []time.Duration{3 * ms},
+			want: subcommandsutil.BenchmarkResult{
+				Command:  "query",
+				Runs:     1,
+				Min:      3 * ms,
+				P50:      3 * ms,
+				P90:      3 * ms,
+				Max:      3 * ms,
+				Mean:     3 * ms,
+				Statuses: map[subcommands.ExitStatus]int{subcommands.ExitSuccess: 1},
+			},
+			wantLog: "query: 1 runs; min 3ms, p50 3ms, p90 3ms, max 3ms, mean 3ms; statuses 0=1",
+		},
+		"when the context is canceled between the runs": {
+			args:       []string{"-bench", "5"},
+			latencies:  []time.Duration{2 * ms, 4 * ms, 6 * ms, 8 * ms, 10 * ms},
+			cancelAt:   2,
+			wantStatus: subcommands.ExitFailure,
+			want: subcommandsutil.BenchmarkResult{
+				Command:  "query",
+				Runs:     2,
+				Min:      2 * ms,
+				P50:      2 * ms,
+				P90:      4 * ms,
+				Max:      4 * ms,
+				Mean:     3 * ms,
+				Statuses: map[subcommands.ExitStatus]int{subcommands.ExitSuccess: 2},
+			},
+			wantLog: "query: 2 runs; min 2ms, p50 2ms, p90 4ms, max 4ms, mean 3ms; statuses 0=2",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			clk := testcmd.NewFakeClock(time.Now())
+			ctx, cancel := context.WithCancel(subcommandsutil.WithClock(context.Background(), clk))
+			defer cancel()
+			var stdout testcmd.Buffer
+			ctx = subcommandsutil.WithOutput(ctx, &stdout, &testcmd.Buffer{})
+
+			run := 0
+			sub := testcmd.NewRecording("query", testcmd.WithExecute(func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+				d := tt.latencies[run]
+				run++
+				fmt.Fprintf(subcommandsutil.Stdout(ctx), "run %d\n", run)
+				subcommandsutil.EmitResult(ctx, run)
+				clk.Advance(d)
+				if run == tt.cancelAt {
+					cancel()
+				}
+				if run == tt.failAt {
+					return subcommands.ExitFailure
+				}
+				return subcommands.ExitSuccess
+			}))
+			var logs testcmd.LogRecorder
+			cmd := subcommandsutil.Benchmarked(sub, subcommandsutil.WithLogger(&logs))
+
+			f := flag.NewFlagSet("query", flag.ContinueOnError)
+			cmd.SetFlags(f)
+			if err := f.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+			got, status, err := subcommandsutil.ExecuteForResult[subcommandsutil.BenchmarkResult](ctx, cmd, f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			testcmd.AssertStatus(t, status, tt.wantStatus)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("wanted %+v but got %+v", tt.want, got)
+			}
+			if !logs.Contains(tt.wantLog) {
+				t.Fatalf("wanted the summary %q logged but got %q", tt.wantLog, logs.Lines())
+			}
+			if stdout.String() != "" {
+				t.Fatalf("wanted the output of the runs discarded but got %q", stdout.String())
+			}
+		})
+	}
+}
+
+func TestBenchmarkedDisabled(t *testing.T) {
+	var stdout testcmd.Buffer
+	sub := testcmd.NewRecording("query", testcmd.WithExecute(func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+		fmt.Fprintln(subcommandsutil.Stdout(ctx), "rows")
+		return subcommands.ExitSuccess
+	}))
+
+	status, _, _ := testcmd.Run(subcommandsutil.WithOutput(context.Background(), &stdout, nil), subcommandsutil.Benchmarked(sub))
+	testcmd.RequireSuccess(t, status)
+	if stdout.String() != "rows\n" {
+		t.Fatalf("wanted the output %q but got %q", "rows\n", stdout.String())
+	}
+	if got := sub.CallCount(); got != 1 {
+		t.Fatalf("wanted 1 run but got %d", got)
+	}
+}
@@ -0,0 +1,126 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"context"
+	"flag"
+
+	"github.com/google/subcommands"
+)
+
+// RunFunc is the function executing a command built by Builder.
+type RunFunc func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus
+
+// Builder builds a CancelableCommand from functions. For example:
+//
+//	cmd := subcommandsutil.New("prune").
+//		Synopsis("remove stale entries").
+//		Usage("prune [-n]\n").
+//		Flags(func(f *flag.FlagSet) { f.BoolVar(&dryRun, "n", false, "dry run") }).
+//		Run(prune).
+//		Build()
+type Builder struct {
+	cmd builtCommand
+}
+
+// New returns a Builder of a command named name.
+func New(name string) *Builder {
+	return &Builder{
+		cmd: builtCommand{name: name},
+	}
+}
+
+// Synopsis sets the Synopsis of the command.
+func (b *Builder) Synopsis(synopsis string) *Builder {
+	b.cmd.synopsis = synopsis
+	return b
+}
+
+// Usage sets the Usage of the command.
+func (b *Builder) Usage(usage string) *Builder {
+	b.cmd.usage = usage
+	return b
+}
+
+// Flags sets the function defining the flags of the command in SetFlags.
+func (b *Builder) Flags(fn func(f *flag.FlagSet)) *Builder {
+	b.cmd.flags = fn
+	return b
+}
+
+// Dispose sets the function called by Dispose. Without one, Dispose does nothing.
+func (b *Builder) Dispose(fn func() error) *Builder {
+	b.cmd.dispose = fn
+	return b
+}
+
+// Run sets the function executing the command.
+func (b *Builder) Run(fn RunFunc) *Builder {
+	b.cmd.run = fn
+	return b
+}
+
+// Build returns the built command. Later changes of b do not affect it. Build panics if the name
+// or the run function is not set.
+func (b *Builder) Build() CancelableCommand {
+	if b.cmd.name == "" {
+		panic("subcommandsutil: building a command without name")
+	}
+	if b.cmd.run == nil {
+		panic("subcommandsutil: building command " + b.cmd.name + " without Run func")
+	}
+
+	cmd := b.cmd
+	return &cmd
+}
+
+// builtCommand is the command built by Builder.
+type builtCommand struct {
+	name     string
+	synopsis string
+	usage    string
+	flags    func(f *flag.FlagSet)
+	dispose  func() error
+	run      RunFunc
+}
+
+// make sure builtCommand implements the CancelableCommand interface.
+var _ CancelableCommand = (*builtCommand)(nil)
+
+// Name implements subcommands.Command.
+func (c *builtCommand) Name() string {
+	return c.name
+}
+
+// Synopsis implements subcommands.Command.
+func (c *builtCommand) Synopsis() string {
+	return c.synopsis
+}
+
+// Usage implements subcommands.Command.
+func (c *builtCommand) Usage() string {
+	return c.usage
+}
+
+// SetFlags implements subcommands.Command.
+func (c *builtCommand) SetFlags(f *flag.FlagSet) {
+	if c.flags != nil {
+		c.flags(f)
+	}
+}
+
+// Execute implements subcommands.Command.
+func (c *builtCommand) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	return c.run(ctx, f, args...)
+}
+
+// Dispose implements CancelableCommand.
+func (c *builtCommand) Dispose() error {
+	if c.dispose == nil {
+		return nil
+	}
+
+	return c.dispose()
+}
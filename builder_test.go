@@ -0,0 +1,114 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+func TestBuilder(t *testing.T) {
+	defer testcmd.VerifyNoLeaks(t)
+
+	var (
+		dryRun   bool
+		disposed int32
+		started  = make(chan struct{})
+	)
+	cmd := subcommandsutil.New("prune").
+		Synopsis("remove stale entries").
+		Usage("prune [-n]\n").
+		Flags(func(f *flag.FlagSet) {
+			f.BoolVar(&dryRun, "n", false, "dry run")
+		}).
+		Dispose(func() error {
+			atomic.AddInt32(&disposed, 1)
+			return nil
+		}).
+		Run(func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+			if f.Arg(0) == "wait" {
+				close(started)
+				<-ctx.Done()
+				return subcommands.ExitFailure
+			}
+			fmt.Fprintf(subcommandsutil.Stdout(ctx), "pruning %s (dry run: %v)\n", f.Arg(0), dryRun)
+			return subcommands.ExitSuccess
+		}).
+		Build()
+
+	if cmd.Name() != "prune" || cmd.Synopsis() != "remove stale entries" || cmd.Usage() != "prune [-n]\n" {
+		t.Fatalf("wanted the metadata to be set, got %q %q %q", cmd.Name(), cmd.Synopsis(), cmd.Usage())
+	}
+
+	h := testcmd.NewHarness(t)
+	wrapped := subcommandsutil.RegisterCancelable(h.Commander, cmd, "", subcommandsutil.WithLogger(&testcmd.LogRecorder{}))
+
+	testcmd.RequireSuccess(t, h.Execute(context.Background(), "prune", "-n", "cache"))
+	if want := "pruning cache (dry run: true)\n"; h.Stdout.String() != want {
+		t.Fatalf("wanted %q but got %q", want, h.Stdout.String())
+	}
+
+	done := make(chan subcommands.ExitStatus, 1)
+	go func() {
+		done <- h.Execute(context.Background(), "prune", "wait")
+	}()
+	<-started
+	wrapped.Stop()
+	testcmd.AssertStatus(t, <-done, subcommands.ExitFailure)
+	if got := atomic.LoadInt32(&disposed); got != 1 {
+		t.Fatalf("wanted Dispose to be called once but got %d", got)
+	}
+}
+
+func TestBuilderDefaults(t *testing.T) {
+	cmd := subcommandsutil.New("noop").Run(func(context.Context, *flag.FlagSet, ...interface{}) subcommands.ExitStatus {
+		return subcommands.ExitSuccess
+	}).Build()
+
+	if err := cmd.Dispose(); err != nil {
+		t.Fatalf("wanted Dispose without func to succeed but got %v", err)
+	}
+	status, _, err := testcmd.Run(context.Background(), cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testcmd.RequireSuccess(t, status)
+}
+
+func TestBuilderPanics(t *testing.T) {
+	run := func(context.Context, *flag.FlagSet, ...interface{}) subcommands.ExitStatus {
+		return subcommands.ExitSuccess
+	}
+	tests := map[string]struct {
+		builder   *subcommandsutil.Builder
+		wantPanic string
+	}{
+		"when the name is empty": {
+			builder:   subcommandsutil.New("").Run(run),
+			wantPanic: "subcommandsutil: building a command without name",
+		},
+		"when the run func is missing": {
+			builder:   subcommandsutil.New("prune"),
+			wantPanic: "subcommandsutil: building command prune without Run func",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				if r := recover(); r != tt.wantPanic {
+					t.Fatalf("wanted panic %q but got %v", tt.wantPanic, r)
+				}
+			}()
+			tt.builder.Build()
+		})
+	}
+}
@@ -0,0 +1,290 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/google/subcommands"
+)
+
+// CacheStore stores the results cached by Cached, by key. It must be safe for concurrent use.
+type CacheStore interface {
+	// Get returns the data stored for key, and whether there is any.
+	Get(key string) (data []byte, ok bool)
+
+	// Put stores data for key, replacing the previous data.
+	Put(key string, data []byte) error
+}
+
+// memoryCacheStore is a CacheStore in memory.
+type memoryCacheStore struct {
+	mu      sync.Mutex
+	entries map[string][]byte
+}
+
+// NewMemoryCacheStore returns a CacheStore keeping the data in memory, for the duration of the
+// process.
+func NewMemoryCacheStore() CacheStore {
+	return &memoryCacheStore{
+		entries: make(map[string][]byte),
+	}
+}
+
+// Get implements CacheStore.
+func (s *memoryCacheStore) Get(key string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, ok := s.entries[key]
+	return data, ok
+}
+
+// Put implements CacheStore.
+func (s *memoryCacheStore) Put(key string, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.entries[key] = append([]byte(nil), data...)
+	return nil
+}
+
+// fileCacheStore is a CacheStore in a directory, one file per key.
+type fileCacheStore struct {
+	dir string
+}
+
+// NewFileCacheStore returns a CacheStore keeping the data in the "results" directory of the
+// CacheDir of the application appName, creating it if it does not exist.
+func NewFileCacheStore(appName string) (CacheStore, error) {
+	dir, err := CacheDir(appName)
+	if err != nil {
+		return nil, err
+	}
+	dir = filepath.Join(dir, "results")
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+
+	return &fileCacheStore{dir: dir}, nil
+}
+
+// Get implements CacheStore.
+func (s *fileCacheStore) Get(key string) ([]byte, bool) {
+	data, err := os.ReadFile(filepath.Join(s.dir, key))
+	return data, err == nil
+}
+
+// Put implements CacheStore.
+func (s *fileCacheStore) Put(key string, data []byte) error {
+	return writeFileAtomic(filepath.Join(s.dir, key), data, 0o600)
+}
+
+// cacheEntry is the data stored by Cached.
+type cacheEntry struct {
+	Time    time.Time              `json:"time"`
+	Status  subcommands.ExitStatus `json:"status"`
+	Results []json.RawMessage      `json:"results"`
+}
+
+// cached wraps a subcommands.Command so that its results are cached.
+type cached struct {
+	sub   subcommands.Command
+	store CacheStore
+	ttl   time.Duration
+
+	decode func(data []byte) (interface{}, error) // decodes a cached result, if set
+
+	noCache bool
+}
+
+// make sure cached implements the subcommands.Command interface.
+var _ subcommands.Command = (*cached)(nil)
+
+// CachedOption is an option of the Cached wrapper.
+type CachedOption interface {
+	applyCached(*cached)
+}
+
+// cachedOptionFunc is a CachedOption implemented by a function.
+type cachedOptionFunc func(*cached)
+
+// applyCached implements CachedOption.
+func (fn cachedOptionFunc) applyCached(c *cached) { fn(c) }
+
+// WithCachedResult makes Cached decode the cached results into values of type T, the type of the
+// results emitted by the command, so that they are emitted again as such. Without it, the cached
+// results are JSON documents, only emitted again to the json encoder of ResultCommand.
+func WithCachedResult[T any]() CachedOption {
+	return cachedOptionFunc(func(c *cached) {
+		c.decode = func(data []byte) (interface{}, error) {
+			var v T
+			err := json.Unmarshal(data, &v)
+			return v, err
+		}
+	})
+}
+
+// Cached wraps sub so that the results it emits with EmitResult, and its status, are cached in
+// store for ttl, measured on the Clock of the execution context. Wrap it in ResultCommand, or
+// execute it with ExecuteForResult: the results are only cached when they are requested.
+//
+// The results are keyed by the name of sub, the flags set explicitly, the sensitive ones by the
+// hash of their value, and the positional arguments. While fresh, they are emitted again and the
+// status returned without executing sub. Only the json encoder is given the cached results unless
+// WithCachedResult decodes them: the other encoders, -format and ExecuteForResult execute sub, as
+// they do when the cached results cannot be decoded. Only a successful execution is cached. The
+// -no-cache flag registered by the wrapper bypasses the cache, whose results are then refreshed. A
+// store failing to store the results is warned about on Stderr.
+func Cached(sub subcommands.Command, store CacheStore, ttl time.Duration, opts ...CachedOption) subcommands.Command {
+	c := &cached{
+		sub:   sub,
+		store: store,
+		ttl:   ttl,
+	}
+	for _, opt := range opts {
+		opt.applyCached(c)
+	}
+
+	return c
+}
+
+// Name forwards to the underlying c.sub Command.
+func (c *cached) Name() string {
+	return c.sub.Name()
+}
+
+// Usage forwards to the underlying c.sub Command.
+func (c *cached) Usage() string {
+	return c.sub.Usage()
+}
+
+// Synopsis forwards to the underlying c.sub Command.
+func (c *cached) Synopsis() string {
+	return c.sub.Synopsis()
+}
+
+// Unwrap returns the underlying c.sub Command.
+func (c *cached) Unwrap() subcommands.Command {
+	return c.sub
+}
+
+// SetFlags forwards to the underlying c.sub Command and registers the -no-cache flag.
+func (c *cached) SetFlags(f *flag.FlagSet) {
+	c.sub.SetFlags(f)
+	f.BoolVar(&c.noCache, "no-cache", false, "execute the command even if its results are cached")
+}
+
+// Execute emits the cached results if they are fresh, or forwards to the underlying c.sub Command
+// and caches its results.
+func (c *cached) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	e := EmitterFromContext(ctx)
+	if e == nil {
+		return c.sub.Execute(ctx, f, args...)
+	}
+
+	clk := ClockFromContext(ctx)
+	key := cacheKey(f, c.sub.Name())
+	if !c.noCache && (c.decode != nil || e.json) {
+		if entry, ok := c.lookup(key, clk.Now()); ok {
+			if results, ok := c.decodeResults(entry.Results); ok {
+				for _, r := range results {
+					e.Emit(r)
+				}
+				return entry.Status
+			}
+		}
+	}
+
+	inner := &Emitter{}
+	status := c.sub.Execute(context.WithValue(ctx, emitterKey{}, inner), f, args...)
+	entry := cacheEntry{Time: clk.Now(), Status: status}
+	var encodeErr error
+	for _, r := range inner.Results() {
+		e.Emit(r)
+		data, err := json.Marshal(r)
+		encodeErr = errors.Join(encodeErr, err)
+		entry.Results = append(entry.Results, data)
+	}
+	if status != subcommands.ExitSuccess || encodeErr != nil {
+		return status
+	}
+
+	data, err := json.Marshal(entry)
+	if err == nil {
+		err = c.store.Put(key, data)
+	}
+	if err != nil {
+		fmt.Fprintf(Stderr(ctx), "warning: caching the results of %s: %v\n", c.sub.Name(), err)
+	}
+
+	return status
+}
+
+// lookup returns the entry of key if it is fresh at now.
+func (c *cached) lookup(key string, now time.Time) (entry cacheEntry, ok bool) {
+	data, ok := c.store.Get(key)
+	if !ok || json.Unmarshal(data, &entry) != nil {
+		return cacheEntry{}, false
+	}
+
+	return entry, now.Sub(entry.Time) < c.ttl
+}
+
+// decodeResults returns the cached results decoded by c.decode, or as they are if it is not set,
+// and whether they all could be decoded.
+func (c *cached) decodeResults(data []json.RawMessage) ([]interface{}, bool) {
+	results := make([]interface{}, 0, len(data))
+	for _, d := range data {
+		if c.decode == nil {
+			results = append(results, d)
+			continue
+		}
+		r, err := c.decode(d)
+		if err != nil {
+			return nil, false
+		}
+		results = append(results, r)
+	}
+
+	return results, true
+}
+
+// cacheKey returns the key of the execution of the command named name with the flags f: the hash
+// of its name, its explicitly set flags but -no-cache, with the sensitive values hashed, and its
+// positional arguments.
+func cacheKey(f *flag.FlagSet, name string) string {
+	// the sensitive values are hashed rather than Redacted, so that they still tell the keys apart
+	values := flagValues(f, func(fl *flag.Flag) string {
+		value := fl.Value.String()
+		if IsSensitiveFlag(f, fl.Name) {
+			sum := sha256.Sum256([]byte(value))
+			value = "sha256:" + hex.EncodeToString(sum[:])
+		}
+		return value
+	})
+	parts := []string{name}
+	for _, name := range sortedFlagNames(values) {
+		if v := values[name]; v.Set && name != "no-cache" {
+			parts = append(parts, "-"+name+"="+v.Value)
+		}
+	}
+	parts = append(parts, "--")
+	parts = append(parts, f.Args()...)
+
+	data, _ := json.Marshal(parts)
+	sum := sha256.Sum256(data)
+
+	return hex.EncodeToString(sum[:])
+}
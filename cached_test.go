@@ -0,0 +1,205 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"context"
+	"flag"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+func TestCached(t *testing.T) {
+	clk := testcmd.NewFakeClock(time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC))
+	status := subcommands.ExitSuccess
+	calls := 0
+	sub := testcmd.NewRecording("query",
+		testcmd.WithFlags(func(f *flag.FlagSet) {
+			f.String("token", "", "API token")
+			subcommandsutil.MarkSensitive(f, "token")
+		}),
+		testcmd.WithExecute(func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+			calls++
+			subcommandsutil.EmitResult(ctx, map[string]interface{}{"call": calls, "args": f.Args()})
+			return status
+		}),
+	)
+	cmd := subcommandsutil.ResultCommand(subcommandsutil.Cached(sub, subcommandsutil.NewMemoryCacheStore(), time.Minute))
+
+	// the steps share the cache, in order
+	steps := []struct {
+		name       string
+		args       []string
+		advance    time.Duration
+		status     subcommands.ExitStatus
+		wantStdout string
+	}{
+		{name: "when the cache is empty", args: []string{"-json", "-token", "a", "users"}, wantStdout: `{"args":["users"],"call":1}`},
+		{name: "when the results are cached", args: []string{"-json", "-token", "a", "users"}, advance: 59 * time.Second, wantStdout: `{"args":["users"],"call":1}`},
+		{name: "when the arguments differ", args: []string{"-json", "-token", "a", "groups"}, wantStdout: `{"args":["groups"],"call":2}`},
+		{name: "when the sensitive flags differ", args: []string{"-json", "-token", "b", "users"}, wantStdout: `{"args":["users"],"call":3}`},
+		{name: "when the cache is bypassed", args: []string{"-json", "-no-cache", "-token", "a", "users"}, wantStdout: `{"args":["users"],"call":4}`},
+		{name: "when the bypass refreshed the results", args: []string{"-json", "-token", "a", "users"}, wantStdout: `{"args":["users"],"call":4}`},
+		{name: "when the results expired", args: []string{"-json", "-token", "a", "users"}, advance: time.Minute, wantStdout: `{"args":["users"],"call":5}`},
+		{name: "when the execution fails", args: []string{"-json", "-token", "a", "teams"}, status: subcommands.ExitFailure, wantStdout: `{"args":["teams"],"call":6}`},
+		{name: "when the failure was not cached", args: []string{"-json", "-token", "a", "teams"}, wantStdout: `{"args":["teams"],"call":7}`},
+		{name: "when JSON output is not requested", args: []string{"-token", "a", "users"}, wantStdout: ``},
+	}
+	for _, step := range steps {
+		clk.Advance(step.advance)
+		status = step.status
+
+		var stdout testcmd.Buffer
+		ctx := subcommandsutil.WithOutput(subcommandsutil.WithClock(context.Background(), clk), &stdout, &testcmd.Buffer{})
+		got, _, _ := testcmd.Run(ctx, cmd, step.args...)
+		testcmd.AssertStatus(t, got, step.status)
+		if got := strings.TrimSpace(stdout.String()); got != step.wantStdout {
+			t.Fatalf("%s: wanted the stdout %q but got %q", step.name, step.wantStdout, got)
+		}
+	}
+	if calls != 8 {
+		t.Fatalf("wanted 8 executions but got %d", calls)
+	}
+}
+
+// cachedUser is the result of the command cached by TestCachedResult.
+type cachedUser struct {
+	Name string `json:"name"`
+	Call int    `json:"call"`
+}
+
+func TestCachedResult(t *testing.T) {
+	tests := map[string]struct {
+		args      []string
+		opts      []subcommandsutil.CachedOption
+		wantCalls int
+	}{
+		"when the results are encoded as csv": {
+			args:      []string{"-output", "csv"},
+			opts:      []subcommandsutil.CachedOption{subcommandsutil.WithCachedResult[cachedUser]()},
+			wantCalls: 1,
+		},
+		"when the results are encoded by -format": {
+			args:      []string{"-format", "{{.Name}} {{.Call}}"},
+			opts:      []subcommandsutil.CachedOption{subcommandsutil.WithCachedResult[cachedUser]()},
+			wantCalls: 1,
+		},
+		"when the results are encoded as csv without WithCachedResult": {
+			args:      []string{"-output", "csv"},
+			wantCalls: 2,
+		},
+		"when the results are encoded as json without WithCachedResult": {
+			args:      []string{"-output", "json"},
+			wantCalls: 1,
+		},
+	}
+	for name, tt := range tests {
+		tt := tt
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			calls := 0
+			sub := testcmd.NewRecording("query", testcmd.WithExecute(func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+				calls++
+				subcommandsutil.EmitResult(ctx, cachedUser{Name: "gopher", Call: calls})
+				return subcommands.ExitSuccess
+			}))
+			cmd := subcommandsutil.ResultCommand(subcommandsutil.Cached(sub, subcommandsutil.NewMemoryCacheStore(), time.Minute, tt.opts...))
+
+			var stdouts []string
+			for i := 0; i < 2; i++ {
+				var stdout testcmd.Buffer
+				ctx := subcommandsutil.WithOutput(context.Background(), &stdout, &testcmd.Buffer{})
+				status, _, _ := testcmd.Run(ctx, cmd, tt.args...)
+				testcmd.AssertStatus(t, status, subcommands.ExitSuccess)
+				stdouts = append(stdouts, stdout.String())
+			}
+			if calls != tt.wantCalls {
+				t.Fatalf("wanted %d executions but got %d", tt.wantCalls, calls)
+			}
+			if tt.wantCalls == 1 && stdouts[1] != stdouts[0] {
+				t.Fatalf("wanted the cached stdout %q but got %q", stdouts[0], stdouts[1])
+			}
+			if !strings.Contains(stdouts[1], "gopher") {
+				t.Fatalf("wanted the result in the stdout but got %q", stdouts[1])
+			}
+		})
+	}
+}
+
+func TestCachedExecuteForResult(t *testing.T) {
+	tests := map[string]struct {
+		opts      []subcommandsutil.CachedOption
+		wantCalls int
+	}{
+		"when the results are decoded": {
+			opts:      []subcommandsutil.CachedOption{subcommandsutil.WithCachedResult[cachedUser]()},
+			wantCalls: 1,
+		},
+		"when the results are not decoded": {
+			wantCalls: 2,
+		},
+	}
+	for name, tt := range tests {
+		tt := tt
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			calls := 0
+			sub := testcmd.NewRecording("query", testcmd.WithExecute(func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+				calls++
+				subcommandsutil.EmitResult(ctx, cachedUser{Name: "gopher", Call: 1})
+				return subcommands.ExitSuccess
+			}))
+			cmd := subcommandsutil.Cached(sub, subcommandsutil.NewMemoryCacheStore(), time.Minute, tt.opts...)
+
+			for i := 0; i < 2; i++ {
+				f := flag.NewFlagSet("query", flag.ContinueOnError)
+				cmd.SetFlags(f)
+				got, status, err := subcommandsutil.ExecuteForResult[cachedUser](context.Background(), cmd, f)
+				if err != nil {
+					t.Fatal(err)
+				}
+				testcmd.AssertStatus(t, status, subcommands.ExitSuccess)
+				if want := (cachedUser{Name: "gopher", Call: 1}); got != want {
+					t.Fatalf("wanted the result %+v but got %+v", want, got)
+				}
+			}
+			if calls != tt.wantCalls {
+				t.Fatalf("wanted %d executions but got %d", tt.wantCalls, calls)
+			}
+		})
+	}
+}
+
+func TestFileCacheStore(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_CACHE_HOME", dir)
+	t.Setenv("LOCALAPPDATA", dir)
+
+	store, err := subcommandsutil.NewFileCacheStore("tool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := store.Get("key"); ok {
+		t.Fatal("wanted no data before Put")
+	}
+	if err := store.Put("key", []byte("data")); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := subcommandsutil.NewFileCacheStore("tool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, ok := reopened.Get("key"); !ok || string(data) != "data" {
+		t.Fatalf("wanted the data %q but got %q (%t)", "data", data, ok)
+	}
+}
@@ -6,8 +6,10 @@ package subcommandsutil
 import (
 	"context"
 	"flag"
-	"log"
-	"runtime"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/google/subcommands"
 )
@@ -27,20 +29,69 @@ type CancelableCommand interface {
 //
 //   subcommands.Register(subcommandsutil.Cancelable(&OtherSubcommand{}))
 type cancelable struct {
-	sub CancelableCommand
+	sub    CancelableCommand
+	logger Logger
+
+	drainTimeout   time.Duration
+	onDrainTimeout func()
+	onHardCancel   func()
+
+	controlPath string
+
+	canceled  int32 // accessed atomically
+	executing executing
+
+	mu   sync.Mutex
+	stop context.CancelFunc // cancels the running execution
+}
+
+// make sure cancelable implements the CancelableWrapper interface.
+var _ CancelableWrapper = (*cancelable)(nil)
+
+// CancelableWrapper is the subcommands.Command returned by RegisterCancelable.
+type CancelableWrapper interface {
+	subcommands.Command
+
+	// Canceled reports whether the last execution was canceled before the underlying Command
+	// finished.
+	Canceled() bool
+
+	// Stop cancels the running execution as if its context was canceled. It does nothing if no
+	// execution is running.
+	Stop()
 }
 
-// make sure cancelable implements the subcommands.Command interface.
-var _ subcommands.Command = (*cancelable)(nil)
+// CancelableOption is an option of the Cancelable wrapper.
+type CancelableOption interface {
+	applyCancelable(*cancelable)
+}
+
+// applyCancelable implements CancelableOption.
+func (o LoggerOption) applyCancelable(c *cancelable) {
+	c.logger = o.logger
+}
 
 // Cancelable wraps a subcommands.Command so that it is canceled if its input execution
 // context emits a Done event before execution is finished.
 //
-// The wrapped sub will calling Dispose before the program exits.
-func Cancelable(sub CancelableCommand) subcommands.Command {
-	return &cancelable{
-		sub: sub,
+// The wrapped sub will calling Dispose before the program exits. The context's error is logged to
+// the standard logger unless WithLogger is given, or printed by PrintError in the json format of
+// ErrorFormat. A sub implementing Drainer, or wrapping one, is drained before its execution context
+// is canceled.
+//
+// An instance is executed once at a time: an execution overlapping a running one fails with
+// subcommands.ExitFailure, logging MessageAlreadyExecuting. Wrap it in Serialize to queue them.
+func Cancelable(sub CancelableCommand, opts ...CancelableOption) subcommands.Command {
+	c := &cancelable{
+		sub:          sub,
+		logger:       stdLogger{},
+		drainTimeout: 10 * time.Second,
+	}
+	for _, opt := range opts {
+		opt.applyCancelable(c)
 	}
+
+	return c
 }
 
 // Name forwards to the underlying c.sub Command.
@@ -58,6 +109,27 @@ func (c *cancelable) Synopsis() string {
 	return c.sub.Synopsis()
 }
 
+// Unwrap returns the underlying c.sub Command.
+func (c *cancelable) Unwrap() subcommands.Command {
+	return c.sub
+}
+
+// Canceled reports whether the last execution was canceled by its context before the underlying
+// c.sub Command finished.
+func (c *cancelable) Canceled() bool {
+	return atomic.LoadInt32(&c.canceled) == 1
+}
+
+// Stop implements CancelableWrapper.
+func (c *cancelable) Stop() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.stop != nil {
+		c.stop()
+	}
+}
+
 // SetFlags forwards to the underlying c.sub Command.
 func (c *cancelable) SetFlags(f *flag.FlagSet) {
 	c.sub.SetFlags(f)
@@ -65,22 +137,218 @@ func (c *cancelable) SetFlags(f *flag.FlagSet) {
 
 // Execute runs the underlying Command in a goroutine.
 //
-// If the input context is canceled before execution finishes, execution is canceled and the context's error is logged.
-func (c *cancelable) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
-	ch := make(chan subcommands.ExitStatus)
-	go func() {
-		defer runtime.Goexit()
-		ch <- c.sub.Execute(ctx, f, args...)
+// If the input context is canceled before execution finishes, execution is canceled, after draining a Drainer, and the context's error is logged.
+// A SetupTeardown is set up first, and torn down before it is disposed. The lifecycle Events are
+// published to the Events of ctx.
+func (c *cancelable) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) (status subcommands.ExitStatus) {
+	if !c.executing.enter(ctx, c.logger, c.sub.Name()) {
+		return subcommands.ExitFailure
+	}
+	defer c.executing.exit()
+	atomic.StoreInt32(&c.canceled, 0)
+
+	clk, name := ClockFromContext(ctx), c.sub.Name()
+	start := clk.Now()
+	publish(ctx, ExecutionStarted{Command: name, Time: start})
+	defer func() {
+		now := clk.Now()
+		publish(ctx, ExecutionFinished{Command: name, Time: now, Status: status, Duration: now.Sub(start), Canceled: c.Canceled()})
 	}()
 
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	c.mu.Lock()
+	c.stop = cancel
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		c.stop = nil
+		c.mu.Unlock()
+	}()
+
+	if c.controlPath != "" {
+		closeControl, err := c.serveControl(ctx)
+		if err != nil {
+			fmt.Fprintf(Stderr(ctx), "%s: %v\n", c.sub.Name(), err)
+			return subcommands.ExitFailure
+		}
+		defer closeControl()
+	}
+
+	// a Drainer runs on a context canceled only after the drain
+	drainer, drains := commandDrainer(c.sub)
+	execCtx, hardCancel := ctx, context.CancelFunc(func() {})
+	if drains {
+		execCtx, hardCancel = context.WithCancel(context.WithoutCancel(ctx))
+	}
+	defer hardCancel()
+
+	ectx := &execContext{Context: execCtx, f: f}
+	ectx.stopping, _ = ctx.Value(stoppingKey{}).(*stoppingHooks)
+	if ectx.stopping == nil {
+		ectx.stopping = &ectx.ownStopping
+	}
+	hooks := &ectx.hooks
+
+	teardown, err := c.setup(ectx, f)
+	if err != nil {
+		PrintError(ctx, c.sub.Name(), err)
+		return StatusFromError(err)
+	}
+
+	r := cancelRuns.Get().(*cancelRun)
+	r.c, r.ctx, r.execCtx, r.f, r.args, r.teardown = c, ctx, ectx, f, args, teardown
+	ch := r.ch
+	go r.run()
+
 	select {
 	case <-ctx.Done():
-		_ = c.sub.Dispose()    // TODO(zchee): hasdling error
-		log.Println(ctx.Err()) // TODO(zchee): use custom logger
-		return subcommands.ExitFailure
-
 	case s := <-ch:
-		close(ch)
+		r.release()
 		return s
 	}
+
+	ectx.stopping.stop()
+	if drains {
+		if s, finished := c.drain(ctx, drainer, ch); finished {
+			r.release()
+			return s
+		}
+		hardCancel()
+		if c.onHardCancel != nil {
+			c.onHardCancel()
+		}
+	}
+
+	atomic.StoreInt32(&c.canceled, 1)
+	publish(ctx, Canceled{Command: name, Time: clk.Now(), Err: ctx.Err()})
+	hooks.run()
+	if err := teardown(); err != nil {
+		contextLogger(ctx, c.logger).Printf("%s: teardown: %v", c.sub.Name(), err)
+	}
+	err = c.sub.Dispose() // TODO(zchee): hasdling error
+	publish(ctx, DisposeFinished{Command: name, Time: clk.Now(), Err: err})
+	if isJSONErrorFormat(ctx) {
+		printFailure(ctx, c.sub.Name(), ctx.Err(), subcommands.ExitFailure)
+	} else {
+		reportFailure(ctx, c.sub.Name(), ctx.Err(), subcommands.ExitFailure)
+		contextLogger(ctx, c.logger).Printf("%s", MessagesFromContext(ctx).Sprintf(MessageCanceled, c.sub.Name(), ctx.Err()))
+	}
+	return subcommands.ExitFailure
+}
+
+// execContext is the execution context of the underlying Command of a Cancelable, carrying its
+// cancelHooks, its stoppingHooks and FlagSet in a single allocation.
+type execContext struct {
+	context.Context
+
+	hooks       cancelHooks
+	stopping    *stoppingHooks // the ones of the context, or ownStopping
+	ownStopping stoppingHooks
+	f           *flag.FlagSet
+}
+
+// Value implements context.Context.
+func (c *execContext) Value(key interface{}) interface{} {
+	switch key.(type) {
+	case cancelHooksKey:
+		return &c.hooks
+	case stoppingKey:
+		return c.stopping
+	case flagSetKey:
+		return c.f
+	}
+
+	return c.Context.Value(key)
+}
+
+// cancelRun is the execution of the underlying Command of a Cancelable on its own goroutine. They
+// are pooled, along with their channel, and reused once the status was received.
+type cancelRun struct {
+	c        *cancelable
+	ctx      context.Context
+	execCtx  context.Context
+	f        *flag.FlagSet
+	args     []interface{}
+	teardown func() error
+
+	// buffered so that the goroutine exits even when nobody receives after cancellation
+	ch chan subcommands.ExitStatus
+}
+
+// cancelRuns is the pool of the cancelRuns.
+var cancelRuns = sync.Pool{
+	New: func() interface{} {
+		return &cancelRun{ch: make(chan subcommands.ExitStatus, 1)}
+	},
+}
+
+// run executes the underlying Command and sends its status to r.ch. r is not accessed once the
+// status is sent, as it may be reused.
+func (r *cancelRun) run() {
+	c, ctx, teardown := r.c, r.ctx, r.teardown
+	defer teardown() // when Execute panics
+
+	s := c.sub.Execute(r.execCtx, r.f, r.args...)
+	if err := teardown(); err != nil {
+		fmt.Fprintf(Stderr(ctx), "%s: teardown: %v\n", c.sub.Name(), err)
+		if s == subcommands.ExitSuccess {
+			s = StatusFromError(err)
+		}
+	}
+	r.ch <- s
+}
+
+// release returns r to the pool, once its status was received.
+func (r *cancelRun) release() {
+	ch := r.ch
+	*r = cancelRun{ch: ch}
+	cancelRuns.Put(r)
+}
+
+// cancelHooksKey is the context key of the cancelHooks of a Cancelable execution.
+type cancelHooksKey struct{}
+
+// cancelHooks holds the functions a Cancelable execution calls when its context is canceled,
+// before the underlying Command is disposed and the cancellation is logged.
+type cancelHooks struct {
+	mu  sync.Mutex
+	fns map[*func()]func()
+}
+
+// run calls the registered functions.
+func (h *cancelHooks) run() {
+	h.mu.Lock()
+	fns := make([]func(), 0, len(h.fns))
+	for _, fn := range h.fns {
+		fns = append(fns, fn)
+	}
+	h.mu.Unlock()
+
+	for _, fn := range fns {
+		fn()
+	}
+}
+
+// onCancel registers fn to be called when the Cancelable execution of ctx is canceled. The
+// returned function unregisters fn. It does nothing if ctx is not of a Cancelable execution.
+func onCancel(ctx context.Context, fn func()) (remove func()) {
+	h, ok := ctx.Value(cancelHooksKey{}).(*cancelHooks)
+	if !ok {
+		return func() {}
+	}
+
+	key := &fn
+	h.mu.Lock()
+	if h.fns == nil {
+		h.fns = make(map[*func()]func())
+	}
+	h.fns[key] = fn
+	h.mu.Unlock()
+
+	return func() {
+		h.mu.Lock()
+		delete(h.fns, key)
+		h.mu.Unlock()
+	}
 }
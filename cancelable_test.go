@@ -5,21 +5,25 @@ package subcommandsutil_test
 
 import (
 	"context"
+	"errors"
 	"flag"
-	"sync"
 	"testing"
 	"time"
 
 	"github.com/google/subcommands"
 
 	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
 )
 
 func TestCancelableExecute(t *testing.T) {
 	tests := map[string]struct {
-		// Whether to cancel the execution context early.
+		// Whether to cancel the execution context before the execution.
 		cancelContextEarly bool
 
+		// Whether to cancel the execution context while the underlying subcommand runs.
+		cancelContextWhileRunning bool
+
 		// Whether the underlying subcommand is expected to finish
 		expectToFinish bool
 	}{
@@ -27,24 +31,44 @@ func TestCancelableExecute(t *testing.T) {
 			cancelContextEarly: true,
 			expectToFinish:     false,
 		},
+		"when context is canceled while running": {
+			cancelContextWhileRunning: true,
+			expectToFinish:            false,
+		},
 		"when context is never canceled": {
-			cancelContextEarly: false,
-			expectToFinish:     true,
+			expectToFinish: true,
 		},
 	}
 	for name, tt := range tests {
 		t.Run(name, func(t *testing.T) {
-			tcmd := &testCommand{}
-			cmd := subcommandsutil.Cancelable(tcmd)
+			defer testcmd.VerifyNoLeaks(t)
+
+			tcmd := testcmd.NewBlocking("test")
+			defer tcmd.Release(subcommands.ExitSuccess)
+
+			var logs testcmd.LogRecorder
+			cmd := subcommandsutil.Cancelable(tcmd, subcommandsutil.WithLogger(&logs))
 			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
 
 			if tt.cancelContextEarly {
 				cancel()
-				cmd.Execute(ctx, flag.NewFlagSet("test", flag.ContinueOnError))
-			} else {
-				cmd.Execute(ctx, flag.NewFlagSet("test", flag.ContinueOnError))
+			}
+
+			done := make(chan subcommands.ExitStatus)
+			go func() {
+				done <- cmd.Execute(ctx, flag.NewFlagSet("test", flag.ContinueOnError))
+			}()
+
+			switch {
+			case tt.cancelContextWhileRunning:
+				<-tcmd.Started()
 				cancel()
+			case !tt.cancelContextEarly:
+				<-tcmd.Started()
+				tcmd.Release(subcommands.ExitSuccess)
 			}
+			status := <-done
 
 			switch {
 			case tcmd.DidFinish() && !tt.expectToFinish:
@@ -52,10 +76,49 @@ func TestCancelableExecute(t *testing.T) {
 			case !tcmd.DidFinish() && tt.expectToFinish:
 				t.Fatal("wanted command to finish but it exited early")
 			}
+
+			wantStatus, wantDispose := subcommands.ExitSuccess, 0
+			if !tt.expectToFinish {
+				wantStatus, wantDispose = subcommands.ExitFailure, 1
+			}
+			if status != wantStatus {
+				t.Fatalf("wanted %v but got %v", wantStatus, status)
+			}
+			if got := len(tcmd.DisposeCalls()); got != wantDispose {
+				t.Fatalf("wanted Dispose to be called %d times but got %d", wantDispose, got)
+			}
+			if got := logs.Contains("test: context canceled"); got == tt.expectToFinish {
+				t.Fatalf("wanted the cancellation to be logged only when canceled, got logs %q", logs.Lines())
+			}
 		})
 	}
 }
 
+// TestCancelableDispose verifies that a failing Dispose does not change the status of a canceled
+// execution.
+func TestCancelableDispose(t *testing.T) {
+	defer testcmd.VerifyNoLeaks(t)
+
+	now := time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)
+	tcmd := testcmd.NewBlocking("test")
+	defer tcmd.Release(subcommands.ExitSuccess)
+	tcmd.SetDisposeClock(testcmd.NewFakeClock(now))
+	tcmd.SetDisposeError(errors.New("dispose failed"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	status := subcommandsutil.Cancelable(tcmd, subcommandsutil.WithLogger(&testcmd.LogRecorder{})).Execute(ctx, flag.NewFlagSet("test", flag.ContinueOnError))
+	testcmd.AssertStatus(t, status, subcommands.ExitFailure)
+
+	calls := tcmd.DisposeCalls()
+	if len(calls) != 1 {
+		t.Fatalf("wanted Dispose to be called once but got %d", len(calls))
+	}
+	if !calls[0].Time.Equal(now) || calls[0].Reason != nil {
+		t.Fatalf("wanted a plain Dispose call at %v but got %+v", now, calls[0])
+	}
+}
+
 // TestCancelableDelegation verifies that Cancelable() returns a subcommand.Command that
 // delegates to the input subcommand.Command.
 func TestCancelableDelegation(t *testing.T) {
@@ -65,43 +128,92 @@ func TestCancelableDelegation(t *testing.T) {
 		}
 	}
 
-	cmd := subcommandsutil.Cancelable(&testCommand{
-		name:     "test_name",
-		usage:    "test_usage",
-		synopsis: "test_synopsis",
-	})
+	cmd := subcommandsutil.Cancelable(testcmd.NewRecording("test_name",
+		testcmd.WithUsage("test_usage"),
+		testcmd.WithSynopsis("test_synopsis"),
+	))
 	expectEq(t, "Name", "test_name", cmd.Name())
 	expectEq(t, "Usage", "test_usage", cmd.Usage())
 	expectEq(t, "Synopsis", "test_synopsis", cmd.Synopsis())
 }
 
-type testCommand struct {
-	name        string
-	usage       string
-	synopsis    string
-	didFinish   bool
-	didFinishMu sync.RWMutex
+// noopCommand is a CancelableCommand doing nothing, to measure the cost of the wrappers.
+type noopCommand struct{}
+
+func (noopCommand) Name() string           { return "noop" }
+func (noopCommand) Synopsis() string       { return "" }
+func (noopCommand) Usage() string          { return "" }
+func (noopCommand) SetFlags(*flag.FlagSet) {}
+func (noopCommand) Dispose() error         { return nil }
+func (noopCommand) Execute(context.Context, *flag.FlagSet, ...interface{}) subcommands.ExitStatus {
+	return subcommands.ExitSuccess
 }
 
-func (tcmd *testCommand) Name() string             { return tcmd.name }
-func (tcmd *testCommand) Usage() string            { return tcmd.usage }
-func (tcmd *testCommand) Synopsis() string         { return tcmd.synopsis }
-func (tcmd *testCommand) SetFlags(f *flag.FlagSet) {}
-func (tcmd *testCommand) Dispose() error           { return nil }
+func TestCancelableExecuteAllocs(t *testing.T) {
+	if subcommandsutil.RaceEnabled {
+		t.Skip("the race detector allocates")
+	}
 
-func (tcmd *testCommand) DidFinish() bool {
-	tcmd.didFinishMu.RLock()
-	defer tcmd.didFinishMu.RUnlock()
+	tests := map[string]struct {
+		cmd  subcommands.Command
+		want float64
+	}{
+		"when executing a Cancelable": {
+			// the context and its cancel function, its Done channel, the execution context and the
+			// goroutine
+			cmd:  subcommandsutil.Cancelable(noopCommand{}),
+			want: 5,
+		},
+		"when executing a chain of wrappers": {
+			cmd:  newChain(),
+			want: 14,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			f := flag.NewFlagSet("noop", flag.ContinueOnError)
+			tt.cmd.SetFlags(f)
+			ctx := context.Background()
+
+			got := testing.AllocsPerRun(100, func() {
+				tt.cmd.Execute(ctx, f)
+			})
+			if got > tt.want {
+				t.Fatalf("wanted at most %v allocations per execution but got %v", tt.want, got)
+			}
+		})
+	}
+}
 
-	return tcmd.didFinish
+// newChain returns a noopCommand wrapped in the wrappers commonly chained.
+func newChain() subcommands.Command {
+	return subcommandsutil.Chain(subcommandsutil.Cancelable(noopCommand{}),
+		subcommandsutil.WithGlobalFlags,
+		func(sub subcommands.Command) subcommands.Command { return subcommandsutil.Timeout(sub, time.Minute) },
+		func(sub subcommands.Command) subcommands.Command { return subcommandsutil.Retry(sub) },
+	)
 }
 
-func (tcmd *testCommand) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
-	time.Sleep(time.Millisecond)
+func BenchmarkCancelableExecute(b *testing.B) {
+	cmd := subcommandsutil.Cancelable(noopCommand{})
+	f := flag.NewFlagSet("noop", flag.ContinueOnError)
+	cmd.SetFlags(f)
+	ctx := context.Background()
 
-	tcmd.didFinishMu.Lock()
-	tcmd.didFinish = true
-	tcmd.didFinishMu.Unlock()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		cmd.Execute(ctx, f)
+	}
+}
 
-	return subcommands.ExitSuccess
+func BenchmarkChainExecute(b *testing.B) {
+	cmd := newChain()
+	f := flag.NewFlagSet("noop", flag.ContinueOnError)
+	cmd.SetFlags(f)
+	ctx := context.Background()
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		cmd.Execute(ctx, f)
+	}
 }
@@ -0,0 +1,193 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"sync"
+
+	"github.com/google/subcommands"
+)
+
+// Checkpointer is implemented by the long-running commands which can save their progress, to
+// resume from it after they are canceled.
+type Checkpointer interface {
+	// SaveCheckpoint returns the progress of the canceled execution.
+	SaveCheckpoint(ctx context.Context) ([]byte, error)
+
+	// RestoreCheckpoint restores the progress saved by SaveCheckpoint before the execution.
+	RestoreCheckpoint(ctx context.Context, data []byte) error
+}
+
+// commandCheckpointer returns the Checkpointer that cmd is or wraps.
+func commandCheckpointer(cmd subcommands.Command) (cp Checkpointer, ok bool) {
+	walkCommand(cmd, func(cmd subcommands.Command) bool {
+		cp, ok = cmd.(Checkpointer)
+		return ok
+	})
+
+	return cp, ok
+}
+
+// checkpointHeader starts the checkpoint files, followed by the checksum of the checkpoint.
+const checkpointHeader = "subcommandsutil checkpoint v1\n"
+
+// checkpoint wraps a CancelableCommand so that its progress is saved when it is canceled.
+type checkpoint struct {
+	sub  CancelableCommand
+	path string
+
+	file   string
+	resume bool
+
+	mu  sync.Mutex
+	ctx context.Context // the context of the running execution
+}
+
+// make sure checkpoint implements the CancelableCommand interface.
+var _ CancelableCommand = (*checkpoint)(nil)
+
+// Checkpoint wraps sub, a Checkpointer or wrapping one, with the -checkpoint flag, the path of its
+// checkpoint file defaulting to path, and the -resume flag.
+//
+// When a Cancelable wrapper disposes of sub, its progress is saved by SaveCheckpoint, and written
+// atomically to the checkpoint file, along with its checksum. With -resume, the checkpoint is read
+// and restored by RestoreCheckpoint before sub executes; a checkpoint file which is missing or
+// corrupt is a usage error. The checkpoint file is removed once an execution succeeds without being
+// canceled.
+func Checkpoint(sub CancelableCommand, path string) CancelableCommand {
+	return &checkpoint{
+		sub:  sub,
+		path: path,
+	}
+}
+
+// Name forwards to the underlying c.sub Command.
+func (c *checkpoint) Name() string {
+	return c.sub.Name()
+}
+
+// Usage forwards to the underlying c.sub Command.
+func (c *checkpoint) Usage() string {
+	return c.sub.Usage()
+}
+
+// Synopsis forwards to the underlying c.sub Command.
+func (c *checkpoint) Synopsis() string {
+	return c.sub.Synopsis()
+}
+
+// Unwrap returns the underlying c.sub Command.
+func (c *checkpoint) Unwrap() subcommands.Command {
+	return c.sub
+}
+
+// SetFlags forwards to the underlying c.sub Command and registers the -checkpoint and -resume
+// flags.
+func (c *checkpoint) SetFlags(f *flag.FlagSet) {
+	c.sub.SetFlags(f)
+	f.StringVar(&c.file, "checkpoint", c.path, "save the progress to `file` when canceled")
+	f.BoolVar(&c.resume, "resume", false, "resume from the progress saved to the -checkpoint file")
+}
+
+// Dispose saves the checkpoint of the running execution, and forwards to the underlying c.sub
+// Command.
+func (c *checkpoint) Dispose() error {
+	c.mu.Lock()
+	ctx := c.ctx
+	c.mu.Unlock()
+
+	var err error
+	if ctx != nil {
+		if err = c.save(ctx); err != nil {
+			fmt.Fprintf(Stderr(ctx), "%s: saving checkpoint: %v\n", c.sub.Name(), err)
+		}
+	}
+
+	return errors.Join(err, c.sub.Dispose())
+}
+
+// Execute restores the checkpoint with the -resume flag, and forwards to the underlying c.sub
+// Command.
+func (c *checkpoint) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	cp, ok := commandCheckpointer(c.sub)
+	if !ok {
+		return c.sub.Execute(ctx, f, args...)
+	}
+
+	if c.resume {
+		data, err := readCheckpoint(c.file)
+		if err != nil {
+			fmt.Fprintf(Stderr(ctx), "%s: -resume: %v\n", c.sub.Name(), err)
+			return subcommands.ExitUsageError
+		}
+		if err := cp.RestoreCheckpoint(ctx, data); err != nil {
+			fmt.Fprintf(Stderr(ctx), "%s: restoring checkpoint: %v\n", c.sub.Name(), err)
+			return StatusFromError(err)
+		}
+	}
+
+	c.mu.Lock()
+	c.ctx = ctx
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		c.ctx = nil
+		c.mu.Unlock()
+	}()
+
+	status := c.sub.Execute(ctx, f, args...)
+	if status == subcommands.ExitSuccess && ctx.Err() == nil {
+		os.Remove(c.file)
+	}
+
+	return status
+}
+
+// save writes the checkpoint of the Checkpointer c.sub is or wraps to the checkpoint file.
+func (c *checkpoint) save(ctx context.Context) error {
+	cp, ok := commandCheckpointer(c.sub)
+	if !ok {
+		return nil
+	}
+	data, err := cp.SaveCheckpoint(context.WithoutCancel(ctx))
+	if err != nil {
+		return err
+	}
+
+	sum := sha256.Sum256(data)
+	content := append([]byte(checkpointHeader+hex.EncodeToString(sum[:])+"\n"), data...)
+
+	return writeFileAtomic(c.file, content, 0o600)
+}
+
+// readCheckpoint returns the checkpoint of the checkpoint file at path, verifying its checksum.
+func readCheckpoint(path string) ([]byte, error) {
+	content, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("no checkpoint at %s", path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rest, ok := bytes.CutPrefix(content, []byte(checkpointHeader))
+	if !ok {
+		return nil, fmt.Errorf("%s is not a checkpoint", path)
+	}
+	sum, data, ok := bytes.Cut(rest, []byte("\n"))
+	if want := sha256.Sum256(data); !ok || string(sum) != hex.EncodeToString(want[:]) {
+		return nil, fmt.Errorf("corrupt checkpoint at %s", path)
+	}
+
+	return data, nil
+}
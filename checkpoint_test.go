@@ -0,0 +1,124 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"context"
+	"flag"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+// migrating is a CancelableCommand implementing subcommandsutil.Checkpointer, whose progress is
+// the number of migrated rows.
+type migrating struct {
+	subcommandsutil.CancelableCommand
+	rows     int64 // accessed atomically
+	restored int64 // the rows restored, -1 if none; accessed atomically
+}
+
+// SaveCheckpoint implements subcommandsutil.Checkpointer.
+func (c *migrating) SaveCheckpoint(ctx context.Context) ([]byte, error) {
+	return []byte(strconv.FormatInt(atomic.LoadInt64(&c.rows), 10)), nil
+}
+
+// RestoreCheckpoint implements subcommandsutil.Checkpointer.
+func (c *migrating) RestoreCheckpoint(ctx context.Context, data []byte) error {
+	rows, err := strconv.ParseInt(string(data), 10, 64)
+	if err != nil {
+		return err
+	}
+	atomic.StoreInt64(&c.restored, rows)
+	atomic.StoreInt64(&c.rows, rows)
+	return nil
+}
+
+func TestCheckpoint(t *testing.T) {
+	defer testcmd.VerifyNoLeaks(t)
+
+	path := filepath.Join(t.TempDir(), "migrate.checkpoint")
+
+	// the first execution migrates 1500 rows before it is canceled
+	tcmd := testcmd.NewBlocking("migrate")
+	defer tcmd.Release(subcommands.ExitSuccess)
+	first := &migrating{CancelableCommand: tcmd, restored: -1}
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-tcmd.Started()
+		atomic.StoreInt64(&first.rows, 1500)
+		cancel()
+	}()
+	status, _, _ := testcmd.Run(ctx, subcommandsutil.Cancelable(subcommandsutil.Checkpoint(first, path), subcommandsutil.WithLogger(&testcmd.LogRecorder{})))
+	testcmd.AssertStatus(t, status, subcommands.ExitFailure)
+
+	// the second execution resumes from them
+	var resumedFrom int64 = -1
+	second := &migrating{restored: -1}
+	second.CancelableCommand = testcmd.NewRecording("migrate", testcmd.WithExecute(func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+		resumedFrom = atomic.LoadInt64(&second.restored)
+		return subcommands.ExitSuccess
+	}))
+	status, _, _ = testcmd.Run(context.Background(), subcommandsutil.Cancelable(subcommandsutil.Checkpoint(second, path)), "-resume")
+	testcmd.RequireSuccess(t, status)
+	if resumedFrom != 1500 {
+		t.Fatalf("wanted the execution resumed from 1500 rows but got %d", resumedFrom)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("wanted the checkpoint removed after the success but got %v", err)
+	}
+}
+
+func TestCheckpointResumeError(t *testing.T) {
+	dir := t.TempDir()
+	corrupt := filepath.Join(dir, "corrupt.checkpoint")
+	if err := os.WriteFile(corrupt, []byte("subcommandsutil checkpoint v1\n0000\n1500"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	garbage := filepath.Join(dir, "garbage.checkpoint")
+	if err := os.WriteFile(garbage, []byte("1500"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]struct {
+		path       string
+		wantStderr string
+	}{
+		"when the checkpoint file is missing": {
+			path:       filepath.Join(dir, "missing.checkpoint"),
+			wantStderr: "migrate: -resume: no checkpoint at " + filepath.Join(dir, "missing.checkpoint") + "\n",
+		},
+		"when the checksum of the checkpoint does not match": {
+			path:       corrupt,
+			wantStderr: "migrate: -resume: corrupt checkpoint at " + corrupt + "\n",
+		},
+		"when the file is not a checkpoint": {
+			path:       garbage,
+			wantStderr: "migrate: -resume: " + garbage + " is not a checkpoint\n",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			sub := &migrating{CancelableCommand: testcmd.NewRecording("migrate"), restored: -1}
+			var stderr testcmd.Buffer
+			ctx := subcommandsutil.WithOutput(context.Background(), &testcmd.Buffer{}, &stderr)
+
+			status, _, _ := testcmd.Run(ctx, subcommandsutil.Checkpoint(sub, tt.path), "-resume")
+			testcmd.AssertStatus(t, status, subcommands.ExitUsageError)
+			if got := stderr.String(); got != tt.wantStderr {
+				t.Fatalf("wanted the stderr %q but got %q", tt.wantStderr, got)
+			}
+			if n := sub.CancelableCommand.(*testcmd.Recording).CallCount(); n != 0 {
+				t.Fatalf("wanted the command not executed but got %d calls", n)
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"context"
+	"os"
+	"strings"
+)
+
+// environKey is the context key of the environment inherited by ChildEnv.
+type environKey struct{}
+
+// WithEnviron returns a copy of ctx whose ChildEnv inherits from environ, in the "key=value" form of
+// os.Environ, instead of the environment of the process.
+func WithEnviron(ctx context.Context, environ []string) context.Context {
+	return context.WithValue(ctx, environKey{}, environ)
+}
+
+// Env is the environment of a child process, built by ChildEnv. Only the variables inherited or set
+// are in it. The values of the variables marked secret are Redacted when it is rendered for logging,
+// by String and Redacted.
+type Env struct {
+	environ []string
+
+	keys   []string // in the order they were first added
+	values map[string]string
+	secret map[string]bool
+}
+
+// ChildEnv returns an empty Env inheriting from the environment of the process, or from the one set
+// by WithEnviron in ctx:
+//
+//	cmd.Env = subcommandsutil.ChildEnv(ctx).
+//		Inherit("PATH", "HOME").
+//		InheritPrefix("LC_").
+//		SetSecret("TOOL_TOKEN", token).
+//		Strings()
+func ChildEnv(ctx context.Context) *Env {
+	environ, ok := ctx.Value(environKey{}).([]string)
+	if !ok {
+		environ = os.Environ()
+	}
+
+	return &Env{
+		environ: environ,
+		values:  make(map[string]string),
+		secret:  make(map[string]bool),
+	}
+}
+
+// lookup returns the value of the inherited variable name. The last one wins if it is duplicated.
+func (e *Env) lookup(name string) (value string, ok bool) {
+	for _, kv := range e.environ {
+		if k, v, found := strings.Cut(kv, "="); found && k == name {
+			value, ok = v, true
+		}
+	}
+
+	return value, ok
+}
+
+// Inherit adds the inherited variables named names which are defined.
+func (e *Env) Inherit(names ...string) *Env {
+	for _, name := range names {
+		if v, ok := e.lookup(name); ok {
+			e.Set(name, v)
+		}
+	}
+
+	return e
+}
+
+// InheritPrefix adds the inherited variables whose names start with prefix.
+func (e *Env) InheritPrefix(prefix string) *Env {
+	for _, kv := range e.environ {
+		if k, v, found := strings.Cut(kv, "="); found && k != "" && strings.HasPrefix(k, prefix) {
+			e.Set(k, v)
+		}
+	}
+
+	return e
+}
+
+// Set adds the variable k with the value v, replacing its previous value.
+func (e *Env) Set(k, v string) *Env {
+	if _, ok := e.values[k]; !ok {
+		e.keys = append(e.keys, k)
+	}
+	e.values[k] = v
+
+	return e
+}
+
+// SetSecret is like Set, and marks k secret.
+func (e *Env) SetSecret(k, v string) *Env {
+	return e.Set(k, v).MarkSecret(k)
+}
+
+// MarkSecret marks the variables named names secret, whether they are already added or not.
+func (e *Env) MarkSecret(names ...string) *Env {
+	for _, name := range names {
+		e.secret[name] = true
+	}
+
+	return e
+}
+
+// IsSecret reports whether the variable named name was marked secret.
+func (e *Env) IsSecret(name string) bool {
+	return e.secret[name]
+}
+
+// Strings returns the variables in the "key=value" form of exec.Cmd.Env, in the order they were
+// first added.
+func (e *Env) Strings() []string {
+	env := make([]string, 0, len(e.keys))
+	for _, k := range e.keys {
+		env = append(env, k+"="+e.values[k])
+	}
+
+	return env
+}
+
+// Redacted is like Strings, with the values of the secret variables replaced by Redacted. It is the
+// form to record the environment in.
+func (e *Env) Redacted() []string {
+	env := make([]string, 0, len(e.keys))
+	for _, k := range e.keys {
+		v := e.values[k]
+		if e.secret[k] {
+			v = Redacted
+		}
+		env = append(env, k+"="+v)
+	}
+
+	return env
+}
+
+// String implements fmt.Stringer, rendering the Redacted variables separated by spaces, so that
+// logging an Env never records a secret.
+func (e *Env) String() string {
+	return strings.Join(e.Redacted(), " ")
+}
@@ -0,0 +1,91 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/zchee/subcommandsutil"
+)
+
+func TestChildEnv(t *testing.T) {
+	environ := []string{
+		"PATH=/usr/bin:/bin",
+		"HOME=/home/gopher",
+		"AWS_SECRET_ACCESS_KEY=hunter2",
+		"LC_ALL=C",
+		"LC_TIME=en_GB.UTF-8",
+		"LANG=C.UTF-8",
+		"EMPTY=",
+		"HOME=/home/other",
+		"=C:=C:\\",
+	}
+	tests := map[string]struct {
+		build        func(e *subcommandsutil.Env)
+		want         []string
+		wantRedacted []string
+	}{
+		"when nothing is added": {
+			build: func(e *subcommandsutil.Env) {},
+			want:  []string{},
+		},
+		"when the allowlisted variables are inherited": {
+			build: func(e *subcommandsutil.Env) {
+				e.Inherit("PATH", "EMPTY", "MISSING")
+			},
+			want: []string{"PATH=/usr/bin:/bin", "EMPTY="},
+		},
+		"when a duplicated variable is inherited": {
+			build: func(e *subcommandsutil.Env) {
+				e.Inherit("HOME")
+			},
+			want: []string{"HOME=/home/other"},
+		},
+		"when the variables with a prefix are inherited": {
+			build: func(e *subcommandsutil.Env) {
+				e.InheritPrefix("LC_")
+			},
+			want: []string{"LC_ALL=C", "LC_TIME=en_GB.UTF-8"},
+		},
+		"when a set variable overrides an inherited one": {
+			build: func(e *subcommandsutil.Env) {
+				e.InheritPrefix("LC_").Set("TOOL_MODE", "ci").Set("LC_ALL", "en_US.UTF-8")
+			},
+			want: []string{"LC_ALL=en_US.UTF-8", "LC_TIME=en_GB.UTF-8", "TOOL_MODE=ci"},
+		},
+		"when secret variables are added": {
+			build: func(e *subcommandsutil.Env) {
+				e.MarkSecret("AWS_SECRET_ACCESS_KEY").
+					Inherit("PATH", "AWS_SECRET_ACCESS_KEY").
+					SetSecret("TOOL_TOKEN", "s3cr3t")
+			},
+			want:         []string{"PATH=/usr/bin:/bin", "AWS_SECRET_ACCESS_KEY=hunter2", "TOOL_TOKEN=s3cr3t"},
+			wantRedacted: []string{"PATH=/usr/bin:/bin", "AWS_SECRET_ACCESS_KEY=" + subcommandsutil.Redacted, "TOOL_TOKEN=" + subcommandsutil.Redacted},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			e := subcommandsutil.ChildEnv(subcommandsutil.WithEnviron(context.Background(), environ))
+			tt.build(e)
+
+			if got := e.Strings(); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("wanted %q but got %q", tt.want, got)
+			}
+			wantRedacted := tt.wantRedacted
+			if wantRedacted == nil {
+				wantRedacted = tt.want
+			}
+			if got := e.Redacted(); !reflect.DeepEqual(got, wantRedacted) {
+				t.Fatalf("wanted the redacted %q but got %q", wantRedacted, got)
+			}
+			if got := fmt.Sprint(e); strings.Contains(got, "hunter2") || strings.Contains(got, "s3cr3t") {
+				t.Fatalf("wanted the rendered environment to redact the secrets but got %q", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,166 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Clock is the source of time of the time-based wrappers of this package. The default Clock uses
+// the time package; tests inject a fake one with WithClock.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+
+	// NewTimer creates a Timer firing once after d.
+	NewTimer(d time.Duration) Timer
+
+	// NewTicker creates a Ticker firing every d.
+	NewTicker(d time.Duration) Ticker
+}
+
+// Timer is a timer created by a Clock, like time.Timer.
+type Timer interface {
+	// C returns the channel the time is sent on when the timer fires.
+	C() <-chan time.Time
+
+	// Stop prevents the timer from firing. It reports whether the timer was active.
+	Stop() bool
+
+	// Reset changes the timer to fire after d. It reports whether the timer was active.
+	Reset(d time.Duration) bool
+}
+
+// Ticker is a ticker created by a Clock, like time.Ticker.
+type Ticker interface {
+	// C returns the channel the ticks are sent on.
+	C() <-chan time.Time
+
+	// Stop turns off the ticker.
+	Stop()
+}
+
+// clockKey is the context key of the Clock.
+type clockKey struct{}
+
+// WithClock returns a copy of ctx carrying clk, which is used by the wrappers of this package
+// executing with the returned context.
+func WithClock(ctx context.Context, clk Clock) context.Context {
+	return context.WithValue(ctx, clockKey{}, clk)
+}
+
+// ClockFromContext returns the Clock carried by ctx, or the Clock of the time package.
+func ClockFromContext(ctx context.Context) Clock {
+	if clk, ok := ctx.Value(clockKey{}).(Clock); ok {
+		return clk
+	}
+
+	return realClock{}
+}
+
+// realClock is the Clock of the time package.
+type realClock struct{}
+
+// Now implements Clock.
+func (realClock) Now() time.Time { return time.Now() }
+
+// NewTimer implements Clock.
+func (realClock) NewTimer(d time.Duration) Timer { return realTimer{time.NewTimer(d)} }
+
+// NewTicker implements Clock.
+func (realClock) NewTicker(d time.Duration) Ticker { return realTicker{time.NewTicker(d)} }
+
+// realTimer is a Timer backed by a time.Timer.
+type realTimer struct {
+	*time.Timer
+}
+
+// C implements Timer.
+func (t realTimer) C() <-chan time.Time { return t.Timer.C }
+
+// realTicker is a Ticker backed by a time.Ticker.
+type realTicker struct {
+	*time.Ticker
+}
+
+// C implements Ticker.
+func (t realTicker) C() <-chan time.Time { return t.Ticker.C }
+
+// sleep waits for d on clk, or until ctx is done. It returns the error of ctx if ctx is done first.
+func sleep(ctx context.Context, clk Clock, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	t := clk.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C():
+		return nil
+	}
+}
+
+// withTimeout is like context.WithTimeout, but measures d on the Clock carried by ctx.
+func withTimeout(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	clk := ClockFromContext(ctx)
+	if _, ok := clk.(realClock); ok {
+		return context.WithTimeout(ctx, d)
+	}
+
+	cctx, cancel := context.WithCancel(ctx)
+	tctx := &clockDeadlineCtx{
+		Context:  cctx,
+		deadline: clk.Now().Add(d),
+	}
+
+	t := clk.NewTimer(d)
+	go func() {
+		select {
+		case <-t.C():
+			tctx.mu.Lock()
+			tctx.err = context.DeadlineExceeded
+			tctx.mu.Unlock()
+			cancel()
+		case <-cctx.Done():
+			t.Stop()
+		}
+	}()
+
+	return tctx, cancel
+}
+
+// clockDeadlineCtx is a context.Context whose deadline is measured on a Clock.
+type clockDeadlineCtx struct {
+	context.Context
+	deadline time.Time
+
+	mu  sync.Mutex
+	err error
+}
+
+// Deadline implements context.Context.
+func (c *clockDeadlineCtx) Deadline() (time.Time, bool) {
+	if d, ok := c.Context.Deadline(); ok && d.Before(c.deadline) {
+		return d, true
+	}
+
+	return c.deadline, true
+}
+
+// Err implements context.Context.
+func (c *clockDeadlineCtx) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.err != nil {
+		return c.err
+	}
+
+	return c.Context.Err()
+}
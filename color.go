@@ -0,0 +1,180 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/google/subcommands"
+)
+
+// NoColorEnv is the environment variable disabling the colors of the auto color mode when set to a
+// non-empty value. See https://no-color.org.
+const NoColorEnv = "NO_COLOR"
+
+// ColorMode is the mode of the -color flag registered by Colored.
+type ColorMode string
+
+const (
+	// ColorAuto enables colors if Stdout is a terminal and NO_COLOR is not set.
+	ColorAuto ColorMode = "auto"
+	// ColorAlways always enables colors.
+	ColorAlways ColorMode = "always"
+	// ColorNever always disables colors.
+	ColorNever ColorMode = "never"
+)
+
+// String implements flag.Value.
+func (m *ColorMode) String() string {
+	return string(*m)
+}
+
+// Set implements flag.Value.
+func (m *ColorMode) Set(s string) error {
+	switch mode := ColorMode(s); mode {
+	case ColorAuto, ColorAlways, ColorNever:
+		*m = mode
+		return nil
+	default:
+		return fmt.Errorf("invalid color mode %q: must be %s, %s or %s", s, ColorAuto, ColorAlways, ColorNever)
+	}
+}
+
+// colorsKey is the context key of the Palette.
+type colorsKey struct{}
+
+// WithColors returns a copy of ctx carrying the Palette decided by mode for the Stdout of ctx.
+func WithColors(ctx context.Context, mode ColorMode) context.Context {
+	return context.WithValue(ctx, colorsKey{}, Palette{enabled: colorsEnabled(mode, Stdout(ctx))})
+}
+
+// Colors returns the Palette of the execution of ctx. Without a Palette carried by ctx, it is
+// decided by the ColorAuto mode.
+func Colors(ctx context.Context) Palette {
+	if p, ok := ctx.Value(colorsKey{}).(Palette); ok {
+		return p
+	}
+
+	return Palette{enabled: colorsEnabled(ColorAuto, Stdout(ctx))}
+}
+
+// colorsEnabled reports whether mode enables the colors of the output to w.
+func colorsEnabled(mode ColorMode, w io.Writer) bool {
+	switch mode {
+	case ColorAlways:
+		return true
+	case ColorNever:
+		return false
+	default:
+		return os.Getenv(NoColorEnv) == "" && isTerminal(w)
+	}
+}
+
+// isTerminal reports whether v, a reader or a writer, is a terminal.
+func isTerminal(v interface{}) bool {
+	f, ok := v.(*os.File)
+
+	return ok && isTerminalFile(f)
+}
+
+// Palette renders strings with ANSI colors and styles, or as is when colors are disabled.
+type Palette struct {
+	enabled bool
+}
+
+// Enabled reports whether p renders colors.
+func (p Palette) Enabled() bool {
+	return p.enabled
+}
+
+// wrap wraps s in the SGR escape code.
+func (p Palette) wrap(code, s string) string {
+	if !p.enabled {
+		return s
+	}
+
+	return "\x1b[" + code + "m" + s + "\x1b[0m"
+}
+
+// Bold renders s in bold.
+func (p Palette) Bold(s string) string { return p.wrap("1", s) }
+
+// Faint renders s faint.
+func (p Palette) Faint(s string) string { return p.wrap("2", s) }
+
+// Underline renders s underlined.
+func (p Palette) Underline(s string) string { return p.wrap("4", s) }
+
+// Red renders s in red.
+func (p Palette) Red(s string) string { return p.wrap("31", s) }
+
+// Green renders s in green.
+func (p Palette) Green(s string) string { return p.wrap("32", s) }
+
+// Yellow renders s in yellow.
+func (p Palette) Yellow(s string) string { return p.wrap("33", s) }
+
+// Blue renders s in blue.
+func (p Palette) Blue(s string) string { return p.wrap("34", s) }
+
+// Magenta renders s in magenta.
+func (p Palette) Magenta(s string) string { return p.wrap("35", s) }
+
+// Cyan renders s in cyan.
+func (p Palette) Cyan(s string) string { return p.wrap("36", s) }
+
+// colored wraps a subcommands.Command so that its colors can be configured.
+type colored struct {
+	sub subcommands.Command
+
+	mode ColorMode
+}
+
+// make sure colored implements the subcommands.Command interface.
+var _ subcommands.Command = (*colored)(nil)
+
+// Colored wraps sub with the -color flag, whose value is a ColorMode defaulting to ColorAuto. The
+// Palette returned by Colors is decided once per execution.
+func Colored(sub subcommands.Command) subcommands.Command {
+	return &colored{
+		sub:  sub,
+		mode: ColorAuto,
+	}
+}
+
+// Name forwards to the underlying c.sub Command.
+func (c *colored) Name() string {
+	return c.sub.Name()
+}
+
+// Usage forwards to the underlying c.sub Command.
+func (c *colored) Usage() string {
+	return c.sub.Usage()
+}
+
+// Synopsis forwards to the underlying c.sub Command.
+func (c *colored) Synopsis() string {
+	return c.sub.Synopsis()
+}
+
+// Unwrap returns the underlying c.sub Command.
+func (c *colored) Unwrap() subcommands.Command {
+	return c.sub
+}
+
+// SetFlags forwards to the underlying c.sub Command and registers the -color flag.
+func (c *colored) SetFlags(f *flag.FlagSet) {
+	c.sub.SetFlags(f)
+	c.mode = ColorAuto
+	f.Var(&c.mode, "color", "colorize the output: `auto`, always or never")
+}
+
+// Execute forwards to the underlying c.sub Command with the Palette decided by the -color flag.
+func (c *colored) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	return c.sub.Execute(WithColors(ctx, c.mode), f, args...)
+}
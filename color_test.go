@@ -0,0 +1,121 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"testing"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+func TestColored(t *testing.T) {
+	tests := map[string]struct {
+		args    []string
+		noColor string
+		want    string
+	}{
+		"when the mode is auto and stdout is not a terminal": {
+			want: "error ok\n",
+		},
+		"when the mode is always": {
+			args: []string{"-color=always"},
+			want: "\x1b[31merror\x1b[0m \x1b[1m\x1b[32mok\x1b[0m\x1b[0m\n",
+		},
+		"when the mode is always and NO_COLOR is set": {
+			args:    []string{"-color=always"},
+			noColor: "1",
+			want:    "\x1b[31merror\x1b[0m \x1b[1m\x1b[32mok\x1b[0m\x1b[0m\n",
+		},
+		"when the mode is never": {
+			args: []string{"-color=never"},
+			want: "error ok\n",
+		},
+		"when the mode is auto and NO_COLOR is set": {
+			args:    []string{"-color=auto"},
+			noColor: "1",
+			want:    "error ok\n",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv(subcommandsutil.NoColorEnv, tt.noColor)
+
+			cmd := subcommandsutil.Colored(testcmd.NewRecording("status",
+				testcmd.WithExecute(func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+					p := subcommandsutil.Colors(ctx)
+					fmt.Fprintln(subcommandsutil.Stdout(ctx), p.Red("error"), p.Bold(p.Green("ok")))
+					return subcommands.ExitSuccess
+				}),
+			))
+
+			var stdout testcmd.Buffer
+			ctx := subcommandsutil.WithOutput(context.Background(), &stdout, nil)
+			status, _, err := testcmd.Run(ctx, cmd, tt.args...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			testcmd.RequireSuccess(t, status)
+			if got := stdout.String(); got != tt.want {
+				t.Fatalf("wanted %q but got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestColoredReused(t *testing.T) {
+	cmd := subcommandsutil.Colored(testcmd.NewRecording("status",
+		testcmd.WithExecute(func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+			fmt.Fprintln(subcommandsutil.Stdout(ctx), subcommandsutil.Colors(ctx).Red("error"))
+			return subcommands.ExitSuccess
+		}),
+	))
+	for _, tt := range []struct {
+		args []string
+		want string
+	}{
+		{args: []string{"-color=always"}, want: "\x1b[31merror\x1b[0m\n"},
+		{want: "error\n"},
+	} {
+		var stdout testcmd.Buffer
+		status, f, _ := testcmd.Run(subcommandsutil.WithOutput(context.Background(), &stdout, nil), cmd, tt.args...)
+		testcmd.RequireSuccess(t, status)
+		if got := stdout.String(); got != tt.want {
+			t.Fatalf("wanted %q with the arguments %q but got %q", tt.want, tt.args, got)
+		}
+		if def := f.Lookup("color").DefValue; def != "auto" {
+			t.Fatalf("wanted the default of -color auto but got %q", def)
+		}
+	}
+}
+
+func TestColorModeSet(t *testing.T) {
+	var mode subcommandsutil.ColorMode
+	if err := mode.Set("always"); err != nil {
+		t.Fatal(err)
+	}
+	if mode != subcommandsutil.ColorAlways {
+		t.Fatalf("wanted %q but got %q", subcommandsutil.ColorAlways, mode)
+	}
+	if err := mode.Set("sometimes"); err == nil {
+		t.Fatal("wanted an invalid color mode to be rejected but got none")
+	}
+}
+
+func TestColorsDefault(t *testing.T) {
+	t.Setenv(subcommandsutil.NoColorEnv, "")
+
+	ctx := subcommandsutil.WithOutput(context.Background(), &testcmd.Buffer{}, nil)
+	if subcommandsutil.Colors(ctx).Enabled() {
+		t.Fatal("wanted colors disabled without a terminal but they were enabled")
+	}
+	if !subcommandsutil.Colors(subcommandsutil.WithColors(ctx, subcommandsutil.ColorAlways)).Enabled() {
+		t.Fatal("wanted colors enabled by the always mode but they were disabled")
+	}
+}
@@ -0,0 +1,205 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"context"
+	"flag"
+	"io"
+
+	"github.com/google/subcommands"
+)
+
+// CancelableCommander is a subcommands.Commander whose Register wraps the commands with a
+// Dispose or Close method in Cancelable.
+type CancelableCommander struct {
+	*subcommands.Commander
+
+	topFlags  *flag.FlagSet
+	opts      []CancelableOption
+	injectors []Injector
+	hooks     []DispatchHooks
+	logger    Logger
+	policy    *HookErrorPolicy
+
+	stdout io.Writer
+	stderr io.Writer
+}
+
+// NewCancelableCommander returns a new CancelableCommander over the top-level flags f, wrapping the
+// registered commands with opts.
+func NewCancelableCommander(f *flag.FlagSet, name string, opts ...CancelableOption) *CancelableCommander {
+	cdr := &CancelableCommander{
+		Commander: subcommands.NewCommander(f, name),
+		topFlags:  f,
+		opts:      opts,
+		logger:    stdLogger{},
+	}
+	for _, opt := range opts {
+		if o, ok := opt.(LoggerOption); ok {
+			cdr.logger = o.logger
+		}
+	}
+
+	return cdr
+}
+
+// Register registers cmd in group. A cmd implementing CancelableCommand, or io.Closer whose Close is
+// then used as Dispose, is wrapped in Cancelable first; other commands are registered untouched.
+func (cdr *CancelableCommander) Register(cmd subcommands.Command, group string) {
+	switch c := cmd.(type) {
+	case CancelableCommand:
+		cmd = Cancelable(c, cdr.opts...)
+	case io.Closer:
+		cmd = Cancelable(closerCommand{Command: cmd, closer: c}, cdr.opts...)
+	}
+
+	cdr.Commander.Register(cmd, group)
+}
+
+// SetOutput sets the writers of the output of every execution: the help of the Commander is
+// written to them, and Execute runs the commands with them set by WithOutput. A nil writer keeps
+// the current one.
+func (cdr *CancelableCommander) SetOutput(stdout, stderr io.Writer) {
+	if stdout != nil {
+		cdr.stdout = stdout
+		cdr.Output = stdout
+	}
+	if stderr != nil {
+		cdr.stderr = stderr
+		cdr.Error = stderr
+	}
+}
+
+// Provide adds injectors run on the context of every execution by Execute, so that every command
+// dispatched by cdr can retrieve their values with Value or MustValue:
+//
+//	cdr.Provide(subcommandsutil.Inject(client), subcommandsutil.Inject(cfg))
+func (cdr *CancelableCommander) Provide(injectors ...Injector) {
+	cdr.injectors = append(cdr.injectors, injectors...)
+}
+
+// DispatchHooks is called by CancelableCommander around each dispatch, whichever command it runs.
+type DispatchHooks interface {
+	// BeforeDispatch is called before the command named name parses its arguments argv. The
+	// returned context is the one the command, and AfterDispatch, run with.
+	BeforeDispatch(ctx context.Context, name string, argv []string) context.Context
+
+	// AfterDispatch is called with the status of the dispatch, which is
+	// subcommands.ExitUsageError when no command is named name.
+	AfterDispatch(ctx context.Context, name string, status subcommands.ExitStatus)
+}
+
+// AddDispatchHooks adds hooks called by Execute around each dispatch. The BeforeDispatch methods
+// are called in the order the hooks were added, and the AfterDispatch methods in the reverse order.
+//
+// A panic of a hook is logged, to the Logger of the options of cdr, and handled according to the
+// HookErrorPolicy, or the one set by the SetHookErrorPolicy method: with FailCommand, a failed BeforeDispatch
+// skips the dispatch, and the status of a failed AfterDispatch is subcommands.ExitFailure.
+func (cdr *CancelableCommander) AddDispatchHooks(hooks ...DispatchHooks) {
+	cdr.hooks = append(cdr.hooks, hooks...)
+}
+
+// SetHookErrorPolicy sets the HookErrorPolicy of the dispatch hooks of cdr, instead of the policy
+// set by the package-level SetHookErrorPolicy.
+func (cdr *CancelableCommander) SetHookErrorPolicy(p HookErrorPolicy) {
+	cdr.policy = &p
+}
+
+// Execute runs the subcommand named by the top-level flags like subcommands.Commander.Execute,
+// with the writers set by SetOutput and the values of the injectors added by Provide, between the
+// hooks added by AddDispatchHooks.
+func (cdr *CancelableCommander) Execute(ctx context.Context, args ...interface{}) subcommands.ExitStatus {
+	if cdr.stdout != nil || cdr.stderr != nil {
+		ctx = WithOutput(ctx, cdr.stdout, cdr.stderr)
+	}
+	for _, inject := range cdr.injectors {
+		ctx = inject(ctx)
+	}
+	ctx = withDispatchArgs(context.WithValue(ctx, commanderKey{}, cdr), cdr.topFlags)
+	if len(cdr.hooks) == 0 {
+		return cdr.Commander.Execute(ctx, args...)
+	}
+
+	name, argv := cdr.topFlags.Arg(0), []string{}
+	if cdr.topFlags.NArg() > 1 {
+		argv = cdr.topFlags.Args()[1:]
+	}
+
+	return cdr.dispatch(ctx, name, argv, func(ctx context.Context) subcommands.ExitStatus {
+		return cdr.Commander.Execute(ctx, args...)
+	})
+}
+
+// commanderKey is the context key of the CancelableCommander executing the command.
+type commanderKey struct{}
+
+// dispatchCommander returns the CancelableCommander executing the commands of cdr on ctx, if any.
+func dispatchCommander(ctx context.Context, cdr *subcommands.Commander) (*CancelableCommander, bool) {
+	c, ok := ctx.Value(commanderKey{}).(*CancelableCommander)
+	return c, ok && c.Commander == cdr
+}
+
+// dispatch runs execute, the dispatch to the command named name with the arguments argv, between
+// the hooks added by AddDispatchHooks.
+func (cdr *CancelableCommander) dispatch(ctx context.Context, name string, argv []string, execute func(ctx context.Context) subcommands.ExitStatus) subcommands.ExitStatus {
+	logger, policy := contextLogger(ctx, cdr.logger), hookErrorPolicy(cdr.policy)
+
+	dispatch := true
+	for _, h := range cdr.hooks {
+		hctx := ctx
+		err := callHook(func() error {
+			hctx = h.BeforeDispatch(ctx, name, argv)
+			return nil
+		})
+		if err == nil {
+			ctx = hctx
+			continue
+		}
+		if p := hookFailed(ctx, logger, name, "before dispatch", err, policy); p != ContinueAndLog {
+			dispatch = p != FailCommand
+			break
+		}
+	}
+
+	status := subcommands.ExitFailure
+	if dispatch {
+		status = execute(ctx)
+	}
+	for i := len(cdr.hooks) - 1; i >= 0; i-- {
+		h := cdr.hooks[i]
+		err := callHook(func() error {
+			h.AfterDispatch(ctx, name, status)
+			return nil
+		})
+		if err == nil {
+			continue
+		}
+		p := hookFailed(ctx, logger, name, "after dispatch", err, policy)
+		if p == FailCommand && status == subcommands.ExitSuccess {
+			status = subcommands.ExitFailure
+		}
+		if p == Abort {
+			break
+		}
+	}
+
+	return status
+}
+
+// closerCommand is a CancelableCommand disposed by closing an io.Closer.
+type closerCommand struct {
+	subcommands.Command
+	closer io.Closer
+}
+
+// Dispose implements CancelableCommand.
+func (c closerCommand) Dispose() error {
+	return c.closer.Close()
+}
+
+// Unwrap returns the underlying c.Command.
+func (c closerCommand) Unwrap() subcommands.Command {
+	return c.Command
+}
@@ -0,0 +1,269 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+// closingCommand is a command with a Close method instead of Dispose.
+type closingCommand struct {
+	subcommands.Command
+	closed int32
+}
+
+func (c *closingCommand) Close() error {
+	atomic.AddInt32(&c.closed, 1)
+	return nil
+}
+
+func TestCancelableCommander(t *testing.T) {
+	defer testcmd.VerifyNoLeaks(t)
+
+	var stdout, stderr testcmd.Buffer
+	top := flag.NewFlagSet("tool", flag.ContinueOnError)
+	top.SetOutput(&stderr)
+	top.Bool("verbose", false, "verbose output")
+	cdr := subcommandsutil.NewCancelableCommander(top, "tool", subcommandsutil.WithLogger(&testcmd.LogRecorder{}))
+	cdr.Output, cdr.Error = &stdout, &stderr
+	cdr.Register(cdr.HelpCommand(), "")
+	cdr.ImportantFlag("verbose")
+
+	disposing := testcmd.NewBlocking("disposing")
+	defer disposing.Release(subcommands.ExitSuccess)
+	// a plain command, without Dispose
+	plain := testcmd.NewRecording("plain")
+	blocking := testcmd.NewBlocking("closing")
+	defer blocking.Release(subcommands.ExitSuccess)
+	closing := &closingCommand{Command: struct{ subcommands.Command }{blocking}}
+	cdr.Register(disposing, "")
+	cdr.Register(struct{ subcommands.Command }{plain}, "")
+	cdr.Register(closing, "")
+
+	wrapped := make(map[string]bool)
+	cdr.VisitCommands(func(_ *subcommands.CommandGroup, cmd subcommands.Command) {
+		_, ok := cmd.(subcommandsutil.CancelableWrapper)
+		wrapped[cmd.Name()] = ok
+	})
+	if !wrapped["disposing"] || !wrapped["closing"] || wrapped["plain"] || wrapped["help"] {
+		t.Fatalf("wanted only the disposing and closing commands to be wrapped but got %v", wrapped)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	execute := func(argv ...string) subcommands.ExitStatus {
+		if err := top.Parse(argv); err != nil {
+			t.Fatal(err)
+		}
+		return cdr.Execute(ctx)
+	}
+
+	testcmd.AssertStatus(t, execute("disposing"), subcommands.ExitFailure)
+	if disposing.DisposeCount() != 1 || disposing.DidFinish() {
+		t.Fatal("wanted the canceled disposing command to be disposed without finishing")
+	}
+	testcmd.AssertStatus(t, execute("closing"), subcommands.ExitFailure)
+	if atomic.LoadInt32(&closing.closed) != 1 {
+		t.Fatal("wanted the canceled closing command to be closed")
+	}
+	testcmd.RequireSuccess(t, execute("plain"))
+	if !plain.DidFinish() || plain.DisposeCount() != 0 {
+		t.Fatal("wanted the plain command to run without cancellation semantics")
+	}
+
+	testcmd.AssertStatus(t, execute("help"), subcommands.ExitSuccess)
+	if !strings.Contains(stdout.String(), "-verbose=false: verbose output") {
+		t.Fatalf("wanted the help to list the important flag but got %q", stdout.String())
+	}
+}
+
+func TestCancelableCommanderSetOutput(t *testing.T) {
+	var stdout, stderr testcmd.Buffer
+	top := flag.NewFlagSet("tool", flag.ContinueOnError)
+	top.SetOutput(&stderr)
+	cdr := subcommandsutil.NewCancelableCommander(top, "tool")
+	cdr.SetOutput(&stdout, &stderr)
+	cdr.Register(subcommandsutil.Logged(testcmd.NewRecording("greet",
+		testcmd.WithExecute(func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+			fmt.Fprintln(subcommandsutil.Stdout(ctx), "hello")
+			fmt.Fprintln(subcommandsutil.Stderr(ctx), "warning")
+			return subcommands.ExitSuccess
+		}),
+	)), "")
+
+	if err := top.Parse([]string{"greet"}); err != nil {
+		t.Fatal(err)
+	}
+	testcmd.RequireSuccess(t, cdr.Execute(context.Background()))
+	if want := "hello\n"; stdout.String() != want {
+		t.Fatalf("wanted stdout %q but got %q", want, stdout.String())
+	}
+	for _, want := range []string{"warning\n", "greet: running greet", "greet: finished with status 0"} {
+		if !strings.Contains(stderr.String(), want) {
+			t.Fatalf("wanted stderr to contain %q but got %q", want, stderr.String())
+		}
+	}
+
+	if err := top.Parse(nil); err != nil {
+		t.Fatal(err)
+	}
+	testcmd.AssertStatus(t, cdr.Execute(context.Background()), subcommands.ExitUsageError)
+	if !strings.Contains(stderr.String(), "Usage: tool") {
+		t.Fatalf("wanted the help written to the stderr set but got %q", stderr.String())
+	}
+}
+
+// dispatchRecorder is a DispatchHooks recording the dispatches as events.
+type dispatchRecorder struct {
+	name   string
+	events *[]string
+}
+
+// dispatchKey is the context key of the dispatch enriched by dispatchRecorder.
+type dispatchKey string
+
+// BeforeDispatch implements subcommandsutil.DispatchHooks.
+func (r dispatchRecorder) BeforeDispatch(ctx context.Context, name string, argv []string) context.Context {
+	*r.events = append(*r.events, fmt.Sprintf("%s.before %s %q", r.name, name, argv))
+	return subcommandsutil.WithValue(ctx, dispatchKey(r.name))
+}
+
+// AfterDispatch implements subcommandsutil.DispatchHooks.
+func (r dispatchRecorder) AfterDispatch(ctx context.Context, name string, status subcommands.ExitStatus) {
+	key, _ := subcommandsutil.Value[dispatchKey](ctx)
+	*r.events = append(*r.events, fmt.Sprintf("%s.after %s %d (%s)", r.name, name, status, key))
+}
+
+func TestCancelableCommanderDispatchHooks(t *testing.T) {
+	tests := map[string]struct {
+		argv       []string
+		wantStatus subcommands.ExitStatus
+		wantEvents []string
+	}{
+		"when a command is dispatched": {
+			argv:       []string{"build", "-v", "./..."},
+			wantStatus: subcommands.ExitFailure,
+			wantEvents: []string{
+				`outer.before build ["-v" "./..."]`,
+				`inner.before build ["-v" "./..."]`,
+				"execute inner",
+				"inner.after build 1 (inner)",
+				"outer.after build 1 (inner)",
+			},
+		},
+		"when an unknown command is dispatched": {
+			argv:       []string{"nope"},
+			wantStatus: subcommands.ExitUsageError,
+			wantEvents: []string{
+				"outer.before nope []",
+				"inner.before nope []",
+				"inner.after nope 2 (inner)",
+				"outer.after nope 2 (inner)",
+			},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var events []string
+			top := flag.NewFlagSet("tool", flag.ContinueOnError)
+			cdr := subcommandsutil.NewCancelableCommander(top, "tool")
+			cdr.SetOutput(&testcmd.Buffer{}, &testcmd.Buffer{})
+			cdr.AddDispatchHooks(dispatchRecorder{name: "outer", events: &events}, dispatchRecorder{name: "inner", events: &events})
+			cdr.Register(testcmd.NewRecording("build",
+				testcmd.WithFlags(func(f *flag.FlagSet) { f.Bool("v", false, "verbose") }),
+				testcmd.WithExecute(func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+					key, _ := subcommandsutil.Value[dispatchKey](ctx)
+					events = append(events, "execute "+string(key))
+					return subcommands.ExitFailure
+				}),
+			), "")
+
+			if err := top.Parse(tt.argv); err != nil {
+				t.Fatal(err)
+			}
+			testcmd.AssertStatus(t, cdr.Execute(context.Background()), tt.wantStatus)
+			if strings.Join(events, "\n") != strings.Join(tt.wantEvents, "\n") {
+				t.Fatalf("wanted the events %q but got %q", tt.wantEvents, events)
+			}
+		})
+	}
+}
+
+// panickingDispatch is a DispatchHooks whose BeforeDispatch panics.
+type panickingDispatch struct{}
+
+// BeforeDispatch implements subcommandsutil.DispatchHooks.
+func (panickingDispatch) BeforeDispatch(ctx context.Context, name string, argv []string) context.Context {
+	panic("telemetry unavailable")
+}
+
+// AfterDispatch implements subcommandsutil.DispatchHooks.
+func (panickingDispatch) AfterDispatch(ctx context.Context, name string, status subcommands.ExitStatus) {
+}
+
+func TestCancelableCommanderDispatchHooksErrorPolicy(t *testing.T) {
+	tests := map[string]struct {
+		policy     subcommandsutil.HookErrorPolicy
+		wantStatus subcommands.ExitStatus
+		wantEvents []string
+	}{
+		"when a hook panics with the ContinueAndLog policy": {
+			policy:     subcommandsutil.ContinueAndLog,
+			wantStatus: subcommands.ExitSuccess,
+			wantEvents: []string{`inner.before build []`, "execute inner", "inner.after build 0 (inner)"},
+		},
+		"when a hook panics with the FailCommand policy": {
+			policy:     subcommandsutil.FailCommand,
+			wantStatus: subcommands.ExitFailure,
+			wantEvents: []string{"inner.after build 1 ()"},
+		},
+		"when a hook panics with the Abort policy": {
+			policy:     subcommandsutil.Abort,
+			wantStatus: subcommands.ExitSuccess,
+			wantEvents: []string{"execute ", "inner.after build 0 ()"},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var events []string
+			var logs testcmd.LogRecorder
+			top := flag.NewFlagSet("tool", flag.ContinueOnError)
+			cdr := subcommandsutil.NewCancelableCommander(top, "tool", subcommandsutil.WithLogger(&logs))
+			cdr.SetHookErrorPolicy(tt.policy)
+			cdr.AddDispatchHooks(panickingDispatch{}, dispatchRecorder{name: "inner", events: &events})
+			cdr.Register(testcmd.NewRecording("build", testcmd.WithExecute(func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+				key, _ := subcommandsutil.Value[dispatchKey](ctx)
+				events = append(events, "execute "+string(key))
+				return subcommands.ExitSuccess
+			})), "")
+
+			if err := top.Parse([]string{"build"}); err != nil {
+				t.Fatal(err)
+			}
+			var failures []string
+			testcmd.AssertStatus(t, cdr.Execute(subcommandsutil.WithEvents(context.Background(), hookFailures(&failures))), tt.wantStatus)
+			if strings.Join(events, "\n") != strings.Join(tt.wantEvents, "\n") {
+				t.Fatalf("wanted the events %q but got %q", tt.wantEvents, events)
+			}
+			want := fmt.Sprintf("build: before dispatch hook: panic: telemetry unavailable (%v)", tt.policy)
+			if !logs.Contains(want) {
+				t.Fatalf("wanted the log to contain %q but got %q", want, logs.Lines())
+			}
+			if !reflect.DeepEqual(failures, []string{want}) {
+				t.Fatalf("wanted the HookFailed events %q but got %q", []string{want}, failures)
+			}
+		})
+	}
+}
@@ -0,0 +1,184 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"context"
+	"flag"
+	"strings"
+	"time"
+
+	"github.com/google/subcommands"
+)
+
+// DefaultCompletionTimeout is the time the completers of CompleteFlag and CompleteArgs are given
+// to return their values, after which the completion has no candidates.
+const DefaultCompletionTimeout = 500 * time.Millisecond
+
+// FlagCompleter returns the values completing prefix, the value typed for a flag. ctx is canceled
+// after DefaultCompletionTimeout.
+type FlagCompleter func(ctx context.Context, prefix string) []string
+
+// ArgsCompleter returns the values completing prefix, the pos-th positional argument typed, from
+// 0. ctx is canceled after DefaultCompletionTimeout.
+type ArgsCompleter func(ctx context.Context, prefix string, pos int) []string
+
+// completer wraps a subcommands.Command with the completer of one of its flags or of its
+// positional arguments.
+type completer struct {
+	sub  subcommands.Command
+	flag string
+	fn   FlagCompleter
+	args ArgsCompleter
+}
+
+// make sure completer implements the subcommands.Command interface.
+var _ subcommands.Command = (*completer)(nil)
+
+// CompleteFlag wraps cmd so that Complete and the completion scripts of CompletionCommand complete
+// the values of the flag named name with the values returned by fn, like the branches of a
+// repository:
+//
+//	cmd = subcommandsutil.CompleteFlag(cmd, "branch", func(ctx context.Context, prefix string) []string {
+//		out, err := exec.CommandContext(ctx, "git", "branch", "--format=%(refname:short)").Output()
+//		if err != nil {
+//			return nil
+//		}
+//		return strings.Fields(string(out))
+//	})
+//
+// The values not starting with the typed prefix are dropped. The completion has no candidates if fn
+// does not return before DefaultCompletionTimeout, or panics.
+func CompleteFlag(cmd subcommands.Command, name string, fn FlagCompleter) subcommands.Command {
+	return &completer{
+		sub:  cmd,
+		flag: name,
+		fn:   fn,
+	}
+}
+
+// CompleteArgs wraps cmd so that Complete and the completion scripts of CompletionCommand complete
+// its positional arguments with the values returned by fn, like CompleteFlag.
+func CompleteArgs(cmd subcommands.Command, fn ArgsCompleter) subcommands.Command {
+	return &completer{
+		sub:  cmd,
+		args: fn,
+	}
+}
+
+// Name forwards to the underlying c.sub Command.
+func (c *completer) Name() string {
+	return c.sub.Name()
+}
+
+// Usage forwards to the underlying c.sub Command.
+func (c *completer) Usage() string {
+	return c.sub.Usage()
+}
+
+// Synopsis forwards to the underlying c.sub Command.
+func (c *completer) Synopsis() string {
+	return c.sub.Synopsis()
+}
+
+// SetFlags forwards to the underlying c.sub Command.
+func (c *completer) SetFlags(f *flag.FlagSet) {
+	c.sub.SetFlags(f)
+}
+
+// Unwrap returns the underlying c.sub Command.
+func (c *completer) Unwrap() subcommands.Command {
+	return c.sub
+}
+
+// Execute forwards to the underlying c.sub Command.
+func (c *completer) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	return c.sub.Execute(ctx, f, args...)
+}
+
+// flagCompleterOf returns the completer registered by CompleteFlag on cmd, or on any Command it
+// wraps, for the flag named name of f.
+func flagCompleterOf(cmd subcommands.Command, f *flag.FlagSet, name string) (fn FlagCompleter, ok bool) {
+	walkCommand(cmd, func(cmd subcommands.Command) bool {
+		c, isCompleter := cmd.(*completer)
+		if isCompleter && c.fn != nil && CanonicalFlagName(f, c.flag) == name {
+			fn, ok = c.fn, true
+		}
+		return ok
+	})
+
+	return fn, ok
+}
+
+// argsCompleterOf returns the completer registered by CompleteArgs on cmd, or on any Command it
+// wraps.
+func argsCompleterOf(cmd subcommands.Command) (fn ArgsCompleter, ok bool) {
+	walkCommand(cmd, func(cmd subcommands.Command) bool {
+		c, isCompleter := cmd.(*completer)
+		if isCompleter && c.args != nil {
+			fn, ok = c.args, true
+		}
+		return ok
+	})
+
+	return fn, ok
+}
+
+// completerCandidates returns the values returned by complete starting with value, sorted and
+// prefixed by prefix. complete is called in its own goroutine with ctx canceled after
+// DefaultCompletionTimeout, and no candidates are returned if it does not return by then.
+func completerCandidates(ctx context.Context, prefix, value string, complete func(ctx context.Context) []string) []candidate {
+	ctx, cancel := withTimeout(ctx, DefaultCompletionTimeout)
+	defer cancel()
+
+	values := make(chan []string, 1)
+	go func() {
+		defer func() {
+			if recover() != nil {
+				values <- nil
+			}
+		}()
+		values <- complete(ctx)
+	}()
+
+	var cands []candidate
+	select {
+	case vs := <-values:
+		for _, v := range vs {
+			if strings.HasPrefix(v, value) {
+				cands = append(cands, candidate{value: prefix + v})
+			}
+		}
+	case <-ctx.Done():
+		return nil
+	}
+	sortCandidates(cands)
+
+	return cands
+}
+
+// positionalIndex returns the index of the positional argument following words, the words of the
+// command line after the command, and whether the flags of f ended before it, after a positional
+// argument or "--".
+func positionalIndex(f *flag.FlagSet, words []string) (pos int, flagsDone bool) {
+	for i := 0; i < len(words); i++ {
+		w := words[i]
+		if !flagsDone {
+			if w == "--" {
+				flagsDone = true
+				continue
+			}
+			if strings.HasPrefix(w, "-") {
+				if takesValue(f, w) {
+					i++ // the value of the flag
+				}
+				continue
+			}
+			flagsDone = true
+		}
+		pos++
+	}
+
+	return pos, flagsDone
+}
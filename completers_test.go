@@ -0,0 +1,117 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+// newCheckoutCommand returns a command completing its -branch flag with branches, and its
+// positional arguments with args.
+func newCheckoutCommand(branches subcommandsutil.FlagCompleter, args subcommandsutil.ArgsCompleter) subcommands.Command {
+	cmd := testcmd.NewRecording("checkout", testcmd.WithFlags(func(f *flag.FlagSet) {
+		f.String("branch", "", "check out `branch`")
+		f.Bool("force", false, "discard the local changes")
+		subcommandsutil.AliasFlag(f, "branch", "b")
+	}))
+
+	return subcommandsutil.CompleteArgs(subcommandsutil.CompleteFlag(cmd, "branch", branches), args)
+}
+
+func TestCompleteFlag(t *testing.T) {
+	branches := func(ctx context.Context, prefix string) []string {
+		return []string{"main", "master", "develop"}
+	}
+	args := func(ctx context.Context, prefix string, pos int) []string {
+		return []string{fmt.Sprintf("remote%d", pos), fmt.Sprintf("path%d", pos)}
+	}
+
+	tests := map[string]struct {
+		words    []string
+		branches subcommandsutil.FlagCompleter
+		want     []string
+	}{
+		"when completing the value of a flag": {
+			words: []string{"checkout", "-branch", "ma"},
+			want:  []string{"main", "master"},
+		},
+		"when completing the value of an alias after =": {
+			words: []string{"checkout", "-b=d"},
+			want:  []string{"-b=develop"},
+		},
+		"when completing the first positional argument": {
+			words: []string{"checkout", "-force", "-b", "main", "r"},
+			want:  []string{"remote0"},
+		},
+		"when completing the second positional argument": {
+			words: []string{"checkout", "remote0", ""},
+			want:  []string{"path1", "remote1"},
+		},
+		"when completing a positional argument after --": {
+			words: []string{"checkout", "--", "p"},
+			want:  []string{"path0"},
+		},
+		"when completing the flags": {
+			words: []string{"checkout", "-f"},
+			want:  []string{"-force"},
+		},
+		"when the completer panics": {
+			words: []string{"checkout", "-branch", ""},
+			branches: func(ctx context.Context, prefix string) []string {
+				panic("no repository")
+			},
+			want: nil,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			h := testcmd.NewHarness(t)
+			if tt.branches == nil {
+				tt.branches = branches
+			}
+			h.Register(newCheckoutCommand(tt.branches, args), "")
+
+			got := subcommandsutil.Complete(h.Commander, tt.words)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("wanted the candidates %q but got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestCompleteFlagTimeout(t *testing.T) {
+	defer testcmd.VerifyNoLeaks(t)
+
+	clk := testcmd.NewFakeClock(time.Now())
+	h := testcmd.NewHarness(t)
+	release := make(chan struct{})
+	slow := func(ctx context.Context, prefix string) []string {
+		<-release // ignores ctx, like a hung command
+		return []string{"main"}
+	}
+	h.Register(newCheckoutCommand(slow, nil), "")
+
+	done := make(chan []string)
+	go func() {
+		done <- subcommandsutil.CompleteContext(subcommandsutil.WithClock(context.Background(), clk), h.Commander, []string{"checkout", "-branch", ""})
+	}()
+	clk.BlockUntil(1)
+	clk.Advance(subcommandsutil.DefaultCompletionTimeout)
+
+	got := <-done
+	close(release)
+	if got != nil {
+		t.Fatalf("wanted no candidates but got %q", got)
+	}
+}
@@ -0,0 +1,415 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/google/subcommands"
+)
+
+// completeCommandName is the name of the hidden command the completion scripts call to get the
+// candidates.
+const completeCommandName = "__complete"
+
+// EnumValue is implemented by the flag.Value of flags taking one of a fixed set of values, which
+// are completed by the completion scripts of CompletionCommand.
+type EnumValue interface {
+	flag.Value
+
+	// Values returns the values the flag accepts.
+	Values() []string
+}
+
+// completion is the command printing the completion script of a shell.
+type completion struct {
+	cdr *subcommands.Commander
+}
+
+// make sure completion implements the subcommands.Command interface.
+var _ subcommands.Command = (*completion)(nil)
+
+// CompletionCommand returns a command named "completion" printing the completion script of the
+// shell given as its argument, bash, fish or zsh, for the commands registered in cdr, like:
+//
+//	eval "$(prog completion bash)"
+//	prog completion fish | source
+//	source <(prog completion zsh)
+//
+// The fish and zsh candidates are described by the Synopsis of the commands and the usage of the
+// flags.
+// It also registers a hidden "__complete" command in cdr, which the scripts call with the words of
+// the command line to get the candidates computed by Complete.
+func CompletionCommand(cdr *subcommands.Commander) subcommands.Command {
+	cdr.Register(Hidden(&complete{cdr: cdr}), "")
+
+	return &completion{
+		cdr: cdr,
+	}
+}
+
+// Name implements subcommands.Command.
+func (c *completion) Name() string {
+	return "completion"
+}
+
+// Synopsis implements subcommands.Command.
+func (c *completion) Synopsis() string {
+	return "print a shell completion script"
+}
+
+// Usage implements subcommands.Command.
+func (c *completion) Usage() string {
+	return "completion bash|fish|zsh:\n  Print the completion script of the shell.\n"
+}
+
+// SetFlags implements subcommands.Command. The completion command has no flags.
+func (c *completion) SetFlags(*flag.FlagSet) {}
+
+// Execute implements subcommands.Command.
+func (c *completion) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	if f.NArg() != 1 {
+		fmt.Fprintf(Stderr(ctx), "%s: wanted a single shell argument, one of %s\n", c.Name(), strings.Join(completionShells(), ", "))
+		return subcommands.ExitUsageError
+	}
+
+	write, ok := completionScripts[f.Arg(0)]
+	if !ok {
+		fmt.Fprintf(Stderr(ctx), "%s: unsupported shell %q; wanted one of %s\n", c.Name(), f.Arg(0), strings.Join(completionShells(), ", "))
+		return subcommands.ExitUsageError
+	}
+	write(Stdout(ctx), c.cdr.Name())
+
+	return subcommands.ExitSuccess
+}
+
+// completionScripts holds the functions writing the completion script of a program to w, by the
+// name of their shell.
+var completionScripts = map[string]func(w io.Writer, name string){
+	"bash": writeBashCompletion,
+	"fish": writeFishCompletion,
+	"zsh":  writeZshCompletion,
+}
+
+// completionShells returns the sorted names of the shells of completionScripts.
+func completionShells() []string {
+	shells := make([]string, 0, len(completionScripts))
+	for shell := range completionScripts {
+		shells = append(shells, shell)
+	}
+	sort.Strings(shells)
+
+	return shells
+}
+
+// nonIdentifier matches the characters which are not allowed in a shell function name.
+var nonIdentifier = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// writeBashCompletion writes the bash completion script of the program named name to w. The command
+// line is split on spaces up to the cursor rather than by COMP_WORDS, which splits "-flag=value"
+// on the "=".
+func writeBashCompletion(w io.Writer, name string) {
+	fmt.Fprintf(w, `# bash completion for %[1]s
+_%[2]s_complete() {
+	local line="${COMP_LINE:0:COMP_POINT}"
+	local -a words
+	read -ra words <<< "$line"
+	[[ "$line" == *" " ]] && words+=("")
+	local cur="${words[${#words[@]}-1]}"
+	local IFS=$'\n'
+	COMPREPLY=($("${words[0]}" %[3]s -- "${words[@]:1}" 2>/dev/null))
+	if [[ "$cur" == *=* && "$COMP_WORDBREAKS" == *=* ]]; then
+		COMPREPLY=("${COMPREPLY[@]#*=}")
+	fi
+}
+complete -o default -F _%[2]s_complete %[1]s
+`, name, nonIdentifier.ReplaceAllString(name, "_"), completeCommandName)
+}
+
+// writeZshCompletion writes the zsh completion script of the program named name to w. The
+// candidates are described by the Synopsis of the commands and the usage of the flags.
+func writeZshCompletion(w io.Writer, name string) {
+	fmt.Fprintf(w, `#compdef %[1]s
+# zsh completion for %[1]s
+_%[2]s() {
+	local -a lines described
+	local line value tab=$'\t'
+	local IFS=$'\n'
+	lines=($("${words[1]}" %[3]s -descriptions -- "${(@)words[2,CURRENT]}" 2>/dev/null))
+	for line in "${lines[@]}"; do
+		value="${${line%%%%${tab}*}//:/\\:}"
+		if [[ "$line" == *${tab}* ]]; then
+			described+=("$value:${line#*${tab}}")
+		else
+			described+=("$value")
+		fi
+	done
+	_describe -t candidates '%[1]s' described
+}
+compdef _%[2]s %[1]s
+`, name, nonIdentifier.ReplaceAllString(name, "_"), completeCommandName)
+}
+
+// writeFishCompletion writes the fish completion script of the program named name to w. The
+// candidates are described by the Synopsis of the commands and the usage of the flags.
+func writeFishCompletion(w io.Writer, name string) {
+	fmt.Fprintf(w, `# fish completion for %[1]s
+function __%[2]s_complete
+	set -l tokens (commandline -opc)
+	set -l cur (commandline -ct)
+	$tokens[1] %[3]s -descriptions -- $tokens[2..-1] "$cur" 2>/dev/null
+end
+complete -c %[1]s -f -a '(__%[2]s_complete)'
+`, name, nonIdentifier.ReplaceAllString(name, "_"), completeCommandName)
+}
+
+// complete is the hidden command printing the candidates of Complete.
+type complete struct {
+	cdr          *subcommands.Commander
+	descriptions bool
+}
+
+// make sure complete implements the subcommands.Command interface.
+var _ subcommands.Command = (*complete)(nil)
+
+// Name implements subcommands.Command.
+func (c *complete) Name() string {
+	return completeCommandName
+}
+
+// Synopsis implements subcommands.Command.
+func (c *complete) Synopsis() string {
+	return "print the completion candidates of a command line"
+}
+
+// Usage implements subcommands.Command.
+func (c *complete) Usage() string {
+	return completeCommandName + " [-descriptions] -- [words...]:\n  Print the completion candidates of the last word, one per line.\n"
+}
+
+// SetFlags implements subcommands.Command.
+func (c *complete) SetFlags(f *flag.FlagSet) {
+	f.BoolVar(&c.descriptions, "descriptions", false, "follow the candidates by a tab and their description")
+}
+
+// Execute implements subcommands.Command.
+func (c *complete) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	w := Stdout(ctx)
+	for _, cand := range completeCandidates(ctx, c.cdr, f.Args()) {
+		if c.descriptions && cand.description != "" {
+			fmt.Fprintf(w, "%s\t%s\n", cand.value, firstLine(cand.description))
+			continue
+		}
+		fmt.Fprintln(w, cand.value)
+	}
+
+	return subcommands.ExitSuccess
+}
+
+// candidate is a completion candidate.
+type candidate struct {
+	value       string
+	description string
+}
+
+// sortCandidates sorts cands by value.
+func sortCandidates(cands []candidate) {
+	sort.Slice(cands, func(i, j int) bool {
+		return cands[i].value < cands[j].value
+	})
+}
+
+// firstLine returns the first line of s.
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+
+	return line
+}
+
+// Complete returns the sorted completion candidates of the last of words, the words of a command
+// line after the program name, for the commands registered in cdr. The last word is the one being
+// typed, and is empty when a new word is started.
+//
+// The first word which is not a flag is completed with the names of the visible commands, and a
+// word starting with "-" with the names of the visible flags of the command, or of the top-level
+// flags before the command. The value of a flag whose Value is an EnumValue, either as the word
+// after the flag or after "=", is completed with its values. The values of the flags and the
+// positional arguments of the commands wrapped by CompleteFlag and CompleteArgs are completed with
+// the values returned by their completers.
+func Complete(cdr *subcommands.Commander, words []string) []string {
+	return CompleteContext(context.Background(), cdr, words)
+}
+
+// CompleteContext is like Complete, but calls the completers of CompleteFlag and CompleteArgs with
+// ctx, canceled after DefaultCompletionTimeout.
+func CompleteContext(ctx context.Context, cdr *subcommands.Commander, words []string) []string {
+	var values []string
+	for _, cand := range completeCandidates(ctx, cdr, words) {
+		values = append(values, cand.value)
+	}
+
+	return values
+}
+
+// completeCandidates returns the candidates of CompleteContext, with their descriptions.
+func completeCandidates(ctx context.Context, cdr *subcommands.Commander, words []string) []candidate {
+	if len(words) == 0 {
+		words = []string{""}
+	}
+	prev, cur := words[:len(words)-1], words[len(words)-1]
+
+	top := flag.NewFlagSet(cdr.Name(), flag.ContinueOnError)
+	cdr.VisitAll(func(fl *flag.Flag) {
+		top.Var(fl.Value, fl.Name, fl.Usage)
+	})
+
+	for i := 0; i < len(prev); i++ {
+		if w := prev[i]; strings.HasPrefix(w, "-") {
+			if takesValue(top, w) {
+				i++ // the value of the flag
+			}
+			continue
+		}
+
+		cmd := lookupCommand(cdr, prev[i])
+		if cmd == nil {
+			return nil
+		}
+		f := flag.NewFlagSet(cmd.Name(), flag.ContinueOnError)
+		f.SetOutput(io.Discard)
+		cmd.SetFlags(f)
+
+		return flagCandidates(ctx, cmd, f, prev[i+1:], cur)
+	}
+
+	if values, ok := flagValueCandidates(ctx, nil, top, prev, cur); ok {
+		return values
+	}
+	if strings.HasPrefix(cur, "-") {
+		return flagCandidates(ctx, nil, top, prev, cur)
+	}
+
+	return commandCandidates(cdr, cur)
+}
+
+// takesValue reports whether w, a word starting with "-", is a flag of f taking its value from the
+// next word.
+func takesValue(f *flag.FlagSet, w string) bool {
+	if strings.Contains(w, "=") || w == "--" {
+		return false
+	}
+	fl := f.Lookup(CanonicalFlagName(f, strings.TrimLeft(w, "-")))
+	if fl == nil {
+		return false
+	}
+	b, ok := fl.Value.(interface{ IsBoolFlag() bool })
+
+	return !ok || !b.IsBoolFlag()
+}
+
+// lookupCommand returns the command named name registered in cdr, or nil.
+func lookupCommand(cdr *subcommands.Commander, name string) (cmd subcommands.Command) {
+	cdr.VisitCommands(func(_ *subcommands.CommandGroup, c subcommands.Command) {
+		if c.Name() == name {
+			cmd = c
+		}
+	})
+
+	return cmd
+}
+
+// commandCandidates returns the names of the visible commands of cdr starting with prefix,
+// described by their Synopsis.
+func commandCandidates(cdr *subcommands.Commander, prefix string) []candidate {
+	var cands []candidate
+	cdr.VisitCommands(func(_ *subcommands.CommandGroup, cmd subcommands.Command) {
+		if !IsHiddenCommand(cmd) && strings.HasPrefix(cmd.Name(), prefix) {
+			cands = append(cands, candidate{cmd.Name(), cmd.Synopsis()})
+		}
+	})
+	sortCandidates(cands)
+
+	return cands
+}
+
+// flagCandidates returns the candidates of cur, the word typed after prev in the flags f of cmd:
+// the values of the flag cur or prev sets, the names of the visible flags of f described by their
+// usage, or the values of the positional argument completer of cmd.
+func flagCandidates(ctx context.Context, cmd subcommands.Command, f *flag.FlagSet, prev []string, cur string) []candidate {
+	if values, ok := flagValueCandidates(ctx, cmd, f, prev, cur); ok {
+		return values
+	}
+	pos, flagsDone := positionalIndex(f, prev)
+	if flagsDone || !strings.HasPrefix(cur, "-") {
+		complete, ok := argsCompleterOf(cmd)
+		if !ok {
+			return nil
+		}
+		return completerCandidates(ctx, "", cur, func(ctx context.Context) []string {
+			return complete(ctx, cur, pos)
+		})
+	}
+
+	dashes := "-"
+	if strings.HasPrefix(cur, "--") {
+		dashes = "--"
+	}
+	prefix := strings.TrimPrefix(cur, dashes)
+
+	var cands []candidate
+	f.VisitAll(func(fl *flag.Flag) {
+		if !IsHiddenFlag(f, fl.Name) && strings.HasPrefix(fl.Name, prefix) {
+			cands = append(cands, candidate{dashes + fl.Name, fl.Usage})
+		}
+	})
+	sortCandidates(cands)
+
+	return cands
+}
+
+// flagValueCandidates returns the values of the flag of f cur is the value of, either as
+// "-flag=value" or after a last word of prev which takes a value: the values of an EnumValue, or
+// the values of the completer of the flag registered on cmd by CompleteFlag. It reports false if
+// cur is not the value of a flag.
+func flagValueCandidates(ctx context.Context, cmd subcommands.Command, f *flag.FlagSet, prev []string, cur string) ([]candidate, bool) {
+	var name, value, prefix string
+	switch eq := strings.IndexByte(cur, '='); {
+	case strings.HasPrefix(cur, "-") && eq >= 0:
+		name, value, prefix = cur[:eq], cur[eq+1:], cur[:eq+1]
+	case !strings.HasPrefix(cur, "-") && len(prev) > 0 && strings.HasPrefix(prev[len(prev)-1], "-") && takesValue(f, prev[len(prev)-1]):
+		name, value = prev[len(prev)-1], cur
+	default:
+		return nil, false
+	}
+
+	fl := f.Lookup(CanonicalFlagName(f, strings.TrimLeft(name, "-")))
+	if fl == nil {
+		return nil, true
+	}
+	if complete, ok := flagCompleterOf(cmd, f, fl.Name); ok {
+		return completerCandidates(ctx, prefix, value, func(ctx context.Context) []string {
+			return complete(ctx, value)
+		}), true
+	}
+	enum, ok := fl.Value.(EnumValue)
+	if !ok {
+		return nil, true
+	}
+
+	var cands []candidate
+	for _, v := range enum.Values() {
+		if strings.HasPrefix(v, value) {
+			cands = append(cands, candidate{value: prefix + v})
+		}
+	}
+	sortCandidates(cands)
+
+	return cands, true
+}
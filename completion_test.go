@@ -0,0 +1,184 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"context"
+	"flag"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+// formatValue is a flag.Value taking one of a few output formats.
+type formatValue string
+
+// String implements flag.Value.
+func (v *formatValue) String() string { return string(*v) }
+
+// Set implements flag.Value.
+func (v *formatValue) Set(s string) error {
+	*v = formatValue(s)
+	return nil
+}
+
+// Values implements subcommandsutil.EnumValue.
+func (v *formatValue) Values() []string { return []string{"text", "json", "jsonl"} }
+
+// newCompletionHarness returns a Harness with commands for the completion tests.
+func newCompletionHarness(t *testing.T) *testcmd.Harness {
+	h := testcmd.NewHarness(t)
+	h.Flags.Bool("v", false, "verbose output")
+	h.Flags.String("config", "", "config file")
+
+	h.Register(testcmd.NewRecording("push", testcmd.WithFlags(func(f *flag.FlagSet) {
+		var format formatValue
+		f.Var(&format, "format", "output format")
+		subcommandsutil.AliasFlag(f, "format", "f")
+		f.Bool("force", false, "force the push")
+		f.String("remote", "", "remote name")
+		f.Bool("secret", false, "hidden flag")
+		subcommandsutil.HideFlags(f, "secret")
+	})), "")
+	h.Register(testcmd.NewRecording("pull"), "")
+	h.Register(testcmd.NewRecording("prune"), "")
+	h.Register(subcommandsutil.Hidden(testcmd.NewRecording("private")), "")
+	h.Register(subcommandsutil.CompletionCommand(h.Commander), "")
+
+	return h
+}
+
+func TestComplete(t *testing.T) {
+	tests := map[string]struct {
+		words []string
+		want  []string
+	}{
+		"when completing a command name": {
+			words: []string{"p"},
+			want:  []string{"prune", "pull", "push"},
+		},
+		"when completing a command name after top-level flags": {
+			words: []string{"-v", "-config", "prog.yaml", "pu"},
+			want:  []string{"pull", "push"},
+		},
+		"when completing a top-level flag": {
+			words: []string{"-c"},
+			want:  []string{"-config"},
+		},
+		"when completing the flags of a command": {
+			words: []string{"push", "-f"},
+			want:  []string{"-f", "-force", "-format"},
+		},
+		"when completing the flags with two dashes": {
+			words: []string{"push", "--fo"},
+			want:  []string{"--force", "--format"},
+		},
+		"when completing a hidden flag": {
+			words: []string{"push", "-sec"},
+			want:  nil,
+		},
+		"when completing the values of an enum flag": {
+			words: []string{"push", "-format", "js"},
+			want:  []string{"json", "jsonl"},
+		},
+		"when completing the values of an enum flag after an alias": {
+			words: []string{"push", "-f", ""},
+			want:  []string{"json", "jsonl", "text"},
+		},
+		"when completing the values of an enum flag after =": {
+			words: []string{"push", "-format=t"},
+			want:  []string{"-format=text"},
+		},
+		"when completing the value of a flag which is not an enum": {
+			words: []string{"push", "-remote", ""},
+			want:  nil,
+		},
+		"when completing after a boolean flag": {
+			words: []string{"push", "-force", "-rem"},
+			want:  []string{"-remote"},
+		},
+		"when the command is not registered": {
+			words: []string{"unknown", "-"},
+			want:  nil,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := subcommandsutil.Complete(newCompletionHarness(t).Commander, tt.words)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("wanted the candidates %q but got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestCompletionCommand(t *testing.T) {
+	tests := map[string]struct {
+		args       []string
+		wantStatus subcommands.ExitStatus
+		wantStdout []string
+		wantStderr string
+	}{
+		"when printing the bash script": {
+			args:       []string{"completion", "bash"},
+			wantStatus: subcommands.ExitSuccess,
+			wantStdout: []string{`"${words[0]}" __complete -- "${words[@]:1}"`, "complete -o default -F _TestCompletionCommand_when_printing_the_bash_script_complete TestCompletionCommand/when_printing_the_bash_script\n"},
+		},
+		"when the shell is not supported": {
+			args:       []string{"completion", "tcsh"},
+			wantStatus: subcommands.ExitUsageError,
+			wantStderr: "completion: unsupported shell \"tcsh\"; wanted one of bash, fish, zsh\n",
+		},
+		"when the hidden command prints the candidates": {
+			args:       []string{"__complete", "--", "push", "-format", "j"},
+			wantStatus: subcommands.ExitSuccess,
+			wantStdout: []string{"json\njsonl\n"},
+		},
+		"when the hidden command prints the candidates with descriptions": {
+			args:       []string{"__complete", "-descriptions", "--", "push", "-fo"},
+			wantStatus: subcommands.ExitSuccess,
+			wantStdout: []string{"-force\tforce the push\n-format\toutput format\n"},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			h := newCompletionHarness(t)
+			status := h.Execute(context.Background(), tt.args...)
+
+			testcmd.AssertStatus(t, status, tt.wantStatus)
+			for _, want := range tt.wantStdout {
+				if !strings.Contains(h.Stdout.String(), want) {
+					t.Fatalf("wanted the stdout to hold %q but got %q", want, h.Stdout.String())
+				}
+			}
+			if got := h.Stderr.String(); got != tt.wantStderr {
+				t.Fatalf("wanted the stderr %q but got %q", tt.wantStderr, got)
+			}
+		})
+	}
+}
+
+func TestCompletionScripts(t *testing.T) {
+	for _, shell := range []string{"bash", "fish", "zsh"} {
+		t.Run("when printing the "+shell+" script", func(t *testing.T) {
+			top := flag.NewFlagSet("prog", flag.ContinueOnError)
+			cdr := subcommands.NewCommander(top, "prog")
+			cdr.Register(testcmd.NewRecording("push"), "")
+			cdr.Register(testcmd.NewRecording("pull"), "")
+			cdr.Register(subcommandsutil.CompletionCommand(cdr), "")
+
+			var stdout testcmd.Buffer
+			if err := top.Parse([]string{"completion", shell}); err != nil {
+				t.Fatal(err)
+			}
+			testcmd.RequireSuccess(t, cdr.Execute(subcommandsutil.WithOutput(context.Background(), &stdout, &stdout)))
+			testcmd.Golden(t, stdout.String(), "testdata/completion_"+shell+".golden")
+		})
+	}
+}
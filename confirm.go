@@ -0,0 +1,166 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/google/subcommands"
+)
+
+// ConfirmOption is an option of the Confirm wrapper.
+type ConfirmOption interface {
+	applyConfirm(*confirm)
+}
+
+// confirmOptionFunc is a ConfirmOption implemented by a function.
+type confirmOptionFunc func(*confirm)
+
+// applyConfirm implements ConfirmOption.
+func (fn confirmOptionFunc) applyConfirm(c *confirm) { fn(c) }
+
+// WithConfirmStdin makes Confirm read the answer from r instead of os.Stdin. r is treated as a
+// terminal.
+func WithConfirmStdin(r io.Reader) ConfirmOption {
+	return confirmOptionFunc(func(c *confirm) {
+		c.stdin = r
+		c.terminal = true
+	})
+}
+
+// WithConfirmStatus sets the ExitStatus returned when the command is not confirmed. It defaults to
+// subcommands.ExitFailure.
+func WithConfirmStatus(status subcommands.ExitStatus) ConfirmOption {
+	return confirmOptionFunc(func(c *confirm) {
+		c.status = status
+	})
+}
+
+// confirm wraps a subcommands.Command so that its executions are confirmed by the user.
+type confirm struct {
+	sub      subcommands.Command
+	prompt   string
+	stdin    io.Reader
+	terminal bool
+	status   subcommands.ExitStatus
+
+	yes bool
+}
+
+// make sure confirm implements the subcommands.Command interface.
+var _ subcommands.Command = (*confirm)(nil)
+
+// Confirm wraps sub so that each execution asks the user to confirm, by printing prompt followed by
+// " [y/N] " to Stderr and reading a line from stdin. The -yes flag registered by the wrapper skips
+// the confirmation.
+//
+// sub is not run, and the ExitStatus set by WithConfirmStatus is returned, if the answer is not "y"
+// or "yes", if stdin is not a terminal, or if the execution context is done while waiting for the
+// answer.
+func Confirm(sub subcommands.Command, prompt string, opts ...ConfirmOption) subcommands.Command {
+	c := &confirm{
+		sub:      sub,
+		prompt:   prompt,
+		stdin:    os.Stdin,
+		terminal: isTerminal(os.Stdin),
+		status:   subcommands.ExitFailure,
+	}
+	for _, opt := range opts {
+		opt.applyConfirm(c)
+	}
+
+	return c
+}
+
+// Name forwards to the underlying c.sub Command.
+func (c *confirm) Name() string {
+	return c.sub.Name()
+}
+
+// Usage forwards to the underlying c.sub Command.
+func (c *confirm) Usage() string {
+	return c.sub.Usage()
+}
+
+// Synopsis forwards to the underlying c.sub Command.
+func (c *confirm) Synopsis() string {
+	return c.sub.Synopsis()
+}
+
+// Unwrap returns the underlying c.sub Command.
+func (c *confirm) Unwrap() subcommands.Command {
+	return c.sub
+}
+
+// SetFlags forwards to the underlying c.sub Command and registers the -yes flag.
+func (c *confirm) SetFlags(f *flag.FlagSet) {
+	c.sub.SetFlags(f)
+	f.BoolVar(&c.yes, "yes", false, "run without asking for confirmation")
+}
+
+// Execute asks for confirmation, unless the -yes flag is set, and forwards to the underlying c.sub
+// Command if it is confirmed.
+func (c *confirm) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	if !c.yes {
+		m := MessagesFromContext(ctx)
+		if !c.terminal {
+			fmt.Fprintln(Stderr(ctx), m.Sprintf(MessageConfirmRefused, c.sub.Name()))
+			return c.status
+		}
+
+		fmt.Fprint(Stderr(ctx), m.Sprintf(MessageConfirmPrompt, c.prompt))
+		answer, err := readLine(ctx, bufio.NewReader(c.stdin))
+		if err != nil {
+			fmt.Fprintf(Stderr(ctx), "\n%s: %v\n", m.Sprintf(MessageConfirmAborted, c.sub.Name()), err)
+			return c.status
+		}
+		if !isAffirmative(answer) {
+			fmt.Fprintln(Stderr(ctx), m.Sprintf(MessageConfirmAborted, c.sub.Name()))
+			return c.status
+		}
+	}
+
+	return c.sub.Execute(ctx, f, args...)
+}
+
+// isAffirmative reports whether answer is "y" or "yes", in any case.
+func isAffirmative(answer string) bool {
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	default:
+		return false
+	}
+}
+
+// readLine reads a line from r, or returns the error of ctx if ctx is done first. The read itself
+// cannot be interrupted, so it keeps waiting for a line in the background after ctx is done.
+func readLine(ctx context.Context, r *bufio.Reader) (string, error) {
+	type result struct {
+		line string
+		err  error
+	}
+	// buffered so that the goroutine exits once the read returns even after ctx is done
+	ch := make(chan result, 1)
+	go func() {
+		line, err := r.ReadString('\n')
+		if err == io.EOF && line != "" {
+			err = nil
+		}
+		ch <- result{line: line, err: err}
+	}()
+
+	select {
+	case <-ctx.Done():
+		return "", ctx.Err()
+	case res := <-ch:
+		return res.line, res.err
+	}
+}
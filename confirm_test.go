@@ -0,0 +1,127 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+func TestConfirm(t *testing.T) {
+	tests := map[string]struct {
+		args       []string
+		stdin      io.Reader
+		opts       []subcommandsutil.ConfirmOption
+		wantStatus subcommands.ExitStatus
+		wantRun    bool
+		wantStderr string
+	}{
+		"when the answer is yes": {
+			stdin:      strings.NewReader("yes\n"),
+			wantStatus: subcommands.ExitSuccess,
+			wantRun:    true,
+			wantStderr: "delete everything? [y/N] ",
+		},
+		"when the answer is y without a newline": {
+			stdin:      strings.NewReader("Y"),
+			wantStatus: subcommands.ExitSuccess,
+			wantRun:    true,
+			wantStderr: "delete everything? [y/N] ",
+		},
+		"when the answer is no": {
+			stdin:      strings.NewReader("n\n"),
+			wantStatus: subcommands.ExitFailure,
+			wantStderr: "delete everything? [y/N] purge: aborted\n",
+		},
+		"when the answer is empty": {
+			stdin:      strings.NewReader("\n"),
+			opts:       []subcommandsutil.ConfirmOption{subcommandsutil.WithConfirmStatus(subcommands.ExitUsageError)},
+			wantStatus: subcommands.ExitUsageError,
+			wantStderr: "delete everything? [y/N] purge: aborted\n",
+		},
+		"when -yes is set": {
+			args:       []string{"-yes"},
+			stdin:      strings.NewReader(""),
+			wantStatus: subcommands.ExitSuccess,
+			wantRun:    true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			rec := testcmd.NewRecording("purge")
+			opts := append([]subcommandsutil.ConfirmOption{subcommandsutil.WithConfirmStdin(tt.stdin)}, tt.opts...)
+			cmd := subcommandsutil.Confirm(rec, "delete everything?", opts...)
+
+			var stderr testcmd.Buffer
+			ctx := subcommandsutil.WithOutput(context.Background(), nil, &stderr)
+			status, _, err := testcmd.Run(ctx, cmd, tt.args...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			testcmd.AssertStatus(t, status, tt.wantStatus)
+			if got := rec.DidFinish(); got != tt.wantRun {
+				t.Fatalf("wanted the command to run %t but got %t", tt.wantRun, got)
+			}
+			if got := stderr.String(); got != tt.wantStderr {
+				t.Fatalf("wanted stderr %q but got %q", tt.wantStderr, got)
+			}
+		})
+	}
+}
+
+func TestConfirmNotTerminal(t *testing.T) {
+	rec := testcmd.NewRecording("purge")
+	cmd := subcommandsutil.Confirm(rec, "delete everything?")
+
+	var stderr testcmd.Buffer
+	ctx := subcommandsutil.WithOutput(context.Background(), nil, &stderr)
+	status, _, err := testcmd.Run(ctx, cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stderr.String() == "delete everything? [y/N] " {
+		t.Skip("stdin of the test is a terminal")
+	}
+	testcmd.AssertStatus(t, status, subcommands.ExitFailure)
+	if rec.DidFinish() {
+		t.Fatal("wanted the command not to run without confirmation")
+	}
+	if want := "purge: refusing to run without confirmation; use -yes\n"; stderr.String() != want {
+		t.Fatalf("wanted stderr %q but got %q", want, stderr.String())
+	}
+}
+
+func TestConfirmCanceled(t *testing.T) {
+	defer testcmd.VerifyNoLeaks(t)
+
+	stdin, w := io.Pipe()
+	defer w.Close()
+
+	rec := testcmd.NewRecording("purge")
+	cmd := subcommandsutil.Confirm(rec, "delete everything?", subcommandsutil.WithConfirmStdin(stdin))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	var stderr testcmd.Buffer
+	status, _, err := testcmd.Run(subcommandsutil.WithOutput(ctx, nil, &stderr), cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testcmd.AssertStatus(t, status, subcommands.ExitFailure)
+	if rec.DidFinish() {
+		t.Fatal("wanted the command not to run when canceled")
+	}
+	if want := "delete everything? [y/N] \npurge: aborted: context deadline exceeded\n"; stderr.String() != want {
+		t.Fatalf("wanted stderr %q but got %q", want, stderr.String())
+	}
+}
@@ -0,0 +1,178 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// controlTimeout bounds how long a connection to the control socket may take to send its request
+// and read the reply.
+const controlTimeout = 5 * time.Second
+
+// WithControlSocket makes Cancelable listen on the unix domain socket at path while it executes, so
+// that another local process can control the execution with a request line:
+//
+//   - "cancel" cancels the execution as if its context was canceled, and is replied "ok".
+//   - "status" is replied the name of the command and how long it has been running, like
+//     "agent 1m30s".
+//
+// The socket is created with the 0600 permissions, replacing a stale socket left at path but no
+// other file, and is removed once the execution finished or was canceled and the command disposed.
+// CancelRemote sends the "cancel" request.
+func WithControlSocket(path string) CancelableOption {
+	return cancelableOptionFunc(func(c *cancelable) {
+		c.controlPath = path
+	})
+}
+
+// CancelRemote requests the cancellation of the execution of Cancelable listening on the control
+// socket at path, set by WithControlSocket. It returns once the request is accepted, without
+// waiting for the execution to stop.
+func CancelRemote(path string) error {
+	reply, err := controlRequest(path, "cancel")
+	if err != nil {
+		return err
+	}
+	if reply != "ok" {
+		return fmt.Errorf("cancel %s: %s", path, reply)
+	}
+
+	return nil
+}
+
+// controlRequest sends the request line req to the control socket at path and returns the reply
+// line.
+func controlRequest(path, req string) (string, error) {
+	conn, err := net.DialTimeout("unix", path, controlTimeout)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	if err := conn.SetDeadline(time.Now().Add(controlTimeout)); err != nil {
+		return "", err
+	}
+	if _, err := fmt.Fprintf(conn, "%s\n", req); err != nil {
+		return "", err
+	}
+	reply, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		return "", fmt.Errorf("read the reply of %s: %w", path, err)
+	}
+
+	return strings.TrimSuffix(reply, "\n"), nil
+}
+
+// listenControl listens on the control socket at path, replacing a stale socket which nobody
+// listens on anymore. Anything else at path is left alone.
+func listenControl(path string) (net.Listener, error) {
+	if fi, err := os.Lstat(path); err == nil {
+		if fi.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("listen unix %s: not a socket", path)
+		}
+		if conn, err := net.Dial("unix", path); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("listen unix %s: address already in use", path)
+		}
+	}
+
+	// bound in a directory of its own, so that nobody connects before the socket is made 0600
+	dir, err := os.MkdirTemp(filepath.Dir(path), ".control-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	tmp := filepath.Join(dir, "s")
+	ln, err := net.Listen("unix", tmp)
+	if err != nil {
+		return nil, err
+	}
+	// once moved to path, closing ln only unlinks tmp: the socket is removed by closeControl
+	err = os.Chmod(tmp, 0o600)
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		ln.Close()
+		return nil, err
+	}
+
+	return ln, nil
+}
+
+// serveControl serves the control socket of c until the returned function is called, which closes
+// and removes the socket.
+func (c *cancelable) serveControl(ctx context.Context) (closeControl func(), err error) {
+	ln, err := listenControl(c.controlPath)
+	if err != nil {
+		return nil, fmt.Errorf("control socket: %w", err)
+	}
+
+	clk := ClockFromContext(ctx)
+	start := clk.Now()
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				c.handleControl(conn, done, func() time.Duration { return clk.Now().Sub(start) })
+			}()
+		}
+	}()
+
+	return func() {
+		close(done)
+		ln.Close()
+		wg.Wait()
+		os.Remove(c.controlPath)
+	}, nil
+}
+
+// handleControl replies the request read from conn, and closes conn. conn is closed early when done
+// is closed.
+func (c *cancelable) handleControl(conn net.Conn, done <-chan struct{}, uptime func() time.Duration) {
+	finished := make(chan struct{})
+	defer close(finished)
+	go func() {
+		select {
+		case <-done:
+			conn.Close()
+		case <-finished:
+		}
+	}()
+	defer conn.Close()
+
+	_ = conn.SetDeadline(time.Now().Add(controlTimeout))
+	req, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil && req == "" {
+		return
+	}
+
+	switch req = strings.TrimSpace(req); req {
+	case "cancel":
+		fmt.Fprintln(conn, "ok")
+		c.Stop()
+	case "status":
+		fmt.Fprintf(conn, "%s %v\n", c.sub.Name(), uptime().Round(time.Second))
+	default:
+		fmt.Fprintf(conn, "error: unknown request %q\n", req)
+	}
+}
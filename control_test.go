@@ -0,0 +1,182 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+// controlSocketPath returns the path of a control socket in a temporary directory short enough for
+// the length limit of the unix socket paths.
+func controlSocketPath(t *testing.T) string {
+	t.Helper()
+
+	dir, err := os.MkdirTemp("", "ctl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	return filepath.Join(dir, "agent.sock")
+}
+
+// controlRequest sends the request line req to the control socket at path and returns the reply.
+func controlRequest(t *testing.T, path, req string) string {
+	t.Helper()
+
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte(req + "\n")); err != nil {
+		t.Fatal(err)
+	}
+	reply, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return reply
+}
+
+func TestWithControlSocket(t *testing.T) {
+	defer testcmd.VerifyNoLeaks(t)
+
+	path := controlSocketPath(t)
+	tcmd := testcmd.NewBlocking("agent")
+	defer tcmd.Release(subcommands.ExitSuccess)
+
+	clk := testcmd.NewFakeClock(time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC))
+	cmd := subcommandsutil.Cancelable(tcmd, subcommandsutil.WithControlSocket(path), subcommandsutil.WithLogger(&testcmd.LogRecorder{}))
+
+	done := make(chan subcommands.ExitStatus)
+	go func() {
+		status, _, _ := testcmd.Run(subcommandsutil.WithClock(context.Background(), clk), cmd)
+		done <- status
+	}()
+	<-tcmd.Started()
+
+	if runtime.GOOS != "windows" {
+		fi, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if perm := fi.Mode().Perm(); perm != 0o600 {
+			t.Fatalf("wanted the socket permissions 0600 but got %v", perm)
+		}
+	}
+
+	clk.Advance(90 * time.Second)
+	if got, want := controlRequest(t, path, "status"), "agent 1m30s\n"; got != want {
+		t.Fatalf("wanted the status %q but got %q", want, got)
+	}
+	if got, want := controlRequest(t, path, "reboot"), "error: unknown request \"reboot\"\n"; got != want {
+		t.Fatalf("wanted the reply %q but got %q", want, got)
+	}
+	if tcmd.DisposeCount() != 0 {
+		t.Fatalf("wanted no Dispose before the cancel request but got %d", tcmd.DisposeCount())
+	}
+
+	if err := subcommandsutil.CancelRemote(path); err != nil {
+		t.Fatal(err)
+	}
+	testcmd.AssertStatus(t, <-done, subcommands.ExitFailure)
+	if !cmd.(subcommandsutil.CancelableWrapper).Canceled() {
+		t.Fatal("wanted the execution to be canceled but it was not")
+	}
+	if tcmd.DisposeCount() != 1 {
+		t.Fatalf("wanted Dispose to be called once but got %d", tcmd.DisposeCount())
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("wanted the socket to be removed but got %v", err)
+	}
+	if err := subcommandsutil.CancelRemote(path); err == nil {
+		t.Fatal("wanted an error once the socket is removed but got nil")
+	}
+}
+
+func TestWithControlSocketStale(t *testing.T) {
+	path := controlSocketPath(t)
+	// moved to path before closing, so that Close does not remove it
+	ln, err := net.Listen("unix", path+".tmp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		t.Fatal(err)
+	}
+	ln.Close()
+
+	sub := testcmd.NewRecording("agent")
+	cmd := subcommandsutil.Cancelable(sub, subcommandsutil.WithControlSocket(path))
+	status, _, _ := testcmd.Run(context.Background(), cmd)
+	testcmd.RequireSuccess(t, status)
+	if sub.CallCount() != 1 {
+		t.Fatalf("wanted the stale socket to be replaced and the command to run but got %d calls", sub.CallCount())
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("wanted the socket to be removed but got %v", err)
+	}
+}
+
+func TestWithControlSocketInUse(t *testing.T) {
+	path := controlSocketPath(t)
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	sub := testcmd.NewRecording("agent")
+	var stderr testcmd.Buffer
+	ctx := subcommandsutil.WithOutput(context.Background(), &testcmd.Buffer{}, &stderr)
+	status, _, _ := testcmd.Run(ctx, subcommandsutil.Cancelable(sub, subcommandsutil.WithControlSocket(path)))
+	testcmd.AssertStatus(t, status, subcommands.ExitFailure)
+	if sub.CallCount() != 0 {
+		t.Fatalf("wanted the command not to run but got %d calls", sub.CallCount())
+	}
+	if !strings.Contains(stderr.String(), "agent: control socket: ") {
+		t.Fatalf("wanted the error to be reported but got %q", stderr.String())
+	}
+}
+
+func TestWithControlSocketNotASocket(t *testing.T) {
+	path := controlSocketPath(t)
+	if err := os.WriteFile(path, []byte("data"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	sub := testcmd.NewRecording("agent")
+	var stderr testcmd.Buffer
+	ctx := subcommandsutil.WithOutput(context.Background(), &testcmd.Buffer{}, &stderr)
+	status, _, _ := testcmd.Run(ctx, subcommandsutil.Cancelable(sub, subcommandsutil.WithControlSocket(path)))
+	testcmd.AssertStatus(t, status, subcommands.ExitFailure)
+	if sub.CallCount() != 0 {
+		t.Fatalf("wanted the command not to run but got %d calls", sub.CallCount())
+	}
+	if want := "agent: control socket: listen unix " + path + ": not a socket"; !strings.Contains(stderr.String(), want) {
+		t.Fatalf("wanted the error %q but got %q", want, stderr.String())
+	}
+	if data, err := os.ReadFile(path); err != nil || string(data) != "data" {
+		t.Fatalf("wanted the file left alone but got %q (%v)", data, err)
+	}
+	if entries, err := os.ReadDir(filepath.Dir(path)); err != nil || len(entries) != 1 {
+		t.Fatalf("wanted no other file next to it but got %v (%v)", entries, err)
+	}
+}
@@ -0,0 +1,356 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/google/subcommands"
+)
+
+// DaemonEnv is the environment variable marking the process re-executed by Daemonize as the
+// daemon.
+const DaemonEnv = "SUBCOMMANDSUTIL_DAEMON"
+
+// daemonPollInterval is the interval at which the parent of a daemon checks for its readiness.
+const daemonPollInterval = 50 * time.Millisecond
+
+var (
+	// ErrDaemonNotRunning is returned by StatusDaemon and StopDaemon when the pidfile does not
+	// exist or holds no pid.
+	ErrDaemonNotRunning = errors.New("daemon is not running")
+
+	// ErrStalePidFile is returned by StatusDaemon and StopDaemon when the process named by the
+	// pidfile is no longer running.
+	ErrStalePidFile = errors.New("stale pidfile")
+)
+
+// startDaemon starts the daemon process cmd and returns its pid, and a channel closed once it
+// exited and was reaped. It is replaced by the tests.
+var startDaemon = func(cmd *exec.Cmd) (int, <-chan struct{}, error) {
+	if err := cmd.Start(); err != nil {
+		return 0, nil, err
+	}
+
+	// waited for, so that a daemon exiting early is reaped rather than left a zombie which still
+	// looks alive
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+		cmd.Wait()
+	}()
+
+	return cmd.Process.Pid, exited, nil
+}
+
+// DaemonOption is an option of the Daemonize wrapper.
+type DaemonOption interface {
+	applyDaemon(*daemon)
+}
+
+// daemonOptionFunc is a DaemonOption implemented by a function.
+type daemonOptionFunc func(*daemon)
+
+// applyDaemon implements DaemonOption.
+func (fn daemonOptionFunc) applyDaemon(c *daemon) { fn(c) }
+
+// WithPidFile sets the path of the pidfile of the daemon. It defaults to <name>.pid in
+// os.TempDir, name being the name of the command.
+func WithPidFile(path string) DaemonOption {
+	return daemonOptionFunc(func(c *daemon) {
+		c.pidFile = path
+	})
+}
+
+// WithDaemonLog sets the path of the file the output of the daemon is appended to. The output is
+// discarded by default.
+func WithDaemonLog(path string) DaemonOption {
+	return daemonOptionFunc(func(c *daemon) {
+		c.logPath = path
+	})
+}
+
+// WithReadyTimeout sets how long the parent waits for the daemon to become ready, 10 seconds by
+// default. A daemon not ready in time is terminated.
+func WithReadyTimeout(d time.Duration) DaemonOption {
+	return daemonOptionFunc(func(c *daemon) {
+		c.readyTimeout = d
+	})
+}
+
+// WithManualReady makes the daemon ready only when the command calls DaemonReady, instead of
+// before its Execute method is called.
+func WithManualReady() DaemonOption {
+	return daemonOptionFunc(func(c *daemon) {
+		c.manualReady = true
+	})
+}
+
+// daemon wraps a CancelableCommand so that it can run in the background.
+type daemon struct {
+	sub CancelableCommand
+
+	pidFile      string
+	logPath      string
+	readyTimeout time.Duration
+	manualReady  bool
+
+	daemon bool
+}
+
+// make sure daemon implements the CancelableCommand interface.
+var _ CancelableCommand = (*daemon)(nil)
+
+// Daemonize wraps sub with the -daemon flag. When set, the executable is re-executed with the same
+// arguments in a new session, its environment marked by DaemonEnv and its output appended to the
+// file set by WithDaemonLog. The parent returns subcommands.ExitSuccess once the daemon is ready,
+// or subcommands.ExitFailure if it exits or is not ready within the timeout set by
+// WithReadyTimeout.
+//
+// The daemon writes its pid into the pidfile set by WithPidFile when it becomes ready, and removes
+// it when Execute returns. SIGTERM and interrupts cancel its execution context. The daemon refuses
+// to start while the pidfile names a running process; a stale pidfile is removed with a warning.
+//
+// Dispose removes the pidfile of the daemon and forwards to the Dispose method of sub.
+func Daemonize(sub CancelableCommand, opts ...DaemonOption) CancelableCommand {
+	c := &daemon{
+		sub:          sub,
+		readyTimeout: 10 * time.Second,
+	}
+	for _, opt := range opts {
+		opt.applyDaemon(c)
+	}
+
+	return c
+}
+
+// Name forwards to the underlying c.sub Command.
+func (c *daemon) Name() string {
+	return c.sub.Name()
+}
+
+// Usage forwards to the underlying c.sub Command.
+func (c *daemon) Usage() string {
+	return c.sub.Usage()
+}
+
+// Synopsis forwards to the underlying c.sub Command.
+func (c *daemon) Synopsis() string {
+	return c.sub.Synopsis()
+}
+
+// Unwrap returns the underlying c.sub Command.
+func (c *daemon) Unwrap() subcommands.Command {
+	return c.sub
+}
+
+// SetFlags forwards to the underlying c.sub Command and registers the -daemon flag.
+func (c *daemon) SetFlags(f *flag.FlagSet) {
+	c.sub.SetFlags(f)
+	f.BoolVar(&c.daemon, "daemon", false, "run in the background")
+}
+
+// Dispose removes the pidfile of the daemon and forwards to the underlying c.sub Command.
+func (c *daemon) Dispose() error {
+	removePidFile(c.pidFilePath())
+
+	return c.sub.Dispose()
+}
+
+// Execute runs the underlying c.sub Command as the daemon when the process is marked by DaemonEnv,
+// starts the daemon when the -daemon flag is set, and forwards to c.sub otherwise.
+func (c *daemon) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	switch {
+	case os.Getenv(DaemonEnv) != "":
+		return c.runDaemon(ctx, f, args...)
+	case c.daemon:
+		return c.start(ctx)
+	default:
+		return c.sub.Execute(ctx, f, args...)
+	}
+}
+
+// pidFilePath returns the path of the pidfile.
+func (c *daemon) pidFilePath() string {
+	if c.pidFile != "" {
+		return c.pidFile
+	}
+
+	return filepath.Join(os.TempDir(), c.sub.Name()+".pid")
+}
+
+// start re-executes the process as the daemon and waits for it to become ready.
+func (c *daemon) start(ctx context.Context) subcommands.ExitStatus {
+	pidFile := c.pidFilePath()
+	switch pid, err := StatusDaemon(pidFile); {
+	case err == nil:
+		fmt.Fprintf(Stderr(ctx), "%s: already running (pid %d)\n", c.sub.Name(), pid)
+		return subcommands.ExitFailure
+	case errors.Is(err, ErrStalePidFile):
+		fmt.Fprintf(Stderr(ctx), "warning: %s: removing the stale pidfile of pid %d\n", c.sub.Name(), pid)
+		removePidFile(pidFile)
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		fmt.Fprintf(Stderr(ctx), "%s: starting daemon: %v\n", c.sub.Name(), err)
+		return subcommands.ExitFailure
+	}
+	logPath := c.logPath
+	if logPath == "" {
+		logPath = os.DevNull
+	} else if err := os.MkdirAll(filepath.Dir(logPath), 0o755); err != nil {
+		fmt.Fprintf(Stderr(ctx), "%s: opening daemon log: %v\n", c.sub.Name(), err)
+		return subcommands.ExitFailure
+	}
+	logFile, err := os.OpenFile(logPath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		fmt.Fprintf(Stderr(ctx), "%s: opening daemon log: %v\n", c.sub.Name(), err)
+		return subcommands.ExitFailure
+	}
+	defer logFile.Close()
+
+	cmd := exec.Command(exe, os.Args[1:]...)
+	cmd.Env = append(os.Environ(), DaemonEnv+"=1")
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	cmd.SysProcAttr = daemonSysProcAttr()
+	pid, exited, err := startDaemon(cmd)
+	if err != nil {
+		fmt.Fprintf(Stderr(ctx), "%s: starting daemon: %v\n", c.sub.Name(), err)
+		return subcommands.ExitFailure
+	}
+
+	clk := ClockFromContext(ctx)
+	deadline := clk.Now().Add(c.readyTimeout)
+	for {
+		if got, err := StatusDaemon(pidFile); err == nil && got == pid {
+			fmt.Fprintf(Info(ctx), "%s: started daemon (pid %d)\n", c.sub.Name(), pid)
+			return subcommands.ExitSuccess
+		}
+		select {
+		case <-exited:
+			fmt.Fprintf(Stderr(ctx), "%s: daemon (pid %d) exited before becoming ready\n", c.sub.Name(), pid)
+			return subcommands.ExitFailure
+		default:
+		}
+
+		remaining := deadline.Sub(clk.Now())
+		if remaining <= 0 {
+			_ = terminateProcess(pid)
+			fmt.Fprintf(Stderr(ctx), "%s: daemon (pid %d) was not ready after %v\n", c.sub.Name(), pid, c.readyTimeout)
+			return subcommands.ExitFailure
+		}
+		if err := sleep(ctx, clk, min(remaining, daemonPollInterval)); err != nil {
+			fmt.Fprintf(Stderr(ctx), "%s: %v\n", c.sub.Name(), err)
+			return subcommands.ExitFailure
+		}
+	}
+}
+
+// runDaemon runs the underlying c.sub Command as the daemon.
+func (c *daemon) runDaemon(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	os.Unsetenv(DaemonEnv)
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	pidFile := c.pidFilePath()
+	defer removePidFile(pidFile)
+
+	var once sync.Once
+	var readyErr error
+	ready := func() error {
+		once.Do(func() {
+			readyErr = writePidFile(pidFile)
+		})
+		return readyErr
+	}
+	if !c.manualReady {
+		if err := ready(); err != nil {
+			fmt.Fprintf(Stderr(ctx), "%s: writing pidfile: %v\n", c.sub.Name(), err)
+			return subcommands.ExitFailure
+		}
+	}
+
+	return c.sub.Execute(context.WithValue(ctx, daemonReadyKey{}, ready), f, args...)
+}
+
+// daemonReadyKey is the context key of the readiness function of the daemon.
+type daemonReadyKey struct{}
+
+// DaemonReady marks the daemon run by Daemonize with WithManualReady as ready, writing its
+// pidfile, which makes the parent return. It does nothing, and returns nil, outside a daemon or
+// when the daemon is already ready.
+func DaemonReady(ctx context.Context) error {
+	ready, ok := ctx.Value(daemonReadyKey{}).(func() error)
+	if !ok {
+		return nil
+	}
+
+	return ready()
+}
+
+// StatusDaemon returns the pid of the daemon named by pidfile. It returns ErrDaemonNotRunning if
+// pidfile does not exist or holds no pid, and ErrStalePidFile, with the pid, if the process is no
+// longer running.
+func StatusDaemon(pidfile string) (int, error) {
+	data, err := os.ReadFile(pidfile)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, ErrDaemonNotRunning
+	}
+	if err != nil {
+		return 0, err
+	}
+	pid := parsePid(data)
+	if pid == 0 {
+		return 0, ErrDaemonNotRunning
+	}
+	if !processAlive(pid) {
+		return pid, fmt.Errorf("%w: pid %d is not running", ErrStalePidFile, pid)
+	}
+
+	return pid, nil
+}
+
+// StopDaemon asks the daemon named by pidfile to terminate, with SIGTERM on unix. It does not wait
+// for the daemon to exit. A stale pidfile is removed, and reported by ErrStalePidFile.
+func StopDaemon(pidfile string) error {
+	pid, err := StatusDaemon(pidfile)
+	if errors.Is(err, ErrStalePidFile) {
+		removePidFile(pidfile)
+	}
+	if err != nil {
+		return err
+	}
+
+	return terminateProcess(pid)
+}
+
+// writePidFile atomically replaces pidfile with one holding the pid of the process.
+func writePidFile(pidfile string) error {
+	return writeFileAtomic(pidfile, []byte(strconv.Itoa(os.Getpid())+"\n"), 0o644)
+}
+
+// removePidFile removes pidfile if it holds the pid of the process, or a stale pid.
+func removePidFile(pidfile string) {
+	data, err := os.ReadFile(pidfile)
+	if err != nil {
+		return
+	}
+	if pid := parsePid(data); pid == os.Getpid() || pid == 0 || !processAlive(pid) {
+		os.Remove(pidfile)
+	}
+}
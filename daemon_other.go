@@ -0,0 +1,26 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+//go:build !unix && !windows
+
+package subcommandsutil
+
+import (
+	"os"
+	"syscall"
+)
+
+// daemonSysProcAttr returns no attributes, as a daemon cannot be detached on this platform.
+func daemonSysProcAttr() *syscall.SysProcAttr {
+	return nil
+}
+
+// terminateProcess kills the process pid.
+func terminateProcess(pid int) error {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+
+	return p.Kill()
+}
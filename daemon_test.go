@@ -0,0 +1,305 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+// startSleep starts a process sleeping for a minute and returns it.
+func startSleep(t *testing.T) *exec.Cmd {
+	t.Helper()
+
+	sleep, err := exec.LookPath("sleep")
+	if err != nil {
+		t.Skip(err)
+	}
+	cmd := exec.Command(sleep, "60")
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+
+	return cmd
+}
+
+// writeFile writes content into the file at path.
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDaemonizeForeground(t *testing.T) {
+	restore := subcommandsutil.SetStartDaemon(func(cmd *exec.Cmd) (int, <-chan struct{}, error) {
+		t.Fatal("wanted no daemon to be started")
+		return 0, nil, nil
+	})
+	defer restore()
+
+	sub := testcmd.NewRecording("agent")
+	status, _, err := testcmd.Run(context.Background(), subcommandsutil.Daemonize(sub), "arg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	testcmd.RequireSuccess(t, status)
+	if sub.CallCount() != 1 {
+		t.Fatalf("wanted sub to run in the foreground once but got %d calls", sub.CallCount())
+	}
+}
+
+func TestDaemonizeStart(t *testing.T) {
+	tests := map[string]struct {
+		pidFile    func(t *testing.T) string // the content of the pidfile before the start, if any
+		start      func(t *testing.T, pidFile string) (int, <-chan struct{}, error)
+		opts       []subcommandsutil.DaemonOption
+		wantStatus subcommands.ExitStatus
+		wantStdout string
+		wantStderr string
+		wantStart  bool
+	}{
+		"when the daemon becomes ready": {
+			start: func(t *testing.T, pidFile string) (int, <-chan struct{}, error) {
+				writeFile(t, pidFile, fmt.Sprintf("%d\n", os.Getpid()))
+				return os.Getpid(), nil, nil
+			},
+			wantStatus: subcommands.ExitSuccess,
+			wantStdout: fmt.Sprintf("agent: started daemon (pid %d)\n", os.Getpid()),
+			wantStart:  true,
+		},
+		"when the pidfile is stale": {
+			pidFile: func(t *testing.T) string { return fmt.Sprintf("%d\n", exitedPid(t)) },
+			start: func(t *testing.T, pidFile string) (int, <-chan struct{}, error) {
+				writeFile(t, pidFile, fmt.Sprintf("%d\n", os.Getpid()))
+				return os.Getpid(), nil, nil
+			},
+			wantStatus: subcommands.ExitSuccess,
+			wantStdout: fmt.Sprintf("agent: started daemon (pid %d)\n", os.Getpid()),
+			wantStderr: "warning: agent: removing the stale pidfile of pid ",
+			wantStart:  true,
+		},
+		"when the daemon is already running": {
+			pidFile:    func(t *testing.T) string { return fmt.Sprintf("%d\n", os.Getpid()) },
+			wantStatus: subcommands.ExitFailure,
+			wantStderr: fmt.Sprintf("agent: already running (pid %d)\n", os.Getpid()),
+		},
+		"when the daemon fails to start": {
+			start: func(t *testing.T, pidFile string) (int, <-chan struct{}, error) {
+				return 0, nil, errors.New("exec format error")
+			},
+			wantStatus: subcommands.ExitFailure,
+			wantStderr: "agent: starting daemon: exec format error\n",
+			wantStart:  true,
+		},
+		"when the daemon exits before becoming ready": {
+			start: func(t *testing.T, pidFile string) (int, <-chan struct{}, error) {
+				return subcommandsutil.StartDaemon(exec.Command(os.Args[0], "-test.run=^$"))
+			},
+			// well within the ready timeout, which an exit left unnoticed waits for
+			opts:       []subcommandsutil.DaemonOption{subcommandsutil.WithReadyTimeout(time.Minute)},
+			wantStatus: subcommands.ExitFailure,
+			wantStderr: ") exited before becoming ready\n",
+			wantStart:  true,
+		},
+		"when the daemon is not ready in time": {
+			start: func(t *testing.T, pidFile string) (int, <-chan struct{}, error) {
+				return startSleep(t).Process.Pid, nil, nil
+			},
+			opts:       []subcommandsutil.DaemonOption{subcommandsutil.WithReadyTimeout(100 * time.Millisecond)},
+			wantStatus: subcommands.ExitFailure,
+			wantStderr: ") was not ready after 100ms\n",
+			wantStart:  true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			pidFile := filepath.Join(dir, "agent.pid")
+			logPath := filepath.Join(dir, "logs", "agent.log")
+			if tt.pidFile != nil {
+				writeFile(t, pidFile, tt.pidFile(t))
+			}
+
+			started := false
+			restore := subcommandsutil.SetStartDaemon(func(cmd *exec.Cmd) (int, <-chan struct{}, error) {
+				started = true
+				if !slices.Contains(cmd.Env, subcommandsutil.DaemonEnv+"=1") {
+					t.Errorf("wanted the environment of the daemon to hold %s=1 but got %q", subcommandsutil.DaemonEnv, cmd.Env)
+				}
+				if !slices.Equal(cmd.Args[1:], os.Args[1:]) {
+					t.Errorf("wanted the daemon to be run with the arguments %q but got %q", os.Args[1:], cmd.Args[1:])
+				}
+				if f, ok := cmd.Stdout.(*os.File); !ok || f.Name() != logPath || cmd.Stderr != cmd.Stdout {
+					t.Errorf("wanted the output of the daemon to go to %s but got %v and %v", logPath, cmd.Stdout, cmd.Stderr)
+				}
+				return tt.start(t, pidFile)
+			})
+			defer restore()
+
+			opts := append([]subcommandsutil.DaemonOption{subcommandsutil.WithPidFile(pidFile), subcommandsutil.WithDaemonLog(logPath)}, tt.opts...)
+			sub := testcmd.NewRecording("agent")
+			var stdout, stderr testcmd.Buffer
+			ctx := subcommandsutil.WithOutput(context.Background(), &stdout, &stderr)
+			status, _, err := testcmd.Run(ctx, subcommandsutil.Daemonize(sub, opts...), "-daemon")
+			if err != nil {
+				t.Fatal(err)
+			}
+			testcmd.AssertStatus(t, status, tt.wantStatus)
+			if started != tt.wantStart {
+				t.Fatalf("wanted the daemon started to be %v but got %v", tt.wantStart, started)
+			}
+			if sub.CallCount() != 0 {
+				t.Fatalf("wanted sub not to run in the parent but got %d calls", sub.CallCount())
+			}
+			if stdout.String() != tt.wantStdout {
+				t.Fatalf("wanted stdout %q but got %q", tt.wantStdout, stdout.String())
+			}
+			if !strings.Contains(stderr.String(), tt.wantStderr) || (tt.wantStderr == "" && stderr.String() != "") {
+				t.Fatalf("wanted stderr containing %q but got %q", tt.wantStderr, stderr.String())
+			}
+		})
+	}
+}
+
+func TestDaemonizeChild(t *testing.T) {
+	tests := map[string]struct {
+		opts []subcommandsutil.DaemonOption
+		// wantReadyBefore is whether the pidfile is written before the command calls DaemonReady.
+		wantReadyBefore bool
+	}{
+		"when ready automatically": {
+			wantReadyBefore: true,
+		},
+		"when ready manually": {
+			opts: []subcommandsutil.DaemonOption{subcommandsutil.WithManualReady()},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv(subcommandsutil.DaemonEnv, "1")
+
+			pidFile := filepath.Join(t.TempDir(), "run", "agent.pid")
+			sub := testcmd.NewRecording("agent", testcmd.WithExecute(func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+				if env := os.Getenv(subcommandsutil.DaemonEnv); env != "" {
+					t.Errorf("wanted %s to be unset in the daemon but got %q", subcommandsutil.DaemonEnv, env)
+				}
+				if _, err := subcommandsutil.StatusDaemon(pidFile); (err == nil) != tt.wantReadyBefore {
+					t.Errorf("wanted the daemon ready before DaemonReady to be %v but got the error %v", tt.wantReadyBefore, err)
+				}
+				if err := subcommandsutil.DaemonReady(ctx); err != nil {
+					t.Error(err)
+				}
+				if pid, err := subcommandsutil.StatusDaemon(pidFile); err != nil || pid != os.Getpid() {
+					t.Errorf("wanted the pidfile to hold pid %d but got %d and the error %v", os.Getpid(), pid, err)
+				}
+				return subcommands.ExitSuccess
+			}))
+
+			status, _, err := testcmd.Run(context.Background(), subcommandsutil.Daemonize(sub, append(tt.opts, subcommandsutil.WithPidFile(pidFile))...), "-daemon")
+			if err != nil {
+				t.Fatal(err)
+			}
+			testcmd.RequireSuccess(t, status)
+			if sub.CallCount() != 1 {
+				t.Fatalf("wanted sub to run in the daemon once but got %d calls", sub.CallCount())
+			}
+			if _, err := os.Stat(pidFile); !errors.Is(err, os.ErrNotExist) {
+				t.Fatalf("wanted the pidfile to be removed but got %v", err)
+			}
+		})
+	}
+}
+
+func TestStatusDaemon(t *testing.T) {
+	tests := map[string]struct {
+		content string // the content of the pidfile; no pidfile if empty
+		wantErr error
+	}{
+		"when the pidfile does not exist": {
+			wantErr: subcommandsutil.ErrDaemonNotRunning,
+		},
+		"when the pidfile holds no pid": {
+			content: "\n",
+			wantErr: subcommandsutil.ErrDaemonNotRunning,
+		},
+		"when the daemon is running": {
+			content: fmt.Sprintf("%d\n", os.Getpid()),
+		},
+		"when the pidfile is stale": {
+			content: "stale",
+			wantErr: subcommandsutil.ErrStalePidFile,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			pidFile := filepath.Join(t.TempDir(), "agent.pid")
+			wantPid := 0
+			switch tt.content {
+			case "":
+			case "stale":
+				wantPid = exitedPid(t)
+				writeFile(t, pidFile, fmt.Sprintf("%d\n", wantPid))
+			default:
+				wantPid, _ = strconv.Atoi(strings.TrimSpace(tt.content))
+				writeFile(t, pidFile, tt.content)
+			}
+
+			pid, err := subcommandsutil.StatusDaemon(pidFile)
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Fatalf("wanted the error %v but got %v", tt.wantErr, err)
+			}
+			if pid != wantPid {
+				t.Fatalf("wanted pid %d but got %d", wantPid, pid)
+			}
+		})
+	}
+}
+
+func TestStopDaemon(t *testing.T) {
+	t.Run("when the daemon is running", func(t *testing.T) {
+		proc := startSleep(t)
+		pidFile := filepath.Join(t.TempDir(), "agent.pid")
+		writeFile(t, pidFile, fmt.Sprintf("%d\n", proc.Process.Pid))
+
+		if err := subcommandsutil.StopDaemon(pidFile); err != nil {
+			t.Fatal(err)
+		}
+		if err := proc.Wait(); err == nil {
+			t.Fatal("wanted the daemon to be terminated but it exited successfully")
+		}
+	})
+
+	t.Run("when the pidfile is stale", func(t *testing.T) {
+		pidFile := filepath.Join(t.TempDir(), "agent.pid")
+		writeFile(t, pidFile, fmt.Sprintf("%d\n", exitedPid(t)))
+
+		if err := subcommandsutil.StopDaemon(pidFile); !errors.Is(err, subcommandsutil.ErrStalePidFile) {
+			t.Fatalf("wanted the error %v but got %v", subcommandsutil.ErrStalePidFile, err)
+		}
+		if _, err := os.Stat(pidFile); !errors.Is(err, os.ErrNotExist) {
+			t.Fatalf("wanted the stale pidfile to be removed but got %v", err)
+		}
+	})
+}
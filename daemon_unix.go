@@ -0,0 +1,18 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+//go:build unix
+
+package subcommandsutil
+
+import "syscall"
+
+// daemonSysProcAttr returns the attributes detaching a daemon from the session of its parent.
+func daemonSysProcAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{Setsid: true}
+}
+
+// terminateProcess asks the process pid to terminate with SIGTERM.
+func terminateProcess(pid int) error {
+	return syscall.Kill(pid, syscall.SIGTERM)
+}
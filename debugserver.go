@@ -0,0 +1,182 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"sync"
+	"time"
+
+	"github.com/google/subcommands"
+)
+
+// DebugServerOption is an option of the DebugServer wrapper.
+type DebugServerOption interface {
+	applyDebugServer(*debugServer)
+}
+
+// debugServerOptionFunc is a DebugServerOption implemented by a function.
+type debugServerOptionFunc func(*debugServer)
+
+// applyDebugServer implements DebugServerOption.
+func (fn debugServerOptionFunc) applyDebugServer(c *debugServer) { fn(c) }
+
+// applyDebugServer implements DebugServerOption.
+func (o LoggerOption) applyDebugServer(c *debugServer) {
+	c.logger = o.logger
+}
+
+// WithStrictDebugAddr makes DebugServer fail the command when the debug server cannot listen on
+// the address of the -debug-addr flag, instead of logging it and executing the command anyway.
+func WithStrictDebugAddr() DebugServerOption {
+	return debugServerOptionFunc(func(c *debugServer) {
+		c.strict = true
+	})
+}
+
+// debugServer wraps a subcommands.Command so that a debug HTTP server runs during its executions.
+type debugServer struct {
+	sub    subcommands.Command
+	logger Logger
+	strict bool
+
+	addr string
+}
+
+// make sure debugServer implements the CancelableCommand interface.
+var _ CancelableCommand = (*debugServer)(nil)
+
+// DebugServer wraps sub with the -debug-addr flag. When set, an HTTP server serving net/http/pprof
+// under /debug/pprof/ and expvar under /debug/vars listens on its address for the duration of
+// Execute, to diagnose a hung command in the field. The bound address, useful with a port of 0, is
+// logged to the standard logger unless WithLogger is given.
+//
+// The server is shut down when Execute returns, or when a Cancelable wrapper stops waiting for sub.
+// An address the server cannot listen on is logged, and sub executed anyway, unless
+// WithStrictDebugAddr is given. Dispose forwards to the Dispose method of sub, if any.
+func DebugServer(sub subcommands.Command, opts ...DebugServerOption) CancelableCommand {
+	c := &debugServer{
+		sub:    sub,
+		logger: stdLogger{},
+	}
+	for _, opt := range opts {
+		opt.applyDebugServer(c)
+	}
+
+	return c
+}
+
+// Name forwards to the underlying c.sub Command.
+func (c *debugServer) Name() string {
+	return c.sub.Name()
+}
+
+// Usage forwards to the underlying c.sub Command.
+func (c *debugServer) Usage() string {
+	return c.sub.Usage()
+}
+
+// Synopsis forwards to the underlying c.sub Command.
+func (c *debugServer) Synopsis() string {
+	return c.sub.Synopsis()
+}
+
+// Unwrap returns the underlying c.sub Command.
+func (c *debugServer) Unwrap() subcommands.Command {
+	return c.sub
+}
+
+// SetFlags forwards to the underlying c.sub Command and registers the -debug-addr flag.
+func (c *debugServer) SetFlags(f *flag.FlagSet) {
+	c.sub.SetFlags(f)
+	f.StringVar(&c.addr, "debug-addr", "", "serve pprof and expvar over HTTP on `address` during the execution")
+}
+
+// Dispose forwards to the underlying c.sub Command if it is a CancelableCommand.
+func (c *debugServer) Dispose() error {
+	if sub, ok := c.sub.(CancelableCommand); ok {
+		return sub.Dispose()
+	}
+
+	return nil
+}
+
+// Execute serves the debug server on the address of the -debug-addr flag while forwarding to the
+// underlying c.sub Command.
+func (c *debugServer) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	if c.addr == "" {
+		return c.sub.Execute(ctx, f, args...)
+	}
+
+	logger := contextLogger(ctx, c.logger)
+	bound, shutdown, err := serveDebug(ctx, c.addr, logger)
+	switch {
+	case err != nil && c.strict:
+		fmt.Fprintf(Stderr(ctx), "%s: -debug-addr: %v\n", c.sub.Name(), err)
+		return subcommands.ExitFailure
+	case err != nil:
+		logger.Printf("%s: -debug-addr: %v; continuing without the debug server", c.sub.Name(), err)
+	default:
+		logger.Printf("%s: debug server listening on http://%s/debug/", c.sub.Name(), bound)
+		defer shutdown()
+		defer onCancel(ctx, shutdown)()
+	}
+
+	return c.sub.Execute(ctx, f, args...)
+}
+
+// serveDebug serves the debug handlers on addr, logging the errors of the server to l, and returns
+// the bound address and the shutdown function of serveHTTP.
+func serveDebug(ctx context.Context, addr string, l Logger) (bound net.Addr, shutdown func(), err error) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+
+	return serveHTTP(ctx, addr, mux, l, "debug server")
+}
+
+// serveHTTP serves handler on addr, logging the errors of the server, named name, to l, and returns
+// the bound address. The returned shutdown function shuts the server down and waits for it, once.
+func serveHTTP(ctx context.Context, addr string, handler http.Handler, l Logger, name string) (bound net.Addr, shutdown func(), err error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	srv := &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			l.Printf("%s: %v", name, err)
+		}
+	}()
+
+	var once sync.Once
+	return ln.Addr(), func() {
+		once.Do(func() {
+			sctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+			defer cancel()
+			if srv.Shutdown(sctx) != nil {
+				_ = srv.Close()
+			}
+			<-done
+		})
+	}, nil
+}
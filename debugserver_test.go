@@ -0,0 +1,123 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"context"
+	"flag"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+// debugURL returns the URL of the debug server logged to logs.
+func debugURL(t *testing.T, logs *testcmd.LogRecorder) string {
+	t.Helper()
+
+	const marker = "debug server listening on "
+	for _, line := range logs.Lines() {
+		if i := strings.Index(line, marker); i >= 0 {
+			return line[i+len(marker):]
+		}
+	}
+	t.Fatalf("wanted the address of the debug server logged but got %q", logs.Lines())
+	return ""
+}
+
+func TestDebugServer(t *testing.T) {
+	defer testcmd.VerifyNoLeaks(t)
+
+	client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+	var logs testcmd.LogRecorder
+	var url, index, vars string
+	sub := testcmd.NewRecording("serve", testcmd.WithExecute(func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+		url = debugURL(t, &logs)
+		index, vars = get(t, client, url+"pprof/"), get(t, client, url+"vars")
+		return subcommands.ExitSuccess
+	}))
+
+	status, _, _ := testcmd.Run(context.Background(), subcommandsutil.DebugServer(sub, subcommandsutil.WithLogger(&logs)), "-debug-addr", "127.0.0.1:0")
+	testcmd.RequireSuccess(t, status)
+	if !strings.Contains(index, "goroutine") {
+		t.Fatalf("wanted the pprof index but got %q", index)
+	}
+	if !strings.Contains(vars, `"cmdline"`) {
+		t.Fatalf("wanted the expvar variables but got %q", vars)
+	}
+	if resp, err := client.Get(url + "pprof/"); err == nil {
+		resp.Body.Close()
+		t.Fatalf("wanted the debug server shut down but got %s", resp.Status)
+	}
+}
+
+// get returns the body of the response to a GET request of url.
+func get(t *testing.T, client *http.Client, url string) string {
+	t.Helper()
+
+	resp, err := client.Get(url)
+	if err != nil {
+		t.Fatalf("wanted no error but got %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("wanted no error but got %v", err)
+	}
+
+	return string(body)
+}
+
+func TestDebugServerBindFailure(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	tests := map[string]struct {
+		opts       []subcommandsutil.DebugServerOption
+		wantStatus subcommands.ExitStatus
+		wantCalls  int
+		wantLog    string
+		wantStderr string
+	}{
+		"when the address is in use": {
+			wantStatus: subcommands.ExitSuccess,
+			wantCalls:  1,
+			wantLog:    "serve: -debug-addr: listen tcp " + ln.Addr().String(),
+		},
+		"when the address is in use with WithStrictDebugAddr": {
+			opts:       []subcommandsutil.DebugServerOption{subcommandsutil.WithStrictDebugAddr()},
+			wantStatus: subcommands.ExitFailure,
+			wantStderr: "serve: -debug-addr: listen tcp " + ln.Addr().String(),
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var logs testcmd.LogRecorder
+			var stderr testcmd.Buffer
+			sub := testcmd.NewRecording("serve")
+			ctx := subcommandsutil.WithOutput(context.Background(), &testcmd.Buffer{}, &stderr)
+
+			status, _, _ := testcmd.Run(ctx, subcommandsutil.DebugServer(sub, append(tt.opts, subcommandsutil.WithLogger(&logs))...), "-debug-addr", ln.Addr().String())
+			testcmd.AssertStatus(t, status, tt.wantStatus)
+			if sub.CallCount() != tt.wantCalls {
+				t.Fatalf("wanted %d calls but got %d", tt.wantCalls, sub.CallCount())
+			}
+			if got := strings.Join(logs.Lines(), "\n"); !strings.HasPrefix(got, tt.wantLog) || (tt.wantLog == "") != (got == "") {
+				t.Fatalf("wanted the log %q but got %q", tt.wantLog, got)
+			}
+			if got := stderr.String(); !strings.HasPrefix(got, tt.wantStderr) || (tt.wantStderr == "") != (got == "") {
+				t.Fatalf("wanted the stderr %q but got %q", tt.wantStderr, got)
+			}
+		})
+	}
+}
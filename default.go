@@ -0,0 +1,37 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"context"
+	"flag"
+	"strings"
+
+	"github.com/google/subcommands"
+)
+
+// ExecuteWithDefault is like cdr.Execute, but executes the command named name when no subcommand
+// is given, instead of printing the command listing. topFlags are the parsed top-level flags of
+// cdr, usually flag.CommandLine.
+//
+// Arguments after "--" which start with "-" are parsed as the flags of the default command, so
+// "mytool -- -short" runs "mytool status -short" if status is the default. "-h" and the help
+// command still show the help.
+func ExecuteWithDefault(ctx context.Context, cdr *subcommands.Commander, topFlags *flag.FlagSet, name string, args ...interface{}) subcommands.ExitStatus {
+	if err := defaultCommand(topFlags, name); err != nil {
+		return subcommands.ExitUsageError
+	}
+
+	return cdr.Execute(ctx, args...)
+}
+
+// defaultCommand makes the parsed topFlags name the command name when they name no subcommand.
+func defaultCommand(topFlags *flag.FlagSet, name string) error {
+	if topFlags.NArg() > 0 && !strings.HasPrefix(topFlags.Arg(0), "-") {
+		return nil
+	}
+
+	// the flags are already parsed, so only the arguments change
+	return topFlags.Parse(append([]string{"--", name}, topFlags.Args()...))
+}
@@ -0,0 +1,95 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"context"
+	"flag"
+	"strings"
+	"testing"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+func TestExecuteWithDefault(t *testing.T) {
+	tests := map[string]struct {
+		argv          []string
+		wantStatus    subcommands.ExitStatus
+		wantStatusRun bool
+		wantArgs      []string
+		wantShort     bool
+		wantStdout    string
+	}{
+		"when no subcommand is given": {
+			argv:          []string{},
+			wantStatusRun: true,
+		},
+		"when only top-level flags are given": {
+			argv:          []string{"-verbose"},
+			wantStatusRun: true,
+		},
+		"when flags of the default command are given": {
+			argv:          []string{"--", "-short", "dir"},
+			wantStatusRun: true,
+			wantShort:     true,
+			wantArgs:      []string{"dir"},
+		},
+		"when a subcommand is given": {
+			argv:          []string{"push"},
+			wantStatusRun: false,
+		},
+		"when help is asked": {
+			argv:          []string{"help"},
+			wantStatusRun: false,
+			wantStdout:    "Subcommands:",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var short bool
+			status := testcmd.NewRecording("status", testcmd.WithFlags(func(f *flag.FlagSet) {
+				f.BoolVar(&short, "short", false, "short output")
+			}))
+			h := testcmd.NewHarness(t)
+			h.Flags.Bool("verbose", false, "verbose output")
+			h.Register(status, "")
+			h.Register(testcmd.NewRecording("push"), "")
+
+			if err := h.Flags.Parse(tt.argv); err != nil {
+				t.Fatal(err)
+			}
+			ctx := subcommandsutil.WithOutput(context.Background(), h.Stdout, h.Stderr)
+			testcmd.AssertStatus(t, subcommandsutil.ExecuteWithDefault(ctx, h.Commander, h.Flags, "status"), tt.wantStatus)
+
+			if status.DidFinish() != tt.wantStatusRun {
+				t.Fatalf("wanted the default command to run: %v", tt.wantStatusRun)
+			}
+			if short != tt.wantShort {
+				t.Fatalf("wanted -short to be %v", tt.wantShort)
+			}
+			if call, ok := status.LastCall(); ok && strings.Join(call.Args, " ") != strings.Join(tt.wantArgs, " ") {
+				t.Fatalf("wanted args %q but got %q", tt.wantArgs, call.Args)
+			}
+			if !strings.Contains(h.Stdout.String(), tt.wantStdout) {
+				t.Fatalf("wanted stdout to contain %q but got %q", tt.wantStdout, h.Stdout.String())
+			}
+		})
+	}
+}
+
+func TestExecuteWithDefaultHelpFlag(t *testing.T) {
+	h := testcmd.NewHarness(t)
+	status := testcmd.NewRecording("status")
+	h.Register(status, "")
+
+	if err := h.Flags.Parse([]string{"-h"}); err != flag.ErrHelp {
+		t.Fatalf("wanted %v but got %v", flag.ErrHelp, err)
+	}
+	if !strings.Contains(h.Stderr.String(), "Subcommands:") || status.DidFinish() {
+		t.Fatalf("wanted -h to show the help without running the default, got %q", h.Stderr.String())
+	}
+}
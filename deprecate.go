@@ -0,0 +1,188 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/google/subcommands"
+)
+
+// DeprecateFlag registers old as a deprecated alias of the flag named replacement in f. Setting old
+// forwards to the Value of replacement and prints a warning with the optional message to f.Output()
+// the first time old is used in f. The deprecated flag is hidden from PrintDefaults.
+//
+// DeprecateFlag is usually called from SetFlags, after replacement is defined. It panics if f does
+// not define replacement.
+func DeprecateFlag(f *flag.FlagSet, old, replacement, message string) {
+	target := mustLookup(f, replacement)
+
+	v := &deprecatedValue{
+		forwardValue: forwardValue{target.Value},
+		warn: func() {
+			fmt.Fprintf(f.Output(), "warning: flag -%s is deprecated, use -%s instead", old, replacement)
+			if message != "" {
+				fmt.Fprintf(f.Output(), ": %s", message)
+			}
+			fmt.Fprintln(f.Output())
+		},
+	}
+	f.Var(v, old, fmt.Sprintf("deprecated: use -%s instead", replacement))
+
+	updateFlagMeta(f, func(m *flagMeta) {
+		m.hidden[old] = true
+		m.deprecated[old] = replacement
+	})
+}
+
+// forwardValue is a flag.Value sharing the value of another flag.
+type forwardValue struct {
+	flag.Value
+}
+
+// Get implements flag.Getter.
+func (v forwardValue) Get() interface{} {
+	if g, ok := v.Value.(flag.Getter); ok {
+		return g.Get()
+	}
+
+	return v.Value.String()
+}
+
+// IsBoolFlag lets the forwarded flag be set without an explicit value if the shared flag allows it.
+func (v forwardValue) IsBoolFlag() bool {
+	b, ok := v.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+// String implements flag.Value. It handles the zero value used by flag.PrintDefaults.
+func (v forwardValue) String() string {
+	if v.Value == nil {
+		return ""
+	}
+
+	return v.Value.String()
+}
+
+// deprecatedValue is a forwardValue which warns on first use.
+type deprecatedValue struct {
+	forwardValue
+	once sync.Once
+	warn func()
+}
+
+// Set implements flag.Value.
+func (v *deprecatedValue) Set(s string) error {
+	v.once.Do(v.warn)
+
+	return v.forwardValue.Set(s)
+}
+
+// StrictDeprecationsEnv is the environment variable which, when set to a true value such as "1",
+// makes commands wrapped by Deprecated fail instead of running.
+const StrictDeprecationsEnv = "STRICT_DEPRECATIONS"
+
+// DeprecatedOption is an option of the Deprecated wrapper.
+type DeprecatedOption interface {
+	applyDeprecated(*deprecated)
+}
+
+// deprecatedOptionFunc is a DeprecatedOption implemented by a function.
+type deprecatedOptionFunc func(*deprecated)
+
+// applyDeprecated implements DeprecatedOption.
+func (fn deprecatedOptionFunc) applyDeprecated(c *deprecated) { fn(c) }
+
+// WithDeprecatedHidden makes the Deprecated wrapper report itself as hidden, so that it is omitted
+// from the command listing.
+func WithDeprecatedHidden() DeprecatedOption {
+	return deprecatedOptionFunc(func(c *deprecated) {
+		c.hidden = true
+	})
+}
+
+// WithStrictStatus sets the ExitStatus the Deprecated wrapper returns in strict mode. The default
+// is subcommands.ExitFailure.
+func WithStrictStatus(status subcommands.ExitStatus) DeprecatedOption {
+	return deprecatedOptionFunc(func(c *deprecated) {
+		c.strictStatus = status
+	})
+}
+
+// deprecated wraps a subcommands.Command so that its executions warn about its deprecation.
+type deprecated struct {
+	sub          subcommands.Command
+	message      string
+	hidden       bool
+	strictStatus subcommands.ExitStatus
+}
+
+// make sure deprecated implements the subcommands.Command interface.
+var _ subcommands.Command = (*deprecated)(nil)
+
+// Deprecated wraps sub so that each execution first prints "NAME: deprecated: MESSAGE" to the
+// Stderr of the execution context, such as "deploy: deprecated: use 'release' instead".
+//
+// When StrictDeprecationsEnv is set to a true value, the warning is printed and sub is not
+// executed; the strict status is returned instead.
+func Deprecated(sub subcommands.Command, message string, opts ...DeprecatedOption) subcommands.Command {
+	c := &deprecated{
+		sub:          sub,
+		message:      message,
+		strictStatus: subcommands.ExitFailure,
+	}
+	for _, opt := range opts {
+		opt.applyDeprecated(c)
+	}
+
+	return c
+}
+
+// Name forwards to the underlying c.sub Command.
+func (c *deprecated) Name() string {
+	return c.sub.Name()
+}
+
+// Usage forwards to the underlying c.sub Command.
+func (c *deprecated) Usage() string {
+	return c.sub.Usage()
+}
+
+// Synopsis forwards to the underlying c.sub Command.
+func (c *deprecated) Synopsis() string {
+	return c.sub.Synopsis()
+}
+
+// Hidden reports whether the command was wrapped with WithDeprecatedHidden, or the underlying c.sub
+// Command is hidden.
+func (c *deprecated) Hidden() bool {
+	return c.hidden || IsHiddenCommand(c.sub)
+}
+
+// Unwrap returns the underlying c.sub Command.
+func (c *deprecated) Unwrap() subcommands.Command {
+	return c.sub
+}
+
+// SetFlags forwards to the underlying c.sub Command.
+func (c *deprecated) SetFlags(f *flag.FlagSet) {
+	c.sub.SetFlags(f)
+}
+
+// Execute prints the deprecation warning and forwards to the underlying c.sub Command, unless
+// strict deprecations are enabled.
+func (c *deprecated) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	fmt.Fprintf(Stderr(ctx), "%s: deprecated: %s\n", c.sub.Name(), c.message)
+
+	if strict, _ := strconv.ParseBool(os.Getenv(StrictDeprecationsEnv)); strict {
+		return c.strictStatus
+	}
+
+	return c.sub.Execute(ctx, f, args...)
+}
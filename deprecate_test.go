@@ -0,0 +1,141 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"strings"
+	"testing"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+func TestDeprecateFlag(t *testing.T) {
+	tests := map[string]struct {
+		args      []string
+		wantColor string
+		wantWarns int
+	}{
+		"when the new flag is used": {
+			args:      []string{"-color", "never"},
+			wantColor: "never",
+			wantWarns: 0,
+		},
+		"when the deprecated flag is used": {
+			args:      []string{"-colour", "always"},
+			wantColor: "always",
+			wantWarns: 1,
+		},
+		"when the deprecated flag repeats": {
+			args:      []string{"-colour", "always", "-colour", "never"},
+			wantColor: "never",
+			wantWarns: 1,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var out bytes.Buffer
+			f := flag.NewFlagSet("test", flag.ContinueOnError)
+			f.SetOutput(&out)
+			color := f.String("color", "auto", "colorize the output")
+			subcommandsutil.DeprecateFlag(f, "colour", "color", "-colour will be removed in v2")
+
+			if err := f.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+			if *color != tt.wantColor {
+				t.Fatalf("wanted color to be %q but got %q", tt.wantColor, *color)
+			}
+			if got := strings.Count(out.String(), "warning: flag -colour is deprecated, use -color instead"); got != tt.wantWarns {
+				t.Fatalf("wanted %d warnings but got %d: %q", tt.wantWarns, got, out.String())
+			}
+		})
+	}
+}
+
+func TestDeprecateFlagBool(t *testing.T) {
+	f := flag.NewFlagSet("test", flag.ContinueOnError)
+	f.SetOutput(new(bytes.Buffer))
+	quiet := f.Bool("quiet", false, "suppress output")
+	subcommandsutil.DeprecateFlag(f, "silent", "quiet", "")
+
+	if err := f.Parse([]string{"-silent"}); err != nil {
+		t.Fatal(err)
+	}
+	if !*quiet {
+		t.Fatal("wanted -silent to set -quiet")
+	}
+
+	var buf bytes.Buffer
+	f.SetOutput(&buf)
+	subcommandsutil.PrintDefaults(f)
+	if strings.Contains(buf.String(), "-silent") {
+		t.Fatalf("wanted deprecated flag to be hidden but got %q", buf.String())
+	}
+}
+
+func TestDeprecated(t *testing.T) {
+	tests := map[string]struct {
+		strict     string
+		opts       []subcommandsutil.DeprecatedOption
+		wantStatus subcommands.ExitStatus
+		wantRun    bool
+	}{
+		"when not strict": {
+			wantStatus: subcommands.ExitSuccess,
+			wantRun:    true,
+		},
+		"when strict is disabled": {
+			strict:     "false",
+			wantStatus: subcommands.ExitSuccess,
+			wantRun:    true,
+		},
+		"when strict": {
+			strict:     "1",
+			wantStatus: subcommands.ExitFailure,
+			wantRun:    false,
+		},
+		"when strict with a custom status": {
+			strict:     "true",
+			opts:       []subcommandsutil.DeprecatedOption{subcommandsutil.WithStrictStatus(subcommands.ExitUsageError)},
+			wantStatus: subcommands.ExitUsageError,
+			wantRun:    false,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv(subcommandsutil.StrictDeprecationsEnv, tt.strict)
+
+			deploy := testcmd.NewRecording("deploy")
+			h := testcmd.NewHarness(t)
+			h.Register(subcommandsutil.Deprecated(deploy, "use 'release' instead", tt.opts...), "")
+
+			testcmd.AssertStatus(t, h.Execute(context.Background(), "deploy"), tt.wantStatus)
+			if deploy.DidFinish() != tt.wantRun {
+				t.Fatalf("wanted the command to run: %v", tt.wantRun)
+			}
+			const warning = "deploy: deprecated: use 'release' instead\n"
+			if got := h.Stderr.String(); got != warning {
+				t.Fatalf("wanted the warning %q exactly once but got %q", warning, got)
+			}
+		})
+	}
+}
+
+func TestDeprecatedHidden(t *testing.T) {
+	if subcommandsutil.IsHiddenCommand(subcommandsutil.Deprecated(testcmd.NewRecording("deploy"), "gone")) {
+		t.Fatal("wanted the deprecated command to be listed by default")
+	}
+	if !subcommandsutil.IsHiddenCommand(subcommandsutil.Deprecated(testcmd.NewRecording("deploy"), "gone", subcommandsutil.WithDeprecatedHidden())) {
+		t.Fatal("wanted the deprecated command to be hidden")
+	}
+	if !subcommandsutil.IsHiddenCommand(subcommandsutil.Deprecated(subcommandsutil.Hidden(testcmd.NewRecording("deploy")), "gone")) {
+		t.Fatal("wanted the deprecated hidden command to be hidden")
+	}
+}
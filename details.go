@@ -0,0 +1,98 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"context"
+	"flag"
+	"regexp"
+	"strings"
+
+	"github.com/google/subcommands"
+)
+
+// Detailer is implemented by a Command providing a long description, a few paragraphs separated by
+// blank lines explaining what the command does beyond its synopsis and usage.
+type Detailer interface {
+	Details() string
+}
+
+// detailsCommand wraps a subcommands.Command so that it provides a long description.
+type detailsCommand struct {
+	sub     subcommands.Command
+	details string
+}
+
+// make sure detailsCommand implements the subcommands.Command interface.
+var _ subcommands.Command = (*detailsCommand)(nil)
+
+// WithDetails wraps sub so that it is a Detailer providing details, for the commands which do not
+// implement Detailer themselves, like the commands of CommandFunc. The details are rendered after
+// the usage by ExplainCommand, WriteDocs and ManCommand.
+func WithDetails(sub subcommands.Command, details string) subcommands.Command {
+	return &detailsCommand{
+		sub:     sub,
+		details: details,
+	}
+}
+
+// Name forwards to the underlying c.sub Command.
+func (c *detailsCommand) Name() string {
+	return c.sub.Name()
+}
+
+// Usage forwards to the underlying c.sub Command.
+func (c *detailsCommand) Usage() string {
+	return c.sub.Usage()
+}
+
+// Synopsis forwards to the underlying c.sub Command.
+func (c *detailsCommand) Synopsis() string {
+	return c.sub.Synopsis()
+}
+
+// Unwrap returns the underlying c.sub Command.
+func (c *detailsCommand) Unwrap() subcommands.Command {
+	return c.sub
+}
+
+// Details implements Detailer.
+func (c *detailsCommand) Details() string {
+	return c.details
+}
+
+// SetFlags forwards to the underlying c.sub Command.
+func (c *detailsCommand) SetFlags(f *flag.FlagSet) {
+	c.sub.SetFlags(f)
+}
+
+// Execute forwards to the underlying c.sub Command.
+func (c *detailsCommand) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	return c.sub.Execute(ctx, f, args...)
+}
+
+// paragraphBreak separates the paragraphs of details.
+var paragraphBreak = regexp.MustCompile(`\n[ \t]*\n`)
+
+// paragraphs returns the paragraphs of details, each with its lines joined by spaces.
+func paragraphs(details string) []string {
+	var paras []string
+	for _, p := range paragraphBreak.Split(strings.TrimSpace(details), -1) {
+		if p = strings.Join(strings.Fields(p), " "); p != "" {
+			paras = append(paras, p)
+		}
+	}
+
+	return paras
+}
+
+// wrapParagraphs wraps each paragraph of details to width, separating them by blank lines.
+func wrapParagraphs(details string, width int) string {
+	paras := paragraphs(details)
+	for i, p := range paras {
+		paras[i] = strings.Join(wrapText(p, width), "\n")
+	}
+
+	return strings.Join(paras, "\n\n")
+}
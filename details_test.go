@@ -0,0 +1,103 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+// syncDetails are the details of the command returned by newSyncCommand.
+const syncDetails = `
+Sync copies the files of SRC missing or older in DST, and
+removes the files of DST missing in SRC when -delete is given.
+
+The files are compared by size and modification time.
+	Use -checksum to compare their contents instead, which is slower.
+
+  
+Symbolic links are copied as links.
+`
+
+// newSyncCommand returns a command with multi-paragraph details.
+func newSyncCommand() subcommands.Command {
+	return subcommandsutil.WithDetails(testcmd.NewRecording("sync",
+		testcmd.WithSynopsis("synchronize directories"),
+		testcmd.WithUsage("sync [-delete] SRC DST:\n  Synchronize DST with SRC.\n"),
+		testcmd.WithFlags(func(f *flag.FlagSet) {
+			f.Bool("delete", false, "delete the extraneous files of DST")
+			f.Bool("checksum", false, "compare the contents of the files")
+		}),
+	), syncDetails)
+}
+
+func TestWithDetails(t *testing.T) {
+	tests := map[string]struct {
+		cmd        subcommands.Command
+		goldenPath string
+	}{
+		"when the command has details": {
+			cmd:        newSyncCommand(),
+			goldenPath: "testdata/details.golden",
+		},
+		"when the details are empty": {
+			cmd:        subcommandsutil.WithDetails(testcmd.NewRecording("sync", testcmd.WithUsage("sync SRC DST:\n  Synchronize DST with SRC.\n")), " \n"),
+			goldenPath: "testdata/details_none.golden",
+		},
+	}
+	for name, tt := range tests {
+		tt := tt
+		t.Run(name, func(t *testing.T) {
+			t.Setenv(subcommandsutil.ColumnsEnv, "40")
+
+			var buf bytes.Buffer
+			subcommandsutil.ExplainCommand(&buf, tt.cmd)
+			testcmd.Golden(t, buf.String(), tt.goldenPath)
+
+			status, _, _ := testcmd.Run(context.Background(), tt.cmd)
+			testcmd.RequireSuccess(t, status)
+		})
+	}
+}
+
+func TestDetailsDocs(t *testing.T) {
+	top := flag.NewFlagSet("tool", flag.ContinueOnError)
+	cdr := subcommands.NewCommander(top, "tool")
+	cdr.Register(newSyncCommand(), "")
+
+	dir := t.TempDir()
+	if err := subcommandsutil.WriteDocs(cdr, dir); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "tool_sync.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	testcmd.Golden(t, string(data), "testdata/details_docs.md.golden")
+
+	man := subcommandsutil.ManCommand(cdr, subcommandsutil.ManMeta{
+		Date:   time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC),
+		Source: "tool 1.2.0",
+		Manual: "Tool Manual",
+	})
+	if err := top.Parse([]string{"man", "-dir", dir}); err != nil {
+		t.Fatal(err)
+	}
+	cdr.Register(man, "")
+	testcmd.RequireSuccess(t, cdr.Execute(subcommandsutil.WithOutput(context.Background(), &testcmd.Buffer{}, &testcmd.Buffer{})))
+	if data, err = os.ReadFile(filepath.Join(dir, "tool-sync.1")); err != nil {
+		t.Fatal(err)
+	}
+	testcmd.Golden(t, string(data), "testdata/details_man.1.golden")
+}
@@ -0,0 +1,170 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// dirKind is a kind of per-user directory of an application.
+type dirKind int
+
+const (
+	configDirKind dirKind = iota
+	stateDirKind
+	cacheDirKind
+)
+
+// dirKinds describes the dirKinds, in order: the name used in the errors, the XDG environment
+// variable overriding the directory, and its default relative to the home directory.
+var dirKinds = [...]struct {
+	name       string
+	xdgEnv     string
+	xdgDefault string
+}{
+	configDirKind: {name: "config", xdgEnv: "XDG_CONFIG_HOME", xdgDefault: ".config"},
+	stateDirKind:  {name: "state", xdgEnv: "XDG_STATE_HOME", xdgDefault: filepath.Join(".local", "state")},
+	cacheDirKind:  {name: "cache", xdgEnv: "XDG_CACHE_HOME", xdgDefault: ".cache"},
+}
+
+// ConfigDir returns the per-user configuration directory of the application appName, creating it
+// with the 0700 permissions if it does not exist:
+//
+//   - $XDG_CONFIG_HOME/appName, or ~/.config/appName, on unix.
+//   - $XDG_CONFIG_HOME/appName, or ~/Library/Application Support/appName, on macOS.
+//   - %APPDATA%\appName on Windows.
+//
+// The XDG variables are ignored unless they are absolute paths, as the XDG Base Directory
+// specification requires.
+func ConfigDir(appName string) (string, error) {
+	return makeUserDir(configDirKind, appName)
+}
+
+// StateDir returns the per-user state directory of the application appName, for the data which
+// outlive an execution but are not worth backing up, like a history or the crash dumps, creating it
+// with the 0700 permissions if it does not exist:
+//
+//   - $XDG_STATE_HOME/appName, or ~/.local/state/appName, on unix.
+//   - $XDG_STATE_HOME/appName, or ~/Library/Application Support/appName, on macOS.
+//   - %LOCALAPPDATA%\appName on Windows.
+func StateDir(appName string) (string, error) {
+	return makeUserDir(stateDirKind, appName)
+}
+
+// CacheDir returns the per-user cache directory of the application appName, for the data which can
+// be deleted at any time, creating it with the 0700 permissions if it does not exist:
+//
+//   - $XDG_CACHE_HOME/appName, or ~/.cache/appName, on unix.
+//   - $XDG_CACHE_HOME/appName, or ~/Library/Caches/appName, on macOS.
+//   - %LOCALAPPDATA%\appName\cache on Windows.
+func CacheDir(appName string) (string, error) {
+	return makeUserDir(cacheDirKind, appName)
+}
+
+// ConfigFilePaths returns the paths where the configuration file named filename of the application
+// appName is searched for, the most specific first:
+//
+//   - filename in the current directory.
+//   - filename in the ConfigDir of appName, which is not created.
+//   - filename in appName in each directory of $XDG_CONFIG_DIRS, or /etc/xdg, on unix but macOS.
+//   - /etc/appName/filename on unix, or %ProgramData%\appName\filename on Windows.
+//
+// The directories which cannot be resolved, like the ConfigDir when the home directory is unknown,
+// are left out.
+func ConfigFilePaths(appName, filename string) []string {
+	cwd, _ := os.Getwd()
+
+	return configFilePaths(appName, filename, runtime.GOOS, os.Getenv, cwd)
+}
+
+// makeUserDir returns the directory of kind of appName on the running platform, creating it with
+// the 0700 permissions if it does not exist.
+func makeUserDir(kind dirKind, appName string) (string, error) {
+	dir, err := userDir(kind, appName, runtime.GOOS, os.Getenv)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", fmt.Errorf("create the %s directory: %w", dirKinds[kind].name, err)
+	}
+
+	return dir, nil
+}
+
+// userDir returns the directory of kind of appName on goos, reading the environment with getenv.
+func userDir(kind dirKind, appName, goos string, getenv func(string) string) (string, error) {
+	if appName == "" {
+		return "", errors.New("empty application name")
+	}
+	k := dirKinds[kind]
+
+	if goos == "windows" {
+		env := "LOCALAPPDATA"
+		if kind == configDirKind {
+			env = "APPDATA"
+		}
+		base := getenv(env)
+		if base == "" {
+			return "", fmt.Errorf("%s directory: %%%s%% is not defined", k.name, env)
+		}
+		if kind == cacheDirKind {
+			return filepath.Join(base, appName, "cache"), nil
+		}
+
+		return filepath.Join(base, appName), nil
+	}
+
+	if base := getenv(k.xdgEnv); filepath.IsAbs(base) {
+		return filepath.Join(base, appName), nil
+	}
+	home := getenv("HOME")
+	if home == "" {
+		return "", fmt.Errorf("%s directory: neither $%s nor $HOME is defined", k.name, k.xdgEnv)
+	}
+	if goos == "darwin" {
+		sub := filepath.Join("Library", "Application Support")
+		if kind == cacheDirKind {
+			sub = filepath.Join("Library", "Caches")
+		}
+		return filepath.Join(home, sub, appName), nil
+	}
+
+	return filepath.Join(home, k.xdgDefault, appName), nil
+}
+
+// configFilePaths returns the ConfigFilePaths of filename of appName on goos, reading the
+// environment with getenv, relative to the current directory cwd.
+func configFilePaths(appName, filename, goos string, getenv func(string) string, cwd string) []string {
+	paths := []string{filepath.Join(cwd, filename)}
+	if dir, err := userDir(configDirKind, appName, goos, getenv); err == nil {
+		paths = append(paths, filepath.Join(dir, filename))
+	}
+
+	switch goos {
+	case "windows":
+		if base := getenv("ProgramData"); base != "" {
+			paths = append(paths, filepath.Join(base, appName, filename))
+		}
+	case "darwin":
+		paths = append(paths, filepath.Join("/etc", appName, filename))
+	default:
+		dirs := getenv("XDG_CONFIG_DIRS")
+		if dirs == "" {
+			dirs = "/etc/xdg"
+		}
+		for _, dir := range strings.Split(dirs, ":") {
+			if filepath.IsAbs(dir) {
+				paths = append(paths, filepath.Join(dir, appName, filename))
+			}
+		}
+		paths = append(paths, filepath.Join("/etc", appName, filename))
+	}
+
+	return paths
+}
@@ -0,0 +1,249 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+
+	"github.com/zchee/subcommandsutil"
+)
+
+func TestUserDir(t *testing.T) {
+	const (
+		home         = "/home/gopher"
+		appData      = `C:\Users\gopher\AppData\Roaming`
+		localAppData = `C:\Users\gopher\AppData\Local`
+	)
+	tests := map[string]struct {
+		kind    string
+		goos    string
+		env     map[string]string
+		want    string
+		wantErr bool
+	}{
+		"when the config directory is the default on linux": {
+			kind: "config",
+			goos: "linux",
+			env:  map[string]string{"HOME": home},
+			want: filepath.Join(home, ".config", "tool"),
+		},
+		"when the state directory is the default on linux": {
+			kind: "state",
+			goos: "linux",
+			env:  map[string]string{"HOME": home},
+			want: filepath.Join(home, ".local", "state", "tool"),
+		},
+		"when the cache directory is the default on freebsd": {
+			kind: "cache",
+			goos: "freebsd",
+			env:  map[string]string{"HOME": home},
+			want: filepath.Join(home, ".cache", "tool"),
+		},
+		"when XDG_CONFIG_HOME overrides the config directory": {
+			kind: "config",
+			goos: "linux",
+			env:  map[string]string{"HOME": home, "XDG_CONFIG_HOME": "/srv/config"},
+			want: filepath.Join("/srv/config", "tool"),
+		},
+		"when XDG_STATE_HOME overrides the state directory without HOME": {
+			kind: "state",
+			goos: "linux",
+			env:  map[string]string{"XDG_STATE_HOME": "/srv/state"},
+			want: filepath.Join("/srv/state", "tool"),
+		},
+		"when XDG_CACHE_HOME is relative it is ignored": {
+			kind: "cache",
+			goos: "linux",
+			env:  map[string]string{"HOME": home, "XDG_CACHE_HOME": "cache"},
+			want: filepath.Join(home, ".cache", "tool"),
+		},
+		"when the config directory is the default on darwin": {
+			kind: "config",
+			goos: "darwin",
+			env:  map[string]string{"HOME": home},
+			want: filepath.Join(home, "Library", "Application Support", "tool"),
+		},
+		"when the cache directory is the default on darwin": {
+			kind: "cache",
+			goos: "darwin",
+			env:  map[string]string{"HOME": home},
+			want: filepath.Join(home, "Library", "Caches", "tool"),
+		},
+		"when XDG_CONFIG_HOME overrides the config directory on darwin": {
+			kind: "config",
+			goos: "darwin",
+			env:  map[string]string{"HOME": home, "XDG_CONFIG_HOME": "/srv/config"},
+			want: filepath.Join("/srv/config", "tool"),
+		},
+		"when the config directory is on windows": {
+			kind: "config",
+			goos: "windows",
+			env:  map[string]string{"APPDATA": appData, "LOCALAPPDATA": localAppData},
+			want: filepath.Join(appData, "tool"),
+		},
+		"when the state directory is on windows": {
+			kind: "state",
+			goos: "windows",
+			env:  map[string]string{"APPDATA": appData, "LOCALAPPDATA": localAppData},
+			want: filepath.Join(localAppData, "tool"),
+		},
+		"when the cache directory is on windows": {
+			kind: "cache",
+			goos: "windows",
+			env:  map[string]string{"APPDATA": appData, "LOCALAPPDATA": localAppData},
+			want: filepath.Join(localAppData, "tool", "cache"),
+		},
+		"when neither XDG_CONFIG_HOME nor HOME is defined": {
+			kind:    "config",
+			goos:    "linux",
+			wantErr: true,
+		},
+		"when APPDATA is not defined on windows": {
+			kind:    "config",
+			goos:    "windows",
+			env:     map[string]string{"LOCALAPPDATA": localAppData},
+			wantErr: true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := subcommandsutil.UserDir(tt.kind, "tool", tt.goos, tt.env)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("wanted error %v but got %v", tt.wantErr, err)
+			}
+			if got != tt.want {
+				t.Fatalf("wanted %q but got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestConfigDir(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the XDG variables are not read on windows")
+	}
+	base := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(base, "config"))
+	t.Setenv("XDG_STATE_HOME", filepath.Join(base, "state"))
+	t.Setenv("XDG_CACHE_HOME", filepath.Join(base, "cache"))
+
+	tests := map[string]struct {
+		dir  func(appName string) (string, error)
+		want string
+	}{
+		"when the config directory is created": {
+			dir:  subcommandsutil.ConfigDir,
+			want: filepath.Join(base, "config", "tool"),
+		},
+		"when the state directory is created": {
+			dir:  subcommandsutil.StateDir,
+			want: filepath.Join(base, "state", "tool"),
+		},
+		"when the cache directory is created": {
+			dir:  subcommandsutil.CacheDir,
+			want: filepath.Join(base, "cache", "tool"),
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			for i := 0; i < 2; i++ {
+				got, err := tt.dir("tool")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got != tt.want {
+					t.Fatalf("wanted %q but got %q", tt.want, got)
+				}
+			}
+			fi, err := os.Stat(tt.want)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if perm := fi.Mode().Perm(); !fi.IsDir() || perm&0o077 != 0 {
+				t.Fatalf("wanted a private directory but got %v", fi.Mode())
+			}
+		})
+	}
+
+	if _, err := subcommandsutil.ConfigDir(""); err == nil {
+		t.Fatal("wanted an error for an empty application name but got nil")
+	}
+}
+
+func TestConfigFilePaths(t *testing.T) {
+	const home = "/home/gopher"
+	tests := map[string]struct {
+		goos string
+		env  map[string]string
+		want []string
+	}{
+		"when the search path is the default on linux": {
+			goos: "linux",
+			env:  map[string]string{"HOME": home},
+			want: []string{
+				filepath.Join("/work", "tool.toml"),
+				filepath.Join(home, ".config", "tool", "tool.toml"),
+				filepath.Join("/etc/xdg", "tool", "tool.toml"),
+				filepath.Join("/etc", "tool", "tool.toml"),
+			},
+		},
+		"when XDG_CONFIG_DIRS lists the system directories": {
+			goos: "linux",
+			env:  map[string]string{"HOME": home, "XDG_CONFIG_HOME": "/srv/config", "XDG_CONFIG_DIRS": "/opt/xdg:relative:/usr/local/etc/xdg"},
+			want: []string{
+				filepath.Join("/work", "tool.toml"),
+				filepath.Join("/srv/config", "tool", "tool.toml"),
+				filepath.Join("/opt/xdg", "tool", "tool.toml"),
+				filepath.Join("/usr/local/etc/xdg", "tool", "tool.toml"),
+				filepath.Join("/etc", "tool", "tool.toml"),
+			},
+		},
+		"when the home directory is unknown": {
+			goos: "linux",
+			want: []string{
+				filepath.Join("/work", "tool.toml"),
+				filepath.Join("/etc/xdg", "tool", "tool.toml"),
+				filepath.Join("/etc", "tool", "tool.toml"),
+			},
+		},
+		"when the search path is on darwin": {
+			goos: "darwin",
+			env:  map[string]string{"HOME": home},
+			want: []string{
+				filepath.Join("/work", "tool.toml"),
+				filepath.Join(home, "Library", "Application Support", "tool", "tool.toml"),
+				filepath.Join("/etc", "tool", "tool.toml"),
+			},
+		},
+		"when the search path is on windows": {
+			goos: "windows",
+			env:  map[string]string{"APPDATA": `C:\Users\gopher\AppData\Roaming`, "ProgramData": `C:\ProgramData`},
+			want: []string{
+				filepath.Join("/work", "tool.toml"),
+				filepath.Join(`C:\Users\gopher\AppData\Roaming`, "tool", "tool.toml"),
+				filepath.Join(`C:\ProgramData`, "tool", "tool.toml"),
+			},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := subcommandsutil.ConfigFilePathsOn("tool", "tool.toml", tt.goos, "/work", tt.env)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("wanted %q but got %q", tt.want, got)
+			}
+		})
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := subcommandsutil.ConfigFilePaths("tool", "tool.toml"); len(got) == 0 || got[0] != filepath.Join(cwd, "tool.toml") {
+		t.Fatalf("wanted the current directory to be searched first but got %q", got)
+	}
+}
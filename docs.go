@@ -0,0 +1,280 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/google/subcommands"
+)
+
+// docsIndexName is the name of the index page written by WriteDocs.
+const docsIndexName = "index.md"
+
+// docsPage is a command of the reference written by WriteDocs.
+type docsPage struct {
+	path []string // the names from the program to the command
+	cmd  subcommands.Command
+	subs []*docsPage // the commands of a Group, sorted by name
+}
+
+// fileName returns the name of the Markdown file of the page.
+func (p *docsPage) fileName() string {
+	return strings.Join(p.path, "_") + ".md"
+}
+
+// WriteDocs writes a Markdown reference of the visible commands registered in cdr to dir: a file
+// per command, including the commands nested in a Group, with front matter, the synopsis, the
+// usage, the flags, the positional arguments and the examples of the command, and an index page
+// listing the commands. The files are named after the command path, like "tool_remote_add.md",
+// and their content only depends on the commands, so they can be committed and diffed.
+func WriteDocs(cdr *subcommands.Commander, dir string) error {
+	var pages []*docsPage
+	for _, cmd := range visibleCommands(cdr) {
+		pages = append(pages, newDocsPage([]string{cdr.Name()}, cmd))
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	writeDocsIndex(&buf, cdr.Name(), pages)
+	if err := os.WriteFile(filepath.Join(dir, docsIndexName), buf.Bytes(), 0o644); err != nil {
+		return err
+	}
+
+	return writeDocsPages(dir, pages)
+}
+
+// newDocsPage returns the page of cmd, named by path after the names of its parents.
+func newDocsPage(parents []string, cmd subcommands.Command) *docsPage {
+	p := &docsPage{
+		path: append(append([]string(nil), parents...), cmd.Name()),
+		cmd:  cmd,
+	}
+
+	var g *Group
+	walkCommand(cmd, func(cmd subcommands.Command) bool {
+		var ok bool
+		g, ok = cmd.(*Group)
+		return ok
+	})
+	if g == nil {
+		return p
+	}
+
+	var subs []subcommands.Command
+	for _, e := range g.entries {
+		if !IsHiddenCommand(e.cmd) {
+			subs = append(subs, e.cmd)
+		}
+	}
+	sort.Slice(subs, func(i, j int) bool {
+		return subs[i].Name() < subs[j].Name()
+	})
+	for _, sub := range subs {
+		p.subs = append(p.subs, newDocsPage(p.path, sub))
+	}
+
+	return p
+}
+
+// writeDocsPages writes the files of pages and of their nested pages to dir.
+func writeDocsPages(dir string, pages []*docsPage) error {
+	for _, p := range pages {
+		var buf bytes.Buffer
+		writeDocsPage(&buf, p)
+		if err := os.WriteFile(filepath.Join(dir, p.fileName()), buf.Bytes(), 0o644); err != nil {
+			return err
+		}
+		if err := writeDocsPages(dir, p.subs); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeDocsIndex writes the index page of the program named name, listing pages, to w.
+func writeDocsIndex(w io.Writer, name string, pages []*docsPage) {
+	fmt.Fprintf(w, "---\ntitle: %q\n---\n\n# %s\n\n", name, name)
+	writeDocsCommands(w, "Commands", pages)
+}
+
+// writeDocsCommands writes the table of the commands of pages under title to w.
+func writeDocsCommands(w io.Writer, title string, pages []*docsPage) {
+	if len(pages) == 0 {
+		return
+	}
+
+	fmt.Fprintf(w, "## %s\n\n| Command | Description |\n| --- | --- |\n", title)
+	for _, p := range pages {
+		fmt.Fprintf(w, "| [%s](%s) | %s |\n", strings.Join(p.path, " "), p.fileName(), markdownCell(p.cmd.Synopsis()))
+	}
+	fmt.Fprintf(w, "\n")
+}
+
+// writeDocsPage writes the page p to w.
+func writeDocsPage(w io.Writer, p *docsPage) {
+	d := NewUsageData(p.cmd)
+	title := strings.Join(p.path, " ")
+
+	fmt.Fprintf(w, "---\ntitle: %q\ndescription: %q\n---\n\n# %s\n\n", title, d.Synopsis, title)
+	if d.Synopsis != "" {
+		fmt.Fprintf(w, "%s\n\n", d.Synopsis)
+	}
+
+	synopsis := title
+	if len(d.Flags) > 0 {
+		synopsis += " [flags]"
+	}
+	if d.Args != "" {
+		synopsis += " " + d.Args
+	}
+	if len(p.subs) > 0 {
+		synopsis += " COMMAND [args...]"
+	}
+	fmt.Fprintf(w, "## Synopsis\n\n```\n%s\n```\n\n", synopsis)
+
+	if usage := strings.TrimRight(d.Usage, "\n"); usage != "" {
+		fmt.Fprintf(w, "## Usage\n\n```\n%s\n```\n\n", usage)
+	}
+
+	if paras := paragraphs(d.Details); len(paras) > 0 {
+		fmt.Fprintf(w, "## Description\n\n%s\n\n", strings.Join(paras, "\n\n"))
+	}
+
+	if spec, ok := ArgsSpecOf(p.cmd); ok {
+		fmt.Fprintf(w, "## Arguments\n\n`%s` (%s)\n\n", spec.String(), argsCount(spec))
+	}
+
+	if len(d.FlagGroups) > 0 {
+		fmt.Fprintf(w, "## Flags\n\n")
+	}
+	for _, g := range d.FlagGroups {
+		if g.Title != "" {
+			fmt.Fprintf(w, "### %s\n\n", g.Title)
+		}
+		writeDocsFlags(w, g.Flags)
+	}
+
+	if len(d.Examples) > 0 {
+		fmt.Fprintf(w, "## Examples\n\n")
+	}
+	for _, e := range d.Examples {
+		fmt.Fprintf(w, "%s:\n\n```\n%s\n```\n\n", e.Description, e.Command)
+	}
+
+	writeDocsCommands(w, "Commands", p.subs)
+
+	parent := docsIndexName
+	if len(p.path) > 2 {
+		parent = strings.Join(p.path[:len(p.path)-1], "_") + ".md"
+	}
+	fmt.Fprintf(w, "## See also\n\n- [%s](%s)\n", strings.Join(p.path[:len(p.path)-1], " "), parent)
+}
+
+// writeDocsFlags writes the table of flags to w.
+func writeDocsFlags(w io.Writer, flags []FlagData) {
+	fmt.Fprintf(w, "| Flag | Default | Description |\n| --- | --- | --- |\n")
+	for _, fl := range flags {
+		names := make([]string, 0, 1+len(fl.Aliases))
+		for _, name := range append([]string{fl.Name}, fl.Aliases...) {
+			names = append(names, "-"+name)
+		}
+		flagCell := "`" + strings.Join(names, "`, `") + "`"
+		if fl.ValueName != "" {
+			flagCell += " _" + fl.ValueName + "_"
+		}
+		defaultCell := ""
+		if fl.Default != "" {
+			defaultCell = "`" + fl.Default + "`"
+		}
+		usage := fl.Usage
+		if fl.Sensitive {
+			usage += " (sensitive)"
+		}
+		fmt.Fprintf(w, "| %s | %s | %s |\n", flagCell, markdownCell(defaultCell), markdownCell(usage))
+	}
+	fmt.Fprintf(w, "\n")
+}
+
+// argsCount describes the number of positional arguments spec accepts, like "1 to 2 arguments".
+func argsCount(spec ArgsSpec) string {
+	switch {
+	case spec.Max < 0:
+		return fmt.Sprintf("at least %d %s", spec.Min, plural(spec.Min, "argument"))
+	case spec.Min == spec.Max:
+		return fmt.Sprintf("%d %s", spec.Min, plural(spec.Min, "argument"))
+	default:
+		return fmt.Sprintf("%d to %d arguments", spec.Min, spec.Max)
+	}
+}
+
+// markdownCell escapes s for use in a cell of a Markdown table.
+func markdownCell(s string) string {
+	return strings.NewReplacer("|", `\|`, "\n", " ").Replace(s)
+}
+
+// docs is the command writing the Markdown reference of the commands of a Commander.
+type docs struct {
+	cdr *subcommands.Commander
+
+	dir string
+}
+
+// make sure docs implements the subcommands.Command interface.
+var _ subcommands.Command = (*docs)(nil)
+
+// DocsCommand returns a hidden command named "docs" writing the Markdown reference of the commands
+// registered in cdr with WriteDocs to the directory given by its -dir flag.
+func DocsCommand(cdr *subcommands.Commander) subcommands.Command {
+	return Hidden(&docs{
+		cdr: cdr,
+	})
+}
+
+// Name implements subcommands.Command.
+func (c *docs) Name() string {
+	return "docs"
+}
+
+// Synopsis implements subcommands.Command.
+func (c *docs) Synopsis() string {
+	return "write the Markdown reference of the commands"
+}
+
+// Usage implements subcommands.Command.
+func (c *docs) Usage() string {
+	return "docs [-dir DIR]:\n  Write the Markdown reference of the commands to DIR.\n"
+}
+
+// SetFlags implements subcommands.Command.
+func (c *docs) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&c.dir, "dir", "docs", "the `directory` to write the reference to")
+}
+
+// Execute implements subcommands.Command.
+func (c *docs) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	if f.NArg() != 0 {
+		fmt.Fprintf(Stderr(ctx), "%s: unexpected arguments %q\n", c.Name(), f.Args())
+		return subcommands.ExitUsageError
+	}
+
+	if err := WriteDocs(c.cdr, c.dir); err != nil {
+		fmt.Fprintf(Stderr(ctx), "%s: %v\n", c.Name(), err)
+		return subcommands.ExitFailure
+	}
+	fmt.Fprintf(Info(ctx), "%s: wrote the reference to %s\n", c.Name(), c.dir)
+
+	return subcommands.ExitSuccess
+}
@@ -0,0 +1,89 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"context"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+// newDocsCommander returns a Commander with nested commands for the docs tests.
+func newDocsCommander() (*subcommands.Commander, *flag.FlagSet) {
+	top := flag.NewFlagSet("tool", flag.ContinueOnError)
+	cdr := subcommands.NewCommander(top, "tool")
+	cdr.Register(newCopyCommand(), "")
+
+	remote := subcommandsutil.NewGroup("remote", "manage remotes")
+	remote.Register(subcommandsutil.WithArgs(testcmd.NewRecording("add",
+		testcmd.WithSynopsis("add a remote"),
+		testcmd.WithUsage("add NAME URL:\n  Add the remote NAME at URL.\n"),
+		testcmd.WithFlags(func(f *flag.FlagSet) {
+			f.Bool("fetch", false, "fetch the remote | its tags")
+		}),
+	), subcommandsutil.ArgsSpec{Min: 2, Max: 2, Names: []string{"NAME", "URL"}}), "")
+	remote.Register(testcmd.NewRecording("list", testcmd.WithSynopsis("list the remotes")), "")
+	remote.Register(subcommandsutil.Hidden(testcmd.NewRecording("prune")), "")
+	cdr.Register(remote, "")
+
+	cdr.Register(subcommandsutil.Hidden(testcmd.NewRecording("debug")), "")
+	cdr.Register(subcommandsutil.DocsCommand(cdr), "")
+
+	return cdr, top
+}
+
+func TestWriteDocs(t *testing.T) {
+	cdr, _ := newDocsCommander()
+	dir := t.TempDir()
+	if err := subcommandsutil.WriteDocs(cdr, dir); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	sort.Strings(names)
+	if want := []string{"index.md", "tool_cp.md", "tool_remote.md", "tool_remote_add.md", "tool_remote_list.md"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("wanted the files %q but got %q", want, names)
+	}
+
+	for _, name := range []string{"index.md", "tool_cp.md", "tool_remote.md", "tool_remote_add.md"} {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		testcmd.Golden(t, string(data), filepath.Join("testdata", "docs_"+name+".golden"))
+	}
+}
+
+func TestDocsCommand(t *testing.T) {
+	cdr, top := newDocsCommander()
+	dir := filepath.Join(t.TempDir(), "reference")
+	if err := top.Parse([]string{"docs", "-dir", dir}); err != nil {
+		t.Fatal(err)
+	}
+	var stdout testcmd.Buffer
+	testcmd.RequireSuccess(t, cdr.Execute(subcommandsutil.WithOutput(context.Background(), &stdout, &stdout)))
+
+	if _, err := os.Stat(filepath.Join(dir, "index.md")); err != nil {
+		t.Fatalf("wanted the index page to be written but got %v", err)
+	}
+	if want := "docs: wrote the reference to " + dir + "\n"; stdout.String() != want {
+		t.Fatalf("wanted the output %q but got %q", want, stdout.String())
+	}
+}
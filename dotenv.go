@@ -0,0 +1,216 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/google/subcommands"
+)
+
+// dotEnv wraps a subcommands.Command so that dotenv files are loaded before it is executed.
+type dotEnv struct {
+	sub   subcommands.Command
+	paths []string
+}
+
+// make sure dotEnv implements the subcommands.Command interface.
+var _ subcommands.Command = (*dotEnv)(nil)
+
+// DotEnv wraps sub so that the dotenv files at paths are loaded with LoadDotEnv before sub is
+// executed. If no paths are given, ".env" is loaded.
+//
+// A malformed file is reported to the FlagSet's output and Execute returns subcommands.ExitFailure.
+func DotEnv(sub subcommands.Command, paths ...string) subcommands.Command {
+	if len(paths) == 0 {
+		paths = []string{".env"}
+	}
+
+	return &dotEnv{
+		sub:   sub,
+		paths: paths,
+	}
+}
+
+// Name forwards to the underlying c.sub Command.
+func (c *dotEnv) Name() string {
+	return c.sub.Name()
+}
+
+// Usage forwards to the underlying c.sub Command.
+func (c *dotEnv) Usage() string {
+	return c.sub.Usage()
+}
+
+// Synopsis forwards to the underlying c.sub Command.
+func (c *dotEnv) Synopsis() string {
+	return c.sub.Synopsis()
+}
+
+// SetFlags forwards to the underlying c.sub Command.
+func (c *dotEnv) SetFlags(f *flag.FlagSet) {
+	c.sub.SetFlags(f)
+}
+
+// Unwrap returns the underlying c.sub Command.
+func (c *dotEnv) Unwrap() subcommands.Command {
+	return c.sub
+}
+
+// Execute loads the dotenv files and forwards to the underlying c.sub Command.
+func (c *dotEnv) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	if err := LoadDotEnv(c.paths...); err != nil {
+		fmt.Fprintln(f.Output(), err)
+		return subcommands.ExitFailure
+	}
+
+	return c.sub.Execute(ctx, f, args...)
+}
+
+// LoadDotEnv loads the KEY=VALUE lines of the dotenv files at paths into the process environment.
+// Variables already present in the environment are never overridden, so earlier files take
+// precedence over later ones. Files which do not exist are skipped.
+//
+// Blank lines and lines starting with "#" are ignored, a leading "export " is allowed, and values
+// may be single-quoted (literal) or double-quoted (with \n, \t, \" and \\ escapes).
+func LoadDotEnv(paths ...string) error {
+	for _, path := range paths {
+		fp, err := os.Open(path)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return err
+		}
+
+		vars, err := parseDotEnv(fp, path)
+		fp.Close()
+		if err != nil {
+			return err
+		}
+
+		for _, kv := range vars {
+			if _, ok := os.LookupEnv(kv[0]); ok {
+				continue
+			}
+			if err := os.Setenv(kv[0], kv[1]); err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// parseDotEnv parses the dotenv file r named path into key value pairs.
+func parseDotEnv(r io.Reader, path string) ([][2]string, error) {
+	var vars [][2]string
+
+	sc := bufio.NewScanner(r)
+	for lineno := 1; sc.Scan(); lineno++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		i := strings.IndexByte(line, '=')
+		if i < 0 {
+			return nil, fmt.Errorf("%s:%d: missing '=' in %q", path, lineno, line)
+		}
+		key := strings.TrimSpace(line[:i])
+		if !isEnvName(key) {
+			return nil, fmt.Errorf("%s:%d: invalid variable name %q", path, lineno, key)
+		}
+
+		value, err := parseDotEnvValue(strings.TrimSpace(line[i+1:]))
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, lineno, err)
+		}
+		vars = append(vars, [2]string{key, value})
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return vars, nil
+}
+
+// parseDotEnvValue parses the value of a dotenv line.
+func parseDotEnvValue(s string) (string, error) {
+	if s == "" {
+		return "", nil
+	}
+
+	switch quote := s[0]; quote {
+	case '\'':
+		end := strings.IndexByte(s[1:], '\'')
+		if end < 0 {
+			return "", errors.New("unterminated single-quoted value")
+		}
+		if rest := strings.TrimSpace(s[end+2:]); rest != "" && !strings.HasPrefix(rest, "#") {
+			return "", fmt.Errorf("unexpected %q after quoted value", rest)
+		}
+		return s[1 : end+1], nil
+
+	case '"':
+		var b strings.Builder
+		for i := 1; i < len(s); i++ {
+			switch c := s[i]; c {
+			case '\\':
+				i++
+				if i == len(s) {
+					return "", errors.New("unterminated double-quoted value")
+				}
+				switch e := s[i]; e {
+				case 'n':
+					b.WriteByte('\n')
+				case 't':
+					b.WriteByte('\t')
+				default:
+					b.WriteByte(e)
+				}
+			case '"':
+				if rest := strings.TrimSpace(s[i+1:]); rest != "" && !strings.HasPrefix(rest, "#") {
+					return "", fmt.Errorf("unexpected %q after quoted value", rest)
+				}
+				return b.String(), nil
+			default:
+				b.WriteByte(c)
+			}
+		}
+		return "", errors.New("unterminated double-quoted value")
+	}
+
+	// an unquoted value ends at an inline comment
+	if i := strings.Index(s, " #"); i >= 0 {
+		s = s[:i]
+	}
+
+	return strings.TrimSpace(s), nil
+}
+
+// isEnvName reports whether s is a valid environment variable name.
+func isEnvName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, c := range s {
+		switch {
+		case c == '_', 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z':
+		case '0' <= c && c <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+
+	return true
+}
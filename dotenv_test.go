@@ -0,0 +1,115 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+func TestDotEnv(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, ".env")
+	const content = `# local development settings
+export DOTENV_TEST_HOST=localhost
+DOTENV_TEST_PORT=8080 # inline comment
+DOTENV_TEST_GREETING="hello\nworld"
+DOTENV_TEST_LITERAL='a $b \n'
+DOTENV_TEST_PRESET=from-file
+`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	keys := []string{"DOTENV_TEST_HOST", "DOTENV_TEST_PORT", "DOTENV_TEST_GREETING", "DOTENV_TEST_LITERAL", "DOTENV_TEST_PRESET"}
+	defer func() {
+		for _, key := range keys {
+			os.Unsetenv(key)
+		}
+	}()
+	os.Setenv("DOTENV_TEST_PRESET", "from-env")
+
+	got := make(map[string]string)
+	cmd := subcommandsutil.DotEnv(testcmd.NewRecording("dotenv",
+		testcmd.WithExecute(func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+			for _, key := range keys {
+				got[key] = os.Getenv(key)
+			}
+			return subcommands.ExitSuccess
+		}),
+	), path, filepath.Join(dir, "missing.env"))
+
+	f := flag.NewFlagSet("dotenv", flag.ContinueOnError)
+	if status := cmd.Execute(context.Background(), f); status != subcommands.ExitSuccess {
+		t.Fatalf("wanted %v but got %v", subcommands.ExitSuccess, status)
+	}
+
+	want := map[string]string{
+		"DOTENV_TEST_HOST":     "localhost",
+		"DOTENV_TEST_PORT":     "8080",
+		"DOTENV_TEST_GREETING": "hello\nworld",
+		"DOTENV_TEST_LITERAL":  `a $b \n`,
+		"DOTENV_TEST_PRESET":   "from-env",
+	}
+	for key, value := range want {
+		if got[key] != value {
+			t.Fatalf("wanted %s to be %q but got %q", key, value, got[key])
+		}
+	}
+}
+
+func TestDotEnvMalformed(t *testing.T) {
+	tests := map[string]struct {
+		content string
+		wantErr string
+	}{
+		"when the line has no equal sign": {
+			content: "# comment\nDOTENV_TEST_OK=1\nnot a variable\n",
+			wantErr: ".env:3: missing '='",
+		},
+		"when the name is invalid": {
+			content: "1ABC=value\n",
+			wantErr: ".env:1: invalid variable name",
+		},
+		"when the quote is unterminated": {
+			content: "\nDOTENV_TEST_Q=\"open\n",
+			wantErr: ".env:2: unterminated double-quoted value",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), ".env")
+			if err := os.WriteFile(path, []byte(tt.content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			defer os.Unsetenv("DOTENV_TEST_OK")
+
+			tcmd := testcmd.NewRecording("dotenv")
+			cmd := subcommandsutil.DotEnv(tcmd, path)
+
+			var out bytes.Buffer
+			f := flag.NewFlagSet("dotenv", flag.ContinueOnError)
+			f.SetOutput(&out)
+			if status := cmd.Execute(context.Background(), f); status != subcommands.ExitFailure {
+				t.Fatalf("wanted %v but got %v", subcommands.ExitFailure, status)
+			}
+			if !strings.Contains(out.String(), tt.wantErr) {
+				t.Fatalf("wanted error containing %q but got %q", tt.wantErr, out.String())
+			}
+			if tcmd.DidFinish() {
+				t.Fatal("wanted the command not to run")
+			}
+		})
+	}
+}
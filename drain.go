@@ -0,0 +1,99 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/subcommands"
+)
+
+// Drainer is implemented by the commands which stop gracefully: Drain stops accepting new work
+// and returns once the work in flight is finished, or when ctx is done.
+//
+// When the cancellation of a Cancelable execution is requested, a Drainer keeps running on an
+// execution context which is not canceled yet, and is drained first. Only after Drain returns, or
+// the drain timeout set by WithDrainTimeout expires, is the execution context canceled and the
+// command disposed.
+type Drainer interface {
+	Drain(ctx context.Context) error
+}
+
+// cancelableOptionFunc is a CancelableOption implemented by a function.
+type cancelableOptionFunc func(*cancelable)
+
+// applyCancelable implements CancelableOption.
+func (fn cancelableOptionFunc) applyCancelable(c *cancelable) { fn(c) }
+
+// WithDrainTimeout sets how long Cancelable waits for the Drain method of a Drainer, measured on the
+// Clock of the execution context. The default is 10 seconds.
+func WithDrainTimeout(d time.Duration) CancelableOption {
+	return cancelableOptionFunc(func(c *cancelable) {
+		c.drainTimeout = d
+	})
+}
+
+// WithOnDrainTimeout sets a function called by Cancelable when the Drain method of a Drainer did
+// not return within the drain timeout.
+func WithOnDrainTimeout(fn func()) CancelableOption {
+	return cancelableOptionFunc(func(c *cancelable) {
+		c.onDrainTimeout = fn
+	})
+}
+
+// WithOnHardCancel sets a function called by Cancelable when it cancels the execution context of a
+// Drainer, after the drain.
+func WithOnHardCancel(fn func()) CancelableOption {
+	return cancelableOptionFunc(func(c *cancelable) {
+		c.onHardCancel = fn
+	})
+}
+
+// commandDrainer returns the Drainer that cmd is or wraps.
+func commandDrainer(cmd subcommands.Command) (d Drainer, ok bool) {
+	walkCommand(cmd, func(cmd subcommands.Command) bool {
+		d, ok = cmd.(Drainer)
+		return ok
+	})
+
+	return d, ok
+}
+
+// drain drains d, a Drainer whose execution was requested to stop by ctx. It reports true, with
+// its status, if the execution finished while draining.
+func (c *cancelable) drain(ctx context.Context, d Drainer, ch <-chan subcommands.ExitStatus) (subcommands.ExitStatus, bool) {
+	name := c.sub.Name()
+	drainCtx, cancel := withTimeout(context.WithoutCancel(ctx), c.drainTimeout)
+	defer cancel()
+
+	// buffered so that the goroutine exits even when nobody receives after the timeout
+	errc := make(chan error, 1)
+	go func() {
+		errc <- d.Drain(drainCtx)
+	}()
+
+	select {
+	case s := <-ch:
+		return s, true
+
+	case err := <-errc:
+		if !errors.Is(drainCtx.Err(), context.DeadlineExceeded) {
+			if err != nil {
+				contextLogger(ctx, c.logger).Printf("%s: drain: %v", name, err)
+			}
+			return 0, false
+		}
+
+	case <-drainCtx.Done():
+	}
+
+	if c.onDrainTimeout != nil {
+		c.onDrainTimeout()
+	}
+	contextLogger(ctx, c.logger).Printf("%s: drain did not finish within %v", name, c.drainTimeout)
+
+	return 0, false
+}
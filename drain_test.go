@@ -0,0 +1,169 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"context"
+	"flag"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+// draining is a Recording implementing subcommandsutil.Drainer with drain, and calling onDispose
+// from Dispose.
+type draining struct {
+	*testcmd.Recording
+	drain     func(ctx context.Context) error
+	onDispose func()
+}
+
+// Drain implements subcommandsutil.Drainer.
+func (c *draining) Drain(ctx context.Context) error {
+	return c.drain(ctx)
+}
+
+// Dispose implements subcommandsutil.CancelableCommand.
+func (c *draining) Dispose() error {
+	c.onDispose()
+
+	return c.Recording.Dispose()
+}
+
+// events records the order of the events of a drain.
+type events struct {
+	mu   sync.Mutex
+	list []string
+}
+
+// add records the event e.
+func (ev *events) add(e string) {
+	ev.mu.Lock()
+	defer ev.mu.Unlock()
+
+	ev.list = append(ev.list, e)
+}
+
+// get returns the events recorded.
+func (ev *events) get() []string {
+	ev.mu.Lock()
+	defer ev.mu.Unlock()
+
+	return append([]string(nil), ev.list...)
+}
+
+func TestCancelableDrain(t *testing.T) {
+	tests := map[string]struct {
+		drain      func(ctx context.Context, ev *events, finish chan<- struct{}) error
+		timeout    bool // whether the drain timeout expires
+		wantStatus subcommands.ExitStatus
+		wantEvents []string
+		wantLog    string
+	}{
+		"when the drain completes in time": {
+			drain: func(ctx context.Context, ev *events, finish chan<- struct{}) error {
+				ev.add("drained")
+				return nil
+			},
+			wantStatus: subcommands.ExitFailure,
+			wantEvents: []string{"drained", "hard cancel", "disposed"},
+			wantLog:    "serve: context canceled",
+		},
+		"when the drain exceeds the timeout": {
+			drain: func(ctx context.Context, ev *events, finish chan<- struct{}) error {
+				<-ctx.Done()
+				return ctx.Err()
+			},
+			timeout:    true,
+			wantStatus: subcommands.ExitFailure,
+			wantEvents: []string{"drain timeout", "hard cancel", "disposed"},
+			wantLog:    "serve: drain did not finish within 5s",
+		},
+		"when the execution finishes while draining": {
+			drain: func(ctx context.Context, ev *events, finish chan<- struct{}) error {
+				close(finish)
+				<-ctx.Done()
+				return ctx.Err()
+			},
+			wantStatus: subcommands.ExitSuccess,
+			wantEvents: []string{"finished"},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			defer testcmd.VerifyNoLeaks(t)
+
+			clk := testcmd.NewFakeClock(time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC))
+			var ev events
+			started := make(chan struct{})
+			finish := make(chan struct{})
+			drainStarted := make(chan struct{})
+			var execCtx context.Context
+			sub := &draining{
+				Recording: testcmd.NewRecording("serve", testcmd.WithExecute(func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+					execCtx = ctx
+					close(started)
+					select {
+					case <-ctx.Done():
+						return subcommands.ExitFailure
+					case <-finish:
+						ev.add("finished")
+						return subcommands.ExitSuccess
+					}
+				})),
+				onDispose: func() {
+					if execCtx.Err() == nil {
+						t.Error("wanted the execution context to be canceled before Dispose")
+					}
+					ev.add("disposed")
+				},
+			}
+			drain := tt.drain // the drain may still be returning when the next case starts
+			sub.drain = func(ctx context.Context) error {
+				if execCtx.Err() != nil {
+					t.Error("wanted the execution context to be canceled only after the drain")
+				}
+				close(drainStarted)
+				return drain(ctx, &ev, finish)
+			}
+
+			var logs testcmd.LogRecorder
+			cmd := subcommandsutil.Cancelable(sub,
+				subcommandsutil.WithLogger(&logs),
+				subcommandsutil.WithDrainTimeout(5*time.Second),
+				subcommandsutil.WithOnDrainTimeout(func() { ev.add("drain timeout") }),
+				subcommandsutil.WithOnHardCancel(func() { ev.add("hard cancel") }),
+			)
+
+			ctx, cancel := context.WithCancel(subcommandsutil.WithClock(context.Background(), clk))
+			defer cancel()
+			done := make(chan subcommands.ExitStatus, 1)
+			go func() {
+				status, _, _ := testcmd.Run(ctx, cmd)
+				done <- status
+			}()
+			<-started
+			cancel()
+			<-drainStarted
+			if tt.timeout {
+				clk.BlockUntil(1)
+				clk.Advance(5 * time.Second)
+			}
+
+			testcmd.AssertStatus(t, <-done, tt.wantStatus)
+			if got := ev.get(); !reflect.DeepEqual(got, tt.wantEvents) {
+				t.Fatalf("wanted the events %q but got %q", tt.wantEvents, got)
+			}
+			if tt.wantLog != "" && !logs.Contains(tt.wantLog) {
+				t.Fatalf("wanted the logs to hold %q but got %q", tt.wantLog, logs.Lines())
+			}
+		})
+	}
+}
@@ -0,0 +1,173 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+//go:build unix
+
+package subcommandsutil_test
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"os/user"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+// listenerKey is the context key of the listener set up by the after function of DropPrivileges.
+type listenerKey struct{}
+
+func TestDropPrivileges(t *testing.T) {
+	const dropped = "after,setgroups 65534,setgid 65534,setuid 65534,setuid 0"
+	tests := map[string]struct {
+		uid, gid   int
+		user       string
+		group      string
+		fail       string
+		afterErr   error
+		reversible bool
+		ignored    bool
+		wantCalls  string
+		wantStderr string
+		wantUID    int // the user ID sub is executed with, unless wantStderr
+	}{
+		"when started as root": {
+			user:      "nobody",
+			wantCalls: dropped,
+			wantUID:   65534,
+		},
+		"when the user is given by ID": {
+			user:      "65534",
+			wantCalls: dropped,
+			wantUID:   65534,
+		},
+		"when the group is given by name": {
+			user:      "nobody",
+			group:     "www-data",
+			wantCalls: "after,setgroups 33,setgid 33,setuid 65534,setuid 0",
+			wantUID:   65534,
+		},
+		"when the group is given by ID": {
+			user:      "nobody",
+			group:     "33",
+			wantCalls: "after,setgroups 33,setgid 33,setuid 65534,setuid 0",
+			wantUID:   65534,
+		},
+		"when started as the user already": {
+			uid:       65534,
+			gid:       65534,
+			user:      "nobody",
+			wantCalls: "after",
+			wantUID:   65534,
+		},
+		"when started as another unprivileged user": {
+			uid:        1000,
+			gid:        1000,
+			user:       "nobody",
+			wantStderr: "serve: dropping the privileges to nobody: not running as root but as the user ID 1000\n",
+		},
+		"when the user is unknown": {
+			user:       "ghost",
+			wantStderr: "serve: dropping the privileges to ghost: unknown user ghost\n",
+		},
+		"when the group is unknown": {
+			user:       "nobody",
+			group:      "ghosts",
+			wantStderr: "serve: dropping the privileges to nobody: unknown group ghosts\n",
+		},
+		"when the setup fails": {
+			user:       "nobody",
+			afterErr:   errors.New("listen tcp :443: bind: address already in use"),
+			wantCalls:  "after",
+			wantStderr: "serve: dropping the privileges to nobody: setting up: listen tcp :443: bind: address already in use\n",
+		},
+		"when setgroups fails": {
+			user:       "nobody",
+			fail:       "setgroups 65534",
+			wantCalls:  "after,setgroups 65534",
+			wantStderr: "serve: dropping the privileges to nobody: setgroups 65534: operation not permitted\n",
+		},
+		"when setgid fails": {
+			user:       "nobody",
+			fail:       "setgid 65534",
+			wantCalls:  "after,setgroups 65534,setgid 65534",
+			wantStderr: "serve: dropping the privileges to nobody: setgid 65534: operation not permitted\n",
+		},
+		"when setuid fails": {
+			user:       "nobody",
+			fail:       "setuid 65534",
+			wantCalls:  "after,setgroups 65534,setgid 65534,setuid 65534",
+			wantStderr: "serve: dropping the privileges to nobody: setuid 65534: operation not permitted\n",
+		},
+		"when setuid does not change the user IDs": {
+			user:       "nobody",
+			ignored:    true,
+			wantCalls:  "after,setgroups 65534,setgid 65534,setuid 65534",
+			wantStderr: "serve: dropping the privileges to nobody: the real and effective user IDs are 0 and 0, and group IDs 65534 and 65534, after the drop\n",
+		},
+		"when root can be regained": {
+			user:       "nobody",
+			reversible: true,
+			wantCalls:  dropped,
+			wantStderr: "serve: dropping the privileges to nobody: the user ID 0 could be set back\n",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			fake := &subcommandsutil.FakeIDs{
+				UID:        tt.uid,
+				GID:        tt.gid,
+				Users:      []user.User{{Uid: "65534", Gid: "65534", Username: "nobody"}},
+				Groups:     []user.Group{{Gid: "33", Name: "www-data"}},
+				Fail:       tt.fail,
+				Reversible: tt.reversible,
+				Ignored:    tt.ignored,
+			}
+			defer subcommandsutil.SetProcessIDs(fake)()
+
+			after := func(ctx context.Context) (context.Context, error) {
+				fake.Calls = append(fake.Calls, "after")
+				if tt.afterErr != nil {
+					return nil, tt.afterErr
+				}
+				return context.WithValue(ctx, listenerKey{}, "listener"), nil
+			}
+			var execUID int
+			var listener interface{}
+			sub := testcmd.NewRecording("serve", testcmd.WithExecute(func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+				execUID, listener = fake.UID, ctx.Value(listenerKey{})
+				return subcommands.ExitSuccess
+			}))
+
+			var stderr testcmd.Buffer
+			ctx := subcommandsutil.WithOutput(context.Background(), &testcmd.Buffer{}, &stderr)
+			status, _, _ := testcmd.Run(ctx, subcommandsutil.DropPrivileges(sub, tt.user, tt.group, after))
+			if got := strings.Join(fake.Calls, ","); got != tt.wantCalls {
+				t.Fatalf("wanted the calls %q but got %q", tt.wantCalls, got)
+			}
+			if stderr.String() != tt.wantStderr {
+				t.Fatalf("wanted stderr %q but got %q", tt.wantStderr, stderr.String())
+			}
+			if tt.wantStderr != "" {
+				testcmd.AssertStatus(t, status, subcommands.ExitFailure)
+				if sub.CallCount() != 0 {
+					t.Fatalf("wanted sub not executed but got %d calls", sub.CallCount())
+				}
+				return
+			}
+			testcmd.RequireSuccess(t, status)
+			if execUID != tt.wantUID {
+				t.Fatalf("wanted sub executed as the user ID %d but got %d", tt.wantUID, execUID)
+			}
+			if !reflect.DeepEqual(listener, "listener") {
+				t.Fatalf("wanted sub executed with the context of after but got the listener %v", listener)
+			}
+		})
+	}
+}
@@ -0,0 +1,191 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+//go:build unix
+
+package subcommandsutil
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+	"syscall"
+
+	"github.com/google/subcommands"
+)
+
+// processIDs is the system calls reading and setting the user and group IDs of the process, and
+// the lookups of the users and groups, of DropPrivileges.
+type processIDs struct {
+	getuid, geteuid, getgid, getegid func() int
+
+	setgroups func(gids []int) error
+	setgid    func(gid int) error
+	setuid    func(uid int) error
+
+	lookupUser   func(name string) (*user.User, error)
+	lookupUserID func(uid string) (*user.User, error)
+	lookupGroup  func(name string) (*user.Group, error)
+}
+
+// systemIDs is the processIDs of the system, replaced in the tests. The set calls apply to every
+// thread of the process.
+var systemIDs = &processIDs{
+	getuid:       os.Getuid,
+	geteuid:      os.Geteuid,
+	getgid:       os.Getgid,
+	getegid:      os.Getegid,
+	setgroups:    syscall.Setgroups,
+	setgid:       syscall.Setgid,
+	setuid:       syscall.Setuid,
+	lookupUser:   user.Lookup,
+	lookupUserID: user.LookupId,
+	lookupGroup:  user.LookupGroup,
+}
+
+// privilegeDrop wraps a subcommands.Command so that it executes as an unprivileged user.
+type privilegeDrop struct {
+	sub   subcommands.Command
+	user  string
+	group string
+	after func(ctx context.Context) (context.Context, error)
+}
+
+// make sure privilegeDrop implements the subcommands.Command interface.
+var _ subcommands.Command = (*privilegeDrop)(nil)
+
+// DropPrivileges wraps sub so that it executes as the user name, a username or a user ID, and the
+// group, a group name or a group ID, or the primary group of the user if empty, when started as
+// root. after, unless nil, is called first while still privileged, like to bind a port below 1024,
+// and returns the execution context of sub, like carrying the listener. The supplementary groups
+// of the process are then replaced with group, and its group and user IDs set, in that order.
+// Finally, the process must not be able to get root back before sub is executed.
+//
+// Started as the user and group already, DropPrivileges only calls after. Any failure, including
+// when started as another unprivileged user, prints "NAME: dropping the privileges to USER: ERROR"
+// to Stderr and returns subcommands.ExitFailure without executing sub.
+func DropPrivileges(sub subcommands.Command, user, group string, after func(ctx context.Context) (context.Context, error)) subcommands.Command {
+	return &privilegeDrop{
+		sub:   sub,
+		user:  user,
+		group: group,
+		after: after,
+	}
+}
+
+// Name forwards to the underlying c.sub Command.
+func (c *privilegeDrop) Name() string {
+	return c.sub.Name()
+}
+
+// Usage forwards to the underlying c.sub Command.
+func (c *privilegeDrop) Usage() string {
+	return c.sub.Usage()
+}
+
+// Synopsis forwards to the underlying c.sub Command.
+func (c *privilegeDrop) Synopsis() string {
+	return c.sub.Synopsis()
+}
+
+// Unwrap returns the underlying c.sub Command.
+func (c *privilegeDrop) Unwrap() subcommands.Command {
+	return c.sub
+}
+
+// SetFlags forwards to the underlying c.sub Command.
+func (c *privilegeDrop) SetFlags(f *flag.FlagSet) {
+	c.sub.SetFlags(f)
+}
+
+// Execute calls after, drops the privileges of the process, and then forwards to the underlying
+// c.sub Command.
+func (c *privilegeDrop) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	ids := systemIDs
+	fail := func(err error) subcommands.ExitStatus {
+		fmt.Fprintf(Stderr(ctx), "%s: dropping the privileges to %s: %v\n", c.sub.Name(), c.user, err)
+		return subcommands.ExitFailure
+	}
+
+	uid, gid, err := c.lookup(ids)
+	if err != nil {
+		return fail(err)
+	}
+	already := ids.geteuid() == uid && ids.getuid() == uid && ids.getegid() == gid && ids.getgid() == gid
+	if !already && ids.geteuid() != 0 {
+		return fail(fmt.Errorf("not running as root but as the user ID %d", ids.geteuid()))
+	}
+
+	if c.after != nil {
+		actx, err := c.after(ctx)
+		if err != nil {
+			return fail(fmt.Errorf("setting up: %w", err))
+		}
+		ctx = actx
+	}
+	if !already {
+		if err := dropPrivileges(ids, uid, gid); err != nil {
+			return fail(err)
+		}
+	}
+
+	return c.sub.Execute(ctx, f, args...)
+}
+
+// lookup returns the IDs of the user and group of c.
+func (c *privilegeDrop) lookup(ids *processIDs) (uid, gid int, err error) {
+	u, err := ids.lookupUser(c.user)
+	if err != nil {
+		if u, err = ids.lookupUserID(c.user); err != nil {
+			return 0, 0, fmt.Errorf("unknown user %s", c.user)
+		}
+	}
+	if uid, err = strconv.Atoi(u.Uid); err != nil {
+		return 0, 0, fmt.Errorf("user %s: invalid user ID %q", c.user, u.Uid)
+	}
+
+	group := c.group
+	if group == "" {
+		group = u.Gid
+	} else if g, err := ids.lookupGroup(group); err == nil {
+		group = g.Gid
+	}
+	if gid, err = strconv.Atoi(group); err != nil {
+		return 0, 0, fmt.Errorf("unknown group %s", group)
+	}
+
+	return uid, gid, nil
+}
+
+// errPrivilegesRegained is the error of dropPrivileges when the process can get root back.
+var errPrivilegesRegained = errors.New("the user ID 0 could be set back")
+
+// dropPrivileges sets the supplementary groups of the process to gid, its group ID to gid and its
+// user ID to uid, and verifies that it cannot get root back.
+func dropPrivileges(ids *processIDs, uid, gid int) error {
+	// the groups first, as they can no longer be changed without root
+	if err := ids.setgroups([]int{gid}); err != nil {
+		return fmt.Errorf("setgroups %d: %w", gid, err)
+	}
+	if err := ids.setgid(gid); err != nil {
+		return fmt.Errorf("setgid %d: %w", gid, err)
+	}
+	if err := ids.setuid(uid); err != nil {
+		return fmt.Errorf("setuid %d: %w", uid, err)
+	}
+
+	if got := [4]int{ids.getuid(), ids.geteuid(), ids.getgid(), ids.getegid()}; got != [4]int{uid, uid, gid, gid} {
+		return fmt.Errorf("the real and effective user IDs are %d and %d, and group IDs %d and %d, after the drop", got[0], got[1], got[2], got[3])
+	}
+	if uid != 0 {
+		if err := ids.setuid(0); err == nil {
+			return errPrivilegesRegained
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,168 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strconv"
+
+	"github.com/google/subcommands"
+)
+
+// dryRunKey is the context key of the dry-run mode.
+type dryRunKey struct{}
+
+// WithDryRun returns a copy of ctx in dry-run mode if dryRun is true.
+func WithDryRun(ctx context.Context, dryRun bool) context.Context {
+	return context.WithValue(ctx, dryRunKey{}, dryRun)
+}
+
+// DryRun reports whether the execution of ctx is in dry-run mode. Commands check it to skip their
+// side effects.
+func DryRun(ctx context.Context) bool {
+	dryRun, _ := ctx.Value(dryRunKey{}).(bool)
+
+	return dryRun
+}
+
+// dryRunValue is the flag.Value of the -dry-run flag registered by DryRunCommand.
+type dryRunValue bool
+
+// String implements flag.Value.
+func (v *dryRunValue) String() string {
+	return strconv.FormatBool(bool(*v))
+}
+
+// Set implements flag.Value.
+func (v *dryRunValue) Set(s string) error {
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return err
+	}
+	*v = dryRunValue(b)
+
+	return nil
+}
+
+// IsBoolFlag makes the -dry-run flag a boolean flag.
+func (v *dryRunValue) IsBoolFlag() bool {
+	return true
+}
+
+// isDryRun reports whether the execution of ctx is in dry-run mode, or will be because the -dry-run
+// flag registered by DryRunCommand is set in f.
+func isDryRun(ctx context.Context, f *flag.FlagSet) bool {
+	if DryRun(ctx) {
+		return true
+	}
+	fl := f.Lookup("dry-run")
+	if fl == nil {
+		return false
+	}
+	v, ok := fl.Value.(*dryRunValue)
+
+	return ok && bool(*v)
+}
+
+// SupportsDryRun reports whether cmd, or a Command it wraps, has a SupportsDryRun method reporting
+// true. The outermost SupportsDryRun method found decides.
+func SupportsDryRun(cmd subcommands.Command) (supported bool) {
+	walkCommand(cmd, func(cmd subcommands.Command) bool {
+		s, ok := cmd.(interface{ SupportsDryRun() bool })
+		if ok {
+			supported = s.SupportsDryRun()
+		}
+		return ok
+	})
+
+	return supported
+}
+
+// DryRunOption is an option of the DryRunCommand wrapper.
+type DryRunOption interface {
+	applyDryRun(*dryRun)
+}
+
+// dryRunOptionFunc is a DryRunOption implemented by a function.
+type dryRunOptionFunc func(*dryRun)
+
+// applyDryRun implements DryRunOption.
+func (fn dryRunOptionFunc) applyDryRun(c *dryRun) { fn(c) }
+
+// WithDryRunSupportRequired makes DryRunCommand refuse to run a command in dry-run mode, returning
+// subcommands.ExitUsageError, unless the command declares its support with a SupportsDryRun method
+// reporting true.
+func WithDryRunSupportRequired() DryRunOption {
+	return dryRunOptionFunc(func(c *dryRun) {
+		c.required = true
+	})
+}
+
+// dryRun wraps a subcommands.Command so that it can run in dry-run mode.
+type dryRun struct {
+	sub      subcommands.Command
+	required bool
+
+	dryRun dryRunValue
+}
+
+// make sure dryRun implements the subcommands.Command interface.
+var _ subcommands.Command = (*dryRun)(nil)
+
+// DryRunCommand wraps sub with the -n and -dry-run flags. When set, the execution context is in
+// dry-run mode, reported by DryRun.
+func DryRunCommand(sub subcommands.Command, opts ...DryRunOption) subcommands.Command {
+	c := &dryRun{
+		sub: sub,
+	}
+	for _, opt := range opts {
+		opt.applyDryRun(c)
+	}
+
+	return c
+}
+
+// Name forwards to the underlying c.sub Command.
+func (c *dryRun) Name() string {
+	return c.sub.Name()
+}
+
+// Usage forwards to the underlying c.sub Command.
+func (c *dryRun) Usage() string {
+	return c.sub.Usage()
+}
+
+// Synopsis forwards to the underlying c.sub Command.
+func (c *dryRun) Synopsis() string {
+	return c.sub.Synopsis()
+}
+
+// Unwrap returns the underlying c.sub Command.
+func (c *dryRun) Unwrap() subcommands.Command {
+	return c.sub
+}
+
+// SetFlags forwards to the underlying c.sub Command and registers the -dry-run flag and its -n
+// alias.
+func (c *dryRun) SetFlags(f *flag.FlagSet) {
+	c.sub.SetFlags(f)
+	c.dryRun = false
+	f.Var(&c.dryRun, "dry-run", "show what would be done without doing it")
+	AliasFlag(f, "dry-run", "n")
+}
+
+// Execute forwards to the underlying c.sub Command, in dry-run mode if the -dry-run flag is set.
+func (c *dryRun) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	if !c.dryRun {
+		return c.sub.Execute(ctx, f, args...)
+	}
+	if c.required && !SupportsDryRun(c.sub) {
+		fmt.Fprintf(Stderr(ctx), "%s: -dry-run is not supported\n", c.sub.Name())
+		return subcommands.ExitUsageError
+	}
+
+	return c.sub.Execute(WithDryRun(ctx, true), f, args...)
+}
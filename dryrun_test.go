@@ -0,0 +1,147 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"context"
+	"flag"
+	"strings"
+	"testing"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+// dryRunnable is a Recording declaring its support of the dry-run mode.
+type dryRunnable struct {
+	*testcmd.Recording
+}
+
+// SupportsDryRun implements the marker checked by subcommandsutil.SupportsDryRun.
+func (dryRunnable) SupportsDryRun() bool { return true }
+
+func TestDryRunCommand(t *testing.T) {
+	tests := map[string]struct {
+		args       []string
+		supported  bool
+		opts       []subcommandsutil.DryRunOption
+		wantStatus subcommands.ExitStatus
+		wantRun    bool
+		wantDryRun bool
+	}{
+		"when the flag is not set": {
+			wantStatus: subcommands.ExitSuccess,
+			wantRun:    true,
+		},
+		"when -n is set": {
+			args:       []string{"-n"},
+			wantStatus: subcommands.ExitSuccess,
+			wantRun:    true,
+			wantDryRun: true,
+		},
+		"when -dry-run is set on a supported command": {
+			args:       []string{"-dry-run"},
+			supported:  true,
+			opts:       []subcommandsutil.DryRunOption{subcommandsutil.WithDryRunSupportRequired()},
+			wantStatus: subcommands.ExitSuccess,
+			wantRun:    true,
+			wantDryRun: true,
+		},
+		"when -dry-run is set on an unsupported command": {
+			args:       []string{"-dry-run"},
+			opts:       []subcommandsutil.DryRunOption{subcommandsutil.WithDryRunSupportRequired()},
+			wantStatus: subcommands.ExitUsageError,
+		},
+		"when the flag is not set on an unsupported command": {
+			opts:       []subcommandsutil.DryRunOption{subcommandsutil.WithDryRunSupportRequired()},
+			wantStatus: subcommands.ExitSuccess,
+			wantRun:    true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var gotDryRun bool
+			rec := testcmd.NewRecording("deploy",
+				testcmd.WithExecute(func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+					gotDryRun = subcommandsutil.DryRun(ctx)
+					return subcommands.ExitSuccess
+				}),
+			)
+			var sub subcommands.Command = rec
+			if tt.supported {
+				sub = dryRunnable{rec}
+			}
+
+			var stderr testcmd.Buffer
+			ctx := subcommandsutil.WithOutput(context.Background(), nil, &stderr)
+			status, _, err := testcmd.Run(ctx, subcommandsutil.DryRunCommand(sub, tt.opts...), tt.args...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			testcmd.AssertStatus(t, status, tt.wantStatus)
+			if got := rec.DidFinish(); got != tt.wantRun {
+				t.Fatalf("wanted the command to run %t but got %t", tt.wantRun, got)
+			}
+			if gotDryRun != tt.wantDryRun {
+				t.Fatalf("wanted dry-run %t but got %t", tt.wantDryRun, gotDryRun)
+			}
+			if tt.wantStatus == subcommands.ExitUsageError && stderr.String() != "deploy: -dry-run is not supported\n" {
+				t.Fatalf("wanted the refusal reported but got %q", stderr.String())
+			}
+		})
+	}
+}
+
+func TestDryRunCommandReused(t *testing.T) {
+	var gotDryRun bool
+	cmd := subcommandsutil.DryRunCommand(testcmd.NewRecording("deploy",
+		testcmd.WithExecute(func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+			gotDryRun = subcommandsutil.DryRun(ctx)
+			return subcommands.ExitSuccess
+		}),
+	))
+
+	for _, tt := range []struct {
+		args       []string
+		wantDryRun bool
+	}{
+		{args: []string{"-n"}, wantDryRun: true},
+		{},
+		{args: []string{"-dry-run"}, wantDryRun: true},
+		{},
+	} {
+		status, f, _ := testcmd.Run(context.Background(), cmd, tt.args...)
+		testcmd.RequireSuccess(t, status)
+		if gotDryRun != tt.wantDryRun {
+			t.Fatalf("wanted dry-run %t with the arguments %q but got %t", tt.wantDryRun, tt.args, gotDryRun)
+		}
+		if def := f.Lookup("dry-run").DefValue; def != "false" {
+			t.Fatalf("wanted the default of -dry-run false but got %q", def)
+		}
+	}
+}
+
+func TestLoggedDryRunPrefix(t *testing.T) {
+	var logs testcmd.LogRecorder
+	cmd := subcommandsutil.Logged(subcommandsutil.DryRunCommand(testcmd.NewRecording("deploy")),
+		subcommandsutil.WithLogger(&logs), subcommandsutil.WithDryRunPrefix())
+
+	status, _, err := testcmd.Run(context.Background(), cmd, "-n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	testcmd.RequireSuccess(t, status)
+
+	lines := logs.Lines()
+	if len(lines) != 2 {
+		t.Fatalf("wanted 2 log lines but got %q", lines)
+	}
+	for _, line := range lines {
+		if !strings.HasPrefix(line, "DRY-RUN deploy: ") {
+			t.Fatalf("wanted the line prefixed with DRY-RUN but got %q", line)
+		}
+	}
+}
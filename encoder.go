@@ -0,0 +1,221 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Encoder encodes the results emitted by a command run by ResultCommand.
+type Encoder interface {
+	// Encode writes results, in the order they were emitted, to w.
+	Encode(w io.Writer, results []interface{}) error
+}
+
+// EncoderFunc is an Encoder implemented by a function.
+type EncoderFunc func(w io.Writer, results []interface{}) error
+
+// Encode implements Encoder.
+func (fn EncoderFunc) Encode(w io.Writer, results []interface{}) error {
+	return fn(w, results)
+}
+
+// Tabular is implemented by the results rendering their own columns and rows in the csv and tsv
+// encoders.
+type Tabular interface {
+	// Columns returns the titles of the columns.
+	Columns() []string
+
+	// Rows returns the cells of the rows, in the order of the columns.
+	Rows() [][]string
+}
+
+var (
+	encodersMu sync.Mutex
+	encoders   = map[string]Encoder{
+		"json": EncoderFunc(encodeJSON),
+		"csv":  tableEncoder(','),
+		"tsv":  tableEncoder('\t'),
+	}
+)
+
+// RegisterEncoder registers enc as the encoder of the results named name, selected by the -output
+// flag of ResultCommand, replacing the encoder registered under name if any. The json, csv and tsv
+// encoders are registered by default.
+//
+// RegisterEncoder is usually called from an init function, before the flags are set.
+func RegisterEncoder(name string, enc Encoder) {
+	encodersMu.Lock()
+	defer encodersMu.Unlock()
+
+	encoders[name] = enc
+}
+
+// lookupEncoder returns the encoder registered under name.
+func lookupEncoder(name string) (Encoder, bool) {
+	encodersMu.Lock()
+	defer encodersMu.Unlock()
+
+	enc, ok := encoders[name]
+
+	return enc, ok
+}
+
+// encoderNames returns the sorted names of the registered encoders.
+func encoderNames() []string {
+	encodersMu.Lock()
+	defer encodersMu.Unlock()
+
+	return sortedFlagNames(encoders)
+}
+
+// encodeJSON encodes results as JSON: a single result as itself, more than one as a JSON array,
+// and none as nothing.
+func encodeJSON(w io.Writer, results []interface{}) error {
+	enc := json.NewEncoder(w)
+	switch len(results) {
+	case 0:
+		return nil
+	case 1:
+		return enc.Encode(results[0])
+	default:
+		return enc.Encode(results)
+	}
+}
+
+// tableEncoder returns an Encoder writing the results as the records of encoding/csv separated by
+// comma, after a header record of the column titles.
+//
+// A result implementing Tabular renders its columns and rows. Otherwise the results are structs,
+// maps with string keys, or slices of them, one row each: the columns of the structs are their
+// exported fields, titled by the names of their json tags if any, and the ones of the maps their
+// sorted keys.
+func tableEncoder(comma rune) Encoder {
+	return EncoderFunc(func(w io.Writer, results []interface{}) error {
+		columns, rows, err := tabulate(results)
+		if err != nil {
+			return err
+		}
+		if columns == nil {
+			return nil
+		}
+
+		cw := csv.NewWriter(w)
+		cw.Comma = comma
+		if err := cw.Write(columns); err != nil {
+			return err
+		}
+
+		return cw.WriteAll(rows)
+	})
+}
+
+// tabulate returns the columns and rows of results for tableEncoder.
+func tabulate(results []interface{}) (columns []string, rows [][]string, err error) {
+	var values []reflect.Value
+	for _, r := range results {
+		if r == nil {
+			continue
+		}
+		if t, ok := r.(Tabular); ok {
+			if columns != nil && !slices.Equal(columns, t.Columns()) {
+				return nil, nil, fmt.Errorf("results of type %T have other columns than %q", r, columns)
+			}
+			columns = t.Columns()
+			rows = append(rows, t.Rows()...)
+			continue
+		}
+		v := reflect.Indirect(reflect.ValueOf(r))
+		if v.Kind() == reflect.Slice || v.Kind() == reflect.Array {
+			for i := 0; i < v.Len(); i++ {
+				values = append(values, reflect.Indirect(v.Index(i)))
+			}
+			continue
+		}
+		values = append(values, v)
+	}
+	if len(values) == 0 {
+		return columns, rows, nil
+	}
+	if rows != nil {
+		return nil, nil, fmt.Errorf("cannot mix Tabular results with results of type %s", values[0].Type())
+	}
+
+	for _, v := range values {
+		var cols []string
+		var row []string
+		switch v.Kind() {
+		case reflect.Struct:
+			cols, row = structRow(v)
+		case reflect.Map:
+			if v.Type().Key().Kind() != reflect.String {
+				return nil, nil, fmt.Errorf("unsupported result of type %s", v.Type())
+			}
+			cols, row = mapRow(v)
+		default:
+			return nil, nil, fmt.Errorf("unsupported result of type %s", v.Type())
+		}
+		if columns != nil && !slices.Equal(columns, cols) {
+			return nil, nil, fmt.Errorf("result of type %s has other columns than %q", v.Type(), columns)
+		}
+		columns = cols
+		rows = append(rows, row)
+	}
+
+	return columns, rows, nil
+}
+
+// structRow returns the columns and the row of the struct v.
+func structRow(v reflect.Value) (columns, row []string) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name := field.Name
+		if tag, _, _ := strings.Cut(field.Tag.Get("json"), ","); tag == "-" {
+			continue
+		} else if tag != "" {
+			name = tag
+		}
+		columns = append(columns, name)
+		row = append(row, formatCell(v.Field(i)))
+	}
+
+	return columns, row
+}
+
+// mapRow returns the columns and the row of the map v with string keys.
+func mapRow(v reflect.Value) (columns, row []string) {
+	keys := v.MapKeys()
+	sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+	for _, k := range keys {
+		columns = append(columns, k.String())
+		row = append(row, formatCell(v.MapIndex(k)))
+	}
+
+	return columns, row
+}
+
+// formatCell returns the text of the value of a cell: empty for nil, and as formatted by fmt
+// otherwise.
+func formatCell(v reflect.Value) string {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+
+	return fmt.Sprint(v.Interface())
+}
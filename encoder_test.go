@@ -0,0 +1,179 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"context"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+// release is a result of the sample releases command.
+type release struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	Notes   string `json:"notes,omitempty"`
+	Secret  string `json:"-"`
+	Stable  *bool
+}
+
+// releaseList is a Tabular result of the sample releases command.
+type releaseList []release
+
+// Columns implements subcommandsutil.Tabular.
+func (l releaseList) Columns() []string { return []string{"release"} }
+
+// Rows implements subcommandsutil.Tabular.
+func (l releaseList) Rows() [][]string {
+	rows := make([][]string, len(l))
+	for i, r := range l {
+		rows[i] = []string{r.Name + "@" + r.Version}
+	}
+	return rows
+}
+
+func TestResultCommandEncoders(t *testing.T) {
+	stable := true
+	releases := []release{
+		{Name: "tool", Version: "v1.2.0", Notes: "fixes, \"quoted\"\tand tabbed", Secret: "x", Stable: &stable},
+		{Name: "lib", Version: "v0.1.0", Notes: "multi\nline"},
+	}
+
+	tests := map[string]struct {
+		results []interface{}
+		format  string
+		want    [][]string
+	}{
+		"when a slice of structs is encoded as csv": {
+			results: []interface{}{releases},
+			format:  "csv",
+			want: [][]string{
+				{"name", "version", "notes", "Stable"},
+				{"tool", "v1.2.0", "fixes, \"quoted\"\tand tabbed", "true"},
+				{"lib", "v0.1.0", "multi\nline", ""},
+			},
+		},
+		"when the structs are encoded as tsv": {
+			results: []interface{}{releases[0], &releases[1]},
+			format:  "tsv",
+			want: [][]string{
+				{"name", "version", "notes", "Stable"},
+				{"tool", "v1.2.0", "fixes, \"quoted\"\tand tabbed", "true"},
+				{"lib", "v0.1.0", "multi\nline", ""},
+			},
+		},
+		"when maps are encoded as csv": {
+			results: []interface{}{map[string]int{"b": 2, "a": 1}, map[string]int{"a": 3, "b": 4}},
+			format:  "csv",
+			want:    [][]string{{"a", "b"}, {"1", "2"}, {"3", "4"}},
+		},
+		"when a Tabular result is encoded as tsv": {
+			results: []interface{}{releaseList(releases)},
+			format:  "tsv",
+			want:    [][]string{{"release"}, {"tool@v1.2.0"}, {"lib@v0.1.0"}},
+		},
+		"when there are no results": {
+			format: "csv",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var stdout testcmd.Buffer
+			ctx := subcommandsutil.WithOutput(context.Background(), &stdout, &testcmd.Buffer{})
+
+			status, _, err := testcmd.Run(ctx, newReleasesCommand(tt.results...), "-output", tt.format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			testcmd.RequireSuccess(t, status)
+
+			r := csv.NewReader(strings.NewReader(stdout.String()))
+			if tt.format == "tsv" {
+				r.Comma = '\t'
+			}
+			got, err := r.ReadAll()
+			if err != nil {
+				t.Fatalf("wanted valid %s but got %v: %q", tt.format, err, stdout.String())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("wanted the records %q but got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+// newReleasesCommand returns a sample command emitting results.
+func newReleasesCommand(results ...interface{}) subcommands.Command {
+	return subcommandsutil.ResultCommand(testcmd.NewRecording("releases",
+		testcmd.WithExecute(func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+			for _, v := range results {
+				subcommandsutil.EmitResult(ctx, v)
+			}
+			return subcommands.ExitSuccess
+		}),
+	))
+}
+
+func TestResultCommandOutputFlag(t *testing.T) {
+	subcommandsutil.RegisterEncoder("names", subcommandsutil.EncoderFunc(func(w io.Writer, results []interface{}) error {
+		for _, r := range results {
+			fmt.Fprintln(w, r.(release).Name)
+		}
+		return nil
+	}))
+
+	tests := map[string]struct {
+		args       []string
+		wantStatus subcommands.ExitStatus
+		wantStdout string
+		wantErr    string
+	}{
+		"when a registered encoder is selected": {
+			args:       []string{"-output", "names"},
+			wantStdout: "tool\nlib\n",
+		},
+		"when json is selected": {
+			args:       []string{"-output=json"},
+			wantStdout: `[{"name":"tool","version":"v1.2.0","Stable":null},{"name":"lib","version":"v0.1.0","Stable":null}]` + "\n",
+		},
+		"when the encoder is unknown": {
+			args:       []string{"-output", "xml"},
+			wantStatus: subcommands.ExitUsageError,
+			wantErr:    `invalid value "xml" for flag -output: unknown output format; wanted one of csv, json, names, tsv`,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var stdout testcmd.Buffer
+			ctx := subcommandsutil.WithOutput(context.Background(), &stdout, &testcmd.Buffer{})
+			cmd := newReleasesCommand(release{Name: "tool", Version: "v1.2.0"}, release{Name: "lib", Version: "v0.1.0"})
+
+			f := flag.NewFlagSet("releases", flag.ContinueOnError)
+			f.SetOutput(io.Discard)
+			cmd.SetFlags(f)
+			if usage := f.Lookup("output").Usage; !strings.Contains(usage, "csv, json, names, tsv") {
+				t.Fatalf("wanted the usage to list the encoders but got %q", usage)
+			}
+
+			status, _, err := testcmd.Run(ctx, cmd, tt.args...)
+			if tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr) {
+				t.Fatalf("wanted the error %q but got %v", tt.wantErr, err)
+			}
+			testcmd.AssertStatus(t, status, tt.wantStatus)
+			if got := stdout.String(); got != tt.wantStdout {
+				t.Fatalf("wanted stdout %q but got %q", tt.wantStdout, got)
+			}
+		})
+	}
+}
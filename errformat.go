@@ -0,0 +1,196 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/google/subcommands"
+)
+
+// hintError is an error carrying a hint to fix it.
+type hintError struct {
+	err  error
+	hint string
+}
+
+// Error implements error.
+func (e *hintError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the underlying e.err error.
+func (e *hintError) Unwrap() error {
+	return e.err
+}
+
+// ErrorWithHint returns err annotated with hint, a suggestion to fix it, such as "run 'tool
+// login' first", which PrintError prints along with it.
+func ErrorWithHint(err error, hint string) error {
+	if err == nil {
+		return nil
+	}
+
+	return &hintError{err: err, hint: hint}
+}
+
+// ErrorHint returns the hint of the first error annotated by ErrorWithHint in the chain of err, or
+// "" if there is none.
+func ErrorHint(err error) string {
+	var h *hintError
+	if errors.As(err, &h) {
+		return h.hint
+	}
+
+	return ""
+}
+
+// errorFormatKey is the context key of the error format.
+type errorFormatKey struct{}
+
+// errorFormat is the value of the -error-format flag: "text" or "json".
+type errorFormat string
+
+// String implements flag.Value.
+func (v *errorFormat) String() string {
+	return string(*v)
+}
+
+// Set implements flag.Value.
+func (v *errorFormat) Set(s string) error {
+	switch s {
+	case "text", "json":
+		*v = errorFormat(s)
+		return nil
+	default:
+		return fmt.Errorf("unknown format %q, want text or json", s)
+	}
+}
+
+// jsonFailure is the failure printed by PrintError in the json error format.
+type jsonFailure struct {
+	Error      string `json:"error"`
+	Command    string `json:"command"`
+	Status     int    `json:"status"`
+	StatusName string `json:"status_name"`
+	Hint       string `json:"hint,omitempty"`
+}
+
+// isJSONErrorFormat reports whether the failures of the execution of ctx are printed as JSON.
+func isJSONErrorFormat(ctx context.Context) bool {
+	format, _ := ctx.Value(errorFormatKey{}).(errorFormat)
+
+	return format == "json"
+}
+
+// PrintError prints err, the failure of the command named name, to the Stderr of ctx, in the error
+// format selected by the -error-format flag of an ErrorFormat wrapper.
+//
+// In the text format, the default, it prints "NAME: ERROR", followed by "; HINT" if err has a hint
+// set by ErrorWithHint. In the json format, it prints a line like:
+//
+//	{"error":"no such remote","command":"remote push","status":1,"status_name":"ExitFailure","hint":"run 'tool remote add' first"}
+//
+// where command is the CommandPath of ctx, or name outside of a Group, and status is the ExitStatus
+// from StatusFromError.
+func PrintError(ctx context.Context, name string, err error) {
+	printFailure(ctx, name, err, StatusFromError(err))
+}
+
+// printFailure prints err, the failure with status of the command named name, like PrintError, and
+// reports it to a GitHubActions wrapper.
+func printFailure(ctx context.Context, name string, err error, status subcommands.ExitStatus) {
+	reportFailure(ctx, name, err, status)
+
+	hint := ErrorHint(err)
+	if !isJSONErrorFormat(ctx) {
+		if hint != "" {
+			fmt.Fprintf(Stderr(ctx), "%s: %v; %s\n", name, err, hint)
+			return
+		}
+		fmt.Fprintf(Stderr(ctx), "%s: %v\n", name, err)
+		return
+	}
+
+	command := name
+	if path := CommandPath(ctx); len(path) > 0 {
+		command = strings.Join(path, " ")
+	}
+	data, _ := json.Marshal(jsonFailure{
+		Error:      err.Error(),
+		Command:    command,
+		Status:     int(status),
+		StatusName: statusName(status),
+		Hint:       hint,
+	})
+	fmt.Fprintf(Stderr(ctx), "%s\n", data)
+}
+
+// statusName returns the name of s in StatusString, such as "ExitUsageError".
+func statusName(s subcommands.ExitStatus) string {
+	name := StatusString(s)
+	if i := strings.IndexByte(name, '('); i > 0 && s <= subcommands.ExitUsageError {
+		return name[:i]
+	}
+
+	return name
+}
+
+// errorFormatted wraps a subcommands.Command with the -error-format flag.
+type errorFormatted struct {
+	sub subcommands.Command
+
+	format errorFormat
+}
+
+// make sure errorFormatted implements the subcommands.Command interface.
+var _ subcommands.Command = (*errorFormatted)(nil)
+
+// ErrorFormat wraps sub with the -error-format flag, "text" or "json", selecting the format in
+// which PrintError prints the failures of the execution: the errors of CommandFuncE, including
+// those of UsageErrorf, and the failures of the Setup and cancellation of Cancelable. The text
+// format, the default, prints them as before.
+func ErrorFormat(sub subcommands.Command) subcommands.Command {
+	return &errorFormatted{
+		sub:    sub,
+		format: "text",
+	}
+}
+
+// Name forwards to the underlying c.sub Command.
+func (c *errorFormatted) Name() string {
+	return c.sub.Name()
+}
+
+// Usage forwards to the underlying c.sub Command.
+func (c *errorFormatted) Usage() string {
+	return c.sub.Usage()
+}
+
+// Synopsis forwards to the underlying c.sub Command.
+func (c *errorFormatted) Synopsis() string {
+	return c.sub.Synopsis()
+}
+
+// Unwrap returns the underlying c.sub Command.
+func (c *errorFormatted) Unwrap() subcommands.Command {
+	return c.sub
+}
+
+// SetFlags forwards to the underlying c.sub Command and registers the -error-format flag.
+func (c *errorFormatted) SetFlags(f *flag.FlagSet) {
+	c.sub.SetFlags(f)
+	c.format = "text"
+	f.Var(&c.format, "error-format", "print the failures in `format`, text or json")
+}
+
+// Execute forwards to the underlying c.sub Command with the selected error format.
+func (c *errorFormatted) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	return c.sub.Execute(context.WithValue(ctx, errorFormatKey{}, c.format), f, args...)
+}
@@ -0,0 +1,154 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"reflect"
+	"testing"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+// failing returns a CommandFuncE named push returning err.
+func failing(err error) subcommands.Command {
+	return subcommandsutil.CommandFuncE("push", "push changes", "push", func(ctx context.Context, f *flag.FlagSet, args ...interface{}) error {
+		return err
+	})
+}
+
+func TestErrorFormat(t *testing.T) {
+	tests := map[string]struct {
+		cmd        subcommands.Command
+		args       []string
+		wantStatus subcommands.ExitStatus
+		wantStderr string
+		wantJSON   map[string]interface{}
+	}{
+		"when the error is printed as text": {
+			cmd:        subcommandsutil.ErrorFormat(failing(errors.New("no such remote"))),
+			wantStatus: subcommands.ExitFailure,
+			wantStderr: "push: no such remote\n",
+		},
+		"when the error with a hint is printed as text": {
+			cmd:        subcommandsutil.ErrorFormat(failing(subcommandsutil.ErrorWithHint(errors.New("no such remote"), "run 'tool remote add' first"))),
+			wantStatus: subcommands.ExitFailure,
+			wantStderr: "push: no such remote; run 'tool remote add' first\n",
+		},
+		"when the error is printed as JSON": {
+			cmd:        subcommandsutil.ErrorFormat(failing(errors.New("no such remote"))),
+			args:       []string{"-error-format", "json"},
+			wantStatus: subcommands.ExitFailure,
+			wantJSON:   map[string]interface{}{"error": "no such remote", "command": "push", "status": 1.0, "status_name": "ExitFailure"},
+		},
+		"when the usage error with a hint is printed as JSON": {
+			cmd:        subcommandsutil.ErrorFormat(failing(subcommandsutil.ErrorWithHint(subcommandsutil.UsageErrorf("missing %s", "REMOTE"), "see 'tool help push'"))),
+			args:       []string{"-error-format=json"},
+			wantStatus: subcommands.ExitUsageError,
+			wantJSON:   map[string]interface{}{"error": "missing REMOTE", "command": "push", "status": 2.0, "status_name": "ExitUsageError", "hint": "see 'tool help push'"},
+		},
+		"when the setup of Cancelable fails": {
+			cmd: subcommandsutil.ErrorFormat(subcommandsutil.Cancelable(&settingUp{
+				CancelableCommand: testcmd.NewRecording("push", testcmd.WithFlags(func(f *flag.FlagSet) { f.String("cache", "", "cache mode") })),
+				ev:                &events{},
+				setupErr:          subcommandsutil.UsageErrorf("bad config"),
+			})),
+			args:       []string{"-error-format", "json"},
+			wantStatus: subcommands.ExitUsageError,
+			wantJSON:   map[string]interface{}{"error": "bad config", "command": "push", "status": 2.0, "status_name": "ExitUsageError"},
+		},
+		"when the format is unknown": {
+			cmd:        subcommandsutil.ErrorFormat(failing(nil)),
+			args:       []string{"-error-format", "yaml"},
+			wantStatus: subcommands.ExitUsageError,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var stderr testcmd.Buffer
+			ctx := subcommandsutil.WithOutput(context.Background(), nil, &stderr)
+			status, _, _ := testcmd.Run(ctx, tt.cmd, tt.args...)
+			testcmd.AssertStatus(t, status, tt.wantStatus)
+			if tt.wantJSON == nil {
+				if got := stderr.String(); got != tt.wantStderr {
+					t.Fatalf("wanted the stderr %q but got %q", tt.wantStderr, got)
+				}
+				return
+			}
+
+			var got map[string]interface{}
+			if err := json.Unmarshal([]byte(stderr.String()), &got); err != nil {
+				t.Fatalf("wanted a JSON object but got %q: %v", stderr.String(), err)
+			}
+			if !reflect.DeepEqual(got, tt.wantJSON) {
+				t.Fatalf("wanted the failure %v but got %v", tt.wantJSON, got)
+			}
+		})
+	}
+}
+
+func TestErrorFormatReused(t *testing.T) {
+	cmd := subcommandsutil.ErrorFormat(failing(errors.New("no such remote")))
+	for _, tt := range []struct {
+		args       []string
+		wantStderr string
+	}{
+		{args: []string{"-error-format", "json"}, wantStderr: `{"error":"no such remote","command":"push","status":1,"status_name":"ExitFailure"}` + "\n"},
+		{wantStderr: "push: no such remote\n"},
+	} {
+		var stderr testcmd.Buffer
+		status, _, _ := testcmd.Run(subcommandsutil.WithOutput(context.Background(), nil, &stderr), cmd, tt.args...)
+		testcmd.AssertStatus(t, status, subcommands.ExitFailure)
+		if got := stderr.String(); got != tt.wantStderr {
+			t.Fatalf("wanted the stderr %q with the arguments %q but got %q", tt.wantStderr, tt.args, got)
+		}
+	}
+}
+
+func TestErrorFormatCanceled(t *testing.T) {
+	defer testcmd.VerifyNoLeaks(t)
+
+	tcmd := testcmd.NewBlocking("push")
+	defer tcmd.Release(subcommands.ExitSuccess)
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-tcmd.Started()
+		cancel()
+	}()
+
+	var stderr testcmd.Buffer
+	logger := &testcmd.LogRecorder{}
+	cmd := subcommandsutil.ErrorFormat(subcommandsutil.Cancelable(tcmd, subcommandsutil.WithLogger(logger)))
+	status, _, _ := testcmd.Run(subcommandsutil.WithOutput(ctx, nil, &stderr), cmd, "-error-format", "json")
+	testcmd.AssertStatus(t, status, subcommands.ExitFailure)
+
+	want := `{"error":"context canceled","command":"push","status":1,"status_name":"ExitFailure"}` + "\n"
+	if got := stderr.String(); got != want {
+		t.Fatalf("wanted the stderr %q but got %q", want, got)
+	}
+	if lines := logger.Lines(); len(lines) != 0 {
+		t.Fatalf("wanted no log but got %q", lines)
+	}
+}
+
+func TestErrorFormatGroup(t *testing.T) {
+	remote := subcommandsutil.NewGroup("remote", "manage remotes")
+	remote.Register(failing(errors.New("no such remote")), "")
+
+	var stderr testcmd.Buffer
+	ctx := subcommandsutil.WithOutput(context.Background(), nil, &stderr)
+	status, _, _ := testcmd.Run(ctx, subcommandsutil.ErrorFormat(remote), "-error-format", "json", "push")
+	testcmd.AssertStatus(t, status, subcommands.ExitFailure)
+
+	want := `{"error":"no such remote","command":"remote push","status":1,"status_name":"ExitFailure"}` + "\n"
+	if got := stderr.String(); got != want {
+		t.Fatalf("wanted the stderr %q but got %q", want, got)
+	}
+}
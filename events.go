@@ -0,0 +1,320 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/google/subcommands"
+)
+
+// Event is a lifecycle event published by the wrappers to the Events of the execution context. It
+// is one of ExecutionStarted, Canceled, DisposeFinished, RetryScheduled, TimeoutWarning,
+// ExecutionFinished, RuntimeStatsReported, OutputCounted and HookFailed.
+type Event interface {
+	// CommandName returns the name of the command the event is about.
+	CommandName() string
+}
+
+// ExecutionStarted is published by Cancelable when an execution starts.
+type ExecutionStarted struct {
+	Command string
+	Time    time.Time
+}
+
+// Canceled is published by Cancelable when the context of an execution is canceled before the
+// underlying Command finished.
+type Canceled struct {
+	Command string
+	Time    time.Time
+	Err     error // the error of the context
+}
+
+// DisposeFinished is published by Cancelable when the underlying Command of a canceled execution
+// is disposed.
+type DisposeFinished struct {
+	Command string
+	Time    time.Time
+	Err     error // the error returned by Dispose
+}
+
+// RetryScheduled is published by Retry when an attempt failed and another one is scheduled.
+type RetryScheduled struct {
+	Command string
+	Time    time.Time
+	Attempt int // the failed attempt, from 1
+	Status  subcommands.ExitStatus
+	Backoff time.Duration
+}
+
+// TimeoutWarning is published by Timeout when an execution timed out.
+type TimeoutWarning struct {
+	Command string
+	Time    time.Time
+	Timeout time.Duration
+}
+
+// ExecutionFinished is published by Cancelable when an execution finishes, canceled or not. It is
+// also the summary of the execution in RunResult, hence its JSON encoding.
+type ExecutionFinished struct {
+	Command  string                 `json:"command"`
+	Time     time.Time              `json:"end"`
+	Status   subcommands.ExitStatus `json:"status"`
+	Duration time.Duration          `json:"duration_ns"`
+	Canceled bool                   `json:"canceled"`
+}
+
+// CommandName implements Event.
+func (e ExecutionStarted) CommandName() string { return e.Command }
+
+// CommandName implements Event.
+func (e Canceled) CommandName() string { return e.Command }
+
+// CommandName implements Event.
+func (e DisposeFinished) CommandName() string { return e.Command }
+
+// CommandName implements Event.
+func (e RetryScheduled) CommandName() string { return e.Command }
+
+// CommandName implements Event.
+func (e TimeoutWarning) CommandName() string { return e.Command }
+
+// CommandName implements Event.
+func (e ExecutionFinished) CommandName() string { return e.Command }
+
+// Events delivers the Events published by the wrappers to its subscribers. Attach it to the
+// execution context with WithEvents:
+//
+//	events := subcommandsutil.NewEvents()
+//	events.Subscribe(func(ev subcommandsutil.Event) {
+//		if ev, ok := ev.(subcommandsutil.Canceled); ok {
+//			metrics.Canceled(ev.Command)
+//		}
+//	})
+//	os.Exit(int(cdr.Execute(subcommandsutil.WithEvents(ctx, events))))
+//
+// The events are delivered synchronously, in the order they are published, and to the subscribers
+// in the order they subscribed; a subscriber must not publish from its function. A subscriber
+// which panics is logged to the logger of Events and does not prevent the delivery to the others.
+type Events struct {
+	logger Logger
+
+	deliverMu sync.Mutex // serializes the deliveries
+
+	mu   sync.Mutex
+	subs []*func(ev Event, telemetry bool)
+}
+
+// EventsOption is an option of NewEvents.
+type EventsOption interface {
+	applyEvents(*Events)
+}
+
+// applyEvents implements EventsOption.
+func (o LoggerOption) applyEvents(e *Events) {
+	e.logger = o.logger
+}
+
+// NewEvents returns new Events logging the panics of its subscribers to the standard logger unless
+// WithLogger is given.
+func NewEvents(opts ...EventsOption) *Events {
+	e := &Events{
+		logger: stdLogger{},
+	}
+	for _, opt := range opts {
+		opt.applyEvents(e)
+	}
+
+	return e
+}
+
+// Subscribe registers fn to be called with each published Event. The returned function
+// unregisters fn.
+func (e *Events) Subscribe(fn func(Event)) (unsubscribe func()) {
+	return e.subscribe(func(ev Event, _ bool) { fn(ev) })
+}
+
+// SubscribeTelemetry registers fn, a subscriber sending the events or their metrics over the
+// network, to be called with each Event published by an execution for which TelemetryEnabled
+// reports true. The returned function unregisters fn.
+func (e *Events) SubscribeTelemetry(fn func(Event)) (unsubscribe func()) {
+	return e.subscribe(func(ev Event, telemetry bool) {
+		if telemetry {
+			fn(ev)
+		}
+	})
+}
+
+// forward registers parent to be published the events of e, preserving the telemetry decision of
+// their executions.
+func (e *Events) forward(parent *Events) {
+	e.subscribe(parent.publish)
+}
+
+// subscribe registers fn to be called with each published Event and the telemetry decision of its
+// execution. The returned function unregisters fn.
+func (e *Events) subscribe(fn func(ev Event, telemetry bool)) (unsubscribe func()) {
+	key := &fn
+	e.mu.Lock()
+	e.subs = append(e.subs, key)
+	e.mu.Unlock()
+
+	return func() {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+
+		for i, sub := range e.subs {
+			if sub == key {
+				e.subs = append(e.subs[:i:i], e.subs[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// Publish delivers ev to the subscribers, and returns once they all returned. The subscribers
+// registered by SubscribeTelemetry are included.
+func (e *Events) Publish(ev Event) {
+	e.publish(ev, true)
+}
+
+// publish delivers ev, published by an execution with the telemetry decision telemetry, to the
+// subscribers.
+func (e *Events) publish(ev Event, telemetry bool) {
+	e.deliverMu.Lock()
+	defer e.deliverMu.Unlock()
+
+	e.mu.Lock()
+	subs := make([]*func(Event, bool), len(e.subs))
+	copy(subs, e.subs)
+	e.mu.Unlock()
+
+	for _, fn := range subs {
+		e.deliver(*fn, ev, telemetry)
+	}
+}
+
+// deliver calls fn with ev and telemetry, logging its panic.
+func (e *Events) deliver(fn func(Event, bool), ev Event, telemetry bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			e.logger.Printf("%s: event subscriber: panic: %v", ev.CommandName(), r)
+		}
+	}()
+
+	fn(ev, telemetry)
+}
+
+// JSONEventWriter returns a subscriber writing each Event to w as a line of JSON, for a log shipper
+// or another process to consume the event stream:
+//
+//	events.Subscribe(subcommandsutil.JSONEventWriter(os.Stderr))
+//
+// The line is an object whose "event" member is the name of the type of the Event, followed by its
+// fields, named by their json tag or in snake case: {"event":"Canceled","command":"build",...}. The
+// errors are encoded as their message, or null. A failure to encode or write the Event panics, so
+// that it is logged by Events.
+func JSONEventWriter(w io.Writer) func(Event) {
+	return func(ev Event) {
+		data, err := marshalEvent(ev)
+		if err == nil {
+			_, err = w.Write(data)
+		}
+		if err != nil {
+			panic(err)
+		}
+	}
+}
+
+// marshalEvent returns the JSON line of ev written by JSONEventWriter.
+func marshalEvent(ev Event) ([]byte, error) {
+	v := reflect.Indirect(reflect.ValueOf(ev))
+	var buf bytes.Buffer
+	buf.WriteString(`{"event":`)
+	name, _ := json.Marshal(v.Type().Name())
+	buf.Write(name)
+
+	member := func(key string, value interface{}) error {
+		data, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		k, _ := json.Marshal(key)
+		buf.WriteByte(',')
+		buf.Write(k)
+		buf.WriteByte(':')
+		buf.Write(data)
+		return nil
+	}
+	if v.Kind() != reflect.Struct {
+		if err := member("command", ev.CommandName()); err != nil {
+			return nil, err
+		}
+	} else {
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			key, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			switch {
+			case !field.IsExported() || key == "-":
+				continue
+			case key == "":
+				key = snakeCase(field.Name)
+			}
+			value := v.Field(i).Interface()
+			if err, ok := value.(error); ok {
+				value = err.Error()
+			}
+			if err := member(key, value); err != nil {
+				return nil, err
+			}
+		}
+	}
+	buf.WriteString("}\n")
+
+	return buf.Bytes(), nil
+}
+
+// snakeCase returns the Go identifier name in snake case, like "heap_alloc" for "HeapAlloc".
+func snakeCase(name string) string {
+	var b strings.Builder
+	runes := []rune(name)
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) && (unicode.IsLower(runes[i-1]) || i+1 < len(runes) && unicode.IsLower(runes[i+1])) {
+			b.WriteByte('_')
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+
+	return b.String()
+}
+
+// eventsKey is the context key of the Events.
+type eventsKey struct{}
+
+// WithEvents returns a copy of ctx carrying events, to which the wrappers publish their Events.
+func WithEvents(ctx context.Context, events *Events) context.Context {
+	return context.WithValue(ctx, eventsKey{}, events)
+}
+
+// EventsFromContext returns the Events carried by ctx, or nil.
+func EventsFromContext(ctx context.Context) *Events {
+	events, _ := ctx.Value(eventsKey{}).(*Events)
+	return events
+}
+
+// publish publishes ev to the Events carried by ctx, if any, with the telemetry decision of ctx.
+// It is generic so that ev is boxed into an Event only when there are Events to deliver it to.
+func publish[E Event](ctx context.Context, ev E) {
+	if events := EventsFromContext(ctx); events != nil {
+		events.publish(ev, TelemetryEnabled(ctx))
+	}
+}
@@ -0,0 +1,142 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+// describe returns a short description of ev, like "Canceled(build, context canceled)".
+func describe(ev subcommandsutil.Event) string {
+	switch ev := ev.(type) {
+	case subcommandsutil.Canceled:
+		return fmt.Sprintf("Canceled(%s, %v)", ev.Command, ev.Err)
+	case subcommandsutil.DisposeFinished:
+		return fmt.Sprintf("DisposeFinished(%s, %v)", ev.Command, ev.Err)
+	case subcommandsutil.RetryScheduled:
+		return fmt.Sprintf("RetryScheduled(%s, %d)", ev.Command, ev.Attempt)
+	case subcommandsutil.ExecutionFinished:
+		return fmt.Sprintf("ExecutionFinished(%s, %d, canceled=%t)", ev.Command, ev.Status, ev.Canceled)
+	default:
+		return fmt.Sprintf("%T(%s)", ev, ev.CommandName())[len("subcommandsutil."):]
+	}
+}
+
+func TestEventsCanceled(t *testing.T) {
+	defer testcmd.VerifyNoLeaks(t)
+
+	var ev events
+	bus := subcommandsutil.NewEvents()
+	bus.Subscribe(func(e subcommandsutil.Event) { ev.add(describe(e)) })
+
+	tcmd := testcmd.NewBlocking("build")
+	defer tcmd.Release(subcommands.ExitSuccess)
+	cmd := subcommandsutil.Cancelable(tcmd, subcommandsutil.WithLogger(&testcmd.LogRecorder{}))
+
+	ctx, cancel := context.WithCancel(subcommandsutil.WithEvents(context.Background(), bus))
+	go func() {
+		<-tcmd.Started()
+		cancel()
+	}()
+	status, _, _ := testcmd.Run(ctx, cmd)
+	testcmd.AssertStatus(t, status, subcommands.ExitFailure)
+
+	want := []string{
+		"ExecutionStarted(build)",
+		"Canceled(build, context canceled)",
+		"DisposeFinished(build, <nil>)",
+		"ExecutionFinished(build, 1, canceled=true)",
+	}
+	if got := ev.get(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("wanted the events %q but got %q", want, got)
+	}
+}
+
+func TestEventsRetry(t *testing.T) {
+	var ev events
+	bus := subcommandsutil.NewEvents()
+	bus.Subscribe(func(e subcommandsutil.Event) { ev.add(describe(e)) })
+
+	sub := testcmd.NewRecording("flaky", testcmd.WithStatus(subcommands.ExitFailure))
+	cmd := subcommandsutil.Retry(subcommandsutil.Cancelable(sub), subcommandsutil.WithAttempts(2), subcommandsutil.WithBackoff(0, 0), subcommandsutil.WithLogger(&testcmd.LogRecorder{}))
+
+	status, _, _ := testcmd.Run(subcommandsutil.WithEvents(context.Background(), bus), cmd)
+	testcmd.AssertStatus(t, status, subcommands.ExitFailure)
+
+	want := []string{
+		"ExecutionStarted(flaky)",
+		"ExecutionFinished(flaky, 1, canceled=false)",
+		"RetryScheduled(flaky, 1)",
+		"ExecutionStarted(flaky)",
+		"ExecutionFinished(flaky, 1, canceled=false)",
+	}
+	if got := ev.get(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("wanted the events %q but got %q", want, got)
+	}
+}
+
+func TestEventsSubscribe(t *testing.T) {
+	var logs testcmd.LogRecorder
+	bus := subcommandsutil.NewEvents(subcommandsutil.WithLogger(&logs))
+
+	var ev events
+	bus.Subscribe(func(e subcommandsutil.Event) { panic(errors.New("metrics unavailable")) })
+	unsubscribe := bus.Subscribe(func(e subcommandsutil.Event) { ev.add("second " + describe(e)) })
+	bus.Subscribe(func(e subcommandsutil.Event) { ev.add("third " + describe(e)) })
+
+	bus.Publish(subcommandsutil.ExecutionStarted{Command: "build"})
+	unsubscribe()
+	bus.Publish(subcommandsutil.ExecutionStarted{Command: "test"})
+
+	want := []string{"second ExecutionStarted(build)", "third ExecutionStarted(build)", "third ExecutionStarted(test)"}
+	if got := ev.get(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("wanted the events %q but got %q", want, got)
+	}
+	if !logs.Contains("build: event subscriber: panic: metrics unavailable") {
+		t.Fatalf("wanted the panic of the subscriber logged but got %q", logs.Lines())
+	}
+}
+
+func TestJSONEventWriter(t *testing.T) {
+	var logs testcmd.LogRecorder
+	var buf testcmd.Buffer
+	bus := subcommandsutil.NewEvents(subcommandsutil.WithLogger(&logs))
+	bus.Subscribe(subcommandsutil.JSONEventWriter(&buf))
+
+	at := time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)
+	bus.Publish(subcommandsutil.Canceled{Command: "build", Time: at, Err: context.Canceled})
+	bus.Publish(subcommandsutil.DisposeFinished{Command: "build", Time: at})
+	bus.Publish(subcommandsutil.ExecutionFinished{Command: "build", Time: at, Status: subcommands.ExitFailure, Duration: time.Second, Canceled: true})
+	bus.Publish(subcommandsutil.HookFailed{Time: at, Phase: "exit", Err: errors.New("flush failed"), Policy: subcommandsutil.FailCommand})
+
+	want := strings.Join([]string{
+		`{"event":"Canceled","command":"build","time":"2021-01-02T03:04:05Z","err":"context canceled"}`,
+		`{"event":"DisposeFinished","command":"build","time":"2021-01-02T03:04:05Z","err":null}`,
+		`{"event":"ExecutionFinished","command":"build","end":"2021-01-02T03:04:05Z","status":1,"duration_ns":1000000000,"canceled":true}`,
+		fmt.Sprintf(`{"event":"HookFailed","command":"","time":"2021-01-02T03:04:05Z","phase":"exit","err":"flush failed","policy":%d}`, subcommandsutil.FailCommand),
+	}, "\n") + "\n"
+	if got := buf.String(); got != want {
+		t.Fatalf("wanted the lines\n%s\nbut got\n%s", want, got)
+	}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if !json.Valid([]byte(line)) {
+			t.Fatalf("wanted a line of JSON but got %q", line)
+		}
+	}
+	if lines := logs.Lines(); len(lines) != 0 {
+		t.Fatalf("wanted no failure logged but got %q", lines)
+	}
+}
@@ -0,0 +1,203 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"context"
+	"flag"
+	"math/rand"
+	"time"
+
+	"github.com/google/subcommands"
+)
+
+// OverlapPolicy decides what Every does when a run outlasts the interval.
+type OverlapPolicy int
+
+const (
+	// OverlapSkip skips the runs whose time passed during the long run, and waits for the next
+	// time on the schedule.
+	OverlapSkip OverlapPolicy = iota
+
+	// OverlapDelay starts the delayed run as soon as the long run finishes.
+	OverlapDelay
+)
+
+// EveryOption is an option of the Every wrapper.
+type EveryOption interface {
+	applyEvery(*every)
+}
+
+// everyOptionFunc is an EveryOption implemented by a function.
+type everyOptionFunc func(*every)
+
+// applyEvery implements EveryOption.
+func (fn everyOptionFunc) applyEvery(c *every) { fn(c) }
+
+// applyEvery implements EveryOption.
+func (o LoggerOption) applyEvery(c *every) {
+	c.logger = o.logger
+}
+
+// WithJitter makes Every wait up to max longer, at random, before each run after the first.
+func WithJitter(max time.Duration) EveryOption {
+	return everyOptionFunc(func(c *every) {
+		c.jitter = max
+	})
+}
+
+// WithMaxRuns makes Every stop after n runs. The default is to run until the context is done.
+func WithMaxRuns(n int) EveryOption {
+	return everyOptionFunc(func(c *every) {
+		c.maxRuns = n
+	})
+}
+
+// WithStopOnFailure makes Every stop at the first run returning a status other than
+// subcommands.ExitSuccess.
+func WithStopOnFailure() EveryOption {
+	return everyOptionFunc(func(c *every) {
+		c.stopOnFailure = true
+	})
+}
+
+// WithOverlap sets what Every does when a run outlasts the interval. The default is OverlapSkip.
+func WithOverlap(policy OverlapPolicy) EveryOption {
+	return everyOptionFunc(func(c *every) {
+		c.overlap = policy
+	})
+}
+
+// every wraps a CancelableCommand so that it runs on an interval.
+type every struct {
+	sub    CancelableCommand
+	logger Logger
+
+	jitter        time.Duration
+	maxRuns       int
+	stopOnFailure bool
+	overlap       OverlapPolicy
+
+	interval time.Duration
+}
+
+// make sure every implements the CancelableCommand interface.
+var _ CancelableCommand = (*every)(nil)
+
+// Every wraps sub with the -every flag. When set, sub runs every interval, measured on the Clock of
+// the execution context, until the context is done, or until the limits set by WithMaxRuns and
+// WithStopOnFailure are reached. The runs never overlap; a run outlasting the interval is handled
+// per WithOverlap, the skipped runs being logged to the standard logger unless WithLogger is given.
+//
+// When the context is done while waiting for the next run, the status of the last run is returned.
+// When it is done during a run, sub is disposed without waiting for it, the context's error is
+// logged and subcommands.ExitFailure is returned, as done by Cancelable.
+func Every(sub CancelableCommand, opts ...EveryOption) CancelableCommand {
+	c := &every{
+		sub:    sub,
+		logger: stdLogger{},
+	}
+	for _, opt := range opts {
+		opt.applyEvery(c)
+	}
+
+	return c
+}
+
+// Name forwards to the underlying c.sub Command.
+func (c *every) Name() string {
+	return c.sub.Name()
+}
+
+// Usage forwards to the underlying c.sub Command.
+func (c *every) Usage() string {
+	return c.sub.Usage()
+}
+
+// Synopsis forwards to the underlying c.sub Command.
+func (c *every) Synopsis() string {
+	return c.sub.Synopsis()
+}
+
+// Unwrap returns the underlying c.sub Command.
+func (c *every) Unwrap() subcommands.Command {
+	return c.sub
+}
+
+// SetFlags forwards to the underlying c.sub Command and registers the -every flag.
+func (c *every) SetFlags(f *flag.FlagSet) {
+	c.sub.SetFlags(f)
+	f.DurationVar(&c.interval, "every", 0, "run again every `duration` until interrupted")
+}
+
+// Dispose forwards to the underlying c.sub Command.
+func (c *every) Dispose() error {
+	return c.sub.Dispose()
+}
+
+// Execute forwards to the underlying c.sub Command every interval, if the -every flag is set.
+func (c *every) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	if c.interval <= 0 {
+		return c.sub.Execute(ctx, f, args...)
+	}
+
+	clk := ClockFromContext(ctx)
+	status := subcommands.ExitSuccess
+	for runs := 1; ; runs++ {
+		start := clk.Now()
+		s, ok := c.run(ctx, f, args...)
+		if !ok {
+			return subcommands.ExitFailure
+		}
+		status = s
+		if (c.stopOnFailure && status != subcommands.ExitSuccess) || (c.maxRuns > 0 && runs >= c.maxRuns) {
+			return status
+		}
+
+		next := start.Add(c.interval)
+		if now := clk.Now(); now.After(next) {
+			switch c.overlap {
+			case OverlapDelay:
+				next = now
+			default:
+				skipped := int(now.Sub(next)/c.interval) + 1
+				if now.Sub(next)%c.interval == 0 {
+					skipped--
+				}
+				next = next.Add(time.Duration(skipped) * c.interval)
+				if skipped > 0 {
+					contextLogger(ctx, c.logger).Printf("%s: run took %v, skipping %d runs", c.sub.Name(), now.Sub(start), skipped)
+				}
+			}
+		}
+
+		wait := next.Sub(clk.Now())
+		if c.jitter > 0 {
+			wait += time.Duration(rand.Int63n(int64(c.jitter)))
+		}
+		if sleep(ctx, clk, wait) != nil {
+			return status
+		}
+	}
+}
+
+// run executes the underlying c.sub Command once. It reports false if the context was done before
+// c.sub returned, in which case c.sub is disposed.
+func (c *every) run(ctx context.Context, f *flag.FlagSet, args ...interface{}) (subcommands.ExitStatus, bool) {
+	// buffered so that the goroutine exits even when nobody receives after cancellation
+	ch := make(chan subcommands.ExitStatus, 1)
+	go func() {
+		ch <- c.sub.Execute(ctx, f, args...)
+	}()
+
+	select {
+	case <-ctx.Done():
+		_ = c.sub.Dispose()
+		contextLogger(ctx, c.logger).Printf("%s: %v", c.sub.Name(), ctx.Err())
+		return subcommands.ExitFailure, false
+
+	case s := <-ch:
+		return s, true
+	}
+}
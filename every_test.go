@@ -0,0 +1,179 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"context"
+	"flag"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+func TestEvery(t *testing.T) {
+	start := time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := map[string]struct {
+		args       []string
+		opts       []subcommandsutil.EveryOption
+		durations  []time.Duration // how long each run advances the clock
+		statuses   []subcommands.ExitStatus
+		wantStatus subcommands.ExitStatus
+		wantStarts []time.Duration // the start times of the runs after start
+		wantLogs   []string
+	}{
+		"when the flag is not set": {
+			durations:  []time.Duration{time.Second},
+			wantStatus: subcommands.ExitSuccess,
+			wantStarts: []time.Duration{0},
+		},
+		"when the runs are shorter than the interval": {
+			args:       []string{"-every=10s"},
+			opts:       []subcommandsutil.EveryOption{subcommandsutil.WithMaxRuns(3)},
+			durations:  []time.Duration{time.Second, 2 * time.Second, time.Second},
+			wantStatus: subcommands.ExitSuccess,
+			wantStarts: []time.Duration{0, 10 * time.Second, 20 * time.Second},
+		},
+		"when skipping the runs overlapped by a long run": {
+			args:       []string{"-every=10s"},
+			opts:       []subcommandsutil.EveryOption{subcommandsutil.WithMaxRuns(2)},
+			durations:  []time.Duration{25 * time.Second, time.Second},
+			wantStatus: subcommands.ExitSuccess,
+			wantStarts: []time.Duration{0, 30 * time.Second},
+			wantLogs:   []string{"cleanup: run took 25s, skipping 2 runs"},
+		},
+		"when delaying the run overlapped by a long run": {
+			args:       []string{"-every=10s"},
+			opts:       []subcommandsutil.EveryOption{subcommandsutil.WithMaxRuns(3), subcommandsutil.WithOverlap(subcommandsutil.OverlapDelay)},
+			durations:  []time.Duration{25 * time.Second, time.Second, time.Second},
+			wantStatus: subcommands.ExitSuccess,
+			wantStarts: []time.Duration{0, 25 * time.Second, 35 * time.Second},
+		},
+		"when stopping on failure": {
+			args:       []string{"-every=10s"},
+			opts:       []subcommandsutil.EveryOption{subcommandsutil.WithStopOnFailure()},
+			durations:  []time.Duration{time.Second, time.Second},
+			statuses:   []subcommands.ExitStatus{subcommands.ExitSuccess, subcommands.ExitFailure},
+			wantStatus: subcommands.ExitFailure,
+			wantStarts: []time.Duration{0, 10 * time.Second},
+		},
+		"when continuing after a failure": {
+			args:       []string{"-every=10s"},
+			opts:       []subcommandsutil.EveryOption{subcommandsutil.WithMaxRuns(2)},
+			durations:  []time.Duration{time.Second, time.Second},
+			statuses:   []subcommands.ExitStatus{subcommands.ExitFailure, subcommands.ExitSuccess},
+			wantStatus: subcommands.ExitSuccess,
+			wantStarts: []time.Duration{0, 10 * time.Second},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			defer testcmd.VerifyNoLeaks(t)
+
+			clk := testcmd.NewFakeClock(start)
+			var (
+				mu     sync.Mutex
+				starts []time.Duration
+			)
+			sub := testcmd.NewRecording("cleanup", testcmd.WithExecute(func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+				mu.Lock()
+				i := len(starts)
+				starts = append(starts, clk.Now().Sub(start))
+				mu.Unlock()
+
+				clk.Advance(tt.durations[i])
+				if i < len(tt.statuses) {
+					return tt.statuses[i]
+				}
+				return subcommands.ExitSuccess
+			}))
+			var logs testcmd.LogRecorder
+			cmd := subcommandsutil.Every(sub, append(tt.opts, subcommandsutil.WithLogger(&logs))...)
+
+			done := make(chan subcommands.ExitStatus, 1)
+			go func() {
+				status, _, _ := testcmd.Run(subcommandsutil.WithClock(context.Background(), clk), cmd, tt.args...)
+				done <- status
+			}()
+
+			var status subcommands.ExitStatus
+			for finished := false; !finished; {
+				select {
+				case status = <-done:
+					finished = true
+				case <-time.After(time.Millisecond):
+					if clk.Waiters() > 0 {
+						clk.Advance(time.Second)
+					}
+				}
+			}
+
+			testcmd.AssertStatus(t, status, tt.wantStatus)
+			if !reflect.DeepEqual(starts, tt.wantStarts) {
+				t.Fatalf("wanted the runs to start at %v but got %v", tt.wantStarts, starts)
+			}
+			if got := logs.Lines(); len(got) != len(tt.wantLogs) || (len(got) > 0 && !reflect.DeepEqual(got, tt.wantLogs)) {
+				t.Fatalf("wanted the logs %q but got %q", tt.wantLogs, got)
+			}
+		})
+	}
+}
+
+func TestEveryCanceled(t *testing.T) {
+	t.Run("when canceled while waiting for the next run", func(t *testing.T) {
+		defer testcmd.VerifyNoLeaks(t)
+
+		clk := testcmd.NewFakeClock(time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC))
+		ctx, cancel := context.WithCancel(subcommandsutil.WithClock(context.Background(), clk))
+		defer cancel()
+		sub := testcmd.NewRecording("cleanup", testcmd.WithStatus(subcommands.ExitUsageError))
+
+		done := make(chan subcommands.ExitStatus, 1)
+		go func() {
+			status, _, _ := testcmd.Run(ctx, subcommandsutil.Every(sub, subcommandsutil.WithLogger(&testcmd.LogRecorder{})), "-every=1m")
+			done <- status
+		}()
+		clk.BlockUntil(1)
+		cancel()
+
+		testcmd.AssertStatus(t, <-done, subcommands.ExitUsageError)
+		if sub.CallCount() != 1 {
+			t.Fatalf("wanted a single run but got %d", sub.CallCount())
+		}
+		if sub.DisposeCount() != 0 {
+			t.Fatalf("wanted no run to be disposed but got %d", sub.DisposeCount())
+		}
+	})
+
+	t.Run("when canceled during a run", func(t *testing.T) {
+		defer testcmd.VerifyNoLeaks(t)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		sub := testcmd.NewBlocking("cleanup")
+		defer sub.Release(subcommands.ExitSuccess)
+
+		var logs testcmd.LogRecorder
+		done := make(chan subcommands.ExitStatus, 1)
+		go func() {
+			status, _, _ := testcmd.Run(ctx, subcommandsutil.Every(sub, subcommandsutil.WithLogger(&logs)), "-every=1m")
+			done <- status
+		}()
+		<-sub.Started()
+		cancel()
+
+		testcmd.AssertStatus(t, <-done, subcommands.ExitFailure)
+		if sub.DisposeCount() != 1 {
+			t.Fatalf("wanted the running execution to be disposed once but got %d", sub.DisposeCount())
+		}
+		if want := "cleanup: context canceled"; !logs.Contains(want) {
+			t.Fatalf("wanted the logs to hold %q but got %q", want, logs.Lines())
+		}
+	})
+}
@@ -0,0 +1,65 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"context"
+	"flag"
+
+	"github.com/google/subcommands"
+)
+
+// examplesCommand wraps a subcommands.Command so that it provides examples.
+type examplesCommand struct {
+	sub      subcommands.Command
+	examples []Example
+}
+
+// make sure examplesCommand implements the subcommands.Command interface.
+var _ subcommands.Command = (*examplesCommand)(nil)
+
+// WithExamples wraps sub so that it is an Exampler providing examples, for the commands which do
+// not implement Exampler themselves, like the commands of CommandFunc. The examples are rendered
+// by ExplainCommand, WriteDocs and ManCommand.
+func WithExamples(sub subcommands.Command, examples ...Example) subcommands.Command {
+	return &examplesCommand{
+		sub:      sub,
+		examples: examples,
+	}
+}
+
+// Name forwards to the underlying c.sub Command.
+func (c *examplesCommand) Name() string {
+	return c.sub.Name()
+}
+
+// Usage forwards to the underlying c.sub Command.
+func (c *examplesCommand) Usage() string {
+	return c.sub.Usage()
+}
+
+// Synopsis forwards to the underlying c.sub Command.
+func (c *examplesCommand) Synopsis() string {
+	return c.sub.Synopsis()
+}
+
+// Unwrap returns the underlying c.sub Command.
+func (c *examplesCommand) Unwrap() subcommands.Command {
+	return c.sub
+}
+
+// Examples implements Exampler.
+func (c *examplesCommand) Examples() []Example {
+	return c.examples
+}
+
+// SetFlags forwards to the underlying c.sub Command.
+func (c *examplesCommand) SetFlags(f *flag.FlagSet) {
+	c.sub.SetFlags(f)
+}
+
+// Execute forwards to the underlying c.sub Command.
+func (c *examplesCommand) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	return c.sub.Execute(ctx, f, args...)
+}
@@ -0,0 +1,54 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"testing"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+func TestWithExamples(t *testing.T) {
+	tests := map[string]struct {
+		examples   []subcommandsutil.Example
+		goldenPath string
+	}{
+		"when the command has examples": {
+			examples: []subcommandsutil.Example{
+				{Description: "fetch a single remote", Command: "fetch origin"},
+				{Description: "fetch every remote, pruning the deleted branches", Command: "fetch -all \\\n  -prune"},
+			},
+			goldenPath: "testdata/examples.golden",
+		},
+		"when the command has no examples": {
+			goldenPath: "testdata/examples_none.golden",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			sub := testcmd.NewRecording("fetch",
+				testcmd.WithUsage("fetch [-all] [REMOTE]:\n  Fetch the branches of REMOTE.\n"),
+				testcmd.WithFlags(func(f *flag.FlagSet) {
+					f.Bool("all", false, "fetch every remote")
+					f.Bool("prune", false, "prune the deleted branches")
+				}),
+			)
+			cmd := subcommandsutil.WithExamples(sub, tt.examples...)
+
+			var buf bytes.Buffer
+			subcommandsutil.ExplainCommand(&buf, cmd)
+			testcmd.Golden(t, buf.String(), tt.goldenPath)
+
+			status, _, _ := testcmd.Run(context.Background(), cmd, "-all")
+			testcmd.RequireSuccess(t, status)
+			if sub.CallCount() != 1 {
+				t.Fatalf("wanted the execution to be forwarded once but got %d", sub.CallCount())
+			}
+		})
+	}
+}
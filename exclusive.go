@@ -0,0 +1,229 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/subcommands"
+)
+
+// lockPollInterval is the interval at which a waiting Exclusive retries to take its lock.
+const lockPollInterval = 100 * time.Millisecond
+
+// ExclusiveOption is an option of the Exclusive wrapper.
+type ExclusiveOption interface {
+	applyExclusive(*exclusive)
+}
+
+// exclusiveOptionFunc is an ExclusiveOption implemented by a function.
+type exclusiveOptionFunc func(*exclusive)
+
+// applyExclusive implements ExclusiveOption.
+func (fn exclusiveOptionFunc) applyExclusive(c *exclusive) { fn(c) }
+
+// WithLockWait makes Exclusive wait up to timeout, measured on the Clock of the execution context,
+// for another instance to release the lock instead of failing immediately.
+func WithLockWait(timeout time.Duration) ExclusiveOption {
+	return exclusiveOptionFunc(func(c *exclusive) {
+		c.wait = timeout
+	})
+}
+
+// exclusive wraps a CancelableCommand so that only one instance of it runs at a time.
+type exclusive struct {
+	sub  CancelableCommand
+	path string
+	wait time.Duration
+
+	mu   sync.Mutex
+	file *os.File // the locked file of the running execution
+}
+
+// make sure exclusive implements the CancelableCommand interface.
+var _ CancelableCommand = (*exclusive)(nil)
+
+// Exclusive wraps sub so that it runs only while holding the advisory lock of the file at lockPath,
+// a flock on unix and a LockFileEx lock on windows. The file and its parent directories are created,
+// and the pid of the holder is written into it.
+//
+// When another instance holds the lock, Exclusive fails immediately with subcommands.ExitFailure,
+// reporting its pid, unless WithLockWait is given. A lock file left with the pid of a process which
+// is no longer running, and no lock held, is taken over with a warning on Stderr.
+//
+// The lock is released when Execute returns, even by a panic, or by Dispose when a Cancelable wrapper
+// stops waiting for sub; Dispose also forwards to the Dispose method of sub.
+func Exclusive(sub CancelableCommand, lockPath string, opts ...ExclusiveOption) CancelableCommand {
+	c := &exclusive{
+		sub:  sub,
+		path: lockPath,
+	}
+	for _, opt := range opts {
+		opt.applyExclusive(c)
+	}
+
+	return c
+}
+
+// Name forwards to the underlying c.sub Command.
+func (c *exclusive) Name() string {
+	return c.sub.Name()
+}
+
+// Usage forwards to the underlying c.sub Command.
+func (c *exclusive) Usage() string {
+	return c.sub.Usage()
+}
+
+// Synopsis forwards to the underlying c.sub Command.
+func (c *exclusive) Synopsis() string {
+	return c.sub.Synopsis()
+}
+
+// Unwrap returns the underlying c.sub Command.
+func (c *exclusive) Unwrap() subcommands.Command {
+	return c.sub
+}
+
+// SetFlags forwards to the underlying c.sub Command.
+func (c *exclusive) SetFlags(f *flag.FlagSet) {
+	c.sub.SetFlags(f)
+}
+
+// Dispose releases the lock of the running execution and forwards to the underlying c.sub Command.
+func (c *exclusive) Dispose() error {
+	c.release()
+
+	return c.sub.Dispose()
+}
+
+// Execute takes the lock and forwards to the underlying c.sub Command.
+func (c *exclusive) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	file, err := os.OpenFile(c.path, os.O_RDWR|os.O_CREATE, 0o644)
+	if os.IsNotExist(err) {
+		if err = os.MkdirAll(filepath.Dir(c.path), 0o755); err == nil {
+			file, err = os.OpenFile(c.path, os.O_RDWR|os.O_CREATE, 0o644)
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(Stderr(ctx), "%s: opening lock file: %v\n", c.sub.Name(), err)
+		return subcommands.ExitFailure
+	}
+
+	if status, ok := c.lock(ctx, file); !ok {
+		file.Close()
+		return status
+	}
+	c.mu.Lock()
+	c.file = file
+	c.mu.Unlock()
+	defer c.release()
+
+	return c.sub.Execute(ctx, f, args...)
+}
+
+// lock takes the lock of file, waiting for it if WithLockWait was given. It reports false, with the
+// status to return, if the lock was not taken.
+func (c *exclusive) lock(ctx context.Context, file *os.File) (subcommands.ExitStatus, bool) {
+	clk := ClockFromContext(ctx)
+	deadline := clk.Now().Add(c.wait)
+	for {
+		ok, err := tryLockFile(file)
+		if err != nil {
+			fmt.Fprintf(Stderr(ctx), "%s: locking %s: %v\n", c.sub.Name(), c.path, err)
+			return subcommands.ExitFailure, false
+		}
+		pid := readLockPid(file)
+		if ok {
+			if pid != 0 && pid != os.Getpid() && !processAlive(pid) {
+				fmt.Fprintf(Stderr(ctx), "warning: %s: taking over the stale lock of pid %d\n", c.sub.Name(), pid)
+			}
+			if err := writeLockPid(file); err != nil {
+				_ = unlockFile(file)
+				fmt.Fprintf(Stderr(ctx), "%s: writing lock file: %v\n", c.sub.Name(), err)
+				return subcommands.ExitFailure, false
+			}
+			return subcommands.ExitSuccess, true
+		}
+
+		remaining := deadline.Sub(clk.Now())
+		if remaining <= 0 {
+			fmt.Fprintf(Stderr(ctx), "%s: %s", c.sub.Name(), runningMessage(pid))
+			if c.wait > 0 {
+				fmt.Fprintf(Stderr(ctx), "; gave up waiting after %v", c.wait)
+			}
+			fmt.Fprintln(Stderr(ctx))
+			return subcommands.ExitFailure, false
+		}
+		if err := sleep(ctx, clk, min(remaining, lockPollInterval)); err != nil {
+			fmt.Fprintf(Stderr(ctx), "%s: %v\n", c.sub.Name(), err)
+			return subcommands.ExitFailure, false
+		}
+	}
+}
+
+// release empties the lock file of the running execution, releases its lock and closes it, once.
+func (c *exclusive) release() {
+	c.mu.Lock()
+	file := c.file
+	c.file = nil
+	c.mu.Unlock()
+	if file == nil {
+		return
+	}
+
+	_ = file.Truncate(0)
+	_ = unlockFile(file)
+	file.Close()
+}
+
+// runningMessage describes the instance holding the lock, with the pid read from the lock file.
+func runningMessage(pid int) string {
+	if pid == 0 {
+		return "another instance is running"
+	}
+
+	return fmt.Sprintf("another instance is running (pid %d)", pid)
+}
+
+// readLockPid returns the pid written into the lock file, or 0 if it holds none.
+func readLockPid(file *os.File) int {
+	data, err := io.ReadAll(io.NewSectionReader(file, 0, 32))
+	if err != nil {
+		return 0
+	}
+
+	return parsePid(data)
+}
+
+// parsePid returns the pid written in data, or 0 if data holds none.
+func parsePid(data []byte) int {
+	pid, err := strconv.Atoi(string(bytes.TrimSpace(data)))
+	if err != nil || pid <= 0 {
+		return 0
+	}
+
+	return pid
+}
+
+// writeLockPid replaces the content of the lock file with the pid of the process.
+func writeLockPid(file *os.File) error {
+	if err := file.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := file.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0); err != nil {
+		return err
+	}
+
+	return file.Sync()
+}
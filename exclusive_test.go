@@ -0,0 +1,230 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+// holdLock runs an Exclusive Blocking command on path in the background until it holds the lock.
+// The returned function releases it and waits for the run to finish.
+func holdLock(t *testing.T, path string) (holder *testcmd.Blocking, release func()) {
+	t.Helper()
+
+	holder = testcmd.NewBlocking("sync")
+	done := make(chan subcommands.ExitStatus, 1)
+	go func() {
+		status, _, _ := testcmd.Run(context.Background(), subcommandsutil.Exclusive(holder, path))
+		done <- status
+	}()
+	<-holder.Started()
+
+	return holder, func() {
+		holder.Release(subcommands.ExitSuccess)
+		testcmd.RequireSuccess(t, <-done)
+	}
+}
+
+func TestExclusive(t *testing.T) {
+	tests := map[string]struct {
+		opts       []subcommandsutil.ExclusiveOption
+		wantStderr string
+	}{
+		"when failing immediately": {
+			wantStderr: fmt.Sprintf("sync: another instance is running (pid %d)\n", os.Getpid()),
+		},
+		"when waiting times out": {
+			opts:       []subcommandsutil.ExclusiveOption{subcommandsutil.WithLockWait(150 * time.Millisecond)},
+			wantStderr: fmt.Sprintf("sync: another instance is running (pid %d); gave up waiting after 150ms\n", os.Getpid()),
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			defer testcmd.VerifyNoLeaks(t)
+
+			path := filepath.Join(t.TempDir(), "run", "sync.lock")
+			_, release := holdLock(t, path)
+			defer release()
+
+			contender := testcmd.NewRecording("sync")
+			var stdout, stderr testcmd.Buffer
+			ctx := subcommandsutil.WithOutput(context.Background(), &stdout, &stderr)
+			status, _, err := testcmd.Run(ctx, subcommandsutil.Exclusive(contender, path, tt.opts...))
+			if err != nil {
+				t.Fatal(err)
+			}
+			testcmd.AssertStatus(t, status, subcommands.ExitFailure)
+			if stderr.String() != tt.wantStderr {
+				t.Fatalf("wanted stderr %q but got %q", tt.wantStderr, stderr.String())
+			}
+			if contender.CallCount() != 0 {
+				t.Fatalf("wanted the contender not to run but got %d calls", contender.CallCount())
+			}
+		})
+	}
+}
+
+func TestExclusiveWait(t *testing.T) {
+	defer testcmd.VerifyNoLeaks(t)
+
+	path := filepath.Join(t.TempDir(), "sync.lock")
+	_, release := holdLock(t, path)
+
+	contender := testcmd.NewRecording("sync", testcmd.WithExecute(func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Error(err)
+		}
+		if want := fmt.Sprintf("%d\n", os.Getpid()); string(data) != want {
+			t.Errorf("wanted the lock file to hold %q but got %q", want, data)
+		}
+		return subcommands.ExitSuccess
+	}))
+	done := make(chan subcommands.ExitStatus, 1)
+	go func() {
+		status, _, _ := testcmd.Run(context.Background(), subcommandsutil.Exclusive(contender, path, subcommandsutil.WithLockWait(time.Minute)))
+		done <- status
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	if contender.CallCount() != 0 {
+		t.Fatal("wanted the contender to wait for the lock but it ran")
+	}
+	release()
+	testcmd.RequireSuccess(t, <-done)
+	if contender.CallCount() != 1 {
+		t.Fatalf("wanted the contender to run once but got %d calls", contender.CallCount())
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 0 {
+		t.Fatalf("wanted the released lock file to be empty but got %q", data)
+	}
+}
+
+func TestExclusiveWaitCanceled(t *testing.T) {
+	defer testcmd.VerifyNoLeaks(t)
+
+	path := filepath.Join(t.TempDir(), "sync.lock")
+	_, release := holdLock(t, path)
+	defer release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	var stdout, stderr testcmd.Buffer
+	ctx = subcommandsutil.WithOutput(ctx, &stdout, &stderr)
+	status, _, err := testcmd.Run(ctx, subcommandsutil.Exclusive(testcmd.NewRecording("sync"), path, subcommandsutil.WithLockWait(time.Minute)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	testcmd.AssertStatus(t, status, subcommands.ExitFailure)
+	if want := "sync: context deadline exceeded\n"; stderr.String() != want {
+		t.Fatalf("wanted stderr %q but got %q", want, stderr.String())
+	}
+}
+
+// exitedPid returns the pid of a process which is no longer running.
+func exitedPid(t *testing.T) int {
+	t.Helper()
+
+	exited := exec.Command(os.Args[0], "-test.run=^$")
+	if err := exited.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	return exited.Process.Pid
+}
+
+func TestExclusiveStaleLock(t *testing.T) {
+	pid := exitedPid(t)
+
+	path := filepath.Join(t.TempDir(), "sync.lock")
+	if err := os.WriteFile(path, []byte(fmt.Sprintf("%d\n", pid)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr testcmd.Buffer
+	ctx := subcommandsutil.WithOutput(context.Background(), &stdout, &stderr)
+	status, _, err := testcmd.Run(ctx, subcommandsutil.Exclusive(testcmd.NewRecording("sync"), path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	testcmd.RequireSuccess(t, status)
+	if want := fmt.Sprintf("warning: sync: taking over the stale lock of pid %d\n", pid); stderr.String() != want {
+		t.Fatalf("wanted stderr %q but got %q", want, stderr.String())
+	}
+}
+
+func TestExclusiveReleasesOnPanic(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sync.lock")
+	panicking := subcommandsutil.Exclusive(testcmd.NewRecording("sync", testcmd.WithExecute(func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+		panic("boom")
+	})), path)
+
+	func() {
+		defer func() {
+			if r := recover(); r != "boom" {
+				t.Fatalf("wanted the panic %q but got %v", "boom", r)
+			}
+		}()
+		testcmd.Run(context.Background(), panicking)
+	}()
+
+	status, _, err := testcmd.Run(context.Background(), subcommandsutil.Exclusive(testcmd.NewRecording("sync"), path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	testcmd.RequireSuccess(t, status)
+}
+
+func TestExclusiveDispose(t *testing.T) {
+	defer testcmd.VerifyNoLeaks(t)
+
+	path := filepath.Join(t.TempDir(), "sync.lock")
+	holder := testcmd.NewBlocking("sync")
+	cmd := subcommandsutil.Exclusive(holder, path)
+	done := make(chan subcommands.ExitStatus, 1)
+	go func() {
+		status, _, _ := testcmd.Run(context.Background(), cmd)
+		done <- status
+	}()
+	defer func() {
+		holder.Release(subcommands.ExitSuccess)
+		<-done
+	}()
+	<-holder.Started()
+
+	if err := cmd.Dispose(); err != nil {
+		t.Fatal(err)
+	}
+	if holder.DisposeCount() != 1 {
+		t.Fatalf("wanted sub to be disposed once but got %d", holder.DisposeCount())
+	}
+
+	var stdout, stderr testcmd.Buffer
+	ctx := subcommandsutil.WithOutput(context.Background(), &stdout, &stderr)
+	status, _, err := testcmd.Run(ctx, subcommandsutil.Exclusive(testcmd.NewRecording("sync"), path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != subcommands.ExitSuccess || strings.Contains(stderr.String(), "another instance") {
+		t.Fatalf("wanted the lock to be released by Dispose but got status %v and stderr %q", status, stderr.String())
+	}
+}
@@ -0,0 +1,47 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Arg returns the first value of args, the varargs of Execute, of type T, which may be an
+// interface implemented by the value. The wrappers of this package forward args untouched, so that
+// the dependencies given to subcommands.Commander.Execute reach the commands:
+//
+//	client, ok := subcommandsutil.Arg[*api.Client](args)
+func Arg[T any](args []interface{}) (T, bool) {
+	for _, arg := range args {
+		if v, ok := arg.(T); ok {
+			return v, true
+		}
+	}
+
+	var zero T
+	return zero, false
+}
+
+// MustArg is like Arg, but panics if args has no value of type T.
+func MustArg[T any](args []interface{}) T {
+	v, ok := Arg[T](args)
+	if !ok {
+		panic(fmt.Sprintf("subcommandsutil: no Execute argument of type %v among %d", reflect.TypeOf((*T)(nil)).Elem(), len(args)))
+	}
+
+	return v
+}
+
+// Args returns the values of args, the varargs of Execute, of type T, in order.
+func Args[T any](args []interface{}) []T {
+	var vs []T
+	for _, arg := range args {
+		if v, ok := arg.(T); ok {
+			vs = append(vs, v)
+		}
+	}
+
+	return vs
+}
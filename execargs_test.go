@@ -0,0 +1,118 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+// client is a dependency given to Execute.
+type client struct{ name string }
+
+func TestArg(t *testing.T) {
+	tests := map[string]struct {
+		args      []interface{}
+		wantOK    bool
+		wantFirst *client
+		wantAll   []*client
+	}{
+		"when no value has the type": {
+			args: []interface{}{"config", 42},
+		},
+		"when a single value has the type": {
+			args:      []interface{}{"config", &client{name: "api"}, 42},
+			wantOK:    true,
+			wantFirst: &client{name: "api"},
+			wantAll:   []*client{{name: "api"}},
+		},
+		"when several values have the type": {
+			args:      []interface{}{&client{name: "api"}, "config", &client{name: "storage"}},
+			wantOK:    true,
+			wantFirst: &client{name: "api"},
+			wantAll:   []*client{{name: "api"}, {name: "storage"}},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, ok := subcommandsutil.Arg[*client](tt.args)
+			if ok != tt.wantOK || !reflect.DeepEqual(got, tt.wantFirst) {
+				t.Fatalf("wanted %+v, %t but got %+v, %t", tt.wantFirst, tt.wantOK, got, ok)
+			}
+			if all := subcommandsutil.Args[*client](tt.args); !reflect.DeepEqual(all, tt.wantAll) {
+				t.Fatalf("wanted %+v but got %+v", tt.wantAll, all)
+			}
+		})
+	}
+}
+
+func TestArgInterface(t *testing.T) {
+	args := []interface{}{"config", os.Stdout}
+	if w, ok := subcommandsutil.Arg[io.Writer](args); !ok || w != os.Stdout {
+		t.Fatalf("wanted os.Stdout as an io.Writer but got %v, %t", w, ok)
+	}
+}
+
+func TestMustArg(t *testing.T) {
+	if got := subcommandsutil.MustArg[string]([]interface{}{42, "config"}); got != "config" {
+		t.Fatalf("wanted config but got %q", got)
+	}
+
+	defer func() {
+		want := "subcommandsutil: no Execute argument of type *subcommandsutil_test.client among 2"
+		if r := recover(); fmt.Sprint(r) != want {
+			t.Fatalf("wanted the panic %q but got %v", want, r)
+		}
+	}()
+	subcommandsutil.MustArg[*client]([]interface{}{42, "config"})
+}
+
+func TestWrappersForwardArgs(t *testing.T) {
+	tests := map[string]struct {
+		wrap func(sub subcommandsutil.CancelableCommand) subcommands.Command
+	}{
+		"when wrapped in Cancelable": {
+			wrap: func(sub subcommandsutil.CancelableCommand) subcommands.Command {
+				return subcommandsutil.Cancelable(sub)
+			},
+		},
+		"when wrapped in Timeout": {
+			wrap: func(sub subcommandsutil.CancelableCommand) subcommands.Command {
+				return subcommandsutil.Timeout(sub, time.Minute)
+			},
+		},
+		"when wrapped in Retry": {
+			wrap: func(sub subcommandsutil.CancelableCommand) subcommands.Command { return subcommandsutil.Retry(sub) },
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			sub := testcmd.NewRecording("deploy")
+			cmd := tt.wrap(sub)
+			f := flag.NewFlagSet("deploy", flag.ContinueOnError)
+			cmd.SetFlags(f)
+			want := []interface{}{&client{name: "api"}, "config", 42}
+
+			testcmd.RequireSuccess(t, cmd.Execute(context.Background(), f, want...))
+			call, ok := sub.LastCall()
+			if !ok {
+				t.Fatal("wanted the command to be executed")
+			}
+			if !reflect.DeepEqual(call.Varargs, want) || call.Varargs[0] != want[0] {
+				t.Fatalf("wanted the args %v but got %v", want, call.Varargs)
+			}
+		})
+	}
+}
@@ -0,0 +1,136 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/google/subcommands"
+)
+
+// exitHook is a function registered by OnExitStatus.
+type exitHook struct {
+	fn      func(status subcommands.ExitStatus)
+	timeout time.Duration
+	policy  *HookErrorPolicy
+}
+
+var (
+	exitHooksMu sync.Mutex
+	exitHooks   []*exitHook
+
+	// exit and exitLogger are replaced in the tests.
+	exit              = os.Exit
+	exitLogger Logger = stdLogger{}
+)
+
+// ExitHookOption is an option of OnExitStatus.
+type ExitHookOption interface {
+	applyExitHook(*exitHook)
+}
+
+// exitHookOptionFunc is an ExitHookOption implemented by a function.
+type exitHookOptionFunc func(*exitHook)
+
+// applyExitHook implements ExitHookOption.
+func (fn exitHookOptionFunc) applyExitHook(h *exitHook) { fn(h) }
+
+// applyExitHook implements ExitHookOption.
+func (o HookErrorPolicyOption) applyExitHook(h *exitHook) {
+	h.policy = &o.policy
+}
+
+// WithExitHookTimeout sets how long Exit waits for the hook to return before running the next one.
+// The default is 5 seconds; zero waits forever.
+func WithExitHookTimeout(d time.Duration) ExitHookOption {
+	return exitHookOptionFunc(func(h *exitHook) {
+		h.timeout = d
+	})
+}
+
+// OnExitStatus registers fn to be called by Exit with the final status of the program, right
+// before it exits, like to flush buffered telemetry:
+//
+//	subcommandsutil.OnExitStatus(func(status subcommands.ExitStatus) {
+//		telemetry.Flush(int(status))
+//	})
+//	subcommandsutil.Exit(cdr.Execute(ctx))
+//
+// The registered functions run in the order they were registered, also when CancelOnSignal forces
+// the exit on a termination signal received after the cancellation, once the command is disposed.
+// A function which panics, or does not return in time, is logged to the standard logger and
+// handled according to the HookErrorPolicy, which WithHookErrorPolicy overrides: with FailCommand,
+// a successful status becomes subcommands.ExitFailure.
+func OnExitStatus(fn func(status subcommands.ExitStatus), opts ...ExitHookOption) {
+	h := &exitHook{
+		fn:      fn,
+		timeout: 5 * time.Second,
+	}
+	for _, opt := range opts {
+		opt.applyExitHook(h)
+	}
+
+	exitHooksMu.Lock()
+	defer exitHooksMu.Unlock()
+
+	exitHooks = append(exitHooks, h)
+}
+
+// Exit calls the functions registered by OnExitStatus with status, and exits the program with
+// status. It is meant to be called last from main, once the commands are disposed.
+func Exit(status subcommands.ExitStatus) {
+	exit(int(runExitHooks(context.Background(), status)))
+}
+
+// runExitHooks calls the functions registered by OnExitStatus with status, and returns the status
+// the program exits with. Their failures are published to the Events of ctx.
+func runExitHooks(ctx context.Context, status subcommands.ExitStatus) subcommands.ExitStatus {
+	exitHooksMu.Lock()
+	hooks := append([]*exitHook(nil), exitHooks...)
+	exitHooksMu.Unlock()
+
+	for _, h := range hooks {
+		err := h.run(status)
+		if err == nil {
+			continue
+		}
+		p := hookFailed(ctx, exitLogger, "", "exit", err, hookErrorPolicy(h.policy))
+		if p == FailCommand && status == subcommands.ExitSuccess {
+			status = subcommands.ExitFailure
+		}
+		if p == Abort {
+			break
+		}
+	}
+
+	return status
+}
+
+// run calls h with status, and waits for it up to its timeout.
+func (h *exitHook) run(status subcommands.ExitStatus) error {
+	// buffered so that the goroutine exits even when nobody receives after the timeout
+	errc := make(chan error, 1)
+	go func() {
+		errc <- callHook(func() error {
+			h.fn(status)
+			return nil
+		})
+	}()
+
+	if h.timeout <= 0 {
+		return <-errc
+	}
+	timer := time.NewTimer(h.timeout)
+	defer timer.Stop()
+	select {
+	case err := <-errc:
+		return err
+	case <-timer.C:
+		return fmt.Errorf("did not return within %v", h.timeout)
+	}
+}
@@ -0,0 +1,117 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+func TestExit(t *testing.T) {
+	tests := map[string]struct {
+		hooks      func(events *[]string)
+		status     subcommands.ExitStatus
+		wantEvents []string
+		wantLog    []string
+	}{
+		"when no hook is registered": {
+			hooks:      func(events *[]string) {},
+			status:     subcommands.ExitSuccess,
+			wantEvents: []string{"exit 0"},
+		},
+		"when two hooks are registered": {
+			hooks: func(events *[]string) {
+				subcommandsutil.OnExitStatus(func(status subcommands.ExitStatus) {
+					*events = append(*events, fmt.Sprintf("flush telemetry %d", status))
+				})
+				subcommandsutil.OnExitStatus(func(status subcommands.ExitStatus) {
+					*events = append(*events, fmt.Sprintf("write marker %d", status))
+				})
+			},
+			status:     subcommands.ExitUsageError,
+			wantEvents: []string{"flush telemetry 2", "write marker 2", "exit 2"},
+		},
+		"when a hook panics": {
+			hooks: func(events *[]string) {
+				subcommandsutil.OnExitStatus(func(status subcommands.ExitStatus) {
+					panic("boom")
+				})
+				subcommandsutil.OnExitStatus(func(status subcommands.ExitStatus) {
+					*events = append(*events, fmt.Sprintf("flush telemetry %d", status))
+				})
+			},
+			status:     subcommands.ExitFailure,
+			wantEvents: []string{"flush telemetry 1", "exit 1"},
+			wantLog:    []string{"exit hook: panic: boom (ContinueAndLog)"},
+		},
+		"when a hook panics with the FailCommand policy": {
+			hooks: func(events *[]string) {
+				subcommandsutil.OnExitStatus(func(status subcommands.ExitStatus) {
+					panic("boom")
+				}, subcommandsutil.WithHookErrorPolicy(subcommandsutil.FailCommand))
+				subcommandsutil.OnExitStatus(func(status subcommands.ExitStatus) {
+					*events = append(*events, fmt.Sprintf("flush telemetry %d", status))
+				})
+			},
+			status:     subcommands.ExitSuccess,
+			wantEvents: []string{"flush telemetry 1", "exit 1"},
+			wantLog:    []string{"exit hook: panic: boom (FailCommand)"},
+		},
+		"when a hook panics with the Abort policy": {
+			hooks: func(events *[]string) {
+				subcommandsutil.OnExitStatus(func(status subcommands.ExitStatus) {
+					panic("boom")
+				}, subcommandsutil.WithHookErrorPolicy(subcommandsutil.Abort))
+				subcommandsutil.OnExitStatus(func(status subcommands.ExitStatus) {
+					*events = append(*events, fmt.Sprintf("flush telemetry %d", status))
+				})
+			},
+			status:     subcommands.ExitSuccess,
+			wantEvents: []string{"exit 0"},
+			wantLog:    []string{"exit hook: panic: boom (Abort)"},
+		},
+		"when a hook does not return in time": {
+			hooks: func(events *[]string) {
+				release := make(chan struct{})
+				t.Cleanup(func() { close(release) })
+				subcommandsutil.OnExitStatus(func(status subcommands.ExitStatus) {
+					<-release
+				}, subcommandsutil.WithExitHookTimeout(10*time.Millisecond))
+				subcommandsutil.OnExitStatus(func(status subcommands.ExitStatus) {
+					*events = append(*events, fmt.Sprintf("flush telemetry %d", status))
+				})
+			},
+			status:     subcommands.ExitFailure,
+			wantEvents: []string{"flush telemetry 1", "exit 1"},
+			wantLog:    []string{"exit hook: did not return within 10ms (ContinueAndLog)"},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var events []string
+			var logs testcmd.LogRecorder
+			restore := subcommandsutil.SetExit(func(code int) {
+				events = append(events, fmt.Sprintf("exit %d", code))
+			}, &logs)
+			defer restore()
+
+			tt.hooks(&events)
+			subcommandsutil.Exit(tt.status)
+			if !reflect.DeepEqual(events, tt.wantEvents) {
+				t.Fatalf("wanted the events %q but got %q", tt.wantEvents, events)
+			}
+			if got := logs.Lines(); strings.Join(got, "\n") != strings.Join(tt.wantLog, "\n") {
+				t.Fatalf("wanted the log %q but got %q", tt.wantLog, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,195 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/google/subcommands"
+)
+
+// ExpandEnv replaces the $VAR and ${VAR} references in s with the values of the environment
+// variables looked up with os.LookupEnv, and $$ with a literal $. A $ which starts no reference is
+// kept as is. The references to unset variables are replaced with the empty string, or kept intact
+// if keepUnset is true.
+func ExpandEnv(s string, keepUnset bool) string {
+	i := strings.IndexByte(s, '$')
+	if i < 0 {
+		return s
+	}
+
+	var b strings.Builder
+	b.Grow(len(s))
+	for ; i >= 0; i = strings.IndexByte(s, '$') {
+		b.WriteString(s[:i])
+		s = s[i:]
+
+		ref, name := envRef(s)
+		switch {
+		case ref == "":
+			b.WriteByte('$')
+			s = s[1:]
+			continue
+		case ref == "$$":
+			b.WriteByte('$')
+		default:
+			if v, ok := os.LookupEnv(name); ok {
+				b.WriteString(v)
+			} else if keepUnset {
+				b.WriteString(ref)
+			}
+		}
+		s = s[len(ref):]
+	}
+	b.WriteString(s)
+
+	return b.String()
+}
+
+// envRef returns the reference starting s, which starts with a $, and the name of its variable:
+// "$$" for the escape, with no name, and "" if s starts no reference.
+func envRef(s string) (ref, name string) {
+	if len(s) < 2 {
+		return "", ""
+	}
+
+	switch s[1] {
+	case '$':
+		return "$$", ""
+	case '{':
+		end := strings.IndexByte(s, '}')
+		if end < 0 || !isEnvName(s[2:end]) {
+			return "", ""
+		}
+		return s[:end+1], s[2:end]
+	default:
+		n := 1
+		for n < len(s) && isEnvName(s[1:n+1]) {
+			n++
+		}
+		if n == 1 {
+			return "", ""
+		}
+		return s[:n], s[1:n]
+	}
+}
+
+// ExpandEnvOption is an option of the ExpandEnvArgs wrapper.
+type ExpandEnvOption interface {
+	applyExpandEnv(*expandEnvArgs)
+}
+
+// expandEnvOptionFunc is an ExpandEnvOption implemented by a function.
+type expandEnvOptionFunc func(*expandEnvArgs)
+
+// applyExpandEnv implements ExpandEnvOption.
+func (fn expandEnvOptionFunc) applyExpandEnv(c *expandEnvArgs) { fn(c) }
+
+// WithExpandFlags makes ExpandEnvArgs expand the values of the flags set on the command line too.
+// A flag whose value changes is set again to the expanded value, so that a flag accumulating its
+// values, like a list, holds the expanded value in addition to the original one.
+func WithExpandFlags() ExpandEnvOption {
+	return expandEnvOptionFunc(func(c *expandEnvArgs) {
+		c.flags = true
+	})
+}
+
+// WithKeepUnset makes ExpandEnvArgs keep the references to unset variables intact, instead of
+// replacing them with the empty string.
+func WithKeepUnset() ExpandEnvOption {
+	return expandEnvOptionFunc(func(c *expandEnvArgs) {
+		c.keepUnset = true
+	})
+}
+
+// expandEnvArgs wraps a subcommands.Command so that the environment variables in its arguments are
+// expanded.
+type expandEnvArgs struct {
+	sub       subcommands.Command
+	flags     bool
+	keepUnset bool
+}
+
+// make sure expandEnvArgs implements the subcommands.Command interface.
+var _ subcommands.Command = (*expandEnvArgs)(nil)
+
+// ExpandEnvArgs wraps sub so that the environment variable references in its positional arguments
+// are expanded with ExpandEnv before it is executed, for the programs invoked without a shell
+// expanding them, like from cmd.exe or another program:
+//
+//	mytool render $HOME/templates
+//
+// The expansion happens after the flags are parsed, so that their values are left untouched unless
+// WithExpandFlags is given. A flag value the flag rejects once expanded is reported to the
+// FlagSet's output and Execute returns subcommands.ExitUsageError.
+func ExpandEnvArgs(sub subcommands.Command, opts ...ExpandEnvOption) subcommands.Command {
+	c := &expandEnvArgs{
+		sub: sub,
+	}
+	for _, opt := range opts {
+		opt.applyExpandEnv(c)
+	}
+
+	return c
+}
+
+// Name forwards to the underlying c.sub Command.
+func (c *expandEnvArgs) Name() string {
+	return c.sub.Name()
+}
+
+// Usage forwards to the underlying c.sub Command.
+func (c *expandEnvArgs) Usage() string {
+	return c.sub.Usage()
+}
+
+// Synopsis forwards to the underlying c.sub Command.
+func (c *expandEnvArgs) Synopsis() string {
+	return c.sub.Synopsis()
+}
+
+// SetFlags forwards to the underlying c.sub Command.
+func (c *expandEnvArgs) SetFlags(f *flag.FlagSet) {
+	c.sub.SetFlags(f)
+}
+
+// Unwrap returns the underlying c.sub Command.
+func (c *expandEnvArgs) Unwrap() subcommands.Command {
+	return c.sub
+}
+
+// Execute expands the arguments of f and forwards to the underlying c.sub Command.
+func (c *expandEnvArgs) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	if c.flags {
+		var err error
+		f.Visit(func(fl *flag.Flag) {
+			if v := fl.Value.String(); err == nil && strings.IndexByte(v, '$') >= 0 {
+				if expanded := ExpandEnv(v, c.keepUnset); expanded != v {
+					if err = f.Set(fl.Name, expanded); err != nil {
+						err = fmt.Errorf("-%s: %w", fl.Name, err)
+					}
+				}
+			}
+		})
+		if err != nil {
+			fmt.Fprintf(f.Output(), "%s: %v\n", c.sub.Name(), err)
+			return subcommands.ExitUsageError
+		}
+	}
+
+	expanded := make([]string, f.NArg())
+	for i, arg := range f.Args() {
+		expanded[i] = ExpandEnv(arg, c.keepUnset)
+	}
+	// a leading "--" keeps the positional arguments from being parsed as flags again
+	if err := f.Parse(append([]string{"--"}, expanded...)); err != nil {
+		return subcommands.ExitUsageError
+	}
+
+	return c.sub.Execute(ctx, f, args...)
+}
@@ -0,0 +1,113 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"context"
+	"flag"
+	"reflect"
+	"testing"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+func TestExpandEnv(t *testing.T) {
+	t.Setenv("TOOL_HOME", "/home/gopher")
+	t.Setenv("TOOL_EMPTY", "")
+
+	tests := map[string]struct {
+		s         string
+		keepUnset bool
+		want      string
+	}{
+		"when the variable is set": {
+			s:    "$TOOL_HOME/templates",
+			want: "/home/gopher/templates",
+		},
+		"when the variable is braced": {
+			s:    "${TOOL_HOME}_1",
+			want: "/home/gopher_1",
+		},
+		"when the variable is set empty": {
+			s:    "a${TOOL_EMPTY}b",
+			want: "ab",
+		},
+		"when the variable is unset": {
+			s:    "a$TOOL_UNSET/${TOOL_UNSET}b",
+			want: "a/b",
+		},
+		"when the unset variable is kept": {
+			s:         "a$TOOL_UNSET/${TOOL_UNSET}b",
+			keepUnset: true,
+			want:      "a$TOOL_UNSET/${TOOL_UNSET}b",
+		},
+		"when the $ is escaped": {
+			s:    "$$TOOL_HOME costs $$5",
+			want: "$TOOL_HOME costs $5",
+		},
+		"when the $ starts no reference": {
+			s:    "$ 5$ ${1x} ${TOOL_HOME",
+			want: "$ 5$ ${1x} ${TOOL_HOME",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := subcommandsutil.ExpandEnv(tt.s, tt.keepUnset); got != tt.want {
+				t.Fatalf("wanted %q but got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestExpandEnvArgs(t *testing.T) {
+	t.Setenv("TOOL_HOME", "/home/gopher")
+
+	tests := map[string]struct {
+		opts     []subcommandsutil.ExpandEnvOption
+		args     []string
+		wantArgs []string
+		wantOut  string
+	}{
+		"when the positional arguments are expanded": {
+			args:     []string{"-out", "$TOOL_HOME/out", "$TOOL_HOME/templates", "$$TOOL_HOME", "$TOOL_UNSET"},
+			wantArgs: []string{"/home/gopher/templates", "$TOOL_HOME", ""},
+			wantOut:  "$TOOL_HOME/out",
+		},
+		"when the unset variables are kept": {
+			opts:     []subcommandsutil.ExpandEnvOption{subcommandsutil.WithKeepUnset()},
+			args:     []string{"${TOOL_UNSET}/templates"},
+			wantArgs: []string{"${TOOL_UNSET}/templates"},
+		},
+		"when the flag values are expanded": {
+			opts:     []subcommandsutil.ExpandEnvOption{subcommandsutil.WithExpandFlags()},
+			args:     []string{"-out", "$TOOL_HOME/out", "-verbose", "$TOOL_HOME"},
+			wantArgs: []string{"/home/gopher"},
+			wantOut:  "/home/gopher/out",
+		},
+		"when an argument looks like a flag once expanded": {
+			args:     []string{"--", "${TOOL_HOME}", "-verbose"},
+			wantArgs: []string{"/home/gopher", "-verbose"},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var out string
+			var verbose bool
+			sub := testcmd.NewRecording("render", testcmd.WithFlags(func(f *flag.FlagSet) {
+				f.StringVar(&out, "out", "", "")
+				f.BoolVar(&verbose, "verbose", false, "")
+			}))
+
+			status, _, _ := testcmd.Run(context.Background(), subcommandsutil.ExpandEnvArgs(sub, tt.opts...), tt.args...)
+			testcmd.RequireSuccess(t, status)
+			if call, ok := sub.LastCall(); !ok || !reflect.DeepEqual(call.Args, tt.wantArgs) {
+				t.Fatalf("wanted the arguments %q but got %q", tt.wantArgs, call.Args)
+			}
+			if out != tt.wantOut {
+				t.Fatalf("wanted -out %q but got %q", tt.wantOut, out)
+			}
+		})
+	}
+}
@@ -0,0 +1,194 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"fmt"
+	"io/fs"
+	"os/exec"
+	"time"
+)
+
+// ResetGlobalFlags removes every flag registered by AddGlobalFlag.
+func ResetGlobalFlags() {
+	globalFlagsMu.Lock()
+	defer globalFlagsMu.Unlock()
+
+	globalFlags = nil
+}
+
+// FlagMetaCount returns the number of FlagSets with metadata.
+func FlagMetaCount() int {
+	flagMetasMu.Lock()
+	defer flagMetasMu.Unlock()
+
+	return len(flagMetas)
+}
+
+// StartDaemon is the function starting the daemon process of Daemonize, unless replaced by
+// SetStartDaemon.
+var StartDaemon = startDaemon
+
+// SetStartDaemon replaces the function starting the daemon process of Daemonize with fn.
+func SetStartDaemon(fn func(cmd *exec.Cmd) (int, <-chan struct{}, error)) (restore func()) {
+	saved := startDaemon
+	startDaemon = fn
+
+	return func() { startDaemon = saved }
+}
+
+// UserDir returns the directory of kind, one of "config", "state" or "cache", of appName on goos,
+// with the environment env, without creating it.
+func UserDir(kind, appName, goos string, env map[string]string) (string, error) {
+	kinds := map[string]dirKind{"config": configDirKind, "state": stateDirKind, "cache": cacheDirKind}
+
+	return userDir(kinds[kind], appName, goos, func(k string) string { return env[k] })
+}
+
+// ConfigFilePathsOn returns the ConfigFilePaths of filename of appName on goos, with the
+// environment env, relative to the current directory cwd.
+func ConfigFilePathsOn(appName, filename, goos, cwd string, env map[string]string) []string {
+	return configFilePaths(appName, filename, goos, func(k string) string { return env[k] }, cwd)
+}
+
+// SetExit replaces the function exiting the program of Exit with fn, and its logger with l, and
+// removes the functions registered by OnExitStatus.
+func SetExit(fn func(code int), l Logger) (restore func()) {
+	savedExit, savedLogger := exit, exitLogger
+	exit, exitLogger = fn, l
+
+	exitHooksMu.Lock()
+	savedHooks := exitHooks
+	exitHooks = nil
+	exitHooksMu.Unlock()
+
+	return func() {
+		exit, exitLogger = savedExit, savedLogger
+
+		exitHooksMu.Lock()
+		exitHooks = savedHooks
+		exitHooksMu.Unlock()
+	}
+}
+
+// WatchdogInterval returns the interval of the WATCHDOG=1 messages of SystemdNotify from the values
+// of WATCHDOG_USEC and WATCHDOG_PID.
+func WatchdogInterval(usec, pid string) time.Duration {
+	return watchdogInterval(usec, pid)
+}
+
+// SplitShellWords splits line into words like ShellCommand.
+func SplitShellWords(line string) ([]string, error) {
+	return splitShellWords(line)
+}
+
+// SetProcessPrivileged replaces the function reporting whether the process runs as root for
+// RequireRoot with fn.
+func SetProcessPrivileged(fn func() bool) (restore func()) {
+	saved := processPrivileged
+	processPrivileged = fn
+
+	return func() { processPrivileged = saved }
+}
+
+// SetPlatform replaces the operating system and architecture checked by OnlyOn, OnlyOnArch and
+// OnlyWhere with goos and goarch.
+func SetPlatform(goos, goarch string) (restore func()) {
+	saved := platform
+	platform = func() (string, string) { return goos, goarch }
+
+	return func() { platform = saved }
+}
+
+// CPUQuota returns the CPU quota of MaxProcs read from the file system fsys.
+func CPUQuota(fsys fs.FS) (float64, bool) {
+	return cpuQuota(fsys)
+}
+
+// SetCgroupFS replaces the file system MaxProcs reads the CPU quota from with fsys.
+func SetCgroupFS(fsys fs.FS) (restore func()) {
+	saved := cgroupFS
+	cgroupFS = fsys
+
+	return func() { cgroupFS = saved }
+}
+
+// FakeServices is a fake service control manager for WindowsService, InstallServiceCommand and
+// UninstallServiceCommand.
+type FakeServices struct {
+	Service   bool              // whether the process runs as a Windows service
+	Requests  chan string       // the control requests sent to the service, "stop" or "shutdown"
+	States    chan string       // the states reported by the service
+	ExitCode  uint32            // the exit code of the last run of the service
+	Installed map[string]string // the configuration of the installed services, by name
+}
+
+// SetServices replaces the service control manager of WindowsService, InstallServiceCommand and
+// UninstallServiceCommand with f.
+func SetServices(f *FakeServices) (restore func()) {
+	saved := services
+	services = fakeServices{f}
+
+	return func() { services = saved }
+}
+
+// fakeServices is the serviceControl of a FakeServices.
+type fakeServices struct {
+	f *FakeServices
+}
+
+// isService implements serviceControl.
+func (s fakeServices) isService() (bool, error) { return s.f.Service, nil }
+
+// run implements serviceControl.
+func (s fakeServices) run(name string, handler serviceHandler) error {
+	requests := make(chan serviceRequest)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case req := <-s.f.Requests:
+				r := map[string]serviceRequest{"stop": serviceStop, "shutdown": serviceShutdown}[req]
+				select {
+				case requests <- r:
+				case <-done:
+					return
+				}
+			}
+		}
+	}()
+
+	s.f.ExitCode = handler(requests, func(state serviceState) { s.f.States <- state.String() })
+
+	return nil
+}
+
+// install implements serviceControl.
+func (s fakeServices) install(name string, cfg serviceConfig) error {
+	if _, ok := s.f.Installed[name]; ok {
+		return fmt.Errorf("the service %s already exists", name)
+	}
+	s.f.Installed[name] = fmt.Sprintf("display-name=%q description=%q manual=%v args=%q", cfg.displayName, cfg.description, cfg.manual, cfg.args)
+
+	return nil
+}
+
+// uninstall implements serviceControl.
+func (s fakeServices) uninstall(name string) error {
+	if _, ok := s.f.Installed[name]; !ok {
+		return fmt.Errorf("the service %s does not exist", name)
+	}
+	delete(s.f.Installed, name)
+
+	return nil
+}
+
+// ExecutableName returns name without its executable extension on goos, and whether the file of
+// name with the mode is executable, with the PATHEXT pathext.
+func ExecutableName(name string, mode fs.FileMode, goos, pathext string) (string, bool) {
+	return executableName(name, mode, goos, pathext)
+}
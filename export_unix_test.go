@@ -0,0 +1,88 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+//go:build unix
+
+package subcommandsutil
+
+import (
+	"errors"
+	"fmt"
+	"os/user"
+	"strconv"
+)
+
+// FakeIDs is a fake of the user and group IDs of the process for DropPrivileges, recording the
+// calls setting them.
+type FakeIDs struct {
+	UID, GID int
+	Users    []user.User  // the user database
+	Groups   []user.Group // the group database
+
+	Fail       string // the call failing, like "setuid 1000", if any
+	Reversible bool   // whether the user ID 0 can be set back once dropped
+	Ignored    bool   // whether setuid succeeds without changing the user IDs
+
+	Calls []string
+}
+
+// SetProcessIDs replaces the user and group IDs of the process of DropPrivileges with f.
+func SetProcessIDs(f *FakeIDs) (restore func()) {
+	saved := systemIDs
+	root := f.UID == 0
+	call := func(name string, id int, set func()) error {
+		c := fmt.Sprint(name, " ", id)
+		f.Calls = append(f.Calls, c)
+		if c == f.Fail || !root && !f.Reversible {
+			return errors.New("operation not permitted")
+		}
+		set()
+		return nil
+	}
+	systemIDs = &processIDs{
+		getuid:  func() int { return f.UID },
+		geteuid: func() int { return f.UID },
+		getgid:  func() int { return f.GID },
+		getegid: func() int { return f.GID },
+		setgroups: func(gids []int) error {
+			return call("setgroups", gids[0], func() {})
+		},
+		setgid: func(gid int) error {
+			return call("setgid", gid, func() { f.GID = gid })
+		},
+		setuid: func(uid int) error {
+			return call("setuid", uid, func() {
+				if !f.Ignored {
+					f.UID, root = uid, uid == 0
+				}
+			})
+		},
+		lookupUser: func(name string) (*user.User, error) {
+			for _, u := range f.Users {
+				if u.Username == name {
+					return &u, nil
+				}
+			}
+			return nil, user.UnknownUserError(name)
+		},
+		lookupUserID: func(uid string) (*user.User, error) {
+			for _, u := range f.Users {
+				if u.Uid == uid {
+					return &u, nil
+				}
+			}
+			id, _ := strconv.Atoi(uid)
+			return nil, user.UnknownUserIdError(id)
+		},
+		lookupGroup: func(name string) (*user.Group, error) {
+			for _, g := range f.Groups {
+				if g.Name == name {
+					return &g, nil
+				}
+			}
+			return nil, user.UnknownGroupError(name)
+		},
+	}
+
+	return func() { systemIDs = saved }
+}
@@ -0,0 +1,120 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"flag"
+)
+
+// DefaultFlagGroupTitle is the title of the section of the flags in no group, listed after the
+// groups registered by FlagGroup.
+const DefaultFlagGroupTitle = "Other options"
+
+// FlagGroup puts the named flags of f in the group titled title. PrintDefaults, ExplainCommand and
+// the generated documentation then list the flags under titled sections, one per group in the
+// order FlagGroup was first called with its title, followed by the flags in no group under
+// DefaultFlagGroupTitle:
+//
+//	Output options:
+//	  -format string
+//	    	...
+//
+//	Other options:
+//	  -v	...
+//
+// The aliases registered by AliasFlag are grouped with their canonical flag. A flag put in several
+// groups stays in the last one. Calling FlagGroup again with the same title adds names to the group.
+//
+// FlagGroup is usually called from SetFlags, after the flags are defined. It panics if f does not
+// define one of names.
+func FlagGroup(f *flag.FlagSet, title string, names ...string) {
+	for _, name := range names {
+		mustLookup(f, name)
+	}
+
+	canonical := make([]string, len(names))
+	for i, name := range names {
+		canonical[i] = CanonicalFlagName(f, name)
+	}
+	updateFlagMeta(f, func(m *flagMeta) {
+		if _, ok := m.groupIndex(title); !ok {
+			m.groups = append(m.groups, title)
+		}
+		for _, name := range canonical {
+			m.group[name] = title
+		}
+	})
+}
+
+// FlagGroupOf returns the title of the group the flag named name of f, or the flag it is an alias
+// of, was put in by FlagGroup.
+func FlagGroupOf(f *flag.FlagSet, name string) (title string, ok bool) {
+	name = CanonicalFlagName(f, name)
+	readFlagMeta(f, func(m *flagMeta) {
+		if m != nil {
+			title, ok = m.group[name]
+		}
+	})
+
+	return title, ok
+}
+
+// groupIndex returns the index of the group titled title in m.groups.
+func (m *flagMeta) groupIndex(title string) (int, bool) {
+	for i, t := range m.groups {
+		if t == title {
+			return i, true
+		}
+	}
+
+	return 0, false
+}
+
+// flagSection is a titled section of the flags of a FlagSet.
+type flagSection[T any] struct {
+	title string
+	items []T
+}
+
+// flagSections sorts the items of flags, in the order of flags, into the sections of their groups.
+// Without any group, it returns a single untitled section, and no section is empty.
+func flagSections[T any](f *flag.FlagSet, flags []*flag.Flag, items []T) []flagSection[T] {
+	var sections []flagSection[T]
+	section := make(map[string]int) // flag name -> index in sections
+	readFlagMeta(f, func(m *flagMeta) {
+		if m == nil {
+			return
+		}
+		for _, title := range m.groups {
+			sections = append(sections, flagSection[T]{title: title})
+		}
+		for name, title := range m.group {
+			section[name], _ = m.groupIndex(title)
+		}
+	})
+	if len(sections) == 0 {
+		if len(items) == 0 {
+			return nil
+		}
+		return []flagSection[T]{{items: items}}
+	}
+
+	sections = append(sections, flagSection[T]{title: DefaultFlagGroupTitle})
+	for i, fl := range flags {
+		j, ok := section[fl.Name]
+		if !ok {
+			j = len(sections) - 1
+		}
+		sections[j].items = append(sections[j].items, items[i])
+	}
+
+	nonEmpty := sections[:0]
+	for _, s := range sections {
+		if len(s.items) > 0 {
+			nonEmpty = append(nonEmpty, s)
+		}
+	}
+
+	return nonEmpty
+}
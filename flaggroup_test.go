@@ -0,0 +1,128 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+// newPublishCommand returns a command whose flags are in groups, wrapped in Timeout adding a flag
+// in no group.
+func newPublishCommand() subcommands.Command {
+	return subcommandsutil.Timeout(testcmd.NewRecording("publish",
+		testcmd.WithSynopsis("publish a release"),
+		testcmd.WithUsage("publish [flags] VERSION:\n  Publish the release VERSION.\n"),
+		testcmd.WithFlags(func(f *flag.FlagSet) {
+			f.String("format", "text", "render the report as `format`")
+			f.String("output", "", "write the report to `file`")
+			f.String("token", "", "authenticate with `token`")
+			f.String("user", "", "authenticate as `name`")
+			f.Bool("debug", false, "print the requests")
+			f.Bool("v", false, "verbose output")
+			subcommandsutil.AliasFlag(f, "output", "o")
+			subcommandsutil.HideFlags(f, "debug")
+			subcommandsutil.FlagGroup(f, "Output options", "format", "o")
+			subcommandsutil.FlagGroup(f, "Authentication", "user", "token", "debug")
+		}),
+	), time.Minute)
+}
+
+func TestFlagGroup(t *testing.T) {
+	f := flag.NewFlagSet("publish", flag.ContinueOnError)
+	newPublishCommand().SetFlags(f)
+
+	tests := map[string]struct {
+		name      string
+		wantTitle string
+		wantOK    bool
+	}{
+		"when the flag is in a group": {
+			name:      "token",
+			wantTitle: "Authentication",
+			wantOK:    true,
+		},
+		"when the flag is grouped by its alias": {
+			name:      "output",
+			wantTitle: "Output options",
+			wantOK:    true,
+		},
+		"when the alias is asked about": {
+			name:      "o",
+			wantTitle: "Output options",
+			wantOK:    true,
+		},
+		"when the flag is in no group": {
+			name: "timeout",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			title, ok := subcommandsutil.FlagGroupOf(f, tt.name)
+			if title != tt.wantTitle || ok != tt.wantOK {
+				t.Fatalf("wanted %q, %t but got %q, %t", tt.wantTitle, tt.wantOK, title, ok)
+			}
+		})
+	}
+}
+
+func TestFlagGroupUsage(t *testing.T) {
+	t.Setenv(subcommandsutil.ColumnsEnv, "80")
+	cmd := newPublishCommand()
+
+	var buf bytes.Buffer
+	subcommandsutil.ExplainCommand(&buf, cmd)
+	testcmd.Golden(t, buf.String(), "testdata/flaggroup.golden")
+
+	var defaults bytes.Buffer
+	f := flag.NewFlagSet("publish", flag.ContinueOnError)
+	f.SetOutput(&defaults)
+	cmd.SetFlags(f)
+	subcommandsutil.PrintDefaults(f)
+	if got, want := defaults.String(), strings.TrimPrefix(buf.String(), cmd.Usage()+"\n"); got != want {
+		t.Fatalf("wanted PrintDefaults to print the flags of the usage %q but got %q", want, got)
+	}
+}
+
+func TestFlagGroupDocs(t *testing.T) {
+	top := flag.NewFlagSet("tool", flag.ContinueOnError)
+	cdr := subcommands.NewCommander(top, "tool")
+	cdr.Register(newPublishCommand(), "")
+
+	dir := t.TempDir()
+	if err := subcommandsutil.WriteDocs(cdr, dir); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "tool_publish.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	testcmd.Golden(t, string(data), "testdata/flaggroup_docs.md.golden")
+
+	man := subcommandsutil.ManCommand(cdr, subcommandsutil.ManMeta{
+		Date:   time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC),
+		Source: "tool 1.2.0",
+		Manual: "Tool Manual",
+	})
+	if err := top.Parse([]string{"man", "-dir", dir}); err != nil {
+		t.Fatal(err)
+	}
+	cdr.Register(man, "")
+	testcmd.RequireSuccess(t, cdr.Execute(subcommandsutil.WithOutput(context.Background(), &testcmd.Buffer{}, &testcmd.Buffer{})))
+	if data, err = os.ReadFile(filepath.Join(dir, "tool-publish.1")); err != nil {
+		t.Fatal(err)
+	}
+	testcmd.Golden(t, string(data), "testdata/flaggroup_man.1.golden")
+}
@@ -0,0 +1,72 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"flag"
+	"fmt"
+	"sync"
+
+	"github.com/google/subcommands"
+)
+
+// flagMeta holds the metadata this package attaches to the flags of a flag.FlagSet.
+//
+// The metadata is keyed by the *flag.FlagSet, so it survives any number of wrappers forwarding
+// SetFlags to the underlying Command. It is released with the FlagSet: see flagMetaOf.
+type flagMeta struct {
+	hidden     map[string]bool
+	deprecated map[string]string // deprecated name -> replacement
+	aliases    map[string]string // alias -> canonical name
+	sensitive  map[string]bool
+	global     map[string]bool
+	required   map[string]bool
+	groups     []string            // the titles of the groups, in order
+	group      map[string]string   // flag name -> group title
+	args       *ArgsSpec           // the positional arguments declared by WithArgs
+	globalErr  error               // the first collision of a global flag of WithGlobalFlags
+	serialized subcommands.Command // the instance of SerializeNew whose flags are set
+}
+
+// flagMetasMu guards the metadata of every FlagSet.
+var flagMetasMu sync.Mutex
+
+// newFlagMeta returns empty metadata.
+func newFlagMeta() *flagMeta {
+	return &flagMeta{
+		hidden:     make(map[string]bool),
+		deprecated: make(map[string]string),
+		aliases:    make(map[string]string),
+		sensitive:  make(map[string]bool),
+		global:     make(map[string]bool),
+		required:   make(map[string]bool),
+		group:      make(map[string]string),
+	}
+}
+
+// updateFlagMeta calls fn with the metadata of f, creating it if needed.
+func updateFlagMeta(f *flag.FlagSet, fn func(m *flagMeta)) {
+	flagMetasMu.Lock()
+	defer flagMetasMu.Unlock()
+
+	fn(flagMetaOf(f, true))
+}
+
+// readFlagMeta calls fn with the metadata of f. m is nil if no metadata was ever attached to f.
+func readFlagMeta(f *flag.FlagSet, fn func(m *flagMeta)) {
+	flagMetasMu.Lock()
+	defer flagMetasMu.Unlock()
+
+	fn(flagMetaOf(f, false))
+}
+
+// mustLookup returns the flag named name in f, or panics if f does not define it.
+func mustLookup(f *flag.FlagSet, name string) *flag.Flag {
+	fl := f.Lookup(name)
+	if fl == nil {
+		panic(fmt.Sprintf("subcommandsutil: flag -%s is not defined in %q", name, f.Name()))
+	}
+
+	return fl
+}
@@ -0,0 +1,28 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+//go:build !go1.24
+
+package subcommandsutil
+
+import (
+	"flag"
+)
+
+// flagMetas is the metadata of the FlagSets. Without weak pointers, before Go 1.24, it keeps the
+// FlagSets reachable for the lifetime of the program.
+var flagMetas = make(map[*flag.FlagSet]*flagMeta)
+
+// flagMetaOf returns the metadata of f, creating it if create is true, or nil. flagMetasMu must be
+// held.
+func flagMetaOf(f *flag.FlagSet, create bool) *flagMeta {
+	m, ok := flagMetas[f]
+	if ok || !create {
+		return m
+	}
+
+	m = newFlagMeta()
+	flagMetas[f] = m
+
+	return m
+}
@@ -0,0 +1,50 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+//go:build go1.24
+
+package subcommandsutil_test
+
+import (
+	"flag"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+func TestFlagMetaReleased(t *testing.T) {
+	before := subcommandsutil.FlagMetaCount()
+	sub := testcmd.NewRecording("sync", testcmd.WithFlags(func(f *flag.FlagSet) {
+		f.Bool("verbose", false, "verbose output")
+		f.String("token", "", "the access token")
+		subcommandsutil.AliasFlag(f, "verbose", "v")
+		subcommandsutil.HideFlags(f, "token")
+	}))
+	defer subcommandsutil.ResetGlobalFlags()
+	subcommandsutil.AddGlobalFlag(func(f *flag.FlagSet) {
+		f.Bool("verbose", false, "verbose output")
+	})
+	cmd := subcommandsutil.WithGlobalFlags(sub) // whose -verbose collides
+	for i := 0; i < 100; i++ {
+		f := flag.NewFlagSet("sync", flag.ContinueOnError)
+		cmd.SetFlags(f)
+		if !subcommandsutil.IsHiddenFlag(f, "token") {
+			t.Fatal("wanted -token hidden")
+		}
+	}
+	if got := subcommandsutil.FlagMetaCount(); got < before+100 {
+		t.Fatalf("wanted the metadata of 100 FlagSets but got %d more", got-before)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for subcommandsutil.FlagMetaCount() > before {
+		if time.Now().After(deadline) {
+			t.Fatalf("wanted the metadata released with the FlagSets but got %d more", subcommandsutil.FlagMetaCount()-before)
+		}
+		runtime.GC()
+		time.Sleep(10 * time.Millisecond)
+	}
+}
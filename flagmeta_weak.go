@@ -0,0 +1,37 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+//go:build go1.24
+
+package subcommandsutil
+
+import (
+	"flag"
+	"runtime"
+	"weak"
+)
+
+// flagMetas is the metadata of the FlagSets, which it does not keep reachable.
+var flagMetas = make(map[weak.Pointer[flag.FlagSet]]*flagMeta)
+
+// flagMetaOf returns the metadata of f, creating it if create is true, or nil. The metadata is
+// deleted once f is garbage collected, so that the FlagSets of each dispatch, usage, completion
+// or failed parsing do not accumulate in a long-running program. flagMetasMu must be held.
+func flagMetaOf(f *flag.FlagSet, create bool) *flagMeta {
+	key := weak.Make(f)
+	m, ok := flagMetas[key]
+	if ok || !create {
+		return m
+	}
+
+	m = newFlagMeta()
+	flagMetas[key] = m
+	runtime.AddCleanup(f, func(key weak.Pointer[flag.FlagSet]) {
+		flagMetasMu.Lock()
+		defer flagMetasMu.Unlock()
+
+		delete(flagMetas, key)
+	}, key)
+
+	return m
+}
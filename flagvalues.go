@@ -0,0 +1,71 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"flag"
+	"sort"
+)
+
+// FlagValue is the value of a flag reported by AllFlagValues.
+type FlagValue struct {
+	// Value is the value of the flag, as rendered by its flag.Value, or Redacted if it is sensitive.
+	Value string
+
+	// Set reports whether the flag was set explicitly on the command line, by its name, an alias
+	// or a deprecated name, rather than left to its default.
+	Set bool
+}
+
+// FlagValues returns the values of the flags of f set explicitly on the command line, keyed by
+// their canonical names, the values of the sensitive flags Redacted. The wrappers of this package
+// rendering the flags of an execution, like Logged and ResultFile, render these.
+func FlagValues(f *flag.FlagSet) map[string]string {
+	values := make(map[string]string)
+	for name, v := range AllFlagValues(f) {
+		if v.Set {
+			values[name] = v.Value
+		}
+	}
+
+	return values
+}
+
+// AllFlagValues is like FlagValues, but includes the flags left to their defaults, with Set
+// reporting whether each was set. The aliases and deprecated names are collapsed into the flags
+// they stand for.
+func AllFlagValues(f *flag.FlagSet) map[string]FlagValue {
+	return flagValues(f, func(fl *flag.Flag) string {
+		return flagValueString(f, fl)
+	})
+}
+
+// flagValues returns the values of the flags of f like AllFlagValues, rendered by render.
+func flagValues(f *flag.FlagSet, render func(fl *flag.Flag) string) map[string]FlagValue {
+	set := make(map[string]bool)
+	f.Visit(func(fl *flag.Flag) {
+		set[CanonicalFlagName(f, fl.Name)] = true
+	})
+
+	values := make(map[string]FlagValue)
+	f.VisitAll(func(fl *flag.Flag) {
+		if CanonicalFlagName(f, fl.Name) != fl.Name {
+			return
+		}
+		values[fl.Name] = FlagValue{Value: render(fl), Set: set[fl.Name]}
+	})
+
+	return values
+}
+
+// sortedFlagNames returns the names of values in lexical order, like flag.FlagSet.Visit.
+func sortedFlagNames[V any](values map[string]V) []string {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
@@ -0,0 +1,79 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"flag"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/zchee/subcommandsutil"
+)
+
+func TestFlagValues(t *testing.T) {
+	tests := map[string]struct {
+		args    []string
+		want    map[string]string
+		wantAll map[string]subcommandsutil.FlagValue
+	}{
+		"when no flag is set": {
+			want: map[string]string{},
+			wantAll: map[string]subcommandsutil.FlagValue{
+				"output":  {Value: "text"},
+				"token":   {Value: subcommandsutil.Redacted},
+				"verbose": {Value: "false"},
+			},
+		},
+		"when the flags are set": {
+			args: []string{"-token", "s3cr3t", "-verbose"},
+			want: map[string]string{"token": subcommandsutil.Redacted, "verbose": "true"},
+			wantAll: map[string]subcommandsutil.FlagValue{
+				"output":  {Value: "text"},
+				"token":   {Value: subcommandsutil.Redacted, Set: true},
+				"verbose": {Value: "true", Set: true},
+			},
+		},
+		"when the flags are set by an alias and a deprecated name": {
+			args: []string{"-o", "json", "-format", "yaml"},
+			want: map[string]string{"output": "yaml"},
+			wantAll: map[string]subcommandsutil.FlagValue{
+				"output":  {Value: "yaml", Set: true},
+				"token":   {Value: subcommandsutil.Redacted},
+				"verbose": {Value: "false"},
+			},
+		},
+		"when a flag is set to its default": {
+			args: []string{"-output", "text"},
+			want: map[string]string{"output": "text"},
+			wantAll: map[string]subcommandsutil.FlagValue{
+				"output":  {Value: "text", Set: true},
+				"token":   {Value: subcommandsutil.Redacted},
+				"verbose": {Value: "false"},
+			},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			f := flag.NewFlagSet("push", flag.ContinueOnError)
+			f.SetOutput(io.Discard)
+			f.String("output", "text", "output format")
+			f.String("token", "", "API token")
+			f.Bool("verbose", false, "verbose output")
+			subcommandsutil.AliasFlag(f, "output", "o")
+			subcommandsutil.DeprecateFlag(f, "format", "output", "")
+			subcommandsutil.MarkSensitive(f, "token")
+			if err := f.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := subcommandsutil.FlagValues(f); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("wanted the values %v but got %v", tt.want, got)
+			}
+			if got := subcommandsutil.AllFlagValues(f); !reflect.DeepEqual(got, tt.wantAll) {
+				t.Fatalf("wanted all the values %+v but got %+v", tt.wantAll, got)
+			}
+		})
+	}
+}
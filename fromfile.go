@@ -0,0 +1,145 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// DefaultFromFileLimit is the default size limit, in bytes, of the files read by FromFile.
+const DefaultFromFileLimit = 1 << 20
+
+// FromFileOption is an option of FromFile.
+type FromFileOption interface {
+	applyFromFile(*fromFile)
+}
+
+// fromFileOptionFunc is a FromFileOption implemented by a function.
+type fromFileOptionFunc func(*fromFile)
+
+// applyFromFile implements FromFileOption.
+func (fn fromFileOptionFunc) applyFromFile(v *fromFile) { fn(v) }
+
+// WithFileSizeLimit sets the size limit, in bytes, of the files read by FromFile. It defaults to
+// DefaultFromFileLimit.
+func WithFileSizeLimit(n int64) FromFileOption {
+	return fromFileOptionFunc(func(v *fromFile) {
+		v.limit = n
+	})
+}
+
+// WithTrimNewline makes FromFile remove a single trailing newline, "\n" or "\r\n", from the
+// contents of the files.
+func WithTrimNewline() FromFileOption {
+	return fromFileOptionFunc(func(v *fromFile) {
+		v.trim = true
+	})
+}
+
+// WithFromFileStdin makes FromFile read "@-" from r instead of os.Stdin.
+func WithFromFileStdin(r io.Reader) FromFileOption {
+	return fromFileOptionFunc(func(v *fromFile) {
+		v.stdin = r
+	})
+}
+
+// fromFile is a flag.Value reading the values starting with "@" from files.
+type fromFile struct {
+	inner flag.Value
+	limit int64
+	trim  bool
+	stdin io.Reader
+}
+
+// make sure fromFile implements the flag.Getter interface.
+var _ flag.Getter = (*fromFile)(nil)
+
+// FromFile wraps inner so that a value of the form "@path" sets inner to the contents of the file
+// at path, and "@-" to the contents of the standard input. To wrap a flag defined by f.String:
+//
+//	fl := f.Lookup("query")
+//	fl.Value = subcommandsutil.FromFile(fl.Value)
+//
+// A value starting with "@@" sets inner to the value without its first "@". The other values are
+// passed to inner as they are. Files larger than the limit set by WithFileSizeLimit are rejected.
+func FromFile(inner flag.Value, opts ...FromFileOption) flag.Value {
+	v := &fromFile{
+		inner: inner,
+		limit: DefaultFromFileLimit,
+		stdin: os.Stdin,
+	}
+	for _, opt := range opts {
+		opt.applyFromFile(v)
+	}
+
+	return v
+}
+
+// Set implements flag.Value.
+func (v *fromFile) Set(s string) error {
+	switch {
+	case strings.HasPrefix(s, "@@"):
+		return v.inner.Set(s[1:])
+	case strings.HasPrefix(s, "@"):
+		data, err := v.read(s[1:])
+		if err != nil {
+			return err
+		}
+		return v.inner.Set(data)
+	default:
+		return v.inner.Set(s)
+	}
+}
+
+// read returns the contents of the file at path, or of v.stdin if path is "-".
+func (v *fromFile) read(path string) (string, error) {
+	r := v.stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return "", err
+		}
+		defer f.Close()
+		r = f
+	} else {
+		path = "the standard input"
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r, v.limit+1))
+	if err != nil {
+		return "", fmt.Errorf("reading %s: %w", path, err)
+	}
+	if int64(len(data)) > v.limit {
+		return "", fmt.Errorf("%s is larger than %d bytes", path, v.limit)
+	}
+
+	s := string(data)
+	if v.trim && strings.HasSuffix(s, "\n") {
+		s = strings.TrimSuffix(strings.TrimSuffix(s, "\n"), "\r")
+	}
+
+	return s, nil
+}
+
+// String implements flag.Value.
+func (v *fromFile) String() string {
+	if v == nil || v.inner == nil {
+		return ""
+	}
+
+	return v.inner.String()
+}
+
+// Get implements flag.Getter, returning the value of inner if it is a flag.Getter.
+func (v *fromFile) Get() interface{} {
+	if g, ok := v.inner.(flag.Getter); ok {
+		return g.Get()
+	}
+
+	return v.inner.String()
+}
@@ -0,0 +1,85 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/zchee/subcommandsutil"
+)
+
+func TestFromFile(t *testing.T) {
+	dir := t.TempDir()
+	query := filepath.Join(dir, "query.sql")
+	if err := os.WriteFile(query, []byte("SELECT 1;\r\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]struct {
+		value   string
+		opts    []subcommandsutil.FromFileOption
+		want    string
+		wantErr string
+	}{
+		"when the value is literal": {
+			value: "SELECT 2;",
+			want:  "SELECT 2;",
+		},
+		"when the value is read from a file": {
+			value: "@" + query,
+			want:  "SELECT 1;\r\n",
+		},
+		"when the trailing newline is trimmed": {
+			value: "@" + query,
+			opts:  []subcommandsutil.FromFileOption{subcommandsutil.WithTrimNewline()},
+			want:  "SELECT 1;",
+		},
+		"when the value is read from stdin": {
+			value: "@-",
+			opts:  []subcommandsutil.FromFileOption{subcommandsutil.WithFromFileStdin(strings.NewReader("SELECT 3;\n"))},
+			want:  "SELECT 3;\n",
+		},
+		"when the at sign is escaped": {
+			value: "@@admin",
+			want:  "@admin",
+		},
+		"when the file does not exist": {
+			value:   "@" + filepath.Join(dir, "missing.sql"),
+			wantErr: `invalid value "@` + filepath.Join(dir, "missing.sql") + `" for flag -query: open ` + filepath.Join(dir, "missing.sql") + ": no such file or directory",
+		},
+		"when the file is too large": {
+			value:   "@-",
+			opts:    []subcommandsutil.FromFileOption{subcommandsutil.WithFromFileStdin(strings.NewReader("SELECT 4;")), subcommandsutil.WithFileSizeLimit(4)},
+			wantErr: `invalid value "@-" for flag -query: the standard input is larger than 4 bytes`,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			f := flag.NewFlagSet("sql", flag.ContinueOnError)
+			f.SetOutput(io.Discard)
+			got := f.String("query", "", "run the SQL `query`")
+			fl := f.Lookup("query")
+			fl.Value = subcommandsutil.FromFile(fl.Value, tt.opts...)
+
+			err := f.Parse([]string{"-query", tt.value})
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("wanted the error %q but got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if *got != tt.want {
+				t.Fatalf("wanted %q but got %q", tt.want, *got)
+			}
+		})
+	}
+}
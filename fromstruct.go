@@ -0,0 +1,223 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"reflect"
+	"strings"
+	"unicode"
+
+	"github.com/google/subcommands"
+)
+
+// The types of the methods of FromStruct.
+var (
+	contextType    = reflect.TypeOf((*context.Context)(nil)).Elem()
+	flagSetType    = reflect.TypeOf((*flag.FlagSet)(nil))
+	argsType       = reflect.TypeOf([]interface{}(nil))
+	errorType      = reflect.TypeOf((*error)(nil)).Elem()
+	exitStatusType = reflect.TypeOf(subcommands.ExitStatus(0))
+	stringType     = reflect.TypeOf("")
+)
+
+// FromStructOption is an option of FromStruct.
+type FromStructOption interface {
+	applyFromStruct(*fromStruct)
+}
+
+// fromStructOptionFunc is a FromStructOption implemented by a function.
+type fromStructOptionFunc func(*fromStruct)
+
+// applyFromStruct implements FromStructOption.
+func (fn fromStructOptionFunc) applyFromStruct(c *fromStruct) { fn(c) }
+
+// WithMethodPrefix makes FromStruct consider only the methods named with prefix, like "Run", which
+// is removed from the names of the commands: RunSync is the sync command.
+func WithMethodPrefix(prefix string) FromStructOption {
+	return fromStructOptionFunc(func(c *fromStruct) {
+		c.prefix = prefix
+	})
+}
+
+// fromStruct is the configuration of FromStruct.
+type fromStruct struct {
+	prefix string
+}
+
+// FromStruct returns a command for each exported method of v, typically a pointer to a struct,
+// taking a context.Context and a *flag.FlagSet first, or named with the prefix of
+// WithMethodPrefix, in the order of their names. A method must be one of:
+//
+//	func(ctx context.Context, f *flag.FlagSet) error
+//	func(ctx context.Context, f *flag.FlagSet, args ...interface{}) error
+//	func(ctx context.Context, f *flag.FlagSet) subcommands.ExitStatus
+//	func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus
+//
+// An error is printed and mapped to the ExitStatus like CommandFuncE does. The name of the command
+// is the one of the method in kebab case, RunSync being run-sync, without the prefix of
+// WithMethodPrefix. For a method Xxx, the companion methods, all optional, are:
+//
+//	func (s *Service) XxxDoc() (synopsis, usage string)
+//	func (s *Service) XxxFlags(f *flag.FlagSet)
+//
+// Instead of XxxDoc, the synopsis and usage can be the synopsis and usage tags of a field of the
+// struct named XxxDoc:
+//
+//	type Service struct {
+//		RunSyncDoc struct{} `synopsis:"sync the mirrors" usage:"sync [-n]\n"`
+//	}
+//
+// FromStruct panics, naming the method, if the signature of a command method or of a companion
+// method is not one of these.
+func FromStruct(v interface{}, opts ...FromStructOption) []subcommands.Command {
+	c := &fromStruct{}
+	for _, opt := range opts {
+		opt.applyFromStruct(c)
+	}
+
+	rv := reflect.ValueOf(v)
+	rt := rv.Type()
+	var cmds []subcommands.Command
+	for i := 0; i < rt.NumMethod(); i++ {
+		m := rt.Method(i)
+		if !strings.HasPrefix(m.Name, c.prefix) || c.prefix == "" && !takesFlagSet(m.Type) || isCompanion(rt, m.Name) {
+			continue
+		}
+		run := structMethodRun(rt, m)
+		name := kebabCase(strings.TrimPrefix(m.Name, c.prefix))
+		if name == "" {
+			panic(fmt.Sprintf("subcommandsutil: method %s of %v: no command name without the prefix %q", m.Name, rt, c.prefix))
+		}
+
+		method := rv.Method(i)
+		synopsis, usage := structMethodDoc(rv, m.Name)
+		var setFlags func(f *flag.FlagSet)
+		if fm, ok := rt.MethodByName(m.Name + "Flags"); ok {
+			if fm.Type.NumIn() != 2 || fm.Type.In(1) != flagSetType || fm.Type.NumOut() != 0 {
+				panic(fmt.Sprintf("subcommandsutil: method %s of %v: wanted func(*flag.FlagSet) but got %v", fm.Name, rt, methodFuncType(fm.Type)))
+			}
+			flags := rv.MethodByName(fm.Name)
+			setFlags = func(f *flag.FlagSet) { flags.Call([]reflect.Value{reflect.ValueOf(f)}) }
+		}
+
+		cmds = append(cmds, CommandFunc(name, synopsis, usage, func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+			return run(method, name, ctx, f, args)
+		}, WithFlagsFunc(setFlags)))
+	}
+
+	return cmds
+}
+
+// isCompanion reports whether the method named name of rt is the XxxDoc or XxxFlags companion of
+// a method Xxx.
+func isCompanion(rt reflect.Type, name string) bool {
+	for _, suffix := range []string{"Doc", "Flags"} {
+		if base, ok := strings.CutSuffix(name, suffix); ok && base != "" {
+			if _, ok := rt.MethodByName(base); ok {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// takesFlagSet reports whether the method of type t, its receiver first, takes a context.Context
+// and a *flag.FlagSet first.
+func takesFlagSet(t reflect.Type) bool {
+	return t.NumIn() > 2 && t.In(1) == contextType && t.In(2) == flagSetType
+}
+
+// structMethodRun returns the function calling the method m of rt, panicking if its signature is
+// not one of the ones of FromStruct.
+func structMethodRun(rt reflect.Type, m reflect.Method) func(method reflect.Value, name string, ctx context.Context, f *flag.FlagSet, args []interface{}) subcommands.ExitStatus {
+	t := m.Type
+	variadic := t.IsVariadic()
+	if !takesFlagSet(t) || !(t.NumIn() == 3 || variadic && t.NumIn() == 4 && t.In(3) == argsType) || t.NumOut() != 1 || t.Out(0) != errorType && t.Out(0) != exitStatusType {
+		panic(fmt.Sprintf("subcommandsutil: method %s of %v: wanted func(context.Context, *flag.FlagSet[, ...interface{}]) error or subcommands.ExitStatus but got %v", m.Name, rt, methodFuncType(t)))
+	}
+	returnsError := t.Out(0) == errorType
+
+	return func(method reflect.Value, name string, ctx context.Context, f *flag.FlagSet, args []interface{}) subcommands.ExitStatus {
+		in := []reflect.Value{reflect.ValueOf(&ctx).Elem(), reflect.ValueOf(f)}
+		for _, arg := range args {
+			in = append(in, reflect.ValueOf(&arg).Elem())
+		}
+		if !variadic {
+			in = in[:2]
+		}
+		out := method.Call(in)[0]
+		if !returnsError {
+			return out.Interface().(subcommands.ExitStatus)
+		}
+
+		err, _ := out.Interface().(error)
+		if err != nil {
+			PrintError(ctx, name, err)
+		}
+		return StatusFromError(err)
+	}
+}
+
+// structMethodDoc returns the synopsis and usage of the method named name of rv, from its XxxDoc
+// companion method or field.
+func structMethodDoc(rv reflect.Value, name string) (synopsis, usage string) {
+	rt := rv.Type()
+	if dm, ok := rt.MethodByName(name + "Doc"); ok {
+		t := dm.Type
+		if t.NumIn() != 1 || t.NumOut() != 2 || t.Out(0) != stringType || t.Out(1) != stringType {
+			panic(fmt.Sprintf("subcommandsutil: method %s of %v: wanted func() (string, string) but got %v", dm.Name, rt, methodFuncType(t)))
+		}
+		out := rv.MethodByName(dm.Name).Call(nil)
+		return out[0].String(), out[1].String()
+	}
+
+	st := rt
+	if st.Kind() == reflect.Ptr {
+		st = st.Elem()
+	}
+	if st.Kind() == reflect.Struct {
+		if field, ok := st.FieldByName(name + "Doc"); ok {
+			return field.Tag.Get("synopsis"), field.Tag.Get("usage")
+		}
+	}
+
+	return "", ""
+}
+
+// methodFuncType returns the type of the function of a method of type t, without its receiver.
+func methodFuncType(t reflect.Type) reflect.Type {
+	in := make([]reflect.Type, t.NumIn()-1)
+	for i := range in {
+		in[i] = t.In(i + 1)
+	}
+	out := make([]reflect.Type, t.NumOut())
+	for i := range out {
+		out[i] = t.Out(i)
+	}
+
+	return reflect.FuncOf(in, out, t.IsVariadic())
+}
+
+// kebabCase returns name, in camel case, in kebab case: RunSync is run-sync, ServeHTTP serve-http
+// and HTTPProxy http-proxy.
+func kebabCase(name string) string {
+	runes := []rune(name)
+	var b strings.Builder
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) {
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || unicode.IsUpper(prev) && nextLower {
+				b.WriteByte('-')
+			}
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+
+	return b.String()
+}
@@ -0,0 +1,176 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+// mirrorService is a sample struct whose methods are commands.
+type mirrorService struct {
+	RunPruneDoc struct{} `synopsis:"remove stale mirrors" usage:"prune\n"`
+
+	dryRun bool
+	log    []string
+}
+
+func (s *mirrorService) RunSync(ctx context.Context, f *flag.FlagSet, args ...interface{}) error {
+	if f.NArg() == 0 {
+		return subcommandsutil.UsageErrorf("missing mirror")
+	}
+	s.log = append(s.log, fmt.Sprintf("sync %s dry-run=%v args=%d", strings.Join(f.Args(), " "), s.dryRun, len(args)))
+	return nil
+}
+
+func (s *mirrorService) RunSyncDoc() (string, string) {
+	return "sync the mirrors", "sync [-n] mirror...\n"
+}
+
+func (s *mirrorService) RunSyncFlags(f *flag.FlagSet) {
+	f.BoolVar(&s.dryRun, "n", false, "dry run")
+}
+
+func (s *mirrorService) RunPrune(ctx context.Context, f *flag.FlagSet) error {
+	return errors.New("prune failed")
+}
+
+func (s *mirrorService) RunHTTPStatus(ctx context.Context, f *flag.FlagSet) subcommands.ExitStatus {
+	s.log = append(s.log, "http status")
+	return subcommands.ExitSuccess
+}
+
+// Close is not a command.
+func (s *mirrorService) Close(ctx context.Context) error {
+	return nil
+}
+
+func TestFromStruct(t *testing.T) {
+	svc := &mirrorService{}
+	cmds := subcommandsutil.FromStruct(svc, subcommandsutil.WithMethodPrefix("Run"))
+
+	var names []string
+	for _, cmd := range cmds {
+		names = append(names, cmd.Name()+": "+cmd.Synopsis())
+	}
+	if want := []string{"http-status: ", "prune: remove stale mirrors", "sync: sync the mirrors"}; strings.Join(names, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("wanted the commands %q but got %q", want, names)
+	}
+
+	tests := map[string]struct {
+		args       []string
+		wantStatus subcommands.ExitStatus
+		wantLog    string
+		wantStderr string
+	}{
+		"when the command has flags and arguments": {
+			args:    []string{"sync", "-n", "debian", "alpine"},
+			wantLog: "sync debian alpine dry-run=true args=0",
+		},
+		"when the command returns a usage error": {
+			args:       []string{"sync"},
+			wantStatus: subcommands.ExitUsageError,
+			wantStderr: "sync: missing mirror\n",
+		},
+		"when the command returns an error": {
+			args:       []string{"prune"},
+			wantStatus: subcommands.ExitFailure,
+			wantStderr: "prune: prune failed\n",
+		},
+		"when the command returns a status": {
+			args:    []string{"http-status"},
+			wantLog: "http status",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			svc := &mirrorService{}
+			h := testcmd.NewHarness(t)
+			for _, cmd := range subcommandsutil.FromStruct(svc, subcommandsutil.WithMethodPrefix("Run")) {
+				h.Register(cmd, "")
+			}
+
+			testcmd.AssertStatus(t, h.Execute(context.Background(), tt.args...), tt.wantStatus)
+			if got := strings.Join(svc.log, "\n"); got != tt.wantLog {
+				t.Fatalf("wanted the log %q but got %q", tt.wantLog, got)
+			}
+			if got := h.Stderr.String(); got != tt.wantStderr {
+				t.Fatalf("wanted stderr %q but got %q", tt.wantStderr, got)
+			}
+		})
+	}
+}
+
+// badRunService has a command method with an invalid signature.
+type badRunService struct{}
+
+func (badRunService) RunSync(ctx context.Context, f *flag.FlagSet) string { return "" }
+
+// withoutPrefixService has a command method and a context method which is not one.
+type withoutPrefixService struct{}
+
+func (withoutPrefixService) DumpConfig(ctx context.Context, f *flag.FlagSet) error { return nil }
+func (withoutPrefixService) Close(ctx context.Context) error                       { return nil }
+
+// badFlagsService has a companion method with an invalid signature.
+type badFlagsService struct{}
+
+func (badFlagsService) RunSync(ctx context.Context, f *flag.FlagSet) error { return nil }
+func (badFlagsService) RunSyncFlags(f *flag.FlagSet) error                 { return nil }
+
+func TestFromStructSignatures(t *testing.T) {
+	tests := map[string]struct {
+		v         interface{}
+		opts      []subcommandsutil.FromStructOption
+		wantNames []string
+		wantPanic string
+	}{
+		"when the methods have no prefix": {
+			v:         withoutPrefixService{},
+			wantNames: []string{"dump-config"},
+		},
+		"when a command method has an invalid signature": {
+			v:         badRunService{},
+			opts:      []subcommandsutil.FromStructOption{subcommandsutil.WithMethodPrefix("Run")},
+			wantPanic: "subcommandsutil: method RunSync of subcommandsutil_test.badRunService: wanted func(context.Context, *flag.FlagSet[, ...interface{}]) error or subcommands.ExitStatus but got func(context.Context, *flag.FlagSet) string",
+		},
+		"when a flags method has an invalid signature": {
+			v:         badFlagsService{},
+			wantPanic: "subcommandsutil: method RunSyncFlags of subcommandsutil_test.badFlagsService: wanted func(*flag.FlagSet) but got func(*flag.FlagSet) error",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				r := recover()
+				if tt.wantPanic == "" && r != nil {
+					t.Fatalf("wanted no panic but got %v", r)
+				}
+				if tt.wantPanic != "" && fmt.Sprint(r) != tt.wantPanic {
+					t.Fatalf("wanted the panic %q but got %v", tt.wantPanic, r)
+				}
+			}()
+
+			var names []string
+			for _, cmd := range subcommandsutil.FromStruct(tt.v, tt.opts...) {
+				names = append(names, cmd.Name())
+			}
+			if tt.wantPanic != "" {
+				t.Fatalf("wanted the panic %q but got the commands %q", tt.wantPanic, names)
+			}
+			if strings.Join(names, " ") != strings.Join(tt.wantNames, " ") {
+				t.Fatalf("wanted the commands %q but got %q", tt.wantNames, names)
+			}
+		})
+	}
+}
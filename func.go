@@ -0,0 +1,96 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"context"
+	"flag"
+
+	"github.com/google/subcommands"
+)
+
+// CommandFuncOption is an option of CommandFunc and CommandFuncE.
+type CommandFuncOption interface {
+	applyCommandFunc(*commandFunc)
+}
+
+// commandFuncOptionFunc is a CommandFuncOption implemented by a function.
+type commandFuncOptionFunc func(*commandFunc)
+
+// applyCommandFunc implements CommandFuncOption.
+func (fn commandFuncOptionFunc) applyCommandFunc(c *commandFunc) { fn(c) }
+
+// WithFlagsFunc sets the function defining the flags of the command in SetFlags. Without it,
+// SetFlags does nothing.
+func WithFlagsFunc(fn func(f *flag.FlagSet)) CommandFuncOption {
+	return commandFuncOptionFunc(func(c *commandFunc) {
+		c.flags = fn
+	})
+}
+
+// commandFunc is a subcommands.Command implemented by a function.
+type commandFunc struct {
+	name     string
+	synopsis string
+	usage    string
+	flags    func(f *flag.FlagSet)
+	run      RunFunc
+}
+
+// make sure commandFunc implements the subcommands.Command interface.
+var _ subcommands.Command = (*commandFunc)(nil)
+
+// CommandFunc returns a subcommands.Command with the given metadata, executed by run.
+func CommandFunc(name, synopsis, usage string, run RunFunc, opts ...CommandFuncOption) subcommands.Command {
+	c := &commandFunc{
+		name:     name,
+		synopsis: synopsis,
+		usage:    usage,
+		run:      run,
+	}
+	for _, opt := range opts {
+		opt.applyCommandFunc(c)
+	}
+
+	return c
+}
+
+// CommandFuncE is like CommandFunc, but run returns an error. A non-nil error is printed by
+// PrintError, prefixed by the command name, and mapped to the ExitStatus by StatusFromError.
+func CommandFuncE(name, synopsis, usage string, run func(ctx context.Context, f *flag.FlagSet, args ...interface{}) error, opts ...CommandFuncOption) subcommands.Command {
+	return CommandFunc(name, synopsis, usage, func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+		err := run(ctx, f, args...)
+		if err != nil {
+			PrintError(ctx, name, err)
+		}
+		return StatusFromError(err)
+	}, opts...)
+}
+
+// Name implements subcommands.Command.
+func (c *commandFunc) Name() string {
+	return c.name
+}
+
+// Synopsis implements subcommands.Command.
+func (c *commandFunc) Synopsis() string {
+	return c.synopsis
+}
+
+// Usage implements subcommands.Command.
+func (c *commandFunc) Usage() string {
+	return c.usage
+}
+
+// SetFlags implements subcommands.Command.
+func (c *commandFunc) SetFlags(f *flag.FlagSet) {
+	if c.flags != nil {
+		c.flags(f)
+	}
+}
+
+// Execute implements subcommands.Command.
+func (c *commandFunc) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	return c.run(ctx, f, args...)
+}
@@ -0,0 +1,92 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"testing"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+func TestCommandFunc(t *testing.T) {
+	var name string
+	cmd := subcommandsutil.CommandFunc("greet", "say hello", "greet [-name NAME]\n",
+		func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+			fmt.Fprintf(subcommandsutil.Stdout(ctx), "hello %s\n", name)
+			return subcommands.ExitSuccess
+		},
+		subcommandsutil.WithFlagsFunc(func(f *flag.FlagSet) {
+			f.StringVar(&name, "name", "world", "who to greet")
+		}),
+	)
+	if cmd.Name() != "greet" || cmd.Synopsis() != "say hello" || cmd.Usage() != "greet [-name NAME]\n" {
+		t.Fatalf("wanted the metadata to be set, got %q %q %q", cmd.Name(), cmd.Synopsis(), cmd.Usage())
+	}
+
+	h := testcmd.NewHarness(t)
+	h.Register(cmd, "")
+	testcmd.RequireSuccess(t, h.Execute(context.Background(), "greet", "-name", "gopher"))
+	if want := "hello gopher\n"; h.Stdout.String() != want {
+		t.Fatalf("wanted %q but got %q", want, h.Stdout.String())
+	}
+}
+
+func TestCommandFuncNoFlags(t *testing.T) {
+	cmd := subcommandsutil.CommandFunc("noop", "", "", func(context.Context, *flag.FlagSet, ...interface{}) subcommands.ExitStatus {
+		return subcommands.ExitSuccess
+	})
+
+	f := flag.NewFlagSet("noop", flag.ContinueOnError)
+	cmd.SetFlags(f)
+	f.VisitAll(func(fl *flag.Flag) {
+		t.Fatalf("wanted no flags but got -%s", fl.Name)
+	})
+}
+
+func TestCommandFuncE(t *testing.T) {
+	tests := map[string]struct {
+		err        error
+		wantStatus subcommands.ExitStatus
+		wantStderr string
+	}{
+		"when run succeeds": {
+			wantStatus: subcommands.ExitSuccess,
+		},
+		"when run fails": {
+			err:        errors.New("disk full"),
+			wantStatus: subcommands.ExitFailure,
+			wantStderr: "save: disk full\n",
+		},
+		"when run reports a usage error": {
+			err:        subcommandsutil.UsageErrorf("missing %s", "FILE"),
+			wantStatus: subcommands.ExitUsageError,
+			wantStderr: "save: missing FILE\n",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			cmd := subcommandsutil.CommandFuncE("save", "save the file", "save FILE\n", func(context.Context, *flag.FlagSet, ...interface{}) error {
+				return tt.err
+			})
+
+			var stderr testcmd.Buffer
+			ctx := subcommandsutil.WithOutput(context.Background(), nil, &stderr)
+			status, _, err := testcmd.Run(ctx, cmd)
+			if err != nil {
+				t.Fatal(err)
+			}
+			testcmd.AssertStatus(t, status, tt.wantStatus)
+			if stderr.String() != tt.wantStderr {
+				t.Fatalf("wanted stderr %q but got %q", tt.wantStderr, stderr.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/google/subcommands"
+)
+
+// EnvGate returns a gate for Gated which is open while the environment variable name is set to a
+// true value such as "1". The variable is read each time the gate is evaluated.
+func EnvGate(name string) func() bool {
+	return func() bool {
+		enabled, _ := strconv.ParseBool(os.Getenv(name))
+		return enabled
+	}
+}
+
+// GatedOption is an option of the Gated wrapper.
+type GatedOption interface {
+	applyGated(*gated)
+}
+
+// gatedOptionFunc is a GatedOption implemented by a function.
+type gatedOptionFunc func(*gated)
+
+// applyGated implements GatedOption.
+func (fn gatedOptionFunc) applyGated(c *gated) { fn(c) }
+
+// WithGateHint sets the hint printed after the error of a Gated command run while its gate is
+// closed, such as "set MYTOOL_EXPERIMENTAL=1".
+func WithGateHint(hint string) GatedOption {
+	return gatedOptionFunc(func(c *gated) {
+		c.hint = hint
+	})
+}
+
+// gated wraps a subcommands.Command so that it is disabled while its gate is closed.
+type gated struct {
+	sub     subcommands.Command
+	enabled func() bool
+	hint    string
+}
+
+// make sure gated implements the subcommands.Command interface.
+var _ subcommands.Command = (*gated)(nil)
+
+// Gated wraps sub so that it is enabled only while enabled reports true, to ship experimental
+// commands dark. The gate is evaluated on each listing and execution, so that it can be toggled
+// without restarting the program.
+//
+// While the gate is closed, the command is hidden, as reported by IsHiddenCommand, and its
+// executions print "NAME: this command is not enabled", followed by the hint set by WithGateHint,
+// and return subcommands.ExitUsageError. While it is open, the command is sub.
+func Gated(sub subcommands.Command, enabled func() bool, opts ...GatedOption) subcommands.Command {
+	c := &gated{
+		sub:     sub,
+		enabled: enabled,
+	}
+	for _, opt := range opts {
+		opt.applyGated(c)
+	}
+
+	return c
+}
+
+// Name forwards to the underlying c.sub Command.
+func (c *gated) Name() string {
+	return c.sub.Name()
+}
+
+// Usage forwards to the underlying c.sub Command.
+func (c *gated) Usage() string {
+	return c.sub.Usage()
+}
+
+// Synopsis forwards to the underlying c.sub Command.
+func (c *gated) Synopsis() string {
+	return c.sub.Synopsis()
+}
+
+// Hidden reports that the command is hidden while its gate is closed, and whether the underlying
+// c.sub Command is hidden otherwise.
+func (c *gated) Hidden() bool {
+	return !c.enabled() || IsHiddenCommand(c.sub)
+}
+
+// Unwrap returns the underlying c.sub Command.
+func (c *gated) Unwrap() subcommands.Command {
+	return c.sub
+}
+
+// SetFlags forwards to the underlying c.sub Command.
+func (c *gated) SetFlags(f *flag.FlagSet) {
+	c.sub.SetFlags(f)
+}
+
+// Execute forwards to the underlying c.sub Command if its gate is open.
+func (c *gated) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	if !c.enabled() {
+		msg := "this command is not enabled"
+		if c.hint != "" {
+			msg += "; " + c.hint
+		}
+		fmt.Fprintf(Stderr(ctx), "%s: %s\n", c.sub.Name(), msg)
+		return subcommands.ExitUsageError
+	}
+
+	return c.sub.Execute(ctx, f, args...)
+}
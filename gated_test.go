@@ -0,0 +1,102 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+func TestGated(t *testing.T) {
+	const env = "MYTOOL_EXPERIMENTAL"
+
+	sub := testcmd.NewRecording("preview", testcmd.WithSynopsis("preview the next release"))
+	h := testcmd.NewHarness(t)
+	h.Commander.ExplainGroup = subcommandsutil.ExplainGroup(h.Commander)
+	h.Register(testcmd.NewRecording("status", testcmd.WithSynopsis("show status")), "")
+	h.Register(subcommandsutil.Gated(sub, subcommandsutil.EnvGate(env), subcommandsutil.WithGateHint("set "+env+"=1")), "")
+
+	tests := []struct {
+		name       string
+		env        string
+		wantListed bool
+		wantStatus subcommands.ExitStatus
+		wantStderr string
+		wantCalls  int
+	}{
+		{
+			name:       "when the gate is closed",
+			wantStatus: subcommands.ExitUsageError,
+			wantStderr: "preview: this command is not enabled; set MYTOOL_EXPERIMENTAL=1\n",
+		},
+		{
+			name:       "when the gate is opened",
+			env:        "1",
+			wantListed: true,
+			wantStatus: subcommands.ExitSuccess,
+			wantCalls:  1,
+		},
+		{
+			name:       "when the gate is closed again",
+			env:        "false",
+			wantStatus: subcommands.ExitUsageError,
+			wantStderr: "preview: this command is not enabled; set MYTOOL_EXPERIMENTAL=1\n",
+			wantCalls:  1,
+		},
+	}
+	// the steps share the Commander, as the gate is toggled without registering again
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(env, tt.env)
+
+			h.Stdout.Reset()
+			testcmd.RequireSuccess(t, h.Execute(context.Background(), "help"))
+			if got := strings.Contains(h.Stdout.String(), "preview"); got != tt.wantListed {
+				t.Fatalf("wanted listed %v but got %q", tt.wantListed, h.Stdout.String())
+			}
+
+			h.Stderr.Reset()
+			testcmd.AssertStatus(t, h.Execute(context.Background(), "preview"), tt.wantStatus)
+			if got := h.Stderr.String(); got != tt.wantStderr {
+				t.Fatalf("wanted the stderr %q but got %q", tt.wantStderr, got)
+			}
+			if sub.CallCount() != tt.wantCalls {
+				t.Fatalf("wanted %d calls but got %d", tt.wantCalls, sub.CallCount())
+			}
+		})
+	}
+}
+
+func TestGatedHidden(t *testing.T) {
+	tests := map[string]struct {
+		cmd  subcommands.Command
+		want bool
+	}{
+		"when the gate is open": {
+			cmd:  subcommandsutil.Gated(testcmd.NewRecording("preview"), func() bool { return true }),
+			want: false,
+		},
+		"when the gate is closed": {
+			cmd:  subcommandsutil.Gated(testcmd.NewRecording("preview"), func() bool { return false }),
+			want: true,
+		},
+		"when the gate is open on a hidden command": {
+			cmd:  subcommandsutil.Gated(subcommandsutil.Hidden(testcmd.NewRecording("preview")), func() bool { return true }),
+			want: true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := subcommandsutil.IsHiddenCommand(tt.cmd); got != tt.want {
+				t.Fatalf("wanted %v but got %v", tt.want, got)
+			}
+		})
+	}
+}
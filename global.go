@@ -0,0 +1,178 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/subcommands"
+)
+
+var (
+	globalFlagsMu sync.Mutex
+	globalFlags   []func(*flag.FlagSet)
+)
+
+// AddGlobalFlag registers flags shared by every Command wrapped with WithGlobalFlags. register is
+// called with each wrapped Command's FlagSet, after the Command's own SetFlags.
+//
+//	subcommandsutil.AddGlobalFlag(func(f *flag.FlagSet) {
+//		f.StringVar(&profile, "profile", "default", "configuration profile")
+//	})
+func AddGlobalFlag(register func(*flag.FlagSet)) {
+	globalFlagsMu.Lock()
+	defer globalFlagsMu.Unlock()
+
+	globalFlags = append(globalFlags, register)
+}
+
+// globalFlagsCommand wraps a subcommands.Command so that the global flags are added to its FlagSet.
+type globalFlagsCommand struct {
+	sub subcommands.Command
+}
+
+// make sure globalFlagsCommand implements the subcommands.Command interface.
+var _ subcommands.Command = (*globalFlagsCommand)(nil)
+
+// WithGlobalFlags wraps sub so that the flags registered by AddGlobalFlag are added to its FlagSet.
+//
+// A global flag colliding with a flag of sub is not added, and Execute reports the collision and
+// returns subcommands.ExitUsageError. The parsed global flags are available to sub through
+// GlobalFlag and its typed variants.
+func WithGlobalFlags(sub subcommands.Command) subcommands.Command {
+	return &globalFlagsCommand{
+		sub: sub,
+	}
+}
+
+// Name forwards to the underlying c.sub Command.
+func (c *globalFlagsCommand) Name() string {
+	return c.sub.Name()
+}
+
+// Usage forwards to the underlying c.sub Command.
+func (c *globalFlagsCommand) Usage() string {
+	return c.sub.Usage()
+}
+
+// Synopsis forwards to the underlying c.sub Command.
+func (c *globalFlagsCommand) Synopsis() string {
+	return c.sub.Synopsis()
+}
+
+// Unwrap returns the underlying c.sub Command.
+func (c *globalFlagsCommand) Unwrap() subcommands.Command {
+	return c.sub
+}
+
+// SetFlags forwards to the underlying c.sub Command and then adds the global flags to f.
+func (c *globalFlagsCommand) SetFlags(f *flag.FlagSet) {
+	c.sub.SetFlags(f)
+
+	globalFlagsMu.Lock()
+	registers := make([]func(*flag.FlagSet), len(globalFlags))
+	copy(registers, globalFlags)
+	globalFlagsMu.Unlock()
+
+	var names []string
+	var err error
+	for _, register := range registers {
+		probe := flag.NewFlagSet(f.Name(), flag.ContinueOnError)
+		register(probe)
+
+		probe.VisitAll(func(fl *flag.Flag) {
+			if f.Lookup(fl.Name) != nil {
+				if err == nil {
+					err = fmt.Errorf("global flag -%s collides with a flag of %q", fl.Name, c.sub.Name())
+				}
+				return
+			}
+			f.Var(fl.Value, fl.Name, fl.Usage)
+			names = append(names, fl.Name)
+		})
+	}
+
+	updateFlagMeta(f, func(m *flagMeta) {
+		for _, name := range names {
+			m.global[name] = true
+		}
+		if m.globalErr == nil {
+			m.globalErr = err
+		}
+	})
+}
+
+// Execute stores the global flags of f in ctx and forwards to the underlying c.sub Command.
+func (c *globalFlagsCommand) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	var err error
+	globals := make(map[string]*flag.Flag)
+	readFlagMeta(f, func(m *flagMeta) {
+		if m == nil {
+			return
+		}
+		err = m.globalErr
+		for name := range m.global {
+			globals[name] = f.Lookup(name)
+		}
+	})
+	if err != nil {
+		fmt.Fprintln(f.Output(), err)
+		return subcommands.ExitUsageError
+	}
+	ctx = context.WithValue(ctx, globalFlagsKey{}, globals)
+
+	return c.sub.Execute(ctx, f, args...)
+}
+
+// globalFlagsKey is the context key of the global flags stored by WithGlobalFlags.
+type globalFlagsKey struct{}
+
+// GlobalFlag returns the global flag named name parsed by the WithGlobalFlags wrapper executing
+// ctx, or nil if there is no such flag.
+func GlobalFlag(ctx context.Context, name string) *flag.Flag {
+	globals, _ := ctx.Value(globalFlagsKey{}).(map[string]*flag.Flag)
+
+	return globals[name]
+}
+
+// globalValue returns the value of the global flag named name, or nil.
+func globalValue(ctx context.Context, name string) interface{} {
+	fl := GlobalFlag(ctx, name)
+	if fl == nil {
+		return nil
+	}
+	if g, ok := fl.Value.(flag.Getter); ok {
+		return g.Get()
+	}
+
+	return fl.Value.String()
+}
+
+// GlobalString returns the value of the global string flag named name, or the empty string.
+func GlobalString(ctx context.Context, name string) string {
+	s, _ := globalValue(ctx, name).(string)
+	return s
+}
+
+// GlobalBool returns the value of the global bool flag named name, or false.
+func GlobalBool(ctx context.Context, name string) bool {
+	b, _ := globalValue(ctx, name).(bool)
+	return b
+}
+
+// GlobalInt returns the value of the global int flag named name, or 0.
+func GlobalInt(ctx context.Context, name string) int {
+	i, _ := globalValue(ctx, name).(int)
+	return i
+}
+
+// GlobalDuration returns the value of the global time.Duration flag named name, or 0.
+func GlobalDuration(ctx context.Context, name string) time.Duration {
+	d, _ := globalValue(ctx, name).(time.Duration)
+	return d
+}
@@ -0,0 +1,114 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"strings"
+	"testing"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+func TestWithGlobalFlags(t *testing.T) {
+	defer subcommandsutil.ResetGlobalFlags()
+
+	subcommandsutil.AddGlobalFlag(func(f *flag.FlagSet) {
+		f.String("profile", "default", "configuration profile")
+	})
+	subcommandsutil.AddGlobalFlag(func(f *flag.FlagSet) {
+		f.Bool("debug", false, "enable debug logging")
+	})
+
+	tests := map[string]struct {
+		args        []string
+		wantProfile string
+		wantDebug   bool
+	}{
+		"build": {
+			args:        []string{"-profile", "ci", "-local"},
+			wantProfile: "ci",
+		},
+		"push": {
+			args:        []string{"-debug", "-local"},
+			wantProfile: "default",
+			wantDebug:   true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var (
+				gotProfile string
+				gotDebug   bool
+				gotLocal   bool
+			)
+			cmd := subcommandsutil.WithGlobalFlags(testcmd.NewRecording(name,
+				testcmd.WithFlags(func(f *flag.FlagSet) {
+					f.BoolVar(&gotLocal, "local", false, "command-local flag")
+				}),
+				testcmd.WithExecute(func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+					gotProfile = subcommandsutil.GlobalString(ctx, "profile")
+					gotDebug = subcommandsutil.GlobalBool(ctx, "debug")
+					return subcommands.ExitSuccess
+				}),
+			))
+
+			f := flag.NewFlagSet(name, flag.ContinueOnError)
+			cmd.SetFlags(f)
+			if err := f.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+			if status := cmd.Execute(context.Background(), f); status != subcommands.ExitSuccess {
+				t.Fatalf("wanted %v but got %v", subcommands.ExitSuccess, status)
+			}
+
+			if !gotLocal {
+				t.Fatal("wanted the command-local flag to be set")
+			}
+			if gotProfile != tt.wantProfile {
+				t.Fatalf("wanted profile to be %q but got %q", tt.wantProfile, gotProfile)
+			}
+			if gotDebug != tt.wantDebug {
+				t.Fatalf("wanted debug to be %t but got %t", tt.wantDebug, gotDebug)
+			}
+		})
+	}
+}
+
+func TestWithGlobalFlagsCollision(t *testing.T) {
+	defer subcommandsutil.ResetGlobalFlags()
+
+	subcommandsutil.AddGlobalFlag(func(f *flag.FlagSet) {
+		f.String("profile", "default", "configuration profile")
+	})
+
+	cmd := subcommandsutil.WithGlobalFlags(testcmd.NewRecording("collide",
+		testcmd.WithFlags(func(f *flag.FlagSet) {
+			f.String("profile", "", "command-local profile")
+		}),
+	))
+
+	// a FlagSet only used for the usage is not executed
+	cmd.SetFlags(flag.NewFlagSet("collide", flag.ContinueOnError))
+
+	var out bytes.Buffer
+	f := flag.NewFlagSet("collide", flag.ContinueOnError)
+	f.SetOutput(&out)
+	cmd.SetFlags(f)
+
+	for i := 0; i < 2; i++ {
+		out.Reset()
+		if status := cmd.Execute(context.Background(), f); status != subcommands.ExitUsageError {
+			t.Fatalf("wanted %v but got %v", subcommands.ExitUsageError, status)
+		}
+		if !strings.Contains(out.String(), `global flag -profile collides with a flag of "collide"`) {
+			t.Fatalf("wanted a collision error but got %q", out.String())
+		}
+	}
+}
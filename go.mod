@@ -1,5 +1,8 @@
 module github.com/zchee/subcommandsutil
 
-go 1.16
+go 1.21
 
-require github.com/google/subcommands v1.2.0
+require (
+	github.com/google/subcommands v1.2.0
+	golang.org/x/sys v0.30.0
+)
@@ -0,0 +1,194 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/google/subcommands"
+)
+
+// Group is a Command dispatching to nested commands, like "remote" in "git remote add origin URL".
+// Its Execute builds a subcommands.Commander over the remaining arguments, so each level has its
+// own help command and listing. The listing omits hidden commands, as ExplainGroup does.
+type Group struct {
+	name     string
+	synopsis string
+	entries  []groupEntry
+}
+
+// groupEntry is a Command registered in a Group.
+type groupEntry struct {
+	cmd      subcommands.Command
+	category string
+}
+
+// make sure Group implements the subcommands.Command interface.
+var _ subcommands.Command = (*Group)(nil)
+
+// NewGroup returns a new Group command.
+func NewGroup(name, synopsis string) *Group {
+	return &Group{
+		name:     name,
+		synopsis: synopsis,
+	}
+}
+
+// Register registers sub in category of g. Like subcommands.Commander, commands are listed by
+// category.
+func (g *Group) Register(sub subcommands.Command, category string) {
+	g.entries = append(g.entries, groupEntry{cmd: sub, category: category})
+}
+
+// Name implements subcommands.Command.
+func (g *Group) Name() string {
+	return g.name
+}
+
+// Synopsis implements subcommands.Command.
+func (g *Group) Synopsis() string {
+	return g.synopsis
+}
+
+// Usage implements subcommands.Command. It lists the registered commands.
+func (g *Group) Usage() string {
+	var b strings.Builder
+	g.commander(flag.NewFlagSet(g.name, flag.ContinueOnError), g.name, &b, &b).Explain(&b)
+
+	return b.String()
+}
+
+// SetFlags implements subcommands.Command. A Group has no flags of its own.
+func (g *Group) SetFlags(*flag.FlagSet) {}
+
+// Execute implements subcommands.Command. It dispatches to the command named by the first argument
+// of f, with the following arguments as the arguments of that command. The command is executed
+// with its name appended to the CommandPath of g.
+//
+// If the command is not registered, Execute prints suggestions of close command names and lists the
+// registered commands to the Stderr of ctx, and returns subcommands.ExitUsageError.
+func (g *Group) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	// a Group dispatched by another Group is already in the path
+	names := CommandPath(ctx)
+	if ctx.Value(commandPathKey{}) == nil {
+		names = []string{g.name}
+	}
+	stderr := Stderr(ctx)
+
+	top := flag.NewFlagSet(strings.Join(names, " "), flag.ContinueOnError)
+	top.SetOutput(stderr)
+	// f.Args() are already parsed, a "--" in them is an argument of the command
+	if err := top.Parse(append([]string{"--"}, f.Args()...)); err != nil {
+		return subcommands.ExitUsageError
+	}
+	cdr := g.commander(top, strings.Join(names, " "), Stdout(ctx), stderr)
+
+	name := top.Arg(0)
+	if name != "" && !isRegistered(cdr, name) {
+		fmt.Fprintf(stderr, "%s: %s\n", strings.Join(names, " "), unknownCommand(MessagesFromContext(ctx), name, CommandSuggestions(cdr, name, false)))
+	}
+	if name != "" {
+		names = append(names, name)
+	}
+
+	return cdr.Execute(withDispatchArgs(withCommandPath(ctx, names), top), args...)
+}
+
+// commander returns a Commander named name over top, dispatching to the commands of g and writing
+// to stdout and stderr.
+func (g *Group) commander(top *flag.FlagSet, name string, stdout, stderr io.Writer) *subcommands.Commander {
+	cdr := subcommands.NewCommander(top, name)
+	cdr.Output = stdout
+	cdr.Error = stderr
+	cdr.ExplainGroup = ExplainGroup(cdr)
+	for _, e := range g.entries {
+		cdr.Register(&groupCommand{sub: e.cmd, stderr: stderr}, e.category)
+	}
+	cdr.Register(cdr.HelpCommand(), "")
+
+	return cdr
+}
+
+// groupCommand wraps a Command registered in a Group so that its FlagSet writes to the Stderr of
+// the context of the Group.
+type groupCommand struct {
+	sub    subcommands.Command
+	stderr io.Writer
+}
+
+// make sure groupCommand implements the subcommands.Command interface.
+var _ subcommands.Command = (*groupCommand)(nil)
+
+// Name forwards to the underlying c.sub Command.
+func (c *groupCommand) Name() string {
+	return c.sub.Name()
+}
+
+// Usage forwards to the underlying c.sub Command.
+func (c *groupCommand) Usage() string {
+	return c.sub.Usage()
+}
+
+// Synopsis forwards to the underlying c.sub Command.
+func (c *groupCommand) Synopsis() string {
+	return c.sub.Synopsis()
+}
+
+// Unwrap returns the underlying c.sub Command.
+func (c *groupCommand) Unwrap() subcommands.Command {
+	return c.sub
+}
+
+// SetFlags sets the output of f to the Stderr of the Group and forwards to the underlying c.sub
+// Command.
+func (c *groupCommand) SetFlags(f *flag.FlagSet) {
+	f.SetOutput(c.stderr)
+	c.sub.SetFlags(f)
+}
+
+// Execute forwards to the underlying c.sub Command with f carried by ctx.
+func (c *groupCommand) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	return c.sub.Execute(WithFlagSet(ctx, f), f, args...)
+}
+
+// commandPathKey is the context key of the command path.
+type commandPathKey struct{}
+
+// withCommandPath returns a copy of ctx carrying names as its command path.
+func withCommandPath(ctx context.Context, names []string) context.Context {
+	return context.WithValue(ctx, commandPathKey{}, names)
+}
+
+// CommandPath returns the names of the Groups the command executed with ctx was dispatched
+// through, followed by its own name, such as ["remote", "add"]. It is nil for commands not
+// dispatched by a Group.
+func CommandPath(ctx context.Context) []string {
+	names, _ := ctx.Value(commandPathKey{}).([]string)
+
+	return append([]string(nil), names...)
+}
+
+// flagSetKey is the context key of the FlagSet of the execution.
+type flagSetKey struct{}
+
+// WithFlagSet returns a copy of ctx carrying f, the parsed FlagSet of the command executed with
+// it. Groups, Shell and Cancelable set it before executing a command; dispatchers of their own
+// should too.
+func WithFlagSet(ctx context.Context, f *flag.FlagSet) context.Context {
+	return context.WithValue(ctx, flagSetKey{}, f)
+}
+
+// FlagSetFromContext returns the parsed FlagSet of the command executed with ctx, the innermost
+// one for a command dispatched by nested Groups, so that the helpers it calls can consult its
+// flags. It reports false if no FlagSet was set by WithFlagSet. The FlagSet is shared with the
+// command and must only be read.
+func FlagSetFromContext(ctx context.Context) (*flag.FlagSet, bool) {
+	f, ok := ctx.Value(flagSetKey{}).(*flag.FlagSet)
+
+	return f, ok && f != nil
+}
@@ -0,0 +1,184 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+// pathPrinter returns a command printing its CommandPath and arguments.
+func pathPrinter(name string) *testcmd.Recording {
+	return testcmd.NewRecording(name,
+		testcmd.WithSynopsis(name+" things"),
+		testcmd.WithUsage(name+" [-v] ARG...\n"),
+		testcmd.WithFlags(func(f *flag.FlagSet) {
+			f.Bool("v", false, "verbose")
+		}),
+		testcmd.WithExecute(func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+			fmt.Fprintf(subcommandsutil.Stdout(ctx), "%s: %s\n", strings.Join(subcommandsutil.CommandPath(ctx), " "), strings.Join(f.Args(), " "))
+			return subcommands.ExitSuccess
+		}),
+	)
+}
+
+// newGroupHarness returns a Harness with "remote add", "remote remove" and "remote branch rename".
+func newGroupHarness(t *testing.T) *testcmd.Harness {
+	branch := subcommandsutil.NewGroup("branch", "manage remote branches")
+	branch.Register(pathPrinter("rename"), "")
+	remote := subcommandsutil.NewGroup("remote", "manage remotes")
+	remote.Register(pathPrinter("add"), "edit")
+	remote.Register(pathPrinter("remove"), "edit")
+	remote.Register(branch, "")
+
+	h := testcmd.NewHarness(t)
+	h.Register(remote, "")
+
+	return h
+}
+
+func TestGroup(t *testing.T) {
+	tests := map[string]struct {
+		argv       []string
+		wantStatus subcommands.ExitStatus
+		wantStdout string
+		wantStderr string
+	}{
+		"when dispatching one level deep": {
+			argv:       []string{"remote", "add", "-v", "origin", "https://example.com"},
+			wantStdout: "remote add: origin https://example.com\n",
+		},
+		"when dispatching two levels deep": {
+			argv:       []string{"remote", "branch", "rename", "main", "trunk"},
+			wantStdout: "remote branch rename: main trunk\n",
+		},
+		"when the inner command is unknown": {
+			argv:       []string{"remote", "ad"},
+			wantStatus: subcommands.ExitUsageError,
+			wantStderr: "remote: unknown command \"ad\"; did you mean \"add\"?\nUsage: remote <flags> <subcommand> <subcommand args>",
+		},
+		"when no inner command is given": {
+			argv:       []string{"remote", "branch"},
+			wantStatus: subcommands.ExitUsageError,
+			wantStderr: "Usage: remote branch <flags> <subcommand> <subcommand args>",
+		},
+		"when the inner flags are invalid": {
+			argv:       []string{"remote", "add", "-x"},
+			wantStatus: subcommands.ExitUsageError,
+			wantStderr: "flag provided but not defined: -x",
+		},
+		"when asking the inner help": {
+			argv:       []string{"remote", "help", "add"},
+			wantStdout: "add [-v] ARG...\n",
+		},
+		"when asking the top-level help": {
+			argv:       []string{"help", "remote"},
+			wantStdout: "Usage: remote <flags> <subcommand> <subcommand args>",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			h := newGroupHarness(t)
+			testcmd.AssertStatus(t, h.Execute(context.Background(), tt.argv...), tt.wantStatus)
+			if !strings.HasPrefix(h.Stdout.String(), tt.wantStdout) {
+				t.Fatalf("wanted stdout to start with %q but got %q", tt.wantStdout, h.Stdout.String())
+			}
+			if !strings.HasPrefix(h.Stderr.String(), tt.wantStderr) {
+				t.Fatalf("wanted stderr to start with %q but got %q", tt.wantStderr, h.Stderr.String())
+			}
+		})
+	}
+}
+
+func TestGroupUsage(t *testing.T) {
+	h := newGroupHarness(t)
+	testcmd.RequireSuccess(t, h.Execute(context.Background(), "remote", "help"))
+
+	usage := h.Stdout.String()
+	for _, want := range []string{"branch", "manage remote branches", "Subcommands for edit:", "add", "add things", "remove"} {
+		if !strings.Contains(usage, want) {
+			t.Fatalf("wanted the listing to contain %q but got %q", want, usage)
+		}
+	}
+}
+
+func TestCommandPath(t *testing.T) {
+	if path := subcommandsutil.CommandPath(context.Background()); path != nil {
+		t.Fatalf("wanted no path outside a Group but got %q", path)
+	}
+}
+
+// flagReader returns a command printing the -v flag of the FlagSet of its context.
+func flagReader(name string) *testcmd.Recording {
+	return testcmd.NewRecording(name,
+		testcmd.WithFlags(func(f *flag.FlagSet) {
+			f.Bool("v", false, "verbose")
+		}),
+		testcmd.WithExecute(func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+			fs, ok := subcommandsutil.FlagSetFromContext(ctx)
+			if !ok {
+				fmt.Fprintln(subcommandsutil.Stdout(ctx), "no FlagSet")
+				return subcommands.ExitFailure
+			}
+			fmt.Fprintf(subcommandsutil.Stdout(ctx), "%s -v=%s\n", fs.Name(), fs.Lookup("v").Value)
+			return subcommands.ExitSuccess
+		}),
+	)
+}
+
+func TestFlagSetFromContext(t *testing.T) {
+	tests := map[string]struct {
+		cmd  func() subcommands.Command
+		args []string
+		want string
+	}{
+		"when the command is dispatched by a Group": {
+			cmd: func() subcommands.Command {
+				remote := subcommandsutil.NewGroup("remote", "manage remotes")
+				remote.Register(flagReader("add"), "")
+				return remote
+			},
+			args: []string{"add", "-v"},
+			want: "add -v=true\n",
+		},
+		"when the command is dispatched by nested Groups": {
+			cmd: func() subcommands.Command {
+				branch := subcommandsutil.NewGroup("branch", "manage remote branches")
+				branch.Register(flagReader("rename"), "")
+				remote := subcommandsutil.NewGroup("remote", "manage remotes")
+				remote.Register(branch, "")
+				return remote
+			},
+			args: []string{"branch", "rename", "-v"},
+			want: "rename -v=true\n",
+		},
+		"when the command is Cancelable": {
+			cmd:  func() subcommands.Command { return subcommandsutil.Cancelable(flagReader("add")) },
+			args: []string{"-v=false"},
+			want: "add -v=false\n",
+		},
+		"when nothing sets the FlagSet": {
+			cmd:  func() subcommands.Command { return flagReader("add") },
+			want: "no FlagSet\n",
+		},
+	}
+	for name, tt := range tests {
+		tt := tt
+		t.Run(name, func(t *testing.T) {
+			var stdout testcmd.Buffer
+			testcmd.Run(subcommandsutil.WithOutput(context.Background(), &stdout, &testcmd.Buffer{}), tt.cmd(), tt.args...)
+			if got := stdout.String(); got != tt.want {
+				t.Fatalf("wanted the output %q but got %q", tt.want, got)
+			}
+		})
+	}
+}
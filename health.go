@@ -0,0 +1,232 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"github.com/google/subcommands"
+)
+
+// Readier is implemented by the commands which report their readiness to serve, polled by the
+// /readyz endpoint of HealthServer.
+type Readier interface {
+	// Ready reports whether the command is ready to serve.
+	Ready() bool
+}
+
+// stoppingKey is the context key of the stoppingHooks of an execution.
+type stoppingKey struct{}
+
+// stoppingHooks holds the functions called when the execution is requested to stop, before it is
+// canceled: when CancelOnSignal receives a signal, before its lame-duck delay, or when Cancelable
+// starts draining. It is shared by the wrappers of an execution.
+type stoppingHooks struct {
+	mu      sync.Mutex
+	stopped bool
+	fns     map[*func()]func()
+}
+
+// withStopping returns ctx carrying stoppingHooks, the ones ctx already carries if any.
+func withStopping(ctx context.Context) (context.Context, *stoppingHooks) {
+	if h, ok := ctx.Value(stoppingKey{}).(*stoppingHooks); ok {
+		return ctx, h
+	}
+
+	h := &stoppingHooks{}
+	return context.WithValue(ctx, stoppingKey{}, h), h
+}
+
+// stop calls the registered functions, once.
+func (h *stoppingHooks) stop() {
+	h.mu.Lock()
+	if h.stopped {
+		h.mu.Unlock()
+		return
+	}
+	h.stopped = true
+	fns := make([]func(), 0, len(h.fns))
+	for _, fn := range h.fns {
+		fns = append(fns, fn)
+	}
+	h.mu.Unlock()
+
+	for _, fn := range fns {
+		fn()
+	}
+}
+
+// onStopping registers fn to be called when the execution of ctx is requested to stop, or calls it
+// now if it already was. The returned function unregisters fn. It does nothing if ctx carries no
+// stoppingHooks.
+func onStopping(ctx context.Context, fn func()) (remove func()) {
+	h, ok := ctx.Value(stoppingKey{}).(*stoppingHooks)
+	if !ok {
+		return func() {}
+	}
+
+	key := &fn
+	h.mu.Lock()
+	if h.stopped {
+		h.mu.Unlock()
+		fn()
+		return func() {}
+	}
+	if h.fns == nil {
+		h.fns = make(map[*func()]func())
+	}
+	h.fns[key] = fn
+	h.mu.Unlock()
+
+	return func() {
+		h.mu.Lock()
+		delete(h.fns, key)
+		h.mu.Unlock()
+	}
+}
+
+// healthKey is the context key of the health of a HealthServer execution.
+type healthKey struct{}
+
+// health is the state of an execution served by HealthServer.
+type health struct {
+	readier  Readier // nil if the command is not a Readier
+	ready    int32   // accessed atomically
+	stopping int32   // accessed atomically
+}
+
+// isReady reports whether the execution is ready.
+func (h *health) isReady() bool {
+	return atomic.LoadInt32(&h.ready) == 1 || (h.readier != nil && h.readier.Ready())
+}
+
+// ServeHTTP implements http.Handler.
+func (h *health) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == "/healthz":
+		fmt.Fprintln(w, "ok")
+	case r.URL.Path != "/readyz":
+		http.NotFound(w, r)
+	case atomic.LoadInt32(&h.stopping) == 1:
+		http.Error(w, "stopping", http.StatusServiceUnavailable)
+	case !h.isReady():
+		http.Error(w, "not ready", http.StatusServiceUnavailable)
+	default:
+		fmt.Fprintln(w, "ok")
+	}
+}
+
+// HealthServerOption is an option of the HealthServer wrapper.
+type HealthServerOption interface {
+	applyHealthServer(*healthServer)
+}
+
+// applyHealthServer implements HealthServerOption.
+func (o LoggerOption) applyHealthServer(c *healthServer) {
+	c.logger = o.logger
+}
+
+// healthServer wraps a subcommands.Command so that a health HTTP server runs during its executions.
+type healthServer struct {
+	sub    subcommands.Command
+	logger Logger
+
+	addr string
+}
+
+// make sure healthServer implements the CancelableCommand interface.
+var _ CancelableCommand = (*healthServer)(nil)
+
+// HealthServer wraps sub with the -health-addr flag. When set, an HTTP server listens on its
+// address for the duration of Execute, for the probes of an orchestrator:
+//
+//   - /healthz responds 200 while Execute runs.
+//   - /readyz responds 200 once sub is ready, when it calls NotifyReady or, if sub is or wraps a
+//     Readier, while Ready reports true; and 503 before, and once the execution is requested to
+//     stop: when a CancelOnSignal wrapper receives a signal, before its lame-duck delay, or when a
+//     Cancelable wrapper starts draining a Drainer.
+//
+// The bound address, useful with a port of 0, is logged to the standard logger unless WithLogger
+// is given; an address the server cannot listen on is logged, and sub executed anyway. The server
+// is shut down when Execute returns, or when a Cancelable wrapper stops waiting for sub. Dispose
+// forwards to the Dispose method of sub, if any.
+func HealthServer(sub subcommands.Command, opts ...HealthServerOption) CancelableCommand {
+	c := &healthServer{
+		sub:    sub,
+		logger: stdLogger{},
+	}
+	for _, opt := range opts {
+		opt.applyHealthServer(c)
+	}
+
+	return c
+}
+
+// Name forwards to the underlying c.sub Command.
+func (c *healthServer) Name() string {
+	return c.sub.Name()
+}
+
+// Usage forwards to the underlying c.sub Command.
+func (c *healthServer) Usage() string {
+	return c.sub.Usage()
+}
+
+// Synopsis forwards to the underlying c.sub Command.
+func (c *healthServer) Synopsis() string {
+	return c.sub.Synopsis()
+}
+
+// Unwrap returns the underlying c.sub Command.
+func (c *healthServer) Unwrap() subcommands.Command {
+	return c.sub
+}
+
+// SetFlags forwards to the underlying c.sub Command and registers the -health-addr flag.
+func (c *healthServer) SetFlags(f *flag.FlagSet) {
+	c.sub.SetFlags(f)
+	f.StringVar(&c.addr, "health-addr", "", "serve /healthz and /readyz over HTTP on `address` during the execution")
+}
+
+// Dispose forwards to the underlying c.sub Command if it is a CancelableCommand.
+func (c *healthServer) Dispose() error {
+	if sub, ok := c.sub.(CancelableCommand); ok {
+		return sub.Dispose()
+	}
+
+	return nil
+}
+
+// Execute serves the health server on the address of the -health-addr flag while forwarding to the
+// underlying c.sub Command.
+func (c *healthServer) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	if c.addr == "" {
+		return c.sub.Execute(ctx, f, args...)
+	}
+
+	h := &health{}
+	walkCommand(c.sub, func(cmd subcommands.Command) bool {
+		h.readier, _ = cmd.(Readier)
+		return h.readier != nil
+	})
+	ctx, _ = withStopping(context.WithValue(ctx, healthKey{}, h))
+	defer onStopping(ctx, func() { atomic.StoreInt32(&h.stopping, 1) })()
+
+	logger := contextLogger(ctx, c.logger)
+	bound, shutdown, err := serveHTTP(ctx, c.addr, h, logger, "health server")
+	if err != nil {
+		logger.Printf("%s: -health-addr: %v; continuing without the health server", c.sub.Name(), err)
+		return c.sub.Execute(ctx, f, args...)
+	}
+	logger.Printf("%s: health server listening on http://%s/", c.sub.Name(), bound)
+	defer shutdown()
+	defer onCancel(ctx, shutdown)()
+
+	return c.sub.Execute(ctx, f, args...)
+}
@@ -0,0 +1,132 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"context"
+	"flag"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+// healthURL returns the URL of the health server logged to logs.
+func healthURL(t *testing.T, logs *testcmd.LogRecorder) string {
+	t.Helper()
+
+	const marker = "health server listening on "
+	for _, line := range logs.Lines() {
+		if i := strings.Index(line, marker); i >= 0 {
+			return line[i+len(marker):]
+		}
+	}
+	t.Fatalf("wanted the address of the health server logged but got %q", logs.Lines())
+	return ""
+}
+
+// probe returns the status code of the response to a GET request of url.
+func probe(t *testing.T, client *http.Client, url string) int {
+	t.Helper()
+
+	resp, err := client.Get(url)
+	if err != nil {
+		t.Fatalf("wanted no error but got %v", err)
+	}
+	resp.Body.Close()
+
+	return resp.StatusCode
+}
+
+// assertProbes asserts the status codes of the /healthz and /readyz endpoints of url.
+func assertProbes(t *testing.T, client *http.Client, url string, wantHealthz, wantReadyz int) {
+	t.Helper()
+
+	if got := probe(t, client, url+"healthz"); got != wantHealthz {
+		t.Errorf("wanted /healthz to respond %d but got %d", wantHealthz, got)
+	}
+	if got := probe(t, client, url+"readyz"); got != wantReadyz {
+		t.Errorf("wanted /readyz to respond %d but got %d", wantReadyz, got)
+	}
+}
+
+func TestHealthServer(t *testing.T) {
+	defer testcmd.VerifyNoLeaks(t)
+
+	client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+	var logs testcmd.LogRecorder
+	var url string
+	ready := make(chan struct{})
+	finish := make(chan struct{})
+	sub := &draining{
+		Recording: testcmd.NewRecording("agent", testcmd.WithExecute(func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+			url = healthURL(t, &logs)
+			assertProbes(t, client, url, http.StatusOK, http.StatusServiceUnavailable)
+			if err := subcommandsutil.NotifyReady(ctx); err != nil {
+				t.Errorf("wanted no error but got %v", err)
+			}
+			assertProbes(t, client, url, http.StatusOK, http.StatusOK)
+			close(ready)
+			<-finish
+			return subcommands.ExitSuccess
+		})),
+		drain: func(ctx context.Context) error {
+			assertProbes(t, client, url, http.StatusOK, http.StatusServiceUnavailable)
+			close(finish)
+			<-ctx.Done()
+			return ctx.Err()
+		},
+		onDispose: func() {},
+	}
+	cmd := subcommandsutil.Cancelable(subcommandsutil.HealthServer(sub, subcommandsutil.WithLogger(&logs)), subcommandsutil.WithLogger(&testcmd.LogRecorder{}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-ready
+		cancel()
+	}()
+	status, _, _ := testcmd.Run(ctx, cmd, "-health-addr", "127.0.0.1:0")
+	testcmd.RequireSuccess(t, status)
+	if resp, err := client.Get(url + "healthz"); err == nil {
+		resp.Body.Close()
+		t.Fatalf("wanted the health server shut down but got %s", resp.Status)
+	}
+}
+
+// readier is a Recording implementing subcommandsutil.Readier.
+type readier struct {
+	*testcmd.Recording
+	ready int32 // accessed atomically
+}
+
+// Ready implements subcommandsutil.Readier.
+func (c *readier) Ready() bool {
+	return atomic.LoadInt32(&c.ready) == 1
+}
+
+func TestHealthServerReadier(t *testing.T) {
+	defer testcmd.VerifyNoLeaks(t)
+
+	client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+	var logs testcmd.LogRecorder
+	sub := &readier{}
+	sub.Recording = testcmd.NewRecording("agent", testcmd.WithExecute(func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+		url := healthURL(t, &logs)
+		assertProbes(t, client, url, http.StatusOK, http.StatusServiceUnavailable)
+		atomic.StoreInt32(&sub.ready, 1)
+		assertProbes(t, client, url, http.StatusOK, http.StatusOK)
+		atomic.StoreInt32(&sub.ready, 0)
+		assertProbes(t, client, url, http.StatusOK, http.StatusServiceUnavailable)
+		return subcommands.ExitSuccess
+	}))
+
+	status, _, _ := testcmd.Run(context.Background(), subcommandsutil.HealthServer(sub, subcommandsutil.WithLogger(&logs)), "-health-addr", "127.0.0.1:0")
+	testcmd.RequireSuccess(t, status)
+}
@@ -0,0 +1,195 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/google/subcommands"
+)
+
+// heartbeat wraps a subcommands.Command so that a heartbeat file is updated while it runs.
+type heartbeat struct {
+	sub subcommands.Command
+
+	path     string
+	interval time.Duration
+
+	mu   sync.Mutex
+	beat *heartbeatRun // the heartbeat of the running execution
+}
+
+// make sure heartbeat implements the CancelableCommand interface.
+var _ CancelableCommand = (*heartbeat)(nil)
+
+// Heartbeat wraps sub so that the file at path is rewritten every interval while it runs, measured
+// on the Clock of the execution context. The file holds the time of the update, the pid of the
+// process and the name of the command:
+//
+//	time=2021-01-02T03:04:05Z pid=1234 command=sync
+//
+// The file is replaced atomically by a rename, so watchdogs never read a partial one. It is removed
+// when Execute returns, or by Dispose when a Cancelable wrapper stops waiting for sub; Dispose also
+// forwards to the Dispose method of sub, if any. A failed update is warned about on Stderr, once
+// until an update succeeds again, and does not stop sub.
+func Heartbeat(sub subcommands.Command, path string, interval time.Duration) CancelableCommand {
+	return &heartbeat{
+		sub:      sub,
+		path:     path,
+		interval: interval,
+	}
+}
+
+// Name forwards to the underlying c.sub Command.
+func (c *heartbeat) Name() string {
+	return c.sub.Name()
+}
+
+// Usage forwards to the underlying c.sub Command.
+func (c *heartbeat) Usage() string {
+	return c.sub.Usage()
+}
+
+// Synopsis forwards to the underlying c.sub Command.
+func (c *heartbeat) Synopsis() string {
+	return c.sub.Synopsis()
+}
+
+// Unwrap returns the underlying c.sub Command.
+func (c *heartbeat) Unwrap() subcommands.Command {
+	return c.sub
+}
+
+// SetFlags forwards to the underlying c.sub Command.
+func (c *heartbeat) SetFlags(f *flag.FlagSet) {
+	c.sub.SetFlags(f)
+}
+
+// Dispose stops the heartbeat of the running execution, removing its file, and forwards to the
+// underlying c.sub Command if it is a CancelableCommand.
+func (c *heartbeat) Dispose() error {
+	c.mu.Lock()
+	beat := c.beat
+	c.mu.Unlock()
+	beat.stop()
+
+	if sub, ok := c.sub.(CancelableCommand); ok {
+		return sub.Dispose()
+	}
+
+	return nil
+}
+
+// Execute forwards to the underlying c.sub Command while updating the heartbeat file.
+func (c *heartbeat) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	beat := startHeartbeat(ctx, c.path, c.interval, c.sub.Name())
+	c.mu.Lock()
+	c.beat = beat
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		c.beat = nil
+		c.mu.Unlock()
+		beat.stop()
+	}()
+
+	return c.sub.Execute(ctx, f, args...)
+}
+
+// heartbeatRun is the goroutine updating a heartbeat file.
+type heartbeatRun struct {
+	path string
+
+	stopOnce sync.Once
+	stopped  chan struct{}
+	done     chan struct{}
+}
+
+// startHeartbeat writes the heartbeat file at path of the command name, and starts updating it
+// every interval.
+func startHeartbeat(ctx context.Context, path string, interval time.Duration, name string) *heartbeatRun {
+	b := &heartbeatRun{
+		path:    path,
+		stopped: make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	clk := ClockFromContext(ctx)
+	warn := Stderr(ctx)
+
+	warned := false
+	update := func() {
+		content := fmt.Sprintf("time=%s pid=%d command=%s\n", clk.Now().Format(time.RFC3339), os.Getpid(), name)
+		if err := writeFileAtomic(path, []byte(content), 0o644); err != nil {
+			if !warned {
+				warned = true
+				fmt.Fprintf(warn, "warning: writing heartbeat file: %v\n", err)
+			}
+			return
+		}
+		warned = false
+	}
+	update()
+
+	ticker := clk.NewTicker(interval)
+	go func() {
+		defer close(b.done)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-b.stopped:
+				return
+			case <-ticker.C():
+				update()
+			}
+		}
+	}()
+
+	return b
+}
+
+// stop stops the updates and removes the heartbeat file, once. It does nothing on a nil b.
+func (b *heartbeatRun) stop() {
+	if b == nil {
+		return
+	}
+
+	b.stopOnce.Do(func() {
+		close(b.stopped)
+		<-b.done
+		os.Remove(b.path)
+	})
+}
+
+// writeFileAtomic replaces the file at path by one holding data with the permissions perm, through
+// a temporary file renamed over it. The parent directories are created.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
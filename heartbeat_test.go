@@ -0,0 +1,121 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+// readHeartbeat returns the content of the heartbeat file at path, or "" if it does not exist.
+func readHeartbeat(t *testing.T, path string) string {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return ""
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(data)
+}
+
+func TestHeartbeat(t *testing.T) {
+	defer testcmd.VerifyNoLeaks(t)
+
+	path := filepath.Join(t.TempDir(), "run", "sync.alive")
+	start := time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)
+	clk := testcmd.NewFakeClock(start)
+	sub := testcmd.NewBlocking("sync")
+	defer sub.Release(subcommands.ExitFailure)
+
+	done := make(chan subcommands.ExitStatus, 1)
+	go func() {
+		status, _, _ := testcmd.Run(subcommandsutil.WithClock(context.Background(), clk), subcommandsutil.Heartbeat(sub, path, time.Minute))
+		done <- status
+	}()
+	<-sub.Started()
+
+	for i := 0; i < 3; i++ {
+		want := fmt.Sprintf("time=%s pid=%d command=sync\n", start.Add(time.Duration(i)*time.Minute).Format(time.RFC3339), os.Getpid())
+		waitFor(t, fmt.Sprintf("the heartbeat %q", want), func() bool { return readHeartbeat(t, path) == want })
+		clk.BlockUntil(1)
+		clk.Advance(time.Minute)
+	}
+
+	sub.Release(subcommands.ExitSuccess)
+	testcmd.RequireSuccess(t, <-done)
+	if got := readHeartbeat(t, path); got != "" {
+		t.Fatalf("wanted the heartbeat file to be removed but it holds %q", got)
+	}
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("wanted no temporary file to be left but got %v", entries)
+	}
+}
+
+func TestHeartbeatDispose(t *testing.T) {
+	defer testcmd.VerifyNoLeaks(t)
+
+	path := filepath.Join(t.TempDir(), "sync.alive")
+	sub := testcmd.NewBlocking("sync")
+	cmd := subcommandsutil.Heartbeat(sub, path, time.Hour)
+	done := make(chan subcommands.ExitStatus, 1)
+	go func() {
+		status, _, _ := testcmd.Run(context.Background(), cmd)
+		done <- status
+	}()
+	defer func() {
+		sub.Release(subcommands.ExitSuccess)
+		<-done
+	}()
+	<-sub.Started()
+
+	if got := readHeartbeat(t, path); !strings.HasPrefix(got, "time=") {
+		t.Fatalf("wanted a heartbeat file but got %q", got)
+	}
+	if err := cmd.Dispose(); err != nil {
+		t.Fatal(err)
+	}
+	if got := readHeartbeat(t, path); got != "" {
+		t.Fatalf("wanted Dispose to remove the heartbeat file but it holds %q", got)
+	}
+	if sub.DisposeCount() != 1 {
+		t.Fatalf("wanted sub to be disposed once but got %d", sub.DisposeCount())
+	}
+}
+
+func TestHeartbeatWriteFailure(t *testing.T) {
+	dir := t.TempDir()
+	// a regular file where the parent directory of the heartbeat file should be
+	blocker := filepath.Join(dir, "run")
+	writeFile(t, blocker, "")
+
+	var stdout, stderr testcmd.Buffer
+	ctx := subcommandsutil.WithOutput(context.Background(), &stdout, &stderr)
+	status, _, err := testcmd.Run(ctx, subcommandsutil.Heartbeat(testcmd.NewRecording("sync"), filepath.Join(blocker, "sync.alive"), time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	testcmd.RequireSuccess(t, status)
+	if got := stderr.String(); !strings.HasPrefix(got, "warning: writing heartbeat file: ") || strings.Count(got, "\n") != 1 {
+		t.Fatalf("wanted a single warning but got %q", got)
+	}
+}
@@ -0,0 +1,102 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"context"
+	"flag"
+
+	"github.com/google/subcommands"
+)
+
+// HideFlags marks the named flags of f as hidden. Hidden flags are still parsed and settable, but
+// PrintDefaults and ExplainCommand omit them unless they are asked about explicitly.
+//
+// HideFlags is usually called from SetFlags, after the flags are defined. It panics if f does not
+// define one of names.
+func HideFlags(f *flag.FlagSet, names ...string) {
+	for _, name := range names {
+		mustLookup(f, name)
+	}
+
+	updateFlagMeta(f, func(m *flagMeta) {
+		for _, name := range names {
+			m.hidden[name] = true
+		}
+	})
+}
+
+// IsHiddenFlag reports whether the flag named name of f was hidden by HideFlags.
+func IsHiddenFlag(f *flag.FlagSet, name string) (hidden bool) {
+	readFlagMeta(f, func(m *flagMeta) {
+		hidden = m != nil && m.hidden[name]
+	})
+
+	return hidden
+}
+
+// hidden wraps a subcommands.Command so that it is omitted from the command listing.
+type hidden struct {
+	sub subcommands.Command
+}
+
+// make sure hidden implements the subcommands.Command interface.
+var _ subcommands.Command = (*hidden)(nil)
+
+// Hidden wraps sub so that ExplainGroup omits it from the command listing. It still runs when
+// named explicitly.
+func Hidden(sub subcommands.Command) subcommands.Command {
+	return &hidden{
+		sub: sub,
+	}
+}
+
+// Name forwards to the underlying c.sub Command.
+func (c *hidden) Name() string {
+	return c.sub.Name()
+}
+
+// Usage forwards to the underlying c.sub Command.
+func (c *hidden) Usage() string {
+	return c.sub.Usage()
+}
+
+// Synopsis forwards to the underlying c.sub Command.
+func (c *hidden) Synopsis() string {
+	return c.sub.Synopsis()
+}
+
+// Hidden reports that the command is hidden.
+func (c *hidden) Hidden() bool {
+	return true
+}
+
+// Unwrap returns the underlying c.sub Command.
+func (c *hidden) Unwrap() subcommands.Command {
+	return c.sub
+}
+
+// SetFlags forwards to the underlying c.sub Command.
+func (c *hidden) SetFlags(f *flag.FlagSet) {
+	c.sub.SetFlags(f)
+}
+
+// Execute forwards to the underlying c.sub Command.
+func (c *hidden) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	return c.sub.Execute(ctx, f, args...)
+}
+
+// IsHiddenCommand reports whether cmd, or a Command it wraps, has a Hidden method reporting true.
+// The outermost Hidden method found decides.
+func IsHiddenCommand(cmd subcommands.Command) (hidden bool) {
+	walkCommand(cmd, func(cmd subcommands.Command) bool {
+		h, ok := cmd.(interface{ Hidden() bool })
+		if ok {
+			hidden = h.Hidden()
+		}
+		return ok
+	})
+
+	return hidden
+}
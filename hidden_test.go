@@ -0,0 +1,159 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"strings"
+	"testing"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+func TestHideFlags(t *testing.T) {
+	var experimental string
+	cmd := subcommandsutil.Cancelable(testcmd.NewRecording("hide",
+		testcmd.WithUsage("hide [-verbose]\n"),
+		testcmd.WithFlags(func(f *flag.FlagSet) {
+			f.Bool("verbose", false, "verbose output")
+			f.StringVar(&experimental, "experimental", "", "experimental feature")
+			subcommandsutil.HideFlags(f, "experimental")
+		}),
+	))
+
+	var buf bytes.Buffer
+	subcommandsutil.ExplainCommand(&buf, cmd)
+	usage := buf.String()
+	if !strings.Contains(usage, "-verbose") {
+		t.Fatalf("wanted -verbose in usage but got %q", usage)
+	}
+	if strings.Contains(usage, "-experimental") {
+		t.Fatalf("wanted -experimental to be hidden but got %q", usage)
+	}
+
+	f := flag.NewFlagSet(cmd.Name(), flag.ContinueOnError)
+	cmd.SetFlags(f)
+	if err := f.Parse([]string{"-experimental", "on"}); err != nil {
+		t.Fatalf("hidden flag must still parse: %v", err)
+	}
+	if experimental != "on" {
+		t.Fatalf("wanted experimental to be %q but got %q", "on", experimental)
+	}
+	if !subcommandsutil.IsHiddenFlag(f, "experimental") {
+		t.Fatal("wanted experimental to be reported as hidden")
+	}
+
+	buf.Reset()
+	f.SetOutput(&buf)
+	subcommandsutil.PrintDefaults(f, "experimental")
+	if !strings.Contains(buf.String(), "-experimental") {
+		t.Fatalf("wanted explicitly asked -experimental in defaults but got %q", buf.String())
+	}
+}
+
+func TestHideFlagsUndefined(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("wanted HideFlags to panic on an undefined flag")
+		}
+	}()
+
+	subcommandsutil.HideFlags(flag.NewFlagSet("test", flag.ContinueOnError), "nope")
+}
+
+func TestIsHiddenCommand(t *testing.T) {
+	tests := map[string]struct {
+		cmd  subcommands.Command
+		want bool
+	}{
+		"when not hidden": {
+			cmd:  subcommandsutil.Logged(testcmd.NewRecording("status")),
+			want: false,
+		},
+		"when hidden": {
+			cmd:  subcommandsutil.Hidden(testcmd.NewRecording("debug")),
+			want: true,
+		},
+		"when wrapped after hiding": {
+			cmd:  subcommandsutil.Timeout(subcommandsutil.Logged(subcommandsutil.Hidden(testcmd.NewRecording("debug"))), 0),
+			want: true,
+		},
+		"when aliasing a hidden command": {
+			cmd:  subcommandsutil.Alias(subcommandsutil.Hidden(testcmd.NewRecording("debug")), "dbg"),
+			want: true,
+		},
+		"when the alias is visible": {
+			cmd:  subcommandsutil.Alias(testcmd.NewRecording("debug"), "dbg"),
+			want: false,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := subcommandsutil.IsHiddenCommand(tt.cmd); got != tt.want {
+				t.Fatalf("wanted %v but got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestExplainGroup(t *testing.T) {
+	remove := testcmd.NewRecording("remove", testcmd.WithSynopsis("remove entries"))
+	debug := testcmd.NewRecording("debug", testcmd.WithSynopsis("dump internal state"))
+
+	h := testcmd.NewHarness(t)
+	h.Commander.ExplainGroup = subcommandsutil.ExplainGroup(h.Commander)
+	h.Register(testcmd.NewRecording("status", testcmd.WithSynopsis("show status")), "")
+	h.Register(remove, "")
+	h.Register(subcommandsutil.Alias(remove, "rm"), "")
+	h.Register(subcommandsutil.Alias(remove, "del", subcommandsutil.WithAliasHidden()), "")
+	h.Register(subcommandsutil.Logged(subcommandsutil.Hidden(debug), subcommandsutil.WithLogger(&testcmd.LogRecorder{})), "")
+	h.Register(subcommandsutil.Hidden(testcmd.NewRecording("internal")), "internal")
+
+	testcmd.RequireSuccess(t, h.Execute(context.Background(), "help"))
+	listing := h.Stdout.String()
+	for _, want := range []string{
+		"Subcommands:\n",
+		"\tremove, rm       remove entries\n",
+		"\tstatus           show status\n",
+	} {
+		if !strings.Contains(listing, want) {
+			t.Fatalf("wanted the listing to contain %q but got %q", want, listing)
+		}
+	}
+	for _, hidden := range []string{"debug", "del", "internal"} {
+		if strings.Contains(listing, hidden) {
+			t.Fatalf("wanted the listing not to contain %q but got %q", hidden, listing)
+		}
+	}
+
+	testcmd.RequireSuccess(t, h.Execute(context.Background(), "debug"))
+	testcmd.RequireSuccess(t, h.Execute(context.Background(), "del"))
+	if debug.CallCount() != 1 || remove.CallCount() != 1 {
+		t.Fatal("wanted the hidden commands to be executable")
+	}
+}
+
+func TestGroupHidden(t *testing.T) {
+	debug := testcmd.NewRecording("debug")
+	g := subcommandsutil.NewGroup("remote", "manage remotes")
+	g.Register(testcmd.NewRecording("add", testcmd.WithSynopsis("add a remote")), "")
+	g.Register(subcommandsutil.Hidden(debug), "")
+
+	h := testcmd.NewHarness(t)
+	h.Register(g, "")
+	testcmd.RequireSuccess(t, h.Execute(context.Background(), "remote", "help"))
+	if listing := h.Stdout.String(); !strings.Contains(listing, "add a remote") || strings.Contains(listing, "debug") {
+		t.Fatalf("wanted the inner listing to omit the hidden command but got %q", listing)
+	}
+
+	testcmd.RequireSuccess(t, h.Execute(context.Background(), "remote", "debug"))
+	if debug.CallCount() != 1 {
+		t.Fatal("wanted the hidden inner command to be executable")
+	}
+}
@@ -0,0 +1,163 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// History is the command history of ShellCommand: the lines it executed, oldest first, persisted
+// to a file across sessions. It is safe for concurrent use.
+type History struct {
+	path string
+	max  int
+
+	mu      sync.Mutex
+	entries []string
+}
+
+// NewHistory returns a new empty History persisted to the file at path, keeping the max latest
+// entries. An empty path keeps the History in memory only, and a max of 0 or less keeps every
+// entry.
+func NewHistory(path string, max int) *History {
+	return &History{
+		path: path,
+		max:  max,
+	}
+}
+
+// Path returns the path of the file h is persisted to, or "".
+func (h *History) Path() string {
+	return h.path
+}
+
+// Load replaces the entries of h by the ones of its file, if it exists. A corrupt file, holding
+// binary data, is truncated at the first corrupt line, and reported by the returned error.
+func (h *History) Load() error {
+	if h.path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(h.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var entries []string
+	n := 0
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		n++
+		line := sc.Text()
+		if !utf8.ValidString(line) || strings.ContainsRune(line, 0) {
+			err = fmt.Errorf("%s: corrupt history at line %d, truncated", h.path, n)
+			break
+		}
+		if line != "" {
+			entries = append(entries, line)
+		}
+	}
+	if sc.Err() != nil {
+		err = fmt.Errorf("%s: corrupt history at line %d, truncated", h.path, n+1)
+	}
+
+	h.mu.Lock()
+	h.entries = h.capped(entries)
+	h.mu.Unlock()
+
+	return err
+}
+
+// Add appends line to the entries of h, dropping the oldest ones past its max.
+func (h *History) Add(line string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.entries = h.capped(append(h.entries, line))
+}
+
+// Entries returns the entries of h, oldest first.
+func (h *History) Entries() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return append([]string(nil), h.entries...)
+}
+
+// Save replaces the file of h atomically by one holding its entries, readable by the user only.
+func (h *History) Save() error {
+	if h.path == "" {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	for _, entry := range h.Entries() {
+		buf.WriteString(entry)
+		buf.WriteByte('\n')
+	}
+
+	return writeFileAtomic(h.path, buf.Bytes(), 0o600)
+}
+
+// capped returns the max latest entries. h.mu must be held.
+func (h *History) capped(entries []string) []string {
+	if h.max > 0 && len(entries) > h.max {
+		return append([]string(nil), entries[len(entries)-h.max:]...)
+	}
+
+	return entries
+}
+
+// historyLine renders argv, the words of a line executed by ShellCommand with the parsed flags f,
+// for its History: the values of the sensitive flags of f are replaced by Redacted. It returns ""
+// when line needs no redaction.
+func historyLine(f *flag.FlagSet, argv []string) string {
+	words := append([]string(nil), argv...)
+	redacted := false
+	for i := 1; i < len(words); i++ {
+		word := words[i]
+		if word == "--" || len(word) < 2 || word[0] != '-' {
+			break
+		}
+
+		name := strings.TrimLeft(word, "-")
+		name, _, hasValue := strings.Cut(name, "=")
+		fl := f.Lookup(name)
+		takesValue := !hasValue && fl != nil && !isBoolFlag(fl) && i+1 < len(words)
+		if IsSensitiveFlag(f, name) {
+			redacted = true
+			switch {
+			case hasValue:
+				words[i] = word[:strings.IndexByte(word, '=')+1] + Redacted
+			case takesValue:
+				words[i+1] = Redacted
+			}
+		}
+		if takesValue {
+			i++
+		}
+	}
+	if !redacted {
+		return ""
+	}
+
+	return QuoteArgs(words)
+}
+
+// isBoolFlag reports whether fl is a boolean flag, which takes no separate value.
+func isBoolFlag(fl *flag.Flag) bool {
+	b, ok := fl.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
@@ -0,0 +1,127 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"context"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+// shellCommander returns a Commander of a "build" command with an -o flag, and a "login" command
+// with a sensitive -token flag.
+func shellCommander() *subcommands.Commander {
+	cdr := subcommands.NewCommander(flag.NewFlagSet("tool", flag.ContinueOnError), "tool")
+	cdr.Register(testcmd.NewRecording("build", testcmd.WithFlags(func(f *flag.FlagSet) { f.String("o", "", "output file") })), "")
+	cdr.Register(testcmd.NewRecording("login", testcmd.WithFlags(func(f *flag.FlagSet) {
+		f.String("token", "", "API token")
+		f.Bool("v", false, "verbose")
+		subcommandsutil.MarkSensitive(f, "token")
+	})), "")
+
+	return cdr
+}
+
+// runShell runs a ShellCommand of cdr reading the lines of script, with opts.
+func runShell(t *testing.T, cdr *subcommands.Commander, script string, opts ...subcommandsutil.ShellOption) (stderr string) {
+	t.Helper()
+
+	var errBuf testcmd.Buffer
+	ctx := subcommandsutil.WithOutput(context.Background(), &testcmd.Buffer{}, &errBuf)
+	opts = append([]subcommandsutil.ShellOption{subcommandsutil.WithShellInput(strings.NewReader(script))}, opts...)
+	status, _, _ := testcmd.Run(ctx, subcommandsutil.ShellCommand(cdr, opts...))
+	testcmd.RequireSuccess(t, status)
+
+	return errBuf.String()
+}
+
+func TestShellHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+	cdr := shellCommander()
+
+	first := subcommandsutil.NewHistory(path, 3)
+	runShell(t, cdr, "build -o a\nlogin -v -token s3cret\nbuidl\nlogin -token='my secret' -v", subcommandsutil.WithHistory(first))
+	want := []string{"build -o a", "login -v -token '[REDACTED]'", "login '-token=[REDACTED]' -v"}
+	if got := first.Entries(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("wanted the entries %q but got %q", want, got)
+	}
+
+	second := subcommandsutil.NewHistory(path, 3)
+	runShell(t, cdr, "build -o b", subcommandsutil.WithHistory(second))
+	want = []string{"login -v -token '[REDACTED]'", "login '-token=[REDACTED]' -v", "build -o b"}
+	if got := second.Entries(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("wanted the entries carried over and capped %q but got %q", want, got)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, wantFile := string(data), strings.Join(want, "\n")+"\n"; got != wantFile {
+		t.Fatalf("wanted the file %q but got %q", wantFile, got)
+	}
+	if strings.Contains(string(data), "secret") {
+		t.Fatalf("wanted the sensitive values redacted but got %q", data)
+	}
+}
+
+func TestShellHistoryStateDir(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_STATE_HOME", dir)
+	t.Setenv("LOCALAPPDATA", dir)
+
+	runShell(t, shellCommander(), "build -o a")
+	runShell(t, shellCommander(), "build -o b")
+
+	h := subcommandsutil.NewHistory(filepath.Join(dir, "tool", "shell_history"), 0)
+	if err := h.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if want, got := []string{"build -o a", "build -o b"}, h.Entries(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("wanted the entries %q but got %q", want, got)
+	}
+
+	runShell(t, shellCommander(), "build -o c", subcommandsutil.WithoutHistoryFile())
+	if err := h.Load(); err != nil || len(h.Entries()) != 2 {
+		t.Fatalf("wanted the history file untouched but got %q (%v)", h.Entries(), err)
+	}
+}
+
+func TestShellHistoryCorrupt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+	if err := os.WriteFile(path, []byte("build -o a\n\x00\xff\xfe\nbuild -o b\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	h := subcommandsutil.NewHistory(path, 0)
+	stderr := runShell(t, shellCommander(), "build -o c", subcommandsutil.WithHistory(h))
+	if want := "warning: shell history: " + path + ": corrupt history at line 2, truncated\n"; stderr != want {
+		t.Fatalf("wanted the stderr %q but got %q", want, stderr)
+	}
+	if want, got := []string{"build -o a", "build -o c"}, h.Entries(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("wanted the entries %q but got %q", want, got)
+	}
+}
+
+func TestShellHistoryDispose(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+	h := subcommandsutil.NewHistory(path, 0)
+	h.Add("build -o a")
+
+	if err := subcommandsutil.ShellCommand(shellCommander(), subcommandsutil.WithHistory(h)).Dispose(); err != nil {
+		t.Fatalf("wanted no error but got %v", err)
+	}
+	if data, err := os.ReadFile(path); err != nil || string(data) != "build -o a\n" {
+		t.Fatalf("wanted the history saved but got %q (%v)", data, err)
+	}
+}
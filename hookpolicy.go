@@ -0,0 +1,115 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// HookErrorPolicy decides what happens when a hook fails: when it returns an error, or panics. It
+// is applied by Hooks, by the dispatch hooks of CancelableCommander and by the exit hooks of
+// OnExitStatus. Each failure is logged with the policy applied, and published as HookFailed.
+type HookErrorPolicy int32
+
+const (
+	// ContinueAndLog logs the failure and carries on as if the hook succeeded.
+	ContinueAndLog HookErrorPolicy = iota
+
+	// FailCommand logs the failure and fails the command: a failed pre hook skips the execution,
+	// whose status is mapped from the error by StatusFromError, and a failed post hook turns a
+	// successful status into subcommands.ExitFailure.
+	FailCommand
+
+	// Abort logs the failure and skips the remaining hooks of the same phase, like the pre hooks of
+	// the inner Hooks wrappers, without failing the command.
+	Abort
+)
+
+// String implements fmt.Stringer.
+func (p HookErrorPolicy) String() string {
+	switch p {
+	case ContinueAndLog:
+		return "ContinueAndLog"
+	case FailCommand:
+		return "FailCommand"
+	case Abort:
+		return "Abort"
+	default:
+		return "HookErrorPolicy(" + strconv.Itoa(int(p)) + ")"
+	}
+}
+
+// defaultHookErrorPolicy is the HookErrorPolicy set by SetHookErrorPolicy, accessed atomically.
+var defaultHookErrorPolicy int32
+
+// SetHookErrorPolicy sets the HookErrorPolicy of the hooks which do not override it with
+// WithHookErrorPolicy. The default is ContinueAndLog.
+func SetHookErrorPolicy(p HookErrorPolicy) {
+	atomic.StoreInt32(&defaultHookErrorPolicy, int32(p))
+}
+
+// HookErrorPolicyOption is an option overriding the HookErrorPolicy set by SetHookErrorPolicy. It
+// is accepted by Hooks and OnExitStatus.
+type HookErrorPolicyOption struct {
+	policy HookErrorPolicy
+}
+
+// WithHookErrorPolicy returns an option making a hook fail according to p instead of the policy set
+// by SetHookErrorPolicy.
+func WithHookErrorPolicy(p HookErrorPolicy) HookErrorPolicyOption {
+	return HookErrorPolicyOption{policy: p}
+}
+
+// hookErrorPolicy returns the policy p points to, or the policy set by SetHookErrorPolicy if p is
+// nil.
+func hookErrorPolicy(p *HookErrorPolicy) HookErrorPolicy {
+	if p != nil {
+		return *p
+	}
+
+	return HookErrorPolicy(atomic.LoadInt32(&defaultHookErrorPolicy))
+}
+
+// callHook calls fn, turning its panic into an error.
+func callHook(fn func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	return fn()
+}
+
+// HookFailed is published when a hook fails, with the HookErrorPolicy applied to the failure, by
+// Hooks, by the dispatch hooks of CancelableCommander, and by the exit hooks of OnExitStatus run by
+// Run and CancelOnSignal.
+type HookFailed struct {
+	Command string // empty for the exit hooks
+	Time    time.Time
+	Phase   string // "pre", "post", "before dispatch", "after dispatch" or "exit"
+	Err     error
+	Policy  HookErrorPolicy
+}
+
+// CommandName implements Event.
+func (e HookFailed) CommandName() string { return e.Command }
+
+// hookFailed logs err, the failure of the hook of phase of the command named name, with the policy
+// p applied to it, publishes it to the Events of ctx, and returns p.
+func hookFailed(ctx context.Context, l Logger, name, phase string, err error, p HookErrorPolicy) HookErrorPolicy {
+	publish(ctx, HookFailed{Command: name, Time: ClockFromContext(ctx).Now(), Phase: phase, Err: err, Policy: p})
+
+	prefix := ""
+	if name != "" {
+		prefix = name + ": "
+	}
+	l.Printf("%s%s hook: %v (%v)", prefix, phase, err, p)
+
+	return p
+}
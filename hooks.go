@@ -0,0 +1,145 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"context"
+	"flag"
+
+	"github.com/google/subcommands"
+)
+
+// HooksOption is an option of the Hooks wrapper.
+type HooksOption interface {
+	applyHooks(*hooks)
+}
+
+// applyHooks implements HooksOption.
+func (o LoggerOption) applyHooks(c *hooks) {
+	c.logger = o.logger
+}
+
+// applyHooks implements HooksOption.
+func (o HookErrorPolicyOption) applyHooks(c *hooks) {
+	c.policy = &o.policy
+}
+
+// hooksStateKey is the context key of the hooksState of an execution.
+type hooksStateKey struct{}
+
+// hooksState is shared by the nested Hooks wrappers of an execution.
+type hooksState struct {
+	abortPre  bool // a pre hook failed with the Abort policy
+	abortPost bool // a post hook failed with the Abort policy
+}
+
+// hooks wraps a subcommands.Command so that functions run before and after its executions.
+type hooks struct {
+	sub    subcommands.Command
+	pre    func(ctx context.Context, f *flag.FlagSet) error
+	post   func(ctx context.Context, status subcommands.ExitStatus)
+	logger Logger
+	policy *HookErrorPolicy
+}
+
+// make sure hooks implements the subcommands.Command interface.
+var _ subcommands.Command = (*hooks)(nil)
+
+// Hooks wraps sub so that pre runs before each execution, like to warm a cache, and post after it,
+// like to write a completion marker. Either may be nil.
+//
+// A hook fails when pre returns an error, or when either panics; the failure is logged and handled
+// according to the HookErrorPolicy, which WithHookErrorPolicy overrides. With FailCommand, a
+// failed pre hook skips the execution of sub, and the status is mapped from the error by
+// StatusFromError. post always runs, with the final status: the one of sub, or of pre, or
+// subcommands.ExitFailure when sub panics, in which case the panic is propagated after post
+// returns. To run post when the execution is canceled too, wrap a Cancelable command.
+//
+// Nested Hooks run in order: the pre of the outer wrapper first, and its post last.
+func Hooks(sub subcommands.Command, pre func(ctx context.Context, f *flag.FlagSet) error, post func(ctx context.Context, status subcommands.ExitStatus), opts ...HooksOption) subcommands.Command {
+	c := &hooks{
+		sub:    sub,
+		pre:    pre,
+		post:   post,
+		logger: stdLogger{},
+	}
+	for _, opt := range opts {
+		opt.applyHooks(c)
+	}
+
+	return c
+}
+
+// Name forwards to the underlying c.sub Command.
+func (c *hooks) Name() string {
+	return c.sub.Name()
+}
+
+// Usage forwards to the underlying c.sub Command.
+func (c *hooks) Usage() string {
+	return c.sub.Usage()
+}
+
+// Synopsis forwards to the underlying c.sub Command.
+func (c *hooks) Synopsis() string {
+	return c.sub.Synopsis()
+}
+
+// Unwrap returns the underlying c.sub Command.
+func (c *hooks) Unwrap() subcommands.Command {
+	return c.sub
+}
+
+// SetFlags forwards to the underlying c.sub Command.
+func (c *hooks) SetFlags(f *flag.FlagSet) {
+	c.sub.SetFlags(f)
+}
+
+// Execute runs the pre hook, forwards to the underlying c.sub Command unless the hook fails the
+// command, and runs the post hook.
+func (c *hooks) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) (status subcommands.ExitStatus) {
+	state, ok := ctx.Value(hooksStateKey{}).(*hooksState)
+	if !ok {
+		state = &hooksState{}
+		ctx = context.WithValue(ctx, hooksStateKey{}, state)
+	}
+	name, logger, policy := c.sub.Name(), contextLogger(ctx, c.logger), hookErrorPolicy(c.policy)
+
+	if c.post != nil {
+		status = subcommands.ExitFailure // unless Execute returns
+		defer func() {
+			if state.abortPost {
+				return
+			}
+			err := callHook(func() error {
+				c.post(ctx, status)
+				return nil
+			})
+			if err == nil {
+				return
+			}
+			switch hookFailed(ctx, logger, name, "post", err, policy) {
+			case FailCommand:
+				if status == subcommands.ExitSuccess {
+					status = subcommands.ExitFailure
+				}
+			case Abort:
+				state.abortPost = true
+			}
+		}()
+	}
+
+	if c.pre != nil && !state.abortPre {
+		if err := callHook(func() error { return c.pre(ctx, f) }); err != nil {
+			switch hookFailed(ctx, logger, name, "pre", err, policy) {
+			case FailCommand:
+				return StatusFromError(err)
+			case Abort:
+				state.abortPre = true
+			}
+		}
+	}
+
+	return c.sub.Execute(ctx, f, args...)
+}
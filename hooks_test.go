@@ -0,0 +1,228 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+// hookRecorder records the runs of the hooks of Hooks wrappers.
+type hookRecorder struct {
+	events []string
+}
+
+// pre returns a pre hook recording its run as "NAME.pre" and returning err.
+func (r *hookRecorder) pre(name string, err error) func(ctx context.Context, f *flag.FlagSet) error {
+	return func(ctx context.Context, f *flag.FlagSet) error {
+		r.events = append(r.events, name+".pre")
+		return err
+	}
+}
+
+// post returns a post hook recording its run as "NAME.post(STATUS)".
+func (r *hookRecorder) post(name string) func(ctx context.Context, status subcommands.ExitStatus) {
+	return func(ctx context.Context, status subcommands.ExitStatus) {
+		r.events = append(r.events, fmt.Sprintf("%s.post(%d)", name, status))
+	}
+}
+
+func TestHooks(t *testing.T) {
+	tests := map[string]struct {
+		status     subcommands.ExitStatus
+		policy     *subcommandsutil.HookErrorPolicy
+		outerErr   error
+		innerErr   error
+		innerPanic bool // whether the inner post hook panics
+		wantStatus subcommands.ExitStatus
+		wantEvents []string
+		wantLog    []string
+	}{
+		"when the command succeeds": {
+			status:     subcommands.ExitSuccess,
+			wantStatus: subcommands.ExitSuccess,
+			wantEvents: []string{"outer.pre", "inner.pre", "execute", "inner.post(0)", "outer.post(0)"},
+		},
+		"when the command fails": {
+			status:     subcommands.ExitFailure,
+			wantStatus: subcommands.ExitFailure,
+			wantEvents: []string{"outer.pre", "inner.pre", "execute", "inner.post(1)", "outer.post(1)"},
+		},
+		"when the outer pre hook fails with the ContinueAndLog policy": {
+			outerErr:   errors.New("cache unavailable"),
+			wantStatus: subcommands.ExitSuccess,
+			wantEvents: []string{"outer.pre", "inner.pre", "execute", "inner.post(0)", "outer.post(0)"},
+			wantLog:    []string{"build: pre hook: cache unavailable (ContinueAndLog)"},
+		},
+		"when the inner pre hook fails with the FailCommand policy": {
+			policy:     policy(subcommandsutil.FailCommand),
+			innerErr:   subcommandsutil.UsageErrorf("missing -cache"),
+			wantStatus: subcommands.ExitUsageError,
+			wantEvents: []string{"outer.pre", "inner.pre", "inner.post(2)", "outer.post(2)"},
+			wantLog:    []string{"build: pre hook: missing -cache (FailCommand)"},
+		},
+		"when the outer pre hook fails with the FailCommand policy": {
+			policy:     policy(subcommandsutil.FailCommand),
+			outerErr:   errors.New("cache unavailable"),
+			wantStatus: subcommands.ExitFailure,
+			wantEvents: []string{"outer.pre", "outer.post(1)"},
+			wantLog:    []string{"build: pre hook: cache unavailable (FailCommand)"},
+		},
+		"when the outer pre hook fails with the Abort policy": {
+			policy:     policy(subcommandsutil.Abort),
+			outerErr:   errors.New("cache unavailable"),
+			wantStatus: subcommands.ExitSuccess,
+			wantEvents: []string{"outer.pre", "execute", "inner.post(0)", "outer.post(0)"},
+			wantLog:    []string{"build: pre hook: cache unavailable (Abort)"},
+		},
+		"when the inner post hook panics with the ContinueAndLog policy": {
+			innerPanic: true,
+			wantStatus: subcommands.ExitSuccess,
+			wantEvents: []string{"outer.pre", "inner.pre", "execute", "inner.post(0)", "outer.post(0)"},
+			wantLog:    []string{"build: post hook: panic: marker not written (ContinueAndLog)"},
+		},
+		"when the inner post hook panics with the FailCommand policy": {
+			policy:     policy(subcommandsutil.FailCommand),
+			innerPanic: true,
+			wantStatus: subcommands.ExitFailure,
+			wantEvents: []string{"outer.pre", "inner.pre", "execute", "inner.post(0)", "outer.post(1)"},
+			wantLog:    []string{"build: post hook: panic: marker not written (FailCommand)"},
+		},
+		"when the inner post hook panics with the Abort policy": {
+			policy:     policy(subcommandsutil.Abort),
+			innerPanic: true,
+			wantStatus: subcommands.ExitSuccess,
+			wantEvents: []string{"outer.pre", "inner.pre", "execute", "inner.post(0)"},
+			wantLog:    []string{"build: post hook: panic: marker not written (Abort)"},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var r hookRecorder
+			sub := testcmd.NewRecording("build", testcmd.WithExecute(func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+				r.events = append(r.events, "execute")
+				return tt.status
+			}))
+			var logs testcmd.LogRecorder
+			opts := []subcommandsutil.HooksOption{subcommandsutil.WithLogger(&logs)}
+			if tt.policy != nil {
+				opts = append(opts, subcommandsutil.WithHookErrorPolicy(*tt.policy))
+			}
+			innerPost := r.post("inner")
+			if tt.innerPanic {
+				innerPost = func(ctx context.Context, status subcommands.ExitStatus) {
+					r.post("inner")(ctx, status)
+					panic("marker not written")
+				}
+			}
+			cmd := subcommandsutil.Hooks(subcommandsutil.Hooks(sub, r.pre("inner", tt.innerErr), innerPost, opts...), r.pre("outer", tt.outerErr), r.post("outer"), opts...)
+
+			var failures []string
+			status, _, _ := testcmd.Run(subcommandsutil.WithEvents(context.Background(), hookFailures(&failures)), cmd)
+			testcmd.AssertStatus(t, status, tt.wantStatus)
+			if !reflect.DeepEqual(r.events, tt.wantEvents) {
+				t.Fatalf("wanted the events %q but got %q", tt.wantEvents, r.events)
+			}
+			if got := logs.Lines(); strings.Join(got, "\n") != strings.Join(tt.wantLog, "\n") {
+				t.Fatalf("wanted the log %q but got %q", tt.wantLog, got)
+			}
+			if strings.Join(failures, "\n") != strings.Join(tt.wantLog, "\n") {
+				t.Fatalf("wanted the HookFailed events %q but got %q", tt.wantLog, failures)
+			}
+		})
+	}
+}
+
+// hookFailures returns Events recording the HookFailed events published to them into failures, in
+// the format of their log lines.
+func hookFailures(failures *[]string) *subcommandsutil.Events {
+	events := subcommandsutil.NewEvents()
+	events.Subscribe(func(ev subcommandsutil.Event) {
+		if ev, ok := ev.(subcommandsutil.HookFailed); ok {
+			prefix := ""
+			if ev.Command != "" {
+				prefix = ev.Command + ": "
+			}
+			*failures = append(*failures, fmt.Sprintf("%s%s hook: %v (%v)", prefix, ev.Phase, ev.Err, ev.Policy))
+		}
+	})
+
+	return events
+}
+
+// policy returns a pointer to p.
+func policy(p subcommandsutil.HookErrorPolicy) *subcommandsutil.HookErrorPolicy {
+	return &p
+}
+
+func TestSetHookErrorPolicy(t *testing.T) {
+	subcommandsutil.SetHookErrorPolicy(subcommandsutil.FailCommand)
+	defer subcommandsutil.SetHookErrorPolicy(subcommandsutil.ContinueAndLog)
+
+	var logs testcmd.LogRecorder
+	sub := testcmd.NewRecording("build")
+	pre := func(ctx context.Context, f *flag.FlagSet) error { return errors.New("cache unavailable") }
+
+	status, _, _ := testcmd.Run(context.Background(), subcommandsutil.Hooks(sub, pre, nil, subcommandsutil.WithLogger(&logs)))
+	testcmd.AssertStatus(t, status, subcommands.ExitFailure)
+	if sub.CallCount() != 0 || !logs.Contains("(FailCommand)") {
+		t.Fatalf("wanted the package policy to fail the command but got %d calls and the log %q", sub.CallCount(), logs.Lines())
+	}
+
+	status, _, _ = testcmd.Run(context.Background(), subcommandsutil.Hooks(sub, pre, nil, subcommandsutil.WithLogger(&logs), subcommandsutil.WithHookErrorPolicy(subcommandsutil.ContinueAndLog)))
+	testcmd.AssertStatus(t, status, subcommands.ExitSuccess)
+	if sub.CallCount() != 1 {
+		t.Fatalf("wanted the wrapper policy to override the package policy but got %d calls", sub.CallCount())
+	}
+}
+
+func TestHooksPanic(t *testing.T) {
+	var r hookRecorder
+	sub := testcmd.NewRecording("build", testcmd.WithExecute(func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+		panic("boom")
+	}))
+	cmd := subcommandsutil.Hooks(sub, nil, r.post("build"))
+
+	func() {
+		defer func() {
+			if got := recover(); got != "boom" {
+				t.Fatalf("wanted the panic to propagate but got %v", got)
+			}
+		}()
+		testcmd.Run(context.Background(), cmd)
+	}()
+	if want := []string{"build.post(1)"}; !reflect.DeepEqual(r.events, want) {
+		t.Fatalf("wanted the events %q but got %q", want, r.events)
+	}
+}
+
+func TestHooksCancelable(t *testing.T) {
+	defer testcmd.VerifyNoLeaks(t)
+
+	var r hookRecorder
+	tcmd := testcmd.NewBlocking("build")
+	defer tcmd.Release(subcommands.ExitSuccess)
+	cmd := subcommandsutil.Hooks(subcommandsutil.Cancelable(tcmd, subcommandsutil.WithLogger(&testcmd.LogRecorder{})), r.pre("build", nil), r.post("build"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-tcmd.Started()
+		cancel()
+	}()
+	status, _, _ := testcmd.Run(ctx, cmd)
+	testcmd.AssertStatus(t, status, subcommands.ExitFailure)
+	if want := []string{"build.pre", "build.post(1)"}; !reflect.DeepEqual(r.events, want) {
+		t.Fatalf("wanted the events %q but got %q", want, r.events)
+	}
+}
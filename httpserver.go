@@ -0,0 +1,207 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/subcommands"
+)
+
+// HTTPServerOption is an option of ServeHTTP.
+type HTTPServerOption interface {
+	applyHTTPServer(*httpServer)
+}
+
+// httpServerOptionFunc is an HTTPServerOption implemented by a function.
+type httpServerOptionFunc func(*httpServer)
+
+// applyHTTPServer implements HTTPServerOption.
+func (fn httpServerOptionFunc) applyHTTPServer(c *httpServer) { fn(c) }
+
+// applyHTTPServer implements HTTPServerOption.
+func (o LoggerOption) applyHTTPServer(c *httpServer) {
+	c.logger = o.logger
+}
+
+// WithDefaultAddr sets the default value of the -addr flag of ServeHTTP. It defaults to ":8080".
+func WithDefaultAddr(addr string) HTTPServerOption {
+	return httpServerOptionFunc(func(c *httpServer) {
+		c.defaultAddr = addr
+	})
+}
+
+// WithShutdownTimeout sets the time the server of ServeHTTP is given to finish the requests in
+// flight when it is shut down, after which it is closed. It defaults to 5s.
+func WithShutdownTimeout(d time.Duration) HTTPServerOption {
+	return httpServerOptionFunc(func(c *httpServer) {
+		c.grace = d
+	})
+}
+
+// httpServer is the command serving the http.Server of ServeHTTP.
+type httpServer struct {
+	name        string
+	synopsis    string
+	build       func(ctx context.Context, f *flag.FlagSet) (*http.Server, error)
+	logger      Logger
+	defaultAddr string
+	grace       time.Duration
+
+	addr     string
+	certFile string
+	keyFile  string
+
+	mu        sync.Mutex
+	srv       *http.Server // the server of the running execution
+	srvLogger Logger       // the logger of the running execution
+	srvClock  Clock        // the Clock of the running execution
+}
+
+// make sure httpServer implements the CancelableCommand interface.
+var _ CancelableCommand = (*httpServer)(nil)
+
+// ServeHTTP returns a command named name serving the http.Server returned by build until the
+// server fails or the execution context is canceled, like:
+//
+//	cmd := subcommandsutil.ServeHTTP("serve", "serve the API", func(ctx context.Context, f *flag.FlagSet) (*http.Server, error) {
+//		return &http.Server{Handler: api.NewHandler()}, nil
+//	})
+//
+// The command registers the -addr flag, the address the server listens on, and the -tls-cert and
+// -tls-key flags, which serve HTTPS with the certificate and key files. The bound address, useful
+// with a port of 0, is logged to the standard logger unless WithLogger is given.
+//
+// When the context is canceled, the server is shut down, waiting for the requests in flight up to
+// the timeout set by WithShutdownTimeout, measured on the Clock of the execution context, and then
+// closed. Dispose does the same for the Cancelable wrapper. A server shut down exits with subcommands.ExitSuccess.
+func ServeHTTP(name, synopsis string, build func(ctx context.Context, f *flag.FlagSet) (*http.Server, error), opts ...HTTPServerOption) CancelableCommand {
+	c := &httpServer{
+		name:        name,
+		synopsis:    synopsis,
+		build:       build,
+		logger:      stdLogger{},
+		defaultAddr: ":8080",
+		grace:       5 * time.Second,
+	}
+	for _, opt := range opts {
+		opt.applyHTTPServer(c)
+	}
+
+	return c
+}
+
+// Name implements subcommands.Command.
+func (c *httpServer) Name() string {
+	return c.name
+}
+
+// Synopsis implements subcommands.Command.
+func (c *httpServer) Synopsis() string {
+	return c.synopsis
+}
+
+// Usage implements subcommands.Command.
+func (c *httpServer) Usage() string {
+	return c.name + " [-addr address] [-tls-cert file -tls-key file]:\n  " + c.synopsis + ".\n"
+}
+
+// SetFlags implements subcommands.Command.
+func (c *httpServer) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&c.addr, "addr", c.defaultAddr, "listen on `address`")
+	f.StringVar(&c.certFile, "tls-cert", "", "serve HTTPS with the certificate `file`")
+	f.StringVar(&c.keyFile, "tls-key", "", "serve HTTPS with the private key `file`")
+}
+
+// Dispose shuts the server of the running execution down, if any.
+func (c *httpServer) Dispose() error {
+	c.mu.Lock()
+	srv, logger, clk := c.srv, c.srvLogger, c.srvClock
+	c.mu.Unlock()
+	if srv == nil {
+		return nil
+	}
+
+	return c.shutdown(srv, logger, clk)
+}
+
+// shutdown shuts srv down, waiting for the requests in flight up to c.grace, and closes it if they
+// do not finish by then on clk, logging it to logger.
+func (c *httpServer) shutdown(srv *http.Server, logger Logger, clk Clock) error {
+	ctx, cancel := withTimeout(WithClock(context.Background(), clk), c.grace)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		logger.Printf("%s: graceful shutdown: %v; closing the server", c.name, err)
+		return srv.Close()
+	}
+
+	return nil
+}
+
+// Execute implements subcommands.Command.
+func (c *httpServer) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	if (c.certFile == "") != (c.keyFile == "") {
+		fmt.Fprintf(f.Output(), "%s: -tls-cert and -tls-key must be given together\n", c.name)
+		return subcommands.ExitUsageError
+	}
+
+	srv, err := c.build(ctx, f)
+	if err != nil {
+		PrintError(ctx, c.name, err)
+		return StatusFromError(err)
+	}
+	ln, err := net.Listen("tcp", c.addr)
+	if err != nil {
+		fmt.Fprintf(Stderr(ctx), "%s: %v\n", c.name, err)
+		return subcommands.ExitFailure
+	}
+	srv.Addr = ln.Addr().String()
+
+	logger, clk := contextLogger(ctx, c.logger), ClockFromContext(ctx)
+	c.mu.Lock()
+	c.srv, c.srvLogger, c.srvClock = srv, logger, clk
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		c.srv, c.srvLogger, c.srvClock = nil, nil, nil
+		c.mu.Unlock()
+	}()
+
+	scheme := "http"
+	if c.certFile != "" {
+		scheme = "https"
+	}
+	logger.Printf("%s: listening on %s://%s", c.name, scheme, ln.Addr())
+
+	served := make(chan error, 1)
+	go func() {
+		if c.certFile != "" {
+			served <- srv.ServeTLS(ln, c.certFile, c.keyFile)
+			return
+		}
+		served <- srv.Serve(ln)
+	}()
+
+	select {
+	case err = <-served:
+	case <-ctx.Done():
+		if err := c.shutdown(srv, logger, clk); err != nil {
+			fmt.Fprintf(Stderr(ctx), "%s: %v\n", c.name, err)
+		}
+		err = <-served
+	}
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		fmt.Fprintf(Stderr(ctx), "%s: %v\n", c.name, err)
+		return subcommands.ExitFailure
+	}
+
+	return subcommands.ExitSuccess
+}
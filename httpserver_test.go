@@ -0,0 +1,168 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"context"
+	"flag"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+// serverURL returns the URL of the server logged to logs by ServeHTTP, waiting for it.
+func serverURL(t *testing.T, logs *testcmd.LogRecorder) string {
+	t.Helper()
+
+	const marker = "listening on "
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		for _, line := range logs.Lines() {
+			if i := strings.Index(line, marker); i >= 0 {
+				return line[i+len(marker):]
+			}
+		}
+	}
+	t.Fatalf("wanted the address of the server logged but got %q", logs.Lines())
+	return ""
+}
+
+func TestServeHTTP(t *testing.T) {
+	tests := map[string]struct {
+		grace      time.Duration
+		wantStatus int
+		wantErr    bool
+	}{
+		"when the requests in flight finish in time": {
+			grace:      5 * time.Second,
+			wantStatus: http.StatusOK,
+		},
+		"when the requests in flight do not finish in time": {
+			grace:   10 * time.Millisecond,
+			wantErr: true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			defer testcmd.VerifyNoLeaks(t)
+
+			var logs testcmd.LogRecorder
+			started, release := make(chan struct{}), make(chan struct{})
+			shutdown := make(chan struct{})
+			cmd := subcommandsutil.ServeHTTP("serve", "serve the API", func(ctx context.Context, f *flag.FlagSet) (*http.Server, error) {
+				srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					if r.URL.Path == "/slow" {
+						close(started)
+						<-release
+					}
+					io.WriteString(w, "ok")
+				})}
+				srv.RegisterOnShutdown(func() { close(shutdown) })
+				return srv, nil
+			}, subcommandsutil.WithShutdownTimeout(tt.grace), subcommandsutil.WithDefaultAddr("127.0.0.1:0"), subcommandsutil.WithLogger(&logs))
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			done := make(chan subcommands.ExitStatus)
+			go func() {
+				status, _, _ := testcmd.Run(ctx, cmd)
+				done <- status
+			}()
+			url := serverURL(t, &logs)
+
+			client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+			if body := get(t, client, url+"/"); body != "ok" {
+				t.Fatalf("wanted the response %q but got %q", "ok", body)
+			}
+
+			type result struct {
+				status int
+				err    error
+			}
+			slow := make(chan result)
+			go func() {
+				resp, err := client.Get(url + "/slow")
+				if err != nil {
+					slow <- result{err: err}
+					return
+				}
+				resp.Body.Close()
+				slow <- result{status: resp.StatusCode}
+			}()
+			<-started
+			cancel()
+			<-shutdown
+			if tt.wantErr {
+				testcmd.AssertStatus(t, <-done, subcommands.ExitSuccess)
+				close(release)
+			} else {
+				close(release)
+				testcmd.AssertStatus(t, <-done, subcommands.ExitSuccess)
+			}
+
+			got := <-slow
+			if got.status != tt.wantStatus || (got.err != nil) != tt.wantErr {
+				t.Fatalf("wanted the status %d, error %t but got %d, %v", tt.wantStatus, tt.wantErr, got.status, got.err)
+			}
+			if _, err := client.Get(url + "/"); err == nil {
+				t.Fatal("wanted the server shut down but it still serves")
+			}
+			client.CloseIdleConnections()
+		})
+	}
+}
+
+func TestServeHTTPShutdownClock(t *testing.T) {
+	defer testcmd.VerifyNoLeaks(t)
+
+	var logs testcmd.LogRecorder
+	started, release := make(chan struct{}), make(chan struct{})
+	cmd := subcommandsutil.ServeHTTP("serve", "serve the API", func(ctx context.Context, f *flag.FlagSet) (*http.Server, error) {
+		return &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			close(started)
+			<-release
+		})}, nil
+	}, subcommandsutil.WithShutdownTimeout(time.Hour), subcommandsutil.WithDefaultAddr("127.0.0.1:0"), subcommandsutil.WithLogger(&logs))
+
+	clk := testcmd.NewFakeClock(time.Now())
+	ctx, cancel := context.WithCancel(subcommandsutil.WithClock(context.Background(), clk))
+	defer cancel()
+	done := make(chan subcommands.ExitStatus)
+	go func() {
+		status, _, _ := testcmd.Run(ctx, cmd)
+		done <- status
+	}()
+	url := serverURL(t, &logs)
+
+	client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+	slow := make(chan error)
+	go func() {
+		resp, err := client.Get(url + "/slow")
+		if err == nil {
+			resp.Body.Close()
+		}
+		slow <- err
+	}()
+	<-started
+	cancel()
+
+	// the grace timeout only expires when the FakeClock is advanced past it
+	clk.BlockUntil(1)
+	clk.Advance(time.Hour)
+	testcmd.AssertStatus(t, <-done, subcommands.ExitSuccess)
+	close(release)
+	if err := <-slow; err == nil {
+		t.Fatal("wanted the request in flight cut by the close but it succeeded")
+	}
+	if want := "serve: graceful shutdown: context deadline exceeded; closing the server"; !logs.Contains(want) {
+		t.Fatalf("wanted the log to contain %q but got %q", want, logs.Lines())
+	}
+	client.CloseIdleConnections()
+}
@@ -0,0 +1,238 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/google/subcommands"
+)
+
+// Listing renders the command listing of a Commander, the output of the help command without
+// arguments, in a configured order. It omits hidden commands like ExplainGroup, and lists the
+// commands registered at the time of rendering. Install it with:
+//
+//	cdr.Explain = (&subcommandsutil.Listing{Commander: cdr, Groups: []string{"remote"}}).Explain
+type Listing struct {
+	// Commander is the Commander whose commands are listed.
+	Commander *subcommands.Commander
+
+	// Groups are the names of the groups rendered first, in order. The other groups follow in
+	// alphabetical order.
+	Groups []string
+
+	// Commands are, per group name, the names of the commands rendered first, in order. The other
+	// commands follow in alphabetical order.
+	Commands map[string][]string
+
+	// Less orders the commands of every group instead of Commands, if set.
+	Less func(a, b subcommands.Command) bool
+
+	// Categories, if set, render the groups as sections with a header and a description, in the
+	// order of the categories. The commands of groups without category are rendered last, in the
+	// section titled DefaultTitle, in alphabetical order unless Less is set.
+	Categories []Category
+
+	// DefaultTitle is the title of the section of the commands without category. The default is
+	// "Other commands".
+	DefaultTitle string
+}
+
+// Category describes the group of commands named Name in a Listing.
+type Category struct {
+	// Name is the group name the commands are registered with.
+	Name string
+
+	// Title is the header of the section. The default is Name.
+	Title string
+
+	// Description is printed under the header, wrapped to the TerminalWidth of the output.
+	Description string
+
+	// Order orders the sections, the smallest first. Sections of equal Order are sorted by Name.
+	Order int
+}
+
+// listingGroup is a group of commands rendered by Listing.
+type listingGroup struct {
+	name string
+	cmds []subcommands.Command
+}
+
+// Explain writes the command listing to w. Its signature matches subcommands.Commander.Explain.
+func (l *Listing) Explain(w io.Writer) {
+	cdr := l.Commander
+	fmt.Fprintf(w, "Usage: %s <flags> <subcommand> <subcommand args>\n\n", cdr.Name())
+
+	if len(l.Categories) > 0 {
+		l.writeSections(w)
+	} else {
+		for _, g := range l.groups() {
+			writeGroup(w, g.name, g.cmds, TerminalWidth(w))
+		}
+	}
+
+	var important []*flag.Flag
+	cdr.VisitAllImportant(func(f *flag.Flag) { important = append(important, f) })
+	if len(important) == 0 {
+		count := 0
+		cdr.VisitAll(func(*flag.Flag) { count++ })
+		if count > 0 {
+			fmt.Fprintf(w, "\nUse \"%s flags\" for a list of top-level flags\n", cdr.Name())
+		}
+		return
+	}
+
+	fmt.Fprintf(w, "\nTop-level flags (use \"%s flags\" for a full list):\n", cdr.Name())
+	for _, f := range important {
+		fmt.Fprintf(w, "  -%s=%s: %s\n", f.Name, f.DefValue, f.Usage)
+	}
+}
+
+// groups returns the non-empty groups of visible commands, in order.
+func (l *Listing) groups() []listingGroup {
+	var groups []listingGroup
+	l.Commander.VisitGroups(func(g *subcommands.CommandGroup) {
+		lg := listingGroup{name: g.Name()}
+		l.Commander.VisitCommands(func(cg *subcommands.CommandGroup, cmd subcommands.Command) {
+			if cg == g && !IsHiddenCommand(cmd) {
+				lg.cmds = append(lg.cmds, cmd)
+			}
+		})
+		if len(lg.cmds) > 0 {
+			groups = append(groups, lg)
+		}
+	})
+
+	groupRank := rank(l.Groups)
+	sort.SliceStable(groups, func(i, j int) bool {
+		return ranked(groupRank, groups[i].name, groups[j].name)
+	})
+	for _, g := range groups {
+		cmds := g.cmds
+		if l.Less != nil {
+			sort.SliceStable(cmds, func(i, j int) bool { return l.Less(cmds[i], cmds[j]) })
+			continue
+		}
+		cmdRank := rank(l.Commands[g.name])
+		sort.SliceStable(cmds, func(i, j int) bool {
+			return ranked(cmdRank, cmds[i].Name(), cmds[j].Name())
+		})
+	}
+
+	return groups
+}
+
+// writeSections writes the groups as the sections of l.Categories.
+func (l *Listing) writeSections(w io.Writer) {
+	categories := append([]Category(nil), l.Categories...)
+	sort.SliceStable(categories, func(i, j int) bool {
+		if categories[i].Order != categories[j].Order {
+			return categories[i].Order < categories[j].Order
+		}
+		return categories[i].Name < categories[j].Name
+	})
+
+	cmds := make(map[string][]subcommands.Command)
+	known := make(map[string]bool, len(categories))
+	for _, c := range categories {
+		known[c.Name] = true
+	}
+	var others []subcommands.Command
+	for _, g := range l.groups() {
+		if known[g.name] {
+			cmds[g.name] = g.cmds
+		} else {
+			others = append(others, g.cmds...)
+		}
+	}
+
+	for _, c := range categories {
+		if len(cmds[c.Name]) == 0 {
+			continue
+		}
+		title := c.Title
+		if title == "" {
+			title = c.Name
+		}
+		writeSection(w, title, c.Description, cmds[c.Name])
+	}
+	if len(others) > 0 {
+		title := l.DefaultTitle
+		if title == "" {
+			title = "Other commands"
+		}
+		if l.Less == nil {
+			sort.SliceStable(others, func(i, j int) bool { return others[i].Name() < others[j].Name() })
+		}
+		writeSection(w, title, "", others)
+	}
+}
+
+// writeSection writes a section of a Listing with Categories.
+func writeSection(w io.Writer, title, description string, cmds []subcommands.Command) {
+	width := TerminalWidth(w)
+	fmt.Fprintf(w, "%s:\n", title)
+	for _, line := range wrapText(description, width-2) {
+		fmt.Fprintf(w, "  %s\n", line)
+	}
+	var b strings.Builder
+	writeGroup(&b, "", cmds, width)
+	// drop the header of the group, the section has its own
+	fmt.Fprint(w, strings.TrimPrefix(b.String(), "Subcommands:\n"))
+}
+
+// wrapText splits s into lines of at most width bytes, breaking at spaces. Longer words get lines
+// of their own.
+func wrapText(s string, width int) []string {
+	var lines []string
+	var line string
+	for _, word := range strings.Fields(s) {
+		switch {
+		case line == "":
+			line = word
+		case len(line)+1+len(word) <= width:
+			line += " " + word
+		default:
+			lines = append(lines, line)
+			line = word
+		}
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+
+	return lines
+}
+
+// rank returns the positions of names.
+func rank(names []string) map[string]int {
+	m := make(map[string]int, len(names))
+	for i, name := range names {
+		if _, ok := m[name]; !ok {
+			m[name] = i
+		}
+	}
+
+	return m
+}
+
+// ranked reports whether a is ordered before b: ranked names first by their rank, then the others
+// alphabetically.
+func ranked(ranks map[string]int, a, b string) bool {
+	ra, aok := ranks[a]
+	rb, bok := ranks[b]
+	switch {
+	case aok && bok:
+		return ra < rb
+	case aok != bok:
+		return aok
+	default:
+		return a < b
+	}
+}
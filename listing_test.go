@@ -0,0 +1,146 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+// listedNames returns the group headers and command names of a rendered listing, in order.
+func listedNames(listing string) []string {
+	re := regexp.MustCompile(`(?m)^(?:Subcommands for (\S+):|Subcommands:|\t(\S+))`)
+	var names []string
+	for _, m := range re.FindAllStringSubmatch(listing, -1) {
+		switch {
+		case m[1] != "":
+			names = append(names, "["+m[1]+"]")
+		case m[2] != "":
+			names = append(names, m[2])
+		default:
+			names = append(names, "[]")
+		}
+	}
+
+	return names
+}
+
+// newListingHarness returns a Harness with commands in the "remote" and "local" groups.
+func newListingHarness(t *testing.T) *testcmd.Harness {
+	h := testcmd.NewHarness(t)
+	for _, name := range []string{"fetch", "pull", "push"} {
+		h.Register(testcmd.NewRecording(name), "remote")
+	}
+	h.Register(testcmd.NewRecording("status"), "local")
+	h.Register(testcmd.NewRecording("add"), "local")
+	h.Register(subcommandsutil.Hidden(testcmd.NewRecording("debug")), "local")
+
+	return h
+}
+
+func TestListing(t *testing.T) {
+	tests := map[string]struct {
+		listing subcommandsutil.Listing
+		want    []string
+	}{
+		"when nothing is configured": {
+			want: []string{"[help]", "commands", "flags", "help", "[local]", "add", "status", "[remote]", "fetch", "pull", "push"},
+		},
+		"when groups and commands are ordered": {
+			listing: subcommandsutil.Listing{
+				Groups: []string{"remote", "local"},
+				Commands: map[string][]string{
+					"remote": {"push", "pull"},
+					"local":  {"status"},
+				},
+			},
+			want: []string{"[remote]", "push", "pull", "fetch", "[local]", "status", "add", "[help]", "commands", "flags", "help"},
+		},
+		"when commands are ordered by a less func": {
+			listing: subcommandsutil.Listing{
+				Groups: []string{"help"},
+				Less: func(a, b subcommands.Command) bool {
+					return a.Name() > b.Name()
+				},
+			},
+			want: []string{"[help]", "help", "flags", "commands", "[local]", "status", "add", "[remote]", "push", "pull", "fetch"},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			h := newListingHarness(t)
+			listing := tt.listing
+			listing.Commander = h.Commander
+			h.Commander.Explain = listing.Explain
+
+			testcmd.RequireSuccess(t, h.Execute(context.Background(), "help"))
+			if got := listedNames(h.Stdout.String()); strings.Join(got, " ") != strings.Join(tt.want, " ") {
+				t.Fatalf("wanted %q but got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestListingDynamic(t *testing.T) {
+	h := newListingHarness(t)
+	h.Flags.Bool("verbose", false, "verbose output")
+	h.Commander.Explain = (&subcommandsutil.Listing{Commander: h.Commander, Groups: []string{"new"}}).Explain
+	h.Register(testcmd.NewRecording("late", testcmd.WithSynopsis("registered late")), "new")
+
+	testcmd.RequireSuccess(t, h.Execute(context.Background(), "help"))
+	listing := h.Stdout.String()
+	if !strings.HasPrefix(listing, "Usage: TestListingDynamic <flags> <subcommand> <subcommand args>\n\nSubcommands for new:\n\tlate             registered late\n") {
+		t.Fatalf("wanted the late command to be listed first but got %q", listing)
+	}
+	if !strings.HasSuffix(listing, "\nUse \"TestListingDynamic flags\" for a list of top-level flags\n") {
+		t.Fatalf("wanted the top-level flags hint but got %q", listing)
+	}
+	if strings.Contains(listing, "debug") {
+		t.Fatalf("wanted the hidden command to be omitted but got %q", listing)
+	}
+
+	h.Stdout.Reset()
+	h.Commander.ImportantFlag("verbose")
+	testcmd.RequireSuccess(t, h.Execute(context.Background(), "help"))
+	if want := "\nTop-level flags (use \"TestListingDynamic flags\" for a full list):\n  -verbose=false: verbose output\n"; !strings.HasSuffix(h.Stdout.String(), want) {
+		t.Fatalf("wanted the important flags but got %q", h.Stdout.String())
+	}
+}
+
+func TestListingCategories(t *testing.T) {
+	h := newListingHarness(t)
+	h.Register(testcmd.NewRecording("cleanup", testcmd.WithSynopsis("remove temporary files")), "misc")
+	h.Commander.Explain = (&subcommandsutil.Listing{
+		Commander: h.Commander,
+		Commands:  map[string][]string{"local": {"status"}},
+		Categories: []subcommandsutil.Category{
+			{
+				Name:        "remote",
+				Title:       "Remote commands",
+				Description: "Synchronize the local repository with remote repositories. Fetching never changes the working tree, pulling merges the fetched changes.",
+				Order:       2,
+			},
+			{
+				Name:        "local",
+				Title:       "Repository commands",
+				Description: "Work with local repositories.",
+				Order:       1,
+			},
+			{
+				Name:  "unused",
+				Title: "Unused commands",
+			},
+		},
+	}).Explain
+
+	testcmd.RequireSuccess(t, h.Execute(context.Background(), "help"))
+	testcmd.Golden(t, h.Stdout.String(), "testdata/listing_categories.golden")
+}
@@ -0,0 +1,29 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+//go:build !unix && !windows
+
+package subcommandsutil
+
+import (
+	"errors"
+	"os"
+)
+
+// errLockUnsupported is returned by tryLockFile on platforms without file locks.
+var errLockUnsupported = errors.New("file locks are not supported on this platform")
+
+// tryLockFile reports that file locks are not supported on this platform.
+func tryLockFile(f *os.File) (bool, error) {
+	return false, errLockUnsupported
+}
+
+// unlockFile reports that file locks are not supported on this platform.
+func unlockFile(f *os.File) error {
+	return errLockUnsupported
+}
+
+// processAlive reports that the process pid is running, as it cannot be checked on this platform.
+func processAlive(pid int) bool {
+	return true
+}
@@ -0,0 +1,67 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	kernel32         = syscall.NewLazyDLL("kernel32.dll")
+	procLockFileEx   = kernel32.NewProc("LockFileEx")
+	procUnlockFileEx = kernel32.NewProc("UnlockFileEx")
+)
+
+const (
+	lockfileFailImmediately = 0x00000001 // LOCKFILE_FAIL_IMMEDIATELY
+	lockfileExclusiveLock   = 0x00000002 // LOCKFILE_EXCLUSIVE_LOCK
+
+	errorLockViolation syscall.Errno = 33 // ERROR_LOCK_VIOLATION
+)
+
+// lockOffsetHigh is the high word of the offset of the byte locked by tryLockFile, far past the pid
+// written into the file, as the locked range cannot be read by the other processes.
+const lockOffsetHigh = 0x7fffffff
+
+// tryLockFile takes the exclusive lock of a byte of f without waiting. It reports false if another
+// open file holds the lock.
+func tryLockFile(f *os.File) (bool, error) {
+	ol := syscall.Overlapped{OffsetHigh: lockOffsetHigh}
+	r, _, err := procLockFileEx.Call(f.Fd(), lockfileExclusiveLock|lockfileFailImmediately, 0, 1, 0, uintptr(unsafe.Pointer(&ol)))
+	switch {
+	case r != 0:
+		return true, nil
+	case errors.Is(err, errorLockViolation):
+		return false, nil
+	default:
+		return false, err
+	}
+}
+
+// unlockFile releases the lock of f taken by tryLockFile.
+func unlockFile(f *os.File) error {
+	ol := syscall.Overlapped{OffsetHigh: lockOffsetHigh}
+	if r, _, err := procUnlockFileEx.Call(f.Fd(), 0, 1, 0, uintptr(unsafe.Pointer(&ol))); r == 0 {
+		return err
+	}
+
+	return nil
+}
+
+// processAlive reports whether the process pid is running.
+func processAlive(pid int) bool {
+	const processQueryLimitedInformation = 0x1000
+	h, err := syscall.OpenProcess(processQueryLimitedInformation, false, uint32(pid))
+	if err != nil {
+		return false
+	}
+	defer syscall.CloseHandle(h)
+
+	var code uint32
+	const stillActive = 259
+	return syscall.GetExitCodeProcess(h, &code) == nil && code == stillActive
+}
@@ -0,0 +1,92 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DedupLogger is a Logger suppressing the copies of a message logged over and over, like a
+// "connection refused" in a retry loop. It is safe for concurrent use.
+type DedupLogger struct {
+	logger Logger
+	clock  Clock
+	n      int
+	window time.Duration
+
+	mu         sync.Mutex
+	last       string    // the last message logged
+	count      int       // the consecutive copies of last in the window, suppressed or not
+	since      time.Time // when the window of last started
+	suppressed int       // the copies of last suppressed
+}
+
+// make sure DedupLogger implements the Logger interface.
+var _ Logger = (*DedupLogger)(nil)
+
+// NewDedupLogger returns a DedupLogger logging to l. Once a message has been logged n times in a
+// row within window of its first copy, measured on clk, or the real clock if nil, the further
+// copies are suppressed. A single line like:
+//
+//	last message repeated 42 times
+//
+// then counts them, logged before the next distinct message, before the next copy once the window
+// has elapsed, which starts a new one, or by Flush. Distinct messages are never suppressed.
+func NewDedupLogger(l Logger, n int, window time.Duration, clk Clock) *DedupLogger {
+	if n < 1 {
+		n = 1
+	}
+	if clk == nil {
+		clk = realClock{}
+	}
+
+	return &DedupLogger{
+		logger: l,
+		clock:  clk,
+		n:      n,
+		window: window,
+	}
+}
+
+// Printf implements Logger.
+func (d *DedupLogger) Printf(format string, v ...interface{}) {
+	msg, now := fmt.Sprintf(format, v...), d.clock.Now()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.count > 0 && msg == d.last && now.Sub(d.since) < d.window {
+		d.count++
+		if d.count > d.n {
+			d.suppressed++
+			return
+		}
+		d.logger.Printf(format, v...)
+		return
+	}
+
+	d.flush()
+	d.last, d.count, d.since = msg, 1, now
+	d.logger.Printf(format, v...)
+}
+
+// Flush logs the count of the suppressed copies of the last message, if any.
+func (d *DedupLogger) Flush() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.flush()
+
+	return nil
+}
+
+// flush logs the count of the suppressed copies of the last message, if any. d.mu must be held.
+func (d *DedupLogger) flush() {
+	if d.suppressed > 0 {
+		d.logger.Printf("last message repeated %d times", d.suppressed)
+		d.suppressed = 0
+	}
+}
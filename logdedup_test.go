@@ -0,0 +1,94 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+func TestDedupLogger(t *testing.T) {
+	// a step of the test logs msg, or advances the fake clock by advance if msg is empty
+	type step struct {
+		msg     string
+		advance time.Duration
+	}
+	repeat := func(msg string, n int) []step {
+		steps := make([]step, n)
+		for i := range steps {
+			steps[i] = step{msg: msg}
+		}
+		return steps
+	}
+	concat := func(steps ...[]step) []step {
+		var all []step
+		for _, s := range steps {
+			all = append(all, s...)
+		}
+		return all
+	}
+
+	tests := map[string]struct {
+		steps []step
+		want  []string
+	}{
+		"when a message is repeated up to the limit": {
+			steps: repeat("connection refused", 3),
+			want:  []string{"connection refused", "connection refused", "connection refused"},
+		},
+		"when a message is repeated beyond the limit": {
+			steps: repeat("connection refused", 10),
+			want:  []string{"connection refused", "connection refused", "connection refused", "last message repeated 7 times"},
+		},
+		"when the repeats are followed by a distinct message": {
+			steps: concat(repeat("connection refused", 5), repeat("connected", 1), repeat("connection refused", 4)),
+			want: []string{
+				"connection refused", "connection refused", "connection refused", "last message repeated 2 times",
+				"connected",
+				"connection refused", "connection refused", "connection refused", "last message repeated 1 times",
+			},
+		},
+		"when distinct messages are interleaved": {
+			steps: concat(repeat("a", 1), repeat("b", 1), repeat("a", 1), repeat("b", 1), repeat("a", 1), repeat("b", 1), repeat("a", 1), repeat("b", 1)),
+			want:  []string{"a", "b", "a", "b", "a", "b", "a", "b"},
+		},
+		"when the window elapses": {
+			steps: concat(repeat("connection refused", 6), []step{{advance: time.Minute}}, repeat("connection refused", 4)),
+			want: []string{
+				"connection refused", "connection refused", "connection refused", "last message repeated 3 times",
+				"connection refused", "connection refused", "connection refused", "last message repeated 1 times",
+			},
+		},
+		"when the window has not elapsed": {
+			steps: concat(repeat("connection refused", 4), []step{{advance: 59 * time.Second}}, repeat("connection refused", 2)),
+			want:  []string{"connection refused", "connection refused", "connection refused", "last message repeated 3 times"},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			clk := testcmd.NewFakeClock(time.Now())
+			var logs testcmd.LogRecorder
+			l := subcommandsutil.NewDedupLogger(&logs, 3, time.Minute, clk)
+
+			for _, s := range tt.steps {
+				if s.msg == "" {
+					clk.Advance(s.advance)
+					continue
+				}
+				l.Printf("%s", s.msg)
+			}
+			if err := l.Flush(); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := logs.Lines(); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("wanted %q but got %q", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,189 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/google/subcommands"
+)
+
+// logFile wraps a subcommands.Command so that its output is teed into a log file.
+type logFile struct {
+	sub subcommands.Command
+
+	path string
+
+	mu   sync.Mutex
+	file *teeFile // the file of the running execution
+}
+
+// make sure logFile implements the CancelableCommand interface.
+var _ CancelableCommand = (*logFile)(nil)
+
+// LogFile wraps sub with the -log-file flag. When set, everything written to Stdout and Stderr
+// during the execution, including the lines of the wrappers logging to the standard logger, is also
+// appended to the named file, after a header with the time and the command line of the run, the
+// values of sensitive flags redacted. The parent directories of the file are created.
+//
+// The file is closed when Execute returns, or by Dispose when a Cancelable wrapper stops waiting for
+// sub; Dispose also forwards to the Dispose method of sub, if any. A failed write to the file is
+// warned about once on Stderr.
+func LogFile(sub subcommands.Command) CancelableCommand {
+	return &logFile{
+		sub: sub,
+	}
+}
+
+// Name forwards to the underlying c.sub Command.
+func (c *logFile) Name() string {
+	return c.sub.Name()
+}
+
+// Usage forwards to the underlying c.sub Command.
+func (c *logFile) Usage() string {
+	return c.sub.Usage()
+}
+
+// Synopsis forwards to the underlying c.sub Command.
+func (c *logFile) Synopsis() string {
+	return c.sub.Synopsis()
+}
+
+// Unwrap returns the underlying c.sub Command.
+func (c *logFile) Unwrap() subcommands.Command {
+	return c.sub
+}
+
+// SetFlags forwards to the underlying c.sub Command and registers the -log-file flag.
+func (c *logFile) SetFlags(f *flag.FlagSet) {
+	c.sub.SetFlags(f)
+	f.StringVar(&c.path, "log-file", "", "also write the output to the log file at `path`")
+}
+
+// Dispose closes the log file of the running execution and forwards to the underlying c.sub
+// Command if it is a CancelableCommand.
+func (c *logFile) Dispose() error {
+	c.mu.Lock()
+	file := c.file
+	c.mu.Unlock()
+	file.close()
+
+	if sub, ok := c.sub.(CancelableCommand); ok {
+		return sub.Dispose()
+	}
+
+	return nil
+}
+
+// Execute forwards to the underlying c.sub Command with its output teed into the log file, if the
+// -log-file flag is set.
+func (c *logFile) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	if c.path == "" {
+		return c.sub.Execute(ctx, f, args...)
+	}
+
+	file, err := openTeeFile(c.path, Stderr(ctx))
+	if err != nil {
+		fmt.Fprintf(Stderr(ctx), "%s: opening log file: %v\n", c.sub.Name(), err)
+		return subcommands.ExitFailure
+	}
+	c.mu.Lock()
+	c.file = file
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		c.file = nil
+		c.mu.Unlock()
+		file.close()
+	}()
+
+	fmt.Fprintf(file, "=== %s %s\n", ClockFromContext(ctx).Now().Format(time.RFC3339), commandLine(f, c.sub.Name()))
+
+	stdout := &teeWriter{w: Stdout(ctx), file: file}
+	stderr := &teeWriter{w: Stderr(ctx), file: file}
+
+	return c.sub.Execute(WithOutput(ctx, stdout, stderr), f, args...)
+}
+
+// teeFile is a log file written by several teeWriter.
+type teeFile struct {
+	warn io.Writer
+
+	mu     sync.Mutex
+	f      *os.File
+	warned bool
+}
+
+// openTeeFile opens the log file at path for appending, creating it and its parent directories.
+// Write errors are warned about once to warn.
+func openTeeFile(path string, warn io.Writer) (*teeFile, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	return &teeFile{warn: warn, f: f}, nil
+}
+
+// Write implements io.Writer. It never fails; the first error is warned about, and the writes to a
+// closed file are dropped.
+func (t *teeFile) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.f == nil {
+		return len(p), nil
+	}
+	if _, err := t.f.Write(p); err != nil && !t.warned {
+		t.warned = true
+		fmt.Fprintf(t.warn, "warning: writing log file: %v\n", err)
+	}
+
+	return len(p), nil
+}
+
+// close syncs and closes the file, once. It does nothing on a nil t.
+func (t *teeFile) close() {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.f == nil {
+		return
+	}
+	_ = t.f.Sync()
+	if err := t.f.Close(); err != nil && !t.warned {
+		t.warned = true
+		fmt.Fprintf(t.warn, "warning: closing log file: %v\n", err)
+	}
+	t.f = nil
+}
+
+// teeWriter writes to w and to the log file.
+type teeWriter struct {
+	w    io.Writer
+	file *teeFile
+}
+
+// Write implements io.Writer.
+func (t *teeWriter) Write(p []byte) (int, error) {
+	n, err := t.w.Write(p)
+	t.file.Write(p[:n])
+
+	return n, err
+}
@@ -0,0 +1,142 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+func TestLogFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "sync.log")
+	clk := testcmd.NewFakeClock(time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC))
+
+	cmd := subcommandsutil.LogFile(subcommandsutil.Logged(testcmd.NewRecording("sync",
+		testcmd.WithFlags(func(f *flag.FlagSet) {
+			f.String("token", "", "the access token")
+			subcommandsutil.MarkSensitive(f, "token")
+		}),
+		testcmd.WithExecute(func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+			fmt.Fprintln(subcommandsutil.Stdout(ctx), "synced 3 files")
+			fmt.Fprintln(subcommandsutil.Stderr(ctx), "skipped 1 file")
+			return subcommands.ExitSuccess
+		}),
+	)))
+
+	for i := 0; i < 2; i++ {
+		var stdout, stderr testcmd.Buffer
+		ctx := subcommandsutil.WithOutput(subcommandsutil.WithClock(context.Background(), clk), &stdout, &stderr)
+		status, _, err := testcmd.Run(ctx, cmd, "-log-file", path, "-token", "s3cr3t", "remote")
+		if err != nil {
+			t.Fatal(err)
+		}
+		testcmd.RequireSuccess(t, status)
+		if want := "synced 3 files\n"; stdout.String() != want {
+			t.Fatalf("wanted stdout %q but got %q", want, stdout.String())
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	log := string(data)
+	header := "=== 2021-01-02T03:04:05Z sync -log-file=" + path + " '-token=" + subcommandsutil.Redacted + "' remote\n"
+	if got := strings.Count(log, header); got != 2 {
+		t.Fatalf("wanted the run header %q twice but got %d times in %q", header, got, log)
+	}
+	for _, want := range []string{"synced 3 files\n", "skipped 1 file\n", "sync: running sync", "sync: finished with status 0"} {
+		if !strings.Contains(log, want) {
+			t.Fatalf("wanted the log file to contain %q but got %q", want, log)
+		}
+	}
+	if strings.Contains(log, "s3cr3t") {
+		t.Fatalf("wanted the sensitive value redacted but got %q", log)
+	}
+}
+
+func TestLogFileWithoutFlag(t *testing.T) {
+	var stdout testcmd.Buffer
+	ctx := subcommandsutil.WithOutput(context.Background(), &stdout, nil)
+	status, _, err := testcmd.Run(ctx, subcommandsutil.LogFile(newListCommand(1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	testcmd.RequireSuccess(t, status)
+	if want := "line 0\n"; stdout.String() != want {
+		t.Fatalf("wanted stdout %q but got %q", want, stdout.String())
+	}
+}
+
+func TestLogFileOpenError(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file")
+	if err := os.WriteFile(file, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stderr testcmd.Buffer
+	ctx := subcommandsutil.WithOutput(context.Background(), nil, &stderr)
+	status, _, err := testcmd.Run(ctx, subcommandsutil.LogFile(newListCommand(1)), "-log-file", filepath.Join(file, "sync.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	testcmd.AssertStatus(t, status, subcommands.ExitFailure)
+	if want := "list: opening log file: "; !strings.HasPrefix(stderr.String(), want) {
+		t.Fatalf("wanted stderr to start with %q but got %q", want, stderr.String())
+	}
+}
+
+func TestLogFileCanceled(t *testing.T) {
+	defer testcmd.VerifyNoLeaks(t)
+
+	path := filepath.Join(t.TempDir(), "sync.log")
+	started, release, done := make(chan struct{}), make(chan struct{}), make(chan struct{})
+	rec := testcmd.NewRecording("sync",
+		testcmd.WithExecute(func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+			defer close(done)
+			fmt.Fprintln(subcommandsutil.Stdout(ctx), "early")
+			close(started)
+			<-release
+			fmt.Fprintln(subcommandsutil.Stdout(ctx), "late")
+			return subcommands.ExitSuccess
+		}),
+	)
+	cmd := subcommandsutil.Cancelable(subcommandsutil.LogFile(rec), subcommandsutil.WithLogger(&testcmd.LogRecorder{}))
+
+	ctx, cancel := context.WithCancel(subcommandsutil.WithOutput(context.Background(), &testcmd.Buffer{}, &testcmd.Buffer{}))
+	go func() {
+		<-started
+		cancel()
+	}()
+	status, _, err := testcmd.Run(ctx, cmd, "-log-file", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testcmd.AssertStatus(t, status, subcommands.ExitFailure)
+	close(release)
+	<-done
+
+	if got := rec.DisposeCount(); got != 1 {
+		t.Fatalf("wanted Dispose forwarded once but got %d", got)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if log := string(data); !strings.Contains(log, "early\n") || strings.Contains(log, "late") {
+		t.Fatalf("wanted the log file closed on cancellation but got %q", log)
+	}
+}
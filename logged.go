@@ -0,0 +1,144 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"context"
+	"flag"
+	"time"
+
+	"github.com/google/subcommands"
+)
+
+// LoggedOption is an option of the Logged wrapper.
+type LoggedOption interface {
+	applyLogged(*logged)
+}
+
+// applyLogged implements LoggedOption.
+func (o LoggerOption) applyLogged(c *logged) {
+	c.logger = o.logger
+}
+
+// loggedOptionFunc is a LoggedOption implemented by a function.
+type loggedOptionFunc func(*logged)
+
+// applyLogged implements LoggedOption.
+func (fn loggedOptionFunc) applyLogged(c *logged) { fn(c) }
+
+// WithDryRunPrefix makes Logged prefix its lines with "DRY-RUN" when the execution is in dry-run
+// mode, either because of the context or the -dry-run flag of DryRunCommand.
+func WithDryRunPrefix() LoggedOption {
+	return loggedOptionFunc(func(c *logged) {
+		c.dryRunPrefix = true
+	})
+}
+
+// WithLogDedup makes Logged suppress the copies of a message logged more than n times in a row
+// within window, with a DedupLogger measured on the Clock of the execution context. The DedupLogger
+// is also set on the execution context by NewContextWithLogger, for the wrappers and the code of
+// sub logging to LoggerFromContext, and flushed when the execution finishes.
+func WithLogDedup(n int, window time.Duration) LoggedOption {
+	return loggedOptionFunc(func(c *logged) {
+		c.dedupN, c.dedupWindow = n, window
+	})
+}
+
+// logged wraps a subcommands.Command so that its executions are logged.
+type logged struct {
+	sub          subcommands.Command
+	logger       Logger
+	dryRunPrefix bool
+	dedupN       int
+	dedupWindow  time.Duration
+}
+
+// make sure logged implements the subcommands.Command interface.
+var _ subcommands.Command = (*logged)(nil)
+
+// Logged wraps sub so that the command line of each execution, and its exit status and duration,
+// are logged. The values of sensitive flags are logged as Redacted.
+func Logged(sub subcommands.Command, opts ...LoggedOption) subcommands.Command {
+	c := &logged{
+		sub:    sub,
+		logger: stdLogger{},
+	}
+	for _, opt := range opts {
+		opt.applyLogged(c)
+	}
+
+	return c
+}
+
+// Name forwards to the underlying c.sub Command.
+func (c *logged) Name() string {
+	return c.sub.Name()
+}
+
+// Usage forwards to the underlying c.sub Command.
+func (c *logged) Usage() string {
+	return c.sub.Usage()
+}
+
+// Synopsis forwards to the underlying c.sub Command.
+func (c *logged) Synopsis() string {
+	return c.sub.Synopsis()
+}
+
+// SetFlags forwards to the underlying c.sub Command.
+func (c *logged) SetFlags(f *flag.FlagSet) {
+	c.sub.SetFlags(f)
+}
+
+// Unwrap returns the underlying c.sub Command.
+func (c *logged) Unwrap() subcommands.Command {
+	return c.sub
+}
+
+// Execute logs the command line of f, forwards to the underlying c.sub Command, and logs the
+// result. In quiet mode, only a result other than subcommands.ExitSuccess is logged.
+func (c *logged) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	prefix := ""
+	if c.dryRunPrefix && isDryRun(ctx, f) {
+		prefix = "DRY-RUN "
+	}
+
+	logger := contextLogger(ctx, c.logger)
+	if c.dedupN > 0 {
+		dedup := NewDedupLogger(logger, c.dedupN, c.dedupWindow, ClockFromContext(ctx))
+		defer dedup.Flush()
+		logger, ctx = dedup, NewContextWithLogger(ctx, dedup)
+	}
+	quiet := IsQuiet(ctx)
+	if !quiet {
+		logger.Printf("%s%s: running %s", prefix, c.sub.Name(), commandLine(f, c.sub.Name()))
+	}
+
+	clk := ClockFromContext(ctx)
+	start := clk.Now()
+	status := c.sub.Execute(ctx, f, args...)
+	if quiet && status == subcommands.ExitSuccess {
+		return status
+	}
+	logger.Printf("%s%s: finished with status %d in %v", prefix, c.sub.Name(), status, clk.Now().Sub(start))
+
+	return status
+}
+
+// commandLine renders the command line of the command named name from the explicitly set flags
+// and the positional arguments of f, quoted by QuoteArgs.
+func commandLine(f *flag.FlagSet, name string) string {
+	return QuoteArgs(commandArgv(f, name))
+}
+
+// commandArgv returns the words of the command line of commandLine.
+func commandArgv(f *flag.FlagSet, name string) []string {
+	argv := []string{name}
+	values := FlagValues(f)
+	for _, name := range sortedFlagNames(values) {
+		argv = append(argv, "-"+name+"="+values[name])
+	}
+
+	return append(argv, f.Args()...)
+}
@@ -0,0 +1,88 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"context"
+	"flag"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+func TestLogged(t *testing.T) {
+	var logs testcmd.LogRecorder
+	fcmd := testcmd.NewRecording("build",
+		testcmd.WithFlags(func(f *flag.FlagSet) {
+			f.Bool("race", false, "enable the race detector")
+		}),
+	)
+	cmd := subcommandsutil.Logged(fcmd, subcommandsutil.WithLogger(&logs))
+
+	f := flag.NewFlagSet("build", flag.ContinueOnError)
+	cmd.SetFlags(f)
+	if err := f.Parse([]string{"-race", "./..."}); err != nil {
+		t.Fatal(err)
+	}
+
+	if status := cmd.Execute(context.Background(), f); status != subcommands.ExitSuccess {
+		t.Fatalf("wanted %v but got %v", subcommands.ExitSuccess, status)
+	}
+	if !fcmd.DidFinish() {
+		t.Fatal("wanted the command to finish")
+	}
+
+	lines := logs.Lines()
+	if len(lines) != 2 {
+		t.Fatalf("wanted 2 log lines but got %q", lines)
+	}
+	if want := "build: running build -race=true ./..."; lines[0] != want {
+		t.Fatalf("wanted %q but got %q", want, lines[0])
+	}
+	if want := "build: finished with status 0 in "; !strings.HasPrefix(lines[1], want) {
+		t.Fatalf("wanted prefix %q but got %q", want, lines[1])
+	}
+}
+
+func TestLoggedDuration(t *testing.T) {
+	clk := testcmd.NewFakeClock(time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC))
+	var logs testcmd.LogRecorder
+	cmd := subcommandsutil.Logged(testcmd.NewRecording("build", testcmd.WithExecute(func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+		clk.Advance(3 * time.Second)
+		return subcommands.ExitSuccess
+	})), subcommandsutil.WithLogger(&logs))
+
+	status, _, _ := testcmd.Run(subcommandsutil.WithClock(context.Background(), clk), cmd)
+	testcmd.RequireSuccess(t, status)
+	if lines := logs.Lines(); len(lines) != 2 || lines[1] != "build: finished with status 0 in 3s" {
+		t.Fatalf("wanted the duration measured on the Clock but got %q", lines)
+	}
+}
+
+func TestLoggedDedup(t *testing.T) {
+	var logs testcmd.LogRecorder
+	sub := testcmd.NewRecording("watch", testcmd.WithExecute(func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+		logger := subcommandsutil.LoggerFromContext(ctx)
+		for i := 0; i < 50; i++ {
+			logger.Printf("watch: connection refused")
+		}
+		return subcommands.ExitSuccess
+	}))
+	cmd := subcommandsutil.Logged(sub, subcommandsutil.WithLogger(&logs), subcommandsutil.WithLogDedup(2, time.Minute))
+
+	status, _, _ := testcmd.Run(context.Background(), cmd)
+	testcmd.RequireSuccess(t, status)
+
+	lines := logs.Lines()
+	want := []string{"watch: running watch", "watch: connection refused", "watch: connection refused", "last message repeated 48 times"}
+	if len(lines) != 5 || !reflect.DeepEqual(lines[:4], want) || !strings.HasPrefix(lines[4], "watch: finished with status 0 in ") {
+		t.Fatalf("wanted %q then the finished line but got %q", want, lines)
+	}
+}
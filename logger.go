@@ -0,0 +1,70 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"context"
+	"log"
+)
+
+// Logger is the logger used by the wrappers of this package. *log.Logger implements it.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// stdLogger is a Logger writing to the standard logger of the log package.
+type stdLogger struct{}
+
+// Printf implements Logger.
+func (stdLogger) Printf(format string, v ...interface{}) {
+	log.Printf(format, v...)
+}
+
+// loggerKey is the context key of the Logger set by NewContextWithLogger.
+type loggerKey struct{}
+
+// NewContextWithLogger returns a copy of ctx carrying l, which the wrappers of this package log to
+// during the execution of ctx unless they are given WithLogger. Run and Main install the Logger of
+// their WithLogger option this way, so that every wrapper of the program logs to it.
+func NewContextWithLogger(ctx context.Context, l Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, l)
+}
+
+// LoggerFromContext returns the Logger the wrappers of this package log to during the execution of
+// ctx unless they are given WithLogger: the one set by NewContextWithLogger, or else the standard
+// logger, writing to Stderr if ctx carries the output writers set by WithOutput.
+func LoggerFromContext(ctx context.Context) Logger {
+	return contextLogger(ctx, stdLogger{})
+}
+
+// contextLogger returns the Logger l logs with during the execution of ctx: the standard logger,
+// the default of the wrappers, is replaced by the Logger set by NewContextWithLogger, or by a
+// logger with the same prefix and flags writing to Stderr if ctx carries the output writers set by
+// WithOutput.
+func contextLogger(ctx context.Context, l Logger) Logger {
+	if _, ok := l.(stdLogger); !ok {
+		return l
+	}
+	if cl, ok := ctx.Value(loggerKey{}).(Logger); ok && cl != nil {
+		return cl
+	}
+	if _, ok := ctx.Value(outputKey{}).(output); !ok {
+		return l
+	}
+
+	return log.New(Stderr(ctx), log.Prefix(), log.Flags())
+}
+
+// LoggerOption is an option setting the Logger of a wrapper. It is accepted by every wrapper of
+// this package which logs.
+type LoggerOption struct {
+	logger Logger
+}
+
+// WithLogger returns an option making a wrapper log to l instead of the standard logger. Without
+// it, a wrapper logs to the Logger of LoggerFromContext: the one set by NewContextWithLogger, or
+// Stderr when executed with the output writers set by WithOutput.
+func WithLogger(l Logger) LoggerOption {
+	return LoggerOption{logger: l}
+}
@@ -0,0 +1,118 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+func TestNewContextWithLogger(t *testing.T) {
+	tests := map[string]struct {
+		// explicit is given to Retry by WithLogger, unless nil
+		explicit     *testcmd.LogRecorder
+		wantContext  []string
+		wantExplicit []string
+	}{
+		"when the wrappers are given no logger": {
+			wantContext: []string{
+				"deploy: running deploy",
+				"deploy: attempt 1 failed with status 1, retrying in 0s",
+				"deploy: attempt 2 failed with status 1, retrying in 0s",
+				"deploy: finished with status 1",
+			},
+		},
+		"when a wrapper is given its own logger": {
+			explicit: &testcmd.LogRecorder{},
+			wantContext: []string{
+				"deploy: running deploy",
+				"deploy: finished with status 1",
+			},
+			wantExplicit: []string{
+				"deploy: attempt 1 failed with status 1, retrying in 0s",
+				"deploy: attempt 2 failed with status 1, retrying in 0s",
+			},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var stderr testcmd.Buffer
+			var logs testcmd.LogRecorder
+			ctx := subcommandsutil.NewContextWithLogger(subcommandsutil.WithOutput(context.Background(), &testcmd.Buffer{}, &stderr), &logs)
+
+			opts := []subcommandsutil.RetryOption{subcommandsutil.WithBackoff(0, 0)}
+			if tt.explicit != nil {
+				opts = append(opts, subcommandsutil.WithLogger(tt.explicit))
+			}
+			sub := testcmd.NewRecording("deploy", testcmd.WithStatus(subcommands.ExitFailure))
+			cmd := subcommandsutil.Logged(subcommandsutil.Retry(sub, opts...))
+
+			status, _, _ := testcmd.Run(ctx, cmd)
+			testcmd.AssertStatus(t, status, subcommands.ExitFailure)
+			if got := trimDurations(logs.Lines()); !reflect.DeepEqual(got, tt.wantContext) {
+				t.Fatalf("wanted the context logger to log %q but got %q", tt.wantContext, got)
+			}
+			if tt.explicit != nil && !reflect.DeepEqual(tt.explicit.Lines(), tt.wantExplicit) {
+				t.Fatalf("wanted the explicit logger to log %q but got %q", tt.wantExplicit, tt.explicit.Lines())
+			}
+			if stderr.String() != "" {
+				t.Fatalf("wanted nothing logged to Stderr but got %q", stderr.String())
+			}
+		})
+	}
+}
+
+// trimDurations removes the measured durations ending the lines logged by Logged.
+func trimDurations(lines []string) []string {
+	trimmed := make([]string, len(lines))
+	for i, line := range lines {
+		if before, _, ok := strings.Cut(line, " in "); ok && strings.Contains(line, ": finished with ") {
+			line = before
+		}
+		trimmed[i] = line
+	}
+
+	return trimmed
+}
+
+func TestLoggerFromContext(t *testing.T) {
+	var stderr testcmd.Buffer
+	ctx := subcommandsutil.WithOutput(context.Background(), &testcmd.Buffer{}, &stderr)
+	subcommandsutil.LoggerFromContext(ctx).Printf("to stderr")
+	if !strings.HasSuffix(stderr.String(), "to stderr\n") {
+		t.Fatalf("wanted the default logger to write to Stderr but got %q", stderr.String())
+	}
+
+	var logs testcmd.LogRecorder
+	subcommandsutil.LoggerFromContext(subcommandsutil.NewContextWithLogger(ctx, &logs)).Printf("to the recorder")
+	if want := []string{"to the recorder"}; !reflect.DeepEqual(logs.Lines(), want) {
+		t.Fatalf("wanted the logger of the context to log %q but got %q", want, logs.Lines())
+	}
+}
+
+func TestRunInstallsLogger(t *testing.T) {
+	defer testcmd.VerifyNoLeaks(t)
+
+	h := testcmd.NewHarness(t)
+	sub := testcmd.NewRecording("deploy", testcmd.WithStatus(subcommands.ExitFailure))
+	h.Register(subcommandsutil.Timeout(subcommandsutil.Retry(sub, subcommandsutil.WithAttempts(2), subcommandsutil.WithBackoff(0, 0)), time.Minute), "")
+	var logs testcmd.LogRecorder
+
+	ctx := subcommandsutil.WithSignalSource(context.Background(), testcmd.NewSignalSource())
+	code := subcommandsutil.Run(subcommandsutil.WithOutput(ctx, h.Stdout, h.Stderr), h.Commander, []string{"deploy"}, subcommandsutil.WithTopFlags(h.Flags), subcommandsutil.WithLogger(&logs))
+	if code != 1 {
+		t.Fatalf("wanted the exit code 1 but got %d", code)
+	}
+	if want := []string{"deploy: attempt 1 failed with status 1, retrying in 0s"}; !reflect.DeepEqual(logs.Lines(), want) {
+		t.Fatalf("wanted the logger of Run to log %q but got %q", want, logs.Lines())
+	}
+}
@@ -0,0 +1,235 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"os"
+	"sync"
+	"syscall"
+
+	"github.com/google/subcommands"
+)
+
+// Executor executes the subcommand named by its parsed top-level flags. *subcommands.Commander and
+// *CancelableCommander implement it.
+type Executor interface {
+	Execute(ctx context.Context, args ...interface{}) subcommands.ExitStatus
+}
+
+// MainOption is an option of Main and Run.
+type MainOption interface {
+	applyMain(*mainConfig)
+}
+
+// mainOptionFunc is a MainOption implemented by a function.
+type mainOptionFunc func(*mainConfig)
+
+// applyMain implements MainOption.
+func (fn mainOptionFunc) applyMain(c *mainConfig) { fn(c) }
+
+// applyMain implements MainOption.
+func (o LoggerOption) applyMain(c *mainConfig) {
+	c.logger = o.logger
+}
+
+// WithCommander makes Main execute cdr, whose top-level flags are topFlags, instead of
+// subcommands.DefaultCommander over flag.CommandLine.
+func WithCommander(cdr Executor, topFlags *flag.FlagSet) MainOption {
+	return mainOptionFunc(func(c *mainConfig) {
+		c.cdr, c.topFlags = cdr, topFlags
+	})
+}
+
+// WithTopFlags sets the top-level flags of the Commander executed by Run, flag.CommandLine by
+// default.
+func WithTopFlags(f *flag.FlagSet) MainOption {
+	return mainOptionFunc(func(c *mainConfig) {
+		c.topFlags = f
+	})
+}
+
+// WithSignals sets the signals canceling the execution context of Main and Run, an interrupt and
+// SIGTERM by default. No signal disables the cancellation.
+func WithSignals(sigs ...os.Signal) MainOption {
+	return mainOptionFunc(func(c *mainConfig) {
+		c.signals = append([]os.Signal{}, sigs...)
+		c.signalsSet = true
+	})
+}
+
+// WithDefaultCommand makes Main and Run execute the command named name when no subcommand is given,
+// like ExecuteWithDefault.
+func WithDefaultCommand(name string) MainOption {
+	return mainOptionFunc(func(c *mainConfig) {
+		c.defaultCommand = name
+	})
+}
+
+// mainConfig is the configuration of Main and Run.
+type mainConfig struct {
+	cdr            Executor
+	topFlags       *flag.FlagSet
+	signals        []os.Signal
+	signalsSet     bool // whether WithSignals set signals, even to none
+	defaultCommand string
+	logger         Logger
+}
+
+// Main runs the program with the arguments of the process, like Run with the background context,
+// and returns its exit code, leaving a single line in main:
+//
+//	func main() {
+//		subcommands.Register(subcommands.HelpCommand(), "")
+//		subcommands.Register(&buildCmd{}, "")
+//		os.Exit(subcommandsutil.Main())
+//	}
+//
+// It executes subcommands.DefaultCommander over flag.CommandLine unless WithCommander is given.
+func Main(opts ...MainOption) int {
+	c := &mainConfig{cdr: subcommands.DefaultCommander}
+	for _, opt := range opts {
+		opt.applyMain(c)
+	}
+
+	return c.run(context.Background(), c.cdr, os.Args[1:])
+}
+
+// Run parses args, the arguments of the program without its name, into the top-level flags of cdr,
+// flag.CommandLine unless WithTopFlags or WithCommander is given, and executes cdr with a context
+// canceled by the termination signals of the SignalSource of ctx, which are logged to the standard
+// logger unless WithLogger is given. The Logger of WithLogger is set on the context by NewContextWithLogger, for the
+// wrappers executed without their own. It then calls the functions registered by OnExitStatus, flushes the logger of
+// WithLogger if it has a Flush or Sync method, and returns the exit code of the program.
+//
+// A failure to parse the top-level flags returns the code of subcommands.ExitUsageError, and -h
+// the one of subcommands.ExitSuccess; the functions of OnExitStatus are called in both cases, also
+// for the top-level flags exiting on error, like flag.CommandLine.
+func Run(ctx context.Context, cdr Executor, args []string, opts ...MainOption) int {
+	c := &mainConfig{}
+	for _, opt := range opts {
+		opt.applyMain(c)
+	}
+
+	return c.run(ctx, cdr, args)
+}
+
+// run implements Run.
+func (c *mainConfig) run(ctx context.Context, cdr Executor, args []string) int {
+	topFlags, signals, logger := c.topFlags, c.signals, c.logger
+	if topFlags == nil {
+		topFlags = flag.CommandLine
+	}
+	if !c.signalsSet {
+		signals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+	}
+	if logger == nil {
+		logger = stdLogger{}
+	}
+
+	status := c.execute(ctx, cdr, topFlags, args, signals, logger)
+	status = runExitHooks(ctx, status)
+	flushLogger(logger)
+
+	return int(status)
+}
+
+// execute parses args into topFlags, and executes cdr with a context canceled by signals.
+func (c *mainConfig) execute(ctx context.Context, cdr Executor, topFlags *flag.FlagSet, args []string, signals []os.Signal, logger Logger) subcommands.ExitStatus {
+	if err := parseTopFlags(topFlags, args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return subcommands.ExitSuccess
+		}
+		return subcommands.ExitUsageError
+	}
+	if c.defaultCommand != "" {
+		if err := defaultCommand(topFlags, c.defaultCommand); err != nil {
+			return subcommands.ExitUsageError
+		}
+	}
+
+	// the Logger of WithLogger is the one of every wrapper not given its own
+	if c.logger != nil {
+		ctx = NewContextWithLogger(ctx, c.logger)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	ctx, stopping := withStopping(ctx)
+	if len(signals) > 0 {
+		sigc := make(chan os.Signal, 1)
+		src := SignalSourceFromContext(ctx)
+		src.Notify(sigc, signals...)
+		defer src.Stop(sigc)
+
+		var wg sync.WaitGroup
+		defer wg.Wait()
+		done := make(chan struct{})
+		defer close(done)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			select {
+			case <-done:
+			case sig := <-sigc:
+				contextLogger(ctx, logger).Printf("%s: received %v, canceling", topFlags.Name(), sig)
+				stopping.stop()
+				cancel()
+			}
+		}()
+	}
+
+	return cdr.Execute(withDispatchArgs(ctx, topFlags))
+}
+
+// parseTopFlags parses args into topFlags. The FlagSets exiting on error, like flag.CommandLine,
+// are parsed through a copy continuing on error, so that the error is returned rather than exiting
+// before the functions of OnExitStatus are called.
+func parseTopFlags(topFlags *flag.FlagSet, args []string) error {
+	if topFlags.ErrorHandling() != flag.ExitOnError {
+		return topFlags.Parse(args)
+	}
+
+	// the copy shares the values of topFlags, which the parse sets
+	parsed := flag.NewFlagSet(topFlags.Name(), flag.ContinueOnError)
+	parsed.SetOutput(topFlags.Output())
+	parsed.Usage = topFlags.Usage
+	topFlags.VisitAll(func(fl *flag.Flag) {
+		parsed.Var(fl.Value, fl.Name, fl.Usage)
+	})
+	if err := parsed.Parse(args); err != nil {
+		return err
+	}
+
+	// the flags of the copy are marked set in topFlags without setting their values again
+	parsed.Visit(func(fl *flag.Flag) {
+		orig := topFlags.Lookup(fl.Name)
+		value := orig.Value
+		orig.Value = setValue{value}
+		topFlags.Set(fl.Name, "")
+		orig.Value = value
+	})
+
+	return topFlags.Parse(append([]string{"--"}, parsed.Args()...))
+}
+
+// setValue is a flag.Value already set, whose Set does nothing.
+type setValue struct {
+	flag.Value
+}
+
+// Set implements flag.Value.
+func (setValue) Set(string) error { return nil }
+
+// flushLogger flushes l if it has a Flush or Sync method, like a buffered logger.
+func flushLogger(l Logger) {
+	switch l := l.(type) {
+	case interface{ Flush() error }:
+		l.Flush()
+	case interface{ Sync() error }:
+		l.Sync()
+	}
+}
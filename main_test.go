@@ -0,0 +1,209 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+// flushingLogger is a LogRecorder counting its flushes.
+type flushingLogger struct {
+	testcmd.LogRecorder
+	flushes int
+}
+
+// Flush records a flush.
+func (l *flushingLogger) Flush() error {
+	l.flushes++
+	return nil
+}
+
+func TestRun(t *testing.T) {
+	tests := map[string]struct {
+		args     []string
+		opts     []subcommandsutil.MainOption
+		status   subcommands.ExitStatus
+		wantCode int
+		wantRuns int
+	}{
+		"when the command succeeds": {
+			args:     []string{"build"},
+			wantRuns: 1,
+		},
+		"when the command fails": {
+			args:     []string{"build"},
+			status:   subcommands.ExitFailure,
+			wantCode: 1,
+			wantRuns: 1,
+		},
+		"when the top-level flags are invalid": {
+			args:     []string{"-unknown", "build"},
+			wantCode: 2,
+		},
+		"when the default command is executed": {
+			opts:     []subcommandsutil.MainOption{subcommandsutil.WithDefaultCommand("build")},
+			wantRuns: 1,
+		},
+		"when no command is given": {
+			wantCode: 2,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			defer testcmd.VerifyNoLeaks(t)
+			defer subcommandsutil.SetExit(func(code int) { t.Fatalf("wanted Run not to exit but got %d", code) }, &testcmd.LogRecorder{})()
+
+			var exited []string
+			subcommandsutil.OnExitStatus(func(status subcommands.ExitStatus) {
+				exited = append(exited, fmt.Sprint(int(status)))
+			})
+			h := testcmd.NewHarness(t)
+			sub := testcmd.NewRecording("build", testcmd.WithExecute(func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+				return tt.status
+			}))
+			h.Register(sub, "")
+			logger := &flushingLogger{}
+			opts := append([]subcommandsutil.MainOption{subcommandsutil.WithTopFlags(h.Flags), subcommandsutil.WithLogger(logger)}, tt.opts...)
+
+			ctx := subcommandsutil.WithSignalSource(context.Background(), testcmd.NewSignalSource())
+			code := subcommandsutil.Run(subcommandsutil.WithOutput(ctx, h.Stdout, h.Stderr), h.Commander, tt.args, opts...)
+			if code != tt.wantCode {
+				t.Fatalf("wanted the exit code %d but got %d: %s", tt.wantCode, code, h.Stderr)
+			}
+			if got := sub.CallCount(); got != tt.wantRuns {
+				t.Fatalf("wanted %d runs but got %d", tt.wantRuns, got)
+			}
+			if want := []string{fmt.Sprint(tt.wantCode)}; !reflect.DeepEqual(exited, want) {
+				t.Fatalf("wanted the exit hooks called with %q but got %q", want, exited)
+			}
+			if logger.flushes != 1 {
+				t.Fatalf("wanted the logger flushed once but got %d flushes", logger.flushes)
+			}
+		})
+	}
+}
+
+func TestRunCanceled(t *testing.T) {
+	defer testcmd.VerifyNoLeaks(t)
+	defer subcommandsutil.SetExit(func(code int) { t.Fatalf("wanted Run not to exit but got %d", code) }, &testcmd.LogRecorder{})()
+	src := testcmd.NewSignalSource()
+
+	h := testcmd.NewHarness(t)
+	sub := testcmd.NewRecording("serve", testcmd.WithExecute(func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+		src.Send(os.Interrupt)
+		<-ctx.Done()
+		return subcommands.ExitFailure
+	}))
+	h.Register(sub, "")
+	var logs testcmd.LogRecorder
+
+	code := subcommandsutil.Run(subcommandsutil.WithSignalSource(context.Background(), src), h.Commander, []string{"serve"}, subcommandsutil.WithTopFlags(h.Flags), subcommandsutil.WithLogger(&logs))
+	if code != 1 {
+		t.Fatalf("wanted the exit code 1 but got %d", code)
+	}
+	if !logs.Contains("received interrupt, canceling") {
+		t.Fatalf("wanted the signal logged but got %q", logs.Lines())
+	}
+}
+
+func TestRunWithoutSignals(t *testing.T) {
+	defer testcmd.VerifyNoLeaks(t)
+	defer subcommandsutil.SetExit(func(code int) { t.Fatalf("wanted Run not to exit but got %d", code) }, &testcmd.LogRecorder{})()
+	src := testcmd.NewSignalSource()
+
+	h := testcmd.NewHarness(t)
+	sub := testcmd.NewRecording("serve", testcmd.WithExecute(func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+		if n := src.Listeners(); n != 0 {
+			t.Errorf("wanted no signal listened to but got %d listeners", n)
+		}
+		return subcommands.ExitSuccess
+	}))
+	h.Register(sub, "")
+
+	code := subcommandsutil.Run(subcommandsutil.WithSignalSource(context.Background(), src), h.Commander, []string{"serve"}, subcommandsutil.WithTopFlags(h.Flags), subcommandsutil.WithSignals())
+	if code != 0 {
+		t.Fatalf("wanted the exit code 0 but got %d", code)
+	}
+}
+
+func TestRunExitOnError(t *testing.T) {
+	tests := map[string]struct {
+		args        []string
+		wantCode    int
+		wantVerbose bool
+		wantRuns    int
+	}{
+		"when the top-level flags are invalid": {
+			args:     []string{"-unknown", "build"},
+			wantCode: 2,
+		},
+		"when -h is given": {
+			args: []string{"-h"},
+		},
+		"when the top-level flags are valid": {
+			args:        []string{"-v", "build"},
+			wantVerbose: true,
+			wantRuns:    1,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			defer subcommandsutil.SetExit(func(code int) { t.Fatalf("wanted Run not to exit but got %d", code) }, &testcmd.LogRecorder{})()
+
+			var exited []string
+			subcommandsutil.OnExitStatus(func(status subcommands.ExitStatus) {
+				exited = append(exited, fmt.Sprint(int(status)))
+			})
+			top := flag.NewFlagSet("tool", flag.ExitOnError)
+			top.SetOutput(&testcmd.Buffer{})
+			verbose := top.Bool("v", false, "verbose output")
+			cdr := subcommands.NewCommander(top, "tool")
+			sub := testcmd.NewRecording("build")
+			cdr.Register(sub, "")
+
+			code := subcommandsutil.Run(context.Background(), cdr, tt.args, subcommandsutil.WithTopFlags(top), subcommandsutil.WithSignals())
+			if code != tt.wantCode {
+				t.Fatalf("wanted the exit code %d but got %d", tt.wantCode, code)
+			}
+			if want := []string{fmt.Sprint(tt.wantCode)}; !reflect.DeepEqual(exited, want) {
+				t.Fatalf("wanted the exit hooks called with %q but got %q", want, exited)
+			}
+			if *verbose != tt.wantVerbose || subcommandsutil.IsFlagSet(top, "v") != tt.wantVerbose {
+				t.Fatalf("wanted -v set to be %t but got %t (%t)", tt.wantVerbose, *verbose, subcommandsutil.IsFlagSet(top, "v"))
+			}
+			if got := sub.CallCount(); got != tt.wantRuns {
+				t.Fatalf("wanted %d runs but got %d", tt.wantRuns, got)
+			}
+		})
+	}
+}
+
+func TestRunExitHookFailed(t *testing.T) {
+	defer subcommandsutil.SetExit(func(code int) { t.Fatalf("wanted Run not to exit but got %d", code) }, &testcmd.LogRecorder{})()
+
+	subcommandsutil.OnExitStatus(func(status subcommands.ExitStatus) {
+		panic("boom")
+	}, subcommandsutil.WithHookErrorPolicy(subcommandsutil.FailCommand))
+	h := testcmd.NewHarness(t)
+	h.Register(testcmd.NewRecording("build"), "")
+
+	var failures []string
+	ctx := subcommandsutil.WithEvents(context.Background(), hookFailures(&failures))
+	if code := subcommandsutil.Run(ctx, h.Commander, []string{"build"}, subcommandsutil.WithTopFlags(h.Flags), subcommandsutil.WithSignals()); code != 1 {
+		t.Fatalf("wanted the exit code 1 but got %d", code)
+	}
+	if want := []string{"exit hook: panic: boom (FailCommand)"}; !reflect.DeepEqual(failures, want) {
+		t.Fatalf("wanted the HookFailed events %q but got %q", want, failures)
+	}
+}
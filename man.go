@@ -0,0 +1,305 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/subcommands"
+)
+
+// ManMeta is the metadata of the man pages rendered by ManCommand.
+type ManMeta struct {
+	// Tool is the name of the tool, naming the top-level page and prefixing the pages of the
+	// commands, like "tool-push". The default is the name of the Commander.
+	Tool string
+
+	// Description is the one-line description of the tool in the NAME section of the top-level page.
+	Description string
+
+	// Section is the section of the manual, "1" if empty.
+	Section string
+
+	// Date is the date of the pages. The default is the current time of the Clock of the execution
+	// context.
+	Date time.Time
+
+	// Source is the source of the pages, usually the tool and its version, like "tool 1.2.0".
+	Source string
+
+	// Manual is the title of the manual, like "Tool Manual".
+	Manual string
+}
+
+// ManOption is an option of the ManCommand command.
+type ManOption interface {
+	applyMan(*man)
+}
+
+// manOptionFunc is a ManOption implemented by a function.
+type manOptionFunc func(*man)
+
+// applyMan implements ManOption.
+func (fn manOptionFunc) applyMan(c *man) { fn(c) }
+
+// WithManVisible makes the ManCommand command visible in the command listing.
+func WithManVisible() ManOption {
+	return manOptionFunc(func(c *man) {
+		c.visible = true
+	})
+}
+
+// man is the command writing the man pages of the commands of a Commander.
+type man struct {
+	cdr     *subcommands.Commander
+	meta    ManMeta
+	visible bool
+
+	dir string
+}
+
+// make sure man implements the subcommands.Command interface.
+var _ subcommands.Command = (*man)(nil)
+
+// ManCommand returns a command named "man" writing roff man pages for the commands registered in
+// cdr to the directory given by its -dir flag: a top-level page listing the visible commands with
+// their Synopsis, and a page of each visible command with its Usage, ArgsSpec, visible flags and
+// their aliases, and the examples of an Exampler. The pages are named after the Tool and the
+// Section of meta, like "tool.1" and "tool-push.1".
+//
+// The command is hidden from the command listing unless WithManVisible is given.
+func ManCommand(cdr *subcommands.Commander, meta ManMeta, opts ...ManOption) subcommands.Command {
+	if meta.Tool == "" {
+		meta.Tool = cdr.Name()
+	}
+	if meta.Section == "" {
+		meta.Section = "1"
+	}
+
+	c := &man{
+		cdr:  cdr,
+		meta: meta,
+	}
+	for _, opt := range opts {
+		opt.applyMan(c)
+	}
+
+	return c
+}
+
+// Name implements subcommands.Command.
+func (c *man) Name() string {
+	return "man"
+}
+
+// Synopsis implements subcommands.Command.
+func (c *man) Synopsis() string {
+	return "write the man pages"
+}
+
+// Usage implements subcommands.Command.
+func (c *man) Usage() string {
+	return "man [-dir DIR]:\n  Write the man pages of the commands to DIR.\n"
+}
+
+// Hidden reports whether the command is hidden.
+func (c *man) Hidden() bool {
+	return !c.visible
+}
+
+// SetFlags implements subcommands.Command.
+func (c *man) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&c.dir, "dir", ".", "the `directory` to write the man pages to")
+}
+
+// Execute implements subcommands.Command.
+func (c *man) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	if f.NArg() != 0 {
+		fmt.Fprintf(Stderr(ctx), "%s: unexpected arguments %q\n", c.Name(), f.Args())
+		return subcommands.ExitUsageError
+	}
+
+	meta := c.meta
+	if meta.Date.IsZero() {
+		meta.Date = ClockFromContext(ctx).Now()
+	}
+
+	if err := os.MkdirAll(c.dir, 0o755); err != nil {
+		fmt.Fprintf(Stderr(ctx), "%s: %v\n", c.Name(), err)
+		return subcommands.ExitFailure
+	}
+
+	cmds := visibleCommands(c.cdr)
+	pages := map[string]func(w io.Writer){
+		meta.Tool: func(w io.Writer) { writeManIndex(w, meta, cmds) },
+	}
+	for _, cmd := range cmds {
+		cmd := cmd
+		pages[manPageName(meta, cmd)] = func(w io.Writer) { writeManPage(w, meta, cmd) }
+	}
+
+	for name, write := range pages {
+		var buf bytes.Buffer
+		write(&buf)
+		if err := os.WriteFile(filepath.Join(c.dir, name+"."+meta.Section), buf.Bytes(), 0o644); err != nil {
+			fmt.Fprintf(Stderr(ctx), "%s: %v\n", c.Name(), err)
+			return subcommands.ExitFailure
+		}
+	}
+	fmt.Fprintf(Info(ctx), "%s: wrote %d pages to %s\n", c.Name(), len(pages), c.dir)
+
+	return subcommands.ExitSuccess
+}
+
+// visibleCommands returns the visible commands registered in cdr, sorted by name.
+func visibleCommands(cdr *subcommands.Commander) []subcommands.Command {
+	var cmds []subcommands.Command
+	cdr.VisitCommands(func(_ *subcommands.CommandGroup, cmd subcommands.Command) {
+		if !IsHiddenCommand(cmd) {
+			cmds = append(cmds, cmd)
+		}
+	})
+	sort.Slice(cmds, func(i, j int) bool {
+		return cmds[i].Name() < cmds[j].Name()
+	})
+
+	return cmds
+}
+
+// manPageName returns the name of the man page of cmd, without the section.
+func manPageName(meta ManMeta, cmd subcommands.Command) string {
+	return meta.Tool + "-" + cmd.Name()
+}
+
+// writeManHeader writes the title line of the man page named name to w.
+func writeManHeader(w io.Writer, meta ManMeta, name string) {
+	fmt.Fprintf(w, ".TH %s %s %s %s %s\n", roffQuote(strings.ToUpper(name)), roffQuote(meta.Section), roffQuote(meta.Date.Format("2006-01-02")), roffQuote(meta.Source), roffQuote(meta.Manual))
+}
+
+// writeManIndex writes the top-level man page of the tool of meta, listing cmds, to w.
+func writeManIndex(w io.Writer, meta ManMeta, cmds []subcommands.Command) {
+	writeManHeader(w, meta, meta.Tool)
+
+	fmt.Fprintf(w, ".SH NAME\n%s", roffEscape(meta.Tool))
+	if meta.Description != "" {
+		fmt.Fprintf(w, " \\- %s", roffEscape(meta.Description))
+	}
+	fmt.Fprintf(w, "\n.SH SYNOPSIS\n.B %s\n[\\fIflags\\fR] \\fIcommand\\fR [\\fIargs\\fR]\n", roffEscape(meta.Tool))
+
+	fmt.Fprintf(w, ".SH COMMANDS\n")
+	for _, cmd := range cmds {
+		fmt.Fprintf(w, ".TP\n\\fB%s\\fR\n%s\n", roffEscape(cmd.Name()), roffText(cmd.Synopsis()))
+	}
+
+	refs := make([]string, 0, len(cmds))
+	for _, cmd := range cmds {
+		refs = append(refs, fmt.Sprintf("\\fB%s\\fR(%s)", roffEscape(manPageName(meta, cmd)), meta.Section))
+	}
+	if len(refs) > 0 {
+		fmt.Fprintf(w, ".SH SEE ALSO\n%s\n", strings.Join(refs, ",\n"))
+	}
+}
+
+// writeManPage writes the man page of cmd to w.
+func writeManPage(w io.Writer, meta ManMeta, cmd subcommands.Command) {
+	d := NewUsageData(cmd)
+	writeManHeader(w, meta, manPageName(meta, cmd))
+
+	fmt.Fprintf(w, ".SH NAME\n%s", roffEscape(manPageName(meta, cmd)))
+	if d.Synopsis != "" {
+		fmt.Fprintf(w, " \\- %s", roffEscape(d.Synopsis))
+	}
+	fmt.Fprintf(w, "\n.SH SYNOPSIS\n.B %s %s\n[\\fIflags\\fR]", roffEscape(meta.Tool), roffEscape(d.Name))
+	if d.Args != "" {
+		fmt.Fprintf(w, " \\fI%s\\fR", roffEscape(d.Args))
+	}
+	fmt.Fprintf(w, "\n")
+
+	usage, paras := strings.TrimRight(d.Usage, "\n"), paragraphs(d.Details)
+	if usage != "" || len(paras) > 0 {
+		fmt.Fprintf(w, ".SH DESCRIPTION\n")
+	}
+	if usage != "" {
+		fmt.Fprintf(w, ".nf\n%s\n.fi\n", roffText(usage))
+	}
+	for _, p := range paras {
+		fmt.Fprintf(w, ".PP\n%s\n", roffText(p))
+	}
+
+	if len(d.FlagGroups) > 0 {
+		fmt.Fprintf(w, ".SH OPTIONS\n")
+	}
+	for _, g := range d.FlagGroups {
+		if g.Title != "" {
+			fmt.Fprintf(w, ".SS %s\n", roffQuote(g.Title))
+		}
+		for _, fl := range g.Flags {
+			writeManFlag(w, fl)
+		}
+	}
+
+	if len(d.Examples) > 0 {
+		fmt.Fprintf(w, ".SH EXAMPLES\n")
+	}
+	for _, e := range d.Examples {
+		fmt.Fprintf(w, ".PP\n%s\n.PP\n.RS 4\n.nf\n%s\n.fi\n.RE\n", roffText(e.Description), roffText(e.Command))
+	}
+
+	fmt.Fprintf(w, ".SH SEE ALSO\n\\fB%s\\fR(%s)\n", roffEscape(meta.Tool), meta.Section)
+}
+
+// writeManFlag writes the paragraph of fl to w.
+func writeManFlag(w io.Writer, fl FlagData) {
+	names := make([]string, 0, 1+len(fl.Aliases))
+	for _, name := range append([]string{fl.Name}, fl.Aliases...) {
+		names = append(names, "\\fB\\-"+roffEscape(name)+"\\fR")
+	}
+	fmt.Fprintf(w, ".TP\n%s", strings.Join(names, ", "))
+	if fl.ValueName != "" {
+		fmt.Fprintf(w, " \\fI%s\\fR", roffEscape(fl.ValueName))
+	}
+	fmt.Fprintf(w, "\n%s", roffText(fl.Usage))
+	if fl.Default != "" {
+		fmt.Fprintf(w, " (default %s)", roffEscape(fl.Default))
+	}
+	if fl.Sensitive {
+		fmt.Fprintf(w, " (sensitive)")
+	}
+	fmt.Fprintf(w, "\n")
+}
+
+// roffEscaper escapes the characters of text having a meaning in roff.
+var roffEscaper = strings.NewReplacer(`\`, `\e`, "-", `\-`)
+
+// roffEscape escapes s for use in a roff line.
+func roffEscape(s string) string {
+	return roffEscaper.Replace(s)
+}
+
+// roffQuote escapes and quotes s for use as an argument of a roff request.
+func roffQuote(s string) string {
+	return `"` + strings.ReplaceAll(roffEscape(s), `"`, `\(dq`) + `"`
+}
+
+// roffText escapes the lines of s for use as roff text, guarding the lines which would otherwise be
+// taken for requests.
+func roffText(s string) string {
+	lines := strings.Split(roffEscape(s), "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(line, ".") || strings.HasPrefix(line, "'") {
+			lines[i] = `\&` + line
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,66 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"context"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+func TestManCommand(t *testing.T) {
+	top := flag.NewFlagSet("tool", flag.ContinueOnError)
+	cdr := subcommands.NewCommander(top, "tool")
+	cdr.Register(newCopyCommand(), "")
+	cdr.Register(testcmd.NewRecording("ls", testcmd.WithSynopsis("list files")), "")
+	cdr.Register(subcommandsutil.Hidden(testcmd.NewRecording("debug")), "")
+	man := subcommandsutil.ManCommand(cdr, subcommandsutil.ManMeta{
+		Description: "manage files",
+		Date:        time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC),
+		Source:      "tool 1.2.0",
+		Manual:      "Tool Manual",
+	})
+	cdr.Register(man, "")
+
+	dir := t.TempDir()
+	if err := top.Parse([]string{"man", "-dir", dir}); err != nil {
+		t.Fatal(err)
+	}
+	var stdout testcmd.Buffer
+	testcmd.RequireSuccess(t, cdr.Execute(subcommandsutil.WithOutput(context.Background(), &stdout, &stdout)))
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	sort.Strings(names)
+	if want := []string{"tool-cp.1", "tool-ls.1", "tool.1"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("wanted the pages %q but got %q", want, names)
+	}
+	if !subcommandsutil.IsHiddenCommand(man) {
+		t.Fatal("wanted the man command to be hidden")
+	}
+
+	for _, name := range []string{"tool.1", "tool-cp.1"} {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		testcmd.Golden(t, string(data), filepath.Join("testdata", "man_"+name+".golden"))
+	}
+}
@@ -0,0 +1,205 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"flag"
+	"io/fs"
+	"math"
+	"path"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/google/subcommands"
+)
+
+// MaxProcsOption is an option of the MaxProcs wrapper.
+type MaxProcsOption interface {
+	applyMaxProcs(*maxProcs)
+}
+
+// applyMaxProcs implements MaxProcsOption.
+func (o LoggerOption) applyMaxProcs(c *maxProcs) {
+	c.logger = o.logger
+}
+
+// maxProcs wraps a subcommands.Command so that GOMAXPROCS matches the CPU quota of the process.
+type maxProcs struct {
+	sub    subcommands.Command
+	logger Logger
+
+	n int
+}
+
+// make sure maxProcs implements the subcommands.Command interface.
+var _ subcommands.Command = (*maxProcs)(nil)
+
+// MaxProcs wraps sub so that it executes with GOMAXPROCS set to the CPU quota of the cgroup of the
+// process, rounded down and at least 1, and restored afterward. The -maxprocs flag registered by
+// the wrapper overrides the detection. Without a -maxprocs flag or a CPU quota, as outside of
+// Linux, GOMAXPROCS is left unchanged.
+//
+// The decision is logged to the Logger set by WithLogger, if any: the wrapper is silent by
+// default.
+func MaxProcs(sub subcommands.Command, opts ...MaxProcsOption) subcommands.Command {
+	c := &maxProcs{
+		sub: sub,
+	}
+	for _, opt := range opts {
+		opt.applyMaxProcs(c)
+	}
+
+	return c
+}
+
+// Name forwards to the underlying c.sub Command.
+func (c *maxProcs) Name() string {
+	return c.sub.Name()
+}
+
+// Usage forwards to the underlying c.sub Command.
+func (c *maxProcs) Usage() string {
+	return c.sub.Usage()
+}
+
+// Synopsis forwards to the underlying c.sub Command.
+func (c *maxProcs) Synopsis() string {
+	return c.sub.Synopsis()
+}
+
+// Unwrap returns the underlying c.sub Command.
+func (c *maxProcs) Unwrap() subcommands.Command {
+	return c.sub
+}
+
+// SetFlags forwards to the underlying c.sub Command and registers the -maxprocs flag.
+func (c *maxProcs) SetFlags(f *flag.FlagSet) {
+	c.sub.SetFlags(f)
+	f.IntVar(&c.n, "maxprocs", 0, "set GOMAXPROCS to `n` instead of the CPU quota")
+}
+
+// Execute sets GOMAXPROCS and forwards to the underlying c.sub Command.
+func (c *maxProcs) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	n, reason := c.n, "-maxprocs"
+	if n <= 0 {
+		quota, ok := cpuQuota(cgroupFS)
+		if !ok {
+			c.logf(ctx, "%s: no CPU quota, GOMAXPROCS=%d", c.sub.Name(), runtime.GOMAXPROCS(0))
+			return c.sub.Execute(ctx, f, args...)
+		}
+		n = int(math.Max(1, math.Floor(quota)))
+		reason = "CPU quota " + strconv.FormatFloat(quota, 'f', -1, 64)
+	}
+
+	prev := runtime.GOMAXPROCS(n)
+	defer runtime.GOMAXPROCS(prev)
+	c.logf(ctx, "%s: GOMAXPROCS=%d (%s)", c.sub.Name(), n, reason)
+
+	return c.sub.Execute(ctx, f, args...)
+}
+
+// logf logs to the logger of c, if any.
+func (c *maxProcs) logf(ctx context.Context, format string, v ...interface{}) {
+	if c.logger != nil {
+		contextLogger(ctx, c.logger).Printf(format, v...)
+	}
+}
+
+// cpuQuota returns the CPU quota of the cgroup of the process, in CPUs, read from fsys, the root of
+// the file system, with the cgroup v2 or v1 interface. It reports false if there is no quota.
+func cpuQuota(fsys fs.FS) (float64, bool) {
+	if fsys == nil {
+		return 0, false
+	}
+	data, err := fs.ReadFile(fsys, "proc/self/cgroup")
+	if err != nil {
+		return 0, false
+	}
+
+	// the lines are "ID:CONTROLLERS:PATH", with the ID 0 and no controllers on cgroup v2
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		fields := strings.SplitN(sc.Text(), ":", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		switch controllers := fields[1]; {
+		case fields[0] == "0" && controllers == "":
+			if quota, ok := cgroupV2Quota(fsys, fields[2]); ok {
+				return quota, true
+			}
+		case containsString(strings.Split(controllers, ","), "cpu"):
+			if quota, ok := cgroupV1Quota(fsys, controllers, fields[2]); ok {
+				return quota, true
+			}
+		}
+	}
+
+	return 0, false
+}
+
+// cgroupDirs returns the candidate directories of the cgroup at p under the mount point dir: its
+// directory, or the mount point itself within a container whose cgroup namespace hides its path.
+func cgroupDirs(dir, p string) []string {
+	return []string{path.Join(dir, p), dir}
+}
+
+// cgroupV2Quota returns the CPU quota of the cgroup v2 at p, from its cpu.max file: "max PERIOD"
+// without a quota, or "QUOTA PERIOD".
+func cgroupV2Quota(fsys fs.FS, p string) (float64, bool) {
+	for _, dir := range cgroupDirs("sys/fs/cgroup", p) {
+		data, err := fs.ReadFile(fsys, path.Join(dir, "cpu.max"))
+		if err != nil {
+			continue
+		}
+		fields := strings.Fields(string(data))
+		if len(fields) != 2 || fields[0] == "max" {
+			return 0, false
+		}
+
+		return quotaRatio(fields[0], fields[1])
+	}
+
+	return 0, false
+}
+
+// cgroupV1Quota returns the CPU quota of the cgroup v1 at p of the hierarchy of controllers, from
+// its cpu.cfs_quota_us file, -1 without a quota, and cpu.cfs_period_us file.
+func cgroupV1Quota(fsys fs.FS, controllers, p string) (float64, bool) {
+	for _, mount := range []string{controllers, "cpu"} {
+		for _, dir := range cgroupDirs(path.Join("sys/fs/cgroup", mount), p) {
+			quota, err := fs.ReadFile(fsys, path.Join(dir, "cpu.cfs_quota_us"))
+			if err != nil {
+				continue
+			}
+			period, err := fs.ReadFile(fsys, path.Join(dir, "cpu.cfs_period_us"))
+			if err != nil {
+				return 0, false
+			}
+
+			return quotaRatio(strings.TrimSpace(string(quota)), strings.TrimSpace(string(period)))
+		}
+	}
+
+	return 0, false
+}
+
+// quotaRatio returns quota over period, both in microseconds. It reports false unless both are
+// positive.
+func quotaRatio(quota, period string) (float64, bool) {
+	q, err := strconv.ParseInt(quota, 10, 64)
+	if err != nil || q <= 0 {
+		return 0, false
+	}
+	p, err := strconv.ParseInt(period, 10, 64)
+	if err != nil || p <= 0 {
+		return 0, false
+	}
+
+	return float64(q) / float64(p), true
+}
@@ -0,0 +1,12 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"io/fs"
+	"os"
+)
+
+// cgroupFS is the root of the file system the CPU quota of MaxProcs is read from.
+var cgroupFS fs.FS = os.DirFS("/")
@@ -0,0 +1,12 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+//go:build !linux
+
+package subcommandsutil
+
+import "io/fs"
+
+// cgroupFS is nil, as there are no cgroups to read the CPU quota of MaxProcs from on this
+// platform.
+var cgroupFS fs.FS
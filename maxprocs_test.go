@@ -0,0 +1,132 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"context"
+	"flag"
+	"runtime"
+	"testing"
+	"testing/fstest"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+// cgroupFiles returns a file system of the files with their contents.
+func cgroupFiles(files map[string]string) fstest.MapFS {
+	fsys := make(fstest.MapFS, len(files))
+	for name, data := range files {
+		fsys[name] = &fstest.MapFile{Data: []byte(data)}
+	}
+
+	return fsys
+}
+
+func TestCPUQuota(t *testing.T) {
+	tests := map[string]struct {
+		files     map[string]string
+		wantQuota float64
+		wantOK    bool
+	}{
+		"when cgroup v2 sets a quota": {
+			files: map[string]string{
+				"proc/self/cgroup":         "0::/\n",
+				"sys/fs/cgroup/cpu.max":    "250000 100000\n",
+				"sys/fs/cgroup/cpu.weight": "100\n",
+			},
+			wantQuota: 2.5,
+			wantOK:    true,
+		},
+		"when cgroup v2 sets a quota on a nested cgroup": {
+			files: map[string]string{
+				"proc/self/cgroup": "0::/system.slice/tool.service\n",
+				"sys/fs/cgroup/system.slice/tool.service/cpu.max": "50000 100000\n",
+			},
+			wantQuota: 0.5,
+			wantOK:    true,
+		},
+		"when cgroup v2 sets no quota": {
+			files: map[string]string{
+				"proc/self/cgroup":      "0::/\n",
+				"sys/fs/cgroup/cpu.max": "max 100000\n",
+			},
+		},
+		"when cgroup v1 sets a quota": {
+			files: map[string]string{
+				"proc/self/cgroup":                            "12:memory:/docker/abc\n4:cpu,cpuacct:/docker/abc\n",
+				"sys/fs/cgroup/cpu,cpuacct/cpu.cfs_quota_us":  "300000\n",
+				"sys/fs/cgroup/cpu,cpuacct/cpu.cfs_period_us": "100000\n",
+			},
+			wantQuota: 3,
+			wantOK:    true,
+		},
+		"when cgroup v1 sets no quota": {
+			files: map[string]string{
+				"proc/self/cgroup":                    "4:cpu,cpuacct:/\n",
+				"sys/fs/cgroup/cpu/cpu.cfs_quota_us":  "-1\n",
+				"sys/fs/cgroup/cpu/cpu.cfs_period_us": "100000\n",
+			},
+		},
+		"when there are no cgroups": {
+			files: map[string]string{},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			quota, ok := subcommandsutil.CPUQuota(cgroupFiles(tt.files))
+			if quota != tt.wantQuota || ok != tt.wantOK {
+				t.Fatalf("wanted the quota %v (%t) but got %v (%t)", tt.wantQuota, tt.wantOK, quota, ok)
+			}
+		})
+	}
+}
+
+func TestMaxProcs(t *testing.T) {
+	defer subcommandsutil.SetCgroupFS(cgroupFiles(map[string]string{
+		"proc/self/cgroup":      "0::/\n",
+		"sys/fs/cgroup/cpu.max": "150000 100000\n",
+	}))()
+	before := runtime.GOMAXPROCS(0)
+
+	tests := map[string]struct {
+		args    []string
+		wantN   int
+		wantLog string
+	}{
+		"when the quota is detected": {
+			wantN:   1,
+			wantLog: "sync: GOMAXPROCS=1 (CPU quota 1.5)",
+		},
+		"when -maxprocs is set": {
+			args:    []string{"-maxprocs", "3"},
+			wantN:   3,
+			wantLog: "sync: GOMAXPROCS=3 (-maxprocs)",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var got int
+			sub := testcmd.NewRecording("sync", testcmd.WithExecute(func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+				got = runtime.GOMAXPROCS(0)
+				return subcommands.ExitSuccess
+			}))
+			logger := &testcmd.LogRecorder{}
+
+			status, _, _ := testcmd.Run(context.Background(), subcommandsutil.MaxProcs(sub, subcommandsutil.WithLogger(logger)), tt.args...)
+			testcmd.RequireSuccess(t, status)
+			if got != tt.wantN {
+				t.Fatalf("wanted GOMAXPROCS=%d but got %d", tt.wantN, got)
+			}
+			if after := runtime.GOMAXPROCS(0); after != before {
+				t.Fatalf("wanted GOMAXPROCS restored to %d but got %d", before, after)
+			}
+			if !logger.Contains(tt.wantLog) {
+				t.Fatalf("wanted the log %q but got %q", tt.wantLog, logger.Lines())
+			}
+		})
+	}
+}
@@ -0,0 +1,244 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"math"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/subcommands"
+)
+
+// sizeUnits are the multipliers of the units of ParseSize.
+var sizeUnits = map[string]float64{
+	"":    1,
+	"b":   1,
+	"k":   1e3,
+	"kb":  1e3,
+	"kib": 1 << 10,
+	"m":   1e6,
+	"mb":  1e6,
+	"mib": 1 << 20,
+	"g":   1e9,
+	"gb":  1e9,
+	"gib": 1 << 30,
+	"t":   1e12,
+	"tb":  1e12,
+	"tib": 1 << 40,
+}
+
+// ParseSize parses a size in bytes, like "1024", "512MiB" or "1.5GB": a decimal number followed
+// by an optional unit, B, the SI units kB, MB, GB and TB, or the IEC units KiB, MiB, GiB and TiB,
+// case insensitively.
+func ParseSize(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	i := strings.IndexFunc(s, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+	if i < 0 {
+		i = len(s)
+	}
+	num, unit := s[:i], strings.ToLower(strings.TrimSpace(s[i:]))
+
+	mul, ok := sizeUnits[unit]
+	if !ok {
+		return 0, fmt.Errorf("invalid size %q: unknown unit %q", s, s[i:])
+	}
+	n, err := strconv.ParseFloat(num, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	if n*mul >= math.MaxInt64 {
+		return 0, fmt.Errorf("invalid size %q: out of range", s)
+	}
+
+	return int64(n * mul), nil
+}
+
+// FormatSize formats the size n in bytes with the largest IEC unit keeping it at least 1, rounded
+// to a decimal, like "512MiB" or "1.5GiB".
+func FormatSize(n int64) string {
+	units := []string{"TiB", "GiB", "MiB", "KiB"}
+	for i, unit := range units {
+		size := float64(int64(1) << (10 * (len(units) - i)))
+		if float64(n) >= size {
+			return strconv.FormatFloat(math.Round(float64(n)/size*10)/10, 'f', -1, 64) + unit
+		}
+	}
+
+	return strconv.FormatInt(n, 10) + "B"
+}
+
+// sizeValue is a flag.Value of a size in bytes parsed by ParseSize.
+type sizeValue int64
+
+// String implements flag.Value.
+func (v *sizeValue) String() string {
+	if *v == 0 {
+		return "0"
+	}
+	return FormatSize(int64(*v))
+}
+
+// Set implements flag.Value.
+func (v *sizeValue) Set(s string) error {
+	n, err := ParseSize(s)
+	if err != nil {
+		return err
+	}
+	*v = sizeValue(n)
+	return nil
+}
+
+// MemoryLimitOption is an option of the MemoryLimit wrapper.
+type MemoryLimitOption interface {
+	applyMemoryLimit(*memoryLimit)
+}
+
+// memoryLimitOptionFunc is a MemoryLimitOption implemented by a function.
+type memoryLimitOptionFunc func(*memoryLimit)
+
+// applyMemoryLimit implements MemoryLimitOption.
+func (fn memoryLimitOptionFunc) applyMemoryLimit(c *memoryLimit) { fn(c) }
+
+// applyMemoryLimit implements MemoryLimitOption.
+func (o LoggerOption) applyMemoryLimit(c *memoryLimit) {
+	c.logger = o.logger
+}
+
+// WithMemoryMonitor makes MemoryLimit sample the heap every interval, measured on the Clock of the
+// execution context, while the command runs with a memory limit. When the heap exceeds fraction of
+// the limit, fn is called with the size of the heap, or a warning logged if fn is nil, once until
+// the heap shrinks below it again.
+func WithMemoryMonitor(interval time.Duration, fraction float64, fn func(ctx context.Context, heap uint64)) MemoryLimitOption {
+	return memoryLimitOptionFunc(func(c *memoryLimit) {
+		c.interval = interval
+		c.fraction = fraction
+		c.onExceeded = fn
+	})
+}
+
+// memoryLimit wraps a subcommands.Command so that it executes with a soft memory limit.
+type memoryLimit struct {
+	sub    subcommands.Command
+	logger Logger
+
+	interval   time.Duration
+	fraction   float64
+	onExceeded func(ctx context.Context, heap uint64)
+
+	limit sizeValue
+}
+
+// make sure memoryLimit implements the subcommands.Command interface.
+var _ subcommands.Command = (*memoryLimit)(nil)
+
+// MemoryLimit wraps sub with the -memlimit flag, a size parsed by ParseSize. When set, sub executes
+// with the soft memory limit of the runtime set to it by debug.SetMemoryLimit, restored afterward.
+// With WithMemoryMonitor, the heap is also monitored against the memory limit, the one of the
+// runtime if -memlimit is not set.
+func MemoryLimit(sub subcommands.Command, opts ...MemoryLimitOption) subcommands.Command {
+	c := &memoryLimit{
+		sub:    sub,
+		logger: stdLogger{},
+	}
+	for _, opt := range opts {
+		opt.applyMemoryLimit(c)
+	}
+
+	return c
+}
+
+// Name forwards to the underlying c.sub Command.
+func (c *memoryLimit) Name() string {
+	return c.sub.Name()
+}
+
+// Usage forwards to the underlying c.sub Command.
+func (c *memoryLimit) Usage() string {
+	return c.sub.Usage()
+}
+
+// Synopsis forwards to the underlying c.sub Command.
+func (c *memoryLimit) Synopsis() string {
+	return c.sub.Synopsis()
+}
+
+// Unwrap returns the underlying c.sub Command.
+func (c *memoryLimit) Unwrap() subcommands.Command {
+	return c.sub
+}
+
+// SetFlags forwards to the underlying c.sub Command and registers the -memlimit flag.
+func (c *memoryLimit) SetFlags(f *flag.FlagSet) {
+	c.sub.SetFlags(f)
+	c.limit = 0
+	f.Var(&c.limit, "memlimit", "set the soft memory limit to `size`, like 512MiB")
+}
+
+// Execute sets the memory limit, starts the monitor, and forwards to the underlying c.sub Command.
+func (c *memoryLimit) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	limit := int64(c.limit)
+	if limit > 0 {
+		prev := debug.SetMemoryLimit(limit)
+		defer debug.SetMemoryLimit(prev)
+	} else {
+		limit = debug.SetMemoryLimit(-1)
+	}
+
+	if c.interval > 0 && limit < math.MaxInt64 {
+		stop := c.monitor(ctx, uint64(float64(limit)*c.fraction))
+		defer stop()
+	}
+
+	return c.sub.Execute(ctx, f, args...)
+}
+
+// monitor starts sampling the heap against threshold until ctx is done or stop is called.
+func (c *memoryLimit) monitor(ctx context.Context, threshold uint64) (stop func()) {
+	ticker := ClockFromContext(ctx).NewTicker(c.interval)
+	stopped := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer ticker.Stop()
+
+		exceeded := false
+		for {
+			select {
+			case <-stopped:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C():
+			}
+
+			var ms runtime.MemStats
+			runtime.ReadMemStats(&ms)
+			switch {
+			case ms.HeapAlloc <= threshold:
+				exceeded = false
+			case !exceeded:
+				exceeded = true
+				if c.onExceeded != nil {
+					c.onExceeded(ctx, ms.HeapAlloc)
+				} else {
+					contextLogger(ctx, c.logger).Printf("%s: warning: heap of %s exceeds %s", c.sub.Name(), FormatSize(int64(ms.HeapAlloc)), FormatSize(int64(threshold)))
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(stopped)
+		<-done
+	}
+}
@@ -0,0 +1,126 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"context"
+	"flag"
+	"runtime/debug"
+	"testing"
+	"time"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+func TestParseSize(t *testing.T) {
+	tests := map[string]struct {
+		s       string
+		want    int64
+		wantErr bool
+	}{
+		"when the size is in bytes":          {s: "1024", want: 1024},
+		"when the unit is B":                 {s: "10B", want: 10},
+		"when the unit is an IEC unit":       {s: "512MiB", want: 512 << 20},
+		"when the unit is an SI unit":        {s: "2GB", want: 2e9},
+		"when the size is fractional":        {s: "1.5GiB", want: 3 << 29},
+		"when the unit is lower case":        {s: "64kib", want: 64 << 10},
+		"when the unit is separated":         {s: "1 TiB", want: 1 << 40},
+		"when the unit is unknown":           {s: "3PB", wantErr: true},
+		"when there is no number":            {s: "MiB", wantErr: true},
+		"when the size is out of range":      {s: "10000000TiB", wantErr: true},
+		"when the number has several points": {s: "1.2.3MiB", wantErr: true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := subcommandsutil.ParseSize(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("wanted an error %t but got %v", tt.wantErr, err)
+			}
+			if got != tt.want {
+				t.Fatalf("wanted %d but got %d", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	tests := map[string]struct {
+		n    int64
+		want string
+	}{
+		"when the size is below a KiB":  {n: 512, want: "512B"},
+		"when the size is whole":        {n: 512 << 20, want: "512MiB"},
+		"when the size is fractional":   {n: 3 << 29, want: "1.5GiB"},
+		"when the size is rounded":      {n: 1<<20 + 200<<10, want: "1.2MiB"},
+		"when the size is in terabytes": {n: 2 << 40, want: "2TiB"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := subcommandsutil.FormatSize(tt.n); got != tt.want {
+				t.Fatalf("wanted %q but got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestMemoryLimit(t *testing.T) {
+	before := debug.SetMemoryLimit(-1)
+
+	var during int64
+	sub := testcmd.NewRecording("batch", testcmd.WithExecute(func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+		during = debug.SetMemoryLimit(-1)
+		return subcommands.ExitSuccess
+	}))
+	cmd := subcommandsutil.MemoryLimit(sub)
+	status, _, _ := testcmd.Run(context.Background(), cmd, "-memlimit", "512MiB")
+	testcmd.RequireSuccess(t, status)
+	if during != 512<<20 {
+		t.Fatalf("wanted the memory limit %d during the execution but got %d", 512<<20, during)
+	}
+	if after := debug.SetMemoryLimit(-1); after != before {
+		t.Fatalf("wanted the memory limit restored to %d but got %d", before, after)
+	}
+
+	// the limit of the previous execution is not set again without -memlimit
+	status, _, _ = testcmd.Run(context.Background(), cmd)
+	testcmd.RequireSuccess(t, status)
+	if during != before {
+		t.Fatalf("wanted the memory limit %d during the execution without -memlimit but got %d", before, during)
+	}
+
+	status, _, _ = testcmd.Run(context.Background(), subcommandsutil.MemoryLimit(testcmd.NewRecording("batch")), "-memlimit", "lots")
+	testcmd.AssertStatus(t, status, subcommands.ExitUsageError)
+}
+
+func TestMemoryMonitor(t *testing.T) {
+	defer testcmd.VerifyNoLeaks(t)
+
+	clk := testcmd.NewFakeClock(time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC))
+	fired := make(chan uint64, 1)
+	onExceeded := func(ctx context.Context, heap uint64) { fired <- heap }
+
+	sub := testcmd.NewRecording("batch", testcmd.WithExecute(func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+		clk.BlockUntil(1)
+		clk.Advance(time.Second)
+		select {
+		case heap := <-fired:
+			if heap == 0 {
+				t.Error("wanted the size of the heap but got 0")
+			}
+		case <-time.After(10 * time.Second):
+			t.Error("wanted the monitor to fire")
+		}
+		return subcommands.ExitSuccess
+	}))
+	cmd := subcommandsutil.MemoryLimit(sub, subcommandsutil.WithMemoryMonitor(time.Second, 1e-9, onExceeded))
+
+	status, _, _ := testcmd.Run(subcommandsutil.WithClock(context.Background(), clk), cmd, "-memlimit", "1GiB")
+	testcmd.RequireSuccess(t, status)
+	if n := clk.Waiters(); n != 0 {
+		t.Fatalf("wanted the monitor stopped but got %d waiters", n)
+	}
+}
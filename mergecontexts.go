@@ -0,0 +1,87 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// mergedContext is a context.Context done when either of its parents is, returned by
+// MergeContexts.
+type mergedContext struct {
+	context.Context // canceled by finish, with the values of primary
+
+	primary, secondary context.Context
+	cancel             context.CancelCauseFunc
+
+	mu  sync.Mutex
+	err error
+}
+
+// MergeContexts returns a context carrying the values of primary, done when primary or secondary
+// is done, like when either the signal context of the command line or a lease of the application
+// expires. Its Err and context.Cause are the ones of the parent done first, either of them when
+// both are done concurrently, and its deadline the earliest of theirs. The contexts derived from it
+// report context.Canceled, with the same cause.
+//
+// Calling the returned cancel releases the resources of the context, and cancels it with
+// context.Canceled unless a parent was done first. It should be called once the context is no
+// longer needed, like for context.WithCancel.
+func MergeContexts(primary, secondary context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancelCause(context.WithoutCancel(primary))
+	m := &mergedContext{
+		Context:   ctx,
+		primary:   primary,
+		secondary: secondary,
+		cancel:    cancel,
+	}
+
+	// done synchronously if a parent already is, rather than once the AfterFunc runs
+	for _, parent := range []context.Context{primary, secondary} {
+		if err := parent.Err(); err != nil {
+			m.finish(err, context.Cause(parent))
+			return m, func() {}
+		}
+	}
+	stopPrimary := context.AfterFunc(primary, func() { m.finish(primary.Err(), context.Cause(primary)) })
+	stopSecondary := context.AfterFunc(secondary, func() { m.finish(secondary.Err(), context.Cause(secondary)) })
+
+	return m, func() {
+		stopPrimary()
+		stopSecondary()
+		m.finish(context.Canceled, context.Canceled)
+	}
+}
+
+// finish cancels m with err and cause, unless it is already done.
+func (m *mergedContext) finish(err, cause error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.err != nil {
+		return
+	}
+	m.err = err
+	m.cancel(cause)
+}
+
+// Deadline implements context.Context.
+func (m *mergedContext) Deadline() (time.Time, bool) {
+	deadline, ok := m.primary.Deadline()
+	if d, sok := m.secondary.Deadline(); sok && (!ok || d.Before(deadline)) {
+		return d, true
+	}
+
+	return deadline, ok
+}
+
+// Err implements context.Context.
+func (m *mergedContext) Err() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.err
+}
@@ -0,0 +1,131 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+// mergeKey is the key of a value of the contexts merged by MergeContexts.
+type mergeKey struct{}
+
+func TestMergeContexts(t *testing.T) {
+	errLease := errors.New("lease expired")
+
+	tests := map[string]struct {
+		// secondFirst cancels the secondary context first, with errLease, instead of the primary one
+		secondFirst bool
+		// early cancels it before the contexts are merged
+		early     bool
+		wantCause error
+	}{
+		"when the primary context is canceled first": {
+			wantCause: context.Canceled,
+		},
+		"when the secondary context is canceled first": {
+			secondFirst: true,
+			wantCause:   errLease,
+		},
+		"when the secondary context is canceled before they are merged": {
+			secondFirst: true,
+			early:       true,
+			wantCause:   errLease,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			defer testcmd.VerifyNoLeaks(t)
+
+			primary, cancelPrimary := context.WithCancelCause(context.WithValue(context.Background(), mergeKey{}, "primary"))
+			defer cancelPrimary(nil)
+			secondary, cancelSecondary := context.WithCancelCause(context.WithValue(context.Background(), mergeKey{}, "secondary"))
+			defer cancelSecondary(nil)
+			first, second := func() { cancelPrimary(nil) }, func() { cancelSecondary(errLease) }
+			if tt.secondFirst {
+				first, second = second, first
+			}
+
+			if tt.early {
+				first()
+			}
+			ctx, cancel := subcommandsutil.MergeContexts(primary, secondary)
+			defer cancel()
+			if got := ctx.Value(mergeKey{}); got != "primary" {
+				t.Fatalf("wanted the value of the primary context but got %v", got)
+			}
+			if !tt.early {
+				if err := ctx.Err(); err != nil {
+					t.Fatalf("wanted the merged context not done but got %v", err)
+				}
+				first()
+			}
+
+			select {
+			case <-ctx.Done():
+			case <-time.After(10 * time.Second):
+				t.Fatal("wanted the merged context done")
+			}
+			second()
+			if err := ctx.Err(); err != context.Canceled {
+				t.Fatalf("wanted the error %v but got %v", context.Canceled, err)
+			}
+			if cause := context.Cause(ctx); cause != tt.wantCause {
+				t.Fatalf("wanted the cause %v but got %v", tt.wantCause, cause)
+			}
+			child, cancelChild := context.WithCancel(ctx)
+			defer cancelChild()
+			if cause := context.Cause(child); cause != tt.wantCause {
+				t.Fatalf("wanted the cause %v for a derived context but got %v", tt.wantCause, cause)
+			}
+		})
+	}
+}
+
+func TestMergeContextsDeadline(t *testing.T) {
+	now := time.Now()
+	primary, cancelPrimary := context.WithDeadline(context.Background(), now.Add(time.Hour))
+	defer cancelPrimary()
+	secondary, cancelSecondary := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancelSecondary()
+
+	ctx, cancel := subcommandsutil.MergeContexts(primary, secondary)
+	defer cancel()
+	if deadline, ok := ctx.Deadline(); !ok || !deadline.Before(now.Add(time.Hour)) {
+		t.Fatalf("wanted the deadline of the secondary context but got %v, %v", deadline, ok)
+	}
+
+	<-ctx.Done()
+	if err := ctx.Err(); err != context.DeadlineExceeded {
+		t.Fatalf("wanted the error %v but got %v", context.DeadlineExceeded, err)
+	}
+}
+
+func TestMergeContextsCancel(t *testing.T) {
+	defer testcmd.VerifyNoLeaks(t)
+
+	primary, cancelPrimary := context.WithCancel(context.Background())
+	defer cancelPrimary()
+	secondary, cancelSecondary := context.WithCancel(context.Background())
+	defer cancelSecondary()
+
+	ctx, cancel := subcommandsutil.MergeContexts(primary, secondary)
+	cancel()
+	<-ctx.Done()
+	if err := ctx.Err(); err != context.Canceled {
+		t.Fatalf("wanted the error %v but got %v", context.Canceled, err)
+	}
+
+	// the parents no longer reach the released context
+	cancelSecondary()
+	cancelPrimary()
+	if cause := context.Cause(ctx); cause != context.Canceled {
+		t.Fatalf("wanted the cause %v but got %v", context.Canceled, cause)
+	}
+}
@@ -0,0 +1,224 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// MessageKey identifies a message printed by the wrappers, whose format can be overridden in
+// Messages.
+type MessageKey string
+
+// The keys of the messages printed by the wrappers. The comments show the English formats they
+// default to.
+const (
+	// MessageCanceled is logged by Cancelable when the execution is canceled: "%s: %v".
+	MessageCanceled MessageKey = "canceled"
+
+	// MessageTimedOut is printed by Timeout when the execution times out: "%s: timed out after %v".
+	MessageTimedOut MessageKey = "timed-out"
+
+	// MessageDeadlinePassed is printed by Timeout when the -deadline is already past:
+	// "%s: deadline %s already passed".
+	MessageDeadlinePassed MessageKey = "deadline-passed"
+
+	// MessageConfirmPrompt is the prompt of Confirm: "%s [y/N] ".
+	MessageConfirmPrompt MessageKey = "confirm-prompt"
+
+	// MessageConfirmRefused is printed by Confirm without a terminal:
+	// "%s: refusing to run without confirmation; use -yes".
+	MessageConfirmRefused MessageKey = "confirm-refused"
+
+	// MessageConfirmAborted is printed by Confirm when the execution is not confirmed: "%s: aborted".
+	MessageConfirmAborted MessageKey = "confirm-aborted"
+
+	// MessageMissingRequiredFlag is printed by RequireFlags: "%s: missing required flag -%s".
+	MessageMissingRequiredFlag MessageKey = "missing-required-flag"
+
+	// MessageNotRoot is printed by RequireRoot: "%s: this command must be run as root; try sudo",
+	// or "%s: this command must be run as an administrator; try an elevated prompt" on Windows.
+	MessageNotRoot MessageKey = "not-root"
+
+	// MessageWrongUser is printed by RequireUser: "%s: this command must be run as %s".
+	MessageWrongUser MessageKey = "wrong-user"
+
+	// MessageUnsupportedPlatform is printed by OnlyOn, OnlyOnArch and OnlyWhere:
+	// "the '%s' command is only supported on %s".
+	MessageUnsupportedPlatform MessageKey = "unsupported-platform"
+
+	// MessageAlreadyExecuting is logged by the wrappers executed again before their execution
+	// returns: "%s: command already executing".
+	MessageAlreadyExecuting MessageKey = "already-executing"
+
+	// MessageRateLimited is printed by RateLimited when an execution is rejected as too soon:
+	// "%s: rate limited, retry in %v".
+	MessageRateLimited MessageKey = "rate-limited"
+
+	// MessageOutputTruncated is printed by LimitOutput when the output exceeds the limit:
+	// "[output truncated after %d bytes]".
+	MessageOutputTruncated MessageKey = "output-truncated"
+
+	// MessageUnknownCommand reports an unknown command: "unknown command %q".
+	MessageUnknownCommand MessageKey = "unknown-command"
+
+	// MessageDidYouMean follows MessageUnknownCommand with the suggestions: "; did you mean %s?".
+	MessageDidYouMean MessageKey = "did-you-mean"
+
+	// MessageDidYouMeanFlag is printed for a mistyped flag with the suggestions: "did you mean -%s?".
+	MessageDidYouMeanFlag MessageKey = "did-you-mean-flag"
+
+	// MessageListOr joins the last of several suggestions to the others: "%s or %s".
+	MessageListOr MessageKey = "list-or"
+)
+
+// defaultMessages are the English formats of the messages.
+var defaultMessages = map[MessageKey]string{
+	MessageCanceled:            "%s: %v",
+	MessageTimedOut:            "%s: timed out after %v",
+	MessageDeadlinePassed:      "%s: deadline %s already passed",
+	MessageConfirmPrompt:       "%s [y/N] ",
+	MessageConfirmRefused:      "%s: refusing to run without confirmation; use -yes",
+	MessageConfirmAborted:      "%s: aborted",
+	MessageMissingRequiredFlag: "%s: missing required flag -%s",
+	MessageNotRoot:             notRootMessage,
+	MessageWrongUser:           "%s: this command must be run as %s",
+	MessageUnsupportedPlatform: "the '%s' command is only supported on %s",
+	MessageAlreadyExecuting:    "%s: command already executing",
+	MessageRateLimited:         "%s: rate limited, retry in %v",
+	MessageOutputTruncated:     "[output truncated after %d bytes]",
+	MessageUnknownCommand:      "unknown command %q",
+	MessageDidYouMean:          "; did you mean %s?",
+	MessageDidYouMeanFlag:      "did you mean -%s?",
+	MessageListOr:              "%s or %s",
+}
+
+// Messages is a catalog of the messages printed by the wrappers, and of the command metadata
+// rendered in the usage. The zero value, like a nil *Messages, prints the English messages.
+type Messages struct {
+	// Overrides maps message keys to the formats replacing their English ones. An override must
+	// have the same printf verbs as the English format, possibly reordered with explicit argument
+	// indexes; otherwise the English format is used.
+	Overrides map[MessageKey]string
+
+	// Translate, if not nil, translates the synopses and usage messages of the commands when
+	// ExplainCommand, ExplainGroup and the command listings render them.
+	Translate func(text string) string
+}
+
+// Format returns the format of the message key, its override if it is valid.
+func (m *Messages) Format(key MessageKey) string {
+	def := defaultMessages[key]
+	if m == nil {
+		return def
+	}
+	if format, ok := m.Overrides[key]; ok && sameVerbs(format, def) {
+		return format
+	}
+
+	return def
+}
+
+// Sprintf formats the message key with args.
+func (m *Messages) Sprintf(key MessageKey, args ...interface{}) string {
+	return fmt.Sprintf(m.Format(key), args...)
+}
+
+// translate returns text translated by m.Translate, if any.
+func (m *Messages) translate(text string) string {
+	if m == nil || m.Translate == nil || text == "" {
+		return text
+	}
+
+	return m.Translate(text)
+}
+
+// messagesKey is the context key of the Messages of an execution.
+type messagesKey struct{}
+
+// WithMessages returns a copy of ctx carrying m, the Messages the wrappers print their messages
+// with during the execution.
+func WithMessages(ctx context.Context, m *Messages) context.Context {
+	return context.WithValue(ctx, messagesKey{}, m)
+}
+
+// MessagesFromContext returns the Messages carried by ctx, or those set by SetMessages.
+func MessagesFromContext(ctx context.Context) *Messages {
+	if m, ok := ctx.Value(messagesKey{}).(*Messages); ok {
+		return m
+	}
+
+	return defaultMessagesCatalog()
+}
+
+var (
+	messagesMu sync.Mutex
+	messages   *Messages
+)
+
+// SetMessages sets the Messages used where no execution context carries any, notably by the usage
+// renderers. A nil m restores the English messages.
+func SetMessages(m *Messages) {
+	messagesMu.Lock()
+	defer messagesMu.Unlock()
+
+	messages = m
+}
+
+// defaultMessagesCatalog returns the Messages set by SetMessages.
+func defaultMessagesCatalog() *Messages {
+	messagesMu.Lock()
+	defer messagesMu.Unlock()
+
+	return messages
+}
+
+// sameVerbs reports whether the formats a and b have the same printf verbs, in any order.
+func sameVerbs(a, b string) bool {
+	va, vb := printfVerbs(a), printfVerbs(b)
+	if len(va) != len(vb) {
+		return false
+	}
+	for i := range va {
+		if va[i] != vb[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// printfVerbs returns the verbs of the printf format, sorted, ignoring "%%".
+func printfVerbs(format string) []byte {
+	var verbs []byte
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			continue
+		}
+		// skip the flags, width, precision and argument index
+		for i++; i < len(format) && isVerbModifier(format[i]); i++ {
+		}
+		if i < len(format) && format[i] != '%' {
+			verbs = append(verbs, format[i])
+		}
+	}
+	sort.Slice(verbs, func(i, j int) bool { return verbs[i] < verbs[j] })
+
+	return verbs
+}
+
+// isVerbModifier reports whether c can appear between a '%' and its verb.
+func isVerbModifier(c byte) bool {
+	switch {
+	case '0' <= c && c <= '9':
+		return true
+	case c == '+', c == '-', c == '#', c == ' ', c == '.', c == '*', c == '[', c == ']':
+		return true
+	}
+
+	return false
+}
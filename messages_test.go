@@ -0,0 +1,133 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"context"
+	"flag"
+	"strings"
+	"testing"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+// frenchMessages is a fake catalog translating some of the messages to French.
+var frenchMessages = &subcommandsutil.Messages{
+	Overrides: map[subcommandsutil.MessageKey]string{
+		subcommandsutil.MessageConfirmPrompt:       "%s [o/N] ",
+		subcommandsutil.MessageConfirmAborted:      "%s : abandon",
+		subcommandsutil.MessageMissingRequiredFlag: "option obligatoire -%[2]s manquante pour %[1]s",
+		subcommandsutil.MessageUnknownCommand:      "commande inconnue %d",
+	},
+	Translate: func(text string) string {
+		translated, ok := map[string]string{
+			"copy files":                 "copie des fichiers",
+			"copy SRC DST:\n  Copies.\n": "copy SRC DST :\n  Copie.\n",
+		}[text]
+		if !ok {
+			return text
+		}
+		return translated
+	},
+}
+
+func TestMessages(t *testing.T) {
+	ctx := subcommandsutil.WithMessages(context.Background(), frenchMessages)
+
+	tests := map[string]struct {
+		cmd        subcommands.Command
+		wantStatus subcommands.ExitStatus
+		wantStderr string
+	}{
+		"when Confirm is aborted": {
+			cmd:        subcommandsutil.Confirm(testcmd.NewRecording("purge"), "tout supprimer ?", subcommandsutil.WithConfirmStdin(strings.NewReader("n\n"))),
+			wantStatus: subcommands.ExitFailure,
+			wantStderr: "tout supprimer ? [o/N] purge : abandon\n",
+		},
+		"when a required flag is missing": {
+			cmd: subcommandsutil.RequireFlags(testcmd.NewRecording("deploy", testcmd.WithFlags(func(f *flag.FlagSet) {
+				f.String("env", "", "environment")
+				subcommandsutil.MarkRequired(f, "env")
+			}))),
+			wantStatus: subcommands.ExitUsageError,
+			wantStderr: "option obligatoire -env manquante pour deploy\n",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var stderr testcmd.Buffer
+			status, _, _ := testcmd.Run(subcommandsutil.WithOutput(ctx, nil, &stderr), tt.cmd)
+			testcmd.AssertStatus(t, status, tt.wantStatus)
+			if got := stderr.String(); got != tt.wantStderr {
+				t.Fatalf("wanted the stderr %q but got %q", tt.wantStderr, got)
+			}
+		})
+	}
+}
+
+func TestMessagesFormat(t *testing.T) {
+	tests := map[string]struct {
+		m    *subcommandsutil.Messages
+		key  subcommandsutil.MessageKey
+		want string
+	}{
+		"when there is no catalog": {
+			key:  subcommandsutil.MessageConfirmAborted,
+			want: "%s: aborted",
+		},
+		"when the key is overridden": {
+			m:    frenchMessages,
+			key:  subcommandsutil.MessageConfirmAborted,
+			want: "%s : abandon",
+		},
+		"when the override reorders the verbs": {
+			m:    frenchMessages,
+			key:  subcommandsutil.MessageMissingRequiredFlag,
+			want: "option obligatoire -%[2]s manquante pour %[1]s",
+		},
+		"when the override changes the verbs": {
+			m:    frenchMessages,
+			key:  subcommandsutil.MessageUnknownCommand,
+			want: "unknown command %q",
+		},
+		"when the key is not overridden": {
+			m:    frenchMessages,
+			key:  subcommandsutil.MessageTimedOut,
+			want: "%s: timed out after %v",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := tt.m.Format(tt.key); got != tt.want {
+				t.Fatalf("wanted the format %q but got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestSetMessages(t *testing.T) {
+	subcommandsutil.SetMessages(frenchMessages)
+	defer subcommandsutil.SetMessages(nil)
+
+	cmd := testcmd.NewRecording("copy", testcmd.WithSynopsis("copy files"), testcmd.WithUsage("copy SRC DST:\n  Copies.\n"))
+
+	var usage strings.Builder
+	subcommandsutil.ExplainCommand(&usage, cmd)
+	if want := "copy SRC DST :\n  Copie.\n"; usage.String() != want {
+		t.Fatalf("wanted the usage %q but got %q", want, usage.String())
+	}
+
+	cdr := subcommands.NewCommander(flag.NewFlagSet("tool", flag.ContinueOnError), "tool")
+	cdr.Register(cmd, "")
+	var listing strings.Builder
+	cdr.VisitGroups(func(g *subcommands.CommandGroup) {
+		subcommandsutil.ExplainGroup(cdr)(&listing, g)
+	})
+	if want := "\tcopy             copie des fichiers\n"; !strings.Contains(listing.String(), want) {
+		t.Fatalf("wanted the listing to contain %q but got %q", want, listing.String())
+	}
+}
@@ -0,0 +1,50 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"strconv"
+	"time"
+)
+
+// Metrics records the metrics of the executions. Its methods must be safe for concurrent use, and
+// must neither block nor fail.
+type Metrics interface {
+	// Count adds value to the counter name with tags.
+	Count(name string, value int64, tags map[string]string)
+
+	// Timing records the duration d in the timing name with tags.
+	Timing(name string, d time.Duration, tags map[string]string)
+}
+
+// The names of the metrics recorded by MetricsSubscriber.
+const (
+	// MetricExecutions counts the finished executions, tagged with command and status.
+	MetricExecutions = "subcommand.executions"
+
+	// MetricDuration records the duration of the finished executions, tagged with command and
+	// status.
+	MetricDuration = "subcommand.duration"
+
+	// MetricCancellations counts the canceled executions, tagged with command.
+	MetricCancellations = "subcommand.cancellations"
+)
+
+// MetricsSubscriber returns a function recording the ExecutionFinished and Canceled events to m,
+// to subscribe to an Events. Subscribe it with SubscribeTelemetry when m sends the metrics over the
+// network, like StatsD, so that it honors the opt-out of Telemetry:
+//
+//	events.SubscribeTelemetry(subcommandsutil.MetricsSubscriber(statsd))
+func MetricsSubscriber(m Metrics) func(Event) {
+	return func(ev Event) {
+		switch ev := ev.(type) {
+		case ExecutionFinished:
+			tags := map[string]string{"command": ev.Command, "status": strconv.Itoa(int(ev.Status))}
+			m.Count(MetricExecutions, 1, tags)
+			m.Timing(MetricDuration, ev.Duration, tags)
+		case Canceled:
+			m.Count(MetricCancellations, 1, map[string]string{"command": ev.Command})
+		}
+	}
+}
@@ -0,0 +1,22 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"github.com/google/subcommands"
+)
+
+// Middleware wraps a Command, like the wrappers of this package. For example:
+//
+//	var mw subcommandsutil.Middleware = subcommandsutil.WithGlobalFlags
+type Middleware func(subcommands.Command) subcommands.Command
+
+// Chain wraps cmd with mws, the first Middleware being the outermost.
+func Chain(cmd subcommands.Command, mws ...Middleware) subcommands.Command {
+	for i := len(mws) - 1; i >= 0; i-- {
+		cmd = mws[i](cmd)
+	}
+
+	return cmd
+}
@@ -0,0 +1,39 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"testing"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+// tagging returns a Middleware appending tag to *tags each time it wraps a Command.
+func tagging(tags *[]string, tag string) subcommandsutil.Middleware {
+	return func(cmd subcommands.Command) subcommands.Command {
+		*tags = append(*tags, tag+":"+cmd.Name())
+		return subcommandsutil.Logged(cmd, subcommandsutil.WithLogger(&testcmd.LogRecorder{}))
+	}
+}
+
+func TestChain(t *testing.T) {
+	var tags []string
+	sub := testcmd.NewRecording("build")
+	cmd := subcommandsutil.Chain(sub, tagging(&tags, "outer"), tagging(&tags, "inner"))
+
+	// the innermost Middleware wraps first
+	if len(tags) != 2 || tags[0] != "inner:build" || tags[1] != "outer:build" {
+		t.Fatalf("wanted the inner Middleware to wrap first but got %q", tags)
+	}
+	if inner := subcommandsutil.Unwrap(cmd); subcommandsutil.Unwrap(inner) != sub {
+		t.Fatal("wanted two wrappers around the command")
+	}
+
+	if got := subcommandsutil.Chain(sub); got != sub {
+		t.Fatalf("wanted Chain without Middleware to return the command but got %v", got)
+	}
+}
@@ -0,0 +1,83 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/subcommands"
+)
+
+// MultiCallOption is an option of MultiCall.
+type MultiCallOption interface {
+	applyMultiCall(*multiCall)
+}
+
+// multiCallOptionFunc is a MultiCallOption implemented by a function.
+type multiCallOptionFunc func(*multiCall)
+
+// applyMultiCall implements MultiCallOption.
+func (fn multiCallOptionFunc) applyMultiCall(c *multiCall) { fn(c) }
+
+// WithNamePrefix sets the prefix MultiCall strips from the name of the program, the canonical name
+// followed by a dash by default. An empty prefix matches the names of the commands as is.
+func WithNamePrefix(prefix string) MultiCallOption {
+	return multiCallOptionFunc(func(c *multiCall) {
+		c.prefix = &prefix
+	})
+}
+
+// WithUnknownNameWarning makes MultiCall print a warning to w when the name of the program is
+// neither the canonical name nor the one of a command, like a misnamed symlink.
+func WithUnknownNameWarning(w io.Writer) MultiCallOption {
+	return multiCallOptionFunc(func(c *multiCall) {
+		c.warnings = w
+	})
+}
+
+// multiCall is the configuration of MultiCall.
+type multiCall struct {
+	prefix   *string // canonical + "-" if nil
+	warnings io.Writer
+}
+
+// MultiCall returns the arguments to execute cdr with, like by Run, for a multi-call binary
+// invoked under the name of a command, like busybox: a program named "mytool" behaves as its push
+// command when started through a symlink named "mytool-push", the arguments of the process being
+// the flags and arguments of push. The name is the base name of os.Args[0], without the .exe
+// extension, and the prefix of WithNamePrefix.
+//
+//	args, _ := subcommandsutil.MultiCall(subcommands.DefaultCommander, "mytool")
+//	os.Exit(subcommandsutil.Run(context.Background(), subcommands.DefaultCommander, args))
+//
+// Invoked under the canonical name, or under a name which is not the one of a command, it returns
+// the arguments of the process as is, and rewritten is false.
+func MultiCall(cdr *subcommands.Commander, canonical string, opts ...MultiCallOption) (argv []string, rewritten bool) {
+	c := &multiCall{}
+	for _, opt := range opts {
+		opt.applyMultiCall(c)
+	}
+	prefix := canonical + "-"
+	if c.prefix != nil {
+		prefix = *c.prefix
+	}
+
+	args := os.Args[1:]
+	name := strings.TrimSuffix(filepath.Base(os.Args[0]), ".exe")
+	if name == canonical {
+		return args, false
+	}
+	if cmdName, ok := strings.CutPrefix(name, prefix); ok && cmdName != "" && lookupCommand(cdr, cmdName) != nil {
+		return append([]string{cmdName}, args...), true
+	}
+
+	if c.warnings != nil {
+		fmt.Fprintf(c.warnings, "warning: %s: %s is not the name of a command; running as %s\n", canonical, name, canonical)
+	}
+	return args, false
+}
@@ -0,0 +1,114 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"context"
+	"flag"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+func TestMultiCall(t *testing.T) {
+	tests := map[string]struct {
+		osArgs        []string
+		opts          []subcommandsutil.MultiCallOption
+		wantArgs      []string
+		wantRewritten bool
+		wantWarning   string
+	}{
+		"when invoked under the canonical name": {
+			osArgs:   []string{"/usr/bin/mytool", "push", "-force", "origin"},
+			wantArgs: []string{"push", "-force", "origin"},
+		},
+		"when invoked under the name of a command": {
+			osArgs:        []string{"/usr/local/bin/mytool-push", "-force", "origin"},
+			wantArgs:      []string{"push", "-force", "origin"},
+			wantRewritten: true,
+		},
+		"when invoked under the name of a command on Windows": {
+			osArgs:        []string{"mytool-pull.exe", "origin"},
+			wantArgs:      []string{"pull", "origin"},
+			wantRewritten: true,
+		},
+		"when the prefix is set": {
+			osArgs:        []string{"./mt_pull"},
+			opts:          []subcommandsutil.MultiCallOption{subcommandsutil.WithNamePrefix("mt_")},
+			wantArgs:      []string{"pull"},
+			wantRewritten: true,
+		},
+		"when the prefix is empty": {
+			osArgs:        []string{"push", "origin"},
+			opts:          []subcommandsutil.MultiCallOption{subcommandsutil.WithNamePrefix("")},
+			wantArgs:      []string{"push", "origin"},
+			wantRewritten: true,
+		},
+		"when the command is unknown": {
+			osArgs:      []string{"mytool-frob", "pull"},
+			wantArgs:    []string{"pull"},
+			wantWarning: "warning: mytool: mytool-frob is not the name of a command; running as mytool\n",
+		},
+		"when the name is unknown": {
+			osArgs:      []string{"mytool.old", "pull"},
+			wantArgs:    []string{"pull"},
+			wantWarning: "warning: mytool: mytool.old is not the name of a command; running as mytool\n",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			saved := os.Args
+			defer func() { os.Args = saved }()
+			os.Args = tt.osArgs
+
+			h := testcmd.NewHarness(t)
+			h.Register(testcmd.NewRecording("push"), "")
+			h.Register(testcmd.NewRecording("pull"), "")
+
+			var warnings testcmd.Buffer
+			opts := append([]subcommandsutil.MultiCallOption{subcommandsutil.WithUnknownNameWarning(&warnings)}, tt.opts...)
+			args, rewritten := subcommandsutil.MultiCall(h.Commander, "mytool", opts...)
+			if !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Fatalf("wanted the arguments %q but got %q", tt.wantArgs, args)
+			}
+			if rewritten != tt.wantRewritten {
+				t.Fatalf("wanted rewritten %v but got %v", tt.wantRewritten, rewritten)
+			}
+			if warnings.String() != tt.wantWarning {
+				t.Fatalf("wanted the warning %q but got %q", tt.wantWarning, warnings.String())
+			}
+		})
+	}
+}
+
+func TestMultiCallFlags(t *testing.T) {
+	saved := os.Args
+	defer func() { os.Args = saved }()
+	os.Args = []string{"/usr/local/bin/mytool-push", "-force", "origin", "main"}
+
+	h := testcmd.NewHarness(t)
+	var force bool
+	sub := testcmd.NewRecording("push", testcmd.WithFlags(func(f *flag.FlagSet) {
+		f.BoolVar(&force, "force", false, "force the push")
+	}))
+	h.Register(sub, "")
+
+	args, _ := subcommandsutil.MultiCall(h.Commander, "mytool")
+	code := subcommandsutil.Run(subcommandsutil.WithSignalSource(context.Background(), testcmd.NewSignalSource()), h.Commander, args, subcommandsutil.WithTopFlags(h.Flags))
+	if code != int(subcommands.ExitSuccess) {
+		t.Fatalf("wanted the exit code 0 but got %d", code)
+	}
+	if !force {
+		t.Fatal("wanted the -force flag forwarded to push")
+	}
+	call, ok := sub.LastCall()
+	if want := []string{"origin", "main"}; !ok || !reflect.DeepEqual(call.Args, want) {
+		t.Fatalf("wanted push executed with the arguments %q but got %+v", want, call)
+	}
+}
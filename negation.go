@@ -0,0 +1,83 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+)
+
+// BoolWithNegation defines a bool flag named name in f, with the default value def, and its
+// negation named no-name, like -color and -no-color. Both flags share the value returned.
+//
+// The negation is registered like an alias of AliasFlag: PrintDefaults renders it on the line of
+// name, like "-color, -no-color", and IsFlagSet and RequireFlags report name as set when either
+// spelling is given. Giving both spellings on one command line is a parse error.
+func BoolWithNegation(f *flag.FlagSet, name string, def bool, usage string) *bool {
+	p := new(bool)
+	*p = def
+	b := &negatableBool{name: name, value: p}
+
+	f.Var(negationValue{b, false}, name, usage)
+	f.Var(negationValue{b, true}, "no-"+name, "negation of -"+name)
+
+	updateFlagMeta(f, func(m *flagMeta) {
+		m.aliases["no-"+name] = name
+	})
+
+	return p
+}
+
+// negatableBool is the value shared by a flag defined by BoolWithNegation and its negation.
+type negatableBool struct {
+	name    string
+	value   *bool
+	set     bool
+	negated bool // whether the negation was given
+}
+
+// negationValue is the flag.Value of one spelling of a flag defined by BoolWithNegation.
+type negationValue struct {
+	b       *negatableBool
+	negated bool
+}
+
+// make sure negationValue implements the flag.Getter interface.
+var _ flag.Getter = negationValue{}
+
+// Set implements flag.Value.
+func (v negationValue) Set(s string) error {
+	x, err := strconv.ParseBool(s)
+	if err != nil {
+		return err
+	}
+	if v.b.set && v.b.negated != v.negated {
+		return fmt.Errorf("-%s and -no-%s are mutually exclusive", v.b.name, v.b.name)
+	}
+
+	v.b.set, v.b.negated = true, v.negated
+	*v.b.value = x != v.negated
+
+	return nil
+}
+
+// String implements flag.Value.
+func (v negationValue) String() string {
+	if v.b == nil {
+		return "false"
+	}
+
+	return strconv.FormatBool(*v.b.value != v.negated)
+}
+
+// Get implements flag.Getter.
+func (v negationValue) Get() interface{} {
+	return *v.b.value != v.negated
+}
+
+// IsBoolFlag makes the flag package accept the flag without a value, like -color.
+func (v negationValue) IsBoolFlag() bool {
+	return true
+}
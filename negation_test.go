@@ -0,0 +1,90 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"bytes"
+	"flag"
+	"io"
+	"testing"
+
+	"github.com/zchee/subcommandsutil"
+)
+
+func TestBoolWithNegation(t *testing.T) {
+	tests := map[string]struct {
+		args    []string
+		want    bool
+		wantSet bool
+		wantErr string
+	}{
+		"when no spelling is given": {
+			want: true,
+		},
+		"when the flag is given": {
+			args:    []string{"-color"},
+			want:    true,
+			wantSet: true,
+		},
+		"when the flag is given false": {
+			args:    []string{"-color=false"},
+			wantSet: true,
+		},
+		"when the negation is given": {
+			args:    []string{"-no-color"},
+			wantSet: true,
+		},
+		"when the negation is given twice": {
+			args:    []string{"-no-color", "-no-color"},
+			wantSet: true,
+		},
+		"when both spellings are given": {
+			args:    []string{"-color", "-no-color"},
+			wantErr: "invalid boolean flag no-color: -color and -no-color are mutually exclusive",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			f := flag.NewFlagSet("log", flag.ContinueOnError)
+			f.SetOutput(io.Discard)
+			color := subcommandsutil.BoolWithNegation(f, "color", true, "colorize the output")
+
+			err := f.Parse(tt.args)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("wanted the error %q but got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if *color != tt.want {
+				t.Fatalf("wanted -color %t but got %t", tt.want, *color)
+			}
+			if got := subcommandsutil.IsFlagSet(f, "color"); got != tt.wantSet {
+				t.Fatalf("wanted IsFlagSet %t but got %t", tt.wantSet, got)
+			}
+		})
+	}
+}
+
+func TestBoolWithNegationUsage(t *testing.T) {
+	t.Setenv(subcommandsutil.ColumnsEnv, "80")
+
+	var buf bytes.Buffer
+	f := flag.NewFlagSet("log", flag.ContinueOnError)
+	f.SetOutput(&buf)
+	subcommandsutil.BoolWithNegation(f, "color", true, "colorize the output")
+	subcommandsutil.BoolWithNegation(f, "pager", false, "page the output")
+	subcommandsutil.PrintDefaults(f)
+
+	want := "  -color, -no-color\n" +
+		"    \tcolorize the output (default true)\n" +
+		"  -pager, -no-pager\n" +
+		"    \tpage the output\n"
+	if got := buf.String(); got != want {
+		t.Fatalf("wanted %q but got %q", want, got)
+	}
+}
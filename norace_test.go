@@ -0,0 +1,10 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+//go:build !race
+
+package subcommandsutil
+
+// RaceEnabled reports whether the tests run with the race detector, which allocates and drops the
+// pooled values.
+const RaceEnabled = false
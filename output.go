@@ -0,0 +1,52 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"context"
+	"io"
+	"os"
+)
+
+// outputKey is the context key of the output writers.
+type outputKey struct{}
+
+// output holds the output writers of an execution.
+type output struct {
+	stdout io.Writer
+	stderr io.Writer
+}
+
+// WithOutput returns a copy of ctx carrying the writers returned by Stdout and Stderr. A nil writer
+// keeps the writer carried by ctx.
+func WithOutput(ctx context.Context, stdout, stderr io.Writer) context.Context {
+	if stdout == nil {
+		stdout = Stdout(ctx)
+	}
+	if stderr == nil {
+		stderr = Stderr(ctx)
+	}
+
+	return context.WithValue(ctx, outputKey{}, output{stdout: stdout, stderr: stderr})
+}
+
+// Stdout returns the writer for the standard output of the execution of ctx. It defaults to
+// os.Stdout.
+func Stdout(ctx context.Context) io.Writer {
+	if o, ok := ctx.Value(outputKey{}).(output); ok {
+		return o.stdout
+	}
+
+	return os.Stdout
+}
+
+// Stderr returns the writer for the standard error of the execution of ctx. It defaults to
+// os.Stderr.
+func Stderr(ctx context.Context) io.Writer {
+	if o, ok := ctx.Value(outputKey{}).(output); ok {
+		return o.stderr
+	}
+
+	return os.Stderr
+}
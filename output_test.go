@@ -0,0 +1,32 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"testing"
+
+	"github.com/zchee/subcommandsutil"
+)
+
+func TestWithOutput(t *testing.T) {
+	ctx := context.Background()
+	if subcommandsutil.Stdout(ctx) != os.Stdout || subcommandsutil.Stderr(ctx) != os.Stderr {
+		t.Fatal("wanted the writers to default to the os streams")
+	}
+
+	var stdout, stderr bytes.Buffer
+	ctx = subcommandsutil.WithOutput(ctx, &stdout, &stderr)
+	if subcommandsutil.Stdout(ctx) != &stdout || subcommandsutil.Stderr(ctx) != &stderr {
+		t.Fatal("wanted the writers carried by the context")
+	}
+
+	var stdout2 bytes.Buffer
+	ctx = subcommandsutil.WithOutput(ctx, &stdout2, nil)
+	if subcommandsutil.Stdout(ctx) != &stdout2 || subcommandsutil.Stderr(ctx) != &stderr {
+		t.Fatal("wanted a nil writer to keep the current one")
+	}
+}
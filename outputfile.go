@@ -0,0 +1,191 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/google/subcommands"
+)
+
+// OutputFileOption is an option of the OutputFile wrapper.
+type OutputFileOption interface {
+	applyOutputFile(*outputFile)
+}
+
+// outputFileOptionFunc is an OutputFileOption implemented by a function.
+type outputFileOptionFunc func(*outputFile)
+
+// applyOutputFile implements OutputFileOption.
+func (fn outputFileOptionFunc) applyOutputFile(c *outputFile) { fn(c) }
+
+// WithOutputFlag sets the name of the flag registered by OutputFile. It defaults to "o".
+func WithOutputFlag(name string) OutputFileOption {
+	return outputFileOptionFunc(func(c *outputFile) {
+		c.flagName = name
+	})
+}
+
+// outputFile wraps a CancelableCommand so that its output can be written to a file atomically.
+type outputFile struct {
+	sub      CancelableCommand
+	flagName string
+
+	path      string
+	executing executing
+
+	mu  sync.Mutex
+	tmp *os.File // the temporary file of the running execution
+}
+
+// make sure outputFile implements the CancelableCommand interface.
+var _ CancelableCommand = (*outputFile)(nil)
+
+// OutputFile wraps sub with the -o flag, whose name is set by WithOutputFlag. When it names a file,
+// the Stdout of the execution context is a temporary file in the same directory, which is synced
+// and renamed to it only if sub returns subcommands.ExitSuccess before its context is done, so that
+// the file is either replaced whole or left untouched; when the context is done first, the error of
+// the context is reported and subcommands.ExitFailure returned. The directory is created if needed.
+// "-", like the flag being unset, writes to Stdout.
+//
+// The temporary file is removed when sub fails, or by Dispose when a Cancelable wrapper stops
+// waiting for sub; Dispose also forwards to the Dispose method of sub.
+func OutputFile(sub CancelableCommand, opts ...OutputFileOption) CancelableCommand {
+	c := &outputFile{
+		sub:      sub,
+		flagName: "o",
+	}
+	for _, opt := range opts {
+		opt.applyOutputFile(c)
+	}
+
+	return c
+}
+
+// Name forwards to the underlying c.sub Command.
+func (c *outputFile) Name() string {
+	return c.sub.Name()
+}
+
+// Usage forwards to the underlying c.sub Command.
+func (c *outputFile) Usage() string {
+	return c.sub.Usage()
+}
+
+// Synopsis forwards to the underlying c.sub Command.
+func (c *outputFile) Synopsis() string {
+	return c.sub.Synopsis()
+}
+
+// Unwrap returns the underlying c.sub Command.
+func (c *outputFile) Unwrap() subcommands.Command {
+	return c.sub
+}
+
+// SetFlags forwards to the underlying c.sub Command and registers the output flag.
+func (c *outputFile) SetFlags(f *flag.FlagSet) {
+	c.sub.SetFlags(f)
+	f.StringVar(&c.path, c.flagName, "", "write the output to `path` instead of stdout (- for stdout)")
+}
+
+// Dispose removes the temporary file of the running execution and forwards to the underlying c.sub
+// Command.
+func (c *outputFile) Dispose() error {
+	c.discard()
+
+	return c.sub.Dispose()
+}
+
+// Execute forwards to the underlying c.sub Command with its Stdout writing to a temporary file, and
+// renames it to the output path if it succeeds.
+func (c *outputFile) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	if c.path == "" || c.path == "-" {
+		return c.sub.Execute(ctx, f, args...)
+	}
+
+	if !c.executing.enter(ctx, stdLogger{}, c.sub.Name()) {
+		return subcommands.ExitFailure
+	}
+	defer c.executing.exit()
+
+	dir := filepath.Dir(c.path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		fmt.Fprintf(Stderr(ctx), "%s: %v\n", c.sub.Name(), err)
+		return subcommands.ExitFailure
+	}
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(c.path)+".*")
+	if err != nil {
+		fmt.Fprintf(Stderr(ctx), "%s: %v\n", c.sub.Name(), err)
+		return subcommands.ExitFailure
+	}
+	c.mu.Lock()
+	c.tmp = tmp
+	c.mu.Unlock()
+	defer c.discard()
+
+	status := c.sub.Execute(WithOutput(ctx, tmp, nil), f, args...)
+	if status != subcommands.ExitSuccess {
+		return status
+	}
+	if err := ctx.Err(); err != nil {
+		// the output may be incomplete: succeeding would leave the previous file in place unnoticed
+		fmt.Fprintf(Stderr(ctx), "%s: %v\n", c.sub.Name(), err)
+		return subcommands.ExitFailure
+	}
+
+	if err := c.commit(); err != nil {
+		fmt.Fprintf(Stderr(ctx), "%s: writing %s: %v\n", c.sub.Name(), c.path, err)
+		return subcommands.ExitFailure
+	}
+
+	return status
+}
+
+// commit syncs the temporary file of the running execution and renames it to the output path. It
+// does nothing if the file was discarded.
+func (c *outputFile) commit() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	tmp := c.tmp
+	if tmp == nil {
+		return nil
+	}
+	c.tmp = nil
+
+	err := tmp.Sync()
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0o644)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), c.path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+
+	return err
+}
+
+// discard closes and removes the temporary file of the running execution, once.
+func (c *outputFile) discard() {
+	c.mu.Lock()
+	tmp := c.tmp
+	c.tmp = nil
+	c.mu.Unlock()
+	if tmp == nil {
+		return
+	}
+
+	tmp.Close()
+	os.Remove(tmp.Name())
+}
@@ -0,0 +1,170 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+// reportWriter returns a command writing a report to its Stdout and returning status.
+func reportWriter(status subcommands.ExitStatus) *testcmd.Recording {
+	return testcmd.NewRecording("report", testcmd.WithExecute(func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+		fmt.Fprintln(subcommandsutil.Stdout(ctx), "total: 42")
+		return status
+	}))
+}
+
+// dirEntries returns the names of the files in dir.
+func dirEntries(t *testing.T, dir string) []string {
+	t.Helper()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+
+	return names
+}
+
+func TestOutputFile(t *testing.T) {
+	tests := map[string]struct {
+		status     subcommands.ExitStatus
+		opts       []subcommandsutil.OutputFileOption
+		args       func(path string) []string
+		wantFile   bool
+		wantStdout string
+	}{
+		"when the command succeeds": {
+			args:     func(path string) []string { return []string{"-o", path} },
+			wantFile: true,
+		},
+		"when the command fails": {
+			status: subcommands.ExitFailure,
+			args:   func(path string) []string { return []string{"-o", path} },
+		},
+		"when the flag is renamed": {
+			opts:     []subcommandsutil.OutputFileOption{subcommandsutil.WithOutputFlag("output")},
+			args:     func(path string) []string { return []string{"-output", path} },
+			wantFile: true,
+		},
+		"when the path is -": {
+			args:       func(string) []string { return []string{"-o", "-"} },
+			wantStdout: "total: 42\n",
+		},
+		"when the flag is not set": {
+			args:       func(string) []string { return nil },
+			wantStdout: "total: 42\n",
+		},
+	}
+	for name, tt := range tests {
+		tt := tt
+		t.Run(name, func(t *testing.T) {
+			dir := filepath.Join(t.TempDir(), "out")
+			path := filepath.Join(dir, "report.txt")
+			cmd := subcommandsutil.OutputFile(reportWriter(tt.status), tt.opts...)
+
+			var stdout testcmd.Buffer
+			status, _, _ := testcmd.Run(subcommandsutil.WithOutput(context.Background(), &stdout, &testcmd.Buffer{}), cmd, tt.args(path)...)
+			testcmd.AssertStatus(t, status, tt.status)
+			if got := stdout.String(); got != tt.wantStdout {
+				t.Fatalf("wanted the output %q but got %q", tt.wantStdout, got)
+			}
+
+			data, err := os.ReadFile(path)
+			switch {
+			case tt.wantFile && err != nil:
+				t.Fatalf("wanted the output file but got %v", err)
+			case tt.wantFile && string(data) != "total: 42\n":
+				t.Fatalf("wanted the report in the output file but got %q", data)
+			case !tt.wantFile && err == nil:
+				t.Fatalf("wanted no output file but got %q", data)
+			}
+			if _, err := os.Stat(dir); err == nil {
+				want := 0
+				if tt.wantFile {
+					want = 1
+				}
+				if names := dirEntries(t, dir); len(names) != want {
+					t.Fatalf("wanted no temporary file left but got %q", names)
+				}
+			}
+		})
+	}
+}
+
+func TestOutputFileCanceled(t *testing.T) {
+	defer testcmd.VerifyNoLeaks(t)
+
+	started, release := make(chan struct{}), make(chan struct{})
+	sub := testcmd.NewRecording("report", testcmd.WithExecute(func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+		fmt.Fprint(subcommandsutil.Stdout(ctx), "total: ")
+		close(started)
+		<-release
+		return subcommands.ExitSuccess
+	}))
+	dir := t.TempDir()
+	path := filepath.Join(dir, "report.txt")
+	if err := os.WriteFile(path, []byte("previous\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-started
+		cancel()
+	}()
+	cmd := subcommandsutil.Cancelable(subcommandsutil.OutputFile(sub), subcommandsutil.WithLogger(&testcmd.LogRecorder{}))
+	status, _, _ := testcmd.Run(ctx, cmd, "-o", path)
+	testcmd.AssertStatus(t, status, subcommands.ExitFailure)
+
+	if names := dirEntries(t, dir); len(names) != 1 {
+		t.Fatalf("wanted the temporary file removed by Dispose but got %q", names)
+	}
+	close(release)
+	if data, err := os.ReadFile(path); err != nil || string(data) != "previous\n" {
+		t.Fatalf("wanted the output file untouched but got %q, %v", data, err)
+	}
+}
+
+func TestOutputFileCanceledSucceeded(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sub := testcmd.NewRecording("report", testcmd.WithExecute(func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+		fmt.Fprint(subcommandsutil.Stdout(ctx), "total: ")
+		cancel()
+		return subcommands.ExitSuccess
+	}))
+	dir := t.TempDir()
+	path := filepath.Join(dir, "report.txt")
+	if err := os.WriteFile(path, []byte("previous\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stderr testcmd.Buffer
+	status, _, _ := testcmd.Run(subcommandsutil.WithOutput(ctx, nil, &stderr), subcommandsutil.OutputFile(sub), "-o", path)
+	testcmd.AssertStatus(t, status, subcommands.ExitFailure)
+	if got, want := stderr.String(), "report: context canceled\n"; got != want {
+		t.Fatalf("wanted %q but got %q", want, got)
+	}
+	if data, err := os.ReadFile(path); err != nil || string(data) != "previous\n" {
+		t.Fatalf("wanted the output file untouched but got %q, %v", data, err)
+	}
+	if names := dirEntries(t, dir); len(names) != 1 {
+		t.Fatalf("wanted the temporary file removed but got %q", names)
+	}
+}
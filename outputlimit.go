@@ -0,0 +1,204 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/google/subcommands"
+)
+
+// OutputCounted is published by LimitOutput when an execution with -max-output-bytes finishes.
+type OutputCounted struct {
+	Command   string
+	Time      time.Time
+	Bytes     int64 // written to Stdout and Stderr, including the discarded ones
+	Truncated bool
+}
+
+// CommandName implements Event.
+func (e OutputCounted) CommandName() string { return e.Command }
+
+// LimitOutputOption is an option of the LimitOutput wrapper.
+type LimitOutputOption interface {
+	applyLimitOutput(*limitOutput)
+}
+
+// limitOutputOptionFunc is a LimitOutputOption implemented by a function.
+type limitOutputOptionFunc func(*limitOutput)
+
+// applyLimitOutput implements LimitOutputOption.
+func (fn limitOutputOptionFunc) applyLimitOutput(c *limitOutput) { fn(c) }
+
+// WithCancelOnOutputLimit makes LimitOutput cancel the execution context once the output is
+// truncated, and fail the execution, instead of letting it finish silently.
+func WithCancelOnOutputLimit() LimitOutputOption {
+	return limitOutputOptionFunc(func(c *limitOutput) {
+		c.cancel = true
+	})
+}
+
+// limitOutput wraps a subcommands.Command so that its output is limited.
+type limitOutput struct {
+	sub    subcommands.Command
+	cancel bool
+
+	limit int64
+}
+
+// make sure limitOutput implements the subcommands.Command interface.
+var _ subcommands.Command = (*limitOutput)(nil)
+
+// LimitOutput wraps sub with the -max-output-bytes flag, 0 for no limit, the default. When set,
+// the output of the execution to Stdout and Stderr, together, is truncated to that many bytes: the
+// further writes are discarded, and MessageOutputTruncated is printed once to the Stderr of the
+// context. The writes keep succeeding, so that sub finishes, unless WithCancelOnOutputLimit is
+// given.
+//
+// The bytes written, and whether they were truncated, are reported by OutputCount during the
+// execution, to the post hook of a Hooks wrapped in LimitOutput, and by an OutputCounted event once
+// it finishes, which ResultFile records.
+func LimitOutput(sub subcommands.Command, opts ...LimitOutputOption) subcommands.Command {
+	c := &limitOutput{
+		sub: sub,
+	}
+	for _, opt := range opts {
+		opt.applyLimitOutput(c)
+	}
+
+	return c
+}
+
+// Name forwards to the underlying c.sub Command.
+func (c *limitOutput) Name() string {
+	return c.sub.Name()
+}
+
+// Usage forwards to the underlying c.sub Command.
+func (c *limitOutput) Usage() string {
+	return c.sub.Usage()
+}
+
+// Synopsis forwards to the underlying c.sub Command.
+func (c *limitOutput) Synopsis() string {
+	return c.sub.Synopsis()
+}
+
+// Unwrap returns the underlying c.sub Command.
+func (c *limitOutput) Unwrap() subcommands.Command {
+	return c.sub
+}
+
+// SetFlags forwards to the underlying c.sub Command and registers the -max-output-bytes flag.
+func (c *limitOutput) SetFlags(f *flag.FlagSet) {
+	c.sub.SetFlags(f)
+	f.Int64Var(&c.limit, "max-output-bytes", 0, "truncate the output after `n` bytes (0 for no limit)")
+}
+
+// Execute forwards to the underlying c.sub Command with the limited output writers.
+func (c *limitOutput) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	if c.limit <= 0 {
+		return c.sub.Execute(ctx, f, args...)
+	}
+
+	l := &outputLimiter{
+		limit:  c.limit,
+		stderr: Stderr(ctx),
+		notice: MessagesFromContext(ctx).Sprintf(MessageOutputTruncated, c.limit),
+	}
+	if c.cancel {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithCancel(ctx)
+		defer cancel()
+		l.onTruncate = cancel
+	}
+	execCtx := WithOutput(context.WithValue(ctx, outputLimiterKey{}, l), &limitedWriter{l: l, w: Stdout(ctx)}, &limitedWriter{l: l, w: l.stderr})
+
+	status := c.sub.Execute(execCtx, f, args...)
+	n, truncated := l.count()
+	publish(ctx, OutputCounted{Command: c.sub.Name(), Time: ClockFromContext(ctx).Now(), Bytes: n, Truncated: truncated})
+	if c.cancel && truncated && status == subcommands.ExitSuccess {
+		return subcommands.ExitFailure
+	}
+
+	return status
+}
+
+// outputLimiterKey is the context key of the outputLimiter of an execution.
+type outputLimiterKey struct{}
+
+// OutputCount returns the bytes written so far to Stdout and Stderr by the execution of ctx,
+// including the discarded ones, and whether they were truncated, in a LimitOutput wrapper with
+// -max-output-bytes. It returns 0 and false otherwise.
+func OutputCount(ctx context.Context) (n int64, truncated bool) {
+	if l, ok := ctx.Value(outputLimiterKey{}).(*outputLimiter); ok {
+		return l.count()
+	}
+
+	return 0, false
+}
+
+// outputLimiter counts the output of an execution, and truncates it after limit bytes.
+type outputLimiter struct {
+	limit      int64
+	stderr     io.Writer
+	notice     string
+	onTruncate func()
+
+	mu        sync.Mutex
+	n         int64
+	truncated bool
+}
+
+// count returns the bytes written to l, and whether they were truncated.
+func (l *outputLimiter) count() (int64, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.n, l.truncated
+}
+
+// write writes p to w, or its part within the limit, printing the notice when it is first
+// exceeded. The discarded bytes are reported written.
+func (l *outputLimiter) write(w io.Writer, p []byte) (int, error) {
+	l.mu.Lock()
+	remaining := l.limit - l.n
+	l.n += int64(len(p))
+	if remaining >= int64(len(p)) {
+		defer l.mu.Unlock()
+		return w.Write(p)
+	}
+
+	if remaining > 0 {
+		w.Write(p[:remaining])
+	}
+	truncating := !l.truncated
+	if truncating {
+		l.truncated = true
+		fmt.Fprintln(l.stderr, l.notice)
+	}
+	l.mu.Unlock()
+
+	if truncating && l.onTruncate != nil {
+		l.onTruncate()
+	}
+
+	return len(p), nil
+}
+
+// limitedWriter is an output writer of an execution limited by an outputLimiter.
+type limitedWriter struct {
+	l *outputLimiter
+	w io.Writer
+}
+
+// Write implements io.Writer.
+func (w *limitedWriter) Write(p []byte) (int, error) {
+	return w.l.write(w.w, p)
+}
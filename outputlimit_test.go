@@ -0,0 +1,136 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+func TestLimitOutput(t *testing.T) {
+	const notice = "[output truncated after 10 bytes]\n"
+
+	tests := map[string]struct {
+		args          []string
+		opts          []subcommandsutil.LimitOutputOption
+		wantStatus    subcommands.ExitStatus
+		wantStdout    string
+		wantStderr    string
+		wantCanceled  bool
+		wantCount     bool
+		wantTruncated bool
+	}{
+		"when the output exceeds the limit": {
+			args:          []string{"-max-output-bytes", "10"},
+			wantStdout:    "hello\n",
+			wantStderr:    "worl" + notice,
+			wantCount:     true,
+			wantTruncated: true,
+		},
+		"when the output fits the limit": {
+			args:       []string{"-max-output-bytes", "18"},
+			wantStdout: "hello\nmore\n",
+			wantStderr: "world!\n",
+			wantCount:  true,
+		},
+		"when the output is not limited": {
+			wantStdout: "hello\nmore\n",
+			wantStderr: "world!\n",
+		},
+		"when the execution is canceled at the limit": {
+			args:          []string{"-max-output-bytes", "10"},
+			opts:          []subcommandsutil.LimitOutputOption{subcommandsutil.WithCancelOnOutputLimit()},
+			wantStatus:    subcommands.ExitFailure,
+			wantStdout:    "hello\n",
+			wantStderr:    "worl" + notice,
+			wantCanceled:  true,
+			wantCount:     true,
+			wantTruncated: true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var canceled bool
+			sub := testcmd.NewRecording("build", testcmd.WithExecute(func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+				fmt.Fprint(subcommandsutil.Stdout(ctx), "hello\n")
+				fmt.Fprint(subcommandsutil.Stderr(ctx), "world!\n")
+				if n, err := fmt.Fprint(subcommandsutil.Stdout(ctx), "more\n"); n != 5 || err != nil {
+					t.Errorf("wanted the discarded write to succeed but got %d, %v", n, err)
+				}
+				fmt.Fprint(subcommandsutil.Stderr(ctx), "")
+				canceled = ctx.Err() != nil
+				return subcommands.ExitSuccess
+			}))
+			var counted []subcommandsutil.OutputCounted
+			bus := subcommandsutil.NewEvents()
+			bus.Subscribe(func(ev subcommandsutil.Event) {
+				if ev, ok := ev.(subcommandsutil.OutputCounted); ok {
+					counted = append(counted, ev)
+				}
+			})
+			var stdout, stderr testcmd.Buffer
+			ctx := subcommandsutil.WithOutput(subcommandsutil.WithEvents(context.Background(), bus), &stdout, &stderr)
+
+			status, _, _ := testcmd.Run(ctx, subcommandsutil.LimitOutput(sub, tt.opts...), tt.args...)
+			testcmd.AssertStatus(t, status, tt.wantStatus)
+			if got := stdout.String(); got != tt.wantStdout {
+				t.Fatalf("wanted the stdout %q but got %q", tt.wantStdout, got)
+			}
+			if got := stderr.String(); got != tt.wantStderr {
+				t.Fatalf("wanted the stderr %q but got %q", tt.wantStderr, got)
+			}
+			if got := strings.Count(stdout.String()+stderr.String(), "[output truncated"); got > 1 {
+				t.Fatalf("wanted the notice once but got it %d times", got)
+			}
+			if canceled != tt.wantCanceled {
+				t.Fatalf("wanted the execution canceled %t but got %t", tt.wantCanceled, canceled)
+			}
+
+			if !tt.wantCount {
+				if len(counted) != 0 {
+					t.Fatalf("wanted no OutputCounted event but got %+v", counted)
+				}
+				return
+			}
+			if len(counted) != 1 {
+				t.Fatalf("wanted an OutputCounted event but got %+v", counted)
+			}
+			if ev := counted[0]; ev.Command != "build" || ev.Bytes != 18 || ev.Truncated != tt.wantTruncated {
+				t.Fatalf("wanted 18 bytes of build truncated %t but got %+v", tt.wantTruncated, ev)
+			}
+		})
+	}
+}
+
+func TestLimitOutputCount(t *testing.T) {
+	sub := testcmd.NewRecording("build", testcmd.WithExecute(func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+		fmt.Fprint(subcommandsutil.Stdout(ctx), strings.Repeat("x", 100))
+		return subcommands.ExitSuccess
+	}))
+	var gotBytes int64
+	var gotTruncated bool
+	post := func(ctx context.Context, status subcommands.ExitStatus) {
+		gotBytes, gotTruncated = subcommandsutil.OutputCount(ctx)
+	}
+	cmd := subcommandsutil.ResultFile(subcommandsutil.LimitOutput(subcommandsutil.Hooks(sub, nil, post)))
+	path := filepath.Join(t.TempDir(), "result.json")
+
+	status, _, _ := testcmd.Run(subcommandsutil.WithOutput(context.Background(), &testcmd.Buffer{}, &testcmd.Buffer{}), cmd, "-result-file", path, "-max-output-bytes", "64")
+	testcmd.RequireSuccess(t, status)
+	if gotBytes != 100 || !gotTruncated {
+		t.Fatalf("wanted the post hook to see 100 bytes truncated but got %d, %t", gotBytes, gotTruncated)
+	}
+	if got := readRunResult(t, path); got.OutputBytes != 100 || !got.OutputTruncated {
+		t.Fatalf("wanted the result to record 100 bytes truncated but got %+v", got)
+	}
+}
@@ -0,0 +1,161 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"context"
+	"flag"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/google/subcommands"
+)
+
+// PagerEnv is the environment variable naming the pager of Paged, with its arguments.
+const PagerEnv = "PAGER"
+
+// defaultPagers are the pagers Paged tries in order when PAGER is not set.
+var defaultPagers = [][]string{
+	{"less", "-FRX"},
+	{"more"},
+}
+
+// PagedOption is an option of the Paged wrapper.
+type PagedOption interface {
+	applyPaged(*paged)
+}
+
+// pagedOptionFunc is a PagedOption implemented by a function.
+type pagedOptionFunc func(*paged)
+
+// applyPaged implements PagedOption.
+func (fn pagedOptionFunc) applyPaged(c *paged) { fn(c) }
+
+// WithPagerTerminal makes Paged page the output as if Stdout was a terminal.
+func WithPagerTerminal() PagedOption {
+	return pagedOptionFunc(func(c *paged) {
+		c.terminal = true
+	})
+}
+
+// paged wraps a subcommands.Command so that its output is paged.
+type paged struct {
+	sub      subcommands.Command
+	terminal bool
+}
+
+// make sure paged implements the subcommands.Command interface.
+var _ subcommands.Command = (*paged)(nil)
+
+// Paged wraps sub so that, when Stdout is a terminal, its Stdout is piped through a pager: the
+// command named by PAGER, or else "less -FRX" or "more". An empty PAGER, or no pager found,
+// disables paging.
+//
+// Paged waits for the pager to exit after sub returns. If the pager exits early, the rest of the
+// output is discarded without failing sub. The pager is killed when the execution context is done.
+func Paged(sub subcommands.Command, opts ...PagedOption) subcommands.Command {
+	c := &paged{
+		sub: sub,
+	}
+	for _, opt := range opts {
+		opt.applyPaged(c)
+	}
+
+	return c
+}
+
+// Name forwards to the underlying c.sub Command.
+func (c *paged) Name() string {
+	return c.sub.Name()
+}
+
+// Usage forwards to the underlying c.sub Command.
+func (c *paged) Usage() string {
+	return c.sub.Usage()
+}
+
+// Synopsis forwards to the underlying c.sub Command.
+func (c *paged) Synopsis() string {
+	return c.sub.Synopsis()
+}
+
+// Unwrap returns the underlying c.sub Command.
+func (c *paged) Unwrap() subcommands.Command {
+	return c.sub
+}
+
+// SetFlags forwards to the underlying c.sub Command.
+func (c *paged) SetFlags(f *flag.FlagSet) {
+	c.sub.SetFlags(f)
+}
+
+// Execute forwards to the underlying c.sub Command with its Stdout piped through the pager.
+func (c *paged) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	stdout := Stdout(ctx)
+	if !c.terminal && !isTerminal(stdout) {
+		return c.sub.Execute(ctx, f, args...)
+	}
+	argv := pagerCommand()
+	if argv == nil {
+		return c.sub.Execute(ctx, f, args...)
+	}
+
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	cmd.Stdout = stdout
+	cmd.Stderr = Stderr(ctx)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return c.sub.Execute(ctx, f, args...)
+	}
+	if err := cmd.Start(); err != nil {
+		return c.sub.Execute(ctx, f, args...)
+	}
+
+	status := c.sub.Execute(WithOutput(ctx, &pagerWriter{w: stdin}, nil), f, args...)
+	stdin.Close()
+	_ = cmd.Wait() // the pager quitting early is not a failure of the command
+
+	return status
+}
+
+// pagerCommand returns the command line of the pager, or nil if there is none.
+func pagerCommand() []string {
+	if pager, ok := os.LookupEnv(PagerEnv); ok {
+		if argv := strings.Fields(pager); len(argv) > 0 {
+			return argv
+		}
+		return nil
+	}
+	for _, argv := range defaultPagers {
+		if _, err := exec.LookPath(argv[0]); err == nil {
+			return argv
+		}
+	}
+
+	return nil
+}
+
+// pagerWriter writes to the pager, and discards the output once the pager stops reading it.
+type pagerWriter struct {
+	mu     sync.Mutex
+	w      io.Writer
+	broken bool
+}
+
+// Write implements io.Writer.
+func (w *pagerWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if !w.broken {
+		if _, err := w.w.Write(p); err != nil {
+			w.broken = true
+		}
+	}
+
+	return len(p), nil
+}
@@ -0,0 +1,112 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+// newListCommand returns a command printing lines numbered lines to Stdout.
+func newListCommand(lines int) *testcmd.Recording {
+	return testcmd.NewRecording("list",
+		testcmd.WithExecute(func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+			for i := 0; i < lines; i++ {
+				if _, err := fmt.Fprintf(subcommandsutil.Stdout(ctx), "line %d\n", i); err != nil {
+					return subcommands.ExitFailure
+				}
+			}
+			return subcommands.ExitSuccess
+		}),
+	)
+}
+
+func TestPaged(t *testing.T) {
+	tests := map[string]struct {
+		pager      string
+		opts       []subcommandsutil.PagedOption
+		lines      int
+		wantStdout string
+	}{
+		"when the pager passes the output through": {
+			pager:      "cat",
+			opts:       []subcommandsutil.PagedOption{subcommandsutil.WithPagerTerminal()},
+			lines:      2,
+			wantStdout: "line 0\nline 1\n",
+		},
+		"when the pager has arguments": {
+			pager:      "head -n 1",
+			opts:       []subcommandsutil.PagedOption{subcommandsutil.WithPagerTerminal()},
+			lines:      2,
+			wantStdout: "line 0\n",
+		},
+		"when the pager exits early": {
+			pager: "true",
+			opts:  []subcommandsutil.PagedOption{subcommandsutil.WithPagerTerminal()},
+			lines: 100000,
+		},
+		"when stdout is not a terminal": {
+			pager:      "true",
+			lines:      2,
+			wantStdout: "line 0\nline 1\n",
+		},
+		"when the pager is disabled": {
+			pager:      "",
+			opts:       []subcommandsutil.PagedOption{subcommandsutil.WithPagerTerminal()},
+			lines:      2,
+			wantStdout: "line 0\nline 1\n",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv(subcommandsutil.PagerEnv, tt.pager)
+
+			var stdout testcmd.Buffer
+			ctx := subcommandsutil.WithOutput(context.Background(), &stdout, nil)
+			status, _, err := testcmd.Run(ctx, subcommandsutil.Paged(newListCommand(tt.lines), tt.opts...))
+			if err != nil {
+				t.Fatal(err)
+			}
+			testcmd.RequireSuccess(t, status)
+			if got := stdout.String(); got != tt.wantStdout {
+				t.Fatalf("wanted stdout %q but got %q", tt.wantStdout, truncate(got))
+			}
+		})
+	}
+}
+
+func TestPagedCanceled(t *testing.T) {
+	t.Setenv(subcommandsutil.PagerEnv, "sleep 10")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	status, _, err := testcmd.Run(subcommandsutil.WithOutput(ctx, &testcmd.Buffer{}, nil),
+		subcommandsutil.Paged(newListCommand(1), subcommandsutil.WithPagerTerminal()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	testcmd.RequireSuccess(t, status)
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("wanted the pager to be killed on cancel but it ran for %v", elapsed)
+	}
+}
+
+// truncate shortens s for a failure message.
+func truncate(s string) string {
+	if len(s) > 64 {
+		return s[:64] + "..."
+	}
+
+	return s
+}
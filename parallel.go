@@ -0,0 +1,214 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/google/subcommands"
+)
+
+// ParallelOption is an option of the Parallel composite.
+type ParallelOption interface {
+	applyParallel(*parallel)
+}
+
+// parallelOptionFunc is a ParallelOption implemented by a function.
+type parallelOptionFunc func(*parallel)
+
+// applyParallel implements ParallelOption.
+func (fn parallelOptionFunc) applyParallel(c *parallel) { fn(c) }
+
+// applyParallel implements ParallelOption. The Logger logs the cancellation of the members.
+func (o LoggerOption) applyParallel(c *parallel) {
+	c.cancelableOpts = append(c.cancelableOpts, o)
+}
+
+// WithConcurrency limits the number of members Parallel runs at once to n. It defaults to 0,
+// running all members at once.
+func WithConcurrency(n int) ParallelOption {
+	return parallelOptionFunc(func(c *parallel) {
+		c.concurrency = n
+	})
+}
+
+// WithRunAll makes Parallel run all members to completion even when one of them fails, instead of
+// canceling the others.
+func WithRunAll() ParallelOption {
+	return parallelOptionFunc(func(c *parallel) {
+		c.runAll = true
+	})
+}
+
+// AggregatorOption is an option setting the StatusAggregator of a composite command. It is accepted
+// by Parallel and Sequence.
+type AggregatorOption struct {
+	aggregate StatusAggregator
+}
+
+// WithAggregator returns an option setting the StatusAggregator combining the statuses of the
+// members run by a composite command.
+func WithAggregator(agg StatusAggregator) AggregatorOption {
+	return AggregatorOption{aggregate: agg}
+}
+
+// applyParallel implements ParallelOption.
+func (o AggregatorOption) applyParallel(c *parallel) {
+	c.aggregate = o.aggregate
+}
+
+// parallel is a subcommands.Command running several commands concurrently.
+type parallel struct {
+	name     string
+	synopsis string
+	members  []CancelableCommand
+
+	concurrency    int
+	runAll         bool
+	aggregate      StatusAggregator
+	cancelableOpts []CancelableOption
+
+	executing executing
+}
+
+// make sure parallel implements the subcommands.Command interface.
+var _ subcommands.Command = (*parallel)(nil)
+
+// Parallel returns a command named name which runs members concurrently. Each member parses the
+// positional arguments of the command with its own FlagSet, and runs in Cancelable. When a member
+// returns a status other than subcommands.ExitSuccess, the others are canceled unless WithRunAll is
+// given; canceled members are disposed, and members not started yet are skipped. The statuses of
+// the members run are combined by the StatusAggregator set by WithAggregator, WorstStatus by
+// default.
+//
+// The lines written by each member to Stdout and Stderr are prefixed with the name of the member in
+// brackets, so that the lines of concurrent members do not interleave.
+func Parallel(name, synopsis string, members []CancelableCommand, opts ...ParallelOption) subcommands.Command {
+	c := &parallel{
+		name:      name,
+		synopsis:  synopsis,
+		members:   members,
+		aggregate: WorstStatus,
+	}
+	for _, opt := range opts {
+		opt.applyParallel(c)
+	}
+
+	return c
+}
+
+// Name implements subcommands.Command.
+func (c *parallel) Name() string {
+	return c.name
+}
+
+// Synopsis implements subcommands.Command.
+func (c *parallel) Synopsis() string {
+	return c.synopsis
+}
+
+// Usage implements subcommands.Command. It lists the members.
+func (c *parallel) Usage() string {
+	return fmt.Sprintf("%s [args...]:\n  %s\n\nRuns concurrently:\n%s", c.name, c.synopsis, listMembers(c.members))
+}
+
+// SetFlags implements subcommands.Command. The members parse their own flags from the positional
+// arguments.
+func (c *parallel) SetFlags(f *flag.FlagSet) {}
+
+// Execute runs the members concurrently and returns their aggregated status.
+func (c *parallel) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	if !c.executing.enter(ctx, stdLogger{}, c.name) {
+		return subcommands.ExitFailure
+	}
+	defer c.executing.exit()
+
+	flagSets, status := memberFlagSets(ctx, c.members, f.Args())
+	if status != subcommands.ExitSuccess {
+		return status
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	limit := c.concurrency
+	if limit <= 0 {
+		limit = len(c.members)
+	}
+	sem := make(chan struct{}, limit)
+
+	var stdoutMu, stderrMu sync.Mutex
+	statuses := make([]subcommands.ExitStatus, len(c.members))
+	started := make([]bool, len(c.members))
+	var wg sync.WaitGroup
+	for i, member := range c.members {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		started[i] = true
+
+		wg.Add(1)
+		go func(i int, member CancelableCommand) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			prefix := "[" + member.Name() + "] "
+			stdout := &prefixWriter{mu: &stdoutMu, w: Stdout(ctx), prefix: prefix}
+			stderr := &prefixWriter{mu: &stderrMu, w: Stderr(ctx), prefix: prefix}
+			mctx := WithOutput(ctx, stdout, stderr)
+
+			statuses[i] = Cancelable(member, c.cancelableOpts...).Execute(mctx, flagSets[i], args...)
+			stdout.Flush()
+			stderr.Flush()
+			if statuses[i] != subcommands.ExitSuccess && !c.runAll {
+				cancel()
+			}
+		}(i, member)
+	}
+	wg.Wait()
+
+	var run []subcommands.ExitStatus
+	for i, s := range statuses {
+		if started[i] {
+			run = append(run, s)
+		}
+	}
+
+	return c.aggregate(run)
+}
+
+// memberFlagSets returns a FlagSet per member of a composite command, each parsed from args. It
+// returns subcommands.ExitUsageError if one of the members cannot parse args.
+func memberFlagSets(ctx context.Context, members []CancelableCommand, args []string) ([]*flag.FlagSet, subcommands.ExitStatus) {
+	flagSets := make([]*flag.FlagSet, len(members))
+	for i, member := range members {
+		mf := flag.NewFlagSet(member.Name(), flag.ContinueOnError)
+		mf.SetOutput(Stderr(ctx))
+		member.SetFlags(mf)
+		if err := mf.Parse(args); err != nil {
+			return nil, subcommands.ExitUsageError
+		}
+		flagSets[i] = mf
+	}
+
+	return flagSets, subcommands.ExitSuccess
+}
+
+// listMembers lists the names and synopses of the members of a composite command.
+func listMembers(members []CancelableCommand) string {
+	var b strings.Builder
+	for _, member := range members {
+		fmt.Fprintf(&b, "\t%-15s  %s\n", member.Name(), member.Synopsis())
+	}
+
+	return b.String()
+}
@@ -0,0 +1,150 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+// newPrinting returns a command printing its name and positional arguments in two writes.
+func newPrinting(name string) *testcmd.Recording {
+	return testcmd.NewRecording(name,
+		testcmd.WithSynopsis(name+" the tree"),
+		testcmd.WithExecute(func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+			fmt.Fprint(subcommandsutil.Stdout(ctx), name)
+			fmt.Fprintln(subcommandsutil.Stdout(ctx), "", strings.Join(f.Args(), " "))
+			fmt.Fprint(subcommandsutil.Stderr(ctx), "no newline")
+			return subcommands.ExitSuccess
+		}),
+	)
+}
+
+func TestParallel(t *testing.T) {
+	defer testcmd.VerifyNoLeaks(t)
+
+	cmd := subcommandsutil.Parallel("check", "run the checks", []subcommandsutil.CancelableCommand{newPrinting("lint"), newPrinting("vet")})
+
+	var stdout, stderr testcmd.Buffer
+	ctx := subcommandsutil.WithOutput(context.Background(), &stdout, &stderr)
+	status, _, err := testcmd.Run(ctx, cmd, "./...")
+	if err != nil {
+		t.Fatal(err)
+	}
+	testcmd.RequireSuccess(t, status)
+
+	lines := strings.Split(strings.TrimSuffix(stdout.String(), "\n"), "\n")
+	sort.Strings(lines)
+	if want := []string{"[lint] lint ./...", "[vet] vet ./..."}; strings.Join(lines, "|") != strings.Join(want, "|") {
+		t.Fatalf("wanted lines %q but got %q", want, lines)
+	}
+	if got := strings.Count(stderr.String(), "] no newline\n"); got != 2 {
+		t.Fatalf("wanted the incomplete lines flushed but got %q", stderr.String())
+	}
+	if usage := cmd.Usage(); !strings.Contains(usage, "lint             lint the tree") || !strings.Contains(usage, "vet              vet the tree") {
+		t.Fatalf("wanted the usage to list the members but got %q", usage)
+	}
+}
+
+func TestParallelFailFast(t *testing.T) {
+	defer testcmd.VerifyNoLeaks(t)
+
+	slow := testcmd.NewBlocking("slow")
+	defer slow.Release(subcommands.ExitSuccess)
+	failing := testcmd.NewRecording("failing", testcmd.WithExecute(func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+		<-slow.Started()
+		return subcommands.ExitFailure
+	}))
+
+	var logs testcmd.LogRecorder
+	cmd := subcommandsutil.Parallel("check", "run the checks", []subcommandsutil.CancelableCommand{slow, failing}, subcommandsutil.WithLogger(&logs))
+	status, _, err := testcmd.Run(subcommandsutil.WithOutput(context.Background(), &testcmd.Buffer{}, &testcmd.Buffer{}), cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testcmd.AssertStatus(t, status, subcommands.ExitFailure)
+	if got := slow.DisposeCount(); got != 1 {
+		t.Fatalf("wanted the canceled member disposed once but got %d", got)
+	}
+	if !logs.Contains("slow: context canceled") {
+		t.Fatalf("wanted the cancellation logged but got %q", logs.Lines())
+	}
+}
+
+func TestParallelRunAll(t *testing.T) {
+	defer testcmd.VerifyNoLeaks(t)
+
+	ok := testcmd.NewRecording("ok", testcmd.WithDelay(10*time.Millisecond))
+	failing := testcmd.NewRecording("failing", testcmd.WithStatus(subcommands.ExitUsageError))
+	cmd := subcommandsutil.Parallel("check", "run the checks", []subcommandsutil.CancelableCommand{ok, failing},
+		subcommandsutil.WithRunAll(), subcommandsutil.WithAggregator(subcommandsutil.FirstFailure))
+
+	status, _, err := testcmd.Run(context.Background(), cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testcmd.AssertStatus(t, status, subcommands.ExitUsageError)
+	if !ok.DidFinish() {
+		t.Fatal("wanted every member to run to completion")
+	}
+	if got := ok.DisposeCount(); got != 0 {
+		t.Fatalf("wanted no member disposed but got %d", got)
+	}
+}
+
+func TestParallelConcurrency(t *testing.T) {
+	defer testcmd.VerifyNoLeaks(t)
+
+	var running, maxRunning int32
+	var members []subcommandsutil.CancelableCommand
+	for i := 0; i < 6; i++ {
+		members = append(members, testcmd.NewRecording(fmt.Sprintf("m%d", i), testcmd.WithExecute(func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+			n := atomic.AddInt32(&running, 1)
+			defer atomic.AddInt32(&running, -1)
+			for {
+				m := atomic.LoadInt32(&maxRunning)
+				if n <= m || atomic.CompareAndSwapInt32(&maxRunning, m, n) {
+					break
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+			return subcommands.ExitSuccess
+		})))
+	}
+
+	cmd := subcommandsutil.Parallel("check", "run the checks", members, subcommandsutil.WithConcurrency(2))
+	status, _, err := testcmd.Run(context.Background(), cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testcmd.RequireSuccess(t, status)
+	if got := atomic.LoadInt32(&maxRunning); got < 1 || got > 2 {
+		t.Fatalf("wanted at most 2 members running at once but got %d", got)
+	}
+}
+
+func TestParallelMemberUsageError(t *testing.T) {
+	cmd := subcommandsutil.Parallel("check", "run the checks", []subcommandsutil.CancelableCommand{newPrinting("lint")})
+
+	var stderr testcmd.Buffer
+	status, _, err := testcmd.Run(subcommandsutil.WithOutput(context.Background(), nil, &stderr), cmd, "--", "-unknown")
+	if err != nil {
+		t.Fatal(err)
+	}
+	testcmd.AssertStatus(t, status, subcommands.ExitUsageError)
+	if !strings.Contains(stderr.String(), "flag provided but not defined: -unknown") {
+		t.Fatalf("wanted the parse error reported but got %q", stderr.String())
+	}
+}
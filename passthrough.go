@@ -0,0 +1,148 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"context"
+	"flag"
+	"slices"
+
+	"github.com/google/subcommands"
+)
+
+// SplitPassthrough splits args at the first "--". own are the arguments before it, and passthrough
+// are the arguments after it, verbatim. passthrough is nil if args contains no "--", and empty if
+// "--" is the last argument.
+func SplitPassthrough(args []string) (own, passthrough []string) {
+	for i, arg := range args {
+		if arg == "--" {
+			return args[:i:i], append([]string{}, args[i+1:]...)
+		}
+	}
+
+	return args, nil
+}
+
+// passthroughKey is the context key of the passthrough arguments.
+type passthroughKey struct{}
+
+// WithPassthrough returns a copy of ctx carrying the passthrough arguments args.
+//
+// It is meant for the programs dispatching the commands themselves, rather than through Run, Main
+// or a CancelableCommander, which split their argv before parsing the top-level flags:
+//
+//	own, passthrough := subcommandsutil.SplitPassthrough(os.Args[1:])
+//	flag.CommandLine.Parse(own)
+//	ctx := subcommandsutil.WithPassthrough(context.Background(), passthrough)
+func WithPassthrough(ctx context.Context, args []string) context.Context {
+	return context.WithValue(ctx, passthroughKey{}, args)
+}
+
+// PassthroughFromContext returns the passthrough arguments carried by ctx, or nil.
+func PassthroughFromContext(ctx context.Context) []string {
+	args, _ := ctx.Value(passthroughKey{}).([]string)
+
+	return args
+}
+
+// passthrough wraps a subcommands.Command so that the arguments after "--" are kept away from it.
+type passthrough struct {
+	sub subcommands.Command
+}
+
+// make sure passthrough implements the subcommands.Command interface.
+var _ subcommands.Command = (*passthrough)(nil)
+
+// Passthrough wraps sub so that the positional arguments after the first "--" are removed from
+// f.Args() and made available through PassthroughFromContext. If ctx already carries passthrough
+// arguments, for example from WithPassthrough, they are kept as is.
+//
+// The arguments are split before the FlagSet of sub parsed them, which consumes a "--" directly
+// following the flags, when the command is dispatched by Run, Main, a CancelableCommander or a
+// Group: "run -- go test ./..." passes "go test ./...", and "run -v -- a -- b" passes "a -- b".
+// Otherwise, only the positional arguments of f are split.
+func Passthrough(sub subcommands.Command) subcommands.Command {
+	return &passthrough{
+		sub: sub,
+	}
+}
+
+// Name forwards to the underlying c.sub Command.
+func (c *passthrough) Name() string {
+	return c.sub.Name()
+}
+
+// Usage forwards to the underlying c.sub Command.
+func (c *passthrough) Usage() string {
+	return c.sub.Usage()
+}
+
+// Synopsis forwards to the underlying c.sub Command.
+func (c *passthrough) Synopsis() string {
+	return c.sub.Synopsis()
+}
+
+// SetFlags forwards to the underlying c.sub Command.
+func (c *passthrough) SetFlags(f *flag.FlagSet) {
+	c.sub.SetFlags(f)
+}
+
+// Unwrap returns the underlying c.sub Command.
+func (c *passthrough) Unwrap() subcommands.Command {
+	return c.sub
+}
+
+// Execute splits the passthrough arguments off f and forwards to the underlying c.sub Command.
+func (c *passthrough) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	if PassthroughFromContext(ctx) == nil {
+		own, pass := splitDispatchArgs(ctx, f)
+		if pass != nil {
+			// a leading "--" keeps the positional arguments from being parsed as flags again
+			if err := f.Parse(append([]string{"--"}, own...)); err != nil {
+				return subcommands.ExitUsageError
+			}
+			ctx = WithPassthrough(ctx, pass)
+		}
+	}
+
+	return c.sub.Execute(ctx, f, args...)
+}
+
+// dispatchArgsKey is the context key of the arguments of the dispatched command.
+type dispatchArgsKey struct{}
+
+// withDispatchArgs returns a copy of ctx carrying the arguments following the name of the command
+// in the arguments of top, before the FlagSet of the command parses them.
+func withDispatchArgs(ctx context.Context, top *flag.FlagSet) context.Context {
+	argv := []string{}
+	if top.NArg() > 1 {
+		argv = top.Args()[1:]
+	}
+
+	return context.WithValue(ctx, dispatchArgsKey{}, argv)
+}
+
+// splitDispatchArgs splits the positional arguments of f like SplitPassthrough, at the first "--"
+// of the arguments of the dispatch carried by ctx if f parsed them, since Parse consumes a "--"
+// directly following the flags.
+func splitDispatchArgs(ctx context.Context, f *flag.FlagSet) (own, passthrough []string) {
+	args := f.Args()
+	argv, ok := ctx.Value(dispatchArgsKey{}).([]string)
+	// the positional arguments of f are the end of argv, unless f parsed other arguments
+	if !ok || len(argv) < len(args) || !slices.Equal(argv[len(argv)-len(args):], args) {
+		return SplitPassthrough(args)
+	}
+
+	_, passthrough = SplitPassthrough(argv)
+	if passthrough == nil || len(passthrough) > len(args) {
+		return SplitPassthrough(args)
+	}
+	own = args[:len(args)-len(passthrough)]
+	// the "--" is left in the positional arguments when it follows one of them
+	if n := len(own); n > 0 && own[n-1] == "--" {
+		own = own[:n-1]
+	}
+
+	return own, passthrough
+}
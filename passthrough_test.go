@@ -0,0 +1,178 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"context"
+	"flag"
+	"reflect"
+	"testing"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+func TestSplitPassthrough(t *testing.T) {
+	tests := map[string]struct {
+		args            []string
+		wantOwn         []string
+		wantPassthrough []string
+	}{
+		"when there is no separator": {
+			args:            []string{"-v", "run"},
+			wantOwn:         []string{"-v", "run"},
+			wantPassthrough: nil,
+		},
+		"when there is a separator": {
+			args:            []string{"-v", "run", "--", "go", "test", "./..."},
+			wantOwn:         []string{"-v", "run"},
+			wantPassthrough: []string{"go", "test", "./..."},
+		},
+		"when there are multiple separators": {
+			args:            []string{"run", "--", "sh", "-c", "--", "x"},
+			wantOwn:         []string{"run"},
+			wantPassthrough: []string{"sh", "-c", "--", "x"},
+		},
+		"when the separator is the last argument": {
+			args:            []string{"run", "--"},
+			wantOwn:         []string{"run"},
+			wantPassthrough: []string{},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			own, passthrough := subcommandsutil.SplitPassthrough(tt.args)
+			if !reflect.DeepEqual(own, tt.wantOwn) {
+				t.Fatalf("wanted own %q but got %q", tt.wantOwn, own)
+			}
+			if !reflect.DeepEqual(passthrough, tt.wantPassthrough) {
+				t.Fatalf("wanted passthrough %#v but got %#v", tt.wantPassthrough, passthrough)
+			}
+		})
+	}
+}
+
+func TestPassthrough(t *testing.T) {
+	tests := map[string]struct {
+		ctx             context.Context
+		args            []string
+		wantArgs        []string
+		wantPassthrough []string
+	}{
+		"when the separator follows a positional argument": {
+			ctx:             context.Background(),
+			args:            []string{"-v", "target", "--", "-x", "--", "y"},
+			wantArgs:        []string{"target"},
+			wantPassthrough: []string{"-x", "--", "y"},
+		},
+		"when the context already carries passthrough arguments": {
+			ctx:             subcommandsutil.WithPassthrough(context.Background(), []string{"go", "test"}),
+			args:            []string{"target", "-x"},
+			wantArgs:        []string{"target", "-x"},
+			wantPassthrough: []string{"go", "test"},
+		},
+		"when there is no separator": {
+			ctx:             context.Background(),
+			args:            []string{"target", "-x"},
+			wantArgs:        []string{"target", "-x"},
+			wantPassthrough: nil,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var gotArgs, gotPassthrough []string
+			cmd := subcommandsutil.Passthrough(testcmd.NewRecording("run",
+				testcmd.WithFlags(func(f *flag.FlagSet) {
+					f.Bool("v", false, "verbose output")
+				}),
+				testcmd.WithExecute(func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+					gotArgs = f.Args()
+					gotPassthrough = subcommandsutil.PassthroughFromContext(ctx)
+					return subcommands.ExitSuccess
+				}),
+			))
+
+			f := flag.NewFlagSet("run", flag.ContinueOnError)
+			cmd.SetFlags(f)
+			if err := f.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+			if status := cmd.Execute(tt.ctx, f); status != subcommands.ExitSuccess {
+				t.Fatalf("wanted %v but got %v", subcommands.ExitSuccess, status)
+			}
+
+			if !reflect.DeepEqual(gotArgs, tt.wantArgs) {
+				t.Fatalf("wanted args %q but got %q", tt.wantArgs, gotArgs)
+			}
+			if !reflect.DeepEqual(gotPassthrough, tt.wantPassthrough) {
+				t.Fatalf("wanted passthrough %q but got %q", tt.wantPassthrough, gotPassthrough)
+			}
+		})
+	}
+}
+
+func TestPassthroughDispatched(t *testing.T) {
+	tests := map[string]struct {
+		args            []string
+		wantArgs        []string
+		wantPassthrough []string
+	}{
+		"when the separator directly follows the command": {
+			args:            []string{"run", "--", "go", "test", "./..."},
+			wantArgs:        []string{},
+			wantPassthrough: []string{"go", "test", "./..."},
+		},
+		"when the separator is the last argument": {
+			args:            []string{"run", "--"},
+			wantArgs:        []string{},
+			wantPassthrough: []string{},
+		},
+		"when the separator directly follows the flags": {
+			args:            []string{"run", "-v", "--", "a", "--", "b"},
+			wantArgs:        []string{},
+			wantPassthrough: []string{"a", "--", "b"},
+		},
+		"when the separator follows a positional argument": {
+			args:            []string{"run", "-v", "target", "--", "a", "--", "b"},
+			wantArgs:        []string{"target"},
+			wantPassthrough: []string{"a", "--", "b"},
+		},
+		"when there is no separator": {
+			args:            []string{"run", "-v", "target"},
+			wantArgs:        []string{"target"},
+			wantPassthrough: nil,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			defer subcommandsutil.SetExit(func(code int) { t.Fatalf("wanted Run not to exit but got %d", code) }, &testcmd.LogRecorder{})()
+
+			var gotArgs, gotPassthrough []string
+			top := flag.NewFlagSet("mytool", flag.ContinueOnError)
+			cdr := subcommandsutil.NewCancelableCommander(top, "mytool")
+			cdr.Register(subcommandsutil.Passthrough(testcmd.NewRecording("run",
+				testcmd.WithFlags(func(f *flag.FlagSet) {
+					f.Bool("v", false, "verbose output")
+				}),
+				testcmd.WithExecute(func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+					gotArgs = f.Args()
+					gotPassthrough = subcommandsutil.PassthroughFromContext(ctx)
+					return subcommands.ExitSuccess
+				}),
+			)), "")
+
+			if code := subcommandsutil.Run(context.Background(), cdr, tt.args, subcommandsutil.WithTopFlags(top), subcommandsutil.WithSignals()); code != 0 {
+				t.Fatalf("wanted the exit code 0 but got %d", code)
+			}
+			if !reflect.DeepEqual(gotArgs, tt.wantArgs) {
+				t.Fatalf("wanted args %q but got %q", tt.wantArgs, gotArgs)
+			}
+			if !reflect.DeepEqual(gotPassthrough, tt.wantPassthrough) {
+				t.Fatalf("wanted passthrough %#v but got %#v", tt.wantPassthrough, gotPassthrough)
+			}
+		})
+	}
+}
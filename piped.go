@@ -0,0 +1,163 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/google/subcommands"
+)
+
+// StdinIsTTY reports whether os.Stdin is a terminal, rather than a pipe or a file.
+func StdinIsTTY() bool {
+	return isTerminal(os.Stdin)
+}
+
+// StdoutIsTTY reports whether os.Stdout is a terminal, rather than a pipe or a file.
+func StdoutIsTTY() bool {
+	return isTerminal(os.Stdout)
+}
+
+// pipedInputKey is the context key of the input piped to the command.
+type pipedInputKey struct{}
+
+// PipedInput returns the input piped to the command executed with ctx, read by a Piped wrapper. It
+// reports false if the input is a terminal, is empty, or no Piped wrapper read it.
+func PipedInput(ctx context.Context) ([]byte, bool) {
+	data, ok := ctx.Value(pipedInputKey{}).([]byte)
+
+	return data, ok
+}
+
+// PipedOption is an option of the Piped wrapper.
+type PipedOption interface {
+	applyPiped(*piped)
+}
+
+// pipedOptionFunc is a PipedOption implemented by a function.
+type pipedOptionFunc func(*piped)
+
+// applyPiped implements PipedOption.
+func (fn pipedOptionFunc) applyPiped(c *piped) { fn(c) }
+
+// WithPipedStdin makes Piped read the input from r instead of os.Stdin. r is treated as piped
+// unless it is a terminal.
+func WithPipedStdin(r io.Reader) PipedOption {
+	return pipedOptionFunc(func(c *piped) {
+		c.stdin = r
+	})
+}
+
+// WithPipedLimit sets the maximum size of the piped input, in bytes. It defaults to 32 MiB.
+func WithPipedLimit(n int64) PipedOption {
+	return pipedOptionFunc(func(c *piped) {
+		c.limit = n
+	})
+}
+
+// piped wraps a subcommands.Command so that the input piped to it is read before it executes.
+type piped struct {
+	sub   subcommands.Command
+	stdin io.Reader
+	limit int64
+}
+
+// make sure piped implements the subcommands.Command interface.
+var _ subcommands.Command = (*piped)(nil)
+
+// Piped wraps sub so that, when stdin is not a terminal, as in "cat ids.txt | tool delete", it is
+// read before sub executes and returned by PipedInput, letting sub accept its input either as
+// arguments or piped:
+//
+//	ids := f.Args()
+//	if data, ok := subcommandsutil.PipedInput(ctx); ok {
+//		ids = strings.Fields(string(data))
+//	}
+//
+// sub is not run, and subcommands.ExitFailure is returned, if the input exceeds the limit set by
+// WithPipedLimit, if reading it fails, or if the execution context is done before it is read.
+func Piped(sub subcommands.Command, opts ...PipedOption) subcommands.Command {
+	c := &piped{
+		sub:   sub,
+		stdin: os.Stdin,
+		limit: 32 << 20,
+	}
+	for _, opt := range opts {
+		opt.applyPiped(c)
+	}
+
+	return c
+}
+
+// Name forwards to the underlying c.sub Command.
+func (c *piped) Name() string {
+	return c.sub.Name()
+}
+
+// Usage forwards to the underlying c.sub Command.
+func (c *piped) Usage() string {
+	return c.sub.Usage()
+}
+
+// Synopsis forwards to the underlying c.sub Command.
+func (c *piped) Synopsis() string {
+	return c.sub.Synopsis()
+}
+
+// Unwrap returns the underlying c.sub Command.
+func (c *piped) Unwrap() subcommands.Command {
+	return c.sub
+}
+
+// SetFlags forwards to the underlying c.sub Command.
+func (c *piped) SetFlags(f *flag.FlagSet) {
+	c.sub.SetFlags(f)
+}
+
+// Execute reads the piped input, if any, and forwards to the underlying c.sub Command.
+func (c *piped) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	if isTerminal(c.stdin) {
+		return c.sub.Execute(ctx, f, args...)
+	}
+
+	data, err := readPiped(ctx, c.stdin, c.limit)
+	if err != nil {
+		fmt.Fprintf(Stderr(ctx), "%s: reading stdin: %v\n", c.sub.Name(), err)
+		return subcommands.ExitFailure
+	}
+	if len(data) > 0 {
+		ctx = context.WithValue(ctx, pipedInputKey{}, data)
+	}
+
+	return c.sub.Execute(ctx, f, args...)
+}
+
+// readPiped reads r up to limit bytes, or fails if it is larger. It returns the error of ctx if it
+// is done first, abandoning the read.
+func readPiped(ctx context.Context, r io.Reader, limit int64) ([]byte, error) {
+	type result struct {
+		data []byte
+		err  error
+	}
+	// buffered so that the goroutine exits once the read returns even after ctx is done
+	ch := make(chan result, 1)
+	go func() {
+		data, err := io.ReadAll(io.LimitReader(r, limit+1))
+		if err == nil && int64(len(data)) > limit {
+			err = fmt.Errorf("input exceeds %s", FormatSize(limit))
+		}
+		ch <- result{data: data, err: err}
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case res := <-ch:
+		return res.data, res.err
+	}
+}
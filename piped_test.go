@@ -0,0 +1,133 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+// pipedPrinter returns a command printing its piped input, or its arguments.
+func pipedPrinter() *testcmd.Recording {
+	return testcmd.NewRecording("delete", testcmd.WithExecute(func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+		ids := f.Args()
+		if data, ok := subcommandsutil.PipedInput(ctx); ok {
+			ids = strings.Fields(string(data))
+		}
+		fmt.Fprintf(subcommandsutil.Stdout(ctx), "deleting %s\n", strings.Join(ids, ","))
+		return subcommands.ExitSuccess
+	}))
+}
+
+func TestPiped(t *testing.T) {
+	tests := map[string]struct {
+		stdin      string
+		limit      int64
+		args       []string
+		wantStatus subcommands.ExitStatus
+		wantStdout string
+		wantStderr string
+	}{
+		"when the input is piped": {
+			stdin:      "1\n2\n3\n",
+			wantStdout: "deleting 1,2,3\n",
+		},
+		"when nothing is piped": {
+			args:       []string{"4", "5"},
+			wantStdout: "deleting 4,5\n",
+		},
+		"when the input fits the limit": {
+			stdin:      "1 2",
+			limit:      3,
+			wantStdout: "deleting 1,2\n",
+		},
+		"when the input exceeds the limit": {
+			stdin:      "1 2 3",
+			limit:      3,
+			wantStatus: subcommands.ExitFailure,
+			wantStderr: "delete: reading stdin: input exceeds 3B\n",
+		},
+	}
+	for name, tt := range tests {
+		tt := tt
+		t.Run(name, func(t *testing.T) {
+			opts := []subcommandsutil.PipedOption{subcommandsutil.WithPipedStdin(strings.NewReader(tt.stdin))}
+			if tt.limit > 0 {
+				opts = append(opts, subcommandsutil.WithPipedLimit(tt.limit))
+			}
+			sub := pipedPrinter()
+			cmd := subcommandsutil.Piped(sub, opts...)
+
+			var stdout, stderr testcmd.Buffer
+			status, _, _ := testcmd.Run(subcommandsutil.WithOutput(context.Background(), &stdout, &stderr), cmd, tt.args...)
+			testcmd.AssertStatus(t, status, tt.wantStatus)
+			if got := stdout.String(); got != tt.wantStdout {
+				t.Fatalf("wanted the output %q but got %q", tt.wantStdout, got)
+			}
+			if got := stderr.String(); got != tt.wantStderr {
+				t.Fatalf("wanted the errors %q but got %q", tt.wantStderr, got)
+			}
+		})
+	}
+}
+
+func TestPipedCanceled(t *testing.T) {
+	defer testcmd.VerifyNoLeaks(t)
+
+	r, w := io.Pipe()
+	defer w.Close()
+	sub := pipedPrinter()
+	cmd := subcommandsutil.Piped(sub, subcommandsutil.WithPipedStdin(r))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		w.Write([]byte("1\n"))
+		cancel()
+	}()
+	var stderr testcmd.Buffer
+	status, _, _ := testcmd.Run(subcommandsutil.WithOutput(ctx, &testcmd.Buffer{}, &stderr), cmd)
+	testcmd.AssertStatus(t, status, subcommands.ExitFailure)
+	if want := "delete: reading stdin: context canceled\n"; stderr.String() != want {
+		t.Fatalf("wanted the errors %q but got %q", want, stderr.String())
+	}
+	if got := sub.CallCount(); got != 0 {
+		t.Fatalf("wanted no execution but got %d", got)
+	}
+}
+
+func TestPipedInput(t *testing.T) {
+	if data, ok := subcommandsutil.PipedInput(context.Background()); ok {
+		t.Fatalf("wanted no piped input but got %q", data)
+	}
+}
+
+func TestIsTTY(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	defer w.Close()
+
+	stdin, stdout := os.Stdin, os.Stdout
+	os.Stdin, os.Stdout = r, w
+	defer func() { os.Stdin, os.Stdout = stdin, stdout }()
+
+	if subcommandsutil.StdinIsTTY() {
+		t.Fatal("wanted a piped stdin not to be a terminal")
+	}
+	if subcommandsutil.StdoutIsTTY() {
+		t.Fatal("wanted a piped stdout not to be a terminal")
+	}
+}
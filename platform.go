@@ -0,0 +1,152 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"runtime"
+	"strings"
+
+	"github.com/google/subcommands"
+)
+
+// platform returns the operating system and architecture the program runs on.
+var platform = func() (goos, goarch string) {
+	return runtime.GOOS, runtime.GOARCH
+}
+
+// PlatformOption is an option of the OnlyOn, OnlyOnArch and OnlyWhere wrappers.
+type PlatformOption interface {
+	applyPlatform(*platformGated)
+}
+
+// platformOptionFunc is a PlatformOption implemented by a function.
+type platformOptionFunc func(*platformGated)
+
+// applyPlatform implements PlatformOption.
+func (fn platformOptionFunc) applyPlatform(c *platformGated) { fn(c) }
+
+// WithHiddenOnUnsupported hides the command, as reported by IsHiddenCommand, on the unsupported
+// platforms.
+func WithHiddenOnUnsupported() PlatformOption {
+	return platformOptionFunc(func(c *platformGated) {
+		c.hide = true
+	})
+}
+
+// platformGated wraps a subcommands.Command so that it executes only on the platforms it supports.
+type platformGated struct {
+	sub       subcommands.Command
+	supported func(goos, goarch string) bool
+	platforms string
+	hide      bool
+}
+
+// make sure platformGated implements the subcommands.Command interface.
+var _ subcommands.Command = (*platformGated)(nil)
+
+// OnlyOn wraps sub so that it executes only on the operating systems goos, values of runtime.GOOS
+// like "linux", like OnlyWhere with SupportedOS.
+func OnlyOn(sub subcommands.Command, goos ...string) subcommands.Command {
+	return OnlyWhere(sub, SupportedOS(goos...), strings.Join(goos, ", "))
+}
+
+// OnlyOnArch wraps sub so that it executes only on the architectures goarch, values of
+// runtime.GOARCH like "amd64", like OnlyWhere with SupportedArch.
+func OnlyOnArch(sub subcommands.Command, goarch ...string) subcommands.Command {
+	return OnlyWhere(sub, SupportedArch(goarch...), strings.Join(goarch, ", "))
+}
+
+// SupportedOS returns a predicate for OnlyWhere reporting whether the operating system is one of
+// goos.
+func SupportedOS(goos ...string) func(goos, goarch string) bool {
+	return func(os, _ string) bool {
+		return containsString(goos, os)
+	}
+}
+
+// SupportedArch returns a predicate for OnlyWhere reporting whether the architecture is one of
+// goarch.
+func SupportedArch(goarch ...string) func(goos, goarch string) bool {
+	return func(_, arch string) bool {
+		return containsString(goarch, arch)
+	}
+}
+
+// OnlyWhere wraps sub so that it executes only on the platforms for which supported reports true,
+// described by platforms, like "linux". On the other ones, its executions print "the 'NAME'
+// command is only supported on PLATFORMS", or the MessageUnsupportedPlatform of the Messages of the
+// execution, and return subcommands.ExitUsageError; with WithHiddenOnUnsupported, it is also
+// hidden from the listings.
+func OnlyWhere(sub subcommands.Command, supported func(goos, goarch string) bool, platforms string, opts ...PlatformOption) subcommands.Command {
+	c := &platformGated{
+		sub:       sub,
+		supported: supported,
+		platforms: platforms,
+	}
+	for _, opt := range opts {
+		opt.applyPlatform(c)
+	}
+
+	return c
+}
+
+// Name forwards to the underlying c.sub Command.
+func (c *platformGated) Name() string {
+	return c.sub.Name()
+}
+
+// Usage forwards to the underlying c.sub Command.
+func (c *platformGated) Usage() string {
+	return c.sub.Usage()
+}
+
+// Synopsis forwards to the underlying c.sub Command.
+func (c *platformGated) Synopsis() string {
+	return c.sub.Synopsis()
+}
+
+// Hidden reports that the command is hidden on the unsupported platforms with
+// WithHiddenOnUnsupported, and whether the underlying c.sub Command is hidden otherwise.
+func (c *platformGated) Hidden() bool {
+	return (c.hide && !c.isSupported()) || IsHiddenCommand(c.sub)
+}
+
+// Unwrap returns the underlying c.sub Command.
+func (c *platformGated) Unwrap() subcommands.Command {
+	return c.sub
+}
+
+// SetFlags forwards to the underlying c.sub Command.
+func (c *platformGated) SetFlags(f *flag.FlagSet) {
+	c.sub.SetFlags(f)
+}
+
+// Execute forwards to the underlying c.sub Command if the platform is supported.
+func (c *platformGated) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	if !c.isSupported() {
+		fmt.Fprintln(Stderr(ctx), MessagesFromContext(ctx).Sprintf(MessageUnsupportedPlatform, c.sub.Name(), c.platforms))
+		return subcommands.ExitUsageError
+	}
+
+	return c.sub.Execute(ctx, f, args...)
+}
+
+// isSupported reports whether the platform of the program is supported.
+func (c *platformGated) isSupported() bool {
+	return c.supported(platform())
+}
+
+// containsString reports whether ss contains s.
+func containsString(ss []string, s string) bool {
+	for _, v := range ss {
+		if v == s {
+			return true
+		}
+	}
+
+	return false
+}
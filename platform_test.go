@@ -0,0 +1,97 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+func TestOnlyOn(t *testing.T) {
+	tests := map[string]struct {
+		goos, goarch string
+		wrap         func(sub subcommands.Command) subcommands.Command
+		wantStatus   subcommands.ExitStatus
+		wantStderr   string
+		wantHidden   bool
+	}{
+		"when the operating system matches": {
+			goos: "linux", goarch: "amd64",
+			wrap:       func(sub subcommands.Command) subcommands.Command { return subcommandsutil.OnlyOn(sub, "linux") },
+			wantStatus: subcommands.ExitSuccess,
+		},
+		"when the operating system does not match": {
+			goos: "darwin", goarch: "arm64",
+			wrap:       func(sub subcommands.Command) subcommands.Command { return subcommandsutil.OnlyOn(sub, "linux") },
+			wantStatus: subcommands.ExitUsageError,
+			wantStderr: "the 'perf' command is only supported on linux\n",
+		},
+		"when none of the operating systems match": {
+			goos: "windows", goarch: "amd64",
+			wrap: func(sub subcommands.Command) subcommands.Command {
+				return subcommandsutil.OnlyOn(sub, "linux", "darwin")
+			},
+			wantStatus: subcommands.ExitUsageError,
+			wantStderr: "the 'perf' command is only supported on linux, darwin\n",
+		},
+		"when the architecture matches": {
+			goos: "darwin", goarch: "arm64",
+			wrap: func(sub subcommands.Command) subcommands.Command {
+				return subcommandsutil.OnlyOnArch(sub, "amd64", "arm64")
+			},
+			wantStatus: subcommands.ExitSuccess,
+		},
+		"when the architecture does not match": {
+			goos: "linux", goarch: "386",
+			wrap:       func(sub subcommands.Command) subcommands.Command { return subcommandsutil.OnlyOnArch(sub, "amd64") },
+			wantStatus: subcommands.ExitUsageError,
+			wantStderr: "the 'perf' command is only supported on amd64\n",
+		},
+		"when the unsupported command is hidden": {
+			goos: "darwin", goarch: "arm64",
+			wrap: func(sub subcommands.Command) subcommands.Command {
+				return subcommandsutil.OnlyWhere(sub, func(goos, goarch string) bool {
+					return goos == "linux" && goarch == "amd64"
+				}, "linux/amd64", subcommandsutil.WithHiddenOnUnsupported())
+			},
+			wantStatus: subcommands.ExitUsageError,
+			wantStderr: "the 'perf' command is only supported on linux/amd64\n",
+			wantHidden: true,
+		},
+		"when the supported command is not hidden": {
+			goos: "linux", goarch: "amd64",
+			wrap: func(sub subcommands.Command) subcommands.Command {
+				return subcommandsutil.OnlyWhere(sub, subcommandsutil.SupportedOS("linux"), "linux", subcommandsutil.WithHiddenOnUnsupported())
+			},
+			wantStatus: subcommands.ExitSuccess,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			defer subcommandsutil.SetPlatform(tt.goos, tt.goarch)()
+
+			rec := testcmd.NewRecording("perf")
+			cmd := tt.wrap(rec)
+			if got := subcommandsutil.IsHiddenCommand(cmd); got != tt.wantHidden {
+				t.Fatalf("wanted the command hidden %t but got %t", tt.wantHidden, got)
+			}
+
+			var stderr testcmd.Buffer
+			ctx := subcommandsutil.WithOutput(context.Background(), nil, &stderr)
+			status, _, _ := testcmd.Run(ctx, cmd)
+			testcmd.AssertStatus(t, status, tt.wantStatus)
+			if got := stderr.String(); got != tt.wantStderr {
+				t.Fatalf("wanted the stderr %q but got %q", tt.wantStderr, got)
+			}
+			if want := tt.wantStatus == subcommands.ExitSuccess; rec.DidFinish() != want {
+				t.Fatalf("wanted the command executed %t but got %t", want, rec.DidFinish())
+			}
+		})
+	}
+}
@@ -0,0 +1,227 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/subcommands"
+)
+
+// PluginOption is an option of PluginDispatcher.
+type PluginOption interface {
+	applyPlugin(*Plugins)
+}
+
+// pluginOptionFunc is a PluginOption implemented by a function.
+type pluginOptionFunc func(*Plugins)
+
+// applyPlugin implements PluginOption.
+func (fn pluginOptionFunc) applyPlugin(p *Plugins) { fn(p) }
+
+// WithPluginAllowlist restricts the plugins to the named ones, such as "foo" for the binary
+// "mytool-foo". By default, every plugin found on PATH is allowed.
+func WithPluginAllowlist(names ...string) PluginOption {
+	return pluginOptionFunc(func(p *Plugins) {
+		p.allowed = make(map[string]bool, len(names))
+		for _, name := range names {
+			p.allowed[name] = true
+		}
+	})
+}
+
+// pluginWaitDelay is how long a canceled plugin may keep its output open after it was killed.
+const pluginWaitDelay = time.Second
+
+// Plugins dispatches unknown subcommands to external plugin binaries found on PATH, git-style: the
+// command "mytool foo" runs the binary "mytool-foo".
+type Plugins struct {
+	prefix  string
+	allowed map[string]bool
+}
+
+// PluginDispatcher returns a new Plugins running the binaries named prefix followed by the
+// subcommand name, such as "mytool-".
+func PluginDispatcher(prefix string, opts ...PluginOption) *Plugins {
+	p := &Plugins{
+		prefix: prefix,
+	}
+	for _, opt := range opts {
+		opt.applyPlugin(p)
+	}
+
+	return p
+}
+
+// ErrPluginNotFound is returned by Plugins.Lookup if no allowed plugin of the name is on PATH.
+var ErrPluginNotFound = errors.New("plugin not found")
+
+// Lookup returns the absolute path of the plugin named name. Plugins found through relative PATH
+// entries are refused.
+func (p *Plugins) Lookup(name string) (string, error) {
+	if !p.isAllowed(name) || strings.ContainsAny(name, `/\`) {
+		return "", ErrPluginNotFound
+	}
+
+	path, err := exec.LookPath(p.prefix + name)
+	switch {
+	case errors.Is(err, exec.ErrDot):
+		return "", fmt.Errorf("refusing to run plugin %s from a relative PATH entry", path)
+	case err != nil:
+		return "", ErrPluginNotFound
+	case !filepath.IsAbs(path):
+		return "", fmt.Errorf("refusing to run plugin %s from a relative path", path)
+	}
+
+	return path, nil
+}
+
+// List returns the sorted names of the allowed plugins found in the absolute PATH entries: the
+// executable files, or on Windows the files with an extension of PATHEXT, which is not part of the
+// name.
+func (p *Plugins) List() []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		if !filepath.IsAbs(dir) {
+			continue
+		}
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			name := strings.TrimPrefix(e.Name(), p.prefix)
+			if name == e.Name() {
+				continue
+			}
+			info, err := e.Info()
+			if err != nil || info.IsDir() {
+				continue
+			}
+			name, ok := executableName(name, info.Mode(), runtime.GOOS, os.Getenv("PATHEXT"))
+			if !ok || name == "" || seen[name] || !p.isAllowed(name) {
+				continue
+			}
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// executableName returns name without its executable extension on goos, and whether the file of
+// name with the mode is executable: with an execute permission bit, or on Windows with an extension
+// of pathext, the value of PATHEXT.
+func executableName(name string, mode fs.FileMode, goos, pathext string) (string, bool) {
+	if goos != "windows" {
+		return name, mode&0o111 != 0
+	}
+
+	if pathext == "" {
+		pathext = ".com;.exe;.bat;.cmd" // the default of exec.LookPath
+	}
+	ext := filepath.Ext(name)
+	for _, e := range strings.Split(pathext, ";") {
+		if e != "" && strings.EqualFold(e, ext) {
+			return strings.TrimSuffix(name, ext), true
+		}
+	}
+
+	return name, false
+}
+
+// isAllowed reports whether the plugin named name is allowed.
+func (p *Plugins) isAllowed(name string) bool {
+	return p.allowed == nil || p.allowed[name]
+}
+
+// Execute is like cdr.Execute, but when the subcommand named by topFlags, the parsed top-level
+// flags of cdr, is not registered and a plugin of that name is found, it runs the plugin with the
+// remaining arguments instead. Otherwise, it behaves like ExecuteWithSuggestions.
+func (p *Plugins) Execute(ctx context.Context, cdr *subcommands.Commander, topFlags *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	name := topFlags.Arg(0)
+	if name == "" || isRegistered(cdr, name) {
+		return cdr.Execute(ctx, args...)
+	}
+
+	path, err := p.Lookup(name)
+	switch {
+	case errors.Is(err, ErrPluginNotFound):
+		return ExecuteWithSuggestions(ctx, cdr, topFlags, args...)
+	case err != nil:
+		fmt.Fprintf(cdr.Error, "%s: %v\n", name, err)
+		return subcommands.ExitFailure
+	}
+
+	return p.Run(ctx, path, topFlags.Args()[1:]...)
+}
+
+// Run runs the plugin binary at path with args, connected to os.Stdin and the Stdout and Stderr of
+// ctx, and returns its exit code. The plugin runs in a ProcessGroup, which kills it along with the
+// processes it started when ctx is done, and the processes it left running once it exits.
+func (p *Plugins) Run(ctx context.Context, path string, args ...string) subcommands.ExitStatus {
+	g, err := NewProcessGroup(ctx)
+	if err != nil {
+		fmt.Fprintf(Stderr(ctx), "%s: %v\n", filepath.Base(path), err)
+		return subcommands.ExitFailure
+	}
+	defer g.Dispose()
+
+	cmd := exec.Command(path, args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = Stdout(ctx)
+	cmd.Stderr = Stderr(ctx)
+	cmd.WaitDelay = pluginWaitDelay
+
+	err = g.Start(cmd)
+	if err == nil {
+		err = cmd.Wait()
+	}
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		return subcommands.ExitSuccess
+	case ctx.Err() != nil:
+		return subcommands.ExitFailure
+	case errors.As(err, &exitErr) && exitErr.ExitCode() > 0:
+		return subcommands.ExitStatus(exitErr.ExitCode())
+	default:
+		fmt.Fprintf(Stderr(ctx), "%s: %v\n", filepath.Base(path), err)
+		return subcommands.ExitFailure
+	}
+}
+
+// Explain returns an Explain function of a Commander which calls explain and then lists the
+// plugins. Install it with:
+//
+//	cdr.Explain = plugins.Explain(cdr.Explain)
+func (p *Plugins) Explain(explain func(w io.Writer)) func(w io.Writer) {
+	return func(w io.Writer) {
+		explain(w)
+
+		names := p.List()
+		if len(names) == 0 {
+			return
+		}
+		fmt.Fprintf(w, "\nPlugins:\n")
+		for _, name := range names {
+			fmt.Fprintf(w, "\t%-15s  %s%s\n", name, p.prefix, name)
+		}
+	}
+}
@@ -0,0 +1,277 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+// writePlugin writes an executable shell script named name with body to dir.
+func writePlugin(t *testing.T, dir, name, body string) {
+	t.Helper()
+
+	if err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"+body+"\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// newPluginPath creates a directory of stub plugins and puts it alone on PATH.
+func newPluginPath(t *testing.T) string {
+	t.Helper()
+
+	sleep, err := exec.LookPath("sleep")
+	if err != nil {
+		t.Skip(err)
+	}
+
+	dir := t.TempDir()
+	writePlugin(t, dir, "mytool-hello", `echo "hello $*"; echo "to stderr" >&2; exit 3`)
+	writePlugin(t, dir, "mytool-ok", `exit 0`)
+	writePlugin(t, dir, "mytool-sleep", "exec "+sleep+" 10")
+	if err := os.WriteFile(filepath.Join(dir, "mytool-notexec"), []byte("#!/bin/sh\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir)
+
+	return dir
+}
+
+func TestPluginsExecute(t *testing.T) {
+	tests := map[string]struct {
+		argv       []string
+		opts       []subcommandsutil.PluginOption
+		wantStatus subcommands.ExitStatus
+		wantStdout string
+		wantStderr string
+	}{
+		"when the command is registered": {
+			argv:       []string{"push"},
+			wantStatus: subcommands.ExitSuccess,
+		},
+		"when a plugin matches": {
+			argv:       []string{"hello", "-x", "world"},
+			wantStatus: subcommands.ExitStatus(3),
+			wantStdout: "hello -x world\n",
+			wantStderr: "to stderr\n",
+		},
+		"when a plugin succeeds": {
+			argv:       []string{"ok"},
+			wantStatus: subcommands.ExitSuccess,
+		},
+		"when the plugin is not allowed": {
+			argv:       []string{"hello"},
+			opts:       []subcommandsutil.PluginOption{subcommandsutil.WithPluginAllowlist("ok")},
+			wantStatus: subcommands.ExitUsageError,
+			wantStderr: "unknown command \"hello\"",
+		},
+		"when no plugin matches": {
+			argv:       []string{"pussh"},
+			wantStatus: subcommands.ExitUsageError,
+			wantStderr: "unknown command \"pussh\"; did you mean \"push\"?\n",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			newPluginPath(t)
+
+			h := testcmd.NewHarness(t)
+			h.Register(testcmd.NewRecording("push"), "")
+			if err := h.Flags.Parse(tt.argv); err != nil {
+				t.Fatal(err)
+			}
+			h.Commander.Error = h.Stderr
+
+			ctx := subcommandsutil.WithOutput(context.Background(), h.Stdout, h.Stderr)
+			plugins := subcommandsutil.PluginDispatcher("mytool-", tt.opts...)
+			testcmd.AssertStatus(t, plugins.Execute(ctx, h.Commander, h.Flags), tt.wantStatus)
+			if got := h.Stdout.String(); got != tt.wantStdout {
+				t.Fatalf("wanted stdout %q but got %q", tt.wantStdout, got)
+			}
+			if got := h.Stderr.String(); !strings.HasPrefix(got, tt.wantStderr) {
+				t.Fatalf("wanted stderr to start with %q but got %q", tt.wantStderr, got)
+			}
+		})
+	}
+}
+
+func TestPluginsRunCanceled(t *testing.T) {
+	newPluginPath(t)
+
+	plugins := subcommandsutil.PluginDispatcher("mytool-")
+	path, err := plugins.Lookup("sleep")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	testcmd.AssertStatus(t, plugins.Run(ctx, path), subcommands.ExitFailure)
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("wanted the plugin to be killed on cancel but it ran for %v", elapsed)
+	}
+}
+
+func TestPluginsRunCanceledGrandchild(t *testing.T) {
+	sleep, err := exec.LookPath("sleep")
+	if err != nil {
+		t.Skip(err)
+	}
+	dir := newPluginPath(t)
+	writePlugin(t, dir, "mytool-spawn", sleep+" 10 & echo started; wait")
+
+	plugins := subcommandsutil.PluginDispatcher("mytool-")
+	path, err := plugins.Lookup("spawn")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the grandchild inherits w: r is at EOF once the plugin and the grandchild exited
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	ctx, cancel := context.WithCancel(subcommandsutil.WithOutput(context.Background(), w, &testcmd.Buffer{}))
+	defer cancel()
+	done := make(chan subcommands.ExitStatus)
+	go func() {
+		done <- plugins.Run(ctx, path)
+	}()
+
+	br := bufio.NewReader(r)
+	if line, err := br.ReadString('\n'); line != "started\n" {
+		t.Fatalf("wanted the grandchild started but got %q, %v", line, err)
+	}
+	cancel()
+	testcmd.AssertStatus(t, <-done, subcommands.ExitFailure)
+	w.Close()
+
+	eof := make(chan struct{})
+	go func() {
+		io.Copy(io.Discard, br)
+		close(eof)
+	}()
+	select {
+	case <-eof:
+	case <-time.After(5 * time.Second):
+		t.Fatal("wanted the grandchild killed with the plugin but it is still running")
+	}
+}
+
+func TestExecutableName(t *testing.T) {
+	tests := map[string]struct {
+		name     string
+		mode     os.FileMode
+		goos     string
+		pathext  string
+		wantName string
+		wantOK   bool
+	}{
+		"when the file is executable on unix": {
+			name: "foo", mode: 0o755, goos: "linux",
+			wantName: "foo", wantOK: true,
+		},
+		"when the file is not executable on unix": {
+			name: "foo", mode: 0o644, goos: "linux",
+			wantName: "foo",
+		},
+		"when the extension is in PATHEXT on Windows": {
+			name: "foo.PS1", goos: "windows", pathext: ".COM;.EXE;.PS1",
+			wantName: "foo", wantOK: true,
+		},
+		"when the extension is in the default PATHEXT on Windows": {
+			name: "foo.exe", goos: "windows",
+			wantName: "foo", wantOK: true,
+		},
+		"when the extension is not in PATHEXT on Windows": {
+			name: "foo.txt", mode: 0o755, goos: "windows", pathext: ".COM;.EXE",
+			wantName: "foo.txt",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			gotName, gotOK := subcommandsutil.ExecutableName(tt.name, tt.mode, tt.goos, tt.pathext)
+			if gotName != tt.wantName || gotOK != tt.wantOK {
+				t.Fatalf("wanted %q (%t) but got %q (%t)", tt.wantName, tt.wantOK, gotName, gotOK)
+			}
+		})
+	}
+}
+
+func TestPluginsLookup(t *testing.T) {
+	dir := newPluginPath(t)
+
+	plugins := subcommandsutil.PluginDispatcher("mytool-")
+	path, err := plugins.Lookup("hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(dir, "mytool-hello"); path != want {
+		t.Fatalf("wanted %q but got %q", want, path)
+	}
+
+	if _, err := plugins.Lookup("../mytool-hello"); err == nil {
+		t.Fatal("wanted a plugin name with a separator to be refused but got none")
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	t.Setenv("PATH", ".")
+
+	if _, err := plugins.Lookup("hello"); err == nil || err == subcommandsutil.ErrPluginNotFound {
+		t.Fatalf("wanted a plugin on a relative PATH entry to be refused but got %v", err)
+	}
+	if got := plugins.List(); len(got) != 0 {
+		t.Fatalf("wanted no plugins listed from a relative PATH entry but got %q", got)
+	}
+}
+
+func TestPluginsExplain(t *testing.T) {
+	tests := map[string]struct {
+		opts []subcommandsutil.PluginOption
+		want string
+	}{
+		"when every plugin is allowed": {
+			want: "\nPlugins:\n\thello            mytool-hello\n\tok               mytool-ok\n\tsleep            mytool-sleep\n",
+		},
+		"when an allowlist is set": {
+			opts: []subcommandsutil.PluginOption{subcommandsutil.WithPluginAllowlist("ok", "missing")},
+			want: "\nPlugins:\n\tok               mytool-ok\n",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			newPluginPath(t)
+
+			plugins := subcommandsutil.PluginDispatcher("mytool-", tt.opts...)
+			var out bytes.Buffer
+			plugins.Explain(func(w io.Writer) { io.WriteString(w, "usage\n") })(&out)
+			if want := "usage\n" + tt.want; out.String() != want {
+				t.Fatalf("wanted %q but got %q", want, out.String())
+			}
+		})
+	}
+}
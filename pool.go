@@ -0,0 +1,139 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"sync"
+)
+
+// PanicError is the error of a function which panicked.
+type PanicError struct {
+	// Value is the value the function panicked with.
+	Value interface{}
+
+	// Stack is the stack trace of the goroutine at the panic.
+	Stack []byte
+}
+
+// Error implements error.
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// Unwrap returns the value the function panicked with if it is an error.
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+
+	return err
+}
+
+// WorkerPool runs functions on a bounded number of goroutines. It is created by Pool.
+type WorkerPool struct {
+	ctx context.Context
+	sem chan struct{}
+
+	mu      sync.Mutex
+	wg      sync.WaitGroup
+	errs    []error
+	skipped bool // whether a function was not run because ctx was done
+
+	removeHook func()
+}
+
+// Pool returns a WorkerPool running at most workers functions at a time with ctx, or
+// runtime.GOMAXPROCS functions if workers is not positive.
+//
+// When ctx is of a Cancelable execution, its cancellation waits for the functions running to
+// return before the command is disposed, so Dispose never races with the workers. The functions
+// must therefore return soon after ctx is done.
+func Pool(ctx context.Context, workers int) *WorkerPool {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	p := &WorkerPool{
+		ctx: ctx,
+		sem: make(chan struct{}, workers),
+	}
+	p.removeHook = onCancel(ctx, p.wait)
+
+	return p
+}
+
+// Go runs fn with the context of the pool on a goroutine, blocking while all the workers are busy.
+// fn is not run once the context is done. A panic of fn is returned by Wait as a *PanicError.
+func (p *WorkerPool) Go(fn func(ctx context.Context) error) {
+	select {
+	case p.sem <- struct{}{}:
+	case <-p.ctx.Done():
+		p.skip()
+		return
+	}
+
+	p.mu.Lock()
+	if p.ctx.Err() != nil {
+		p.mu.Unlock()
+		<-p.sem
+		p.skip()
+		return
+	}
+	p.wg.Add(1)
+	p.mu.Unlock()
+
+	go func() {
+		defer p.wg.Done()
+		defer func() { <-p.sem }()
+
+		if err := p.run(fn); err != nil {
+			p.mu.Lock()
+			p.errs = append(p.errs, err)
+			p.mu.Unlock()
+		}
+	}()
+}
+
+// run calls fn, converting a panic into a *PanicError.
+func (p *WorkerPool) run(fn func(ctx context.Context) error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &PanicError{Value: r, Stack: debug.Stack()}
+		}
+	}()
+
+	return fn(p.ctx)
+}
+
+// skip records that a function was not run as the context is done.
+func (p *WorkerPool) skip() {
+	p.mu.Lock()
+	p.skipped = true
+	p.mu.Unlock()
+}
+
+// wait waits for the functions running to return.
+func (p *WorkerPool) wait() {
+	p.wg.Wait()
+}
+
+// Wait waits for the functions run by Go to return, and returns their errors joined by errors.Join,
+// along with the error of the context if a function was not run because it was done.
+func (p *WorkerPool) Wait() error {
+	p.wait()
+	p.removeHook()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	errs := append([]error(nil), p.errs...)
+	if p.skipped {
+		errs = append(errs, p.ctx.Err())
+	}
+
+	return errors.Join(errs...)
+}
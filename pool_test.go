@@ -0,0 +1,175 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+func TestPoolErrors(t *testing.T) {
+	defer testcmd.VerifyNoLeaks(t)
+
+	errA, errB := errors.New("fetching a"), errors.New("fetching b")
+	tests := map[string]struct {
+		fns       []func(ctx context.Context) error
+		wantErrs  []error
+		wantPanic bool
+	}{
+		"when every function succeeds": {
+			fns: []func(ctx context.Context) error{
+				func(ctx context.Context) error { return nil },
+				func(ctx context.Context) error { return nil },
+			},
+		},
+		"when functions fail": {
+			fns: []func(ctx context.Context) error{
+				func(ctx context.Context) error { return errA },
+				func(ctx context.Context) error { return nil },
+				func(ctx context.Context) error { return errB },
+			},
+			wantErrs: []error{errA, errB},
+		},
+		"when a function panics": {
+			fns: []func(ctx context.Context) error{
+				func(ctx context.Context) error { return errA },
+				func(ctx context.Context) error { panic("boom") },
+			},
+			wantErrs:  []error{errA},
+			wantPanic: true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			p := subcommandsutil.Pool(context.Background(), 2)
+			for _, fn := range tt.fns {
+				p.Go(fn)
+			}
+			err := p.Wait()
+
+			if len(tt.wantErrs) == 0 && !tt.wantPanic {
+				if err != nil {
+					t.Fatalf("wanted no error but got %v", err)
+				}
+				return
+			}
+			for _, want := range tt.wantErrs {
+				if !errors.Is(err, want) {
+					t.Fatalf("wanted the error to hold %v but got %v", want, err)
+				}
+			}
+			var panicErr *subcommandsutil.PanicError
+			if got := errors.As(err, &panicErr); got != tt.wantPanic {
+				t.Fatalf("wanted the error to hold a PanicError to be %v but got %v", tt.wantPanic, err)
+			}
+			if tt.wantPanic && (panicErr.Value != "boom" || len(panicErr.Stack) == 0) {
+				t.Fatalf("wanted the PanicError of %q with a stack but got %#v", "boom", panicErr)
+			}
+		})
+	}
+}
+
+func TestPoolCanceled(t *testing.T) {
+	defer testcmd.VerifyNoLeaks(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	p := subcommandsutil.Pool(ctx, 2)
+
+	var started, finished int32
+	running := make(chan struct{}, 2)
+	for i := 0; i < 10; i++ {
+		if i == 2 {
+			<-running
+			<-running
+			cancel()
+		}
+		p.Go(func(ctx context.Context) error {
+			atomic.AddInt32(&started, 1)
+			running <- struct{}{}
+			<-ctx.Done()
+			atomic.AddInt32(&finished, 1)
+			return nil
+		})
+	}
+
+	if err := p.Wait(); !errors.Is(err, context.Canceled) {
+		t.Fatalf("wanted the error %v but got %v", context.Canceled, err)
+	}
+	if got := atomic.LoadInt32(&started); got != 2 {
+		t.Fatalf("wanted only the 2 functions dispatched before the cancellation to run but got %d", got)
+	}
+	if got := atomic.LoadInt32(&finished); got != 2 {
+		t.Fatalf("wanted Wait to wait for the 2 running functions but got %d finished", got)
+	}
+}
+
+// disposeCheck is a Recording calling check from Dispose.
+type disposeCheck struct {
+	*testcmd.Recording
+	check func()
+}
+
+// Dispose implements subcommandsutil.CancelableCommand.
+func (c *disposeCheck) Dispose() error {
+	c.check()
+
+	return c.Recording.Dispose()
+}
+
+func TestPoolCancelable(t *testing.T) {
+	defer testcmd.VerifyNoLeaks(t)
+
+	var running int32
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	sub := &disposeCheck{
+		Recording: testcmd.NewRecording("fetch", testcmd.WithExecute(func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+			p := subcommandsutil.Pool(ctx, 4)
+			for i := 0; i < 4; i++ {
+				p.Go(func(ctx context.Context) error {
+					defer atomic.AddInt32(&running, -1)
+					if atomic.AddInt32(&running, 1) == 4 {
+						close(started)
+					}
+					<-ctx.Done()
+					time.Sleep(20 * time.Millisecond) // cleanup
+					return ctx.Err()
+				})
+			}
+			err := p.Wait()
+			<-release
+			return subcommandsutil.StatusFromError(err)
+		})),
+	}
+	sub.check = func() {
+		if got := atomic.LoadInt32(&running); got != 0 {
+			t.Errorf("wanted the workers to return before Dispose but got %d running", got)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan subcommands.ExitStatus, 1)
+	go func() {
+		status, _, _ := testcmd.Run(ctx, subcommandsutil.Cancelable(sub, subcommandsutil.WithLogger(&testcmd.LogRecorder{})))
+		done <- status
+	}()
+	<-started
+	cancel()
+
+	testcmd.AssertStatus(t, <-done, subcommands.ExitFailure)
+	if sub.DisposeCount() != 1 {
+		t.Fatalf("wanted sub to be disposed once but got %d", sub.DisposeCount())
+	}
+}
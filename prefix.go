@@ -0,0 +1,62 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+// prefixWriter writes complete lines to w, each prefixed with prefix. Lines are written while
+// holding mu, which is shared by the prefixWriter of the same w so that concurrent lines do not
+// interleave.
+type prefixWriter struct {
+	mu     *sync.Mutex
+	w      io.Writer
+	prefix string
+
+	bufMu sync.Mutex
+	buf   bytes.Buffer
+}
+
+// Write implements io.Writer. Incomplete lines are buffered until their newline or Flush.
+func (p *prefixWriter) Write(b []byte) (int, error) {
+	p.bufMu.Lock()
+	defer p.bufMu.Unlock()
+
+	p.buf.Write(b)
+	for {
+		i := bytes.IndexByte(p.buf.Bytes(), '\n')
+		if i < 0 {
+			return len(b), nil
+		}
+		if err := p.writeLine(p.buf.Next(i + 1)); err != nil {
+			return len(b), err
+		}
+	}
+}
+
+// Flush writes the buffered incomplete line, followed by a newline.
+func (p *prefixWriter) Flush() error {
+	p.bufMu.Lock()
+	defer p.bufMu.Unlock()
+
+	if p.buf.Len() == 0 {
+		return nil
+	}
+	line := append(p.buf.Next(p.buf.Len()), '\n')
+
+	return p.writeLine(line)
+}
+
+// writeLine writes line with the prefix.
+func (p *prefixWriter) writeLine(line []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	_, err := io.WriteString(p.w, p.prefix+string(line))
+
+	return err
+}
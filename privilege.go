@@ -0,0 +1,174 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+
+	"github.com/google/subcommands"
+)
+
+// AssumePrivilegedEnv is the environment variable which, when set to a true value such as "1",
+// makes the privilege checks of RequireRoot, RequireUser and RequirePrivilege pass, for the
+// containers where the privileges of the process cannot be detected.
+const AssumePrivilegedEnv = "SUBCOMMANDSUTIL_ASSUME_PRIVILEGED"
+
+// processPrivileged reports whether the process runs as root, or elevated on Windows.
+var processPrivileged = isPrivileged
+
+// errNotRoot is the error of the check of RequireRoot.
+var errNotRoot = errors.New("not root")
+
+// PrivilegeOption is an option of the RequireRoot, RequireUser and RequirePrivilege wrappers.
+type PrivilegeOption interface {
+	applyPrivilege(*privileged)
+}
+
+// privilegeOptionFunc is a PrivilegeOption implemented by a function.
+type privilegeOptionFunc func(*privileged)
+
+// applyPrivilege implements PrivilegeOption.
+func (fn privilegeOptionFunc) applyPrivilege(c *privileged) { fn(c) }
+
+// WithPrivilegeStatus sets the status returned when the privileges are missing, instead of
+// subcommands.ExitFailure.
+func WithPrivilegeStatus(status subcommands.ExitStatus) PrivilegeOption {
+	return privilegeOptionFunc(func(c *privileged) {
+		c.status = status
+	})
+}
+
+// privileged wraps a subcommands.Command so that it executes only with the privileges it requires.
+type privileged struct {
+	sub    subcommands.Command
+	check  func() error
+	status subcommands.ExitStatus
+}
+
+// make sure privileged implements the subcommands.Command interface.
+var _ subcommands.Command = (*privileged)(nil)
+
+// RequireRoot wraps sub so that it executes only as root, or from an elevated process on Windows.
+// Otherwise it prints "NAME: this command must be run as root; try sudo", or the MessageNotRoot of
+// the Messages of the execution, to Stderr, and returns subcommands.ExitFailure or the status set by
+// WithPrivilegeStatus.
+func RequireRoot(sub subcommands.Command, opts ...PrivilegeOption) subcommands.Command {
+	return RequirePrivilege(sub, func() error {
+		if !processPrivileged() {
+			return errNotRoot
+		}
+		return nil
+	}, opts...)
+}
+
+// RequireUser wraps sub so that it executes only as the user name, a username or a user ID, compared
+// with the effective user of the process. Otherwise it prints "NAME: this command must be run as
+// USER", or the MessageWrongUser of the Messages of the execution, like RequireRoot.
+func RequireUser(sub subcommands.Command, name string, opts ...PrivilegeOption) subcommands.Command {
+	return RequirePrivilege(sub, func() error {
+		return checkUser(name)
+	}, opts...)
+}
+
+// RequirePrivilege wraps sub so that it executes only when check, evaluated before each execution,
+// returns nil. Otherwise it prints "NAME: ERROR" to Stderr, and returns subcommands.ExitFailure or
+// the status set by WithPrivilegeStatus. The check passes if AssumePrivilegedEnv is set to a true
+// value.
+func RequirePrivilege(sub subcommands.Command, check func() error, opts ...PrivilegeOption) subcommands.Command {
+	c := &privileged{
+		sub:    sub,
+		check:  check,
+		status: subcommands.ExitFailure,
+	}
+	for _, opt := range opts {
+		opt.applyPrivilege(c)
+	}
+
+	return c
+}
+
+// Name forwards to the underlying c.sub Command.
+func (c *privileged) Name() string {
+	return c.sub.Name()
+}
+
+// Usage forwards to the underlying c.sub Command.
+func (c *privileged) Usage() string {
+	return c.sub.Usage()
+}
+
+// Synopsis forwards to the underlying c.sub Command.
+func (c *privileged) Synopsis() string {
+	return c.sub.Synopsis()
+}
+
+// Unwrap returns the underlying c.sub Command.
+func (c *privileged) Unwrap() subcommands.Command {
+	return c.sub
+}
+
+// SetFlags forwards to the underlying c.sub Command.
+func (c *privileged) SetFlags(f *flag.FlagSet) {
+	c.sub.SetFlags(f)
+}
+
+// Execute checks the privileges of the process, and forwards to the underlying c.sub Command if
+// they are sufficient.
+func (c *privileged) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	if assume, _ := strconv.ParseBool(os.Getenv(AssumePrivilegedEnv)); assume {
+		return c.sub.Execute(ctx, f, args...)
+	}
+
+	if err := c.check(); err != nil {
+		m := MessagesFromContext(ctx)
+		var wrong *wrongUserError
+		switch {
+		case errors.Is(err, errNotRoot):
+			fmt.Fprintln(Stderr(ctx), m.Sprintf(MessageNotRoot, c.sub.Name()))
+		case errors.As(err, &wrong):
+			fmt.Fprintln(Stderr(ctx), m.Sprintf(MessageWrongUser, c.sub.Name(), wrong.name))
+		default:
+			fmt.Fprintf(Stderr(ctx), "%s: %v\n", c.sub.Name(), err)
+		}
+		return c.status
+	}
+
+	return c.sub.Execute(ctx, f, args...)
+}
+
+// wrongUserError is the error of the check of RequireUser.
+type wrongUserError struct {
+	name string
+}
+
+// Error implements error.
+func (e *wrongUserError) Error() string {
+	return "not running as " + e.name
+}
+
+// checkUser returns a *wrongUserError unless the effective user of the process is name, a username
+// or a user ID.
+func checkUser(name string) error {
+	u, err := user.Lookup(name)
+	if err != nil {
+		if u, err = user.LookupId(name); err != nil {
+			return fmt.Errorf("unknown user %s", name)
+		}
+	}
+	uid, err := effectiveUID()
+	if err != nil {
+		return err
+	}
+	if u.Uid != uid {
+		return &wrongUserError{name: name}
+	}
+
+	return nil
+}
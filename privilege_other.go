@@ -0,0 +1,24 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+//go:build !windows
+
+package subcommandsutil
+
+import (
+	"os"
+	"strconv"
+)
+
+// notRootMessage is the English format of MessageNotRoot.
+const notRootMessage = "%s: this command must be run as root; try sudo"
+
+// isPrivileged reports whether the effective user of the process is root.
+func isPrivileged() bool {
+	return os.Geteuid() == 0
+}
+
+// effectiveUID returns the user ID of the effective user of the process.
+func effectiveUID() (string, error) {
+	return strconv.Itoa(os.Geteuid()), nil
+}
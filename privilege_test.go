@@ -0,0 +1,129 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"context"
+	"errors"
+	"os/user"
+	"testing"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+func TestRequireRoot(t *testing.T) {
+	tests := map[string]struct {
+		privileged bool
+		assume     string
+		opts       []subcommandsutil.PrivilegeOption
+		wantStatus subcommands.ExitStatus
+		wantStderr string
+		wantCalls  int
+	}{
+		"when the process is not privileged": {
+			wantStatus: subcommands.ExitFailure,
+			wantStderr: "install-service: this command must be run as root; try sudo\n",
+		},
+		"when the status is set": {
+			opts:       []subcommandsutil.PrivilegeOption{subcommandsutil.WithPrivilegeStatus(subcommands.ExitUsageError)},
+			wantStatus: subcommands.ExitUsageError,
+			wantStderr: "install-service: this command must be run as root; try sudo\n",
+		},
+		"when the process is privileged": {
+			privileged: true,
+			wantStatus: subcommands.ExitSuccess,
+			wantCalls:  1,
+		},
+		"when the privileges are assumed": {
+			assume:     "1",
+			wantStatus: subcommands.ExitSuccess,
+			wantCalls:  1,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			defer subcommandsutil.SetProcessPrivileged(func() bool { return tt.privileged })()
+			t.Setenv(subcommandsutil.AssumePrivilegedEnv, tt.assume)
+
+			rec := testcmd.NewRecording("install-service")
+			var stderr testcmd.Buffer
+			ctx := subcommandsutil.WithOutput(context.Background(), nil, &stderr)
+			status, _, _ := testcmd.Run(ctx, subcommandsutil.RequireRoot(rec, tt.opts...))
+			testcmd.AssertStatus(t, status, tt.wantStatus)
+			if got := stderr.String(); got != tt.wantStderr {
+				t.Fatalf("wanted the stderr %q but got %q", tt.wantStderr, got)
+			}
+			if got := rec.CallCount(); got != tt.wantCalls {
+				t.Fatalf("wanted %d calls but got %d", tt.wantCalls, got)
+			}
+		})
+	}
+}
+
+func TestRequireUser(t *testing.T) {
+	t.Setenv(subcommandsutil.AssumePrivilegedEnv, "")
+
+	current, err := user.Current()
+	if err != nil {
+		t.Skip(err)
+	}
+
+	tests := map[string]struct {
+		name       string
+		wantStatus subcommands.ExitStatus
+		wantStderr string
+	}{
+		"when the user is the effective user": {
+			name:       current.Username,
+			wantStatus: subcommands.ExitSuccess,
+		},
+		"when the user ID is the effective one": {
+			name:       current.Uid,
+			wantStatus: subcommands.ExitSuccess,
+		},
+		"when the user is unknown": {
+			name:       "no-such-user-subcommandsutil",
+			wantStatus: subcommands.ExitFailure,
+			wantStderr: "backup: unknown user no-such-user-subcommandsutil\n",
+		},
+	}
+	if other, err := user.Lookup("nobody"); err == nil && other.Uid != current.Uid {
+		tests["when the user is another one"] = struct {
+			name       string
+			wantStatus subcommands.ExitStatus
+			wantStderr string
+		}{
+			name:       "nobody",
+			wantStatus: subcommands.ExitFailure,
+			wantStderr: "backup: this command must be run as nobody\n",
+		}
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var stderr testcmd.Buffer
+			ctx := subcommandsutil.WithOutput(context.Background(), nil, &stderr)
+			status, _, _ := testcmd.Run(ctx, subcommandsutil.RequireUser(testcmd.NewRecording("backup"), tt.name))
+			testcmd.AssertStatus(t, status, tt.wantStatus)
+			if got := stderr.String(); got != tt.wantStderr {
+				t.Fatalf("wanted the stderr %q but got %q", tt.wantStderr, got)
+			}
+		})
+	}
+}
+
+func TestRequirePrivilege(t *testing.T) {
+	t.Setenv(subcommandsutil.AssumePrivilegedEnv, "")
+
+	check := func() error { return errors.New("CAP_NET_ADMIN is required") }
+	var stderr testcmd.Buffer
+	ctx := subcommandsutil.WithOutput(context.Background(), nil, &stderr)
+	status, _, _ := testcmd.Run(ctx, subcommandsutil.RequirePrivilege(testcmd.NewRecording("route"), check))
+	testcmd.AssertStatus(t, status, subcommands.ExitFailure)
+	if want := "route: CAP_NET_ADMIN is required\n"; stderr.String() != want {
+		t.Fatalf("wanted the stderr %q but got %q", want, stderr.String())
+	}
+}
@@ -0,0 +1,47 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"os/user"
+	"syscall"
+	"unsafe"
+)
+
+// notRootMessage is the English format of MessageNotRoot.
+const notRootMessage = "%s: this command must be run as an administrator; try an elevated prompt"
+
+// tokenElevation is the TOKEN_INFORMATION_CLASS of the elevation of a token.
+const tokenElevation = 20 // TokenElevation
+
+// isPrivileged reports whether the process is elevated.
+func isPrivileged() bool {
+	p, err := syscall.GetCurrentProcess()
+	if err != nil {
+		return false
+	}
+	var token syscall.Token
+	if err := syscall.OpenProcessToken(p, syscall.TOKEN_QUERY, &token); err != nil {
+		return false
+	}
+	defer token.Close()
+
+	var elevated uint32
+	var n uint32
+	if err := syscall.GetTokenInformation(token, tokenElevation, (*byte)(unsafe.Pointer(&elevated)), uint32(unsafe.Sizeof(elevated)), &n); err != nil {
+		return false
+	}
+
+	return elevated != 0
+}
+
+// effectiveUID returns the security identifier of the user of the process.
+func effectiveUID() (string, error) {
+	u, err := user.Current()
+	if err != nil {
+		return "", err
+	}
+
+	return u.Uid, nil
+}
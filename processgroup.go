@@ -0,0 +1,96 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"sync"
+)
+
+// ErrProcessGroupKilled is returned by ProcessGroup.Start once the group is killed.
+var ErrProcessGroupKilled = errors.New("process group killed")
+
+// ProcessGroup is a group of child processes, and of the processes they start in turn, killed
+// together when the context of the group is done or when it is disposed, so that no grandchild
+// survives the cancellation of a command:
+//
+//	g, err := subcommandsutil.NewProcessGroup(ctx)
+//	if err != nil {
+//		return subcommands.ExitFailure
+//	}
+//	defer g.Dispose()
+//	cmd := exec.Command("go", "build", "./...")
+//	if err := g.Start(cmd); err != nil {
+//		return subcommands.ExitFailure
+//	}
+//	err = cmd.Wait()
+//
+// On unix, each child leads a new process group, which the signals of the terminal, like the
+// interrupt of Ctrl-C, no longer reach, and the groups are sent SIGKILL. On Windows, the children
+// are assigned to a Job Object which kills them when its handle is closed. On the other platforms,
+// only the children are killed.
+type ProcessGroup struct {
+	stop func() bool
+
+	mu     sync.Mutex
+	killed bool
+	sys    processGroupSys
+}
+
+// NewProcessGroup returns an empty ProcessGroup killed when ctx is done.
+func NewProcessGroup(ctx context.Context) (*ProcessGroup, error) {
+	sys, err := newProcessGroupSys()
+	if err != nil {
+		return nil, err
+	}
+
+	g := &ProcessGroup{sys: sys}
+	g.stop = context.AfterFunc(ctx, func() { g.Kill() })
+
+	return g, nil
+}
+
+// Start starts cmd in g. It returns ErrProcessGroupKilled if g is already killed, in which case
+// cmd is not started.
+func (g *ProcessGroup) Start(cmd *exec.Cmd) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.killed {
+		return ErrProcessGroupKilled
+	}
+	g.sys.prepare(cmd)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	if err := g.sys.add(cmd.Process); err != nil {
+		cmd.Process.Kill()
+		return err
+	}
+
+	return nil
+}
+
+// Kill kills the processes of g, once. The processes are not waited for, the callers of Start
+// still have to Wait for their commands.
+func (g *ProcessGroup) Kill() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.killed {
+		return nil
+	}
+	g.killed = true
+
+	return g.sys.kill()
+}
+
+// Dispose kills the processes of g, and releases its resources.
+func (g *ProcessGroup) Dispose() error {
+	g.stop()
+
+	return g.Kill()
+}
@@ -0,0 +1,45 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+//go:build !unix && !windows
+
+package subcommandsutil
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+)
+
+// processGroupSys holds the children of a ProcessGroup. Their own children are not tracked on this
+// platform.
+type processGroupSys struct {
+	procs []*os.Process
+}
+
+// newProcessGroupSys returns an empty processGroupSys.
+func newProcessGroupSys() (processGroupSys, error) {
+	return processGroupSys{}, nil
+}
+
+// prepare does nothing.
+func (s *processGroupSys) prepare(cmd *exec.Cmd) {}
+
+// add adds p.
+func (s *processGroupSys) add(p *os.Process) error {
+	s.procs = append(s.procs, p)
+
+	return nil
+}
+
+// kill kills the children. The ones already gone are skipped.
+func (s *processGroupSys) kill() error {
+	var errs []error
+	for _, p := range s.procs {
+		if err := p.Kill(); err != nil && !errors.Is(err, os.ErrProcessDone) {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
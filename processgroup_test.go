@@ -0,0 +1,118 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/zchee/subcommandsutil"
+)
+
+// processGroupHelperEnv is the environment variable running TestProcessGroupHelper as a child or a
+// grandchild process.
+const processGroupHelperEnv = "SUBCOMMANDSUTIL_TEST_PROCESS_GROUP"
+
+// TestProcessGroupHelper is not a test, but the child process started by TestProcessGroup, which
+// starts a grandchild inheriting its stdout, and the grandchild.
+func TestProcessGroupHelper(t *testing.T) {
+	switch os.Getenv(processGroupHelperEnv) {
+	case "child":
+		grandchild := exec.Command(os.Args[0], "-test.run=^TestProcessGroupHelper$")
+		grandchild.Env = append(os.Environ(), processGroupHelperEnv+"=grandchild")
+		grandchild.Stdout = os.Stdout
+		if err := grandchild.Start(); err != nil {
+			os.Exit(1)
+		}
+		os.Stdout.WriteString("ready\n")
+		time.Sleep(time.Minute)
+		os.Exit(0)
+	case "grandchild":
+		time.Sleep(time.Minute)
+		os.Exit(0)
+	}
+}
+
+func TestProcessGroup(t *testing.T) {
+	switch runtime.GOOS {
+	case "js", "wasip1":
+		t.Skipf("wanted a platform starting processes but got %s", runtime.GOOS)
+	}
+
+	tests := map[string]struct {
+		kill func(cancel context.CancelFunc, g *subcommandsutil.ProcessGroup) error
+	}{
+		"when the context is canceled": {
+			kill: func(cancel context.CancelFunc, g *subcommandsutil.ProcessGroup) error {
+				cancel()
+				return nil
+			},
+		},
+		"when the group is disposed": {
+			kill: func(cancel context.CancelFunc, g *subcommandsutil.ProcessGroup) error {
+				return g.Dispose()
+			},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			g, err := subcommandsutil.NewProcessGroup(ctx)
+			if err != nil {
+				t.Fatalf("wanted no error but got %v", err)
+			}
+			defer g.Dispose()
+
+			// the pipe is at EOF once both the child and the grandchild holding it exit
+			r, w, err := os.Pipe()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer r.Close()
+			child := exec.Command(os.Args[0], "-test.run=^TestProcessGroupHelper$")
+			child.Env = append(os.Environ(), processGroupHelperEnv+"=child")
+			child.Stdout = w
+			err = g.Start(child)
+			w.Close()
+			if err != nil {
+				t.Fatalf("wanted no error but got %v", err)
+			}
+			defer child.Wait()
+
+			br := bufio.NewReader(r)
+			if line, err := br.ReadString('\n'); line != "ready\n" {
+				t.Fatalf("wanted the grandchild started but got %q, %v", line, err)
+			}
+			if err := tt.kill(cancel, g); err != nil {
+				t.Fatalf("wanted no error but got %v", err)
+			}
+
+			eof := make(chan error, 1)
+			go func() {
+				_, err := io.Copy(io.Discard, br)
+				eof <- err
+			}()
+			select {
+			case err := <-eof:
+				if err != nil {
+					t.Fatalf("wanted EOF but got %v", err)
+				}
+			case <-time.After(10 * time.Second):
+				t.Fatal("wanted the child and the grandchild killed but they are still running")
+			}
+
+			if err := g.Start(exec.Command(os.Args[0], "-test.run=^$")); !errors.Is(err, subcommandsutil.ErrProcessGroupKilled) {
+				t.Fatalf("wanted %v but got %v", subcommandsutil.ErrProcessGroupKilled, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+//go:build unix
+
+package subcommandsutil
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// processGroupSys holds the process groups led by the children of a ProcessGroup.
+type processGroupSys struct {
+	pgids []int
+}
+
+// newProcessGroupSys returns an empty processGroupSys.
+func newProcessGroupSys() (processGroupSys, error) {
+	return processGroupSys{}, nil
+}
+
+// prepare makes cmd lead a new process group.
+func (s *processGroupSys) prepare(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+	cmd.SysProcAttr.Pgid = 0
+}
+
+// add adds the process group led by p.
+func (s *processGroupSys) add(p *os.Process) error {
+	s.pgids = append(s.pgids, p.Pid)
+
+	return nil
+}
+
+// kill sends SIGKILL to the process groups. The groups already gone are skipped.
+func (s *processGroupSys) kill() error {
+	var errs []error
+	for _, pgid := range s.pgids {
+		if err := syscall.Kill(-pgid, syscall.SIGKILL); err != nil && !errors.Is(err, syscall.ESRCH) {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
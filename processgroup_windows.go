@@ -0,0 +1,106 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	procCreateJobObjectW         = kernel32.NewProc("CreateJobObjectW")
+	procSetInformationJobObject  = kernel32.NewProc("SetInformationJobObject")
+	procAssignProcessToJobObject = kernel32.NewProc("AssignProcessToJobObject")
+)
+
+const (
+	jobObjectExtendedLimitInformation = 9          // JobObjectExtendedLimitInformation
+	jobObjectLimitKillOnJobClose      = 0x00002000 // JOB_OBJECT_LIMIT_KILL_ON_JOB_CLOSE
+
+	processSetQuota  = 0x0100 // PROCESS_SET_QUOTA
+	processTerminate = 0x0001 // PROCESS_TERMINATE
+)
+
+// jobObjectBasicLimitInformation is the JOBOBJECT_BASIC_LIMIT_INFORMATION structure.
+type jobObjectBasicLimitInformation struct {
+	PerProcessUserTimeLimit int64
+	PerJobUserTimeLimit     int64
+	LimitFlags              uint32
+	MinimumWorkingSetSize   uintptr
+	MaximumWorkingSetSize   uintptr
+	ActiveProcessLimit      uint32
+	Affinity                uintptr
+	PriorityClass           uint32
+	SchedulingClass         uint32
+}
+
+// ioCounters is the IO_COUNTERS structure.
+type ioCounters struct {
+	ReadOperationCount  uint64
+	WriteOperationCount uint64
+	OtherOperationCount uint64
+	ReadTransferCount   uint64
+	WriteTransferCount  uint64
+	OtherTransferCount  uint64
+}
+
+// jobObjectExtendedLimitInfo is the JOBOBJECT_EXTENDED_LIMIT_INFORMATION structure.
+type jobObjectExtendedLimitInfo struct {
+	BasicLimitInformation jobObjectBasicLimitInformation
+	IoInfo                ioCounters
+	ProcessMemoryLimit    uintptr
+	JobMemoryLimit        uintptr
+	PeakProcessMemoryUsed uintptr
+	PeakJobMemoryUsed     uintptr
+}
+
+// processGroupSys holds the Job Object the children of a ProcessGroup are assigned to.
+type processGroupSys struct {
+	job syscall.Handle
+}
+
+// newProcessGroupSys returns a processGroupSys with a new Job Object killing its processes when
+// its handle is closed.
+func newProcessGroupSys() (processGroupSys, error) {
+	r, _, err := procCreateJobObjectW.Call(0, 0)
+	if r == 0 {
+		return processGroupSys{}, os.NewSyscallError("CreateJobObject", err)
+	}
+	job := syscall.Handle(r)
+
+	var info jobObjectExtendedLimitInfo
+	info.BasicLimitInformation.LimitFlags = jobObjectLimitKillOnJobClose
+	r, _, err = procSetInformationJobObject.Call(uintptr(job), jobObjectExtendedLimitInformation, uintptr(unsafe.Pointer(&info)), unsafe.Sizeof(info))
+	if r == 0 {
+		syscall.CloseHandle(job)
+		return processGroupSys{}, os.NewSyscallError("SetInformationJobObject", err)
+	}
+
+	return processGroupSys{job: job}, nil
+}
+
+// prepare does nothing, the children are assigned to the Job Object once started.
+func (s *processGroupSys) prepare(cmd *exec.Cmd) {}
+
+// add assigns p to the Job Object. The processes p starts before are not assigned.
+func (s *processGroupSys) add(p *os.Process) error {
+	h, err := syscall.OpenProcess(processSetQuota|processTerminate, false, uint32(p.Pid))
+	if err != nil {
+		return os.NewSyscallError("OpenProcess", err)
+	}
+	defer syscall.CloseHandle(h)
+
+	if r, _, err := procAssignProcessToJobObject.Call(uintptr(s.job), uintptr(h)); r == 0 {
+		return os.NewSyscallError("AssignProcessToJobObject", err)
+	}
+
+	return nil
+}
+
+// kill closes the handle of the Job Object, terminating its processes.
+func (s *processGroupSys) kill() error {
+	return os.NewSyscallError("CloseHandle", syscall.CloseHandle(s.job))
+}
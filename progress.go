@@ -0,0 +1,271 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/google/subcommands"
+)
+
+// Progress is the progress of a command reported by ReportProgress.
+type Progress struct {
+	// Current is the amount of work done, in the unit of Total.
+	Current int64 `json:"current"`
+
+	// Total is the amount of work to do, or 0 if unknown.
+	Total int64 `json:"total,omitempty"`
+
+	// Message describes the work in progress.
+	Message string `json:"message,omitempty"`
+}
+
+// progressKey is the context key of the progressSink of an execution.
+type progressKey struct{}
+
+// progressSink receives the Progress reported during an execution, and forwards it to the sink of
+// the enclosing wrapper.
+type progressSink struct {
+	report func(Progress)
+	parent *progressSink
+}
+
+// withProgressSink returns a copy of ctx whose reported Progress is passed to report, and to the
+// sinks ctx already carries.
+func withProgressSink(ctx context.Context, report func(Progress)) context.Context {
+	parent, _ := ctx.Value(progressKey{}).(*progressSink)
+
+	return context.WithValue(ctx, progressKey{}, &progressSink{report: report, parent: parent})
+}
+
+// ReportProgress reports p, the progress of the command executing with ctx, to the wrappers
+// consuming it, like ProgressStream, which streams it. It does nothing outside of them, so that a
+// command reports its progress once, whichever wrappers render it or watch it for activity.
+func ReportProgress(ctx context.Context, p Progress) {
+	for s, _ := ctx.Value(progressKey{}).(*progressSink); s != nil; s = s.parent {
+		s.report(p)
+	}
+}
+
+// ProgressMode is the mode of the -progress flag registered by ProgressStream.
+type ProgressMode string
+
+const (
+	// ProgressNone discards the progress.
+	ProgressNone ProgressMode = "none"
+	// ProgressJSON writes the progress as newline-delimited JSON.
+	ProgressJSON ProgressMode = "json"
+)
+
+// String implements flag.Value.
+func (m *ProgressMode) String() string {
+	return string(*m)
+}
+
+// Set implements flag.Value.
+func (m *ProgressMode) Set(s string) error {
+	switch mode := ProgressMode(s); mode {
+	case ProgressNone, ProgressJSON:
+		*m = mode
+		return nil
+	default:
+		return fmt.Errorf("invalid progress mode %q: must be %s or %s", s, ProgressNone, ProgressJSON)
+	}
+}
+
+// ProgressOption is an option of the ProgressStream wrapper.
+type ProgressOption interface {
+	applyProgress(*progressStream)
+}
+
+// progressOptionFunc is a ProgressOption implemented by a function.
+type progressOptionFunc func(*progressStream)
+
+// applyProgress implements ProgressOption.
+func (fn progressOptionFunc) applyProgress(c *progressStream) { fn(c) }
+
+// WithProgressOutput makes ProgressStream write the progress to w, like a file or an inherited file
+// descriptor, instead of the Stderr of the execution context.
+func WithProgressOutput(w io.Writer) ProgressOption {
+	return progressOptionFunc(func(c *progressStream) {
+		c.w = w
+	})
+}
+
+// WithProgressInterval sets the minimum interval between the progress lines written by
+// ProgressStream, measured on the Clock of the execution context. It defaults to 100ms.
+func WithProgressInterval(d time.Duration) ProgressOption {
+	return progressOptionFunc(func(c *progressStream) {
+		c.interval = d
+	})
+}
+
+// progressLine is a line written by ProgressStream in the json mode.
+type progressLine struct {
+	Time    time.Time `json:"time"`
+	Command string    `json:"command"`
+	Progress
+}
+
+// progressStream wraps a subcommands.Command so that its progress is streamed.
+type progressStream struct {
+	sub      subcommands.Command
+	w        io.Writer
+	interval time.Duration
+
+	mode ProgressMode
+}
+
+// make sure progressStream implements the subcommands.Command interface.
+var _ subcommands.Command = (*progressStream)(nil)
+
+// ProgressStream wraps sub with the -progress flag, none or json. In the json mode, the Progress
+// reported by ReportProgress is written to the Stderr of the execution context, unless
+// WithProgressOutput is given, one JSON object per line:
+//
+//	{"time":"2021-01-02T03:04:05Z","command":"sync","current":3,"total":10,"message":"copying"}
+//
+// The lines are throttled to one per interval of WithProgressInterval: the progress reported in
+// between is dropped, but the last one, which is written once the interval elapses or when Execute
+// returns. The none mode, the default, discards the progress.
+func ProgressStream(sub subcommands.Command, opts ...ProgressOption) subcommands.Command {
+	c := &progressStream{
+		sub:      sub,
+		interval: 100 * time.Millisecond,
+		mode:     ProgressNone,
+	}
+	for _, opt := range opts {
+		opt.applyProgress(c)
+	}
+
+	return c
+}
+
+// Name forwards to the underlying c.sub Command.
+func (c *progressStream) Name() string {
+	return c.sub.Name()
+}
+
+// Usage forwards to the underlying c.sub Command.
+func (c *progressStream) Usage() string {
+	return c.sub.Usage()
+}
+
+// Synopsis forwards to the underlying c.sub Command.
+func (c *progressStream) Synopsis() string {
+	return c.sub.Synopsis()
+}
+
+// Unwrap returns the underlying c.sub Command.
+func (c *progressStream) Unwrap() subcommands.Command {
+	return c.sub
+}
+
+// SetFlags forwards to the underlying c.sub Command and registers the -progress flag.
+func (c *progressStream) SetFlags(f *flag.FlagSet) {
+	c.sub.SetFlags(f)
+	c.mode = ProgressNone
+	f.Var(&c.mode, "progress", "report the progress as `mode`, none or json")
+}
+
+// Execute forwards to the underlying c.sub Command, streaming its progress in the json mode.
+func (c *progressStream) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	if c.mode != ProgressJSON {
+		return c.sub.Execute(ctx, f, args...)
+	}
+
+	w := c.w
+	if w == nil {
+		w = Stderr(ctx)
+	}
+	t := &progressThrottle{
+		w:        w,
+		clk:      ClockFromContext(ctx),
+		command:  c.sub.Name(),
+		interval: c.interval,
+		done:     make(chan struct{}),
+	}
+	defer t.flush()
+
+	return c.sub.Execute(withProgressSink(ctx, t.report), f, args...)
+}
+
+// progressThrottle writes the progress lines of an execution, at most one per interval.
+type progressThrottle struct {
+	w        io.Writer
+	clk      Clock
+	command  string
+	interval time.Duration
+
+	mu          sync.Mutex
+	last        time.Time // when the last line was written
+	pending     Progress  // the last progress dropped
+	havePending bool
+	timer       Timer // writing pending once the interval elapses, while it is set
+	done        chan struct{}
+}
+
+// report writes p, or keeps it pending until the interval elapses.
+func (t *progressThrottle) report(p Progress) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.clk.Now()
+	if wait := t.last.Add(t.interval).Sub(now); !t.last.IsZero() && wait > 0 {
+		t.pending, t.havePending = p, true
+		if t.timer == nil {
+			t.timer = t.clk.NewTimer(wait)
+			go t.await(t.timer, t.done)
+		}
+		return
+	}
+	t.write(now, p)
+}
+
+// await writes the pending progress once timer fires, unless done is closed first.
+func (t *progressThrottle) await(timer Timer, done <-chan struct{}) {
+	select {
+	case <-timer.C():
+	case <-done:
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.timer != timer {
+		return
+	}
+	t.timer = nil
+	if t.havePending {
+		t.write(t.clk.Now(), t.pending)
+	}
+}
+
+// flush writes the pending progress, and stops the timer.
+func (t *progressThrottle) flush() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.timer != nil {
+		t.timer.Stop()
+		t.timer = nil
+	}
+	close(t.done)
+	if t.havePending {
+		t.write(t.clk.Now(), t.pending)
+	}
+}
+
+// write writes the line of p at now.
+func (t *progressThrottle) write(now time.Time, p Progress) {
+	t.last, t.havePending = now, false
+	data, _ := json.Marshal(progressLine{Time: now, Command: t.command, Progress: p})
+	fmt.Fprintf(t.w, "%s\n", data)
+}
@@ -0,0 +1,149 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+// lineWriter is an io.Writer sending each write to a channel.
+type lineWriter chan string
+
+// Write implements io.Writer.
+func (w lineWriter) Write(p []byte) (int, error) {
+	w <- string(p)
+	return len(p), nil
+}
+
+// decodeProgress decodes the progress lines into generic JSON objects, to assert their schema.
+func decodeProgress(t *testing.T, lines []string) []map[string]interface{} {
+	t.Helper()
+
+	objs := make([]map[string]interface{}, 0, len(lines))
+	for _, line := range lines {
+		if line == "" || line[len(line)-1] != '\n' {
+			t.Fatalf("wanted a newline-terminated line but got %q", line)
+		}
+		var obj map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &obj); err != nil {
+			t.Fatalf("wanted a JSON line but got %q: %v", line, err)
+		}
+		objs = append(objs, obj)
+	}
+
+	return objs
+}
+
+func TestProgressStream(t *testing.T) {
+	defer testcmd.VerifyNoLeaks(t)
+
+	start := time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)
+	clk := testcmd.NewFakeClock(start)
+	w := make(lineWriter, 10)
+	sub := testcmd.NewRecording("sync", testcmd.WithExecute(func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+		subcommandsutil.ReportProgress(ctx, subcommandsutil.Progress{Current: 1, Total: 4, Message: "copying"})
+		subcommandsutil.ReportProgress(ctx, subcommandsutil.Progress{Current: 2, Total: 4, Message: "copying"})
+		subcommandsutil.ReportProgress(ctx, subcommandsutil.Progress{Current: 3, Total: 4, Message: "copying"})
+		clk.BlockUntil(1)
+		clk.Advance(100 * time.Millisecond)
+		for len(w) < 2 {
+			time.Sleep(time.Millisecond)
+		}
+		clk.Advance(50 * time.Millisecond)
+		subcommandsutil.ReportProgress(ctx, subcommandsutil.Progress{Current: 4})
+		return subcommands.ExitSuccess
+	}))
+	cmd := subcommandsutil.ProgressStream(sub, subcommandsutil.WithProgressOutput(w))
+
+	status, _, _ := testcmd.Run(subcommandsutil.WithClock(context.Background(), clk), cmd, "-progress", "json")
+	testcmd.RequireSuccess(t, status)
+	close(w)
+	var lines []string
+	for line := range w {
+		lines = append(lines, line)
+	}
+
+	want := []map[string]interface{}{
+		{"time": "2021-01-02T03:04:05Z", "command": "sync", "current": 1.0, "total": 4.0, "message": "copying"},
+		{"time": "2021-01-02T03:04:05.1Z", "command": "sync", "current": 3.0, "total": 4.0, "message": "copying"},
+		{"time": "2021-01-02T03:04:05.15Z", "command": "sync", "current": 4.0},
+	}
+	if got := decodeProgress(t, lines); !reflect.DeepEqual(got, want) {
+		t.Fatalf("wanted the progress lines %v but got %v", want, got)
+	}
+}
+
+func TestProgressStreamModes(t *testing.T) {
+	tests := map[string]struct {
+		args       []string
+		wantStatus subcommands.ExitStatus
+		wantLines  int
+	}{
+		"when the progress is streamed": {
+			args:      []string{"-progress", "json"},
+			wantLines: 1,
+		},
+		"when the progress is discarded": {
+			args: []string{"-progress", "none"},
+		},
+		"when the progress is not set": {},
+		"when the mode is invalid": {
+			args:       []string{"-progress", "yaml"},
+			wantStatus: subcommands.ExitUsageError,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			sub := testcmd.NewRecording("sync", testcmd.WithExecute(func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+				subcommandsutil.ReportProgress(ctx, subcommandsutil.Progress{Current: 1})
+				return subcommands.ExitSuccess
+			}))
+			var stderr testcmd.Buffer
+			ctx := subcommandsutil.WithOutput(context.Background(), &testcmd.Buffer{}, &stderr)
+
+			status, _, _ := testcmd.Run(ctx, subcommandsutil.ProgressStream(sub), tt.args...)
+			testcmd.AssertStatus(t, status, tt.wantStatus)
+			if tt.wantStatus != subcommands.ExitSuccess {
+				return
+			}
+			lines := strings.SplitAfter(stderr.String(), "\n")
+			if got := len(decodeProgress(t, lines[:len(lines)-1])); got != tt.wantLines {
+				t.Fatalf("wanted %d progress lines but got %q", tt.wantLines, stderr.String())
+			}
+		})
+	}
+}
+
+func TestProgressStreamReused(t *testing.T) {
+	cmd := subcommandsutil.ProgressStream(testcmd.NewRecording("sync", testcmd.WithExecute(func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+		subcommandsutil.ReportProgress(ctx, subcommandsutil.Progress{Current: 1})
+		return subcommands.ExitSuccess
+	})))
+	for _, tt := range []struct {
+		args      []string
+		wantLines int
+	}{
+		{args: []string{"-progress", "json"}, wantLines: 1},
+		{},
+	} {
+		var stderr testcmd.Buffer
+		status, _, _ := testcmd.Run(subcommandsutil.WithOutput(context.Background(), &testcmd.Buffer{}, &stderr), cmd, tt.args...)
+		testcmd.RequireSuccess(t, status)
+		lines := strings.SplitAfter(stderr.String(), "\n")
+		if got := len(decodeProgress(t, lines[:len(lines)-1])); got != tt.wantLines {
+			t.Fatalf("wanted %d progress lines with the arguments %q but got %q", tt.wantLines, tt.args, stderr.String())
+		}
+	}
+}
@@ -0,0 +1,92 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"context"
+	"flag"
+	"io"
+
+	"github.com/google/subcommands"
+)
+
+// quietKey is the context key of the quiet mode.
+type quietKey struct{}
+
+// WithQuiet returns a copy of ctx in quiet mode if quiet is true.
+func WithQuiet(ctx context.Context, quiet bool) context.Context {
+	return context.WithValue(ctx, quietKey{}, quiet)
+}
+
+// IsQuiet reports whether the execution of ctx is in quiet mode.
+func IsQuiet(ctx context.Context) bool {
+	quiet, _ := ctx.Value(quietKey{}).(bool)
+
+	return quiet
+}
+
+// Info returns the writer for the informational output of the execution of ctx. It is Stdout, or
+// io.Discard in quiet mode. Print the data scripts consume to Stdout, and the errors to Stderr.
+func Info(ctx context.Context) io.Writer {
+	if IsQuiet(ctx) {
+		return io.Discard
+	}
+
+	return Stdout(ctx)
+}
+
+// quiet wraps a subcommands.Command so that its informational output can be suppressed.
+type quiet struct {
+	sub subcommands.Command
+
+	quiet bool
+}
+
+// make sure quiet implements the subcommands.Command interface.
+var _ subcommands.Command = (*quiet)(nil)
+
+// Quiet wraps sub with the -q and -quiet flags. When set, the execution context is in quiet mode:
+// Info discards its output, while Stdout and Stderr are left intact, and the Logged wrappers sub
+// wraps log failures only.
+func Quiet(sub subcommands.Command) subcommands.Command {
+	return &quiet{
+		sub: sub,
+	}
+}
+
+// Name forwards to the underlying c.sub Command.
+func (c *quiet) Name() string {
+	return c.sub.Name()
+}
+
+// Usage forwards to the underlying c.sub Command.
+func (c *quiet) Usage() string {
+	return c.sub.Usage()
+}
+
+// Synopsis forwards to the underlying c.sub Command.
+func (c *quiet) Synopsis() string {
+	return c.sub.Synopsis()
+}
+
+// Unwrap returns the underlying c.sub Command.
+func (c *quiet) Unwrap() subcommands.Command {
+	return c.sub
+}
+
+// SetFlags forwards to the underlying c.sub Command and registers the -quiet flag and its -q alias.
+func (c *quiet) SetFlags(f *flag.FlagSet) {
+	c.sub.SetFlags(f)
+	f.BoolVar(&c.quiet, "quiet", false, "print errors and results only")
+	AliasFlag(f, "quiet", "q")
+}
+
+// Execute forwards to the underlying c.sub Command, in quiet mode if the -quiet flag is set.
+func (c *quiet) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	if c.quiet {
+		ctx = WithQuiet(ctx, true)
+	}
+
+	return c.sub.Execute(ctx, f, args...)
+}
@@ -0,0 +1,77 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"testing"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+func TestQuiet(t *testing.T) {
+	tests := map[string]struct {
+		args       []string
+		status     subcommands.ExitStatus
+		wantStdout string
+		wantLogs   int
+	}{
+		"when quiet is not set": {
+			status:     subcommands.ExitSuccess,
+			wantStdout: "fetching\ndata\n",
+			wantLogs:   2,
+		},
+		"when -q is set": {
+			args:       []string{"-q"},
+			status:     subcommands.ExitSuccess,
+			wantStdout: "data\n",
+		},
+		"when -quiet is set": {
+			args:       []string{"-quiet"},
+			status:     subcommands.ExitSuccess,
+			wantStdout: "data\n",
+		},
+		"when -q is set and the command fails": {
+			args:       []string{"-q"},
+			status:     subcommands.ExitFailure,
+			wantStdout: "data\n",
+			wantLogs:   1,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var logs testcmd.LogRecorder
+			cmd := subcommandsutil.Quiet(subcommandsutil.Logged(testcmd.NewRecording("fetch",
+				testcmd.WithExecute(func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+					fmt.Fprintln(subcommandsutil.Info(ctx), "fetching")
+					fmt.Fprintln(subcommandsutil.Stdout(ctx), "data")
+					fmt.Fprintln(subcommandsutil.Stderr(ctx), "warning")
+					return tt.status
+				}),
+			), subcommandsutil.WithLogger(&logs)))
+
+			var stdout, stderr testcmd.Buffer
+			ctx := subcommandsutil.WithOutput(context.Background(), &stdout, &stderr)
+			status, _, err := testcmd.Run(ctx, cmd, tt.args...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			testcmd.AssertStatus(t, status, tt.status)
+			if got := stdout.String(); got != tt.wantStdout {
+				t.Fatalf("wanted stdout %q but got %q", tt.wantStdout, got)
+			}
+			if want := "warning\n"; stderr.String() != want {
+				t.Fatalf("wanted stderr %q but got %q", want, stderr.String())
+			}
+			if got := len(logs.Lines()); got != tt.wantLogs {
+				t.Fatalf("wanted %d log lines but got %d: %q", tt.wantLogs, got, logs.Lines())
+			}
+		})
+	}
+}
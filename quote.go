@@ -0,0 +1,84 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"strings"
+)
+
+// QuoteArgs renders args as a POSIX sh command line, which a shell splits back into args: the
+// words made of letters, digits and "@%+=:,./_-" only are left bare, and the others are wrapped in
+// single quotes, each single quote in them ending the quoting, escaped and reopening it. It is
+// how this package renders the command lines it logs.
+func QuoteArgs(args []string) string {
+	words := make([]string, len(args))
+	for i, arg := range args {
+		words[i] = quoteShellWord(arg)
+	}
+
+	return strings.Join(words, " ")
+}
+
+// quoteShellWord quotes word for a POSIX sh if it is not left bare by QuoteArgs.
+func quoteShellWord(word string) string {
+	if word != "" && strings.IndexFunc(word, isUnsafeShellRune) < 0 {
+		return word
+	}
+
+	return "'" + strings.ReplaceAll(word, "'", `'\''`) + "'"
+}
+
+// isUnsafeShellRune reports whether r needs quoting in a POSIX sh word.
+func isUnsafeShellRune(r rune) bool {
+	switch {
+	case 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z', '0' <= r && r <= '9':
+		return false
+	default:
+		return !strings.ContainsRune("@%+=:,./_-", r)
+	}
+}
+
+// QuoteArgsWindows renders args as a Windows command line, which CommandLineToArgvW and the C
+// runtime split back into args: the words without spaces, tabs, newlines, double quotes or the
+// cmd.exe operators "&|<>^()" are left bare, and the others are wrapped in double quotes, the
+// double quotes and the backslashes preceding them escaped with backslashes. The % and ! of
+// cmd.exe variables are not escaped.
+func QuoteArgsWindows(args []string) string {
+	words := make([]string, len(args))
+	for i, arg := range args {
+		words[i] = quoteWindowsWord(arg)
+	}
+
+	return strings.Join(words, " ")
+}
+
+// quoteWindowsWord quotes word for CommandLineToArgvW if it is not left bare by QuoteArgsWindows.
+func quoteWindowsWord(word string) string {
+	if word != "" && !strings.ContainsAny(word, " \t\n\v\"&|<>^()") {
+		return word
+	}
+
+	var b strings.Builder
+	b.WriteByte('"')
+	backslashes := 0
+	for i := 0; i < len(word); i++ {
+		switch c := word[i]; c {
+		case '\\':
+			backslashes++
+		case '"':
+			b.WriteString(strings.Repeat(`\`, 2*backslashes+1))
+			backslashes = 0
+			b.WriteByte(c)
+		default:
+			b.WriteString(strings.Repeat(`\`, backslashes))
+			backslashes = 0
+			b.WriteByte(c)
+		}
+	}
+	// the backslashes before the closing quote are doubled so that it is not escaped
+	b.WriteString(strings.Repeat(`\`, 2*backslashes))
+	b.WriteByte('"')
+
+	return b.String()
+}
@@ -0,0 +1,121 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/zchee/subcommandsutil"
+)
+
+// quoteCases are the argument lists quoted by the tests.
+var quoteCases = map[string][]string{
+	"when the words are simple":            {"push", "-v", "origin", "main", "a/b.c", "k=v,w@x:1%+_"},
+	"when a word is empty":                 {"commit", "-m", ""},
+	"when a word has spaces":               {"commit", "-m", "fix the build", "\ttab"},
+	"when a word has quotes":               {"echo", "it's", `say "hi"`, `'`, `"`},
+	"when a word has a newline":            {"echo", "line 1\nline 2", "\n"},
+	"when a word has backslashes":          {"copy", `C:\Program Files\`, `a\\"b`, `\`},
+	"when a word has shell metacharacters": {"run", "$HOME", "*.go", "a;b", "a&b|c", "(x)", "^", "<in", "`id`", "!"},
+	"when a word is not ASCII":             {"greet", "héllo wörld", "日本"},
+}
+
+func TestQuoteArgs(t *testing.T) {
+	for name, args := range quoteCases {
+		args := args
+		t.Run(name, func(t *testing.T) {
+			line := subcommandsutil.QuoteArgs(args)
+			got, err := subcommandsutil.SplitShellWords(line)
+			if err != nil {
+				t.Fatalf("wanted %q to split but got %v", line, err)
+			}
+			if !reflect.DeepEqual(got, args) {
+				t.Fatalf("wanted %q to split into %q but got %q", line, args, got)
+			}
+
+			sh, err := exec.LookPath("sh")
+			if err != nil {
+				return
+			}
+			out, err := exec.Command(sh, "-c", "set -- "+line+`; for arg; do printf '%s\0' "$arg"; done`).Output()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.Split(strings.TrimSuffix(string(out), "\x00"), "\x00"); !reflect.DeepEqual(got, args) {
+				t.Fatalf("wanted sh to split %q into %q but got %q", line, args, got)
+			}
+		})
+	}
+}
+
+func TestQuoteArgsBare(t *testing.T) {
+	if got, want := subcommandsutil.QuoteArgs([]string{"push", "-f", "origin", "it's"}), `push -f origin 'it'\''s'`; got != want {
+		t.Fatalf("wanted %s but got %s", want, got)
+	}
+	if got, want := subcommandsutil.QuoteArgsWindows([]string{"copy", `C:\a b\`, `x"y`}), `copy "C:\a b\\" "x\"y"`; got != want {
+		t.Fatalf("wanted %s but got %s", want, got)
+	}
+}
+
+// splitWindowsArgs splits line into arguments like CommandLineToArgvW, not treating the first one
+// specially.
+func splitWindowsArgs(line string) []string {
+	var args []string
+	var arg strings.Builder
+	inArg, quoted := false, false
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case c == '\\':
+			n := 0
+			for ; i < len(line) && line[i] == '\\'; i++ {
+				n++
+			}
+			if i < len(line) && line[i] == '"' {
+				arg.WriteString(strings.Repeat(`\`, n/2))
+				if n%2 == 1 {
+					arg.WriteByte('"')
+				} else {
+					quoted = !quoted
+				}
+			} else {
+				arg.WriteString(strings.Repeat(`\`, n))
+				i--
+			}
+			inArg = true
+		case c == '"':
+			quoted = !quoted
+			inArg = true
+		case (c == ' ' || c == '\t') && !quoted:
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		default:
+			arg.WriteByte(c)
+			inArg = true
+		}
+	}
+	if inArg {
+		args = append(args, arg.String())
+	}
+
+	return args
+}
+
+func TestQuoteArgsWindows(t *testing.T) {
+	for name, args := range quoteCases {
+		args := args
+		t.Run(name, func(t *testing.T) {
+			line := subcommandsutil.QuoteArgsWindows(args)
+			if got := splitWindowsArgs(line); !reflect.DeepEqual(got, args) {
+				t.Fatalf("wanted %s to split into %q but got %q", line, args, got)
+			}
+		})
+	}
+}
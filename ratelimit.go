@@ -0,0 +1,131 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/google/subcommands"
+)
+
+// RateLimitOption is an option of the RateLimited wrapper.
+type RateLimitOption interface {
+	applyRateLimit(*rateLimited)
+}
+
+// rateLimitOptionFunc is a RateLimitOption implemented by a function.
+type rateLimitOptionFunc func(*rateLimited)
+
+// applyRateLimit implements RateLimitOption.
+func (fn rateLimitOptionFunc) applyRateLimit(c *rateLimited) { fn(c) }
+
+// WithRateLimitFailFast makes RateLimited fail immediately with status when executed too soon,
+// printing MessageRateLimited with the time remaining, instead of waiting.
+func WithRateLimitFailFast(status subcommands.ExitStatus) RateLimitOption {
+	return rateLimitOptionFunc(func(c *rateLimited) {
+		c.failFast = true
+		c.status = status
+	})
+}
+
+// rateLimited wraps a subcommands.Command so that its executions are spaced by a minimum interval.
+type rateLimited struct {
+	sub         subcommands.Command
+	minInterval time.Duration
+	statePath   string
+
+	failFast bool
+	status   subcommands.ExitStatus
+}
+
+// make sure rateLimited implements the subcommands.Command interface.
+var _ subcommands.Command = (*rateLimited)(nil)
+
+// RateLimited wraps sub so that it starts at least minInterval after the start of its previous
+// execution, even by another process, such as in a shell loop: the time of the last start is
+// recorded in the file at statePath, like a file in the StateDir of the application. An execution
+// started too soon waits, on the Clock of the execution context, until it is allowed, and fails
+// with subcommands.ExitFailure if the context is done first; with WithRateLimitFailFast, it fails
+// immediately instead.
+//
+// A missing or unreadable state file does not limit the execution, and a failure to record it is
+// reported as a warning to Stderr.
+func RateLimited(sub subcommands.Command, minInterval time.Duration, statePath string, opts ...RateLimitOption) subcommands.Command {
+	c := &rateLimited{
+		sub:         sub,
+		minInterval: minInterval,
+		statePath:   statePath,
+	}
+	for _, opt := range opts {
+		opt.applyRateLimit(c)
+	}
+
+	return c
+}
+
+// Name forwards to the underlying c.sub Command.
+func (c *rateLimited) Name() string {
+	return c.sub.Name()
+}
+
+// Usage forwards to the underlying c.sub Command.
+func (c *rateLimited) Usage() string {
+	return c.sub.Usage()
+}
+
+// Synopsis forwards to the underlying c.sub Command.
+func (c *rateLimited) Synopsis() string {
+	return c.sub.Synopsis()
+}
+
+// Unwrap returns the underlying c.sub Command.
+func (c *rateLimited) Unwrap() subcommands.Command {
+	return c.sub
+}
+
+// SetFlags forwards to the underlying c.sub Command.
+func (c *rateLimited) SetFlags(f *flag.FlagSet) {
+	c.sub.SetFlags(f)
+}
+
+// Execute waits until the minimum interval since the last execution elapsed, records the time and
+// forwards to the underlying c.sub Command.
+func (c *rateLimited) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	clk := ClockFromContext(ctx)
+	if last, ok := c.lastRun(); ok {
+		if wait := last.Add(c.minInterval).Sub(clk.Now()); wait > 0 {
+			if c.failFast {
+				fmt.Fprintln(Stderr(ctx), MessagesFromContext(ctx).Sprintf(MessageRateLimited, c.sub.Name(), wait.Round(time.Millisecond)))
+				return c.status
+			}
+			if err := sleep(ctx, clk, wait); err != nil {
+				fmt.Fprintf(Stderr(ctx), "%s: %v\n", c.sub.Name(), err)
+				return subcommands.ExitFailure
+			}
+		}
+	}
+
+	now := clk.Now().UTC().Format(time.RFC3339Nano)
+	if err := writeFileAtomic(c.statePath, []byte(now+"\n"), 0o600); err != nil {
+		fmt.Fprintf(Stderr(ctx), "warning: %s: recording the last run: %v\n", c.sub.Name(), err)
+	}
+
+	return c.sub.Execute(ctx, f, args...)
+}
+
+// lastRun returns the time of the last execution recorded in the state file, if any.
+func (c *rateLimited) lastRun() (time.Time, bool) {
+	data, err := os.ReadFile(c.statePath)
+	if err != nil {
+		return time.Time{}, false
+	}
+	last, err := time.Parse(time.RFC3339Nano, string(bytes.TrimSpace(data)))
+
+	return last, err == nil
+}
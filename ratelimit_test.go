@@ -0,0 +1,114 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+func TestRateLimitedFailFast(t *testing.T) {
+	start := time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)
+	clk := testcmd.NewFakeClock(start)
+	statePath := filepath.Join(t.TempDir(), "state", "poll.last")
+	sub := testcmd.NewRecording("poll")
+	// each invocation is a new process, sharing only the state file
+	newCmd := func() subcommands.Command {
+		return subcommandsutil.RateLimited(sub, time.Minute, statePath, subcommandsutil.WithRateLimitFailFast(subcommands.ExitUsageError))
+	}
+
+	run := func() (subcommands.ExitStatus, string) {
+		var stderr testcmd.Buffer
+		status, _, _ := testcmd.Run(subcommandsutil.WithOutput(subcommandsutil.WithClock(context.Background(), clk), &testcmd.Buffer{}, &stderr), newCmd())
+		return status, stderr.String()
+	}
+
+	status, _ := run()
+	testcmd.RequireSuccess(t, status)
+	data, err := os.ReadFile(statePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "2021-01-02T03:04:05Z\n"; string(data) != want {
+		t.Fatalf("wanted the state %q but got %q", want, data)
+	}
+
+	clk.Advance(10 * time.Second)
+	status, stderr := run()
+	testcmd.AssertStatus(t, status, subcommands.ExitUsageError)
+	if want := "poll: rate limited, retry in 50s\n"; stderr != want {
+		t.Fatalf("wanted the output %q but got %q", want, stderr)
+	}
+	if got := sub.CallCount(); got != 1 {
+		t.Fatalf("wanted 1 execution but got %d", got)
+	}
+
+	clk.Advance(50 * time.Second)
+	status, _ = run()
+	testcmd.RequireSuccess(t, status)
+	if got := sub.CallCount(); got != 2 {
+		t.Fatalf("wanted 2 executions but got %d", got)
+	}
+}
+
+func TestRateLimitedWait(t *testing.T) {
+	tests := map[string]struct {
+		cancel     bool
+		wantStatus subcommands.ExitStatus
+		wantCalls  int
+		wantStderr string
+	}{
+		"when the interval elapses": {
+			wantCalls: 2,
+		},
+		"when the context is done while waiting": {
+			cancel:     true,
+			wantStatus: subcommands.ExitFailure,
+			wantCalls:  1,
+			wantStderr: "poll: context canceled\n",
+		},
+	}
+	for name, tt := range tests {
+		tt := tt
+		t.Run(name, func(t *testing.T) {
+			defer testcmd.VerifyNoLeaks(t)
+
+			clk := testcmd.NewFakeClock(time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC))
+			statePath := filepath.Join(t.TempDir(), "poll.last")
+			sub := testcmd.NewRecording("poll")
+			cmd := subcommandsutil.RateLimited(sub, time.Minute, statePath)
+
+			ctx, cancel := context.WithCancel(subcommandsutil.WithClock(context.Background(), clk))
+			defer cancel()
+			status, _, _ := testcmd.Run(ctx, cmd)
+			testcmd.RequireSuccess(t, status)
+
+			go func() {
+				clk.BlockUntil(1)
+				if tt.cancel {
+					cancel()
+					return
+				}
+				clk.Advance(time.Minute)
+			}()
+			var stderr testcmd.Buffer
+			status, _, _ = testcmd.Run(subcommandsutil.WithOutput(ctx, &testcmd.Buffer{}, &stderr), cmd)
+			testcmd.AssertStatus(t, status, tt.wantStatus)
+			if got := sub.CallCount(); got != tt.wantCalls {
+				t.Fatalf("wanted %d executions but got %d", tt.wantCalls, got)
+			}
+			if got := stderr.String(); got != tt.wantStderr {
+				t.Fatalf("wanted the output %q but got %q", tt.wantStderr, got)
+			}
+		})
+	}
+}
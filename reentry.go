@@ -0,0 +1,44 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"sync/atomic"
+)
+
+// PanicOnReentryEnv is the environment variable which, when set to a true value such as "1", makes
+// Cancelable, Timeout, Retry, Sequence and Parallel panic when an instance is executed again before
+// its execution returns, instead of logging MessageAlreadyExecuting and failing, to find the
+// callers sharing an instance in tests.
+const PanicOnReentryEnv = "SUBCOMMANDSUTIL_PANIC_ON_REENTRY"
+
+// executing guards a wrapper keeping the state of its execution in its fields against concurrent
+// executions of the same instance. Sequential executions are unaffected.
+type executing struct {
+	running int32 // accessed atomically
+}
+
+// enter marks an execution of the command named name as running. If one already is, it logs
+// MessageAlreadyExecuting to logger and reports false, or panics if PanicOnReentryEnv is set.
+func (e *executing) enter(ctx context.Context, logger Logger, name string) bool {
+	if atomic.CompareAndSwapInt32(&e.running, 0, 1) {
+		return true
+	}
+
+	msg := MessagesFromContext(ctx).Sprintf(MessageAlreadyExecuting, name)
+	if panics, _ := strconv.ParseBool(os.Getenv(PanicOnReentryEnv)); panics {
+		panic(msg)
+	}
+	contextLogger(ctx, logger).Printf("%s", msg)
+
+	return false
+}
+
+// exit marks the execution entered as returned.
+func (e *executing) exit() {
+	atomic.StoreInt32(&e.running, 0)
+}
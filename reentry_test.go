@@ -0,0 +1,107 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+func TestConcurrentExecute(t *testing.T) {
+	tests := map[string]struct {
+		wrap func(sub *testcmd.Blocking) subcommands.Command
+		name string
+	}{
+		"when it is Cancelable": {
+			wrap: func(sub *testcmd.Blocking) subcommands.Command { return subcommandsutil.Cancelable(sub) },
+			name: "build",
+		},
+		"when it is Timeout": {
+			wrap: func(sub *testcmd.Blocking) subcommands.Command { return subcommandsutil.Timeout(sub, 0) },
+			name: "build",
+		},
+		"when it is Retry": {
+			wrap: func(sub *testcmd.Blocking) subcommands.Command { return subcommandsutil.Retry(sub) },
+			name: "build",
+		},
+		"when it is Sequence": {
+			wrap: func(sub *testcmd.Blocking) subcommands.Command {
+				return subcommandsutil.Sequence("ci", "runs the build", []subcommandsutil.CancelableCommand{sub})
+			},
+			name: "ci",
+		},
+		"when it is Parallel": {
+			wrap: func(sub *testcmd.Blocking) subcommands.Command {
+				return subcommandsutil.Parallel("ci", "runs the build", []subcommandsutil.CancelableCommand{sub})
+			},
+			name: "ci",
+		},
+	}
+	for name, tt := range tests {
+		tt := tt
+		t.Run(name, func(t *testing.T) {
+			defer testcmd.VerifyNoLeaks(t)
+
+			sub := testcmd.NewBlocking("build")
+			defer sub.Release(subcommands.ExitSuccess)
+			cmd := tt.wrap(sub)
+
+			done := make(chan subcommands.ExitStatus)
+			go func() {
+				status, _, _ := testcmd.Run(subcommandsutil.WithOutput(context.Background(), &testcmd.Buffer{}, &testcmd.Buffer{}), cmd)
+				done <- status
+			}()
+			<-sub.Started()
+
+			var stderr testcmd.Buffer
+			status, _, _ := testcmd.Run(subcommandsutil.WithOutput(context.Background(), &testcmd.Buffer{}, &stderr), cmd)
+			testcmd.AssertStatus(t, status, subcommands.ExitFailure)
+			if want := tt.name + ": command already executing\n"; !strings.HasSuffix(stderr.String(), want) {
+				t.Fatalf("wanted the output %q but got %q", want, stderr.String())
+			}
+
+			sub.Release(subcommands.ExitSuccess)
+			testcmd.RequireSuccess(t, <-done)
+
+			status, _, _ = testcmd.Run(subcommandsutil.WithOutput(context.Background(), &testcmd.Buffer{}, &testcmd.Buffer{}), cmd)
+			testcmd.RequireSuccess(t, status)
+			if got := sub.CallCount(); got != 2 {
+				t.Fatalf("wanted 2 executions but got %d", got)
+			}
+		})
+	}
+}
+
+func TestConcurrentExecutePanics(t *testing.T) {
+	t.Setenv(subcommandsutil.PanicOnReentryEnv, "1")
+
+	sub := testcmd.NewBlocking("build")
+	defer sub.Release(subcommands.ExitSuccess)
+	cmd := subcommandsutil.Cancelable(sub, subcommandsutil.WithLogger(&testcmd.LogRecorder{}))
+
+	done := make(chan subcommands.ExitStatus)
+	go func() {
+		status, _, _ := testcmd.Run(context.Background(), cmd)
+		done <- status
+	}()
+	<-sub.Started()
+
+	func() {
+		defer func() {
+			if r := recover(); r != "build: command already executing" {
+				t.Fatalf("wanted a panic but got %v", r)
+			}
+		}()
+		testcmd.Run(context.Background(), cmd)
+	}()
+
+	sub.Release(subcommands.ExitSuccess)
+	testcmd.RequireSuccess(t, <-done)
+}
@@ -0,0 +1,64 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"fmt"
+
+	"github.com/google/subcommands"
+)
+
+// RegisterCancelable wraps sub with Cancelable, applying opts, and registers it in group of cdr.
+// It returns the wrapped command so that the caller can Stop it or check whether it was Canceled.
+func RegisterCancelable(cdr *subcommands.Commander, sub CancelableCommand, group string, opts ...CancelableOption) CancelableWrapper {
+	cmd := Cancelable(sub, opts...).(CancelableWrapper)
+	cdr.Register(cmd, group)
+
+	return cmd
+}
+
+// RegisterCancelableDefault is like RegisterCancelable but registers in
+// subcommands.DefaultCommander.
+func RegisterCancelableDefault(sub CancelableCommand, group string, opts ...CancelableOption) CancelableWrapper {
+	return RegisterCancelable(subcommands.DefaultCommander, sub, group, opts...)
+}
+
+// CommandSet is a group of commands registered together by RegisterAll.
+type CommandSet struct {
+	// Group is the name of the group the commands are registered in.
+	Group string
+
+	// Commands are the commands of the group.
+	Commands []subcommands.Command
+}
+
+// RegisterAll wraps every command of sets with mws, as Chain does, and registers it in the group of
+// its set in cdr. Sets and commands are registered in order.
+//
+// RegisterAll panics before registering anything if two commands share a name, or if a command has
+// the name of a command already registered in cdr.
+func RegisterAll(cdr *subcommands.Commander, sets []CommandSet, mws ...Middleware) {
+	groups := make(map[string]string) // command name -> group
+	cdr.VisitCommands(func(g *subcommands.CommandGroup, cmd subcommands.Command) {
+		groups[cmd.Name()] = g.Name()
+	})
+
+	wrapped := make([][]subcommands.Command, len(sets))
+	for i, set := range sets {
+		for _, cmd := range set.Commands {
+			cmd = Chain(cmd, mws...)
+			if group, ok := groups[cmd.Name()]; ok {
+				panic(fmt.Sprintf("subcommandsutil: command %q of group %q is already registered in group %q", cmd.Name(), set.Group, group))
+			}
+			groups[cmd.Name()] = set.Group
+			wrapped[i] = append(wrapped[i], cmd)
+		}
+	}
+
+	for i, set := range sets {
+		for _, cmd := range wrapped[i] {
+			cdr.Register(cmd, set.Group)
+		}
+	}
+}
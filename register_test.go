@@ -0,0 +1,138 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+func TestRegisterCancelable(t *testing.T) {
+	defer testcmd.VerifyNoLeaks(t)
+
+	h := testcmd.NewHarness(t)
+	logger := subcommandsutil.WithLogger(&testcmd.LogRecorder{})
+	finish := testcmd.NewRecording("finish")
+	finishCmd := subcommandsutil.RegisterCancelable(h.Commander, finish, "test", logger)
+	block := testcmd.NewBlocking("block")
+	defer block.Release(subcommands.ExitSuccess)
+	blockCmd := subcommandsutil.RegisterCancelable(h.Commander, block, "test", logger)
+
+	testcmd.RequireSuccess(t, h.Execute(context.Background(), "finish"))
+	if !finish.DidFinish() || finishCmd.Canceled() {
+		t.Fatal("wanted the registered command to be dispatched and to finish")
+	}
+
+	done := make(chan subcommands.ExitStatus, 1)
+	go func() {
+		done <- h.Execute(context.Background(), "block")
+	}()
+	<-block.Started()
+	blockCmd.Stop()
+
+	testcmd.AssertStatus(t, <-done, subcommands.ExitFailure)
+	testcmd.AssertCanceled(t, blockCmd)
+	if block.DisposeCount() != 1 {
+		t.Fatalf("wanted the stopped command to be disposed once but got %d", block.DisposeCount())
+	}
+
+	// stopping without a running execution does nothing
+	finishCmd.Stop()
+	testcmd.RequireSuccess(t, h.Execute(context.Background(), "finish"))
+}
+
+func TestRegisterCancelableDefault(t *testing.T) {
+	cmd := subcommandsutil.RegisterCancelableDefault(testcmd.NewRecording("register_cancelable_default"), "test")
+
+	var found subcommands.Command
+	subcommands.DefaultCommander.VisitCommands(func(_ *subcommands.CommandGroup, c subcommands.Command) {
+		if c.Name() == "register_cancelable_default" {
+			found = c
+		}
+	})
+	if found != cmd {
+		t.Fatalf("wanted the wrapped command to be registered in the default Commander but got %v", found)
+	}
+}
+
+func TestRegisterAll(t *testing.T) {
+	var tags []string
+	h := testcmd.NewHarness(t)
+	subcommandsutil.RegisterAll(h.Commander, []subcommandsutil.CommandSet{
+		{Group: "remote", Commands: []subcommands.Command{
+			testcmd.NewRecording("push"),
+			subcommandsutil.Cancelable(testcmd.NewRecording("pull")),
+		}},
+		{Group: "local", Commands: []subcommands.Command{
+			testcmd.NewRecording("commit"),
+		}},
+	}, tagging(&tags, "mw"))
+
+	var got []string
+	h.Commander.VisitCommands(func(g *subcommands.CommandGroup, cmd subcommands.Command) {
+		if g.Name() == "help" {
+			return
+		}
+		got = append(got, g.Name()+"/"+cmd.Name())
+		if subcommandsutil.Unwrap(cmd) == nil {
+			t.Fatalf("wanted %q to be wrapped by the Middleware", cmd.Name())
+		}
+	})
+	// the Commander visits groups by name and commands in registration order
+	want := []string{"local/commit", "remote/push", "remote/pull"}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Fatalf("wanted %q but got %q", want, got)
+	}
+	if len(tags) != 3 {
+		t.Fatalf("wanted the Middleware to wrap each command once but got %q", tags)
+	}
+
+	testcmd.RequireSuccess(t, h.Execute(context.Background(), "commit"))
+}
+
+func TestRegisterAllDuplicate(t *testing.T) {
+	tests := map[string]struct {
+		sets      []subcommandsutil.CommandSet
+		wantPanic string
+	}{
+		"when two sets share a command name": {
+			sets: []subcommandsutil.CommandSet{
+				{Group: "a", Commands: []subcommands.Command{testcmd.NewRecording("status")}},
+				{Group: "b", Commands: []subcommands.Command{testcmd.NewRecording("sync"), testcmd.NewRecording("status")}},
+			},
+			wantPanic: `subcommandsutil: command "status" of group "b" is already registered in group "a"`,
+		},
+		"when a command is already registered": {
+			sets: []subcommandsutil.CommandSet{
+				{Group: "a", Commands: []subcommands.Command{testcmd.NewRecording("sync"), testcmd.NewRecording("help")}},
+			},
+			wantPanic: `subcommandsutil: command "help" of group "a" is already registered in group "help"`,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			h := testcmd.NewHarness(t)
+			func() {
+				defer func() {
+					if r := recover(); r != tt.wantPanic {
+						t.Fatalf("wanted panic %q but got %v", tt.wantPanic, r)
+					}
+				}()
+				subcommandsutil.RegisterAll(h.Commander, tt.sets)
+			}()
+
+			h.Commander.VisitCommands(func(_ *subcommands.CommandGroup, cmd subcommands.Command) {
+				if cmd.Name() == "sync" {
+					t.Fatal("wanted nothing to be registered")
+				}
+			})
+		})
+	}
+}
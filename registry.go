@@ -0,0 +1,303 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/google/subcommands"
+)
+
+// Registry is the machine-readable description of the commands registered in a Commander, returned
+// by DumpRegistry. Its JSON field names are stable.
+type Registry struct {
+	// Name is the name of the program.
+	Name string `json:"name"`
+
+	// Commands are the commands of the program, in the order of their registration.
+	Commands []RegistryCommand `json:"commands"`
+}
+
+// RegistryCommand describes a command in a Registry.
+type RegistryCommand struct {
+	// Name is the name of the command.
+	Name string `json:"name"`
+
+	// Group is the group the command is registered in, like "help" for the help command.
+	Group string `json:"group"`
+
+	// Synopsis is the synopsis of the command.
+	Synopsis string `json:"synopsis"`
+
+	// Usage is the usage message of the command.
+	Usage string `json:"usage"`
+
+	// Hidden reports whether the command is hidden from the listings.
+	Hidden bool `json:"hidden"`
+
+	// Deprecated is the message of the command wrapped in Deprecated, or empty.
+	Deprecated string `json:"deprecated,omitempty"`
+
+	// AliasOf is the name of the command an Alias stands for, or empty.
+	AliasOf string `json:"alias_of,omitempty"`
+
+	// Flags are the flags of the command in lexical order, aliases excluded.
+	Flags []RegistryFlag `json:"flags"`
+
+	// Args describes the ArgsSpec of the command, if it declares one.
+	Args *RegistryArgs `json:"args,omitempty"`
+
+	// Examples are the examples of an Exampler.
+	Examples []Example `json:"examples,omitempty"`
+
+	// Commands are the commands nested in a Group, in the order of their registration.
+	Commands []RegistryCommand `json:"commands,omitempty"`
+}
+
+// RegistryFlag describes a flag in a Registry.
+type RegistryFlag struct {
+	// Name is the name of the flag.
+	Name string `json:"name"`
+
+	// Aliases are the aliases of the flag registered by AliasFlag, shortest first.
+	Aliases []string `json:"aliases,omitempty"`
+
+	// Type is the type of the value of the flag: "bool", "int", "uint", "float", "string",
+	// "duration", "enum" for an EnumValue, or "value" for the other flag.Value implementations.
+	Type string `json:"type"`
+
+	// Default is the default value of the flag, or empty if the flag is sensitive.
+	Default string `json:"default"`
+
+	// Usage is the usage message of the flag, with the backquotes of the value name removed.
+	Usage string `json:"usage"`
+
+	// Hidden reports whether the flag is hidden by HideFlags, or deprecated by DeprecateFlag.
+	Hidden bool `json:"hidden,omitempty"`
+
+	// DeprecatedBy is the name of the flag replacing a flag deprecated by DeprecateFlag.
+	DeprecatedBy string `json:"deprecated_by,omitempty"`
+
+	// Sensitive reports whether the flag is marked by MarkSensitive.
+	Sensitive bool `json:"sensitive,omitempty"`
+
+	// Choices are the values accepted by an EnumValue.
+	Choices []string `json:"choices,omitempty"`
+
+	// Group is the title of the group the flag was put in by FlagGroup, or empty.
+	Group string `json:"group,omitempty"`
+}
+
+// RegistryArgs describes the ArgsSpec of a command in a Registry.
+type RegistryArgs struct {
+	// Spec renders the positional arguments, like "SRC [DST]".
+	Spec string `json:"spec"`
+
+	// Min is the minimum number of positional arguments.
+	Min int `json:"min"`
+
+	// Max is the maximum number of positional arguments, or -1 if unbounded.
+	Max int `json:"max"`
+}
+
+// DumpRegistry returns the Registry of the commands registered in cdr, hidden ones included,
+// recursing into the commands of the Groups. It fails if the SetFlags method of a command panics,
+// like on a flag defined twice.
+func DumpRegistry(cdr *subcommands.Commander) (*Registry, error) {
+	r := &Registry{Name: cdr.Name(), Commands: []RegistryCommand{}}
+	var err error
+	cdr.VisitCommands(func(g *subcommands.CommandGroup, cmd subcommands.Command) {
+		if err != nil {
+			return
+		}
+		var rc RegistryCommand
+		if rc, err = newRegistryCommand(cmd, g.Name()); err == nil {
+			r.Commands = append(r.Commands, rc)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// newRegistryCommand returns the RegistryCommand of cmd, registered in group.
+func newRegistryCommand(cmd subcommands.Command, group string) (rc RegistryCommand, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("subcommandsutil: command %s: setting the flags: %v", cmd.Name(), r)
+		}
+	}()
+
+	rc = RegistryCommand{
+		Name:     cmd.Name(),
+		Group:    group,
+		Synopsis: cmd.Synopsis(),
+		Usage:    cmd.Usage(),
+		Hidden:   IsHiddenCommand(cmd),
+		Flags:    []RegistryFlag{},
+	}
+	var g *Group
+	walkCommand(cmd, func(cmd subcommands.Command) bool {
+		switch c := cmd.(type) {
+		case *deprecated:
+			if rc.Deprecated == "" {
+				rc.Deprecated = c.message
+			}
+		case *alias:
+			if rc.AliasOf == "" {
+				rc.AliasOf = c.sub.Name()
+			}
+		case Exampler:
+			if rc.Examples == nil {
+				rc.Examples = c.Examples()
+			}
+		case *Group:
+			g = c
+		}
+		return g != nil
+	})
+	if spec, ok := ArgsSpecOf(cmd); ok {
+		rc.Args = &RegistryArgs{Spec: spec.String(), Min: spec.Min, Max: spec.Max}
+	}
+
+	f := flag.NewFlagSet(cmd.Name(), flag.PanicOnError)
+	f.SetOutput(io.Discard)
+	cmd.SetFlags(f)
+	f.VisitAll(func(fl *flag.Flag) {
+		if !isAliasFlag(f, fl.Name) {
+			rc.Flags = append(rc.Flags, newRegistryFlag(f, fl))
+		}
+	})
+
+	if g != nil {
+		for _, e := range g.entries {
+			sub, err := newRegistryCommand(e.cmd, e.category)
+			if err != nil {
+				return RegistryCommand{}, err
+			}
+			rc.Commands = append(rc.Commands, sub)
+		}
+	}
+
+	return rc, nil
+}
+
+// newRegistryFlag returns the RegistryFlag of fl in f.
+func newRegistryFlag(f *flag.FlagSet, fl *flag.Flag) RegistryFlag {
+	d := newFlagData(f, fl, DefaultWidth)
+	rf := RegistryFlag{
+		Name:         fl.Name,
+		Aliases:      d.Aliases,
+		Type:         flagType(fl),
+		Usage:        d.Usage,
+		Hidden:       d.Hidden,
+		DeprecatedBy: d.Deprecated,
+		Sensitive:    d.Sensitive,
+		Group:        d.Group,
+	}
+	if !rf.Sensitive {
+		rf.Default = fl.DefValue
+	}
+	if enum, ok := fl.Value.(EnumValue); ok {
+		rf.Choices = enum.Values()
+	}
+	if len(rf.Aliases) == 0 {
+		rf.Aliases = nil
+	}
+
+	return rf
+}
+
+// flagType returns the Type of fl in a RegistryFlag.
+func flagType(fl *flag.Flag) string {
+	if _, ok := fl.Value.(EnumValue); ok {
+		return "enum"
+	}
+	if b, ok := fl.Value.(interface{ IsBoolFlag() bool }); ok && b.IsBoolFlag() {
+		return "bool"
+	}
+	g, ok := fl.Value.(flag.Getter)
+	if !ok {
+		return "value"
+	}
+	switch g.Get().(type) {
+	case time.Duration:
+		return "duration"
+	case int, int64:
+		return "int"
+	case uint, uint64:
+		return "uint"
+	case float64:
+		return "float"
+	case string:
+		return "string"
+	default:
+		return "value"
+	}
+}
+
+// dump is the command printing the Registry of a Commander.
+type dump struct {
+	cdr *subcommands.Commander
+}
+
+// make sure dump implements the subcommands.Command interface.
+var _ subcommands.Command = (*dump)(nil)
+
+// DumpCommand returns a hidden command named "__dump" printing the Registry of the commands
+// registered in cdr, returned by DumpRegistry, as indented JSON, for the tools describing the
+// program, like a documentation pipeline.
+func DumpCommand(cdr *subcommands.Commander) subcommands.Command {
+	return Hidden(&dump{
+		cdr: cdr,
+	})
+}
+
+// Name implements subcommands.Command.
+func (c *dump) Name() string {
+	return "__dump"
+}
+
+// Synopsis implements subcommands.Command.
+func (c *dump) Synopsis() string {
+	return "print the description of the commands as JSON"
+}
+
+// Usage implements subcommands.Command.
+func (c *dump) Usage() string {
+	return "__dump:\n  Print the description of the commands as JSON.\n"
+}
+
+// SetFlags implements subcommands.Command.
+func (c *dump) SetFlags(*flag.FlagSet) {}
+
+// Execute implements subcommands.Command.
+func (c *dump) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	if f.NArg() != 0 {
+		fmt.Fprintf(Stderr(ctx), "%s: unexpected arguments %q\n", c.Name(), f.Args())
+		return subcommands.ExitUsageError
+	}
+
+	r, err := DumpRegistry(c.cdr)
+	if err != nil {
+		fmt.Fprintf(Stderr(ctx), "%s: %v\n", c.Name(), err)
+		return subcommands.ExitFailure
+	}
+	enc := json.NewEncoder(Stdout(ctx))
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(r); err != nil {
+		fmt.Fprintf(Stderr(ctx), "%s: %v\n", c.Name(), err)
+		return subcommands.ExitFailure
+	}
+
+	return subcommands.ExitSuccess
+}
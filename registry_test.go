@@ -0,0 +1,96 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+// newRegistryCommander returns the Commander of the docs tests with deprecated, aliased and
+// categorized commands, and flags of every type.
+func newRegistryCommander() *subcommands.Commander {
+	cdr, _ := newDocsCommander()
+
+	format := formatValue("text")
+	sync := testcmd.NewRecording("sync",
+		testcmd.WithSynopsis("sync the mirrors"),
+		testcmd.WithFlags(func(f *flag.FlagSet) {
+			f.Var(&format, "format", "the output `format`")
+			f.Duration("timeout", time.Minute, "the timeout of the sync")
+			f.Int("jobs", 4, "the number of parallel jobs")
+			f.Float64("ratio", 0.5, "the ratio of mirrors to sync")
+			f.String("cache", os.TempDir(), "the cache directory")
+			subcommandsutil.FlagGroup(f, "Output", "format")
+		}),
+	)
+	cdr.Register(sync, "mirrors")
+	cdr.Register(subcommandsutil.Alias(sync, "pull"), "mirrors")
+	cdr.Register(subcommandsutil.Deprecated(testcmd.NewRecording("fetch", testcmd.WithSynopsis("fetch the mirrors")), "use 'sync' instead"), "mirrors")
+	cdr.Register(subcommandsutil.DumpCommand(cdr), "")
+
+	return cdr
+}
+
+func TestDumpCommand(t *testing.T) {
+	cdr := newRegistryCommander()
+	var dump subcommands.Command
+	cdr.VisitCommands(func(_ *subcommands.CommandGroup, cmd subcommands.Command) {
+		if cmd.Name() == "__dump" {
+			dump = cmd
+		}
+	})
+	if !subcommandsutil.IsHiddenCommand(dump) {
+		t.Fatal("wanted the __dump command hidden")
+	}
+
+	var stdout testcmd.Buffer
+	ctx := subcommandsutil.WithOutput(context.Background(), &stdout, &testcmd.Buffer{})
+	status, _, _ := testcmd.Run(ctx, dump)
+	testcmd.RequireSuccess(t, status)
+	// the default of -cache depends on the environment
+	tmp, err := json.Marshal(os.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	testcmd.Golden(t, strings.ReplaceAll(stdout.String(), string(tmp), `"$TMPDIR"`), "testdata/registry.json.golden")
+
+	var r subcommandsutil.Registry
+	if err := json.Unmarshal([]byte(stdout.String()), &r); err != nil {
+		t.Fatalf("wanted the registry as JSON but got %v", err)
+	}
+	want, err := subcommandsutil.DumpRegistry(cdr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := r.Commands[1].Commands[2]; !reflect.DeepEqual(got, want.Commands[1].Commands[2]) || got.Name != "prune" || !got.Hidden {
+		t.Fatalf("wanted the hidden nested command prune as returned by DumpRegistry but got %+v", got)
+	}
+
+	status, _, _ = testcmd.Run(ctx, dump, "extra")
+	testcmd.AssertStatus(t, status, subcommands.ExitUsageError)
+}
+
+func TestDumpRegistryError(t *testing.T) {
+	cdr := subcommands.NewCommander(flag.NewFlagSet("tool", flag.ContinueOnError), "tool")
+	cdr.Register(testcmd.NewRecording("build", testcmd.WithFlags(func(f *flag.FlagSet) {
+		f.Bool("v", false, "verbose output")
+		f.Bool("v", false, "verbose output")
+	})), "")
+
+	if _, err := subcommandsutil.DumpRegistry(cdr); err == nil || !strings.HasPrefix(err.Error(), "subcommandsutil: command build: setting the flags: ") {
+		t.Fatalf("wanted the flag defined twice reported but got %v", err)
+	}
+}
@@ -0,0 +1,151 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"context"
+	"flag"
+	"time"
+
+	"github.com/google/subcommands"
+)
+
+// RepeatOption is an option of the Repeat wrapper.
+type RepeatOption interface {
+	applyRepeat(*repeat)
+}
+
+// applyRepeat implements RepeatOption.
+func (o LoggerOption) applyRepeat(c *repeat) {
+	c.logger = o.logger
+}
+
+// repeatIterationKey is the context key of the iteration of Repeat.
+type repeatIterationKey struct{}
+
+// RepeatIteration returns the iteration of the execution by Repeat carried by ctx, starting at 1,
+// or 0 outside of Repeat.
+func RepeatIteration(ctx context.Context) int {
+	n, _ := ctx.Value(repeatIterationKey{}).(int)
+
+	return n
+}
+
+// repeat wraps a CancelableCommand so that it is executed several times.
+type repeat struct {
+	sub    CancelableCommand
+	logger Logger
+
+	count     int
+	keepGoing bool
+}
+
+// make sure repeat implements the CancelableCommand interface.
+var _ CancelableCommand = (*repeat)(nil)
+
+// Repeat wraps sub with the -count and -keep-going flags. sub is executed -count times in order, 0
+// or 1 meaning once, stopping at the first execution returning a status other than
+// subcommands.ExitSuccess unless -keep-going is set. Each execution gets a context carrying its
+// iteration, returned by RepeatIteration.
+//
+// When sub is executed more than once, a summary with the number of successes and failures and the
+// shortest, average and longest duration, measured on the Clock of the execution context, is logged
+// to the standard logger unless WithLogger is given. The first status other than
+// subcommands.ExitSuccess is returned; if the context is done before the last execution,
+// subcommands.ExitFailure is.
+func Repeat(sub CancelableCommand, opts ...RepeatOption) CancelableCommand {
+	c := &repeat{
+		sub:    sub,
+		logger: stdLogger{},
+	}
+	for _, opt := range opts {
+		opt.applyRepeat(c)
+	}
+
+	return c
+}
+
+// Name forwards to the underlying c.sub Command.
+func (c *repeat) Name() string {
+	return c.sub.Name()
+}
+
+// Usage forwards to the underlying c.sub Command.
+func (c *repeat) Usage() string {
+	return c.sub.Usage()
+}
+
+// Synopsis forwards to the underlying c.sub Command.
+func (c *repeat) Synopsis() string {
+	return c.sub.Synopsis()
+}
+
+// Unwrap returns the underlying c.sub Command.
+func (c *repeat) Unwrap() subcommands.Command {
+	return c.sub
+}
+
+// SetFlags forwards to the underlying c.sub Command and registers the -count and -keep-going flags.
+func (c *repeat) SetFlags(f *flag.FlagSet) {
+	c.sub.SetFlags(f)
+	f.IntVar(&c.count, "count", 1, "run `n` times")
+	f.BoolVar(&c.keepGoing, "keep-going", false, "keep running after a failed run")
+}
+
+// Dispose forwards to the underlying c.sub Command.
+func (c *repeat) Dispose() error {
+	return c.sub.Dispose()
+}
+
+// Execute forwards to the underlying c.sub Command -count times.
+func (c *repeat) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	if c.count <= 1 {
+		return c.sub.Execute(context.WithValue(ctx, repeatIterationKey{}, 1), f, args...)
+	}
+
+	clk := ClockFromContext(ctx)
+	status := subcommands.ExitSuccess
+	var (
+		succeeded, failed        int
+		total, shortest, longest time.Duration
+	)
+	for i := 1; i <= c.count; i++ {
+		if ctx.Err() != nil {
+			status = subcommands.ExitFailure
+			break
+		}
+
+		start := clk.Now()
+		s := c.sub.Execute(context.WithValue(ctx, repeatIterationKey{}, i), f, args...)
+		d := clk.Now().Sub(start)
+
+		total += d
+		if i == 1 || d < shortest {
+			shortest = d
+		}
+		if d > longest {
+			longest = d
+		}
+		if s == subcommands.ExitSuccess {
+			succeeded++
+			continue
+		}
+		failed++
+		if status == subcommands.ExitSuccess {
+			status = s
+		}
+		if !c.keepGoing {
+			break
+		}
+	}
+
+	runs := succeeded + failed
+	var avg time.Duration
+	if runs > 0 {
+		avg = total / time.Duration(runs)
+	}
+	contextLogger(ctx, c.logger).Printf("%s: %d of %d runs, %d succeeded, %d failed; duration min %v, avg %v, max %v", c.sub.Name(), runs, c.count, succeeded, failed, shortest, avg, longest)
+
+	return status
+}
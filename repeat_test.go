@@ -0,0 +1,97 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"context"
+	"flag"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+func TestRepeat(t *testing.T) {
+	tests := map[string]struct {
+		args           []string
+		wantStatus     subcommands.ExitStatus
+		wantIterations []int
+		wantLogs       []string
+	}{
+		"when running once": {
+			wantStatus:     subcommands.ExitSuccess,
+			wantIterations: []int{1},
+		},
+		"when stopping at the first failure": {
+			args:           []string{"-count=3"},
+			wantStatus:     subcommands.ExitUsageError,
+			wantIterations: []int{1, 2},
+			wantLogs:       []string{"soak: 2 of 3 runs, 1 succeeded, 1 failed; duration min 1s, avg 1.5s, max 2s"},
+		},
+		"when keeping going after a failure": {
+			args:           []string{"-count=3", "-keep-going"},
+			wantStatus:     subcommands.ExitUsageError,
+			wantIterations: []int{1, 2, 3},
+			wantLogs:       []string{"soak: 3 of 3 runs, 2 succeeded, 1 failed; duration min 1s, avg 2s, max 3s"},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			clk := testcmd.NewFakeClock(time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC))
+			sub := testcmd.NewRecording("soak", testcmd.WithExecute(func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+				n := subcommandsutil.RepeatIteration(ctx)
+				clk.Advance(time.Duration(n) * time.Second)
+				if n == 2 {
+					return subcommands.ExitUsageError
+				}
+				return subcommands.ExitSuccess
+			}))
+			var logs testcmd.LogRecorder
+			cmd := subcommandsutil.Repeat(sub, subcommandsutil.WithLogger(&logs))
+
+			status, _, err := testcmd.Run(subcommandsutil.WithClock(context.Background(), clk), cmd, tt.args...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			testcmd.AssertStatus(t, status, tt.wantStatus)
+
+			var got []int
+			for _, call := range sub.Calls() {
+				got = append(got, subcommandsutil.RepeatIteration(call.Ctx))
+			}
+			if !reflect.DeepEqual(got, tt.wantIterations) {
+				t.Fatalf("wanted the iterations %v but got %v", tt.wantIterations, got)
+			}
+			if got := logs.Lines(); len(got) != len(tt.wantLogs) || (len(got) > 0 && !reflect.DeepEqual(got, tt.wantLogs)) {
+				t.Fatalf("wanted the logs %q but got %q", tt.wantLogs, logs.Lines())
+			}
+		})
+	}
+}
+
+func TestRepeatCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sub := testcmd.NewRecording("soak", testcmd.WithExecute(func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+		cancel()
+		return subcommands.ExitSuccess
+	}))
+	var logs testcmd.LogRecorder
+	status, _, err := testcmd.Run(ctx, subcommandsutil.Repeat(sub, subcommandsutil.WithLogger(&logs)), "-count=100")
+	if err != nil {
+		t.Fatal(err)
+	}
+	testcmd.AssertStatus(t, status, subcommands.ExitFailure)
+	if sub.CallCount() != 1 {
+		t.Fatalf("wanted the repetition to stop after the cancellation but got %d calls", sub.CallCount())
+	}
+	if want := "soak: 1 of 100 runs, 1 succeeded, 0 failed"; !logs.Contains(want) {
+		t.Fatalf("wanted the logs to hold %q but got %q", want, logs.Lines())
+	}
+}
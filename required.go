@@ -0,0 +1,188 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/google/subcommands"
+)
+
+// MarkRequired marks the named flags of f as required by the RequireFlags wrapper.
+//
+// MarkRequired is usually called from SetFlags, after the flags are defined. It panics if f does
+// not define one of names.
+func MarkRequired(f *flag.FlagSet, names ...string) {
+	for _, name := range names {
+		mustLookup(f, name)
+	}
+
+	updateFlagMeta(f, func(m *flagMeta) {
+		for _, name := range names {
+			m.required[name] = true
+		}
+	})
+}
+
+// IsRequiredFlag reports whether the flag named name of f was marked by MarkRequired.
+func IsRequiredFlag(f *flag.FlagSet, name string) (required bool) {
+	readFlagMeta(f, func(m *flagMeta) {
+		required = m != nil && m.required[name]
+	})
+
+	return required
+}
+
+// RequireOption is an option of the RequireFlags wrapper.
+type RequireOption interface {
+	applyRequire(*require)
+}
+
+// requireOptionFunc is a RequireOption implemented by a function.
+type requireOptionFunc func(*require)
+
+// applyRequire implements RequireOption.
+func (fn requireOptionFunc) applyRequire(c *require) { fn(c) }
+
+// WithPromptMissing makes RequireFlags prompt for the values of the missing required flags when
+// prompt is true and both stdin and Stderr are terminals, instead of failing.
+func WithPromptMissing(prompt bool) RequireOption {
+	return requireOptionFunc(func(c *require) {
+		c.prompt = prompt
+	})
+}
+
+// WithPromptStdin makes RequireFlags read the prompted values from r instead of os.Stdin. r and
+// Stderr are treated as terminals.
+func WithPromptStdin(r io.Reader) RequireOption {
+	return requireOptionFunc(func(c *require) {
+		c.stdin = r
+		c.terminal = true
+	})
+}
+
+// require wraps a subcommands.Command so that its required flags are checked.
+type require struct {
+	sub      subcommands.Command
+	prompt   bool
+	stdin    io.Reader
+	terminal bool
+}
+
+// make sure require implements the subcommands.Command interface.
+var _ subcommands.Command = (*require)(nil)
+
+// RequireFlags wraps sub so that Execute fails with subcommands.ExitUsageError, without running sub,
+// when a flag marked by MarkRequired, or one of its aliases, is not set on the command line.
+//
+// With WithPromptMissing, the user is asked for the value of each missing flag on Stderr instead,
+// with the usage of the flag as help. The values are set with flag.FlagSet.Set, and asked again if
+// the flag rejects them. The values of sensitive flags are read without echo where possible. The
+// usage error is still returned on EOF, if the execution context is done, or if stdin or Stderr is
+// not a terminal.
+func RequireFlags(sub subcommands.Command, opts ...RequireOption) subcommands.Command {
+	c := &require{
+		sub:   sub,
+		stdin: os.Stdin,
+	}
+	for _, opt := range opts {
+		opt.applyRequire(c)
+	}
+
+	return c
+}
+
+// Name forwards to the underlying c.sub Command.
+func (c *require) Name() string {
+	return c.sub.Name()
+}
+
+// Usage forwards to the underlying c.sub Command.
+func (c *require) Usage() string {
+	return c.sub.Usage()
+}
+
+// Synopsis forwards to the underlying c.sub Command.
+func (c *require) Synopsis() string {
+	return c.sub.Synopsis()
+}
+
+// Unwrap returns the underlying c.sub Command.
+func (c *require) Unwrap() subcommands.Command {
+	return c.sub
+}
+
+// SetFlags forwards to the underlying c.sub Command.
+func (c *require) SetFlags(f *flag.FlagSet) {
+	c.sub.SetFlags(f)
+}
+
+// Execute checks the required flags of f, prompting for the missing ones if enabled, and forwards
+// to the underlying c.sub Command.
+func (c *require) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	var missing []*flag.Flag
+	f.VisitAll(func(fl *flag.Flag) {
+		if IsRequiredFlag(f, fl.Name) && !IsFlagSet(f, fl.Name) {
+			missing = append(missing, fl)
+		}
+	})
+	if len(missing) == 0 {
+		return c.sub.Execute(ctx, f, args...)
+	}
+
+	stderr := Stderr(ctx)
+	if !c.prompt || !(c.terminal || (isTerminal(c.stdin) && isTerminal(stderr))) {
+		fmt.Fprintln(stderr, MessagesFromContext(ctx).Sprintf(MessageMissingRequiredFlag, c.sub.Name(), missing[0].Name))
+		return subcommands.ExitUsageError
+	}
+
+	r := bufio.NewReader(c.stdin)
+	for _, fl := range missing {
+		if err := c.promptFlag(ctx, f, fl, r); err != nil {
+			fmt.Fprintf(stderr, "\n%s: %v\n", MessagesFromContext(ctx).Sprintf(MessageMissingRequiredFlag, c.sub.Name(), fl.Name), err)
+			return subcommands.ExitUsageError
+		}
+	}
+
+	return c.sub.Execute(ctx, f, args...)
+}
+
+// promptFlag asks for the value of fl on Stderr until f accepts the answer read from r.
+func (c *require) promptFlag(ctx context.Context, f *flag.FlagSet, fl *flag.Flag, r *bufio.Reader) error {
+	stderr := Stderr(ctx)
+	for {
+		_, usage := flag.UnquoteUsage(fl)
+		fmt.Fprintf(stderr, "%s (%s): ", fl.Name, usage)
+
+		answer, err := c.readAnswer(ctx, r, IsSensitiveFlag(f, fl.Name))
+		if err != nil {
+			return err
+		}
+		err = f.Set(fl.Name, strings.TrimRight(answer, "\r\n"))
+		if err == nil {
+			return nil
+		}
+		fmt.Fprintf(stderr, "invalid value for -%s: %v\n", fl.Name, err)
+	}
+}
+
+// readAnswer reads a line from r, without echo if sensitive and stdin is a terminal.
+func (c *require) readAnswer(ctx context.Context, r *bufio.Reader, sensitive bool) (string, error) {
+	if f, ok := c.stdin.(*os.File); ok && sensitive {
+		if restore, ok := disableEcho(f); ok {
+			defer func() {
+				restore()
+				fmt.Fprintln(Stderr(ctx)) // the newline of the answer was not echoed
+			}()
+		}
+	}
+
+	return readLine(ctx, r)
+}
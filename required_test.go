@@ -0,0 +1,118 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"context"
+	"flag"
+	"strings"
+	"testing"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+// newUserCommand returns a command requiring the -name, -age and -password flags.
+func newUserCommand(name *string, age *int) *testcmd.Recording {
+	return testcmd.NewRecording("useradd",
+		testcmd.WithFlags(func(f *flag.FlagSet) {
+			f.StringVar(name, "name", "", "the user name")
+			subcommandsutil.AliasFlag(f, "name", "n")
+			f.IntVar(age, "age", 0, "the age of the user")
+			f.String("password", "", "the password")
+			subcommandsutil.MarkSensitive(f, "password")
+			subcommandsutil.MarkRequired(f, "name", "age", "password")
+		}),
+	)
+}
+
+func TestRequireFlags(t *testing.T) {
+	tests := map[string]struct {
+		args       []string
+		opts       []subcommandsutil.RequireOption
+		wantStatus subcommands.ExitStatus
+		wantName   string
+		wantAge    int
+		wantStderr string
+	}{
+		"when the required flags are set": {
+			args:       []string{"-n", "gopher", "-age", "12", "-password", "x"},
+			wantStatus: subcommands.ExitSuccess,
+			wantName:   "gopher",
+			wantAge:    12,
+		},
+		"when a required flag is missing": {
+			args:       []string{"-name", "gopher", "-password", "x"},
+			wantStatus: subcommands.ExitUsageError,
+			wantName:   "gopher",
+			wantStderr: "useradd: missing required flag -age\n",
+		},
+		"when the missing flags are prompted": {
+			args:       []string{"-password", "x"},
+			opts:       []subcommandsutil.RequireOption{subcommandsutil.WithPromptMissing(true), subcommandsutil.WithPromptStdin(strings.NewReader("12\ngopher\n"))},
+			wantStatus: subcommands.ExitSuccess,
+			wantName:   "gopher",
+			wantAge:    12,
+			wantStderr: "age (the age of the user): name (the user name): ",
+		},
+		"when a prompted value is invalid": {
+			args:       []string{"-name", "gopher", "-password", "x"},
+			opts:       []subcommandsutil.RequireOption{subcommandsutil.WithPromptMissing(true), subcommandsutil.WithPromptStdin(strings.NewReader("twelve\n12\n"))},
+			wantStatus: subcommands.ExitSuccess,
+			wantName:   "gopher",
+			wantAge:    12,
+			wantStderr: "age (the age of the user): invalid value for -age: parse error\nage (the age of the user): ",
+		},
+		"when the input ends before the answers": {
+			args:       []string{"-name", "gopher"},
+			opts:       []subcommandsutil.RequireOption{subcommandsutil.WithPromptMissing(true), subcommandsutil.WithPromptStdin(strings.NewReader("12\n"))},
+			wantStatus: subcommands.ExitUsageError,
+			wantName:   "gopher",
+			wantAge:    12,
+			wantStderr: "age (the age of the user): password (the password): \nuseradd: missing required flag -password: EOF\n",
+		},
+		"when prompting is disabled": {
+			args:       []string{"-name", "gopher", "-password", "x"},
+			opts:       []subcommandsutil.RequireOption{subcommandsutil.WithPromptMissing(false), subcommandsutil.WithPromptStdin(strings.NewReader("12\n"))},
+			wantStatus: subcommands.ExitUsageError,
+			wantName:   "gopher",
+			wantStderr: "useradd: missing required flag -age\n",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var gotName string
+			var gotAge int
+			rec := newUserCommand(&gotName, &gotAge)
+
+			var stderr testcmd.Buffer
+			ctx := subcommandsutil.WithOutput(context.Background(), nil, &stderr)
+			status, _, err := testcmd.Run(ctx, subcommandsutil.RequireFlags(rec, tt.opts...), tt.args...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			testcmd.AssertStatus(t, status, tt.wantStatus)
+			if got := rec.DidFinish(); got != (tt.wantStatus == subcommands.ExitSuccess) {
+				t.Fatalf("wanted the command to run %t but got %t", !got, got)
+			}
+			if gotName != tt.wantName || gotAge != tt.wantAge {
+				t.Fatalf("wanted name %q and age %d but got %q and %d", tt.wantName, tt.wantAge, gotName, gotAge)
+			}
+			if got := stderr.String(); got != tt.wantStderr {
+				t.Fatalf("wanted stderr %q but got %q", tt.wantStderr, got)
+			}
+		})
+	}
+}
+
+func TestMarkRequiredUndefined(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("wanted a panic for an undefined flag but got none")
+		}
+	}()
+	subcommandsutil.MarkRequired(flag.NewFlagSet("useradd", flag.ContinueOnError), "name")
+}
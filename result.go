@@ -0,0 +1,244 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/google/subcommands"
+)
+
+// emitterKey is the context key of the Emitter.
+type emitterKey struct{}
+
+// Emitter collects the results of a command run by ResultCommand with an output format.
+type Emitter struct {
+	mu      sync.Mutex
+	results []interface{}
+	json    bool // whether the results are only encoded as JSON, which json.RawMessage values are
+}
+
+// Emit records v as a result.
+func (e *Emitter) Emit(v interface{}) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.results = append(e.results, v)
+}
+
+// Results returns the recorded results in the order they were emitted.
+func (e *Emitter) Results() []interface{} {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return append([]interface{}(nil), e.results...)
+}
+
+// EmitterFromContext returns the Emitter carried by ctx, or nil if no output format was requested.
+func EmitterFromContext(ctx context.Context) *Emitter {
+	e, _ := ctx.Value(emitterKey{}).(*Emitter)
+
+	return e
+}
+
+// JSONRequested reports whether JSON output, or another output format, was requested by the -json
+// or -output flag of ResultCommand. Commands check it to suppress their human readable output.
+func JSONRequested(ctx context.Context) bool {
+	return EmitterFromContext(ctx) != nil
+}
+
+// EmitResult records v as a result of the command, to be encoded by ResultCommand. It does nothing
+// if no output format was requested.
+func EmitResult(ctx context.Context, v interface{}) {
+	if e := EmitterFromContext(ctx); e != nil {
+		e.Emit(v)
+	}
+}
+
+// SetResult records v as the result of the command, returned by ExecuteForResult or encoded by
+// ResultCommand. It does nothing if neither collects the results.
+func SetResult(ctx context.Context, v interface{}) {
+	EmitResult(ctx, v)
+}
+
+// ErrNoResult is returned by ExecuteForResult if the command set no result.
+var ErrNoResult = errors.New("no result")
+
+// ExecuteForResult executes cmd with f and args, and returns the last result it set with SetResult
+// or EmitResult, along with its status. It is meant for the programs running commands without a
+// shell, which receive the typed value instead of the printed text.
+//
+// An error wrapping ErrNoResult is returned if cmd set no result, and an error naming both types
+// if the last result is not a T. The execution context carries the Emitter collecting the results,
+// so JSONRequested reports true and the commands suppress their human readable output.
+func ExecuteForResult[T any](ctx context.Context, cmd subcommands.Command, f *flag.FlagSet, args ...interface{}) (T, subcommands.ExitStatus, error) {
+	var zero T
+
+	e := &Emitter{}
+	status := cmd.Execute(context.WithValue(ctx, emitterKey{}, e), f, args...)
+
+	results := e.Results()
+	if len(results) == 0 {
+		return zero, status, fmt.Errorf("%s: %w", cmd.Name(), ErrNoResult)
+	}
+	v, ok := results[len(results)-1].(T)
+	if !ok {
+		return zero, status, fmt.Errorf("%s: result of type %T, want %T", cmd.Name(), results[len(results)-1], zero)
+	}
+
+	return v, status, nil
+}
+
+// result wraps a subcommands.Command so that its results can be printed by an Encoder.
+type result struct {
+	sub subcommands.Command
+
+	json   bool
+	output outputFormat
+	format string
+}
+
+// make sure result implements the subcommands.Command interface.
+var _ subcommands.Command = (*result)(nil)
+
+// ResultCommand wraps sub with the -output flag, selecting an Encoder registered by
+// RegisterEncoder by name, and the -json flag, a shorthand for -output json. When either is set,
+// the execution context carries an Emitter collecting the values passed to EmitResult, and the
+// results are encoded to Stdout after sub returns. The json encoder encodes a single result as
+// itself, several as a JSON array, and the csv and tsv encoders one row per result.
+//
+// The -format flag prints each result with a Go template instead, as described by
+// ParseResultFormat, and cannot be combined with -output or -json. Neither can -json be combined
+// with an -output other than json.
+//
+// An -output naming no Encoder fails the parsing of the flags, and an invalid -format template
+// makes Execute return subcommands.ExitUsageError. If encoding fails, the error is reported to
+// Stderr and Execute returns subcommands.ExitFailure.
+func ResultCommand(sub subcommands.Command) subcommands.Command {
+	return &result{
+		sub: sub,
+	}
+}
+
+// Name forwards to the underlying c.sub Command.
+func (c *result) Name() string {
+	return c.sub.Name()
+}
+
+// Usage forwards to the underlying c.sub Command.
+func (c *result) Usage() string {
+	return c.sub.Usage()
+}
+
+// Synopsis forwards to the underlying c.sub Command.
+func (c *result) Synopsis() string {
+	return c.sub.Synopsis()
+}
+
+// Unwrap returns the underlying c.sub Command.
+func (c *result) Unwrap() subcommands.Command {
+	return c.sub
+}
+
+// SetFlags forwards to the underlying c.sub Command and registers the -json, -output and -format
+// flags.
+func (c *result) SetFlags(f *flag.FlagSet) {
+	c.sub.SetFlags(f)
+	f.BoolVar(&c.json, "json", false, "print the results as JSON")
+	c.output = "" // f.Var keeps the value of the previous execution
+	f.Var(&c.output, "output", "print the results as `format`, one of "+strings.Join(encoderNames(), ", "))
+	f.StringVar(&c.format, "format", "", "print each result with the Go `template`, like {{.Name}}")
+}
+
+// Execute forwards to the underlying c.sub Command, and encodes its results if the -json, -output
+// or -format flag is set.
+func (c *result) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	enc, ok, err := c.encoder(f)
+	if err != nil {
+		fmt.Fprintf(f.Output(), "%s: %v\n", c.sub.Name(), err)
+		return subcommands.ExitUsageError
+	}
+	if !ok {
+		return c.sub.Execute(ctx, f, args...)
+	}
+
+	e := &Emitter{json: c.format == "" && (c.json || c.output == "json")}
+	status := c.sub.Execute(context.WithValue(ctx, emitterKey{}, e), f, args...)
+
+	var buf bytes.Buffer
+	if err := enc.Encode(&buf, e.Results()); err != nil {
+		fmt.Fprintf(Stderr(ctx), "%s: encoding results: %v\n", c.sub.Name(), err)
+		return subcommands.ExitFailure
+	}
+	if _, err := buf.WriteTo(Stdout(ctx)); err != nil {
+		fmt.Fprintf(Stderr(ctx), "%s: writing results: %v\n", c.sub.Name(), err)
+		return subcommands.ExitFailure
+	}
+
+	return status
+}
+
+// encoder returns the Encoder selected by the flags of f, if any.
+func (c *result) encoder(f *flag.FlagSet) (Encoder, bool, error) {
+	if c.format != "" {
+		if IsFlagSet(f, "output") || IsFlagSet(f, "json") {
+			return nil, false, errors.New("-format cannot be combined with -output or -json")
+		}
+		enc, err := ParseResultFormat(c.format)
+		if err != nil {
+			return nil, false, fmt.Errorf("-format: %w", err)
+		}
+		return enc, true, nil
+	}
+
+	name := string(c.output)
+	if c.json {
+		if name != "" && name != "json" {
+			return nil, false, fmt.Errorf("-json cannot be combined with -output %s", name)
+		}
+		name = "json"
+	}
+	if name == "" {
+		return nil, false, nil
+	}
+	enc, _ := lookupEncoder(name) // validated by outputFormat.Set
+
+	return enc, true, nil
+}
+
+// outputFormat is the value of the -output flag of ResultCommand, the name of an Encoder.
+type outputFormat string
+
+// make sure outputFormat implements the EnumValue interface.
+var _ EnumValue = (*outputFormat)(nil)
+
+// String implements flag.Value.
+func (v *outputFormat) String() string {
+	if v == nil {
+		return ""
+	}
+
+	return string(*v)
+}
+
+// Set implements flag.Value.
+func (v *outputFormat) Set(s string) error {
+	if _, ok := lookupEncoder(s); !ok {
+		return fmt.Errorf("unknown output format; wanted one of %s", strings.Join(encoderNames(), ", "))
+	}
+	*v = outputFormat(s)
+
+	return nil
+}
+
+// Values implements EnumValue.
+func (v *outputFormat) Values() []string {
+	return encoderNames()
+}
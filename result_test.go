@@ -0,0 +1,200 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"testing"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+// version is the result of the sample version command.
+type version struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// newVersionCommand returns a sample command emitting a version result for each of results.
+func newVersionCommand(results ...interface{}) subcommands.Command {
+	return subcommandsutil.ResultCommand(testcmd.NewRecording("version",
+		testcmd.WithExecute(func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+			for _, v := range results {
+				subcommandsutil.EmitResult(ctx, v)
+			}
+			if !subcommandsutil.JSONRequested(ctx) {
+				fmt.Fprintf(subcommandsutil.Stdout(ctx), "%d results\n", len(results))
+			}
+			return subcommands.ExitSuccess
+		}),
+	))
+}
+
+func TestResultCommand(t *testing.T) {
+	tests := map[string]struct {
+		results    []interface{}
+		args       []string
+		wantStatus subcommands.ExitStatus
+		wantStdout string
+		wantStderr string
+	}{
+		"when JSON is not requested": {
+			results:    []interface{}{version{Name: "mytool", Version: "1.0.0"}},
+			wantStatus: subcommands.ExitSuccess,
+			wantStdout: "1 results\n",
+		},
+		"when JSON is requested with a result": {
+			results:    []interface{}{version{Name: "mytool", Version: "1.0.0"}},
+			args:       []string{"-json"},
+			wantStatus: subcommands.ExitSuccess,
+			wantStdout: `{"name":"mytool","version":"1.0.0"}` + "\n",
+		},
+		"when JSON is requested with several results": {
+			results:    []interface{}{version{Name: "a", Version: "1"}, version{Name: "b", Version: "2"}},
+			args:       []string{"-json"},
+			wantStatus: subcommands.ExitSuccess,
+			wantStdout: `[{"name":"a","version":"1"},{"name":"b","version":"2"}]` + "\n",
+		},
+		"when JSON is requested without results": {
+			args:       []string{"-json"},
+			wantStatus: subcommands.ExitSuccess,
+		},
+		"when a result cannot be encoded": {
+			results:    []interface{}{make(chan int)},
+			args:       []string{"-json"},
+			wantStatus: subcommands.ExitFailure,
+			wantStderr: "version: encoding results: json: unsupported type: chan int\n",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var stdout, stderr testcmd.Buffer
+			ctx := subcommandsutil.WithOutput(context.Background(), &stdout, &stderr)
+
+			status, _, err := testcmd.Run(ctx, newVersionCommand(tt.results...), tt.args...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			testcmd.AssertStatus(t, status, tt.wantStatus)
+			if got := stdout.String(); got != tt.wantStdout {
+				t.Fatalf("wanted stdout %q but got %q", tt.wantStdout, got)
+			}
+			if got := stderr.String(); got != tt.wantStderr {
+				t.Fatalf("wanted stderr %q but got %q", tt.wantStderr, got)
+			}
+			if len(tt.args) > 0 && tt.wantStdout != "" && !json.Valid([]byte(stdout.String())) {
+				t.Fatalf("wanted valid JSON but got %q", stdout.String())
+			}
+		})
+	}
+}
+
+func TestResultCommandReused(t *testing.T) {
+	cmd := newVersionCommand(version{Name: "mytool", Version: "1.0.0"})
+	for _, want := range []struct {
+		args   []string
+		stdout string
+	}{
+		{args: []string{"-output", "json"}, stdout: `{"name":"mytool","version":"1.0.0"}` + "\n"},
+		{stdout: "1 results\n"},
+	} {
+		var stdout testcmd.Buffer
+		ctx := subcommandsutil.WithOutput(context.Background(), &stdout, &testcmd.Buffer{})
+		status, f, _ := testcmd.Run(ctx, cmd, want.args...)
+		testcmd.RequireSuccess(t, status)
+		if got := stdout.String(); got != want.stdout {
+			t.Fatalf("wanted stdout %q with the arguments %q but got %q", want.stdout, want.args, got)
+		}
+		if def := f.Lookup("output").DefValue; def != "" {
+			t.Fatalf("wanted no default of -output but got %q", def)
+		}
+	}
+}
+
+func TestEmitResultWithoutJSON(t *testing.T) {
+	ctx := context.Background()
+	if subcommandsutil.JSONRequested(ctx) {
+		t.Fatal("wanted JSON not to be requested but it was")
+	}
+	subcommandsutil.EmitResult(ctx, "ignored")
+	if e := subcommandsutil.EmitterFromContext(ctx); e != nil {
+		t.Fatalf("wanted no Emitter but got %v", e)
+	}
+}
+
+func TestExecuteForResult(t *testing.T) {
+	tests := map[string]struct {
+		results []interface{}
+		want    version
+		wantErr string
+	}{
+		"when the command sets a result": {
+			results: []interface{}{version{Name: "tool", Version: "v1.2.0"}},
+			want:    version{Name: "tool", Version: "v1.2.0"},
+		},
+		"when the command sets several results": {
+			results: []interface{}{version{Name: "tool", Version: "v1.1.0"}, version{Name: "tool", Version: "v1.2.0"}},
+			want:    version{Name: "tool", Version: "v1.2.0"},
+		},
+		"when the result is of another type": {
+			results: []interface{}{"v1.2.0"},
+			wantErr: "version: result of type string, want subcommandsutil_test.version",
+		},
+		"when the command sets no result": {
+			wantErr: "version: no result",
+		},
+	}
+	for name, tt := range tests {
+		tt := tt
+		t.Run(name, func(t *testing.T) {
+			var stdout testcmd.Buffer
+			ctx := subcommandsutil.WithOutput(context.Background(), &stdout, &testcmd.Buffer{})
+			f := flag.NewFlagSet("version", flag.ContinueOnError)
+
+			got, status, err := subcommandsutil.ExecuteForResult[version](ctx, newVersionCommand(tt.results...), f)
+			testcmd.RequireSuccess(t, status)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("wanted the error %q but got %v", tt.wantErr, err)
+				}
+				if len(tt.results) == 0 && !errors.Is(err, subcommandsutil.ErrNoResult) {
+					t.Fatalf("wanted ErrNoResult but got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Fatalf("wanted the result %+v but got %+v", tt.want, got)
+			}
+			if out := stdout.String(); out != "" {
+				t.Fatalf("wanted no human readable output but got %q", out)
+			}
+		})
+	}
+}
+
+func TestSetResultJSON(t *testing.T) {
+	cmd := subcommandsutil.ResultCommand(testcmd.NewRecording("version",
+		testcmd.WithExecute(func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+			subcommandsutil.SetResult(ctx, version{Name: "tool", Version: "v1.2.0"})
+			return subcommands.ExitSuccess
+		}),
+	))
+
+	var stdout testcmd.Buffer
+	status, _, _ := testcmd.Run(subcommandsutil.WithOutput(context.Background(), &stdout, &testcmd.Buffer{}), cmd, "-json")
+	testcmd.RequireSuccess(t, status)
+	if want := `{"name":"tool","version":"v1.2.0"}` + "\n"; stdout.String() != want {
+		t.Fatalf("wanted the output %q but got %q", want, stdout.String())
+	}
+}
@@ -0,0 +1,166 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/subcommands"
+)
+
+// RunResult is the metadata of an execution written by ResultFile, like:
+//
+//	{"command":"deploy","end":"2021-01-02T03:04:07Z","status":0,"duration_ns":2000000000,"canceled":false,"argv":["deploy","-result-file=result.json","-token=[REDACTED]","prod"],"start":"2021-01-02T03:04:05Z","retries":1}
+type RunResult struct {
+	ExecutionFinished
+
+	// Argv is the command line of the execution, with the values of the sensitive flags redacted.
+	Argv []string `json:"argv"`
+
+	// Start is the time the execution started.
+	Start time.Time `json:"start"`
+
+	// Retries is the number of the RetryScheduled events published during the execution.
+	Retries int `json:"retries"`
+
+	// Panic is the value the execution panicked with, if it did.
+	Panic string `json:"panic,omitempty"`
+
+	// OutputBytes is the number of bytes written to Stdout and Stderr, including the discarded
+	// ones, as reported by the OutputCounted events of LimitOutput.
+	OutputBytes int64 `json:"output_bytes,omitempty"`
+
+	// OutputTruncated reports whether LimitOutput truncated the output.
+	OutputTruncated bool `json:"output_truncated,omitempty"`
+
+	// Rusage is the usage of the resources of the operating system by the execution, as reported
+	// by the ResourceUsageReported event of ResourceUsage.
+	Rusage *ProcessUsage `json:"rusage,omitempty"`
+}
+
+// resultFile wraps a subcommands.Command with the -result-file flag.
+type resultFile struct {
+	sub subcommands.Command
+
+	path string
+}
+
+// make sure resultFile implements the subcommands.Command interface.
+var _ subcommands.Command = (*resultFile)(nil)
+
+// ResultFile wraps sub with the -result-file flag. When set, the RunResult of each execution is
+// written atomically to the file as JSON, its parent directories created, when sub succeeds, fails,
+// is canceled or panics; the panic then continues. The command is the CommandPath of the execution
+// context, or the name of sub outside of a Group, and the times are measured on its Clock. A
+// failure to write the file is warned about on Stderr.
+func ResultFile(sub subcommands.Command) subcommands.Command {
+	return &resultFile{
+		sub: sub,
+	}
+}
+
+// Name forwards to the underlying c.sub Command.
+func (c *resultFile) Name() string {
+	return c.sub.Name()
+}
+
+// Usage forwards to the underlying c.sub Command.
+func (c *resultFile) Usage() string {
+	return c.sub.Usage()
+}
+
+// Synopsis forwards to the underlying c.sub Command.
+func (c *resultFile) Synopsis() string {
+	return c.sub.Synopsis()
+}
+
+// Unwrap returns the underlying c.sub Command.
+func (c *resultFile) Unwrap() subcommands.Command {
+	return c.sub
+}
+
+// SetFlags forwards to the underlying c.sub Command and registers the -result-file flag.
+func (c *resultFile) SetFlags(f *flag.FlagSet) {
+	c.sub.SetFlags(f)
+	f.StringVar(&c.path, "result-file", "", "write the result of the execution to `path` as JSON")
+}
+
+// Execute forwards to the underlying c.sub Command and writes its RunResult to the -result-file.
+func (c *resultFile) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) (status subcommands.ExitStatus) {
+	if c.path == "" {
+		return c.sub.Execute(ctx, f, args...)
+	}
+
+	clk := ClockFromContext(ctx)
+	result := RunResult{
+		Argv:  commandArgv(f, c.sub.Name()),
+		Start: clk.Now(),
+	}
+	result.Command = c.sub.Name()
+	if path := CommandPath(ctx); len(path) > 0 {
+		result.Command = strings.Join(path, " ")
+	}
+
+	// count the retries, the output and the resource usage on an Events of the execution, forwarding to the one of ctx
+	events := NewEvents()
+	if parent := EventsFromContext(ctx); parent != nil {
+		events.forward(parent)
+	}
+	// accessed atomically, as a canceled execution can still retry
+	var retries, outputBytes int64
+	var outputTruncated int32
+	var rusage atomic.Pointer[ProcessUsage]
+	events.Subscribe(func(ev Event) {
+		switch ev := ev.(type) {
+		case RetryScheduled:
+			atomic.AddInt64(&retries, 1)
+		case OutputCounted:
+			atomic.AddInt64(&outputBytes, ev.Bytes)
+			if ev.Truncated {
+				atomic.StoreInt32(&outputTruncated, 1)
+			}
+		case ResourceUsageReported:
+			rusage.Store(&ev.Usage)
+		}
+	})
+
+	status = subcommands.ExitFailure
+	defer func() {
+		r := recover()
+		if r != nil {
+			result.Panic = fmt.Sprint(r)
+		}
+		result.Time = clk.Now()
+		result.Duration = result.Time.Sub(result.Start)
+		result.Status = status
+		result.Canceled = ctx.Err() != nil
+		result.Retries = int(atomic.LoadInt64(&retries))
+		result.OutputBytes = atomic.LoadInt64(&outputBytes)
+		result.OutputTruncated = atomic.LoadInt32(&outputTruncated) == 1
+		result.Rusage = rusage.Load()
+		c.write(ctx, result)
+		if r != nil {
+			panic(r)
+		}
+	}()
+
+	return c.sub.Execute(WithEvents(ctx, events), f, args...)
+}
+
+// write writes result to the -result-file.
+func (c *resultFile) write(ctx context.Context, result RunResult) {
+	data, err := json.Marshal(result)
+	if err == nil {
+		err = writeFileAtomic(c.path, append(data, '\n'), 0o644)
+	}
+	if err != nil {
+		fmt.Fprintf(Stderr(ctx), "warning: writing result file: %v\n", err)
+	}
+}
@@ -0,0 +1,122 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+// readRunResult returns the RunResult in the file at path.
+func readRunResult(t *testing.T, path string) subcommandsutil.RunResult {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var result subcommandsutil.RunResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		t.Fatalf("wanted a RunResult but got %q: %v", data, err)
+	}
+
+	return result
+}
+
+func TestResultFile(t *testing.T) {
+	start := time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)
+	clk := testcmd.NewFakeClock(start)
+	attempts := 0
+	sub := testcmd.NewRecording("deploy",
+		testcmd.WithFlags(func(f *flag.FlagSet) {
+			f.String("token", "", "API token")
+			subcommandsutil.MarkSensitive(f, "token")
+		}),
+		testcmd.WithExecute(func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+			attempts++
+			if attempts == 1 {
+				return subcommands.ExitFailure
+			}
+			clk.Advance(2 * time.Second)
+			return subcommands.ExitSuccess
+		}),
+	)
+	cmd := subcommandsutil.ResultFile(subcommandsutil.Retry(sub, subcommandsutil.WithBackoff(0, 0), subcommandsutil.WithLogger(&testcmd.LogRecorder{})))
+	path := filepath.Join(t.TempDir(), "ci", "result.json")
+
+	status, _, _ := testcmd.Run(subcommandsutil.WithClock(context.Background(), clk), cmd, "-result-file", path, "-token", "s3cret", "prod")
+	testcmd.RequireSuccess(t, status)
+
+	got := readRunResult(t, path)
+	want := subcommandsutil.RunResult{
+		ExecutionFinished: subcommandsutil.ExecutionFinished{
+			Command:  "deploy",
+			Time:     start.Add(2 * time.Second),
+			Status:   subcommands.ExitSuccess,
+			Duration: 2 * time.Second,
+		},
+		Argv:    []string{"deploy", "-result-file=" + path, "-token=" + subcommandsutil.Redacted, "prod"},
+		Start:   start,
+		Retries: 1,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("wanted the result %+v but got %+v", want, got)
+	}
+}
+
+func TestResultFileCanceled(t *testing.T) {
+	defer testcmd.VerifyNoLeaks(t)
+
+	tcmd := testcmd.NewBlocking("deploy")
+	defer tcmd.Release(subcommands.ExitSuccess)
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-tcmd.Started()
+		cancel()
+	}()
+	path := filepath.Join(t.TempDir(), "result.json")
+
+	cmd := subcommandsutil.ResultFile(subcommandsutil.Cancelable(tcmd, subcommandsutil.WithLogger(&testcmd.LogRecorder{})))
+	status, _, _ := testcmd.Run(ctx, cmd, "-result-file", path)
+	testcmd.AssertStatus(t, status, subcommands.ExitFailure)
+
+	got := readRunResult(t, path)
+	if !got.Canceled || got.Status != subcommands.ExitFailure || got.Command != "deploy" {
+		t.Fatalf("wanted a canceled failure of deploy but got %+v", got)
+	}
+	if got.Time.Before(got.Start) {
+		t.Fatalf("wanted the end %v after the start %v", got.Time, got.Start)
+	}
+}
+
+func TestResultFilePanic(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "result.json")
+	sub := testcmd.NewRecording("deploy", testcmd.WithExecute(func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+		panic("boom")
+	}))
+
+	func() {
+		defer func() {
+			if r := recover(); r != "boom" {
+				t.Fatalf("wanted the panic to continue but got %v", r)
+			}
+		}()
+		testcmd.Run(context.Background(), subcommandsutil.ResultFile(sub), "-result-file", path)
+	}()
+
+	if got := readRunResult(t, path); got.Panic != "boom" || got.Status != subcommands.ExitFailure {
+		t.Fatalf("wanted a failure panicking with boom but got %+v", got)
+	}
+}
@@ -0,0 +1,84 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"text/template"
+)
+
+// resultFormatFuncs are the functions of the templates of ParseResultFormat, beside the builtin
+// functions of text/template such as printf.
+var resultFormatFuncs = template.FuncMap{
+	"json": formatJSON,
+	"join": formatJoin,
+}
+
+// formatEscapes replaces the escapes of the templates of ParseResultFormat.
+var formatEscapes = strings.NewReplacer(`\t`, "\t", `\n`, "\n")
+
+// ParseResultFormat returns an Encoder printing each result with the Go template text, followed by
+// a newline unless the output of the template ends with one, like the -format flag of
+// ResultCommand:
+//
+//	{{.Name}}\t{{.Status}}
+//	{{json .Labels}}
+//	{{join .Tags ", "}}
+//	{{printf "%-10s" .Name}}
+//
+// The escapes \t and \n of text are replaced by a tab and a newline. Beside the builtin functions
+// of text/template, json renders its argument as JSON, and join joins the elements of a slice with
+// a separator.
+func ParseResultFormat(text string) (Encoder, error) {
+	tmpl, err := template.New("format").Funcs(resultFormatFuncs).Parse(formatEscapes.Replace(text))
+	if err != nil {
+		return nil, err
+	}
+
+	return EncoderFunc(func(w io.Writer, results []interface{}) error {
+		var b strings.Builder
+		for _, r := range results {
+			b.Reset()
+			if err := tmpl.Execute(&b, r); err != nil {
+				return err
+			}
+			if !strings.HasSuffix(b.String(), "\n") {
+				b.WriteByte('\n')
+			}
+			if _, err := io.WriteString(w, b.String()); err != nil {
+				return err
+			}
+		}
+		return nil
+	}), nil
+}
+
+// formatJSON is the json function of the templates of ParseResultFormat.
+func formatJSON(v interface{}) (string, error) {
+	data, err := json.Marshal(v)
+
+	return string(data), err
+}
+
+// formatJoin is the join function of the templates of ParseResultFormat.
+func formatJoin(elems interface{}, sep string) (string, error) {
+	if s, ok := elems.([]string); ok {
+		return strings.Join(s, sep), nil
+	}
+
+	v := reflect.ValueOf(elems)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return "", fmt.Errorf("join of %T, not a slice", elems)
+	}
+	s := make([]string, v.Len())
+	for i := range s {
+		s[i] = fmt.Sprint(v.Index(i).Interface())
+	}
+
+	return strings.Join(s, sep), nil
+}
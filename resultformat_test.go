@@ -0,0 +1,115 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"testing"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+// taggedRelease is a result with a slice and a map for the template functions.
+type taggedRelease struct {
+	Name   string
+	Tags   []string
+	Ports  []int
+	Labels map[string]string
+}
+
+func TestResultCommandFormat(t *testing.T) {
+	results := []interface{}{
+		taggedRelease{Name: "tool", Tags: []string{"stable", "latest"}, Ports: []int{80, 443}, Labels: map[string]string{"team": "infra"}},
+		taggedRelease{Name: "lib"},
+	}
+
+	tests := map[string]struct {
+		args       []string
+		wantStatus subcommands.ExitStatus
+		wantStdout string
+		wantStderr string
+		wantOutput string
+	}{
+		"when fields are accessed": {
+			args:       []string{"-format", `{{.Name}}\t{{len .Tags}}`},
+			wantStdout: "tool\t2\nlib\t0\n",
+		},
+		"when the template ends with a newline": {
+			args:       []string{"-format", "{{.Name}}\n"},
+			wantStdout: "tool\nlib\n",
+		},
+		"when the json function is called": {
+			args:       []string{"-format", "{{json .Labels}}"},
+			wantStdout: `{"team":"infra"}` + "\nnull\n",
+		},
+		"when the join function is called": {
+			args:       []string{"-format", `{{.Name}}: {{join .Tags ", "}} {{join .Ports "/"}}`},
+			wantStdout: "tool: stable, latest 80/443\nlib:  \n",
+		},
+		"when the printf function is called": {
+			args:       []string{"-format", `{{printf "%-6s|" .Name}}`},
+			wantStdout: "tool  |\nlib   |\n",
+		},
+		"when the template cannot be parsed": {
+			args:       []string{"-format", "{{.Name"},
+			wantStatus: subcommands.ExitUsageError,
+			wantOutput: "releases: -format: template: format:1: unclosed action\n",
+		},
+		"when the template cannot be executed": {
+			args:       []string{"-format", "{{.Version}}"},
+			wantStatus: subcommands.ExitFailure,
+			wantStderr: "releases: encoding results: template: format:1:2: executing \"format\" at <.Version>: can't evaluate field Version in type subcommandsutil_test.taggedRelease\n",
+		},
+		"when an output format is also given": {
+			args:       []string{"-format", "{{.Name}}", "-output", "csv"},
+			wantStatus: subcommands.ExitUsageError,
+			wantOutput: "releases: -format cannot be combined with -output or -json\n",
+		},
+		"when JSON is also requested": {
+			args:       []string{"-json", "-format", "{{.Name}}"},
+			wantStatus: subcommands.ExitUsageError,
+			wantOutput: "releases: -format cannot be combined with -output or -json\n",
+		},
+		"when JSON is requested by both -json and -output": {
+			args:       []string{"-json", "-output", "json"},
+			wantStdout: `[{"Name":"tool","Tags":["stable","latest"],"Ports":[80,443],"Labels":{"team":"infra"}},{"Name":"lib","Tags":null,"Ports":null,"Labels":null}]` + "\n",
+		},
+		"when JSON is requested with another output format": {
+			args:       []string{"-json", "-output", "csv"},
+			wantStatus: subcommands.ExitUsageError,
+			wantOutput: "releases: -json cannot be combined with -output csv\n",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var stdout, stderr testcmd.Buffer
+			ctx := subcommandsutil.WithOutput(context.Background(), &stdout, &stderr)
+			cmd := newReleasesCommand(results...)
+
+			var out bytes.Buffer
+			f := flag.NewFlagSet("releases", flag.ContinueOnError)
+			f.SetOutput(&out)
+			cmd.SetFlags(f)
+			if err := f.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+
+			testcmd.AssertStatus(t, cmd.Execute(ctx, f), tt.wantStatus)
+			if got := stdout.String(); got != tt.wantStdout {
+				t.Fatalf("wanted stdout %q but got %q", tt.wantStdout, got)
+			}
+			if got := stderr.String(); got != tt.wantStderr {
+				t.Fatalf("wanted stderr %q but got %q", tt.wantStderr, got)
+			}
+			if got := out.String(); got != tt.wantOutput {
+				t.Fatalf("wanted the output %q but got %q", tt.wantOutput, got)
+			}
+		})
+	}
+}
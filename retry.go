@@ -0,0 +1,192 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"time"
+
+	"github.com/google/subcommands"
+)
+
+// RetryOption is an option of the Retry wrapper.
+type RetryOption interface {
+	applyRetry(*retry)
+}
+
+// retryOptionFunc is a RetryOption implemented by a function.
+type retryOptionFunc func(*retry)
+
+// applyRetry implements RetryOption.
+func (fn retryOptionFunc) applyRetry(c *retry) { fn(c) }
+
+// applyRetry implements RetryOption.
+func (o LoggerOption) applyRetry(c *retry) {
+	c.logger = o.logger
+}
+
+// WithAttempts sets the maximum number of attempts of the Retry wrapper. The default is 3.
+func WithAttempts(n int) RetryOption {
+	return retryOptionFunc(func(c *retry) {
+		c.attempts = n
+	})
+}
+
+// WithAttemptTimeout sets the timeout of each attempt of the Retry wrapper, measured on the Clock of
+// the execution context, 0 for none, the default. An attempt whose deadline expires fails with
+// subcommands.ExitFailure, which is retried unless WithRetryOn decides otherwise. A deadline or a
+// cancellation of the execution context itself, such as the one of an outer Timeout, ends the
+// retries instead.
+//
+// A Cancelable sub has been disposed when its timed out attempt returns, before the next attempt
+// starts.
+func WithAttemptTimeout(d time.Duration) RetryOption {
+	return retryOptionFunc(func(c *retry) {
+		c.attemptTimeout = d
+	})
+}
+
+// BackoffOption is an option setting the exponential backoff of a wrapper rerunning a command. It
+// is accepted by Retry and Supervise.
+type BackoffOption struct {
+	initial, max time.Duration
+}
+
+// WithBackoff returns an option setting the delay before the first rerun, which is doubled for each
+// following rerun up to max. The default is 100ms up to 10s for Retry, and 1s up to 1m for
+// Supervise.
+func WithBackoff(initial, max time.Duration) BackoffOption {
+	return BackoffOption{initial: initial, max: max}
+}
+
+// applyRetry implements RetryOption.
+func (o BackoffOption) applyRetry(c *retry) {
+	c.initial = o.initial
+	c.max = o.max
+}
+
+// WithRetryOn sets the function deciding whether the Retry wrapper retries after an attempt
+// returned status. The default retries on subcommands.ExitFailure only.
+func WithRetryOn(fn func(status subcommands.ExitStatus) bool) RetryOption {
+	return retryOptionFunc(func(c *retry) {
+		c.retryOn = fn
+	})
+}
+
+// retry wraps a subcommands.Command so that failed executions are retried.
+type retry struct {
+	sub      subcommands.Command
+	attempts int
+	initial  time.Duration
+	max      time.Duration
+	retryOn  func(status subcommands.ExitStatus) bool
+	logger   Logger
+
+	attemptTimeout time.Duration
+
+	executing executing
+}
+
+// make sure retry implements the subcommands.Command interface.
+var _ subcommands.Command = (*retry)(nil)
+
+// Retry wraps sub so that a failed execution is retried with exponential backoff.
+//
+// The backoff is measured on the Clock carried by the execution context, and is interrupted when
+// the context is done, in which case the status of the last attempt is returned.
+func Retry(sub subcommands.Command, opts ...RetryOption) subcommands.Command {
+	c := &retry{
+		sub:      sub,
+		attempts: 3,
+		initial:  100 * time.Millisecond,
+		max:      10 * time.Second,
+		retryOn: func(status subcommands.ExitStatus) bool {
+			return status == subcommands.ExitFailure
+		},
+		logger: stdLogger{},
+	}
+	for _, opt := range opts {
+		opt.applyRetry(c)
+	}
+
+	return c
+}
+
+// Name forwards to the underlying c.sub Command.
+func (c *retry) Name() string {
+	return c.sub.Name()
+}
+
+// Usage forwards to the underlying c.sub Command.
+func (c *retry) Usage() string {
+	return c.sub.Usage()
+}
+
+// Synopsis forwards to the underlying c.sub Command.
+func (c *retry) Synopsis() string {
+	return c.sub.Synopsis()
+}
+
+// SetFlags forwards to the underlying c.sub Command.
+func (c *retry) SetFlags(f *flag.FlagSet) {
+	c.sub.SetFlags(f)
+}
+
+// Unwrap returns the underlying c.sub Command.
+func (c *retry) Unwrap() subcommands.Command {
+	return c.sub
+}
+
+// Execute forwards to the underlying c.sub Command until it succeeds, is not retryable, or the
+// attempts are exhausted.
+func (c *retry) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	if !c.executing.enter(ctx, c.logger, c.sub.Name()) {
+		return subcommands.ExitFailure
+	}
+	defer c.executing.exit()
+
+	clk := ClockFromContext(ctx)
+
+	backoff := c.initial
+	for attempt := 1; ; attempt++ {
+		status, timedOut := c.attempt(ctx, f, args)
+		if attempt >= c.attempts || !c.retryOn(status) || ctx.Err() != nil {
+			return status
+		}
+
+		if timedOut {
+			contextLogger(ctx, c.logger).Printf("%s: attempt %d timed out after %v, retrying in %v", c.sub.Name(), attempt, c.attemptTimeout, backoff)
+		} else {
+			contextLogger(ctx, c.logger).Printf("%s: attempt %d failed with status %d, retrying in %v", c.sub.Name(), attempt, status, backoff)
+		}
+		publish(ctx, RetryScheduled{Command: c.sub.Name(), Time: clk.Now(), Attempt: attempt, Status: status, Backoff: backoff})
+		if sleep(ctx, clk, backoff) != nil {
+			return status
+		}
+
+		backoff *= 2
+		if backoff > c.max {
+			backoff = c.max
+		}
+	}
+}
+
+// attempt executes the underlying c.sub Command once, within the timeout of an attempt if any. It
+// reports whether the attempt failed because its own deadline expired.
+func (c *retry) attempt(ctx context.Context, f *flag.FlagSet, args []interface{}) (subcommands.ExitStatus, bool) {
+	if c.attemptTimeout <= 0 {
+		return c.sub.Execute(ctx, f, args...), false
+	}
+
+	actx, cancel := withTimeout(ctx, c.attemptTimeout)
+	defer cancel()
+	status := c.sub.Execute(actx, f, args...)
+	if status != subcommands.ExitSuccess && ctx.Err() == nil && errors.Is(actx.Err(), context.DeadlineExceeded) {
+		return subcommands.ExitFailure, true
+	}
+
+	return status, false
+}
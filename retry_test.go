@@ -0,0 +1,240 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"context"
+	"flag"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+func TestRetry(t *testing.T) {
+	defer testcmd.VerifyNoLeaks(t)
+
+	tests := map[string]struct {
+		// statuses returned by the attempts, the last one repeating
+		statuses     []subcommands.ExitStatus
+		wantStatus   subcommands.ExitStatus
+		wantAttempts int
+	}{
+		"when the first attempt succeeds": {
+			statuses:     []subcommands.ExitStatus{subcommands.ExitSuccess},
+			wantStatus:   subcommands.ExitSuccess,
+			wantAttempts: 1,
+		},
+		"when a retry succeeds": {
+			statuses:     []subcommands.ExitStatus{subcommands.ExitFailure, subcommands.ExitFailure, subcommands.ExitSuccess},
+			wantStatus:   subcommands.ExitSuccess,
+			wantAttempts: 3,
+		},
+		"when every attempt fails": {
+			statuses:     []subcommands.ExitStatus{subcommands.ExitFailure},
+			wantStatus:   subcommands.ExitFailure,
+			wantAttempts: 3,
+		},
+		"when the status is not retryable": {
+			statuses:     []subcommands.ExitStatus{subcommands.ExitUsageError},
+			wantStatus:   subcommands.ExitUsageError,
+			wantAttempts: 1,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			clk := testcmd.NewFakeClock(time.Now())
+			ctx := subcommandsutil.WithClock(context.Background(), clk)
+
+			var attempts int32
+			r := testcmd.NewRecording("flaky",
+				testcmd.WithExecute(func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+					i := int(atomic.AddInt32(&attempts, 1)) - 1
+					if i >= len(tt.statuses) {
+						i = len(tt.statuses) - 1
+					}
+					return tt.statuses[i]
+				}),
+			)
+
+			var logs testcmd.LogRecorder
+			cmd := subcommandsutil.Retry(r,
+				subcommandsutil.WithAttempts(3),
+				subcommandsutil.WithBackoff(time.Second, time.Minute),
+				subcommandsutil.WithLogger(&logs),
+			)
+
+			done := make(chan subcommands.ExitStatus)
+			go func() {
+				status, _, _ := testcmd.Run(ctx, cmd)
+				done <- status
+			}()
+
+			// advance the fake clock through the backoff until the wrapper returns
+			var status subcommands.ExitStatus
+		wait:
+			for {
+				select {
+				case status = <-done:
+					break wait
+				case <-time.After(time.Millisecond):
+					clk.Advance(time.Minute)
+				}
+			}
+
+			if status != tt.wantStatus {
+				t.Fatalf("wanted %v but got %v", tt.wantStatus, status)
+			}
+			if r.CallCount() != tt.wantAttempts {
+				t.Fatalf("wanted %d attempts but got %d", tt.wantAttempts, r.CallCount())
+			}
+			if got := strings.Count(strings.Join(logs.Lines(), "\n"), "retrying in"); got != tt.wantAttempts-1 {
+				t.Fatalf("wanted %d retry logs but got %q", tt.wantAttempts-1, logs.Lines())
+			}
+		})
+	}
+}
+
+func TestRetryCanceledDuringBackoff(t *testing.T) {
+	defer testcmd.VerifyNoLeaks(t)
+
+	clk := testcmd.NewFakeClock(time.Now())
+	ctx, cancel := context.WithCancel(subcommandsutil.WithClock(context.Background(), clk))
+	defer cancel()
+
+	r := testcmd.NewRecording("flaky", testcmd.WithStatus(subcommands.ExitFailure))
+	cmd := subcommandsutil.Retry(r, subcommandsutil.WithLogger(&testcmd.LogRecorder{}))
+
+	done := make(chan subcommands.ExitStatus)
+	go func() {
+		status, _, _ := testcmd.Run(ctx, cmd)
+		done <- status
+	}()
+
+	clk.BlockUntil(1)
+	cancel()
+
+	if status := <-done; status != subcommands.ExitFailure {
+		t.Fatalf("wanted %v but got %v", subcommands.ExitFailure, status)
+	}
+	if r.CallCount() != 1 {
+		t.Fatalf("wanted no retry after cancellation but got %d attempts", r.CallCount())
+	}
+}
+
+// attemptCommand is a CancelableCommand whose attempts run until their context is done, except the
+// one at succeedAt.
+type attemptCommand struct {
+	testcmd.DisposeRecorder
+
+	succeedAt int
+	started   chan struct{}
+
+	mu       sync.Mutex
+	attempts int
+	disposed []int // the DisposeCount when each attempt started
+}
+
+func (c *attemptCommand) Name() string           { return "fetch" }
+func (c *attemptCommand) Synopsis() string       { return "" }
+func (c *attemptCommand) Usage() string          { return "" }
+func (c *attemptCommand) SetFlags(*flag.FlagSet) {}
+
+func (c *attemptCommand) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	c.mu.Lock()
+	c.attempts++
+	attempt := c.attempts
+	c.disposed = append(c.disposed, c.DisposeCount())
+	c.mu.Unlock()
+
+	if attempt == c.succeedAt {
+		return subcommands.ExitSuccess
+	}
+	c.started <- struct{}{}
+	<-ctx.Done()
+	return subcommands.ExitFailure
+}
+
+func TestRetryAttemptTimeout(t *testing.T) {
+	defer testcmd.VerifyNoLeaks(t)
+
+	// advance is a step of the test: once an attempt blocks if attempt is set, and the given number
+	// of timers wait, the fake clock is advanced by d
+	type advance struct {
+		attempt bool
+		waiters int
+		d       time.Duration
+	}
+	tests := map[string]struct {
+		succeedAt    int
+		total        time.Duration
+		steps        []advance
+		wantStatus   subcommands.ExitStatus
+		wantDisposed []int
+		wantLogs     []string
+	}{
+		"when an attempt times out and a later one succeeds": {
+			succeedAt:    2,
+			steps:        []advance{{attempt: true, waiters: 1, d: 30 * time.Second}, {waiters: 1, d: time.Second}},
+			wantStatus:   subcommands.ExitSuccess,
+			wantDisposed: []int{0, 1},
+			wantLogs:     []string{"fetch: attempt 1 timed out after 30s, retrying in 1s"},
+		},
+		"when the total timeout expires during an attempt": {
+			total:        45 * time.Second,
+			steps:        []advance{{attempt: true, waiters: 2, d: 30 * time.Second}, {waiters: 2, d: time.Second}, {attempt: true, waiters: 2, d: 14 * time.Second}},
+			wantStatus:   subcommands.ExitFailure,
+			wantDisposed: []int{0, 1},
+			wantLogs:     []string{"fetch: attempt 1 timed out after 30s, retrying in 1s"},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			clk := testcmd.NewFakeClock(time.Now())
+			ctx := subcommandsutil.WithOutput(subcommandsutil.WithClock(context.Background(), clk), &testcmd.Buffer{}, &testcmd.Buffer{})
+
+			sub := &attemptCommand{succeedAt: tt.succeedAt, started: make(chan struct{}, 5)}
+			var logs testcmd.LogRecorder
+			var cmd subcommands.Command = subcommandsutil.Retry(subcommandsutil.Cancelable(sub, subcommandsutil.WithLogger(&testcmd.LogRecorder{})),
+				subcommandsutil.WithAttempts(5),
+				subcommandsutil.WithAttemptTimeout(30*time.Second),
+				subcommandsutil.WithBackoff(time.Second, time.Minute),
+				subcommandsutil.WithLogger(&logs),
+			)
+			if tt.total > 0 {
+				cmd = subcommandsutil.Timeout(cmd, tt.total)
+			}
+
+			done := make(chan subcommands.ExitStatus)
+			go func() {
+				status, _, _ := testcmd.Run(ctx, cmd)
+				done <- status
+			}()
+			for _, step := range tt.steps {
+				if step.attempt {
+					<-sub.started
+				}
+				clk.BlockUntil(step.waiters)
+				clk.Advance(step.d)
+			}
+
+			testcmd.AssertStatus(t, <-done, tt.wantStatus)
+			sub.mu.Lock()
+			defer sub.mu.Unlock()
+			if !reflect.DeepEqual(sub.disposed, tt.wantDisposed) {
+				t.Fatalf("wanted the dispose counts %v when the attempts started but got %v", tt.wantDisposed, sub.disposed)
+			}
+			if got := logs.Lines(); !reflect.DeepEqual(got, tt.wantLogs) {
+				t.Fatalf("wanted the logs %q but got %q", tt.wantLogs, got)
+			}
+		})
+	}
+}
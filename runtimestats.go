@@ -0,0 +1,204 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"context"
+	"flag"
+	"runtime/metrics"
+	"time"
+
+	"github.com/google/subcommands"
+)
+
+// The runtime/metrics samples read by RuntimeStats.
+const (
+	heapObjectsMetric = "/memory/classes/heap/objects:bytes"
+	heapAllocsMetric  = "/gc/heap/allocs:bytes"
+	gcCyclesMetric    = "/gc/cycles/total:gc-cycles"
+	gcPauseMetric     = "/cpu/classes/gc/pause:cpu-seconds"
+	goroutinesMetric  = "/sched/goroutines:goroutines"
+)
+
+// RuntimeUsage is the usage of the Go runtime by an execution, reported by RuntimeStats. Its JSON
+// names are also the names of the logged fields, and are stable.
+type RuntimeUsage struct {
+	// HeapAlloc is the size of the heap objects when the execution finished.
+	HeapAlloc uint64 `json:"heap_alloc_bytes"`
+
+	// TotalAlloc is the size of the heap objects allocated during the execution.
+	TotalAlloc uint64 `json:"total_alloc_bytes"`
+
+	// GCCycles is the number of GC cycles completed during the execution.
+	GCCycles uint64 `json:"gc_cycles"`
+
+	// GCPause is the CPU time the application was paused by the GC during the execution, summed
+	// over all the Ps.
+	GCPause time.Duration `json:"gc_pause_cpu_ns"`
+
+	// Goroutines is the number of goroutines when the execution finished.
+	Goroutines uint64 `json:"goroutines"`
+}
+
+// RuntimeStatsReported is published by RuntimeStats when an execution run with -runtime-stats
+// finishes, canceled or not.
+type RuntimeStatsReported struct {
+	Command string
+	Time    time.Time
+	Usage   RuntimeUsage
+}
+
+// CommandName implements Event.
+func (e RuntimeStatsReported) CommandName() string { return e.Command }
+
+// runtimeSnapshot is the values of the runtime/metrics samples read by RuntimeStats.
+type runtimeSnapshot struct {
+	heapObjects uint64
+	heapAllocs  uint64
+	gcCycles    uint64
+	gcPause     float64
+	goroutines  uint64
+}
+
+// readRuntimeSnapshot reads the runtime/metrics samples of RuntimeStats.
+func readRuntimeSnapshot() runtimeSnapshot {
+	samples := []metrics.Sample{
+		{Name: heapObjectsMetric},
+		{Name: heapAllocsMetric},
+		{Name: gcCyclesMetric},
+		{Name: gcPauseMetric},
+		{Name: goroutinesMetric},
+	}
+	metrics.Read(samples)
+
+	return runtimeSnapshot{
+		heapObjects: sampleUint64(samples[0]),
+		heapAllocs:  sampleUint64(samples[1]),
+		gcCycles:    sampleUint64(samples[2]),
+		gcPause:     sampleFloat64(samples[3]),
+		goroutines:  sampleUint64(samples[4]),
+	}
+}
+
+// sampleUint64 returns the value of s, or 0 if it is not supported by the runtime.
+func sampleUint64(s metrics.Sample) uint64 {
+	if s.Value.Kind() != metrics.KindUint64 {
+		return 0
+	}
+
+	return s.Value.Uint64()
+}
+
+// sampleFloat64 returns the value of s, or 0 if it is not supported by the runtime.
+func sampleFloat64(s metrics.Sample) float64 {
+	if s.Value.Kind() != metrics.KindFloat64 {
+		return 0
+	}
+
+	return s.Value.Float64()
+}
+
+// usageSince returns the RuntimeUsage between start and end.
+func usageSince(start, end runtimeSnapshot) RuntimeUsage {
+	usage := RuntimeUsage{
+		HeapAlloc:  end.heapObjects,
+		Goroutines: end.goroutines,
+	}
+	if end.heapAllocs > start.heapAllocs {
+		usage.TotalAlloc = end.heapAllocs - start.heapAllocs
+	}
+	if end.gcCycles > start.gcCycles {
+		usage.GCCycles = end.gcCycles - start.gcCycles
+	}
+	if end.gcPause > start.gcPause {
+		usage.GCPause = time.Duration((end.gcPause - start.gcPause) * float64(time.Second))
+	}
+
+	return usage
+}
+
+// RuntimeStatsOption is an option of the RuntimeStats wrapper.
+type RuntimeStatsOption interface {
+	applyRuntimeStats(*runtimeStats)
+}
+
+// applyRuntimeStats implements RuntimeStatsOption.
+func (o LoggerOption) applyRuntimeStats(c *runtimeStats) {
+	c.logger = o.logger
+}
+
+// runtimeStats wraps a subcommands.Command so that its usage of the Go runtime can be reported.
+type runtimeStats struct {
+	sub    subcommands.Command
+	logger Logger
+
+	enabled bool
+}
+
+// make sure runtimeStats implements the subcommands.Command interface.
+var _ subcommands.Command = (*runtimeStats)(nil)
+
+// RuntimeStats wraps sub with the -runtime-stats flag. When it is set, the runtime/metrics of the
+// process are read before and after the execution, and its RuntimeUsage is logged to the standard
+// logger unless WithLogger is given, like:
+//
+//	build: runtime: heap_alloc_bytes=4194304 total_alloc_bytes=12582912 gc_cycles=3 gc_pause_cpu_ns=182000 goroutines=4
+//
+// and published to the Events of the execution context as a RuntimeStatsReported event. Canceled
+// executions are reported too when sub is Cancelable. Without the flag, nothing is read.
+func RuntimeStats(sub subcommands.Command, opts ...RuntimeStatsOption) subcommands.Command {
+	c := &runtimeStats{
+		sub:    sub,
+		logger: stdLogger{},
+	}
+	for _, opt := range opts {
+		opt.applyRuntimeStats(c)
+	}
+
+	return c
+}
+
+// Name forwards to the underlying c.sub Command.
+func (c *runtimeStats) Name() string {
+	return c.sub.Name()
+}
+
+// Usage forwards to the underlying c.sub Command.
+func (c *runtimeStats) Usage() string {
+	return c.sub.Usage()
+}
+
+// Synopsis forwards to the underlying c.sub Command.
+func (c *runtimeStats) Synopsis() string {
+	return c.sub.Synopsis()
+}
+
+// Unwrap returns the underlying c.sub Command.
+func (c *runtimeStats) Unwrap() subcommands.Command {
+	return c.sub
+}
+
+// SetFlags forwards to the underlying c.sub Command and registers the -runtime-stats flag.
+func (c *runtimeStats) SetFlags(f *flag.FlagSet) {
+	c.sub.SetFlags(f)
+	f.BoolVar(&c.enabled, "runtime-stats", false, "log the usage of the Go runtime when the command finishes")
+}
+
+// Execute forwards to the underlying c.sub Command, and reports its usage of the Go runtime if the
+// -runtime-stats flag is set.
+func (c *runtimeStats) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	if !c.enabled {
+		return c.sub.Execute(ctx, f, args...)
+	}
+
+	start := readRuntimeSnapshot()
+	status := c.sub.Execute(ctx, f, args...)
+	usage := usageSince(start, readRuntimeSnapshot())
+
+	contextLogger(ctx, c.logger).Printf("%s: runtime: heap_alloc_bytes=%d total_alloc_bytes=%d gc_cycles=%d gc_pause_cpu_ns=%d goroutines=%d",
+		c.sub.Name(), usage.HeapAlloc, usage.TotalAlloc, usage.GCCycles, int64(usage.GCPause), usage.Goroutines)
+	publish(ctx, RuntimeStatsReported{Command: c.sub.Name(), Time: ClockFromContext(ctx).Now(), Usage: usage})
+
+	return status
+}
@@ -0,0 +1,91 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"context"
+	"flag"
+	"regexp"
+	"testing"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+// runtimeStatsLine matches the line logged by RuntimeStats for the build command.
+var runtimeStatsLine = regexp.MustCompile(`^build: runtime: heap_alloc_bytes=[0-9]+ total_alloc_bytes=[0-9]+ gc_cycles=[0-9]+ gc_pause_cpu_ns=[0-9]+ goroutines=[0-9]+$`)
+
+func TestRuntimeStats(t *testing.T) {
+	tests := map[string]struct {
+		args   []string
+		cancel bool
+		want   bool
+	}{
+		"when the flag is set": {
+			args: []string{"-runtime-stats"},
+			want: true,
+		},
+		"when the flag is set and the command is canceled": {
+			args:   []string{"-runtime-stats"},
+			cancel: true,
+			want:   true,
+		},
+		"when the flag is not set": {},
+	}
+	for name, tt := range tests {
+		tt := tt
+		t.Run(name, func(t *testing.T) {
+			var sink [][]byte
+			sub := testcmd.NewRecording("build", testcmd.WithExecute(func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+				for i := 0; i < 64; i++ {
+					sink = append(sink, make([]byte, 1024))
+				}
+				if tt.cancel {
+					<-ctx.Done()
+				}
+				return subcommands.ExitSuccess
+			}))
+
+			var reported []subcommandsutil.RuntimeStatsReported
+			events := subcommandsutil.NewEvents()
+			events.Subscribe(func(ev subcommandsutil.Event) {
+				if ev, ok := ev.(subcommandsutil.RuntimeStatsReported); ok {
+					reported = append(reported, ev)
+				}
+			})
+			ctx, cancel := context.WithCancel(subcommandsutil.WithEvents(context.Background(), events))
+			if tt.cancel {
+				cancel()
+			}
+			defer cancel()
+
+			logger := &testcmd.LogRecorder{}
+			cmd := subcommandsutil.RuntimeStats(subcommandsutil.Cancelable(sub, subcommandsutil.WithLogger(&testcmd.LogRecorder{})), subcommandsutil.WithLogger(logger))
+			testcmd.Run(ctx, cmd, tt.args...)
+
+			if !tt.want {
+				if lines := logger.Lines(); len(lines) != 0 || len(reported) != 0 {
+					t.Fatalf("wanted no report but got %q and %+v", lines, reported)
+				}
+				return
+			}
+			lines := logger.Lines()
+			if len(lines) != 1 || !runtimeStatsLine.MatchString(lines[0]) {
+				t.Fatalf("wanted the runtime stats line but got %q", lines)
+			}
+			if len(reported) != 1 {
+				t.Fatalf("wanted a RuntimeStatsReported event but got %+v", reported)
+			}
+			usage := reported[0].Usage
+			if reported[0].Command != "build" || usage.HeapAlloc == 0 || usage.Goroutines == 0 || usage.GCPause < 0 {
+				t.Fatalf("wanted the usage of build but got %+v", reported[0])
+			}
+			if !tt.cancel && usage.TotalAlloc == 0 {
+				t.Fatalf("wanted the allocations of build but got %+v", usage)
+			}
+		})
+	}
+}
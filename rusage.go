@@ -0,0 +1,175 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/google/subcommands"
+)
+
+// ProcessUsage is the usage of the resources of the operating system by an execution, reported by
+// ResourceUsage. Its JSON names are also the names of the logged fields, and are stable.
+type ProcessUsage struct {
+	// MaxRSS is the peak resident set size of the process when the execution finished.
+	MaxRSS uint64 `json:"max_rss_bytes"`
+
+	// UserTime and SystemTime are the CPU time the process spent in user and kernel mode during
+	// the execution.
+	UserTime   time.Duration `json:"user_time_ns"`
+	SystemTime time.Duration `json:"system_time_ns"`
+
+	// ChildrenMaxRSS is the peak resident set size of the largest child waited for when the
+	// execution finished.
+	ChildrenMaxRSS uint64 `json:"children_max_rss_bytes"`
+
+	// ChildrenUserTime and ChildrenSystemTime are the CPU time the children waited for during the
+	// execution spent in user and kernel mode.
+	ChildrenUserTime   time.Duration `json:"children_user_time_ns"`
+	ChildrenSystemTime time.Duration `json:"children_system_time_ns"`
+}
+
+// ResourceUsageReported is published by ResourceUsage when an execution run with -rusage finishes,
+// canceled or not.
+type ResourceUsageReported struct {
+	Command string
+	Time    time.Time
+	Usage   ProcessUsage
+}
+
+// CommandName implements Event.
+func (e ResourceUsageReported) CommandName() string { return e.Command }
+
+// rusageSnapshot is the resource usage of the process and of its children, read by readRusage.
+type rusageSnapshot struct {
+	self, children rusageValues
+}
+
+// rusageValues is the resource usage of the process or of its children, normalized to bytes and
+// durations.
+type rusageValues struct {
+	maxRSS       uint64
+	user, system time.Duration
+}
+
+// processUsageSince returns the ProcessUsage between start and end.
+func processUsageSince(start, end rusageSnapshot) ProcessUsage {
+	return ProcessUsage{
+		MaxRSS:             end.self.maxRSS,
+		UserTime:           durationSince(start.self.user, end.self.user),
+		SystemTime:         durationSince(start.self.system, end.self.system),
+		ChildrenMaxRSS:     end.children.maxRSS,
+		ChildrenUserTime:   durationSince(start.children.user, end.children.user),
+		ChildrenSystemTime: durationSince(start.children.system, end.children.system),
+	}
+}
+
+// durationSince returns end - start, or 0 if end is before start.
+func durationSince(start, end time.Duration) time.Duration {
+	if end < start {
+		return 0
+	}
+
+	return end - start
+}
+
+// ResourceUsageOption is an option of the ResourceUsage wrapper.
+type ResourceUsageOption interface {
+	applyResourceUsage(*resourceUsage)
+}
+
+// applyResourceUsage implements ResourceUsageOption.
+func (o LoggerOption) applyResourceUsage(c *resourceUsage) {
+	c.logger = o.logger
+}
+
+// resourceUsage wraps a subcommands.Command so that its usage of the resources of the operating
+// system can be reported.
+type resourceUsage struct {
+	sub    subcommands.Command
+	logger Logger
+
+	enabled bool
+}
+
+// make sure resourceUsage implements the subcommands.Command interface.
+var _ subcommands.Command = (*resourceUsage)(nil)
+
+// ResourceUsage wraps sub with the -rusage flag. When it is set, the resource usage of the process
+// and of the children it waited for are read with getrusage before and after the execution, and
+// its ProcessUsage is logged to the standard logger unless WithLogger is given, like:
+//
+//	build: rusage: max_rss_bytes=52428800 user_time_ns=1200000000 system_time_ns=300000000 children_max_rss_bytes=10485760 children_user_time_ns=800000000 children_system_time_ns=100000000
+//
+// and published to the Events of the execution context as a ResourceUsageReported event, which
+// ResultFile records. The peak resident set sizes are the ones of the whole process, not of the
+// execution alone. On platforms without getrusage, a warning is printed to Stderr and sub runs
+// unreported. Without the flag, nothing is read.
+func ResourceUsage(sub subcommands.Command, opts ...ResourceUsageOption) subcommands.Command {
+	c := &resourceUsage{
+		sub:    sub,
+		logger: stdLogger{},
+	}
+	for _, opt := range opts {
+		opt.applyResourceUsage(c)
+	}
+
+	return c
+}
+
+// Name forwards to the underlying c.sub Command.
+func (c *resourceUsage) Name() string {
+	return c.sub.Name()
+}
+
+// Usage forwards to the underlying c.sub Command.
+func (c *resourceUsage) Usage() string {
+	return c.sub.Usage()
+}
+
+// Synopsis forwards to the underlying c.sub Command.
+func (c *resourceUsage) Synopsis() string {
+	return c.sub.Synopsis()
+}
+
+// Unwrap returns the underlying c.sub Command.
+func (c *resourceUsage) Unwrap() subcommands.Command {
+	return c.sub
+}
+
+// SetFlags forwards to the underlying c.sub Command and registers the -rusage flag.
+func (c *resourceUsage) SetFlags(f *flag.FlagSet) {
+	c.sub.SetFlags(f)
+	f.BoolVar(&c.enabled, "rusage", false, "log the memory and CPU time used when the command finishes")
+}
+
+// Execute forwards to the underlying c.sub Command, and reports its resource usage if the -rusage
+// flag is set.
+func (c *resourceUsage) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	if !c.enabled {
+		return c.sub.Execute(ctx, f, args...)
+	}
+
+	start, err := readRusage()
+	if err != nil {
+		fmt.Fprintf(Stderr(ctx), "warning: %s: -rusage: %v\n", c.sub.Name(), err)
+		return c.sub.Execute(ctx, f, args...)
+	}
+	status := c.sub.Execute(ctx, f, args...)
+	end, err := readRusage()
+	if err != nil {
+		fmt.Fprintf(Stderr(ctx), "warning: %s: -rusage: %v\n", c.sub.Name(), err)
+		return status
+	}
+	usage := processUsageSince(start, end)
+
+	contextLogger(ctx, c.logger).Printf("%s: rusage: max_rss_bytes=%d user_time_ns=%d system_time_ns=%d children_max_rss_bytes=%d children_user_time_ns=%d children_system_time_ns=%d",
+		c.sub.Name(), usage.MaxRSS, int64(usage.UserTime), int64(usage.SystemTime), usage.ChildrenMaxRSS, int64(usage.ChildrenUserTime), int64(usage.ChildrenSystemTime))
+	publish(ctx, ResourceUsageReported{Command: c.sub.Name(), Time: ClockFromContext(ctx).Now(), Usage: usage})
+
+	return status
+}
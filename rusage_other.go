@@ -0,0 +1,16 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+//go:build !unix
+
+package subcommandsutil
+
+import "errors"
+
+// errRusageUnsupported is returned by readRusage on platforms without getrusage.
+var errRusageUnsupported = errors.New("resource usage is not supported on this platform")
+
+// readRusage reports that the resource usage cannot be read on this platform.
+func readRusage() (rusageSnapshot, error) {
+	return rusageSnapshot{}, errRusageUnsupported
+}
@@ -0,0 +1,33 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+//go:build !unix
+
+package subcommandsutil_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+func TestResourceUsageUnsupported(t *testing.T) {
+	var stderr testcmd.Buffer
+	ctx := subcommandsutil.WithOutput(context.Background(), &testcmd.Buffer{}, &stderr)
+	sub := testcmd.NewRecording("build")
+	logger := &testcmd.LogRecorder{}
+
+	status, _, _ := testcmd.Run(ctx, subcommandsutil.ResourceUsage(sub, subcommandsutil.WithLogger(logger)), "-rusage")
+	testcmd.RequireSuccess(t, status)
+	if got := sub.CallCount(); got != 1 {
+		t.Fatalf("wanted 1 execution but got %d", got)
+	}
+	if want := "warning: build: -rusage: resource usage is not supported on this platform\n"; stderr.String() != want {
+		t.Fatalf("wanted stderr %q but got %q", want, stderr.String())
+	}
+	if lines := logger.Lines(); len(lines) != 0 {
+		t.Fatalf("wanted no rusage line but got %q", lines)
+	}
+}
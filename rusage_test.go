@@ -0,0 +1,102 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+//go:build linux
+
+package subcommandsutil_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"flag"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+// rusageLine matches the line logged by ResourceUsage for the build command.
+var rusageLine = regexp.MustCompile(`^build: rusage: max_rss_bytes=[0-9]+ user_time_ns=[0-9]+ system_time_ns=[0-9]+ children_max_rss_bytes=[0-9]+ children_user_time_ns=[0-9]+ children_system_time_ns=[0-9]+$`)
+
+func TestResourceUsage(t *testing.T) {
+	sub := testcmd.NewRecording("build", testcmd.WithExecute(func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+		// burn some CPU time, and wait for a child process
+		sum := sha256.Sum256(nil)
+		for i := 0; i < 200000; i++ {
+			sum = sha256.Sum256(sum[:])
+		}
+		if err := exec.Command(os.Args[0], "-test.run=^$").Run(); err != nil {
+			t.Error(err)
+			return subcommands.ExitFailure
+		}
+		return subcommands.ExitSuccess
+	}))
+
+	var reported []subcommandsutil.ProcessUsage
+	events := subcommandsutil.NewEvents()
+	events.Subscribe(func(ev subcommandsutil.Event) {
+		if ev, ok := ev.(subcommandsutil.ResourceUsageReported); ok {
+			reported = append(reported, ev.Usage)
+		}
+	})
+	ctx := subcommandsutil.WithEvents(context.Background(), events)
+	logger := &testcmd.LogRecorder{}
+	cmd := subcommandsutil.ResultFile(subcommandsutil.ResourceUsage(sub, subcommandsutil.WithLogger(logger)))
+	path := filepath.Join(t.TempDir(), "result.json")
+
+	for i := 0; i < 2; i++ {
+		status, _, _ := testcmd.Run(ctx, cmd, "-rusage", "-result-file", path)
+		testcmd.RequireSuccess(t, status)
+	}
+
+	lines := logger.Lines()
+	if len(lines) != 2 || !rusageLine.MatchString(lines[0]) || !rusageLine.MatchString(lines[1]) {
+		t.Fatalf("wanted the rusage lines but got %q", lines)
+	}
+	if len(reported) != 2 {
+		t.Fatalf("wanted 2 ResourceUsageReported events but got %+v", reported)
+	}
+	for _, usage := range reported {
+		if usage.MaxRSS == 0 || usage.UserTime+usage.SystemTime == 0 {
+			t.Fatalf("wanted the usage of the process populated but got %+v", usage)
+		}
+		if usage.ChildrenMaxRSS == 0 || usage.ChildrenUserTime+usage.ChildrenSystemTime == 0 {
+			t.Fatalf("wanted the usage of the children populated but got %+v", usage)
+		}
+	}
+	if first, second := reported[0], reported[1]; second.MaxRSS < first.MaxRSS || second.ChildrenMaxRSS < first.ChildrenMaxRSS {
+		t.Fatalf("wanted the peak resident set sizes to be monotonic but got %+v then %+v", first, second)
+	}
+
+	result := readRunResult(t, path)
+	if result.Rusage == nil || *result.Rusage != reported[1] {
+		t.Fatalf("wanted the result file to record %+v but got %+v", reported[1], result.Rusage)
+	}
+}
+
+func TestResourceUsageDisabled(t *testing.T) {
+	events := subcommandsutil.NewEvents()
+	events.Subscribe(func(ev subcommandsutil.Event) {
+		if _, ok := ev.(subcommandsutil.ResourceUsageReported); ok {
+			t.Fatalf("wanted no ResourceUsageReported event but got %+v", ev)
+		}
+	})
+	logger := &testcmd.LogRecorder{}
+	cmd := subcommandsutil.ResultFile(subcommandsutil.ResourceUsage(testcmd.NewRecording("build"), subcommandsutil.WithLogger(logger)))
+	path := filepath.Join(t.TempDir(), "result.json")
+
+	status, _, _ := testcmd.Run(subcommandsutil.WithEvents(context.Background(), events), cmd, "-result-file", path)
+	testcmd.RequireSuccess(t, status)
+	if lines := logger.Lines(); len(lines) != 0 {
+		t.Fatalf("wanted no rusage line but got %q", lines)
+	}
+	if result := readRunResult(t, path); result.Rusage != nil {
+		t.Fatalf("wanted no rusage in the result file but got %+v", result.Rusage)
+	}
+}
@@ -0,0 +1,43 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+//go:build unix
+
+package subcommandsutil
+
+import (
+	"runtime"
+	"syscall"
+	"time"
+)
+
+// readRusage reads the resource usage of the process and of the children it waited for.
+func readRusage() (rusageSnapshot, error) {
+	var self, children syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &self); err != nil {
+		return rusageSnapshot{}, err
+	}
+	if err := syscall.Getrusage(syscall.RUSAGE_CHILDREN, &children); err != nil {
+		return rusageSnapshot{}, err
+	}
+
+	return rusageSnapshot{
+		self:     rusageValuesOf(&self),
+		children: rusageValuesOf(&children),
+	}, nil
+}
+
+// rusageValuesOf normalizes ru. The maximum resident set size is in bytes on darwin, and in
+// kilobytes elsewhere.
+func rusageValuesOf(ru *syscall.Rusage) rusageValues {
+	maxRSS := uint64(ru.Maxrss)
+	if runtime.GOOS != "darwin" && runtime.GOOS != "ios" {
+		maxRSS *= 1024
+	}
+
+	return rusageValues{
+		maxRSS: maxRSS,
+		user:   time.Duration(ru.Utime.Nano()),
+		system: time.Duration(ru.Stime.Nano()),
+	}
+}
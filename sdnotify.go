@@ -0,0 +1,227 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/subcommands"
+)
+
+// notifierKey is the context key of the notifier of a SystemdNotify execution.
+type notifierKey struct{}
+
+// NotifyReady tells systemd that the startup of the command executing with ctx is complete, by
+// sending READY=1 once to the NOTIFY_SOCKET of SystemdNotify, and makes the /readyz endpoint of a
+// HealthServer report it ready. It does nothing if ctx is of neither, or if systemd did not set
+// NOTIFY_SOCKET.
+func NotifyReady(ctx context.Context) error {
+	if h, ok := ctx.Value(healthKey{}).(*health); ok {
+		atomic.StoreInt32(&h.ready, 1)
+	}
+
+	n, ok := ctx.Value(notifierKey{}).(*notifier)
+	if !ok {
+		return nil
+	}
+
+	var err error
+	n.readyOnce.Do(func() {
+		err = n.send("READY=1")
+	})
+
+	return err
+}
+
+// systemdNotify wraps a CancelableCommand so that it reports its state to systemd.
+type systemdNotify struct {
+	sub CancelableCommand
+
+	mu sync.Mutex
+	n  *notifier // the notifier of the running execution
+}
+
+// make sure systemdNotify implements the CancelableCommand interface.
+var _ CancelableCommand = (*systemdNotify)(nil)
+
+// SystemdNotify wraps sub so that it reports its state to systemd when it runs as a service of
+// Type=notify, through the sd_notify datagram protocol over the unix socket of NOTIFY_SOCKET:
+//
+//   - READY=1 when sub calls NotifyReady, once its startup is complete;
+//   - WATCHDOG=1 every half of WATCHDOG_USEC, measured on the Clock of the execution context, if
+//     systemd set it for this process;
+//   - STOPPING=1 when the execution context is canceled.
+//
+// The wrapper does nothing when NOTIFY_SOCKET is not set. A socket which cannot be dialed is warned
+// about on Stderr, and does not stop sub. The watchdog stops when Execute returns, or by Dispose
+// when a Cancelable wrapper stops waiting for sub; Dispose also forwards to the Dispose method of
+// sub.
+func SystemdNotify(sub CancelableCommand) CancelableCommand {
+	return &systemdNotify{
+		sub: sub,
+	}
+}
+
+// Name forwards to the underlying c.sub Command.
+func (c *systemdNotify) Name() string {
+	return c.sub.Name()
+}
+
+// Usage forwards to the underlying c.sub Command.
+func (c *systemdNotify) Usage() string {
+	return c.sub.Usage()
+}
+
+// Synopsis forwards to the underlying c.sub Command.
+func (c *systemdNotify) Synopsis() string {
+	return c.sub.Synopsis()
+}
+
+// Unwrap returns the underlying c.sub Command.
+func (c *systemdNotify) Unwrap() subcommands.Command {
+	return c.sub
+}
+
+// SetFlags forwards to the underlying c.sub Command.
+func (c *systemdNotify) SetFlags(f *flag.FlagSet) {
+	c.sub.SetFlags(f)
+}
+
+// Dispose stops the watchdog of the running execution, and forwards to the underlying c.sub
+// Command.
+func (c *systemdNotify) Dispose() error {
+	c.mu.Lock()
+	n := c.n
+	c.mu.Unlock()
+	n.close()
+
+	return c.sub.Dispose()
+}
+
+// Execute forwards to the underlying c.sub Command while reporting its state to systemd.
+func (c *systemdNotify) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return c.sub.Execute(ctx, f, args...)
+	}
+	n, err := dialNotifier(socket)
+	if err != nil {
+		fmt.Fprintf(Stderr(ctx), "warning: NOTIFY_SOCKET: %v\n", err)
+		return c.sub.Execute(ctx, f, args...)
+	}
+
+	if interval := watchdogInterval(os.Getenv("WATCHDOG_USEC"), os.Getenv("WATCHDOG_PID")); interval > 0 {
+		n.startWatchdog(ClockFromContext(ctx), interval)
+	}
+	c.mu.Lock()
+	c.n = n
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		c.n = nil
+		c.mu.Unlock()
+		n.close()
+	}()
+
+	// a Cancelable wrapper runs its hooks before disposing of sub, which closes the socket
+	defer onCancel(ctx, n.stopping)()
+	defer context.AfterFunc(ctx, n.stopping)()
+
+	return c.sub.Execute(context.WithValue(ctx, notifierKey{}, n), f, args...)
+}
+
+// watchdogInterval returns the interval of the WATCHDOG=1 messages from the values of WATCHDOG_USEC
+// and WATCHDOG_PID, or 0 if systemd does not expect them from this process.
+func watchdogInterval(usec, pid string) time.Duration {
+	if pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+	n, err := strconv.ParseInt(usec, 10, 64)
+	if err != nil || n <= 0 {
+		return 0
+	}
+
+	return time.Duration(n) * time.Microsecond / 2
+}
+
+// notifier sends the sd_notify messages of an execution.
+type notifier struct {
+	conn *net.UnixConn
+
+	readyOnce    sync.Once
+	stoppingOnce sync.Once
+
+	closeOnce sync.Once
+	stopped   chan struct{}
+	done      chan struct{} // closed when the watchdog returns, if started
+}
+
+// dialNotifier dials the unix datagram socket at socket, systemd's NOTIFY_SOCKET. A leading "@"
+// denotes an abstract socket.
+func dialNotifier(socket string) (*notifier, error) {
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return nil, err
+	}
+
+	return &notifier{
+		conn:    conn,
+		stopped: make(chan struct{}),
+	}, nil
+}
+
+// send sends the state, like "READY=1".
+func (n *notifier) send(state string) error {
+	_, err := n.conn.Write([]byte(state))
+	return err
+}
+
+// stopping sends STOPPING=1, once.
+func (n *notifier) stopping() {
+	n.stoppingOnce.Do(func() {
+		_ = n.send("STOPPING=1")
+	})
+}
+
+// startWatchdog starts sending WATCHDOG=1 every interval, measured on clk.
+func (n *notifier) startWatchdog(clk Clock, interval time.Duration) {
+	n.done = make(chan struct{})
+	ticker := clk.NewTicker(interval)
+	go func() {
+		defer close(n.done)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-n.stopped:
+				return
+			case <-ticker.C():
+				_ = n.send("WATCHDOG=1")
+			}
+		}
+	}()
+}
+
+// close stops the watchdog and closes the socket, once. It does nothing on a nil n.
+func (n *notifier) close() {
+	if n == nil {
+		return
+	}
+
+	n.closeOnce.Do(func() {
+		close(n.stopped)
+		if n.done != nil {
+			<-n.done
+		}
+		n.conn.Close()
+	})
+}
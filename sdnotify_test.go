@@ -0,0 +1,145 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"context"
+	"flag"
+	"net"
+	"os"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+// listenNotify listens on a unix datagram socket set as NOTIFY_SOCKET, and returns a function
+// receiving its next message.
+func listenNotify(t *testing.T) (receive func() string) {
+	t.Helper()
+
+	path := controlSocketPath(t)
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	t.Setenv("NOTIFY_SOCKET", path)
+
+	return func() string {
+		t.Helper()
+
+		buf := make([]byte, 256)
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		n, err := conn.Read(buf)
+		if err != nil {
+			t.Fatalf("wanted a message but got %v", err)
+		}
+		return string(buf[:n])
+	}
+}
+
+func TestSystemdNotify(t *testing.T) {
+	defer testcmd.VerifyNoLeaks(t)
+
+	receive := listenNotify(t)
+	t.Setenv("WATCHDOG_USEC", "2000000")
+	t.Setenv("WATCHDOG_PID", strconv.Itoa(os.Getpid()))
+	clk := testcmd.NewFakeClock(time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC))
+
+	var got []string
+	sub := testcmd.NewRecording("agent", testcmd.WithExecute(func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+		for i := 0; i < 2; i++ {
+			if err := subcommandsutil.NotifyReady(ctx); err != nil {
+				t.Errorf("wanted no error but got %v", err)
+			}
+		}
+		got = append(got, receive())
+		clk.Advance(time.Second)
+		got = append(got, receive())
+		return subcommands.ExitSuccess
+	}))
+
+	status, _, _ := testcmd.Run(subcommandsutil.WithClock(context.Background(), clk), subcommandsutil.SystemdNotify(sub))
+	testcmd.RequireSuccess(t, status)
+	if want := []string{"READY=1", "WATCHDOG=1"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("wanted the messages %q but got %q", want, got)
+	}
+}
+
+func TestSystemdNotifyCanceled(t *testing.T) {
+	defer testcmd.VerifyNoLeaks(t)
+
+	receive := listenNotify(t)
+	tcmd := testcmd.NewBlocking("agent")
+	defer tcmd.Release(subcommands.ExitSuccess)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-tcmd.Started()
+		cancel()
+	}()
+	status, _, _ := testcmd.Run(ctx, subcommandsutil.Cancelable(subcommandsutil.SystemdNotify(tcmd), subcommandsutil.WithLogger(&testcmd.LogRecorder{})))
+	testcmd.AssertStatus(t, status, subcommands.ExitFailure)
+	if got := receive(); got != "STOPPING=1" {
+		t.Fatalf("wanted the message %q but got %q", "STOPPING=1", got)
+	}
+	if tcmd.DisposeCount() != 1 {
+		t.Fatalf("wanted the command disposed once but got %d", tcmd.DisposeCount())
+	}
+}
+
+func TestSystemdNotifyWithoutSocket(t *testing.T) {
+	t.Setenv("NOTIFY_SOCKET", "")
+
+	var err error
+	sub := testcmd.NewRecording("agent", testcmd.WithExecute(func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+		err = subcommandsutil.NotifyReady(ctx)
+		return subcommands.ExitSuccess
+	}))
+
+	status, _, _ := testcmd.Run(context.Background(), subcommandsutil.SystemdNotify(sub))
+	testcmd.RequireSuccess(t, status)
+	if err != nil || sub.CallCount() != 1 {
+		t.Fatalf("wanted the command executed without error but got %d calls and %v", sub.CallCount(), err)
+	}
+}
+
+func TestWatchdogInterval(t *testing.T) {
+	tests := map[string]struct {
+		usec string
+		pid  string
+		want time.Duration
+	}{
+		"when WATCHDOG_USEC is set": {
+			usec: "30000000",
+			want: 15 * time.Second,
+		},
+		"when WATCHDOG_PID is this process": {
+			usec: "30000000",
+			pid:  strconv.Itoa(os.Getpid()),
+			want: 15 * time.Second,
+		},
+		"when WATCHDOG_PID is another process": {
+			usec: "30000000",
+			pid:  strconv.Itoa(os.Getpid() + 1),
+		},
+		"when WATCHDOG_USEC is not set": {},
+		"when WATCHDOG_USEC is invalid": {
+			usec: "30s",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := subcommandsutil.WatchdogInterval(tt.usec, tt.pid); got != tt.want {
+				t.Fatalf("wanted the interval %v but got %v", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"flag"
+)
+
+// Redacted replaces the value of a sensitive flag wherever this package renders flag values.
+const Redacted = "[REDACTED]"
+
+// MarkSensitive marks the named flags of f as sensitive. The values of sensitive flags stay
+// available to the Command, but are rendered as Redacted by this package, and their defaults are
+// omitted from PrintDefaults.
+//
+// MarkSensitive is usually called from SetFlags, after the flags are defined. It panics if f does
+// not define one of names.
+func MarkSensitive(f *flag.FlagSet, names ...string) {
+	for _, name := range names {
+		mustLookup(f, name)
+	}
+
+	updateFlagMeta(f, func(m *flagMeta) {
+		for _, name := range names {
+			m.sensitive[name] = true
+		}
+	})
+}
+
+// IsSensitiveFlag reports whether the flag named name of f, or the flag it is an alias of, was
+// marked by MarkSensitive.
+func IsSensitiveFlag(f *flag.FlagSet, name string) (sensitive bool) {
+	canonical := CanonicalFlagName(f, name)
+	readFlagMeta(f, func(m *flagMeta) {
+		sensitive = m != nil && (m.sensitive[name] || m.sensitive[canonical])
+	})
+
+	return sensitive
+}
+
+// flagValueString returns the value of the flag fl of f for rendering, redacting sensitive values.
+func flagValueString(f *flag.FlagSet, fl *flag.Flag) string {
+	if IsSensitiveFlag(f, fl.Name) {
+		return Redacted
+	}
+
+	return fl.Value.String()
+}
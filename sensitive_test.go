@@ -0,0 +1,83 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"strings"
+	"testing"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+func TestMarkSensitive(t *testing.T) {
+	var token string
+	setFlags := func(f *flag.FlagSet) {
+		f.StringVar(&token, "token", "default-secret", "API token")
+		f.String("user", "", "user name")
+		subcommandsutil.AliasFlag(f, "token", "t")
+		subcommandsutil.MarkSensitive(f, "token")
+	}
+
+	t.Run("logging", func(t *testing.T) {
+		var gotToken string
+		var logs testcmd.LogRecorder
+		cmd := subcommandsutil.Logged(testcmd.NewRecording("push",
+			testcmd.WithFlags(setFlags),
+			testcmd.WithExecute(func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+				gotToken = token
+				return subcommands.ExitSuccess
+			}),
+		), subcommandsutil.WithLogger(&logs))
+
+		f := flag.NewFlagSet("push", flag.ContinueOnError)
+		cmd.SetFlags(f)
+		if err := f.Parse([]string{"-t", "s3cr3t", "-user", "gopher", "origin"}); err != nil {
+			t.Fatal(err)
+		}
+		cmd.Execute(context.Background(), f)
+
+		if gotToken != "s3cr3t" {
+			t.Fatalf("wanted the command to see the real token but got %q", gotToken)
+		}
+		if logs.Contains("s3cr3t") {
+			t.Fatalf("wanted the token to be redacted but got %q", logs.Lines())
+		}
+		// the alias is logged as the flag it stands for
+		if want := "push '-token=[REDACTED]' -user=gopher origin"; !logs.Contains(want) {
+			t.Fatalf("wanted logs to contain %q but got %q", want, logs.Lines())
+		}
+	})
+
+	t.Run("usage", func(t *testing.T) {
+		var buf bytes.Buffer
+		f := flag.NewFlagSet("push", flag.ContinueOnError)
+		f.SetOutput(&buf)
+		setFlags(f)
+		subcommandsutil.PrintDefaults(f)
+
+		if strings.Contains(buf.String(), "default-secret") {
+			t.Fatalf("wanted the default to be hidden but got %q", buf.String())
+		}
+		if !strings.Contains(buf.String(), "-t, -token string") {
+			t.Fatalf("wanted the flag to be listed but got %q", buf.String())
+		}
+	})
+
+	t.Run("alias", func(t *testing.T) {
+		f := flag.NewFlagSet("push", flag.ContinueOnError)
+		setFlags(f)
+		if !subcommandsutil.IsSensitiveFlag(f, "t") {
+			t.Fatal("wanted the alias of a sensitive flag to be sensitive")
+		}
+		if subcommandsutil.IsSensitiveFlag(f, "user") {
+			t.Fatal("wanted user not to be sensitive")
+		}
+	})
+}
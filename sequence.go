@@ -0,0 +1,163 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"github.com/google/subcommands"
+)
+
+// SequenceOption is an option of the Sequence composite.
+type SequenceOption interface {
+	applySequence(*sequence)
+}
+
+// sequenceOptionFunc is a SequenceOption implemented by a function.
+type sequenceOptionFunc func(*sequence)
+
+// applySequence implements SequenceOption.
+func (fn sequenceOptionFunc) applySequence(c *sequence) { fn(c) }
+
+// applySequence implements SequenceOption. The Logger logs the cancellation of the members.
+func (o LoggerOption) applySequence(c *sequence) {
+	c.cancelableOpts = append(c.cancelableOpts, o)
+}
+
+// applySequence implements SequenceOption.
+func (o AggregatorOption) applySequence(c *sequence) {
+	c.aggregate = o.aggregate
+}
+
+// WithContinueOnError makes Sequence run the remaining members after a member fails.
+func WithContinueOnError() SequenceOption {
+	return sequenceOptionFunc(func(c *sequence) {
+		c.continueOnError = true
+	})
+}
+
+// sequence is a subcommands.Command running several commands in order.
+type sequence struct {
+	name     string
+	synopsis string
+	members  []CancelableCommand
+
+	continueOnError bool
+	aggregate       StatusAggregator
+	cancelableOpts  []CancelableOption
+
+	executing executing
+}
+
+// make sure sequence implements the subcommands.Command interface.
+var _ subcommands.Command = (*sequence)(nil)
+
+// Sequence returns a command named name which runs members in order, stopping at the first member
+// returning a status other than subcommands.ExitSuccess unless WithContinueOnError is given. Each
+// member parses the positional arguments of the command with its own FlagSet, and runs in
+// Cancelable. The statuses of the members run are combined by the StatusAggregator set by
+// WithAggregator, FirstFailure by default.
+//
+// When the execution context is done, no further member is run and subcommands.ExitFailure is
+// returned. The member running is disposed, and so are the members already run whose
+// PersistentResources method reports true.
+func Sequence(name, synopsis string, members []CancelableCommand, opts ...SequenceOption) subcommands.Command {
+	c := &sequence{
+		name:      name,
+		synopsis:  synopsis,
+		members:   members,
+		aggregate: FirstFailure,
+	}
+	for _, opt := range opts {
+		opt.applySequence(c)
+	}
+
+	return c
+}
+
+// Name implements subcommands.Command.
+func (c *sequence) Name() string {
+	return c.name
+}
+
+// Synopsis implements subcommands.Command.
+func (c *sequence) Synopsis() string {
+	return c.synopsis
+}
+
+// Usage implements subcommands.Command. It lists the members.
+func (c *sequence) Usage() string {
+	return fmt.Sprintf("%s [args...]:\n  %s\n\nRuns in order:\n%s", c.name, c.synopsis, listMembers(c.members))
+}
+
+// SetFlags implements subcommands.Command. The members parse their own flags from the positional
+// arguments.
+func (c *sequence) SetFlags(f *flag.FlagSet) {}
+
+// Execute runs the members in order and returns their aggregated status.
+func (c *sequence) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	if !c.executing.enter(ctx, stdLogger{}, c.name) {
+		return subcommands.ExitFailure
+	}
+	defer c.executing.exit()
+
+	flagSets, status := memberFlagSets(ctx, c.members, f.Args())
+	if status != subcommands.ExitSuccess {
+		return status
+	}
+
+	var statuses []subcommands.ExitStatus
+	for i, member := range c.members {
+		if ctx.Err() != nil {
+			break
+		}
+
+		cw := Cancelable(member, c.cancelableOpts...).(CancelableWrapper)
+		status := cw.Execute(ctx, flagSets[i], args...)
+		statuses = append(statuses, status)
+		if ctx.Err() != nil {
+			run := c.members[:i+1]
+			if cw.Canceled() {
+				// the running member was disposed by Cancelable
+				run = c.members[:i]
+			}
+			disposePersistent(run)
+			break
+		}
+		if status != subcommands.ExitSuccess && !c.continueOnError {
+			break
+		}
+	}
+	if ctx.Err() != nil {
+		fmt.Fprintf(Stderr(ctx), "%s: %v\n", c.name, ctx.Err())
+		return subcommands.ExitFailure
+	}
+
+	return c.aggregate(statuses)
+}
+
+// disposePersistent disposes the members whose PersistentResources method reports true.
+func disposePersistent(members []CancelableCommand) {
+	for _, member := range members {
+		if hasPersistentResources(member) {
+			_ = member.Dispose()
+		}
+	}
+}
+
+// hasPersistentResources reports whether cmd, or a Command it wraps, has a PersistentResources
+// method reporting true. The outermost PersistentResources method found decides.
+func hasPersistentResources(cmd subcommands.Command) (persistent bool) {
+	walkCommand(cmd, func(cmd subcommands.Command) bool {
+		p, ok := cmd.(interface{ PersistentResources() bool })
+		if ok {
+			persistent = p.PersistentResources()
+		}
+		return ok
+	})
+
+	return persistent
+}
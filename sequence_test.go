@@ -0,0 +1,131 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+// persistent is a Recording declaring persistent resources.
+type persistent struct {
+	*testcmd.Recording
+}
+
+// PersistentResources implements the marker checked by Sequence.
+func (persistent) PersistentResources() bool { return true }
+
+func TestSequence(t *testing.T) {
+	tests := map[string]struct {
+		statuses   []subcommands.ExitStatus
+		opts       []subcommandsutil.SequenceOption
+		wantStatus subcommands.ExitStatus
+		wantRun    []bool
+	}{
+		"when every member succeeds": {
+			statuses:   []subcommands.ExitStatus{subcommands.ExitSuccess, subcommands.ExitSuccess, subcommands.ExitSuccess},
+			wantStatus: subcommands.ExitSuccess,
+			wantRun:    []bool{true, true, true},
+		},
+		"when a member fails": {
+			statuses:   []subcommands.ExitStatus{subcommands.ExitSuccess, subcommands.ExitUsageError, subcommands.ExitSuccess},
+			wantStatus: subcommands.ExitUsageError,
+			wantRun:    []bool{true, true, false},
+		},
+		"when a member fails and errors are ignored": {
+			statuses:   []subcommands.ExitStatus{subcommands.ExitFailure, subcommands.ExitUsageError, subcommands.ExitSuccess},
+			opts:       []subcommandsutil.SequenceOption{subcommandsutil.WithContinueOnError()},
+			wantStatus: subcommands.ExitFailure,
+			wantRun:    []bool{true, true, true},
+		},
+		"when errors are ignored with another aggregator": {
+			statuses:   []subcommands.ExitStatus{subcommands.ExitFailure, subcommands.ExitUsageError, subcommands.ExitSuccess},
+			opts:       []subcommandsutil.SequenceOption{subcommandsutil.WithContinueOnError(), subcommandsutil.WithAggregator(subcommandsutil.WorstStatus)},
+			wantStatus: subcommands.ExitUsageError,
+			wantRun:    []bool{true, true, true},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var members []subcommandsutil.CancelableCommand
+			var recs []*testcmd.Recording
+			for i, status := range tt.statuses {
+				rec := testcmd.NewRecording([]string{"build", "test", "push"}[i], testcmd.WithStatus(status))
+				recs = append(recs, rec)
+				members = append(members, rec)
+			}
+
+			status, _, err := testcmd.Run(context.Background(), subcommandsutil.Sequence("release", "release the project", members, tt.opts...))
+			if err != nil {
+				t.Fatal(err)
+			}
+			testcmd.AssertStatus(t, status, tt.wantStatus)
+			for i, rec := range recs {
+				if got := rec.DidFinish(); got != tt.wantRun[i] {
+					t.Fatalf("wanted %s to run %t but got %t", rec.Name(), tt.wantRun[i], got)
+				}
+			}
+		})
+	}
+}
+
+func TestSequenceCanceled(t *testing.T) {
+	defer testcmd.VerifyNoLeaks(t)
+
+	build := persistent{testcmd.NewRecording("build")}
+	lint := testcmd.NewRecording("lint")
+	test := testcmd.NewBlocking("test")
+	defer test.Release(subcommands.ExitSuccess)
+	push := testcmd.NewRecording("push")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-test.Started()
+		cancel()
+	}()
+
+	var stderr testcmd.Buffer
+	cmd := subcommandsutil.Sequence("release", "release the project", []subcommandsutil.CancelableCommand{build, lint, test, push},
+		subcommandsutil.WithLogger(&testcmd.LogRecorder{}))
+	status, _, err := testcmd.Run(subcommandsutil.WithOutput(ctx, nil, &stderr), cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testcmd.AssertStatus(t, status, subcommands.ExitFailure)
+
+	if push.DidFinish() {
+		t.Fatal("wanted the members after the cancellation skipped")
+	}
+	for _, tt := range []struct {
+		rec  interface{ DisposeCount() int }
+		name string
+		want int
+	}{
+		{build, "build", 1},
+		{lint, "lint", 0},
+		{test, "test", 1},
+		{push, "push", 0},
+	} {
+		if got := tt.rec.DisposeCount(); got != tt.want {
+			t.Fatalf("wanted %s disposed %d times but got %d", tt.name, tt.want, got)
+		}
+	}
+	if want := "release: context canceled\n"; stderr.String() != want {
+		t.Fatalf("wanted stderr %q but got %q", want, stderr.String())
+	}
+}
+
+func TestSequenceUsage(t *testing.T) {
+	cmd := subcommandsutil.Sequence("release", "release the project", []subcommandsutil.CancelableCommand{newPrinting("build"), newPrinting("push")})
+	want := "Runs in order:\n\tbuild            build the tree\n\tpush             push the tree\n"
+	if usage := cmd.Usage(); !strings.HasSuffix(usage, want) {
+		t.Fatalf("wanted the usage to end with %q but got %q", want, usage)
+	}
+}
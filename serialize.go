@@ -0,0 +1,147 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"github.com/google/subcommands"
+)
+
+// SerializeOption is an option of the Serialize wrapper.
+type SerializeOption interface {
+	applySerialize(*serialized)
+}
+
+// serializeOptionFunc is a SerializeOption implemented by a function.
+type serializeOptionFunc func(*serialized)
+
+// applySerialize implements SerializeOption.
+func (fn serializeOptionFunc) applySerialize(c *serialized) { fn(c) }
+
+// WithRejectOverlap makes Serialize reject an execution overlapping a running one immediately,
+// printing "NAME: MESSAGE" to Stderr and returning status, instead of waiting for it. An empty
+// message is "command already running".
+func WithRejectOverlap(status subcommands.ExitStatus, message string) SerializeOption {
+	return serializeOptionFunc(func(c *serialized) {
+		if message == "" {
+			message = "command already running"
+		}
+		c.reject = true
+		c.rejectStatus = status
+		c.rejectMessage = message
+	})
+}
+
+// serialized wraps a subcommands.Command so that its executions never overlap.
+type serialized struct {
+	sub    subcommands.Command
+	newSub func() subcommands.Command // returns the instance of each FlagSet, if set
+	sem    chan struct{}
+
+	reject        bool
+	rejectStatus  subcommands.ExitStatus
+	rejectMessage string
+}
+
+// make sure serialized implements the subcommands.Command interface.
+var _ subcommands.Command = (*serialized)(nil)
+
+// Serialize wraps sub, a command keeping the state of an execution in its fields, so that it can be
+// executed concurrently, such as by a Shell or a server: an execution waits for the running one to
+// return before forwarding to sub. While waiting, it fails with subcommands.ExitFailure when its
+// context is done. With WithRejectOverlap, the overlapping executions are rejected instead.
+//
+// Only Execute is serialized: the Commander calls SetFlags and parses the flags of an execution
+// before it waits, overwriting the flag fields of sub while another execution runs. A command
+// keeping its flags in its fields is wrapped with SerializeNew instead.
+func Serialize(sub subcommands.Command, opts ...SerializeOption) subcommands.Command {
+	c := &serialized{
+		sub: sub,
+		sem: make(chan struct{}, 1),
+	}
+	for _, opt := range opts {
+		opt.applySerialize(c)
+	}
+
+	return c
+}
+
+// SerializeNew is like Serialize, except that SetFlags sets the flags of a new instance returned by
+// newSub, which the execution of the FlagSet forwards to, so that an execution dispatched while
+// another runs leaves the flags of the running one alone. The instance returned by the first call
+// to newSub names the command and describes its usage.
+func SerializeNew(newSub func() subcommands.Command, opts ...SerializeOption) subcommands.Command {
+	c := Serialize(newSub(), opts...).(*serialized)
+	c.newSub = newSub
+
+	return c
+}
+
+// Name forwards to the underlying c.sub Command.
+func (c *serialized) Name() string {
+	return c.sub.Name()
+}
+
+// Usage forwards to the underlying c.sub Command.
+func (c *serialized) Usage() string {
+	return c.sub.Usage()
+}
+
+// Synopsis forwards to the underlying c.sub Command.
+func (c *serialized) Synopsis() string {
+	return c.sub.Synopsis()
+}
+
+// Unwrap returns the underlying c.sub Command.
+func (c *serialized) Unwrap() subcommands.Command {
+	return c.sub
+}
+
+// SetFlags forwards to the underlying c.sub Command, or to a new instance of SerializeNew executed
+// with f.
+func (c *serialized) SetFlags(f *flag.FlagSet) {
+	if c.newSub == nil {
+		c.sub.SetFlags(f)
+		return
+	}
+
+	sub := c.newSub()
+	sub.SetFlags(f)
+	updateFlagMeta(f, func(m *flagMeta) {
+		m.serialized = sub
+	})
+}
+
+// Execute waits for the running execution, if any, to return and forwards to the underlying c.sub
+// Command.
+func (c *serialized) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	if c.reject {
+		select {
+		case c.sem <- struct{}{}:
+		default:
+			fmt.Fprintf(Stderr(ctx), "%s: %s\n", c.sub.Name(), c.rejectMessage)
+			return c.rejectStatus
+		}
+	} else {
+		select {
+		case c.sem <- struct{}{}:
+		case <-ctx.Done():
+			fmt.Fprintf(Stderr(ctx), "%s: %v\n", c.sub.Name(), ctx.Err())
+			return subcommands.ExitFailure
+		}
+	}
+	defer func() { <-c.sem }()
+
+	sub := c.sub
+	readFlagMeta(f, func(m *flagMeta) {
+		if m != nil && m.serialized != nil {
+			sub = m.serialized
+		}
+	})
+
+	return sub.Execute(ctx, f, args...)
+}
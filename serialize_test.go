@@ -0,0 +1,224 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"context"
+	"flag"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+// overlapping returns a command blocking until release is closed, which records the maximum number
+// of its executions running at once into peak.
+func overlapping(started chan<- struct{}, release <-chan struct{}, peak *int32) *testcmd.Recording {
+	var running int32
+	return testcmd.NewRecording("build", testcmd.WithExecute(func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			m := atomic.LoadInt32(peak)
+			if n <= m || atomic.CompareAndSwapInt32(peak, m, n) {
+				break
+			}
+		}
+		started <- struct{}{}
+		<-release
+		return subcommands.ExitSuccess
+	}))
+}
+
+func TestSerialize(t *testing.T) {
+	defer testcmd.VerifyNoLeaks(t)
+
+	started := make(chan struct{}, 2)
+	release := make(chan struct{})
+	var peak int32
+	sub := overlapping(started, release, &peak)
+	cmd := subcommandsutil.Serialize(sub)
+
+	var wg sync.WaitGroup
+	statuses := make([]subcommands.ExitStatus, 2)
+	for i := range statuses {
+		i := i
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			statuses[i], _, _ = testcmd.Run(context.Background(), cmd)
+		}()
+	}
+
+	<-started
+	select {
+	case <-started:
+		t.Fatal("wanted the second execution to wait for the first")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	<-started
+	wg.Wait()
+
+	for _, status := range statuses {
+		testcmd.RequireSuccess(t, status)
+	}
+	if got := sub.CallCount(); got != 2 {
+		t.Fatalf("wanted 2 executions but got %d", got)
+	}
+	if peak != 1 {
+		t.Fatalf("wanted one execution at a time but got %d", peak)
+	}
+}
+
+func TestSerializeCanceledWhileWaiting(t *testing.T) {
+	defer testcmd.VerifyNoLeaks(t)
+
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	var peak int32
+	sub := overlapping(started, release, &peak)
+	cmd := subcommandsutil.Serialize(sub)
+
+	done := make(chan subcommands.ExitStatus)
+	go func() {
+		status, _, _ := testcmd.Run(context.Background(), cmd)
+		done <- status
+	}()
+	<-started
+
+	var stderr testcmd.Buffer
+	ctx, cancel := context.WithCancel(subcommandsutil.WithOutput(context.Background(), nil, &stderr))
+	cancel()
+	status, _, _ := testcmd.Run(ctx, cmd)
+	testcmd.AssertStatus(t, status, subcommands.ExitFailure)
+	if want := "build: context canceled\n"; stderr.String() != want {
+		t.Fatalf("wanted the output %q but got %q", want, stderr.String())
+	}
+
+	close(release)
+	testcmd.RequireSuccess(t, <-done)
+	if got := sub.CallCount(); got != 1 {
+		t.Fatalf("wanted 1 execution but got %d", got)
+	}
+}
+
+func TestSerializeRejectOverlap(t *testing.T) {
+	tests := map[string]struct {
+		message    string
+		wantStderr string
+	}{
+		"when the message is the default": {
+			wantStderr: "build: command already running\n",
+		},
+		"when the message is given": {
+			message:    "a build is in progress",
+			wantStderr: "build: a build is in progress\n",
+		},
+	}
+	for name, tt := range tests {
+		tt := tt
+		t.Run(name, func(t *testing.T) {
+			defer testcmd.VerifyNoLeaks(t)
+
+			started := make(chan struct{}, 1)
+			release := make(chan struct{})
+			var peak int32
+			sub := overlapping(started, release, &peak)
+			cmd := subcommandsutil.Serialize(sub, subcommandsutil.WithRejectOverlap(subcommands.ExitUsageError, tt.message))
+
+			done := make(chan subcommands.ExitStatus)
+			go func() {
+				status, _, _ := testcmd.Run(context.Background(), cmd)
+				done <- status
+			}()
+			<-started
+
+			var stderr testcmd.Buffer
+			status, _, _ := testcmd.Run(subcommandsutil.WithOutput(context.Background(), nil, &stderr), cmd)
+			testcmd.AssertStatus(t, status, subcommands.ExitUsageError)
+			if got := stderr.String(); got != tt.wantStderr {
+				t.Fatalf("wanted the output %q but got %q", tt.wantStderr, got)
+			}
+
+			close(release)
+			testcmd.RequireSuccess(t, <-done)
+			if got := sub.CallCount(); got != 1 {
+				t.Fatalf("wanted 1 execution but got %d", got)
+			}
+		})
+	}
+}
+
+// greetCommand keeps its -name flag in a field, reporting it when it starts and finishes.
+type greetCommand struct {
+	name     string
+	started  chan<- string
+	release  <-chan struct{}
+	finished chan<- string
+}
+
+func (*greetCommand) Name() string     { return "greet" }
+func (*greetCommand) Synopsis() string { return "greet someone" }
+func (*greetCommand) Usage() string    { return "greet [-name NAME]\n" }
+
+func (c *greetCommand) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&c.name, "name", "", "the name to greet")
+}
+
+func (c *greetCommand) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	c.started <- c.name
+	<-c.release
+	c.finished <- c.name
+	return subcommands.ExitSuccess
+}
+
+func TestSerializeNew(t *testing.T) {
+	defer testcmd.VerifyNoLeaks(t)
+
+	started, finished := make(chan string, 2), make(chan string, 2)
+	release := make(chan struct{})
+	cmd := subcommandsutil.SerializeNew(func() subcommands.Command {
+		return &greetCommand{started: started, release: release, finished: finished}
+	})
+
+	var wg sync.WaitGroup
+	statuses := make(chan subcommands.ExitStatus, 2)
+	run := func(name string) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			status, _, _ := testcmd.Run(context.Background(), cmd, "-name", name)
+			statuses <- status
+		}()
+	}
+	run("a")
+	if got := <-started; got != "a" {
+		t.Fatalf("wanted the first execution started with -name=a but got %q", got)
+	}
+	// the second execution parses its flags while the first runs
+	run("b")
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(statuses)
+
+	for status := range statuses {
+		testcmd.RequireSuccess(t, status)
+	}
+	if got := <-finished; got != "a" {
+		t.Fatalf("wanted the first execution finished with -name=a but got %q", got)
+	}
+	if got := <-started; got != "b" {
+		t.Fatalf("wanted the second execution started with -name=b but got %q", got)
+	}
+	if cmd.Name() != "greet" {
+		t.Fatalf("wanted the name greet but got %q", cmd.Name())
+	}
+}
@@ -0,0 +1,56 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"context"
+	"flag"
+	"sync"
+
+	"github.com/google/subcommands"
+)
+
+// SetupTeardown is implemented by the commands which acquire resources before they execute, like a
+// connection the Dispose method later releases.
+//
+// Cancelable calls Setup once the flags are parsed, and does not execute the command if it fails:
+// the error is printed to the Stderr of the execution context, prefixed by the command name, and
+// mapped to the ExitStatus by StatusFromError. Once Setup succeeded, Teardown is called whatever
+// the outcome of the execution: after it returns or panics, or, when it is canceled, before the
+// command is disposed.
+type SetupTeardown interface {
+	Setup(ctx context.Context, f *flag.FlagSet) error
+	Teardown(ctx context.Context) error
+}
+
+// commandSetup returns the SetupTeardown that cmd is or wraps.
+func commandSetup(cmd subcommands.Command) (st SetupTeardown, ok bool) {
+	walkCommand(cmd, func(cmd subcommands.Command) bool {
+		st, ok = cmd.(SetupTeardown)
+		return ok
+	})
+
+	return st, ok
+}
+
+// setup calls the Setup method of the SetupTeardown c.sub is or wraps, if any. The returned
+// teardown function calls its Teardown method the first time it is called, on a context which is
+// not canceled, and does nothing afterward.
+func (c *cancelable) setup(ctx context.Context, f *flag.FlagSet) (teardown func() error, err error) {
+	st, ok := commandSetup(c.sub)
+	if !ok {
+		return func() error { return nil }, nil
+	}
+	if err := st.Setup(ctx, f); err != nil {
+		return nil, err
+	}
+
+	var once sync.Once
+	return func() (err error) {
+		once.Do(func() {
+			err = st.Teardown(context.WithoutCancel(ctx))
+		})
+		return err
+	}, nil
+}
@@ -0,0 +1,131 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+// settingUp is a CancelableCommand implementing subcommandsutil.SetupTeardown, recording its
+// setup, teardown and dispose as events.
+type settingUp struct {
+	subcommandsutil.CancelableCommand
+	ev          *events
+	setupErr    error
+	teardownErr error
+}
+
+// Setup implements subcommandsutil.SetupTeardown.
+func (c *settingUp) Setup(ctx context.Context, f *flag.FlagSet) error {
+	c.ev.add(fmt.Sprintf("setup -cache=%s", f.Lookup("cache").Value))
+	return c.setupErr
+}
+
+// Teardown implements subcommandsutil.SetupTeardown.
+func (c *settingUp) Teardown(ctx context.Context) error {
+	c.ev.add(fmt.Sprintf("teardown (%v)", ctx.Err()))
+	return c.teardownErr
+}
+
+// Dispose implements subcommandsutil.CancelableCommand.
+func (c *settingUp) Dispose() error {
+	c.ev.add("dispose")
+	return c.CancelableCommand.Dispose()
+}
+
+func TestCancelableSetupTeardown(t *testing.T) {
+	tests := map[string]struct {
+		status      subcommands.ExitStatus
+		setupErr    error
+		teardownErr error
+		wantStatus  subcommands.ExitStatus
+		wantEvents  []string
+		wantStderr  string
+	}{
+		"when the execution succeeds": {
+			status:     subcommands.ExitSuccess,
+			wantStatus: subcommands.ExitSuccess,
+			wantEvents: []string{"setup -cache=warm", "execute", "teardown (<nil>)"},
+		},
+		"when the execution fails": {
+			status:     subcommands.ExitFailure,
+			wantStatus: subcommands.ExitFailure,
+			wantEvents: []string{"setup -cache=warm", "execute", "teardown (<nil>)"},
+		},
+		"when the setup fails": {
+			setupErr:   subcommandsutil.UsageErrorf("no database at -db"),
+			wantStatus: subcommands.ExitUsageError,
+			wantEvents: []string{"setup -cache=warm"},
+			wantStderr: "migrate: no database at -db\n",
+		},
+		"when the teardown fails": {
+			status:      subcommands.ExitSuccess,
+			teardownErr: errors.New("connection reset"),
+			wantStatus:  subcommands.ExitFailure,
+			wantEvents:  []string{"setup -cache=warm", "execute", "teardown (<nil>)"},
+			wantStderr:  "migrate: teardown: connection reset\n",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var ev events
+			sub := &settingUp{
+				CancelableCommand: testcmd.NewRecording("migrate",
+					testcmd.WithFlags(func(f *flag.FlagSet) { f.String("cache", "cold", "cache mode") }),
+					testcmd.WithExecute(func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+						ev.add("execute")
+						return tt.status
+					}),
+				),
+				ev:          &ev,
+				setupErr:    tt.setupErr,
+				teardownErr: tt.teardownErr,
+			}
+
+			var stderr testcmd.Buffer
+			ctx := subcommandsutil.WithOutput(context.Background(), &testcmd.Buffer{}, &stderr)
+			status, _, _ := testcmd.Run(ctx, subcommandsutil.Cancelable(sub), "-cache", "warm")
+			testcmd.AssertStatus(t, status, tt.wantStatus)
+			if got := ev.get(); !reflect.DeepEqual(got, tt.wantEvents) {
+				t.Fatalf("wanted the events %q but got %q", tt.wantEvents, got)
+			}
+			if got := stderr.String(); got != tt.wantStderr {
+				t.Fatalf("wanted the stderr %q but got %q", tt.wantStderr, got)
+			}
+		})
+	}
+}
+
+func TestCancelableTeardownOnCancel(t *testing.T) {
+	defer testcmd.VerifyNoLeaks(t)
+
+	var ev events
+	tcmd := testcmd.NewBlocking("migrate", testcmd.WithFlags(func(f *flag.FlagSet) { f.String("cache", "cold", "cache mode") }))
+	defer tcmd.Release(subcommands.ExitSuccess)
+	sub := &settingUp{CancelableCommand: tcmd, ev: &ev}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-tcmd.Started()
+		ev.add("cancel")
+		cancel()
+	}()
+	status, _, _ := testcmd.Run(ctx, subcommandsutil.Cancelable(sub, subcommandsutil.WithLogger(&testcmd.LogRecorder{})))
+	testcmd.AssertStatus(t, status, subcommands.ExitFailure)
+
+	want := []string{"setup -cache=cold", "cancel", "teardown (<nil>)", "dispose"}
+	if got := ev.get(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("wanted the events %q but got %q", want, got)
+	}
+}
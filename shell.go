@@ -0,0 +1,320 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/google/subcommands"
+)
+
+// ShellOption is an option of ShellCommand.
+type ShellOption interface {
+	applyShell(*shell)
+}
+
+// shellOptionFunc is a ShellOption implemented by a function.
+type shellOptionFunc func(*shell)
+
+// applyShell implements ShellOption.
+func (fn shellOptionFunc) applyShell(c *shell) { fn(c) }
+
+// WithPrompt sets the prompt printed to Stdout before reading each line. The default is "> ".
+func WithPrompt(prompt string) ShellOption {
+	return shellOptionFunc(func(c *shell) {
+		c.prompt = prompt
+	})
+}
+
+// WithShellInput makes ShellCommand read the lines from r instead of os.Stdin.
+func WithShellInput(r io.Reader) ShellOption {
+	return shellOptionFunc(func(c *shell) {
+		c.in = r
+	})
+}
+
+// WithHistory makes ShellCommand record the executed lines in h instead of its default History.
+func WithHistory(h *History) ShellOption {
+	return shellOptionFunc(func(c *shell) {
+		c.history = h
+	})
+}
+
+// WithoutHistoryFile makes ShellCommand keep its history in memory only, instead of persisting it
+// to the StateDir of the application.
+func WithoutHistoryFile() ShellOption {
+	return shellOptionFunc(func(c *shell) {
+		c.history = NewHistory("", defaultHistorySize)
+	})
+}
+
+// defaultHistorySize is the number of entries of the default History of ShellCommand.
+const defaultHistorySize = 1000
+
+// shell is a subcommands.Command dispatching the lines it reads to the commands of a Commander.
+type shell struct {
+	cdr    *subcommands.Commander
+	prompt string
+	in     io.Reader
+
+	mu      sync.Mutex
+	history *History
+}
+
+// make sure shell implements the CancelableCommand interface.
+var _ CancelableCommand = (*shell)(nil)
+
+// ShellCommand returns a "shell" command reading lines, and dispatching each to the command of cdr
+// it names, like the arguments of an invocation of the program:
+//
+//	> build -o "my app" ./cmd/app
+//	exit status 0
+//
+// The lines are split into words like a shell does: single quotes preserve their content, double
+// quotes preserve it except for escaping, and a backslash escapes the next character, like a space.
+// Each command is executed with a fresh flag.FlagSet, whose usage is explained by the ExplainCommand
+// of cdr, and the shell's arguments; its status is printed after it returns. The top-level flags of
+// cdr are not parsed again. When the shell is executed by a CancelableCommander of cdr, the hooks
+// added by its AddDispatchHooks run around the dispatch of each line too.
+//
+// The executed lines are recorded in a History of 1000 entries, with the values of the sensitive
+// flags Redacted. It is loaded from the "shell_history" file of the StateDir of cdr when the shell
+// starts, and saved to it when the shell returns or is disposed; a corrupt file is truncated with a
+// warning on Stderr. WithHistory and WithoutHistoryFile change it. The lines are read as is: there
+// is no line editing, nor recall of the History, within a session.
+//
+// The shell returns subcommands.ExitSuccess at the end of the input or on an "exit" line, and
+// subcommands.ExitFailure when its execution context is canceled, while reading a line or after the
+// command it is executing returns.
+func ShellCommand(cdr *subcommands.Commander, opts ...ShellOption) CancelableCommand {
+	c := &shell{
+		cdr:    cdr,
+		prompt: "> ",
+		in:     os.Stdin,
+	}
+	for _, opt := range opts {
+		opt.applyShell(c)
+	}
+
+	return c
+}
+
+// Name implements subcommands.Command.
+func (c *shell) Name() string {
+	return "shell"
+}
+
+// Usage implements subcommands.Command.
+func (c *shell) Usage() string {
+	return "shell:\n\tRun commands read line by line, until the end of the input or \"exit\".\n"
+}
+
+// Synopsis implements subcommands.Command.
+func (c *shell) Synopsis() string {
+	return "run commands interactively"
+}
+
+// SetFlags implements subcommands.Command.
+func (c *shell) SetFlags(f *flag.FlagSet) {}
+
+// Dispose saves the History.
+func (c *shell) Dispose() error {
+	c.mu.Lock()
+	h := c.history
+	c.mu.Unlock()
+	if h == nil {
+		return nil
+	}
+
+	return h.Save()
+}
+
+// loadHistory returns the History of c, loaded from its file, creating the default one on the first
+// execution.
+func (c *shell) loadHistory(ctx context.Context) *History {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.history == nil {
+		dir, err := StateDir(c.cdr.Name())
+		if err != nil {
+			fmt.Fprintf(Stderr(ctx), "warning: shell history: %v\n", err)
+			c.history = NewHistory("", defaultHistorySize)
+		} else {
+			c.history = NewHistory(filepath.Join(dir, "shell_history"), defaultHistorySize)
+		}
+	}
+	if err := c.history.Load(); err != nil {
+		fmt.Fprintf(Stderr(ctx), "warning: shell history: %v\n", err)
+	}
+
+	return c.history
+}
+
+// Execute reads the lines of the input and dispatches them.
+func (c *shell) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	stdout, stderr := Stdout(ctx), Stderr(ctx)
+
+	history := c.loadHistory(ctx)
+	defer func() {
+		if err := history.Save(); err != nil {
+			fmt.Fprintf(stderr, "warning: shell history: %v\n", err)
+		}
+	}()
+
+	done := make(chan struct{})
+	defer close(done)
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+
+		sc := bufio.NewScanner(c.in)
+		for sc.Scan() {
+			select {
+			case lines <- sc.Text():
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	for {
+		fmt.Fprint(stdout, c.prompt)
+
+		var line string
+		var ok bool
+		select {
+		case <-ctx.Done():
+			fmt.Fprintln(stdout)
+			return subcommands.ExitFailure
+		case line, ok = <-lines:
+		}
+		if !ok {
+			fmt.Fprintln(stdout)
+			return subcommands.ExitSuccess
+		}
+
+		argv, err := splitShellWords(line)
+		switch {
+		case err != nil:
+			fmt.Fprintf(stderr, "%s: %v\n", c.Name(), err)
+			continue
+		case len(argv) == 0:
+			continue
+		case len(argv) == 1 && argv[0] == "exit":
+			return subcommands.ExitSuccess
+		}
+
+		status := c.dispatch(ctx, history, line, argv, args...)
+		fmt.Fprintf(stdout, "exit status %d\n", status)
+		if ctx.Err() != nil {
+			return subcommands.ExitFailure
+		}
+	}
+}
+
+// dispatch executes the command of cdr named by argv[0] with the flags and arguments of argv[1:],
+// the words of line, and records line in history once the flags are parsed. When the shell is
+// executed by a CancelableCommander of cdr, the dispatch runs between its hooks.
+func (c *shell) dispatch(ctx context.Context, history *History, line string, argv []string, args ...interface{}) subcommands.ExitStatus {
+	execute := func(ctx context.Context) subcommands.ExitStatus {
+		return c.execute(ctx, history, line, argv, args...)
+	}
+	if cdr, ok := dispatchCommander(ctx, c.cdr); ok {
+		return cdr.dispatch(ctx, argv[0], argv[1:], execute)
+	}
+
+	return execute(ctx)
+}
+
+// execute is the dispatch of argv without the hooks.
+func (c *shell) execute(ctx context.Context, history *History, line string, argv []string, args ...interface{}) subcommands.ExitStatus {
+	name := argv[0]
+	var cmd subcommands.Command
+	c.cdr.VisitCommands(func(_ *subcommands.CommandGroup, cc subcommands.Command) {
+		if cmd == nil && cc.Name() == name {
+			cmd = cc
+		}
+	})
+	if cmd == nil {
+		fmt.Fprintln(Stderr(ctx), unknownCommand(MessagesFromContext(ctx), name, CommandSuggestions(c.cdr, name, false)))
+		return subcommands.ExitUsageError
+	}
+
+	f := flag.NewFlagSet(name, flag.ContinueOnError)
+	f.SetOutput(Stderr(ctx))
+	f.Usage = func() { c.cdr.ExplainCommand(f.Output(), cmd) }
+	cmd.SetFlags(f)
+	if err := f.Parse(argv[1:]); err != nil {
+		return subcommands.ExitUsageError
+	}
+	if redacted := historyLine(f, argv); redacted != "" {
+		line = redacted
+	}
+	history.Add(line)
+
+	return cmd.Execute(WithFlagSet(ctx, f), f, args...)
+}
+
+// splitShellWords splits line into words separated by unquoted spaces and tabs, removing the quotes
+// and escaping backslashes.
+func splitShellWords(line string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord := false
+	for i := 0; i < len(line); i++ {
+		switch ch := line[i]; {
+		case ch == ' ' || ch == '\t':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		case ch == '\\':
+			if i+1 >= len(line) {
+				return nil, errors.New("trailing backslash")
+			}
+			i++
+			word.WriteByte(line[i])
+			inWord = true
+		case ch == '\'':
+			end := strings.IndexByte(line[i+1:], '\'')
+			if end < 0 {
+				return nil, errors.New("unterminated single quote")
+			}
+			word.WriteString(line[i+1 : i+1+end])
+			i += end + 1
+			inWord = true
+		case ch == '"':
+			i++
+			for ; i < len(line) && line[i] != '"'; i++ {
+				if line[i] == '\\' && i+1 < len(line) && strings.IndexByte(`"\$`+"`", line[i+1]) >= 0 {
+					i++
+				}
+				word.WriteByte(line[i])
+			}
+			if i >= len(line) {
+				return nil, errors.New("unterminated double quote")
+			}
+			inWord = true
+		default:
+			word.WriteByte(ch)
+			inWord = true
+		}
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+
+	return words, nil
+}
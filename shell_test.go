@@ -0,0 +1,178 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+func TestShellCommand(t *testing.T) {
+	var ev events
+	cdr := subcommands.NewCommander(flag.NewFlagSet("tool", flag.ContinueOnError), "tool")
+	cdr.Register(testcmd.NewRecording("build",
+		testcmd.WithFlags(func(f *flag.FlagSet) { f.String("o", "", "output file") }),
+		testcmd.WithExecute(func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+			ev.add(fmt.Sprintf("build -o=%q %q", f.Lookup("o").Value, f.Args()))
+			return subcommands.ExitSuccess
+		}),
+	), "")
+	cdr.Register(testcmd.NewRecording("test", testcmd.WithStatus(subcommands.ExitFailure)), "")
+
+	script := strings.Join([]string{
+		`build -o "my app" ./cmd/app`,
+		`build 'a "b"' c\ d`,
+		``,
+		`test -v`,
+		`test`,
+		`buidl`,
+		`build "unterminated`,
+		`exit`,
+		`build never`,
+	}, "\n")
+	var stdout, stderr testcmd.Buffer
+	ctx := subcommandsutil.WithOutput(context.Background(), &stdout, &stderr)
+	cmd := subcommandsutil.ShellCommand(cdr, subcommandsutil.WithShellInput(strings.NewReader(script)), subcommandsutil.WithPrompt("tool> "), subcommandsutil.WithoutHistoryFile())
+
+	status, _, _ := testcmd.Run(ctx, cmd)
+	testcmd.RequireSuccess(t, status)
+	want := []string{
+		`build -o="my app" ["./cmd/app"]`,
+		`build -o="" ["a \"b\"" "c d"]`,
+	}
+	if got := ev.get(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("wanted the dispatches %q but got %q", want, got)
+	}
+	wantStdout := "tool> exit status 0\ntool> exit status 0\ntool> tool> exit status 2\ntool> exit status 1\ntool> exit status 2\ntool> tool> "
+	if got := stdout.String(); got != wantStdout {
+		t.Fatalf("wanted the stdout %q but got %q", wantStdout, got)
+	}
+	for _, want := range []string{"flag provided but not defined: -v", `unknown command "buidl"; did you mean "build"?`, "shell: unterminated double quote"} {
+		if !strings.Contains(stderr.String(), want) {
+			t.Fatalf("wanted the stderr to contain %q but got %q", want, stderr.String())
+		}
+	}
+}
+
+func TestShellCommandDispatchHooks(t *testing.T) {
+	var events []string
+	top := flag.NewFlagSet("tool", flag.ContinueOnError)
+	cdr := subcommandsutil.NewCancelableCommander(top, "tool")
+	var stderr testcmd.Buffer
+	cdr.SetOutput(&testcmd.Buffer{}, &stderr)
+	cdr.ExplainCommand = func(w io.Writer, cmd subcommands.Command) { fmt.Fprintf(w, "explained %s\n", cmd.Name()) }
+	cdr.AddDispatchHooks(dispatchRecorder{name: "outer", events: &events}, dispatchRecorder{name: "inner", events: &events})
+	cdr.Register(testcmd.NewRecording("build",
+		testcmd.WithFlags(func(f *flag.FlagSet) { f.Bool("v", false, "verbose") }),
+		testcmd.WithExecute(func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+			events = append(events, "execute build")
+			return subcommands.ExitFailure
+		}),
+	), "")
+	cdr.Register(subcommandsutil.ShellCommand(cdr.Commander, subcommandsutil.WithShellInput(strings.NewReader("build -v\nbuild -h")), subcommandsutil.WithoutHistoryFile()), "")
+
+	if err := top.Parse([]string{"shell"}); err != nil {
+		t.Fatal(err)
+	}
+	testcmd.RequireSuccess(t, cdr.Execute(context.Background()))
+	want := []string{
+		`outer.before shell []`,
+		`inner.before shell []`,
+		`outer.before build ["-v"]`,
+		`inner.before build ["-v"]`,
+		`execute build`,
+		`inner.after build 1 (inner)`,
+		`outer.after build 1 (inner)`,
+		`outer.before build ["-h"]`,
+		`inner.before build ["-h"]`,
+		`inner.after build 2 (inner)`,
+		`outer.after build 2 (inner)`,
+		`inner.after shell 0 (inner)`,
+		`outer.after shell 0 (inner)`,
+	}
+	if !reflect.DeepEqual(events, want) {
+		t.Fatalf("wanted the events %q but got %q", want, events)
+	}
+	if got, want := stderr.String(), "explained build\n"; got != want {
+		t.Fatalf("wanted the usage explained by the Commander %q but got %q", want, got)
+	}
+}
+
+func TestShellCommandCanceled(t *testing.T) {
+	defer testcmd.VerifyNoLeaks(t)
+
+	cdr := subcommands.NewCommander(flag.NewFlagSet("tool", flag.ContinueOnError), "tool")
+	r, w := io.Pipe()
+	defer w.Close()
+	ctx, cancel := context.WithCancel(subcommandsutil.WithOutput(context.Background(), &testcmd.Buffer{}, &testcmd.Buffer{}))
+	cancel()
+
+	status, _, _ := testcmd.Run(ctx, subcommandsutil.ShellCommand(cdr, subcommandsutil.WithShellInput(r), subcommandsutil.WithoutHistoryFile()))
+	testcmd.AssertStatus(t, status, subcommands.ExitFailure)
+}
+
+func TestSplitShellWords(t *testing.T) {
+	tests := map[string]struct {
+		line    string
+		want    []string
+		wantErr string
+	}{
+		"when the words are separated by spaces and tabs": {
+			line: " build\t-o  out ",
+			want: []string{"build", "-o", "out"},
+		},
+		"when a word is single-quoted": {
+			line: `echo 'a "b" \c'`,
+			want: []string{"echo", `a "b" \c`},
+		},
+		"when a word is double-quoted": {
+			line: `echo "a 'b' \"c\" \d"`,
+			want: []string{"echo", `a 'b' "c" \d`},
+		},
+		"when a space is escaped": {
+			line: `cat my\ file`,
+			want: []string{"cat", "my file"},
+		},
+		"when quoted parts are adjacent": {
+			line: `-o=a'b c'"d"`,
+			want: []string{"-o=ab cd"},
+		},
+		"when an empty word is quoted": {
+			line: `echo ''`,
+			want: []string{"echo", ""},
+		},
+		"when a single quote is unterminated": {
+			line:    `echo 'a`,
+			wantErr: "unterminated single quote",
+		},
+		"when a line ends with a backslash": {
+			line:    `echo a\`,
+			wantErr: "trailing backslash",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := subcommandsutil.SplitShellWords(tt.line)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("wanted the error %q but got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil || !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("wanted the words %q but got %q (%v)", tt.want, got, err)
+			}
+		})
+	}
+}
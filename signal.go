@@ -0,0 +1,272 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/google/subcommands"
+)
+
+// SignalOption is an option of the CancelOnSignal wrapper.
+type SignalOption interface {
+	applySignal(*signalCancel)
+}
+
+// signalOptionFunc is a SignalOption implemented by a function.
+type signalOptionFunc func(*signalCancel)
+
+// applySignal implements SignalOption.
+func (fn signalOptionFunc) applySignal(c *signalCancel) { fn(c) }
+
+// applySignal implements SignalOption.
+func (o LoggerOption) applySignal(c *signalCancel) {
+	c.logger = o.logger
+}
+
+// WithLameDuck makes CancelOnSignal wait d between the termination signal and the cancellation of
+// the execution context, like while a load balancer still routes traffic to a server. hook, unless
+// nil, is called with the execution context when the signal is received, like to fail a readiness
+// check. A second signal cuts the wait short.
+func WithLameDuck(d time.Duration, hook func(ctx context.Context)) SignalOption {
+	return signalOptionFunc(func(c *signalCancel) {
+		c.lameDuck = d
+		c.onLameDuck = hook
+	})
+}
+
+// WithStatusSignals sets the signals making CancelOnSignal print the status of the execution to
+// Stderr instead of canceling it: SIGINFO, sent by Ctrl-T, on macOS and the BSDs, and SIGUSR1 on
+// the other unix platforms by default. No signal disables the status.
+func WithStatusSignals(sigs ...os.Signal) SignalOption {
+	return signalOptionFunc(func(c *signalCancel) {
+		c.statusSignals = sigs
+	})
+}
+
+// signalCancel wraps a subcommands.Command so that its execution context is canceled by the
+// termination signals.
+type signalCancel struct {
+	sub    subcommands.Command
+	logger Logger
+
+	lameDuck      time.Duration
+	onLameDuck    func(ctx context.Context)
+	statusSignals []os.Signal
+}
+
+// make sure signalCancel implements the CancelableCommand interface.
+var _ CancelableCommand = (*signalCancel)(nil)
+
+// CancelOnSignal wraps sub so that its execution context is canceled when the process receives an
+// interrupt or SIGTERM, with a context.Cause naming the signal. The signal is logged to the standard
+// logger unless WithLogger is given. The signals are the ones of the SignalSource of the execution
+// context. Canceling the context does not stop sub: the execution only returns once sub observes
+// it, unless the result is wrapped in Cancelable.
+//
+// With WithLameDuck, the cancellation is delayed, measured on the Clock of the execution context.
+// Dispose forwards to the Dispose method of sub, if any. Another termination signal received once
+// the execution context is canceled, while sub has yet to return, forces the exit: Dispose is
+// called and the program exits with subcommands.ExitFailure, like Exit, calling the functions of
+// OnExitStatus.
+//
+// The status signals of WithStatusSignals print a line to Stderr instead, as many times as they
+// are received, with the last Progress reported by ReportProgress and the elapsed time, like:
+//
+//	sync: copying (3/10), running for 3m12s
+func CancelOnSignal(sub subcommands.Command, opts ...SignalOption) CancelableCommand {
+	c := &signalCancel{
+		sub:           sub,
+		logger:        stdLogger{},
+		statusSignals: defaultStatusSignals,
+	}
+	for _, opt := range opts {
+		opt.applySignal(c)
+	}
+
+	return c
+}
+
+// Name forwards to the underlying c.sub Command.
+func (c *signalCancel) Name() string {
+	return c.sub.Name()
+}
+
+// Usage forwards to the underlying c.sub Command.
+func (c *signalCancel) Usage() string {
+	return c.sub.Usage()
+}
+
+// Synopsis forwards to the underlying c.sub Command.
+func (c *signalCancel) Synopsis() string {
+	return c.sub.Synopsis()
+}
+
+// Unwrap returns the underlying c.sub Command.
+func (c *signalCancel) Unwrap() subcommands.Command {
+	return c.sub
+}
+
+// SetFlags forwards to the underlying c.sub Command.
+func (c *signalCancel) SetFlags(f *flag.FlagSet) {
+	c.sub.SetFlags(f)
+}
+
+// Dispose forwards to the underlying c.sub Command if it is a CancelableCommand.
+func (c *signalCancel) Dispose() error {
+	if sub, ok := c.sub.(CancelableCommand); ok {
+		return sub.Dispose()
+	}
+
+	return nil
+}
+
+// Execute forwards to the underlying c.sub Command with a context canceled by the termination
+// signals.
+func (c *signalCancel) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	// canceled by the signals, with a cause naming the one received
+	sigCtx, cancelSignal := context.WithCancelCause(context.Background())
+	defer cancelSignal(context.Canceled)
+	ctx, cancel := MergeContexts(ctx, sigCtx)
+	defer cancel()
+	ctx, stopping := withStopping(ctx)
+
+	status := &executionStatus{name: c.sub.Name(), clk: ClockFromContext(ctx)}
+	status.start = status.clk.Now()
+	if len(c.statusSignals) > 0 {
+		ctx = withProgressSink(ctx, status.report)
+	}
+
+	// buffered so that a second signal is not dropped during the lame-duck hook
+	sigc := make(chan os.Signal, 2)
+	src := SignalSourceFromContext(ctx)
+	src.Notify(sigc, append([]os.Signal{os.Interrupt, syscall.SIGTERM}, c.statusSignals...)...)
+	defer src.Stop(sigc)
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	done := make(chan struct{})
+	defer close(done)
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		c.watch(ctx, sigc, done, stopping, cancelSignal, status)
+	}()
+
+	return c.sub.Execute(ctx, f, args...)
+}
+
+// watch calls cancel once a signal is received on sigc, after the lame-duck delay, until done is
+// closed. The execution is reported stopping to stopping on the signal. A termination signal
+// received after the cancellation disposes of sub and exits the program like Exit. The status
+// signals print status instead, until done is closed.
+func (c *signalCancel) watch(ctx context.Context, sigc <-chan os.Signal, done <-chan struct{}, stopping *stoppingHooks, cancel context.CancelCauseFunc, status *executionStatus) {
+	dump := func() {
+		fmt.Fprintln(Stderr(ctx), status)
+	}
+	sig, ok := c.nextSignal(sigc, done, nil, dump)
+	if !ok {
+		return
+	}
+	logger := contextLogger(ctx, c.logger)
+	stopping.stop()
+
+	if c.onLameDuck != nil {
+		c.onLameDuck(ctx)
+	}
+	if c.lameDuck > 0 {
+		logger.Printf("%s: received %v, canceling in %v", c.sub.Name(), sig, c.lameDuck)
+		timer := ClockFromContext(ctx).NewTimer(c.lameDuck)
+		defer timer.Stop()
+
+		second, ok := c.nextSignal(sigc, done, timer.C(), dump)
+		if !ok {
+			return
+		}
+		if second != nil {
+			sig = second
+		}
+	}
+
+	logger.Printf("%s: received %v, canceling", c.sub.Name(), sig)
+	cancel(fmt.Errorf("%w: received %v", context.Canceled, sig))
+
+	// keep printing the status while the execution finishes after the cancellation
+	sig, ok = c.nextSignal(sigc, done, nil, dump)
+	if !ok {
+		return
+	}
+	logger.Printf("%s: received %v after the cancellation, exiting", c.sub.Name(), sig)
+	if err := c.Dispose(); err != nil {
+		logger.Printf("%s: dispose: %v", c.sub.Name(), err)
+	}
+	exit(int(runExitHooks(ctx, subcommands.ExitFailure)))
+}
+
+// nextSignal returns the next termination signal received on sigc, calling dump for each status
+// signal received meanwhile, or nil once timeout fires. It reports false once done is closed.
+func (c *signalCancel) nextSignal(sigc <-chan os.Signal, done <-chan struct{}, timeout <-chan time.Time, dump func()) (os.Signal, bool) {
+	for {
+		select {
+		case <-done:
+			return nil, false
+		case <-timeout:
+			return nil, true
+		case sig := <-sigc:
+			if !slices.Contains(c.statusSignals, sig) {
+				return sig, true
+			}
+			dump()
+		}
+	}
+}
+
+// executionStatus is the status of an execution of CancelOnSignal, printed on the status signals.
+type executionStatus struct {
+	name  string
+	clk   Clock
+	start time.Time
+
+	mu       sync.Mutex
+	progress *Progress // the last one reported, if any
+}
+
+// report records p, reported by ReportProgress.
+func (s *executionStatus) report(p Progress) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.progress = &p
+}
+
+// String renders the status line, like "sync: copying (3/10), running for 3m12s".
+func (s *executionStatus) String() string {
+	elapsed := s.clk.Now().Sub(s.start).Round(time.Second)
+
+	s.mu.Lock()
+	p := s.progress
+	s.mu.Unlock()
+	if p == nil {
+		return fmt.Sprintf("%s: running for %v", s.name, elapsed)
+	}
+
+	done := strconv.FormatInt(p.Current, 10)
+	if p.Total > 0 {
+		done += "/" + strconv.FormatInt(p.Total, 10)
+	}
+	if p.Message != "" {
+		done = p.Message + " (" + done + ")"
+	}
+
+	return fmt.Sprintf("%s: %s, running for %v", s.name, done, elapsed)
+}
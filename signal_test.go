@@ -0,0 +1,206 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+func TestCancelOnSignal(t *testing.T) {
+	tests := map[string]struct {
+		lameDuck     time.Duration
+		secondSignal bool // whether a second signal is sent during the lame-duck delay
+		wantEvents   []string
+		wantLog      []string
+	}{
+		"when there is no lame-duck delay": {
+			wantEvents: []string{"signal", "lame duck", "canceled (context canceled: received terminated)"},
+			wantLog:    []string{"serve: received terminated, canceling"},
+		},
+		"when the lame-duck delay elapses": {
+			lameDuck:   5 * time.Second,
+			wantEvents: []string{"signal", "lame duck", "waiting (<nil>)", "canceled (context canceled: received terminated)"},
+			wantLog:    []string{"serve: received terminated, canceling in 5s", "serve: received terminated, canceling"},
+		},
+		"when a second signal is received during the lame-duck delay": {
+			lameDuck:     5 * time.Second,
+			secondSignal: true,
+			wantEvents:   []string{"signal", "lame duck", "waiting (<nil>)", "canceled (context canceled: received interrupt)"},
+			wantLog:      []string{"serve: received terminated, canceling in 5s", "serve: received interrupt, canceling"},
+		},
+	}
+	for name, tt := range tests {
+		tt := tt
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			src := testcmd.NewSignalSource()
+			clk := testcmd.NewFakeClock(time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC))
+
+			var ev events
+			hooked := make(chan struct{})
+			hook := func(ctx context.Context) {
+				ev.add("lame duck")
+				close(hooked)
+			}
+			sub := testcmd.NewRecording("serve", testcmd.WithExecute(func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+				ev.add("signal")
+				src.Send(syscall.SIGTERM)
+				<-hooked
+				if tt.lameDuck > 0 {
+					clk.BlockUntil(1)
+					ev.add(fmt.Sprintf("waiting (%v)", ctx.Err()))
+					if tt.secondSignal {
+						src.Send(os.Interrupt)
+					} else {
+						clk.Advance(tt.lameDuck)
+					}
+				}
+				<-ctx.Done()
+				ev.add(fmt.Sprintf("canceled (%v)", context.Cause(ctx)))
+				return subcommands.ExitFailure
+			}))
+
+			var logs testcmd.LogRecorder
+			cmd := subcommandsutil.CancelOnSignal(sub, subcommandsutil.WithLameDuck(tt.lameDuck, hook), subcommandsutil.WithLogger(&logs))
+			ctx := subcommandsutil.WithSignalSource(subcommandsutil.WithClock(context.Background(), clk), src)
+			status, _, _ := testcmd.Run(ctx, cmd)
+			testcmd.AssertStatus(t, status, subcommands.ExitFailure)
+			if n := src.Listeners(); n != 0 {
+				t.Fatalf("wanted the signals no longer listened to but got %d listeners", n)
+			}
+			if got := ev.get(); !reflect.DeepEqual(got, tt.wantEvents) {
+				t.Fatalf("wanted the events %q but got %q", tt.wantEvents, got)
+			}
+			if got := logs.Lines(); strings.Join(got, "\n") != strings.Join(tt.wantLog, "\n") {
+				t.Fatalf("wanted the log %q but got %q", tt.wantLog, got)
+			}
+		})
+	}
+}
+
+func TestCancelOnSignalForceExit(t *testing.T) {
+	src := testcmd.NewSignalSource()
+	clk := testcmd.NewFakeClock(time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC))
+
+	var ev events
+	exited := make(chan struct{})
+	defer subcommandsutil.SetExit(func(code int) {
+		ev.add(fmt.Sprintf("exit %d", code))
+		close(exited)
+	}, &testcmd.LogRecorder{})()
+	for _, name := range []string{"first", "second"} {
+		name := name
+		subcommandsutil.OnExitStatus(func(status subcommands.ExitStatus) {
+			ev.add(fmt.Sprintf("%s hook (%d)", name, status))
+		})
+	}
+
+	sub := testcmd.NewRecording("serve", testcmd.WithExecute(func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+		src.Send(syscall.SIGTERM)
+		clk.BlockUntil(1)
+		clk.Advance(5 * time.Second)
+		<-ctx.Done()
+		ev.add(fmt.Sprintf("canceled (%v)", context.Cause(ctx)))
+		src.Send(os.Interrupt)
+		<-exited
+		return subcommands.ExitFailure
+	}))
+
+	var logs testcmd.LogRecorder
+	cmd := subcommandsutil.CancelOnSignal(sub, subcommandsutil.WithLameDuck(5*time.Second, nil), subcommandsutil.WithLogger(&logs))
+	ctx := subcommandsutil.WithSignalSource(subcommandsutil.WithClock(context.Background(), clk), src)
+	status, _, _ := testcmd.Run(ctx, cmd)
+	testcmd.AssertStatus(t, status, subcommands.ExitFailure)
+	if want := []string{"canceled (context canceled: received terminated)", "first hook (1)", "second hook (1)", "exit 1"}; !reflect.DeepEqual(ev.get(), want) {
+		t.Fatalf("wanted the events %q but got %q", want, ev.get())
+	}
+	if n := sub.DisposeCount(); n != 1 {
+		t.Fatalf("wanted 1 Dispose call but got %d", n)
+	}
+	wantLog := []string{"serve: received terminated, canceling in 5s", "serve: received terminated, canceling", "serve: received interrupt after the cancellation, exiting"}
+	if got := logs.Lines(); !reflect.DeepEqual(got, wantLog) {
+		t.Fatalf("wanted the log %q but got %q", wantLog, got)
+	}
+}
+
+func TestCancelOnSignalWithoutSignal(t *testing.T) {
+	t.Parallel()
+
+	src := testcmd.NewSignalSource()
+	sub := testcmd.NewRecording("serve")
+	status, _, _ := testcmd.Run(subcommandsutil.WithSignalSource(context.Background(), src), subcommandsutil.CancelOnSignal(sub))
+	testcmd.RequireSuccess(t, status)
+	if n := src.Listeners(); n != 0 {
+		t.Fatalf("wanted the signals no longer listened to but got %d listeners", n)
+	}
+	if sub.CallCount() != 1 {
+		t.Fatalf("wanted 1 call but got %d", sub.CallCount())
+	}
+}
+
+// statusSignal is the status signal of the tests, available on every platform.
+type statusSignal struct{}
+
+func (statusSignal) String() string { return "status" }
+func (statusSignal) Signal()        {}
+
+func TestCancelOnSignalStatus(t *testing.T) {
+	t.Parallel()
+
+	src := testcmd.NewSignalSource()
+	clk := testcmd.NewFakeClock(time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC))
+	stderr := make(lineWriter)
+
+	steps := []struct {
+		progress *subcommandsutil.Progress
+		advance  time.Duration
+		want     string
+	}{
+		{advance: 3*time.Minute + 12*time.Second, want: "sync: running for 3m12s\n"},
+		{progress: &subcommandsutil.Progress{Current: 3, Total: 10, Message: "copying"}, advance: 5 * time.Second, want: "sync: copying (3/10), running for 3m17s\n"},
+		{progress: &subcommandsutil.Progress{Current: 7}, want: "sync: 7, running for 3m17s\n"},
+	}
+	sub := testcmd.NewRecording("sync", testcmd.WithExecute(func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+		for _, step := range steps {
+			if step.progress != nil {
+				subcommandsutil.ReportProgress(ctx, *step.progress)
+			}
+			clk.Advance(step.advance)
+			src.Send(statusSignal{})
+			if got := <-stderr; got != step.want {
+				t.Errorf("wanted the status %q but got %q", step.want, got)
+			}
+			if err := ctx.Err(); err != nil {
+				t.Errorf("wanted the execution not canceled by the status signal but got %v", err)
+			}
+		}
+		src.Send(syscall.SIGTERM)
+		<-ctx.Done()
+		return subcommands.ExitFailure
+	}))
+
+	var logs testcmd.LogRecorder
+	cmd := subcommandsutil.CancelOnSignal(sub, subcommandsutil.WithStatusSignals(statusSignal{}), subcommandsutil.WithLogger(&logs))
+	ctx := subcommandsutil.WithSignalSource(subcommandsutil.WithClock(context.Background(), clk), src)
+	ctx = subcommandsutil.WithOutput(ctx, &testcmd.Buffer{}, stderr)
+	status, _, _ := testcmd.Run(ctx, cmd)
+	testcmd.AssertStatus(t, status, subcommands.ExitFailure)
+	if want := []string{"sync: received terminated, canceling"}; !reflect.DeepEqual(logs.Lines(), want) {
+		t.Fatalf("wanted the log %q but got %q", want, logs.Lines())
+	}
+}
@@ -0,0 +1,49 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"context"
+	"os"
+	"os/signal"
+)
+
+// SignalSource relays the signals of the process to CancelOnSignal and Run, like signal.Notify.
+// The default SignalSource uses the os/signal package; tests inject a fake one with
+// WithSignalSource, like testcmd.SignalSource.
+type SignalSource interface {
+	// Notify relays the signals sigs, or all of them if none, to c.
+	Notify(c chan<- os.Signal, sigs ...os.Signal)
+
+	// Stop stops relaying signals to c.
+	Stop(c chan<- os.Signal)
+}
+
+// signalSourceKey is the context key of the SignalSource.
+type signalSourceKey struct{}
+
+// WithSignalSource returns a copy of ctx carrying src, which is used by the wrappers of this
+// package executing with the returned context.
+func WithSignalSource(ctx context.Context, src SignalSource) context.Context {
+	return context.WithValue(ctx, signalSourceKey{}, src)
+}
+
+// SignalSourceFromContext returns the SignalSource carried by ctx, or the one of the os/signal
+// package.
+func SignalSourceFromContext(ctx context.Context) SignalSource {
+	if src, ok := ctx.Value(signalSourceKey{}).(SignalSource); ok {
+		return src
+	}
+
+	return osSignalSource{}
+}
+
+// osSignalSource is the SignalSource of the os/signal package.
+type osSignalSource struct{}
+
+// Notify implements SignalSource.
+func (osSignalSource) Notify(c chan<- os.Signal, sigs ...os.Signal) { signal.Notify(c, sigs...) }
+
+// Stop implements SignalSource.
+func (osSignalSource) Stop(c chan<- os.Signal) { signal.Stop(c) }
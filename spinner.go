@@ -0,0 +1,147 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// SpinnerOption is an option of Spinner.
+type SpinnerOption interface {
+	applySpinner(*spinner)
+}
+
+// spinnerOptionFunc is a SpinnerOption implemented by a function.
+type spinnerOptionFunc func(*spinner)
+
+// applySpinner implements SpinnerOption.
+func (fn spinnerOptionFunc) applySpinner(s *spinner) { fn(s) }
+
+// WithSpinnerTerminal makes Spinner render to its writer as if it was a terminal.
+func WithSpinnerTerminal() SpinnerOption {
+	return spinnerOptionFunc(func(s *spinner) {
+		s.terminal = true
+	})
+}
+
+// WithSpinnerInterval sets the interval between the redraws of the Spinner. It defaults to 100ms.
+func WithSpinnerInterval(d time.Duration) SpinnerOption {
+	return spinnerOptionFunc(func(s *spinner) {
+		s.interval = d
+	})
+}
+
+// spinnerFrames are the frames drawn by a Spinner in turn.
+var spinnerFrames = []string{"|", "/", "-", `\`}
+
+// clearLine moves the cursor to the start of the line and erases it.
+const clearLine = "\r\x1b[K"
+
+// spinner is a progress indicator drawn on a single terminal line.
+type spinner struct {
+	w        io.Writer
+	terminal bool
+	interval time.Duration
+
+	mu      sync.Mutex
+	message string
+	frame   int
+	cleared bool
+
+	done   chan struct{}
+	exited chan struct{}
+	once   sync.Once
+}
+
+// Spinner draws a spinner followed by message on w, redrawn on a ticker of the Clock carried by
+// ctx. update replaces the message, and stop erases the line and stops the spinner; stop must be
+// called, usually deferred, and is safe to call more than once.
+//
+// The line is also erased when ctx is done, before a Cancelable wrapper logs the cancellation. The
+// spinner draws nothing if w is not a terminal or in quiet mode.
+func Spinner(ctx context.Context, w io.Writer, message string, opts ...SpinnerOption) (update func(message string), stop func()) {
+	s := &spinner{
+		w:        w,
+		terminal: isTerminal(w),
+		interval: 100 * time.Millisecond,
+		message:  message,
+		done:     make(chan struct{}),
+		exited:   make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt.applySpinner(s)
+	}
+	if !s.terminal || IsQuiet(ctx) {
+		return func(string) {}, func() {}
+	}
+
+	remove := onCancel(ctx, s.clear)
+	s.draw(false)
+
+	t := ClockFromContext(ctx).NewTicker(s.interval)
+	go func() {
+		defer close(s.exited)
+		defer t.Stop()
+
+		for {
+			select {
+			case <-t.C():
+				s.draw(true)
+			case <-ctx.Done():
+				s.clear()
+				return
+			case <-s.done:
+				return
+			}
+		}
+	}()
+
+	return s.update, func() {
+		s.once.Do(func() {
+			close(s.done)
+			<-s.exited
+			remove()
+			s.clear()
+		})
+	}
+}
+
+// update replaces the message and redraws the line.
+func (s *spinner) update(message string) {
+	s.mu.Lock()
+	s.message = message
+	s.mu.Unlock()
+
+	s.draw(false)
+}
+
+// draw draws the line, advancing to the next frame if next is true.
+func (s *spinner) draw(next bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.cleared {
+		return
+	}
+	if next {
+		s.frame = (s.frame + 1) % len(spinnerFrames)
+	}
+	fmt.Fprintf(s.w, "%s%s %s", clearLine, spinnerFrames[s.frame], s.message)
+}
+
+// clear erases the line, once, and stops drawing.
+func (s *spinner) clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.cleared {
+		return
+	}
+	s.cleared = true
+	fmt.Fprint(s.w, clearLine)
+}
@@ -0,0 +1,130 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"context"
+	"flag"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+func TestSpinner(t *testing.T) {
+	defer testcmd.VerifyNoLeaks(t)
+
+	clk := testcmd.NewFakeClock(time.Now())
+	ctx := subcommandsutil.WithClock(context.Background(), clk)
+
+	var out testcmd.Buffer
+	update, stop := subcommandsutil.Spinner(ctx, &out, "fetching", subcommandsutil.WithSpinnerTerminal())
+	defer stop()
+
+	clk.BlockUntil(1)
+	clk.Advance(100 * time.Millisecond)
+	for deadline := time.Now().Add(time.Second); !strings.Contains(out.String(), "/ fetching"); {
+		if time.Now().After(deadline) {
+			t.Fatalf("wanted a redraw on the tick but got %q", out.String())
+		}
+		time.Sleep(time.Millisecond)
+	}
+	update("extracting")
+	stop()
+	stop()
+
+	if want := "\r\x1b[K| fetching\r\x1b[K/ fetching\r\x1b[K/ extracting\r\x1b[K"; out.String() != want {
+		t.Fatalf("wanted %q but got %q", want, out.String())
+	}
+
+	update("ignored")
+	if strings.Contains(out.String(), "ignored") {
+		t.Fatalf("wanted no drawing after stop but got %q", out.String())
+	}
+}
+
+func TestSpinnerDisabled(t *testing.T) {
+	tests := map[string]struct {
+		ctx  context.Context
+		opts []subcommandsutil.SpinnerOption
+	}{
+		"when the writer is not a terminal": {
+			ctx: context.Background(),
+		},
+		"when quiet mode is set": {
+			ctx:  subcommandsutil.WithQuiet(context.Background(), true),
+			opts: []subcommandsutil.SpinnerOption{subcommandsutil.WithSpinnerTerminal()},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			defer testcmd.VerifyNoLeaks(t)
+
+			var out testcmd.Buffer
+			update, stop := subcommandsutil.Spinner(tt.ctx, &out, "fetching", tt.opts...)
+			update("extracting")
+			stop()
+			if got := out.String(); got != "" {
+				t.Fatalf("wanted no output but got %q", got)
+			}
+		})
+	}
+}
+
+func TestSpinnerCanceled(t *testing.T) {
+	defer testcmd.VerifyNoLeaks(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var out testcmd.Buffer
+	_, stop := subcommandsutil.Spinner(ctx, &out, "fetching", subcommandsutil.WithSpinnerTerminal(), subcommandsutil.WithSpinnerInterval(time.Hour))
+	cancel()
+	stop()
+
+	if want := "\r\x1b[K| fetching\r\x1b[K"; out.String() != want {
+		t.Fatalf("wanted %q but got %q", want, out.String())
+	}
+}
+
+// snapshotLogger is a subcommandsutil.Logger recording the content of out when it logs.
+type snapshotLogger struct {
+	out  *testcmd.Buffer
+	snap chan string
+}
+
+// Printf implements subcommandsutil.Logger.
+func (l *snapshotLogger) Printf(format string, v ...interface{}) {
+	l.snap <- l.out.String()
+}
+
+func TestSpinnerCancelable(t *testing.T) {
+	defer testcmd.VerifyNoLeaks(t)
+
+	var out testcmd.Buffer
+	started := make(chan struct{})
+	cmd := subcommandsutil.New("fetch").Run(func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+		_, stop := subcommandsutil.Spinner(ctx, &out, "fetching", subcommandsutil.WithSpinnerTerminal(), subcommandsutil.WithSpinnerInterval(time.Hour))
+		defer stop()
+		close(started)
+		<-ctx.Done()
+		return subcommands.ExitSuccess
+	}).Build()
+
+	logger := &snapshotLogger{out: &out, snap: make(chan string, 1)}
+	wrapped := subcommandsutil.Cancelable(cmd, subcommandsutil.WithLogger(logger))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-started
+		cancel()
+	}()
+	testcmd.AssertStatus(t, wrapped.Execute(ctx, flag.NewFlagSet("fetch", flag.ContinueOnError)), subcommands.ExitFailure)
+
+	if got := <-logger.snap; !strings.HasSuffix(got, "\r\x1b[K") {
+		t.Fatalf("wanted the line erased before the cancellation is logged but got %q", got)
+	}
+}
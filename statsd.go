@@ -0,0 +1,181 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// statsDQueue is the number of metrics queued before the new ones are dropped.
+	statsDQueue = 1024
+
+	// statsDPacketSize is the maximum size of a packet, fitting the MTU of most networks.
+	statsDPacketSize = 1432
+)
+
+// StatsDOption is an option of NewStatsD.
+type StatsDOption interface {
+	applyStatsD(*StatsD)
+}
+
+// applyStatsD implements StatsDOption.
+func (o LoggerOption) applyStatsD(s *StatsD) {
+	s.logger = o.logger
+}
+
+// StatsD is a Metrics sending the metrics to a StatsD server, in the format of DogStatsD with
+// tags:
+//
+//	subcommand.executions:1|c|#command:push,status:0
+//	subcommand.duration:1500|ms|#command:push,status:0
+//
+// The metrics are queued and sent by a goroutine, several per packet, so that recording them never
+// blocks; they are dropped when the queue is full, or when the server cannot be reached. Subscribe
+// its MetricsSubscriber with Events.SubscribeTelemetry to honor the opt-out of Telemetry.
+type StatsD struct {
+	logger Logger
+
+	mu    sync.RWMutex
+	queue chan string // nil once closed, or without a connection
+	done  chan struct{}
+}
+
+// make sure StatsD implements the Metrics interface.
+var _ Metrics = (*StatsD)(nil)
+
+// NewStatsD returns a StatsD sending the metrics to addr, "host:port" or "udp://host:port" for UDP,
+// or "unix:///path" for a Unix datagram socket. If the connection cannot be set up, a warning is
+// logged and the metrics are discarded. Close flushes the metrics and closes the connection.
+func NewStatsD(addr string, opts ...StatsDOption) *StatsD {
+	s := &StatsD{
+		logger: stdLogger{},
+		done:   make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt.applyStatsD(s)
+	}
+
+	network, address := "udp", strings.TrimPrefix(addr, "udp://")
+	if path, ok := strings.CutPrefix(addr, "unix://"); ok {
+		network, address = "unixgram", path
+	}
+	conn, err := net.Dial(network, address)
+	if err != nil {
+		s.logger.Printf("warning: statsd: %v; discarding the metrics", err)
+		close(s.done)
+		return s
+	}
+
+	s.queue = make(chan string, statsDQueue)
+	go s.send(conn, s.queue)
+
+	return s
+}
+
+// Count implements Metrics.
+func (s *StatsD) Count(name string, value int64, tags map[string]string) {
+	s.enqueue(name, strconv.FormatInt(value, 10), "c", tags)
+}
+
+// Timing implements Metrics.
+func (s *StatsD) Timing(name string, d time.Duration, tags map[string]string) {
+	ms := float64(d) / float64(time.Millisecond)
+	s.enqueue(name, strconv.FormatFloat(ms, 'f', -1, 64), "ms", tags)
+}
+
+// Close sends the queued metrics and closes the connection. The metrics recorded afterward are
+// discarded.
+func (s *StatsD) Close() error {
+	s.mu.Lock()
+	if s.queue != nil {
+		close(s.queue)
+		s.queue = nil
+	}
+	s.mu.Unlock()
+	<-s.done
+
+	return nil
+}
+
+// enqueue queues the metric name of the type typ with value and tags, unless the queue is full.
+func (s *StatsD) enqueue(name, value, typ string, tags map[string]string) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.queue == nil {
+		return
+	}
+
+	var b strings.Builder
+	b.WriteString(sanitizeStatsD(name))
+	b.WriteString(":" + value + "|" + typ)
+	if len(tags) > 0 {
+		keys := make([]string, 0, len(tags))
+		for k := range tags {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for i, k := range keys {
+			if i == 0 {
+				b.WriteString("|#")
+			} else {
+				b.WriteByte(',')
+			}
+			b.WriteString(sanitizeStatsD(k) + ":" + sanitizeStatsD(tags[k]))
+		}
+	}
+
+	select {
+	case s.queue <- b.String():
+	default:
+	}
+}
+
+// send sends the metrics of queue to conn, joining the metrics queued together into packets, until
+// the queue is closed.
+func (s *StatsD) send(conn net.Conn, queue <-chan string) {
+	defer close(s.done)
+	defer conn.Close()
+
+	var packet []byte
+	for line := range queue {
+		packet = append(packet[:0], line...)
+	batch:
+		for {
+			select {
+			case line, ok := <-queue:
+				if !ok {
+					break batch
+				}
+				if len(packet)+1+len(line) > statsDPacketSize {
+					conn.Write(packet)
+					packet = packet[:0]
+				} else {
+					packet = append(packet, '\n')
+				}
+				packet = append(packet, line...)
+			default:
+				break batch
+			}
+		}
+		conn.Write(packet)
+	}
+}
+
+// sanitizeStatsD replaces the characters of s delimiting the fields of the StatsD format.
+func sanitizeStatsD(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ':', '|', ',', '#', '@', '\n':
+			return '_'
+		}
+		return r
+	}, s)
+}
@@ -0,0 +1,131 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"context"
+	"net"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+// listenStatsD returns a UDP socket listening for the metrics of a StatsD.
+func listenStatsD(t *testing.T) net.PacketConn {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("listening on UDP: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return conn
+}
+
+// readStatsD returns the lines of the packets received on conn until none arrives for a while.
+func readStatsD(t *testing.T, conn net.PacketConn) []string {
+	t.Helper()
+
+	var lines []string
+	buf := make([]byte, 65536)
+	for {
+		conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			return lines
+		}
+		lines = append(lines, strings.Split(string(buf[:n]), "\n")...)
+	}
+}
+
+func TestStatsD(t *testing.T) {
+	tests := map[string]struct {
+		cancel bool
+		want   []string
+	}{
+		"when the command succeeds": {
+			want: []string{
+				`^subcommand\.executions:1\|c\|#command:push,status:0$`,
+				`^subcommand\.duration:[0-9.]+\|ms\|#command:push,status:0$`,
+			},
+		},
+		"when the command is canceled": {
+			cancel: true,
+			want: []string{
+				`^subcommand\.cancellations:1\|c\|#command:push$`,
+				`^subcommand\.executions:1\|c\|#command:push,status:1$`,
+				`^subcommand\.duration:[0-9.]+\|ms\|#command:push,status:1$`,
+			},
+		},
+	}
+	for name, tt := range tests {
+		tt := tt
+		t.Run(name, func(t *testing.T) {
+			conn := listenStatsD(t)
+			s := subcommandsutil.NewStatsD(conn.LocalAddr().String(), subcommandsutil.WithLogger(&testcmd.LogRecorder{}))
+			events := subcommandsutil.NewEvents()
+			events.Subscribe(subcommandsutil.MetricsSubscriber(s))
+
+			ctx, cancel := context.WithCancel(subcommandsutil.WithEvents(context.Background(), events))
+			defer cancel()
+			var sub subcommandsutil.CancelableCommand = testcmd.NewRecording("push")
+			if tt.cancel {
+				tcmd := testcmd.NewBlocking("push")
+				defer tcmd.Release(subcommands.ExitSuccess)
+				go func() {
+					<-tcmd.Started()
+					cancel()
+				}()
+				sub = tcmd
+			}
+			testcmd.Run(ctx, subcommandsutil.Cancelable(sub, subcommandsutil.WithLogger(&testcmd.LogRecorder{})))
+			if err := s.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			got := readStatsD(t, conn)
+			if len(got) != len(tt.want) {
+				t.Fatalf("wanted %d metrics but got %q", len(tt.want), got)
+			}
+			for i, want := range tt.want {
+				if !regexp.MustCompile(want).MatchString(got[i]) {
+					t.Fatalf("wanted the metric %d to match %s but got %q", i, want, got[i])
+				}
+			}
+		})
+	}
+}
+
+func TestStatsDSanitize(t *testing.T) {
+	conn := listenStatsD(t)
+	s := subcommandsutil.NewStatsD("udp://" + conn.LocalAddr().String())
+	s.Count("deploy:count", 2, map[string]string{"region": "us|east", "env": "prod,test"})
+	s.Close()
+
+	got := readStatsD(t, conn)
+	if want := []string{"deploy_count:2|c|#env:prod_test,region:us_east"}; strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("wanted the metrics %q but got %q", want, got)
+	}
+}
+
+func TestStatsDUnreachable(t *testing.T) {
+	logger := &testcmd.LogRecorder{}
+	s := subcommandsutil.NewStatsD("unix:///nonexistent/statsd.sock", subcommandsutil.WithLogger(logger))
+	s.Count(subcommandsutil.MetricExecutions, 1, nil)
+	s.Timing(subcommandsutil.MetricDuration, time.Second, nil)
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if lines := logger.Lines(); len(lines) != 1 || !strings.HasPrefix(lines[0], "warning: statsd: ") {
+		t.Fatalf("wanted a warning but got %q", lines)
+	}
+}
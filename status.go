@@ -0,0 +1,94 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/google/subcommands"
+)
+
+// StatusString returns a readable name of s, such as "ExitUsageError(2)". Unknown statuses are
+// rendered as "ExitStatus(n)".
+func StatusString(s subcommands.ExitStatus) string {
+	switch s {
+	case subcommands.ExitSuccess:
+		return "ExitSuccess(0)"
+	case subcommands.ExitFailure:
+		return "ExitFailure(1)"
+	case subcommands.ExitUsageError:
+		return "ExitUsageError(2)"
+	default:
+		return fmt.Sprintf("ExitStatus(%d)", int(s))
+	}
+}
+
+// ExitError is an error carrying the ExitStatus a command exits with.
+type ExitError struct {
+	Status subcommands.ExitStatus
+	Err    error
+}
+
+// Error implements error.
+func (e *ExitError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying e.Err error.
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
+
+// UsageErrorf returns an ExitError with subcommands.ExitUsageError and the formatted message.
+func UsageErrorf(format string, args ...interface{}) error {
+	return &ExitError{
+		Status: subcommands.ExitUsageError,
+		Err:    fmt.Errorf(format, args...),
+	}
+}
+
+// StatusFromError returns the ExitStatus a command returning err exits with: subcommands.ExitSuccess
+// for nil, the Status of an ExitError in the chain of err, and subcommands.ExitFailure otherwise.
+func StatusFromError(err error) subcommands.ExitStatus {
+	if err == nil {
+		return subcommands.ExitSuccess
+	}
+
+	var exitErr *ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.Status
+	}
+
+	return subcommands.ExitFailure
+}
+
+// StatusAggregator combines the ExitStatus of several commands, in the order the commands were
+// given, into the ExitStatus of the composite command running them.
+type StatusAggregator func(statuses []subcommands.ExitStatus) subcommands.ExitStatus
+
+// WorstStatus is a StatusAggregator returning the highest of statuses, or subcommands.ExitSuccess
+// if there are none.
+func WorstStatus(statuses []subcommands.ExitStatus) subcommands.ExitStatus {
+	worst := subcommands.ExitSuccess
+	for _, s := range statuses {
+		if s > worst {
+			worst = s
+		}
+	}
+
+	return worst
+}
+
+// FirstFailure is a StatusAggregator returning the first of statuses other than
+// subcommands.ExitSuccess, or subcommands.ExitSuccess if there are none.
+func FirstFailure(statuses []subcommands.ExitStatus) subcommands.ExitStatus {
+	for _, s := range statuses {
+		if s != subcommands.ExitSuccess {
+			return s
+		}
+	}
+
+	return subcommands.ExitSuccess
+}
@@ -0,0 +1,114 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+)
+
+func TestStatusString(t *testing.T) {
+	tests := map[string]struct {
+		status subcommands.ExitStatus
+		want   string
+	}{
+		"when success": {
+			status: subcommands.ExitSuccess,
+			want:   "ExitSuccess(0)",
+		},
+		"when failure": {
+			status: subcommands.ExitFailure,
+			want:   "ExitFailure(1)",
+		},
+		"when usage error": {
+			status: subcommands.ExitUsageError,
+			want:   "ExitUsageError(2)",
+		},
+		"when unknown": {
+			status: subcommands.ExitStatus(42),
+			want:   "ExitStatus(42)",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := subcommandsutil.StatusString(tt.status); got != tt.want {
+				t.Fatalf("wanted %q but got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestStatusFromError(t *testing.T) {
+	tests := map[string]struct {
+		err  error
+		want subcommands.ExitStatus
+	}{
+		"when nil": {
+			err:  nil,
+			want: subcommands.ExitSuccess,
+		},
+		"when a plain error": {
+			err:  errors.New("boom"),
+			want: subcommands.ExitFailure,
+		},
+		"when a usage error": {
+			err:  subcommandsutil.UsageErrorf("missing %s", "FILE"),
+			want: subcommands.ExitUsageError,
+		},
+		"when a wrapped exit error": {
+			err:  fmt.Errorf("saving: %w", &subcommandsutil.ExitError{Status: subcommands.ExitStatus(3), Err: errors.New("locked")}),
+			want: subcommands.ExitStatus(3),
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := subcommandsutil.StatusFromError(tt.err); got != tt.want {
+				t.Fatalf("wanted %s but got %s", subcommandsutil.StatusString(tt.want), subcommandsutil.StatusString(got))
+			}
+		})
+	}
+
+	err := subcommandsutil.UsageErrorf("missing %s", "FILE")
+	if err.Error() != "missing FILE" {
+		t.Fatalf("wanted the message %q but got %q", "missing FILE", err.Error())
+	}
+}
+
+func TestStatusAggregators(t *testing.T) {
+	tests := map[string]struct {
+		statuses  []subcommands.ExitStatus
+		wantWorst subcommands.ExitStatus
+		wantFirst subcommands.ExitStatus
+	}{
+		"when there are no statuses": {
+			wantWorst: subcommands.ExitSuccess,
+			wantFirst: subcommands.ExitSuccess,
+		},
+		"when all succeed": {
+			statuses:  []subcommands.ExitStatus{subcommands.ExitSuccess, subcommands.ExitSuccess},
+			wantWorst: subcommands.ExitSuccess,
+			wantFirst: subcommands.ExitSuccess,
+		},
+		"when several fail": {
+			statuses:  []subcommands.ExitStatus{subcommands.ExitSuccess, subcommands.ExitFailure, subcommands.ExitUsageError},
+			wantWorst: subcommands.ExitUsageError,
+			wantFirst: subcommands.ExitFailure,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := subcommandsutil.WorstStatus(tt.statuses); got != tt.wantWorst {
+				t.Fatalf("wanted worst %v but got %v", tt.wantWorst, got)
+			}
+			if got := subcommandsutil.FirstFailure(tt.statuses); got != tt.wantFirst {
+				t.Fatalf("wanted first %v but got %v", tt.wantFirst, got)
+			}
+		})
+	}
+}
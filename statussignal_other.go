@@ -0,0 +1,12 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+//go:build !unix
+
+package subcommandsutil
+
+import "os"
+
+// defaultStatusSignals is empty, as there is no user-defined signal to dump the status of
+// CancelOnSignal with on this platform.
+var defaultStatusSignals []os.Signal
@@ -0,0 +1,15 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+//go:build unix && !(darwin || dragonfly || freebsd || netbsd || openbsd)
+
+package subcommandsutil
+
+import (
+	"os"
+	"syscall"
+)
+
+// defaultStatusSignals are the signals dumping the status of CancelOnSignal, SIGUSR1 on this
+// platform without SIGINFO.
+var defaultStatusSignals = []os.Signal{syscall.SIGUSR1}
@@ -0,0 +1,262 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/google/subcommands"
+)
+
+// maxSuggestions is the maximum number of suggestions printed for a mistyped name.
+const maxSuggestions = 3
+
+// suggestFlags wraps a subcommands.Command so that mistyped flags get suggestions.
+type suggestFlags struct {
+	sub subcommands.Command
+}
+
+// make sure suggestFlags implements the subcommands.Command interface.
+var _ subcommands.Command = (*suggestFlags)(nil)
+
+// SuggestFlags wraps sub so that parsing an undefined flag appends suggestions of the closest
+// defined flags to the parse error, like:
+//
+//	flag provided but not defined: -verbos
+//	did you mean -verbose?
+//
+// The suggestions are printed just before the FlagSet's Usage func runs.
+func SuggestFlags(sub subcommands.Command) subcommands.Command {
+	return &suggestFlags{
+		sub: sub,
+	}
+}
+
+// Name forwards to the underlying c.sub Command.
+func (c *suggestFlags) Name() string {
+	return c.sub.Name()
+}
+
+// Usage forwards to the underlying c.sub Command.
+func (c *suggestFlags) Usage() string {
+	return c.sub.Usage()
+}
+
+// Synopsis forwards to the underlying c.sub Command.
+func (c *suggestFlags) Synopsis() string {
+	return c.sub.Synopsis()
+}
+
+// Unwrap returns the underlying c.sub Command.
+func (c *suggestFlags) Unwrap() subcommands.Command {
+	return c.sub
+}
+
+// SetFlags forwards to the underlying c.sub Command and installs the suggesting Usage func on f.
+func (c *suggestFlags) SetFlags(f *flag.FlagSet) {
+	c.sub.SetFlags(f)
+
+	out := &lastWriteRecorder{Writer: f.Output()}
+	f.SetOutput(out)
+
+	usage := f.Usage
+	f.Usage = func() {
+		if name, ok := undefinedFlag(out.last); ok {
+			if s := FlagSuggestions(f, name); len(s) > 0 {
+				fmt.Fprintln(out.Writer, defaultMessagesCatalog().Sprintf(MessageDidYouMeanFlag, strings.Join(s, ", -")))
+			}
+		}
+		if usage != nil {
+			usage()
+		}
+	}
+}
+
+// Execute forwards to the underlying c.sub Command.
+func (c *suggestFlags) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	return c.sub.Execute(ctx, f, args...)
+}
+
+// FlagSuggestions returns up to three names of visible flags of f, including aliases, which are
+// close to the mistyped name, closest first.
+func FlagSuggestions(f *flag.FlagSet, name string) []string {
+	var candidates []string
+	f.VisitAll(func(fl *flag.Flag) {
+		if !IsHiddenFlag(f, fl.Name) {
+			candidates = append(candidates, fl.Name)
+		}
+	})
+
+	return suggest(name, candidates)
+}
+
+// CommandSuggestions returns up to three names of commands registered in cdr, including aliases,
+// which are close to the mistyped name, closest first. Hidden commands are candidates only if
+// hidden is true.
+func CommandSuggestions(cdr *subcommands.Commander, name string, hidden bool) []string {
+	var candidates []string
+	cdr.VisitCommands(func(_ *subcommands.CommandGroup, cmd subcommands.Command) {
+		if hidden || !IsHiddenCommand(cmd) {
+			candidates = append(candidates, cmd.Name())
+		}
+	})
+
+	return suggest(name, candidates)
+}
+
+// ExecuteWithSuggestions is like cdr.Execute, but when the subcommand named by topFlags, the parsed
+// top-level flags of cdr, is not registered, it prints the closest visible command names to
+// cdr.Error before the command listing, like:
+//
+//	unknown command "pussh"; did you mean "push"?
+//
+// and returns subcommands.ExitUsageError.
+func ExecuteWithSuggestions(ctx context.Context, cdr *subcommands.Commander, topFlags *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	name := topFlags.Arg(0)
+	if name == "" || isRegistered(cdr, name) {
+		return cdr.Execute(ctx, args...)
+	}
+
+	fmt.Fprintln(cdr.Error, unknownCommand(MessagesFromContext(ctx), name, CommandSuggestions(cdr, name, false)))
+	cdr.Explain(cdr.Error)
+
+	return subcommands.ExitUsageError
+}
+
+// isRegistered reports whether a command named name is registered in cdr.
+func isRegistered(cdr *subcommands.Commander, name string) (ok bool) {
+	cdr.VisitCommands(func(_ *subcommands.CommandGroup, cmd subcommands.Command) {
+		ok = ok || cmd.Name() == name
+	})
+
+	return ok
+}
+
+// unknownCommand returns the message of m for the unknown command name with its suggestions.
+func unknownCommand(m *Messages, name string, suggestions []string) string {
+	msg := m.Sprintf(MessageUnknownCommand, name)
+	if len(suggestions) == 0 {
+		return msg
+	}
+
+	quoted := make([]string, len(suggestions))
+	for i, s := range suggestions {
+		quoted[i] = strconv.Quote(s)
+	}
+	list := quoted[0]
+	if len(quoted) > 1 {
+		list = m.Sprintf(MessageListOr, strings.Join(quoted[:len(quoted)-1], ", "), quoted[len(quoted)-1])
+	}
+
+	return msg + m.Sprintf(MessageDidYouMean, list)
+}
+
+// lastWriteRecorder is an io.Writer recording the last write.
+type lastWriteRecorder struct {
+	io.Writer
+	last []byte
+}
+
+// Write implements io.Writer.
+func (w *lastWriteRecorder) Write(p []byte) (int, error) {
+	w.last = append(w.last[:0], p...)
+	return w.Writer.Write(p)
+}
+
+// undefinedFlag extracts the flag name of the error printed by flag.FlagSet for an undefined flag.
+func undefinedFlag(msg []byte) (string, bool) {
+	const prefix = "flag provided but not defined: -"
+
+	msg = bytes.TrimSpace(msg)
+	if !bytes.HasPrefix(msg, []byte(prefix)) {
+		return "", false
+	}
+
+	return string(msg[len(prefix):]), true
+}
+
+// suggest returns up to maxSuggestions candidates close to name, closest first.
+func suggest(name string, candidates []string) []string {
+	maxDist := 2
+	if len(name) <= 3 {
+		maxDist = 1
+	}
+
+	type match struct {
+		name string
+		dist int
+	}
+	var matches []match
+	seen := make(map[string]bool)
+	for _, c := range candidates {
+		if c == name || seen[c] {
+			continue
+		}
+		seen[c] = true
+		if d := levenshtein(name, c); d <= maxDist {
+			matches = append(matches, match{name: c, dist: d})
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].dist != matches[j].dist {
+			return matches[i].dist < matches[j].dist
+		}
+		return matches[i].name < matches[j].name
+	})
+	if len(matches) > maxSuggestions {
+		matches = matches[:maxSuggestions]
+	}
+
+	names := make([]string, len(matches))
+	for i, m := range matches {
+		names[i] = m.name
+	}
+
+	return names
+}
+
+// levenshtein returns the edit distance between a and b.
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+
+	return prev[len(rb)]
+}
+
+// min3 returns the minimum of a, b and c.
+func min3(a, b, c int) int {
+	m := a
+	if b < m {
+		m = b
+	}
+	if c < m {
+		m = c
+	}
+
+	return m
+}
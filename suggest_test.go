@@ -0,0 +1,168 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+func TestSuggestFlags(t *testing.T) {
+	tests := map[string]struct {
+		args           []string
+		wantSuggestion string
+	}{
+		"when a flag is mistyped": {
+			args:           []string{"-verbos"},
+			wantSuggestion: "did you mean -verbose?\n",
+		},
+		"when an alias is mistyped": {
+			args:           []string{"-outptu", "x"},
+			wantSuggestion: "did you mean -output?\n",
+		},
+		"when several flags are close": {
+			args:           []string{"-tag"},
+			wantSuggestion: "did you mean -tab, -tags?\n",
+		},
+		"when the flag is unrelated": {
+			args:           []string{"-zzzzzz"},
+			wantSuggestion: "",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			cmd := subcommandsutil.SuggestFlags(testcmd.NewRecording("suggest",
+				testcmd.WithFlags(func(f *flag.FlagSet) {
+					f.Bool("verbose", false, "verbose output")
+					f.String("o", "", "output file")
+					subcommandsutil.AliasFlag(f, "o", "output")
+					f.String("tags", "", "build tags")
+					f.Bool("tab", false, "tab separated output")
+					f.Bool("secret", false, "hidden flag")
+					subcommandsutil.HideFlags(f, "secret")
+				}),
+			))
+
+			var out bytes.Buffer
+			usageCalled := false
+			f := flag.NewFlagSet("suggest", flag.ContinueOnError)
+			f.SetOutput(&out)
+			f.Usage = func() { usageCalled = true }
+			cmd.SetFlags(f)
+
+			if err := f.Parse(tt.args); err == nil {
+				t.Fatal("wanted a parse error")
+			}
+			if !usageCalled {
+				t.Fatal("wanted the original Usage func to be called")
+			}
+
+			lines := strings.SplitAfterN(out.String(), "\n", 2)
+			if got := lines[1]; got != tt.wantSuggestion {
+				t.Fatalf("wanted suggestion %q but got %q", tt.wantSuggestion, got)
+			}
+		})
+	}
+}
+
+func TestFlagSuggestionsExcludeHidden(t *testing.T) {
+	f := flag.NewFlagSet("test", flag.ContinueOnError)
+	f.SetOutput(io.Discard)
+	f.Bool("secret", false, "hidden flag")
+	subcommandsutil.HideFlags(f, "secret")
+
+	if s := subcommandsutil.FlagSuggestions(f, "secrt"); len(s) != 0 {
+		t.Fatalf("wanted no suggestions for a hidden flag but got %v", s)
+	}
+}
+
+func TestSuggestFlagsExecute(t *testing.T) {
+	tcmd := testcmd.NewRecording("suggest")
+	cmd := subcommandsutil.SuggestFlags(tcmd)
+
+	f := flag.NewFlagSet("suggest", flag.ContinueOnError)
+	cmd.SetFlags(f)
+	if status := cmd.Execute(context.Background(), f); status != subcommands.ExitSuccess {
+		t.Fatalf("wanted %v but got %v", subcommands.ExitSuccess, status)
+	}
+	if !tcmd.DidFinish() {
+		t.Fatal("wanted the command to finish")
+	}
+}
+
+func TestExecuteWithSuggestions(t *testing.T) {
+	tests := map[string]struct {
+		argv       []string
+		wantStatus subcommands.ExitStatus
+		wantStderr string
+	}{
+		"when the command is close to one": {
+			argv:       []string{"pussh"},
+			wantStatus: subcommands.ExitUsageError,
+			wantStderr: "unknown command \"pussh\"; did you mean \"push\"?\nUsage: ",
+		},
+		"when the command is close to several": {
+			argv:       []string{"puhs"},
+			wantStatus: subcommands.ExitUsageError,
+			wantStderr: "unknown command \"puhs\"; did you mean \"pull\" or \"push\"?\n",
+		},
+		"when the command is close to an alias": {
+			argv:       []string{"rn"},
+			wantStatus: subcommands.ExitUsageError,
+			wantStderr: "unknown command \"rn\"; did you mean \"rm\"?\n",
+		},
+		"when the command is close to a hidden one only": {
+			argv:       []string{"debog"},
+			wantStatus: subcommands.ExitUsageError,
+			wantStderr: "unknown command \"debog\"\n",
+		},
+		"when the command is nonsense": {
+			argv:       []string{"xyzzy"},
+			wantStatus: subcommands.ExitUsageError,
+			wantStderr: "unknown command \"xyzzy\"\nUsage: ",
+		},
+		"when the command is registered": {
+			argv:       []string{"push"},
+			wantStatus: subcommands.ExitSuccess,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			h := testcmd.NewHarness(t)
+			h.Register(testcmd.NewRecording("push"), "")
+			h.Register(testcmd.NewRecording("pull"), "")
+			h.Register(subcommandsutil.Alias(testcmd.NewRecording("remove"), "rm"), "")
+			h.Register(subcommandsutil.Hidden(testcmd.NewRecording("debug")), "")
+
+			if err := h.Flags.Parse(tt.argv); err != nil {
+				t.Fatal(err)
+			}
+			testcmd.AssertStatus(t, subcommandsutil.ExecuteWithSuggestions(context.Background(), h.Commander, h.Flags), tt.wantStatus)
+			if !strings.HasPrefix(h.Stderr.String(), tt.wantStderr) {
+				t.Fatalf("wanted stderr to start with %q but got %q", tt.wantStderr, h.Stderr.String())
+			}
+		})
+	}
+}
+
+func TestCommandSuggestions(t *testing.T) {
+	h := testcmd.NewHarness(t)
+	h.Register(subcommandsutil.Hidden(testcmd.NewRecording("debug")), "")
+
+	if got := subcommandsutil.CommandSuggestions(h.Commander, "debog", false); len(got) != 0 {
+		t.Fatalf("wanted no visible suggestions but got %q", got)
+	}
+	if got := subcommandsutil.CommandSuggestions(h.Commander, "debog", true); len(got) != 1 || got[0] != "debug" {
+		t.Fatalf("wanted the hidden command to be suggested but got %q", got)
+	}
+}
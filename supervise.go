@@ -0,0 +1,181 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"context"
+	"flag"
+	"time"
+
+	"github.com/google/subcommands"
+)
+
+// SuperviseOption is an option of the Supervise wrapper.
+type SuperviseOption interface {
+	applySupervise(*supervise)
+}
+
+// superviseOptionFunc is a SuperviseOption implemented by a function.
+type superviseOptionFunc func(*supervise)
+
+// applySupervise implements SuperviseOption.
+func (fn superviseOptionFunc) applySupervise(c *supervise) { fn(c) }
+
+// applySupervise implements SuperviseOption.
+func (o LoggerOption) applySupervise(c *supervise) {
+	c.logger = o.logger
+}
+
+// applySupervise implements SuperviseOption.
+func (o BackoffOption) applySupervise(c *supervise) {
+	c.initial = o.initial
+	c.max = o.max
+}
+
+// WithMaxRestarts makes Supervise give up when the command would be restarted more than n times
+// within window. The default is 5 restarts in 10 minutes.
+func WithMaxRestarts(n int, window time.Duration) SuperviseOption {
+	return superviseOptionFunc(func(c *supervise) {
+		c.maxRestarts = n
+		c.window = window
+	})
+}
+
+// WithHealthyUptime sets how long a run must last for Supervise to consider the command healthy,
+// resetting the backoff. The default is 1 minute.
+func WithHealthyUptime(d time.Duration) SuperviseOption {
+	return superviseOptionFunc(func(c *supervise) {
+		c.healthy = d
+	})
+}
+
+// WithOnRestart sets a function called by Supervise before each restart, with the number of the
+// restart, starting at 1, and the status of the run which exited.
+func WithOnRestart(fn func(restart int, status subcommands.ExitStatus)) SuperviseOption {
+	return superviseOptionFunc(func(c *supervise) {
+		c.onRestart = fn
+	})
+}
+
+// supervise wraps a CancelableCommand so that it is restarted when it fails.
+type supervise struct {
+	sub         CancelableCommand
+	initial     time.Duration
+	max         time.Duration
+	maxRestarts int
+	window      time.Duration
+	healthy     time.Duration
+	onRestart   func(restart int, status subcommands.ExitStatus)
+	logger      Logger
+}
+
+// make sure supervise implements the CancelableCommand interface.
+var _ CancelableCommand = (*supervise)(nil)
+
+// Supervise wraps sub, a command meant to run until its context is done, so that it is restarted
+// whenever it exits with a status other than subcommands.ExitSuccess. Unlike Retry, which reruns a
+// command until it succeeds once, Supervise keeps an always-on command running: sub is disposed
+// before each restart so that no resources leak across runs, the backoff set by WithBackoff is
+// reset after a run lasting the healthy uptime set by WithHealthyUptime, and the restarts are only
+// limited within the window set by WithMaxRestarts. The restarts and giving up are logged to the
+// standard logger unless WithLogger is given.
+//
+// The backoff is measured on the Clock carried by the execution context. Supervise returns the
+// status of the last run when sub succeeds, when it gives up, or when the context is done.
+func Supervise(sub CancelableCommand, opts ...SuperviseOption) CancelableCommand {
+	c := &supervise{
+		sub:         sub,
+		initial:     time.Second,
+		max:         time.Minute,
+		maxRestarts: 5,
+		window:      10 * time.Minute,
+		healthy:     time.Minute,
+		logger:      stdLogger{},
+	}
+	for _, opt := range opts {
+		opt.applySupervise(c)
+	}
+
+	return c
+}
+
+// Name forwards to the underlying c.sub Command.
+func (c *supervise) Name() string {
+	return c.sub.Name()
+}
+
+// Usage forwards to the underlying c.sub Command.
+func (c *supervise) Usage() string {
+	return c.sub.Usage()
+}
+
+// Synopsis forwards to the underlying c.sub Command.
+func (c *supervise) Synopsis() string {
+	return c.sub.Synopsis()
+}
+
+// Unwrap returns the underlying c.sub Command.
+func (c *supervise) Unwrap() subcommands.Command {
+	return c.sub
+}
+
+// SetFlags forwards to the underlying c.sub Command.
+func (c *supervise) SetFlags(f *flag.FlagSet) {
+	c.sub.SetFlags(f)
+}
+
+// Dispose forwards to the underlying c.sub Command.
+func (c *supervise) Dispose() error {
+	return c.sub.Dispose()
+}
+
+// Execute forwards to the underlying c.sub Command, restarting it when it fails.
+func (c *supervise) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	clk := ClockFromContext(ctx)
+
+	backoff := c.initial
+	var restarts []time.Time // the times of the restarts within the window
+	for restart := 1; ; restart++ {
+		start := clk.Now()
+		status := c.sub.Execute(ctx, f, args...)
+		if status == subcommands.ExitSuccess || ctx.Err() != nil {
+			return status
+		}
+
+		now := clk.Now()
+		uptime := now.Sub(start)
+		if uptime >= c.healthy {
+			backoff = c.initial
+		}
+
+		recent := restarts[:0]
+		for _, t := range restarts {
+			if now.Sub(t) < c.window {
+				recent = append(recent, t)
+			}
+		}
+		restarts = recent
+		if len(restarts) >= c.maxRestarts {
+			contextLogger(ctx, c.logger).Printf("%s: exited with status %d, giving up after %d restarts in %v", c.sub.Name(), status, len(restarts), c.window)
+			return status
+		}
+
+		if err := c.sub.Dispose(); err != nil {
+			contextLogger(ctx, c.logger).Printf("%s: disposing before the restart: %v", c.sub.Name(), err)
+		}
+		if c.onRestart != nil {
+			c.onRestart(restart, status)
+		}
+		contextLogger(ctx, c.logger).Printf("%s: exited with status %d after %v, restarting in %v", c.sub.Name(), status, uptime, backoff)
+		if sleep(ctx, clk, backoff) != nil {
+			return status
+		}
+		restarts = append(restarts, clk.Now())
+
+		backoff *= 2
+		if backoff > c.max {
+			backoff = c.max
+		}
+	}
+}
@@ -0,0 +1,158 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"context"
+	"flag"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+// restartCall is a call of the function set by WithOnRestart.
+type restartCall struct {
+	restart int
+	status  subcommands.ExitStatus
+}
+
+func TestSupervise(t *testing.T) {
+	start := time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := map[string]struct {
+		opts         []subcommandsutil.SuperviseOption
+		durations    []time.Duration // how long each run advances the clock; the last one repeats
+		statuses     []subcommands.ExitStatus
+		wantStatus   subcommands.ExitStatus
+		wantStarts   []time.Duration // the start times of the runs after start
+		wantRestarts []restartCall
+		wantLog      string
+	}{
+		"when the backoff is reset after a healthy uptime": {
+			durations:  []time.Duration{0, 0, 0, 70 * time.Second, 0},
+			statuses:   []subcommands.ExitStatus{subcommands.ExitFailure, subcommands.ExitFailure, subcommands.ExitUsageError, subcommands.ExitFailure, subcommands.ExitSuccess},
+			wantStatus: subcommands.ExitSuccess,
+			wantStarts: []time.Duration{0, time.Second, 3 * time.Second, 7 * time.Second, 78 * time.Second},
+			wantRestarts: []restartCall{
+				{1, subcommands.ExitFailure},
+				{2, subcommands.ExitFailure},
+				{3, subcommands.ExitUsageError},
+				{4, subcommands.ExitFailure},
+			},
+			wantLog: "agent: exited with status 1 after 1m10s, restarting in 1s",
+		},
+		"when giving up after too many restarts": {
+			opts:       []subcommandsutil.SuperviseOption{subcommandsutil.WithMaxRestarts(2, time.Minute)},
+			durations:  []time.Duration{0},
+			statuses:   []subcommands.ExitStatus{subcommands.ExitFailure, subcommands.ExitFailure, subcommands.ExitFailure},
+			wantStatus: subcommands.ExitFailure,
+			wantStarts: []time.Duration{0, time.Second, 3 * time.Second},
+			wantRestarts: []restartCall{
+				{1, subcommands.ExitFailure},
+				{2, subcommands.ExitFailure},
+			},
+			wantLog: "agent: exited with status 1, giving up after 2 restarts in 1m0s",
+		},
+		"when the restarts are spread over more than the window": {
+			opts:       []subcommandsutil.SuperviseOption{subcommandsutil.WithMaxRestarts(1, time.Minute), subcommandsutil.WithBackoff(time.Second, time.Second)},
+			durations:  []time.Duration{0, 61 * time.Second, 30 * time.Second},
+			statuses:   []subcommands.ExitStatus{subcommands.ExitFailure, subcommands.ExitFailure, subcommands.ExitFailure},
+			wantStatus: subcommands.ExitFailure,
+			wantStarts: []time.Duration{0, time.Second, 63 * time.Second},
+			wantRestarts: []restartCall{
+				{1, subcommands.ExitFailure},
+				{2, subcommands.ExitFailure},
+			},
+			wantLog: "agent: exited with status 1, giving up after 1 restarts in 1m0s",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			defer testcmd.VerifyNoLeaks(t)
+
+			clk := testcmd.NewFakeClock(start)
+			var (
+				mu     sync.Mutex
+				starts []time.Duration
+			)
+			sub := testcmd.NewRecording("agent", testcmd.WithExecute(func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+				mu.Lock()
+				i := len(starts)
+				starts = append(starts, clk.Now().Sub(start))
+				mu.Unlock()
+
+				clk.Advance(tt.durations[min(i, len(tt.durations)-1)])
+				return tt.statuses[i]
+			}))
+			var restarts []restartCall
+			var logs testcmd.LogRecorder
+			opts := append([]subcommandsutil.SuperviseOption{
+				subcommandsutil.WithLogger(&logs),
+				subcommandsutil.WithOnRestart(func(restart int, status subcommands.ExitStatus) {
+					restarts = append(restarts, restartCall{restart, status})
+				}),
+			}, tt.opts...)
+			cmd := subcommandsutil.Supervise(sub, opts...)
+
+			done := make(chan subcommands.ExitStatus, 1)
+			go func() {
+				status, _, _ := testcmd.Run(subcommandsutil.WithClock(context.Background(), clk), cmd)
+				done <- status
+			}()
+
+			var status subcommands.ExitStatus
+			for finished := false; !finished; {
+				select {
+				case status = <-done:
+					finished = true
+				case <-time.After(time.Millisecond):
+					if clk.Waiters() > 0 {
+						clk.Advance(time.Second)
+					}
+				}
+			}
+
+			testcmd.AssertStatus(t, status, tt.wantStatus)
+			if !reflect.DeepEqual(starts, tt.wantStarts) {
+				t.Fatalf("wanted the runs to start at %v but got %v", tt.wantStarts, starts)
+			}
+			if !reflect.DeepEqual(restarts, tt.wantRestarts) {
+				t.Fatalf("wanted the restarts %v but got %v", tt.wantRestarts, restarts)
+			}
+			if got := sub.DisposeCount(); got != len(tt.wantRestarts) {
+				t.Fatalf("wanted sub to be disposed before each of the %d restarts but got %d", len(tt.wantRestarts), got)
+			}
+			if !logs.Contains(tt.wantLog) {
+				t.Fatalf("wanted the logs to hold %q but got %q", tt.wantLog, logs.Lines())
+			}
+		})
+	}
+}
+
+func TestSuperviseCanceled(t *testing.T) {
+	defer testcmd.VerifyNoLeaks(t)
+
+	clk := testcmd.NewFakeClock(time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC))
+	ctx, cancel := context.WithCancel(subcommandsutil.WithClock(context.Background(), clk))
+	defer cancel()
+	sub := testcmd.NewRecording("agent", testcmd.WithStatus(subcommands.ExitFailure))
+
+	done := make(chan subcommands.ExitStatus, 1)
+	go func() {
+		status, _, _ := testcmd.Run(ctx, subcommandsutil.Supervise(sub, subcommandsutil.WithLogger(&testcmd.LogRecorder{})))
+		done <- status
+	}()
+	clk.BlockUntil(1)
+	cancel()
+
+	testcmd.AssertStatus(t, <-done, subcommands.ExitFailure)
+	if sub.CallCount() != 1 {
+		t.Fatalf("wanted no restart after the cancellation but got %d runs", sub.CallCount())
+	}
+}
@@ -0,0 +1,145 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"context"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// TableOption is an option of Table.
+type TableOption interface {
+	applyTable(*TableWriter)
+}
+
+// tableOptionFunc is a TableOption implemented by a function.
+type tableOptionFunc func(*TableWriter)
+
+// applyTable implements TableOption.
+func (fn tableOptionFunc) applyTable(t *TableWriter) { fn(t) }
+
+// WithTableTerminal makes Table render the table as if Stdout was a terminal.
+func WithTableTerminal() TableOption {
+	return tableOptionFunc(func(t *TableWriter) {
+		t.terminal = true
+	})
+}
+
+// TableWriter renders rows of cells as a table. It is created by Table.
+type TableWriter struct {
+	w        io.Writer
+	terminal bool
+	rows     [][]string
+}
+
+// Table returns a TableWriter writing to the Stdout of ctx, like:
+//
+//	t := subcommandsutil.Table(ctx)
+//	t.Header("NAME", "STATUS")
+//	for _, r := range resources {
+//		t.Row(r.Name, r.Status)
+//	}
+//	if err := t.Flush(); err != nil {
+//		...
+//	}
+//
+// When Stdout is a terminal, the columns are aligned by padding the cells to the widest cell of
+// their column, and the lines longer than TerminalWidth are truncated with an ellipsis. Otherwise
+// the cells are separated by tabs, one row per line, for awk and cut.
+func Table(ctx context.Context, opts ...TableOption) *TableWriter {
+	w := Stdout(ctx)
+	t := &TableWriter{
+		w:        w,
+		terminal: isTerminal(w),
+	}
+	for _, opt := range opts {
+		opt.applyTable(t)
+	}
+
+	return t
+}
+
+// Header adds the header row of the table, with the titles cols.
+func (t *TableWriter) Header(cols ...string) {
+	t.Row(cols...)
+}
+
+// Row adds a row of cells to the table. The rows with fewer cells than others are completed with
+// empty cells.
+func (t *TableWriter) Row(cells ...string) {
+	t.rows = append(t.rows, cells)
+}
+
+// Flush writes the rows added since the last Flush.
+func (t *TableWriter) Flush() error {
+	rows := t.rows
+	t.rows = nil
+
+	var b strings.Builder
+	if !t.terminal {
+		for _, row := range rows {
+			for i, cell := range row {
+				if i > 0 {
+					b.WriteByte('\t')
+				}
+				b.WriteString(cellReplacer.Replace(cell))
+			}
+			b.WriteByte('\n')
+		}
+		_, err := io.WriteString(t.w, b.String())
+		return err
+	}
+
+	var widths []int
+	for _, row := range rows {
+		for i, cell := range row {
+			if i == len(widths) {
+				widths = append(widths, 0)
+			}
+			if n := utf8.RuneCountInString(cell); n > widths[i] {
+				widths[i] = n
+			}
+		}
+	}
+
+	width := TerminalWidth(t.w)
+	var line strings.Builder
+	for _, row := range rows {
+		line.Reset()
+		for i := range widths {
+			var cell string
+			if i < len(row) {
+				cell = cellReplacer.Replace(row[i])
+			}
+			if i > 0 {
+				line.WriteString("  ")
+			}
+			line.WriteString(cell)
+			if i < len(widths)-1 {
+				line.WriteString(strings.Repeat(" ", widths[i]-utf8.RuneCountInString(cell)))
+			}
+		}
+		b.WriteString(truncateLine(strings.TrimRight(line.String(), " "), width))
+		b.WriteByte('\n')
+	}
+	_, err := io.WriteString(t.w, b.String())
+
+	return err
+}
+
+// cellReplacer replaces the tabs and newlines of the cells, which would break the rows, by spaces.
+var cellReplacer = strings.NewReplacer("\t", " ", "\n", " ")
+
+// truncateLine cuts s to width runes, ending with an ellipsis if it was cut.
+func truncateLine(s string, width int) string {
+	if utf8.RuneCountInString(s) <= width {
+		return s
+	}
+
+	runes := []rune(s)
+
+	return string(runes[:width-1]) + "…"
+}
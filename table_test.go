@@ -0,0 +1,46 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+func TestTable(t *testing.T) {
+	t.Setenv(subcommandsutil.ColumnsEnv, "40")
+
+	tests := map[string]struct {
+		opts   []subcommandsutil.TableOption
+		golden string
+	}{
+		"when stdout is a terminal": {
+			opts:   []subcommandsutil.TableOption{subcommandsutil.WithTableTerminal()},
+			golden: "testdata/table_tty.golden",
+		},
+		"when stdout is not a terminal": {
+			golden: "testdata/table.golden",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var stdout testcmd.Buffer
+			ctx := subcommandsutil.WithOutput(context.Background(), &stdout, nil)
+
+			table := subcommandsutil.Table(ctx, tt.opts...)
+			table.Header("NAME", "STATUS", "DESCRIPTION")
+			table.Row("web", "running", "the frontend")
+			table.Row("worker-eu-west", "stopped", "the queue consumer of the european region")
+			table.Row("cron", "", "the scheduled\tjobs")
+			table.Row("db")
+			if err := table.Flush(); err != nil {
+				t.Fatal(err)
+			}
+			testcmd.Golden(t, stdout.String(), tt.golden)
+		})
+	}
+}
@@ -0,0 +1,120 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// ErrTasksRunning is returned by TaskGroup.Close when tasks did not return within its timeout.
+var ErrTasksRunning = errors.New("tasks still running")
+
+// TaskGroup runs background tasks of a command, such as uploaders or log shippers, on their own
+// goroutines. It is created by NewTaskGroup.
+type TaskGroup struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	wg   sync.WaitGroup
+	mu   sync.Mutex
+	errs []error
+
+	removeHook func()
+}
+
+// NewTaskGroup returns a TaskGroup whose tasks run with a context derived from ctx. The context of
+// the tasks is canceled when a task fails, when Close is called, or when ctx is done.
+//
+// When ctx is of a Cancelable execution, its cancellation cancels the tasks and waits for them to
+// return before the command is disposed, so Dispose never races with the tasks. The tasks must
+// therefore return soon after their context is done.
+func NewTaskGroup(ctx context.Context) *TaskGroup {
+	g := &TaskGroup{}
+	g.ctx, g.cancel = context.WithCancel(ctx)
+	g.removeHook = onCancel(ctx, g.stop)
+
+	return g
+}
+
+// Go runs fn with the context of the group on a new goroutine. An error of fn cancels the context
+// of the group, and a panic of fn is returned by Wait as a *PanicError.
+func (g *TaskGroup) Go(fn func(ctx context.Context) error) {
+	g.wg.Add(1)
+
+	go func() {
+		defer g.wg.Done()
+
+		if err := g.run(fn); err != nil {
+			g.mu.Lock()
+			g.errs = append(g.errs, err)
+			g.mu.Unlock()
+			g.cancel()
+		}
+	}()
+}
+
+// run calls fn, converting a panic into a *PanicError.
+func (g *TaskGroup) run(fn func(ctx context.Context) error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &PanicError{Value: r, Stack: debug.Stack()}
+		}
+	}()
+
+	return fn(g.ctx)
+}
+
+// stop cancels the tasks and waits for them to return.
+func (g *TaskGroup) stop() {
+	g.cancel()
+	g.wg.Wait()
+}
+
+// err returns the errors of the tasks joined by errors.Join.
+func (g *TaskGroup) err() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return errors.Join(g.errs...)
+}
+
+// Wait waits for the tasks run by Go to return, and returns their errors joined by errors.Join.
+// The result can be turned into the status of the command with StatusFromError.
+func (g *TaskGroup) Wait() error {
+	g.wg.Wait()
+	g.removeHook()
+	g.cancel()
+
+	return g.err()
+}
+
+// Close cancels the context of the tasks and waits for them to return for at most timeout,
+// measured on the Clock of the context given to NewTaskGroup. It returns the errors of the tasks
+// like Wait, or an error wrapping ErrTasksRunning if some of them are still running.
+func (g *TaskGroup) Close(timeout time.Duration) error {
+	g.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		g.wg.Wait()
+		close(done)
+	}()
+
+	t := ClockFromContext(g.ctx).NewTimer(timeout)
+	defer t.Stop()
+
+	select {
+	case <-done:
+	case <-t.C():
+		return fmt.Errorf("%w after %v", ErrTasksRunning, timeout)
+	}
+	g.removeHook()
+
+	return g.err()
+}
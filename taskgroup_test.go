@@ -0,0 +1,160 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+func TestTaskGroupWait(t *testing.T) {
+	errUpload := subcommandsutil.UsageErrorf("uploading: bad bucket")
+	tests := map[string]struct {
+		fail       bool
+		wantStatus subcommands.ExitStatus
+	}{
+		"when every task succeeds": {
+			wantStatus: subcommands.ExitSuccess,
+		},
+		"when a task fails": {
+			fail:       true,
+			wantStatus: subcommands.ExitUsageError,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			defer testcmd.VerifyNoLeaks(t)
+
+			g := subcommandsutil.NewTaskGroup(context.Background())
+			var canceled int32
+			release := make(chan struct{})
+			g.Go(func(ctx context.Context) error {
+				select {
+				case <-ctx.Done():
+					atomic.StoreInt32(&canceled, 1)
+				case <-release:
+				}
+				return nil
+			})
+			g.Go(func(ctx context.Context) error {
+				if tt.fail {
+					return errUpload
+				}
+				close(release)
+				return nil
+			})
+			err := g.Wait()
+
+			if got := subcommandsutil.StatusFromError(err); got != tt.wantStatus {
+				t.Fatalf("wanted the status %v but got %v of %v", tt.wantStatus, got, err)
+			}
+			if tt.fail && !errors.Is(err, errUpload) {
+				t.Fatalf("wanted the error to hold %v but got %v", errUpload, err)
+			}
+			if got := atomic.LoadInt32(&canceled) == 1; got != tt.fail {
+				t.Fatalf("wanted the other task to be canceled to be %v but got %v", tt.fail, got)
+			}
+		})
+	}
+}
+
+func TestTaskGroupClose(t *testing.T) {
+	tests := map[string]struct {
+		ignoreCancel bool
+		wantErr      error
+	}{
+		"when the tasks return in time": {},
+		"when a task ignores the cancellation": {
+			ignoreCancel: true,
+			wantErr:      subcommandsutil.ErrTasksRunning,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			defer testcmd.VerifyNoLeaks(t)
+
+			clk := testcmd.NewFakeClock(time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC))
+			g := subcommandsutil.NewTaskGroup(subcommandsutil.WithClock(context.Background(), clk))
+			release := make(chan struct{})
+			defer close(release)
+			ignoreCancel := tt.ignoreCancel
+			g.Go(func(ctx context.Context) error {
+				if ignoreCancel {
+					<-release
+					return nil
+				}
+				<-ctx.Done()
+				return nil
+			})
+
+			errc := make(chan error, 1)
+			go func() {
+				errc <- g.Close(time.Second)
+			}()
+			if tt.ignoreCancel {
+				clk.BlockUntil(1)
+				clk.Advance(time.Second)
+			}
+
+			if err := <-errc; !errors.Is(err, tt.wantErr) {
+				t.Fatalf("wanted the error %v but got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestTaskGroupCancelable(t *testing.T) {
+	defer testcmd.VerifyNoLeaks(t)
+
+	var running int32
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	sub := &disposeCheck{
+		Recording: testcmd.NewRecording("ship", testcmd.WithExecute(func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+			g := subcommandsutil.NewTaskGroup(ctx)
+			for i := 0; i < 3; i++ {
+				g.Go(func(ctx context.Context) error {
+					defer atomic.AddInt32(&running, -1)
+					if atomic.AddInt32(&running, 1) == 3 {
+						close(started)
+					}
+					<-ctx.Done()
+					time.Sleep(20 * time.Millisecond) // flushing
+					return nil
+				})
+			}
+			<-release
+			return subcommandsutil.StatusFromError(g.Wait())
+		})),
+	}
+	sub.check = func() {
+		if got := atomic.LoadInt32(&running); got != 0 {
+			t.Errorf("wanted the tasks to return before Dispose but got %d running", got)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan subcommands.ExitStatus, 1)
+	go func() {
+		status, _, _ := testcmd.Run(ctx, subcommandsutil.Cancelable(sub, subcommandsutil.WithLogger(&testcmd.LogRecorder{})))
+		done <- status
+	}()
+	<-started
+	cancel()
+
+	testcmd.AssertStatus(t, <-done, subcommands.ExitFailure)
+	if sub.DisposeCount() != 1 {
+		t.Fatalf("wanted sub to be disposed once but got %d", sub.DisposeCount())
+	}
+}
@@ -0,0 +1,190 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"context"
+	"flag"
+	"os"
+	"strconv"
+
+	"github.com/google/subcommands"
+)
+
+// DoNotTrackEnv is the environment variable disabling the telemetry when set to a value other than
+// a false one, like "1". See https://consoledonottrack.com.
+const DoNotTrackEnv = "DO_NOT_TRACK"
+
+// telemetryKey is the context key of the telemetry decision.
+type telemetryKey struct{}
+
+// telemetryDecision is the telemetry decision of an execution, and its source.
+type telemetryDecision struct {
+	enabled bool
+	source  string
+}
+
+// TelemetryEnabled reports whether the execution of ctx may send telemetry over the network: the
+// metrics, traces and events of the exporters, as opposed to the local logs. It is the decision of
+// the innermost Telemetry wrapper, or, outside of one, whether DoNotTrackEnv is unset.
+func TelemetryEnabled(ctx context.Context) bool {
+	if d, ok := ctx.Value(telemetryKey{}).(telemetryDecision); ok {
+		return d.enabled
+	}
+
+	return !envDisablesTelemetry(DoNotTrackEnv)
+}
+
+// envDisablesTelemetry reports whether the environment variable name disables the telemetry.
+func envDisablesTelemetry(name string) bool {
+	v := os.Getenv(name)
+	if v == "" {
+		return false
+	}
+	enabled, err := strconv.ParseBool(v)
+
+	return err != nil || enabled
+}
+
+// TelemetryOption is an option of the Telemetry wrapper.
+type TelemetryOption interface {
+	applyTelemetry(*telemetry)
+}
+
+// telemetryOptionFunc is a TelemetryOption implemented by a function.
+type telemetryOptionFunc func(*telemetry)
+
+// applyTelemetry implements TelemetryOption.
+func (fn telemetryOptionFunc) applyTelemetry(c *telemetry) { fn(c) }
+
+// applyTelemetry implements TelemetryOption.
+func (o LoggerOption) applyTelemetry(c *telemetry) {
+	c.logger = o.logger
+}
+
+// WithTelemetryEnv makes the environment variable name, such as "MYTOOL_NO_TELEMETRY", disable the
+// telemetry like DoNotTrackEnv.
+func WithTelemetryEnv(name string) TelemetryOption {
+	return telemetryOptionFunc(func(c *telemetry) {
+		c.envs = append(c.envs, name)
+	})
+}
+
+// WithTelemetryConfig makes config decide the telemetry when neither the flag nor the environment
+// disables it. It returns the value of the telemetry key of the configuration file, and whether it
+// is set.
+func WithTelemetryConfig(config func() (enabled, ok bool)) TelemetryOption {
+	return telemetryOptionFunc(func(c *telemetry) {
+		c.config = config
+	})
+}
+
+// WithTelemetryEnabled makes the telemetry enabled or disabled whatever the flag, the environment
+// and the configuration are set to, such as in tests.
+func WithTelemetryEnabled(enabled bool) TelemetryOption {
+	return telemetryOptionFunc(func(c *telemetry) {
+		c.override = &enabled
+	})
+}
+
+// telemetry wraps a subcommands.Command so that its telemetry can be disabled.
+type telemetry struct {
+	sub      subcommands.Command
+	logger   Logger
+	envs     []string
+	config   func() (enabled, ok bool)
+	override *bool
+
+	disabled bool
+}
+
+// make sure telemetry implements the subcommands.Command interface.
+var _ subcommands.Command = (*telemetry)(nil)
+
+// Telemetry wraps sub with the -no-telemetry flag, and decides once per execution whether it may
+// send telemetry, as reported by TelemetryEnabled. It is enabled unless disabled by, in order of
+// precedence:
+//
+//   - WithTelemetryEnabled.
+//   - The -no-telemetry flag.
+//   - DoNotTrackEnv, or an environment variable of WithTelemetryEnv, set to a value other than a
+//     false one.
+//   - The configuration of WithTelemetryConfig.
+//
+// The subscribers of Events registered by SubscribeTelemetry, like the MetricsSubscriber of a
+// StatsD, are not delivered the events of an execution without telemetry, while the others, like
+// the logs, are. The decision and its source are logged to the Logger of WithLogger, if given.
+func Telemetry(sub subcommands.Command, opts ...TelemetryOption) subcommands.Command {
+	c := &telemetry{
+		sub:  sub,
+		envs: []string{DoNotTrackEnv},
+	}
+	for _, opt := range opts {
+		opt.applyTelemetry(c)
+	}
+
+	return c
+}
+
+// Name forwards to the underlying c.sub Command.
+func (c *telemetry) Name() string {
+	return c.sub.Name()
+}
+
+// Usage forwards to the underlying c.sub Command.
+func (c *telemetry) Usage() string {
+	return c.sub.Usage()
+}
+
+// Synopsis forwards to the underlying c.sub Command.
+func (c *telemetry) Synopsis() string {
+	return c.sub.Synopsis()
+}
+
+// Unwrap returns the underlying c.sub Command.
+func (c *telemetry) Unwrap() subcommands.Command {
+	return c.sub
+}
+
+// SetFlags forwards to the underlying c.sub Command and registers the -no-telemetry flag.
+func (c *telemetry) SetFlags(f *flag.FlagSet) {
+	c.sub.SetFlags(f)
+	f.BoolVar(&c.disabled, "no-telemetry", false, "do not send telemetry over the network")
+}
+
+// Execute forwards to the underlying c.sub Command with the telemetry decision.
+func (c *telemetry) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	d := c.decide()
+	if c.logger != nil {
+		state := "enabled"
+		if !d.enabled {
+			state = "disabled"
+		}
+		contextLogger(ctx, c.logger).Printf("%s: telemetry %s by %s", c.sub.Name(), state, d.source)
+	}
+
+	return c.sub.Execute(context.WithValue(ctx, telemetryKey{}, d), f, args...)
+}
+
+// decide returns the telemetry decision of an execution.
+func (c *telemetry) decide() telemetryDecision {
+	if c.override != nil {
+		return telemetryDecision{enabled: *c.override, source: "option"}
+	}
+	if c.disabled {
+		return telemetryDecision{enabled: false, source: "-no-telemetry"}
+	}
+	for _, name := range c.envs {
+		if envDisablesTelemetry(name) {
+			return telemetryDecision{enabled: false, source: name}
+		}
+	}
+	if c.config != nil {
+		if enabled, ok := c.config(); ok {
+			return telemetryDecision{enabled: enabled, source: "config"}
+		}
+	}
+
+	return telemetryDecision{enabled: true, source: "default"}
+}
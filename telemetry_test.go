@@ -0,0 +1,116 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"context"
+	"flag"
+	"reflect"
+	"testing"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+func TestTelemetry(t *testing.T) {
+	tests := map[string]struct {
+		env         map[string]string
+		args        []string
+		opts        []subcommandsutil.TelemetryOption
+		wantEnabled bool
+		wantLog     string
+	}{
+		"when nothing disables it": {
+			wantEnabled: true,
+			wantLog:     "report: telemetry enabled by default",
+		},
+		"when the -no-telemetry flag is set": {
+			args:    []string{"-no-telemetry"},
+			wantLog: "report: telemetry disabled by -no-telemetry",
+		},
+		"when DO_NOT_TRACK is set": {
+			env:     map[string]string{subcommandsutil.DoNotTrackEnv: "1"},
+			wantLog: "report: telemetry disabled by DO_NOT_TRACK",
+		},
+		"when DO_NOT_TRACK is false": {
+			env:         map[string]string{subcommandsutil.DoNotTrackEnv: "0"},
+			wantEnabled: true,
+			wantLog:     "report: telemetry enabled by default",
+		},
+		"when the environment variable of the tool is set": {
+			env:     map[string]string{"MYTOOL_NO_TELEMETRY": "true"},
+			opts:    []subcommandsutil.TelemetryOption{subcommandsutil.WithTelemetryEnv("MYTOOL_NO_TELEMETRY")},
+			wantLog: "report: telemetry disabled by MYTOOL_NO_TELEMETRY",
+		},
+		"when the configuration disables it": {
+			opts:    []subcommandsutil.TelemetryOption{subcommandsutil.WithTelemetryConfig(func() (bool, bool) { return false, true })},
+			wantLog: "report: telemetry disabled by config",
+		},
+		"when the configuration does not set it": {
+			opts:        []subcommandsutil.TelemetryOption{subcommandsutil.WithTelemetryConfig(func() (bool, bool) { return false, false })},
+			wantEnabled: true,
+			wantLog:     "report: telemetry enabled by default",
+		},
+		"when the option overrides the flag": {
+			args:        []string{"-no-telemetry"},
+			opts:        []subcommandsutil.TelemetryOption{subcommandsutil.WithTelemetryEnabled(true)},
+			wantEnabled: true,
+			wantLog:     "report: telemetry enabled by option",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv(subcommandsutil.DoNotTrackEnv, "")
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			var enabled bool
+			sub := testcmd.NewRecording("report", testcmd.WithExecute(func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+				enabled = subcommandsutil.TelemetryEnabled(ctx)
+				return subcommands.ExitSuccess
+			}))
+			var local, remote []string
+			bus := subcommandsutil.NewEvents()
+			bus.Subscribe(func(ev subcommandsutil.Event) { local = append(local, describe(ev)) })
+			bus.SubscribeTelemetry(func(ev subcommandsutil.Event) { remote = append(remote, describe(ev)) })
+			logs := &testcmd.LogRecorder{}
+			cmd := subcommandsutil.Telemetry(subcommandsutil.Cancelable(sub), append(tt.opts, subcommandsutil.WithLogger(logs))...)
+
+			status, _, _ := testcmd.Run(subcommandsutil.WithEvents(context.Background(), bus), cmd, tt.args...)
+			testcmd.RequireSuccess(t, status)
+			if enabled != tt.wantEnabled {
+				t.Fatalf("wanted the telemetry enabled %t but got %t", tt.wantEnabled, enabled)
+			}
+			if want := []string{tt.wantLog}; !reflect.DeepEqual(logs.Lines(), want) {
+				t.Fatalf("wanted the logs %q but got %q", want, logs.Lines())
+			}
+
+			want := []string{"ExecutionStarted(report)", "ExecutionFinished(report, 0, canceled=false)"}
+			if !reflect.DeepEqual(local, want) {
+				t.Fatalf("wanted the local events %q but got %q", want, local)
+			}
+			if !tt.wantEnabled {
+				want = nil
+			}
+			if !reflect.DeepEqual(remote, want) {
+				t.Fatalf("wanted the telemetry events %q but got %q", want, remote)
+			}
+		})
+	}
+}
+
+func TestTelemetryEnabledOutsideTelemetry(t *testing.T) {
+	t.Setenv(subcommandsutil.DoNotTrackEnv, "")
+	if !subcommandsutil.TelemetryEnabled(context.Background()) {
+		t.Fatal("wanted the telemetry enabled by default")
+	}
+
+	t.Setenv(subcommandsutil.DoNotTrackEnv, "1")
+	if subcommandsutil.TelemetryEnabled(context.Background()) {
+		t.Fatal("wanted the telemetry disabled by DO_NOT_TRACK")
+	}
+}
@@ -0,0 +1,165 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/google/subcommands"
+)
+
+// tempDirKey is the context key of the temporary directory.
+type tempDirKey struct{}
+
+// WithTempDir returns a copy of ctx whose temporary directory is dir.
+func WithTempDir(ctx context.Context, dir string) context.Context {
+	return context.WithValue(ctx, tempDirKey{}, dir)
+}
+
+// TempDir returns the temporary directory of the execution of ctx: the directory created by
+// TempDirCommand, or os.TempDir. Commands create their scratch files in it.
+func TempDir(ctx context.Context) string {
+	if dir, ok := ctx.Value(tempDirKey{}).(string); ok {
+		return dir
+	}
+
+	return os.TempDir()
+}
+
+// TempDirOption is an option of the TempDirCommand wrapper.
+type TempDirOption interface {
+	applyTempDir(*tempDir)
+}
+
+// tempDirOptionFunc is a TempDirOption implemented by a function.
+type tempDirOptionFunc func(*tempDir)
+
+// applyTempDir implements TempDirOption.
+func (fn tempDirOptionFunc) applyTempDir(c *tempDir) { fn(c) }
+
+// applyTempDir implements TempDirOption.
+func (o LoggerOption) applyTempDir(c *tempDir) {
+	c.logger = o.logger
+}
+
+// WithTempRoot sets the directory TempDirCommand creates the temporary directories in. It defaults
+// to os.TempDir.
+func WithTempRoot(root string) TempDirOption {
+	return tempDirOptionFunc(func(c *tempDir) {
+		c.root = root
+	})
+}
+
+// tempDir wraps a CancelableCommand so that it executes with a temporary directory of its own.
+type tempDir struct {
+	sub    CancelableCommand
+	root   string
+	logger Logger
+
+	keep      bool
+	executing executing
+
+	mu     sync.Mutex
+	dir    string // the temporary directory of the running execution
+	logCtx context.Context
+}
+
+// make sure tempDir implements the CancelableCommand interface.
+var _ CancelableCommand = (*tempDir)(nil)
+
+// TempDirCommand wraps sub so that each execution has a new temporary directory, returned by the
+// TempDir of its context, which is removed with its content when sub returns or panics, or by
+// Dispose when a Cancelable wrapper stops waiting for sub; Dispose also forwards to the Dispose
+// method of sub. The failures to remove it are logged, and do not change the exit status.
+//
+// The -keep-temp flag registered by the wrapper keeps the directory, whose path is printed to
+// Stderr, for debugging.
+func TempDirCommand(sub CancelableCommand, opts ...TempDirOption) CancelableCommand {
+	c := &tempDir{
+		sub:    sub,
+		logger: stdLogger{},
+	}
+	for _, opt := range opts {
+		opt.applyTempDir(c)
+	}
+
+	return c
+}
+
+// Name forwards to the underlying c.sub Command.
+func (c *tempDir) Name() string {
+	return c.sub.Name()
+}
+
+// Usage forwards to the underlying c.sub Command.
+func (c *tempDir) Usage() string {
+	return c.sub.Usage()
+}
+
+// Synopsis forwards to the underlying c.sub Command.
+func (c *tempDir) Synopsis() string {
+	return c.sub.Synopsis()
+}
+
+// Unwrap returns the underlying c.sub Command.
+func (c *tempDir) Unwrap() subcommands.Command {
+	return c.sub
+}
+
+// SetFlags forwards to the underlying c.sub Command and registers the -keep-temp flag.
+func (c *tempDir) SetFlags(f *flag.FlagSet) {
+	c.sub.SetFlags(f)
+	f.BoolVar(&c.keep, "keep-temp", false, "keep the temporary directory and print its path")
+}
+
+// Dispose removes the temporary directory of the running execution and forwards to the underlying
+// c.sub Command.
+func (c *tempDir) Dispose() error {
+	c.remove()
+
+	return c.sub.Dispose()
+}
+
+// Execute creates the temporary directory and forwards to the underlying c.sub Command.
+func (c *tempDir) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	if !c.executing.enter(ctx, c.logger, c.sub.Name()) {
+		return subcommands.ExitFailure
+	}
+	defer c.executing.exit()
+
+	dir, err := os.MkdirTemp(c.root, c.sub.Name()+"-*")
+	if err != nil {
+		fmt.Fprintf(Stderr(ctx), "%s: %v\n", c.sub.Name(), err)
+		return subcommands.ExitFailure
+	}
+	if c.keep {
+		fmt.Fprintf(Stderr(ctx), "%s: keeping the temporary directory %s\n", c.sub.Name(), dir)
+	} else {
+		c.mu.Lock()
+		c.dir, c.logCtx = dir, ctx
+		c.mu.Unlock()
+		defer c.remove() // also when sub panics
+	}
+
+	return c.sub.Execute(WithTempDir(ctx, dir), f, args...)
+}
+
+// remove removes the temporary directory of the running execution, once, logging the failure.
+func (c *tempDir) remove() {
+	c.mu.Lock()
+	dir, ctx := c.dir, c.logCtx
+	c.dir, c.logCtx = "", nil
+	c.mu.Unlock()
+	if dir == "" {
+		return
+	}
+
+	if err := os.RemoveAll(dir); err != nil {
+		contextLogger(ctx, c.logger).Printf("%s: removing the temporary directory: %v", c.sub.Name(), err)
+	}
+}
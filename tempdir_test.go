@@ -0,0 +1,140 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"context"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+// scratchWriter returns a command writing a scratch file to its TempDir, whose path is stored to
+// dir, and returning status or panicking with panicValue.
+func scratchWriter(dir *string, status subcommands.ExitStatus, panicValue interface{}) *testcmd.Recording {
+	return testcmd.NewRecording("build", testcmd.WithExecute(func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+		*dir = subcommandsutil.TempDir(ctx)
+		if err := os.WriteFile(filepath.Join(*dir, "scratch"), []byte("scratch"), 0o644); err != nil {
+			return subcommands.ExitFailure
+		}
+		if panicValue != nil {
+			panic(panicValue)
+		}
+		return status
+	}))
+}
+
+func TestTempDirCommand(t *testing.T) {
+	tests := map[string]struct {
+		status     subcommands.ExitStatus
+		panics     bool
+		args       []string
+		wantKept   bool
+		wantStderr bool
+	}{
+		"when the command succeeds": {},
+		"when the command fails": {
+			status: subcommands.ExitFailure,
+		},
+		"when the command panics": {
+			panics: true,
+		},
+		"when -keep-temp is set": {
+			args:       []string{"-keep-temp"},
+			wantKept:   true,
+			wantStderr: true,
+		},
+	}
+	for name, tt := range tests {
+		tt := tt
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			root := t.TempDir()
+			var dir string
+			var panicValue interface{}
+			if tt.panics {
+				panicValue = "boom"
+			}
+			cmd := subcommandsutil.TempDirCommand(scratchWriter(&dir, tt.status, panicValue), subcommandsutil.WithTempRoot(root))
+
+			var stderr testcmd.Buffer
+			ctx := subcommandsutil.WithOutput(context.Background(), &testcmd.Buffer{}, &stderr)
+			status := func() subcommands.ExitStatus {
+				defer func() {
+					if r := recover(); r != panicValue {
+						t.Fatalf("wanted the panic %v but got %v", panicValue, r)
+					}
+				}()
+				status, _, _ := testcmd.Run(ctx, cmd, tt.args...)
+				return status
+			}()
+			if !tt.panics {
+				testcmd.AssertStatus(t, status, tt.status)
+			}
+
+			if filepath.Dir(dir) != root || !strings.HasPrefix(filepath.Base(dir), "build-") {
+				t.Fatalf("wanted a temporary directory named after build in %s but got %q", root, dir)
+			}
+			_, err := os.Stat(filepath.Join(dir, "scratch"))
+			if tt.wantKept && err != nil {
+				t.Fatalf("wanted the temporary directory kept but got %v", err)
+			}
+			if !tt.wantKept && !os.IsNotExist(err) {
+				t.Fatalf("wanted the temporary directory removed but got %v", err)
+			}
+			wantStderr := ""
+			if tt.wantStderr {
+				wantStderr = "build: keeping the temporary directory " + dir + "\n"
+			}
+			if stderr.String() != wantStderr {
+				t.Fatalf("wanted stderr %q but got %q", wantStderr, stderr.String())
+			}
+		})
+	}
+}
+
+func TestTempDirCommandCanceled(t *testing.T) {
+	defer testcmd.VerifyNoLeaks(t)
+
+	started, release := make(chan string), make(chan struct{})
+	sub := testcmd.NewRecording("build", testcmd.WithExecute(func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+		started <- subcommandsutil.TempDir(ctx)
+		<-release
+		return subcommands.ExitSuccess
+	}))
+	root := t.TempDir()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	dirc := make(chan string, 1)
+	go func() {
+		dirc <- <-started
+		cancel()
+	}()
+	cmd := subcommandsutil.Cancelable(subcommandsutil.TempDirCommand(sub, subcommandsutil.WithTempRoot(root)), subcommandsutil.WithLogger(&testcmd.LogRecorder{}))
+	status, _, _ := testcmd.Run(ctx, cmd)
+	testcmd.AssertStatus(t, status, subcommands.ExitFailure)
+
+	dir := <-dirc
+	if names := dirEntries(t, root); len(names) != 0 {
+		t.Fatalf("wanted the temporary directory %s removed by Dispose but got %q", dir, names)
+	}
+	close(release)
+}
+
+func TestTempDir(t *testing.T) {
+	if got := subcommandsutil.TempDir(context.Background()); got != os.TempDir() {
+		t.Fatalf("wanted the temporary directory %s but got %s", os.TempDir(), got)
+	}
+	if got := subcommandsutil.TempDir(subcommandsutil.WithTempDir(context.Background(), "/scratch")); got != "/scratch" {
+		t.Fatalf("wanted the temporary directory /scratch but got %s", got)
+	}
+}
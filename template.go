@@ -0,0 +1,304 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/google/subcommands"
+)
+
+// DefaultUsageTemplate is the usage template rendering the usage of a command followed by the
+// paragraphs of the details of a Detailer, by its visible flags in the format of PrintDefaults,
+// under the titles of their groups, and by an "Examples:" section listing the examples of an
+// Exampler.
+const DefaultUsageTemplate = `{{.Usage}}{{with .Details}}
+{{paragraphs $.Width .}}
+
+{{end}}{{range .FlagGroups}}{{with .Title}}
+{{.}}:
+{{end}}{{range .Flags}}{{.Line}}
+{{end}}{{end}}{{with .Examples}}
+Examples:
+{{range .}}{{with .Description}}  {{.}}:
+{{end}}{{indent 4 .Command}}
+{{end}}{{end}}`
+
+// UsageData is the data a usage template is executed with.
+type UsageData struct {
+	// Name is the name of the command.
+	Name string
+
+	// Synopsis is the synopsis of the command.
+	Synopsis string
+
+	// Usage is the usage message of the command.
+	Usage string
+
+	// Details is the long description of the command provided by a Detailer.
+	Details string
+
+	// Args renders the ArgsSpec of the command, like "SRC [DST]", or is empty if the command does
+	// not declare one.
+	Args string
+
+	// Flags are the flags of the command in lexical order, aliases excluded.
+	Flags []FlagData
+
+	// FlagGroups are the visible flags of the command in the sections of the groups registered by
+	// FlagGroup, or in a single untitled section if there is none.
+	FlagGroups []FlagGroupData
+
+	// Examples are the examples of the command declared by an Exampler.
+	Examples []Example
+
+	// Width is the width the usage is wrapped to, as returned by TerminalWidth.
+	Width int
+}
+
+// FlagData describes a flag in UsageData.
+type FlagData struct {
+	// Name is the name of the flag.
+	Name string
+
+	// Aliases are the aliases of the flag registered by AliasFlag, shortest first.
+	Aliases []string
+
+	// ValueName is the name of the value of the flag, empty for boolean flags.
+	ValueName string
+
+	// Usage is the usage message of the flag, with the backquotes of the value name removed.
+	Usage string
+
+	// Default is the default value of the flag, or empty if it is the zero value or the flag is
+	// sensitive.
+	Default string
+
+	// Hidden reports whether the flag is hidden by HideFlags, or deprecated by DeprecateFlag.
+	Hidden bool
+
+	// Deprecated is the name of the flag replacing a flag deprecated by DeprecateFlag.
+	Deprecated string
+
+	// Sensitive reports whether the flag is marked by MarkSensitive.
+	Sensitive bool
+
+	// Group is the title of the group the flag was put in by FlagGroup, or empty.
+	Group string
+
+	// Line is the flag rendered in the format of PrintDefaults, wrapped to the Width of the
+	// UsageData, without the trailing newline.
+	Line string
+}
+
+// FlagGroupData is a section of the flags in UsageData.
+type FlagGroupData struct {
+	// Title is the title of the group registered by FlagGroup, DefaultFlagGroupTitle for the flags
+	// in no group, or empty if the command has no group.
+	Title string
+
+	// Flags are the visible flags of the group in lexical order.
+	Flags []FlagData
+}
+
+// Example is an example of the use of a command.
+type Example struct {
+	// Description describes what the example does.
+	Description string `json:"description"`
+
+	// Command is the example command line.
+	Command string `json:"command"`
+}
+
+// Exampler is implemented by a Command providing examples for its usage.
+type Exampler interface {
+	Examples() []Example
+}
+
+// usageFuncs are the functions available to usage templates.
+var usageFuncs = template.FuncMap{
+	"join": strings.Join,
+	"indent": func(n int, s string) string {
+		pad := strings.Repeat(" ", n)
+		return pad + strings.ReplaceAll(s, "\n", "\n"+pad)
+	},
+	"wrap": func(width int, s string) string {
+		return strings.Join(wrapText(s, width), "\n")
+	},
+	"paragraphs": func(width int, s string) string {
+		return wrapParagraphs(s, width)
+	},
+}
+
+var (
+	usageTemplateMu sync.Mutex
+	usageTemplate   = template.Must(template.New("usage").Funcs(usageFuncs).Parse(DefaultUsageTemplate))
+)
+
+// SetUsageTemplate sets the text/template rendering the usage of commands in ExplainCommand,
+// executed with a UsageData. Besides the builtin functions, templates can call join, like
+// strings.Join, and "indent N", "wrap WIDTH" and "paragraphs WIDTH", wrapping each paragraph, on a
+// string. SetUsageTemplate panics if tmpl cannot be parsed. DefaultUsageTemplate restores the
+// default rendering.
+func SetUsageTemplate(tmpl string) {
+	t := template.Must(template.New("usage").Funcs(usageFuncs).Parse(tmpl))
+
+	usageTemplateMu.Lock()
+	defer usageTemplateMu.Unlock()
+
+	usageTemplate = t
+}
+
+// renderUsage renders the usage of cmd to w with the usage template.
+func renderUsage(w io.Writer, cmd subcommands.Command) {
+	usageTemplateMu.Lock()
+	t := usageTemplate
+	usageTemplateMu.Unlock()
+
+	if err := t.Execute(w, newUsageData(cmd, TerminalWidth(w))); err != nil {
+		fmt.Fprintf(w, "subcommandsutil: rendering the usage of %s: %v\n", cmd.Name(), err)
+	}
+}
+
+// NewUsageData returns the UsageData of cmd, wrapped to DefaultWidth.
+func NewUsageData(cmd subcommands.Command) UsageData {
+	return newUsageData(cmd, DefaultWidth)
+}
+
+// newUsageData returns the UsageData of cmd, wrapped to width, with its synopsis and usage
+// translated by the Messages set by SetMessages.
+func newUsageData(cmd subcommands.Command, width int) UsageData {
+	m := defaultMessagesCatalog()
+	d := UsageData{
+		Name:     cmd.Name(),
+		Synopsis: m.translate(cmd.Synopsis()),
+		Usage:    m.translate(cmd.Usage()),
+		Width:    width,
+	}
+	if spec, ok := ArgsSpecOf(cmd); ok {
+		d.Args = spec.String()
+	}
+	walkCommand(cmd, func(cmd subcommands.Command) bool {
+		e, ok := cmd.(Exampler)
+		if ok {
+			d.Examples = e.Examples()
+		}
+		return ok
+	})
+	walkCommand(cmd, func(cmd subcommands.Command) bool {
+		e, ok := cmd.(Detailer)
+		if ok {
+			d.Details = m.translate(e.Details())
+		}
+		return ok
+	})
+
+	f := flag.NewFlagSet(cmd.Name(), flag.PanicOnError)
+	f.SetOutput(io.Discard)
+	cmd.SetFlags(f)
+	var visible []*flag.Flag
+	var visibleData []FlagData
+	f.VisitAll(func(fl *flag.Flag) {
+		if isAliasFlag(f, fl.Name) {
+			return
+		}
+		fd := newFlagData(f, fl, width)
+		d.Flags = append(d.Flags, fd)
+		if !fd.Hidden {
+			visible, visibleData = append(visible, fl), append(visibleData, fd)
+		}
+	})
+	for _, s := range flagSections(f, visible, visibleData) {
+		d.FlagGroups = append(d.FlagGroups, FlagGroupData{Title: s.title, Flags: s.items})
+	}
+
+	return d
+}
+
+// newFlagData returns the FlagData of fl in f, with its Line wrapped to width.
+func newFlagData(f *flag.FlagSet, fl *flag.Flag, width int) FlagData {
+	names := flagNames(f, fl)
+	aliases := make([]string, 0, len(names)-1)
+	for _, name := range names {
+		if name != fl.Name {
+			aliases = append(aliases, name)
+		}
+	}
+
+	valueName, usage := flag.UnquoteUsage(fl)
+	sensitive := IsSensitiveFlag(f, fl.Name)
+	d := FlagData{
+		Name:      fl.Name,
+		Aliases:   aliases,
+		ValueName: valueName,
+		Usage:     usage,
+		Hidden:    IsHiddenFlag(f, fl.Name),
+		Sensitive: sensitive,
+		Line:      formatFlag(fl, names, sensitive, width),
+	}
+	if !sensitive && !isZeroValue(fl) {
+		d.Default = fl.DefValue
+	}
+	readFlagMeta(f, func(m *flagMeta) {
+		if m != nil {
+			d.Deprecated = m.deprecated[fl.Name]
+			d.Group = m.group[fl.Name]
+		}
+	})
+
+	return d
+}
+
+// templatedUsage wraps a subcommands.Command so that its usage is rendered by the usage template.
+type templatedUsage struct {
+	sub subcommands.Command
+}
+
+// make sure templatedUsage implements the subcommands.Command interface.
+var _ subcommands.Command = (*templatedUsage)(nil)
+
+// TemplatedUsage wraps sub so that the Usage func of its FlagSet, called on a flag parsing error,
+// renders the usage with the template set by SetUsageTemplate.
+func TemplatedUsage(sub subcommands.Command) subcommands.Command {
+	return &templatedUsage{
+		sub: sub,
+	}
+}
+
+// Name forwards to the underlying c.sub Command.
+func (c *templatedUsage) Name() string {
+	return c.sub.Name()
+}
+
+// Usage forwards to the underlying c.sub Command.
+func (c *templatedUsage) Usage() string {
+	return c.sub.Usage()
+}
+
+// Synopsis forwards to the underlying c.sub Command.
+func (c *templatedUsage) Synopsis() string {
+	return c.sub.Synopsis()
+}
+
+// Unwrap returns the underlying c.sub Command.
+func (c *templatedUsage) Unwrap() subcommands.Command {
+	return c.sub
+}
+
+// SetFlags forwards to the underlying c.sub Command and overrides the Usage func of f.
+func (c *templatedUsage) SetFlags(f *flag.FlagSet) {
+	c.sub.SetFlags(f)
+	f.Usage = func() { renderUsage(f.Output(), c) }
+}
+
+// Execute forwards to the underlying c.sub Command.
+func (c *templatedUsage) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	return c.sub.Execute(ctx, f, args...)
+}
@@ -0,0 +1,128 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"strings"
+	"testing"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+// styledUsageTemplate is a usage template with sections, examples and wrapped descriptions.
+const styledUsageTemplate = `NAME
+  {{.Name}} - {{.Synopsis}}
+
+USAGE
+  {{.Name}} [flags]{{with .Args}} {{.}}{{end}}
+
+FLAGS
+{{- range .Flags}}{{if not .Hidden}}
+  -{{.Name}}{{range .Aliases}}, -{{.}}{{end}}{{with .ValueName}} {{.}}{{end}}
+{{indent 6 (wrap 40 .Usage)}}{{with .Default}} (default {{.}}){{end}}
+{{- if .Sensitive}} (sensitive){{end}}
+{{- end}}{{end}}
+{{- with .Examples}}
+
+EXAMPLES
+{{- range .}}
+  # {{.Description}}
+  {{.Command}}
+{{- end}}{{end}}
+`
+
+// exampled is a Recording providing examples.
+type exampled struct {
+	*testcmd.Recording
+}
+
+// Examples implements subcommandsutil.Exampler.
+func (exampled) Examples() []subcommandsutil.Example {
+	return []subcommandsutil.Example{
+		{Description: "copy a file", Command: "cp a.txt b.txt"},
+		{Description: "copy recursively", Command: "cp -r src dst"},
+	}
+}
+
+// newCopyCommand returns a sample command with flags of every kind of metadata.
+func newCopyCommand() subcommands.Command {
+	return subcommandsutil.WithArgs(exampled{testcmd.NewRecording("cp",
+		testcmd.WithSynopsis("copy files"),
+		testcmd.WithUsage("cp [-r] SRC DST:\n  Copy SRC to DST.\n"),
+		testcmd.WithFlags(func(f *flag.FlagSet) {
+			f.Bool("recursive", false, "copy directories recursively, descending into each of their subdirectories")
+			subcommandsutil.AliasFlag(f, "recursive", "r")
+			f.String("mode", "0644", "the file `mode` of the copies")
+			f.String("token", "s3cr3t", "the access token")
+			subcommandsutil.MarkSensitive(f, "token")
+			f.Bool("debug", false, "debug output")
+			subcommandsutil.HideFlags(f, "debug")
+			subcommandsutil.DeprecateFlag(f, "rec", "recursive", "")
+		}),
+	)}, subcommandsutil.ArgsSpec{Min: 2, Max: 2, Names: []string{"SRC", "DST"}})
+}
+
+func TestSetUsageTemplate(t *testing.T) {
+	defer subcommandsutil.SetUsageTemplate(subcommandsutil.DefaultUsageTemplate)
+	subcommandsutil.SetUsageTemplate(styledUsageTemplate)
+
+	var buf bytes.Buffer
+	subcommandsutil.ExplainCommand(&buf, newCopyCommand())
+	testcmd.Golden(t, buf.String(), "testdata/usage_template.golden")
+}
+
+func TestSetUsageTemplateInvalid(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("wanted a panic on an invalid template but got none")
+		}
+	}()
+	subcommandsutil.SetUsageTemplate("{{.Name")
+}
+
+func TestNewUsageData(t *testing.T) {
+	d := subcommandsutil.NewUsageData(newCopyCommand())
+	if d.Name != "cp" || d.Synopsis != "copy files" || d.Args != "SRC DST" || len(d.Examples) != 2 {
+		t.Fatalf("wanted the command fields but got %+v", d)
+	}
+
+	flags := make(map[string]subcommandsutil.FlagData)
+	for _, fl := range d.Flags {
+		flags[fl.Name] = fl
+	}
+	if _, ok := flags["r"]; ok {
+		t.Fatal("wanted the alias -r excluded from the flags but got it")
+	}
+	if got := flags["recursive"].Aliases; len(got) != 1 || got[0] != "r" {
+		t.Fatalf("wanted the alias r of -recursive but got %q", got)
+	}
+	if got := flags["rec"]; !got.Hidden || got.Deprecated != "recursive" {
+		t.Fatalf("wanted -rec hidden and deprecated for -recursive but got %+v", got)
+	}
+	if got := flags["token"]; !got.Sensitive || got.Default != "" {
+		t.Fatalf("wanted -token sensitive without default but got %+v", got)
+	}
+	if got := flags["mode"]; got.ValueName != "mode" || got.Default != "0644" {
+		t.Fatalf("wanted -mode with value name and default but got %+v", got)
+	}
+}
+
+func TestTemplatedUsage(t *testing.T) {
+	defer subcommandsutil.SetUsageTemplate(subcommandsutil.DefaultUsageTemplate)
+	subcommandsutil.SetUsageTemplate("usage of {{.Name}}{{with .Args}} {{.}}{{end}}\n")
+
+	h := testcmd.NewHarness(t)
+	h.Register(subcommandsutil.TemplatedUsage(newCopyCommand()), "")
+
+	testcmd.AssertStatus(t, h.Execute(context.Background(), "cp", "-unknown"), subcommands.ExitUsageError)
+	if want := "usage of cp SRC DST\n"; !strings.HasSuffix(h.Stderr.String(), want) {
+		t.Fatalf("wanted stderr to end with %q but got %q", want, h.Stderr.String())
+	}
+}
@@ -0,0 +1,48 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// isTerminalFile reports whether f is a terminal.
+func isTerminalFile(f *os.File) bool {
+	var termios syscall.Termios
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), uintptr(syscall.TCGETS), uintptr(unsafe.Pointer(&termios)))
+
+	return errno == 0
+}
+
+// terminalWidthFile returns the width of the terminal f is.
+func terminalWidthFile(f *os.File) (int, bool) {
+	var ws winsize
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), uintptr(syscall.TIOCGWINSZ), uintptr(unsafe.Pointer(&ws)))
+
+	return int(ws.col), errno == 0
+}
+
+// winsize is the window size of a terminal, as struct winsize of ioctl_tty(2).
+type winsize struct {
+	row, col, xpixel, ypixel uint16
+}
+
+// disableEcho turns off the echo of the terminal f. restore turns it back on.
+func disableEcho(f *os.File) (restore func(), ok bool) {
+	var termios syscall.Termios
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), uintptr(syscall.TCGETS), uintptr(unsafe.Pointer(&termios))); errno != 0 {
+		return nil, false
+	}
+	noEcho := termios
+	noEcho.Lflag &^= syscall.ECHO
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), uintptr(syscall.TCSETS), uintptr(unsafe.Pointer(&noEcho))); errno != 0 {
+		return nil, false
+	}
+
+	return func() {
+		syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), uintptr(syscall.TCSETS), uintptr(unsafe.Pointer(&termios)))
+	}, true
+}
@@ -0,0 +1,28 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd && !windows
+
+package subcommandsutil
+
+import (
+	"os"
+)
+
+// isTerminalFile reports whether f is a character device, the closest approximation of a terminal
+// available on this platform.
+func isTerminalFile(f *os.File) bool {
+	info, err := f.Stat()
+
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// terminalWidthFile reports that the width of f is unknown on this platform.
+func terminalWidthFile(f *os.File) (int, bool) {
+	return 0, false
+}
+
+// disableEcho reports that the echo of f cannot be turned off on this platform.
+func disableEcho(f *os.File) (restore func(), ok bool) {
+	return nil, false
+}
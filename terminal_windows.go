@@ -0,0 +1,26 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"os"
+	"syscall"
+)
+
+// isTerminalFile reports whether f is a console.
+func isTerminalFile(f *os.File) bool {
+	var mode uint32
+
+	return syscall.GetConsoleMode(syscall.Handle(f.Fd()), &mode) == nil
+}
+
+// terminalWidthFile reports that the width of f is unknown on this platform.
+func terminalWidthFile(f *os.File) (int, bool) {
+	return 0, false
+}
+
+// disableEcho reports that the echo of f cannot be turned off on this platform.
+func disableEcho(f *os.File) (restore func(), ok bool) {
+	return nil, false
+}
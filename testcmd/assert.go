@@ -0,0 +1,50 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package testcmd
+
+import (
+	"testing"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+)
+
+// AssertStatus reports an error to t if got is not want.
+func AssertStatus(t testing.TB, got, want subcommands.ExitStatus) {
+	t.Helper()
+
+	if got != want {
+		t.Errorf("wanted status %s but got %s", subcommandsutil.StatusString(want), subcommandsutil.StatusString(got))
+	}
+}
+
+// RequireSuccess stops the test if got is not subcommands.ExitSuccess.
+func RequireSuccess(t testing.TB, got subcommands.ExitStatus) {
+	t.Helper()
+
+	if got != subcommands.ExitSuccess {
+		t.Fatalf("wanted status %s but got %s", subcommandsutil.StatusString(subcommands.ExitSuccess), subcommandsutil.StatusString(got))
+	}
+}
+
+// AssertCanceled reports an error to t if the last execution of the Cancelable wrapper found in
+// cmd, looking through any number of wrappers, was not canceled. It stops the test if cmd does not
+// wrap a Cancelable command.
+func AssertCanceled(t testing.TB, cmd subcommands.Command) {
+	t.Helper()
+
+	for ; cmd != nil; cmd = subcommandsutil.Unwrap(cmd) {
+		c, ok := cmd.(interface{ Canceled() bool })
+		if !ok {
+			continue
+		}
+		if !c.Canceled() {
+			t.Errorf("wanted %q to be canceled but it finished", cmd.Name())
+		}
+		return
+	}
+
+	t.Fatalf("wanted a Cancelable command but got none")
+}
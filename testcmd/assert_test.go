@@ -0,0 +1,97 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package testcmd_test
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+// fakeTB records the failures reported through testing.TB instead of failing the test.
+type fakeTB struct {
+	testing.TB
+
+	helper bool
+	errors []string
+	fatals []string
+}
+
+func (tb *fakeTB) Helper() { tb.helper = true }
+
+func (tb *fakeTB) Errorf(format string, args ...interface{}) {
+	tb.errors = append(tb.errors, fmt.Sprintf(format, args...))
+}
+
+func (tb *fakeTB) Fatalf(format string, args ...interface{}) {
+	tb.fatals = append(tb.fatals, fmt.Sprintf(format, args...))
+}
+
+func TestAssertStatus(t *testing.T) {
+	tb := &fakeTB{}
+	testcmd.AssertStatus(tb, subcommands.ExitSuccess, subcommands.ExitSuccess)
+	if len(tb.errors) != 0 {
+		t.Fatalf("wanted no errors but got %q", tb.errors)
+	}
+
+	testcmd.AssertStatus(tb, subcommands.ExitUsageError, subcommands.ExitSuccess)
+	if !tb.helper {
+		t.Fatal("wanted AssertStatus to mark itself as a helper")
+	}
+	want := "wanted status ExitSuccess(0) but got ExitUsageError(2)"
+	if len(tb.errors) != 1 || tb.errors[0] != want {
+		t.Fatalf("wanted error %q but got %q", want, tb.errors)
+	}
+}
+
+func TestRequireSuccess(t *testing.T) {
+	tb := &fakeTB{}
+	testcmd.RequireSuccess(tb, subcommands.ExitSuccess)
+	if len(tb.fatals) != 0 {
+		t.Fatalf("wanted no failures but got %q", tb.fatals)
+	}
+
+	testcmd.RequireSuccess(tb, subcommands.ExitFailure)
+	if !tb.helper {
+		t.Fatal("wanted RequireSuccess to mark itself as a helper")
+	}
+	if len(tb.fatals) != 1 || !strings.Contains(tb.fatals[0], "ExitFailure(1)") {
+		t.Fatalf("wanted a failure naming ExitFailure(1) but got %q", tb.fatals)
+	}
+}
+
+func TestAssertCanceled(t *testing.T) {
+	b := testcmd.NewBlocking("block")
+	defer b.Release(subcommands.ExitSuccess)
+	canceled := subcommandsutil.Timeout(subcommandsutil.Cancelable(b, subcommandsutil.WithLogger(&testcmd.LogRecorder{})), 0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	canceled.Execute(ctx, flag.NewFlagSet("block", flag.ContinueOnError))
+
+	tb := &fakeTB{}
+	testcmd.AssertCanceled(tb, canceled)
+	if len(tb.errors) != 0 || len(tb.fatals) != 0 {
+		t.Fatalf("wanted no failures but got %q %q", tb.errors, tb.fatals)
+	}
+
+	finished := subcommandsutil.Cancelable(testcmd.NewRecording("finish"))
+	finished.Execute(context.Background(), flag.NewFlagSet("finish", flag.ContinueOnError))
+	testcmd.AssertCanceled(tb, finished)
+	if len(tb.errors) != 1 {
+		t.Fatalf("wanted an error for a finished command but got %q", tb.errors)
+	}
+
+	testcmd.AssertCanceled(tb, testcmd.NewRecording("plain"))
+	if len(tb.fatals) != 1 {
+		t.Fatalf("wanted a failure for a command without Cancelable but got %q", tb.fatals)
+	}
+}
@@ -0,0 +1,58 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package testcmd
+
+import (
+	"context"
+	"flag"
+	"sync"
+
+	"github.com/google/subcommands"
+)
+
+// Blocking is a Recording whose Execute blocks, ignoring the context, until Release is called. It
+// makes cancellation tests deterministic.
+type Blocking struct {
+	*Recording
+
+	startOnce   sync.Once
+	started     chan struct{}
+	releaseOnce sync.Once
+	released    chan struct{}
+	status      subcommands.ExitStatus
+}
+
+// NewBlocking returns a new Blocking command named name. opts configure the embedded Recording;
+// WithExecute and WithStatus are overridden by Release.
+func NewBlocking(name string, opts ...Option) *Blocking {
+	b := &Blocking{
+		started:  make(chan struct{}),
+		released: make(chan struct{}),
+	}
+	b.Recording = NewRecording(name, append(opts, WithExecute(b.block))...)
+
+	return b
+}
+
+// block is the execute function of the embedded Recording.
+func (b *Blocking) block(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	b.startOnce.Do(func() { close(b.started) })
+	<-b.released
+
+	return b.status
+}
+
+// Started returns a channel closed when Execute is called for the first time.
+func (b *Blocking) Started() <-chan struct{} {
+	return b.started
+}
+
+// Release unblocks every pending and future call of Execute, which return status. Only the first
+// call of Release has an effect.
+func (b *Blocking) Release(status subcommands.ExitStatus) {
+	b.releaseOnce.Do(func() {
+		b.status = status
+		close(b.released)
+	})
+}
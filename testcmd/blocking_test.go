@@ -0,0 +1,50 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package testcmd_test
+
+import (
+	"context"
+	"flag"
+	"testing"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+func TestBlocking(t *testing.T) {
+	b := testcmd.NewBlocking("block", testcmd.WithSynopsis("blocks"))
+	if b.Synopsis() != "blocks" {
+		t.Fatalf("wanted the options to configure the command, got synopsis %q", b.Synopsis())
+	}
+
+	done := make(chan subcommands.ExitStatus)
+	go func() {
+		done <- b.Execute(context.Background(), flag.NewFlagSet("block", flag.ContinueOnError))
+	}()
+
+	<-b.Started()
+	select {
+	case <-done:
+		t.Fatal("wanted Execute to block until Release")
+	default:
+	}
+	if b.DidFinish() {
+		t.Fatal("wanted the command not to finish before Release")
+	}
+
+	b.Release(subcommands.ExitFailure)
+	if status := <-done; status != subcommands.ExitFailure {
+		t.Fatalf("wanted %v but got %v", subcommands.ExitFailure, status)
+	}
+	if !b.DidFinish() || b.CallCount() != 1 {
+		t.Fatal("wanted one finished call")
+	}
+
+	// released commands return immediately
+	b.Release(subcommands.ExitSuccess)
+	if status := b.Execute(context.Background(), flag.NewFlagSet("block", flag.ContinueOnError)); status != subcommands.ExitFailure {
+		t.Fatalf("wanted the first released status %v but got %v", subcommands.ExitFailure, status)
+	}
+}
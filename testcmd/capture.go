@@ -0,0 +1,101 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package testcmd
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/zchee/subcommandsutil"
+)
+
+// CaptureOutput calls fn with os.Stdout and os.Stderr redirected to pipes, and returns what was
+// written to them. The original files are restored even if fn panics.
+//
+// CaptureOutput replaces process-wide files, so it must not be used by parallel tests. Use
+// CaptureWriters for commands writing to subcommandsutil.Stdout and subcommandsutil.Stderr.
+func CaptureOutput(t *testing.T, fn func()) (stdout, stderr string) {
+	t.Helper()
+
+	outR, outW, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	errR, errW, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var outBuf, errBuf bytes.Buffer
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		io.Copy(&outBuf, outR)
+	}()
+	go func() {
+		defer wg.Done()
+		io.Copy(&errBuf, errR)
+	}()
+
+	origStdout, origStderr := os.Stdout, os.Stderr
+	os.Stdout, os.Stderr = outW, errW
+	defer func() {
+		os.Stdout, os.Stderr = origStdout, origStderr
+		outW.Close()
+		errW.Close()
+		wg.Wait()
+		outR.Close()
+		errR.Close()
+		stdout, stderr = outBuf.String(), errBuf.String()
+	}()
+
+	fn()
+
+	return stdout, stderr
+}
+
+// CaptureWriters calls fn with a copy of ctx whose subcommandsutil.Stdout and
+// subcommandsutil.Stderr writers are buffers, and returns what was written to them. It does not
+// touch the os streams, so it is safe for parallel tests.
+func CaptureWriters(ctx context.Context, fn func(ctx context.Context)) (stdout, stderr string) {
+	var outBuf, errBuf Buffer
+	fn(subcommandsutil.WithOutput(ctx, &outBuf, &errBuf))
+
+	return outBuf.String(), errBuf.String()
+}
+
+// Buffer is a bytes.Buffer safe for concurrent use.
+type Buffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+// Write implements io.Writer.
+func (b *Buffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buf.Write(p)
+}
+
+// String returns the contents of the buffer.
+func (b *Buffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buf.String()
+}
+
+// Reset empties the buffer.
+func (b *Buffer) Reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.buf.Reset()
+}
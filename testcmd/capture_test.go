@@ -0,0 +1,73 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package testcmd_test
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+// newGreeter returns a command greeting its arguments on the context writers.
+func newGreeter() *testcmd.Recording {
+	return testcmd.NewRecording("greet",
+		testcmd.WithExecute(func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+			fmt.Fprintf(subcommandsutil.Stdout(ctx), "hello, %s\n", f.Arg(0))
+			fmt.Fprintln(subcommandsutil.Stderr(ctx), "greeted")
+			return subcommands.ExitSuccess
+		}),
+	)
+}
+
+func TestCaptureOutput(t *testing.T) {
+	stdout, stderr := testcmd.CaptureOutput(t, func() {
+		testcmd.Run(context.Background(), newGreeter(), "gopher")
+	})
+	if stdout != "hello, gopher\n" {
+		t.Fatalf("wanted stdout %q but got %q", "hello, gopher\n", stdout)
+	}
+	if stderr != "greeted\n" {
+		t.Fatalf("wanted stderr %q but got %q", "greeted\n", stderr)
+	}
+}
+
+func TestCaptureOutputPanic(t *testing.T) {
+	origStdout, origStderr := os.Stdout, os.Stderr
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("wanted the panic to propagate")
+			}
+		}()
+		testcmd.CaptureOutput(t, func() {
+			panic("boom")
+		})
+	}()
+
+	if os.Stdout != origStdout || os.Stderr != origStderr {
+		t.Fatal("wanted the os streams to be restored after a panic")
+	}
+}
+
+func TestCaptureWriters(t *testing.T) {
+	t.Parallel()
+
+	stdout, stderr := testcmd.CaptureWriters(context.Background(), func(ctx context.Context) {
+		testcmd.Run(ctx, newGreeter(), "parallel")
+	})
+	if stdout != "hello, parallel\n" {
+		t.Fatalf("wanted stdout %q but got %q", "hello, parallel\n", stdout)
+	}
+	if stderr != "greeted\n" {
+		t.Fatalf("wanted stderr %q but got %q", "greeted\n", stderr)
+	}
+}
@@ -0,0 +1,222 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package testcmd
+
+import (
+	"sync"
+	"time"
+
+	"github.com/zchee/subcommandsutil"
+)
+
+// FakeClock is a subcommandsutil.Clock whose time only moves when it is advanced. It is safe for
+// concurrent use.
+//
+//	clk := testcmd.NewFakeClock(time.Now())
+//	ctx := subcommandsutil.WithClock(context.Background(), clk)
+type FakeClock struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	now     time.Time
+	waiters []*fakeWaiter
+}
+
+// make sure FakeClock implements the subcommandsutil.Clock interface.
+var _ subcommandsutil.Clock = (*FakeClock)(nil)
+
+// NewFakeClock returns a new FakeClock set to now.
+func NewFakeClock(now time.Time) *FakeClock {
+	c := &FakeClock{now: now}
+	c.cond = sync.NewCond(&c.mu)
+
+	return c
+}
+
+// Now implements subcommandsutil.Clock.
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+// NewTimer implements subcommandsutil.Clock.
+func (c *FakeClock) NewTimer(d time.Duration) subcommandsutil.Timer {
+	return c.newWaiter(d, 0)
+}
+
+// NewTicker implements subcommandsutil.Clock.
+func (c *FakeClock) NewTicker(d time.Duration) subcommandsutil.Ticker {
+	if d <= 0 {
+		panic("testcmd: non-positive interval for NewTicker")
+	}
+
+	return fakeTicker{c.newWaiter(d, d)}
+}
+
+// newWaiter creates a timer firing after d, and then every period if period is positive.
+func (c *FakeClock) newWaiter(d, period time.Duration) *fakeWaiter {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	w := &fakeWaiter{
+		clock:  c,
+		c:      make(chan time.Time, 1),
+		period: period,
+	}
+	c.schedule(w, d)
+
+	return w
+}
+
+// schedule activates w to fire after d. c.mu must be held.
+func (c *FakeClock) schedule(w *fakeWaiter, d time.Duration) {
+	w.when = c.now.Add(d)
+	if d <= 0 {
+		w.fire(c.now)
+		if w.period <= 0 {
+			return
+		}
+		w.when = c.now.Add(w.period)
+	}
+	c.waiters = append(c.waiters, w)
+	c.cond.Broadcast()
+}
+
+// unschedule deactivates w. It reports whether w was active. c.mu must be held.
+func (c *FakeClock) unschedule(w *fakeWaiter) bool {
+	for i, ww := range c.waiters {
+		if ww == w {
+			c.waiters = append(c.waiters[:i], c.waiters[i+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
+
+// Advance moves the time of c forward by d, firing the timers and tickers due in the meantime.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	end := c.now.Add(d)
+	for {
+		next := -1
+		for i, w := range c.waiters {
+			if !w.when.After(end) && (next < 0 || w.when.Before(c.waiters[next].when)) {
+				next = i
+			}
+		}
+		if next < 0 {
+			break
+		}
+
+		w := c.waiters[next]
+		c.now = w.when
+		w.fire(c.now)
+		if w.period > 0 {
+			w.when = w.when.Add(w.period)
+		} else {
+			c.waiters = append(c.waiters[:next], c.waiters[next+1:]...)
+		}
+	}
+	c.now = end
+}
+
+// Waiters returns the number of active timers and tickers.
+func (c *FakeClock) Waiters() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.waiters)
+}
+
+// BlockUntil blocks until at least n timers and tickers are active. Tests call it before Advance
+// to make sure the code under test has started waiting.
+func (c *FakeClock) BlockUntil(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for len(c.waiters) < n {
+		c.cond.Wait()
+	}
+}
+
+// fakeWaiter is a timer or ticker of a FakeClock.
+type fakeWaiter struct {
+	clock  *FakeClock
+	c      chan time.Time
+	when   time.Time
+	period time.Duration
+}
+
+// fire sends now on the channel of w unless a previous tick is still pending, like time.Ticker.
+func (w *fakeWaiter) fire(now time.Time) {
+	select {
+	case w.c <- now:
+	default:
+	}
+}
+
+// C implements subcommandsutil.Timer.
+func (w *fakeWaiter) C() <-chan time.Time {
+	return w.c
+}
+
+// Stop implements subcommandsutil.Timer.
+func (w *fakeWaiter) Stop() bool {
+	w.clock.mu.Lock()
+	defer w.clock.mu.Unlock()
+
+	return w.clock.unschedule(w)
+}
+
+// Reset implements subcommandsutil.Timer.
+func (w *fakeWaiter) Reset(d time.Duration) bool {
+	w.clock.mu.Lock()
+	defer w.clock.mu.Unlock()
+
+	active := w.clock.unschedule(w)
+	w.clock.schedule(w, d)
+
+	return active
+}
+
+// fakeTicker is a ticker of a FakeClock.
+type fakeTicker struct {
+	w *fakeWaiter
+}
+
+// C implements subcommandsutil.Ticker.
+func (t fakeTicker) C() <-chan time.Time {
+	return t.w.c
+}
+
+// Stop implements subcommandsutil.Ticker.
+func (t fakeTicker) Stop() {
+	t.w.Stop()
+}
+
+// advanceToNext moves the time of c forward to the earliest active timer or ticker, firing it. It
+// reports whether there was one.
+func (c *FakeClock) advanceToNext() bool {
+	c.mu.Lock()
+	if len(c.waiters) == 0 {
+		c.mu.Unlock()
+		return false
+	}
+	next := c.waiters[0].when
+	for _, w := range c.waiters[1:] {
+		if w.when.Before(next) {
+			next = w.when
+		}
+	}
+	d := next.Sub(c.now)
+	c.mu.Unlock()
+
+	c.Advance(d)
+
+	return true
+}
@@ -0,0 +1,80 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package testcmd_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+func TestFakeClockTimer(t *testing.T) {
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	clk := testcmd.NewFakeClock(start)
+
+	timer := clk.NewTimer(time.Minute)
+	clk.Advance(59 * time.Second)
+	select {
+	case <-timer.C():
+		t.Fatal("wanted the timer not to fire yet")
+	default:
+	}
+
+	clk.Advance(time.Second)
+	select {
+	case now := <-timer.C():
+		if !now.Equal(start.Add(time.Minute)) {
+			t.Fatalf("wanted the timer to fire at %v but got %v", start.Add(time.Minute), now)
+		}
+	default:
+		t.Fatal("wanted the timer to fire")
+	}
+	if clk.Waiters() != 0 {
+		t.Fatal("wanted the fired timer to be inactive")
+	}
+
+	if timer.Reset(time.Second) {
+		t.Fatal("wanted Reset of a fired timer to report it was inactive")
+	}
+	if !timer.Stop() {
+		t.Fatal("wanted Stop of a reset timer to report it was active")
+	}
+	clk.Advance(time.Hour)
+	select {
+	case <-timer.C():
+		t.Fatal("wanted a stopped timer not to fire")
+	default:
+	}
+}
+
+func TestFakeClockTicker(t *testing.T) {
+	clk := testcmd.NewFakeClock(time.Now())
+
+	ticker := clk.NewTicker(10 * time.Second)
+	defer ticker.Stop()
+
+	for i := 0; i < 3; i++ {
+		clk.Advance(10 * time.Second)
+		select {
+		case <-ticker.C():
+		default:
+			t.Fatalf("wanted tick %d", i)
+		}
+	}
+}
+
+func TestFakeClockBlockUntil(t *testing.T) {
+	clk := testcmd.NewFakeClock(time.Now())
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		<-clk.NewTimer(time.Hour).C()
+	}()
+
+	clk.BlockUntil(1)
+	clk.Advance(time.Hour)
+	<-done
+}
@@ -0,0 +1,117 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package testcmd
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/zchee/subcommandsutil"
+)
+
+// DisposeCall is a recorded call of DisposeRecorder.
+type DisposeCall struct {
+	// Time is when Dispose was called, on the clock of the DisposeRecorder.
+	Time time.Time
+
+	// Reason is why the command was disposed. It is nil for calls of Dispose.
+	Reason error
+
+	// Deadline is the deadline of the context passed to DisposeContext, if it has one.
+	Deadline    time.Time
+	HasDeadline bool
+}
+
+// DisposeRecorder records calls of Dispose and its reason and context-aware variants. The zero
+// value is ready to use and safe for concurrent use; it is meant to be embedded into test commands
+// to make them subcommandsutil.CancelableCommands.
+type DisposeRecorder struct {
+	mu         sync.Mutex
+	clock      subcommandsutil.Clock
+	err        error
+	panicValue interface{}
+	calls      []DisposeCall
+}
+
+// SetDisposeClock sets the clock the call times are measured on. It defaults to the real clock.
+func (d *DisposeRecorder) SetDisposeClock(clk subcommandsutil.Clock) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.clock = clk
+}
+
+// SetDisposeError sets the error returned by every Dispose variant.
+func (d *DisposeRecorder) SetDisposeError(err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.err = err
+}
+
+// SetDisposePanic makes every Dispose variant panic with v after recording the call. A nil v
+// disables the panic.
+func (d *DisposeRecorder) SetDisposePanic(v interface{}) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.panicValue = v
+}
+
+// Dispose implements subcommandsutil.CancelableCommand. It records the call and returns the
+// configured error.
+func (d *DisposeRecorder) Dispose() error {
+	return d.record(context.Background(), nil)
+}
+
+// DisposeReason records the call with reason and returns the configured error.
+func (d *DisposeRecorder) DisposeReason(reason error) error {
+	return d.record(context.Background(), reason)
+}
+
+// DisposeContext records the call with the deadline of ctx and its error as the reason, and returns
+// the configured error.
+func (d *DisposeRecorder) DisposeContext(ctx context.Context) error {
+	return d.record(ctx, ctx.Err())
+}
+
+// record records a call and returns the configured error, or panics with the configured value.
+func (d *DisposeRecorder) record(ctx context.Context, reason error) error {
+	d.mu.Lock()
+	clk := d.clock
+	if clk == nil {
+		clk = subcommandsutil.ClockFromContext(ctx)
+	}
+	call := DisposeCall{
+		Time:   clk.Now(),
+		Reason: reason,
+	}
+	call.Deadline, call.HasDeadline = ctx.Deadline()
+	d.calls = append(d.calls, call)
+	err, panicValue := d.err, d.panicValue
+	d.mu.Unlock()
+
+	if panicValue != nil {
+		panic(panicValue)
+	}
+
+	return err
+}
+
+// DisposeCount returns the number of recorded calls.
+func (d *DisposeRecorder) DisposeCount() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return len(d.calls)
+}
+
+// DisposeCalls returns the recorded calls in order.
+func (d *DisposeRecorder) DisposeCalls() []DisposeCall {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return append([]DisposeCall(nil), d.calls...)
+}
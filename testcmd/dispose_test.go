@@ -0,0 +1,91 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package testcmd_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+func TestDisposeRecorder(t *testing.T) {
+	now := time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)
+	errDispose := errors.New("dispose failed")
+	errReason := errors.New("shutting down")
+
+	var d testcmd.DisposeRecorder
+	d.SetDisposeClock(testcmd.NewFakeClock(now))
+	d.SetDisposeError(errDispose)
+
+	if err := d.Dispose(); !errors.Is(err, errDispose) {
+		t.Fatalf("wanted %v but got %v", errDispose, err)
+	}
+	if err := d.DisposeReason(errReason); !errors.Is(err, errDispose) {
+		t.Fatalf("wanted %v but got %v", errDispose, err)
+	}
+	deadline := time.Now().Add(time.Hour)
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	cancel()
+	if err := d.DisposeContext(ctx); !errors.Is(err, errDispose) {
+		t.Fatalf("wanted %v but got %v", errDispose, err)
+	}
+
+	calls := d.DisposeCalls()
+	if len(calls) != 3 || d.DisposeCount() != 3 {
+		t.Fatalf("wanted 3 calls but got %d", len(calls))
+	}
+	for i, call := range calls {
+		if !call.Time.Equal(now) {
+			t.Fatalf("wanted call %d at %v but got %v", i, now, call.Time)
+		}
+	}
+	if calls[0].Reason != nil || calls[0].HasDeadline {
+		t.Fatalf("wanted Dispose to record no reason and no deadline but got %+v", calls[0])
+	}
+	if !errors.Is(calls[1].Reason, errReason) {
+		t.Fatalf("wanted reason %v but got %v", errReason, calls[1].Reason)
+	}
+	if !errors.Is(calls[2].Reason, context.Canceled) || !calls[2].HasDeadline || !calls[2].Deadline.Equal(deadline) {
+		t.Fatalf("wanted the context error and deadline to be recorded but got %+v", calls[2])
+	}
+}
+
+func TestDisposeRecorderPanic(t *testing.T) {
+	var d testcmd.DisposeRecorder
+	d.SetDisposePanic("boom")
+
+	func() {
+		defer func() {
+			if r := recover(); r != "boom" {
+				t.Fatalf("wanted panic %q but got %v", "boom", r)
+			}
+		}()
+		d.Dispose()
+	}()
+	if d.DisposeCount() != 1 {
+		t.Fatalf("wanted the panicking call to be recorded but got %d calls", d.DisposeCount())
+	}
+}
+
+func TestDisposeRecorderConcurrent(t *testing.T) {
+	var d testcmd.DisposeRecorder
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d.Dispose()
+		}()
+	}
+	wg.Wait()
+
+	if d.DisposeCount() != 10 {
+		t.Fatalf("wanted 10 calls but got %d", d.DisposeCount())
+	}
+}
@@ -0,0 +1,6 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+// Package testcmd provides test doubles and helpers for testing google/subcommands Commands and the
+// subcommandsutil wrappers.
+package testcmd
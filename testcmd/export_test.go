@@ -0,0 +1,17 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package testcmd
+
+import (
+	"time"
+)
+
+// SetLeakTimeout sets how long VerifyNoLeaks waits for goroutines to exit and returns a function
+// restoring the previous timeout.
+func SetLeakTimeout(d time.Duration) (restore func()) {
+	prev := leakTimeout
+	leakTimeout = d
+
+	return func() { leakTimeout = prev }
+}
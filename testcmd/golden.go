@@ -0,0 +1,95 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package testcmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+)
+
+// UpdateGoldenEnv is the environment variable making the golden-file helpers rewrite the golden
+// files instead of comparing against them.
+//
+//	UPDATE_GOLDEN=1 go test ./...
+const UpdateGoldenEnv = "UPDATE_GOLDEN"
+
+// GoldenUsage renders the usage of cmd with subcommandsutil.ExplainCommand and compares it against
+// the golden file at goldenPath.
+func GoldenUsage(t *testing.T, cmd subcommands.Command, goldenPath string) {
+	t.Helper()
+
+	var buf bytes.Buffer
+	subcommandsutil.ExplainCommand(&buf, cmd)
+	Golden(t, buf.String(), goldenPath)
+}
+
+// Golden compares got against the golden file at goldenPath, ignoring trailing whitespace and line
+// ending differences. If the UPDATE_GOLDEN environment variable is set, the golden file is written
+// with got instead.
+func Golden(t *testing.T, got, goldenPath string) {
+	t.Helper()
+
+	got = normalizeGolden(got)
+	if os.Getenv(UpdateGoldenEnv) != "" {
+		if err := os.MkdirAll(filepath.Dir(goldenPath), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(goldenPath, []byte(got), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	data, err := os.ReadFile(goldenPath)
+	if err != nil {
+		t.Fatalf("reading golden file (set %s=1 to create it): %v", UpdateGoldenEnv, err)
+	}
+	if want := normalizeGolden(string(data)); got != want {
+		t.Fatalf("output differs from %s (set %s=1 to update it):\n%s", goldenPath, UpdateGoldenEnv, lineDiff(want, got))
+	}
+}
+
+// normalizeGolden converts line endings to "\n" and trims trailing whitespace of each line.
+func normalizeGolden(s string) string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t\r")
+	}
+
+	return strings.TrimRight(strings.Join(lines, "\n"), "\n") + "\n"
+}
+
+// lineDiff renders the differing lines of want and got.
+func lineDiff(want, got string) string {
+	wl, gl := strings.Split(want, "\n"), strings.Split(got, "\n")
+	n := len(wl)
+	if len(gl) > n {
+		n = len(gl)
+	}
+
+	var b strings.Builder
+	for i := 0; i < n; i++ {
+		var w, g string
+		if i < len(wl) {
+			w = wl[i]
+		}
+		if i < len(gl) {
+			g = gl[i]
+		}
+		if w != g {
+			fmt.Fprintf(&b, "line %d:\n  want: %q\n  got:  %q\n", i+1, w, g)
+		}
+	}
+
+	return b.String()
+}
@@ -0,0 +1,33 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package testcmd_test
+
+import (
+	"flag"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+func TestGoldenUsage(t *testing.T) {
+	cmd := testcmd.NewRecording("deploy",
+		testcmd.WithUsage("deploy [-f] [-timeout duration] ENV:\n\tDeploy the current build to ENV.\n"),
+		testcmd.WithFlags(func(f *flag.FlagSet) {
+			f.Bool("f", false, "skip the confirmation")
+			f.Duration("timeout", time.Minute, "give up after `duration`")
+			f.String("token", "", "deploy token")
+			subcommandsutil.AliasFlag(f, "f", "force")
+			subcommandsutil.HideFlags(f, "token")
+		}),
+	)
+
+	testcmd.GoldenUsage(t, cmd, filepath.Join("testdata", "deploy.golden"))
+}
+
+func TestGoldenNormalization(t *testing.T) {
+	testcmd.Golden(t, "line one \t\nline two\n\n", filepath.Join("testdata", "normalize.golden"))
+}
@@ -0,0 +1,115 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package testcmd
+
+import (
+	"context"
+	"flag"
+	"testing"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+)
+
+// Harness runs commands through a private subcommands.Commander, the same dispatch path as a
+// main function, without touching flag.CommandLine, os.Stdout and os.Stderr.
+type Harness struct {
+	// Commander dispatches the registered commands. It has the help, flags and commands commands
+	// registered in the "help" group.
+	Commander *subcommands.Commander
+
+	// Flags is the top-level FlagSet of Commander.
+	Flags *flag.FlagSet
+
+	// Stdout and Stderr capture the output of Commander and of commands writing to the
+	// subcommandsutil.Stdout and subcommandsutil.Stderr of their context.
+	Stdout *Buffer
+	Stderr *Buffer
+
+	t testing.TB
+}
+
+// NewHarness returns a new Harness for t.
+func NewHarness(t testing.TB) *Harness {
+	t.Helper()
+
+	h := &Harness{
+		Flags:  flag.NewFlagSet(t.Name(), flag.ContinueOnError),
+		Stdout: &Buffer{},
+		Stderr: &Buffer{},
+		t:      t,
+	}
+	h.Flags.SetOutput(h.Stderr)
+
+	h.Commander = subcommands.NewCommander(h.Flags, t.Name())
+	h.Commander.Output = h.Stdout
+	h.Commander.Error = h.Stderr
+	h.Commander.Register(h.Commander.HelpCommand(), "help")
+	h.Commander.Register(h.Commander.FlagsCommand(), "help")
+	h.Commander.Register(h.Commander.CommandsCommand(), "help")
+
+	return h
+}
+
+// Register registers cmd in group of the Commander. The FlagSet cmd is executed with writes its
+// parse errors to the Stderr buffer.
+func (h *Harness) Register(cmd subcommands.Command, group string) {
+	h.Commander.Register(&harnessed{sub: cmd, stderr: h.Stderr}, group)
+}
+
+// Execute parses argv with the top-level flags and dispatches to the command it names. The output
+// writers of ctx are set to the Stdout and Stderr buffers.
+//
+// If parsing the top-level flags fails, Execute returns subcommands.ExitUsageError.
+func (h *Harness) Execute(ctx context.Context, argv ...string) subcommands.ExitStatus {
+	h.t.Helper()
+
+	if err := h.Flags.Parse(argv); err != nil {
+		return subcommands.ExitUsageError
+	}
+
+	return h.Commander.Execute(subcommandsutil.WithOutput(ctx, h.Stdout, h.Stderr))
+}
+
+// harnessed wraps a Command registered to a Harness so that its FlagSet writes to the Stderr
+// buffer instead of os.Stderr.
+type harnessed struct {
+	sub    subcommands.Command
+	stderr *Buffer
+}
+
+// make sure harnessed implements the subcommands.Command interface.
+var _ subcommands.Command = (*harnessed)(nil)
+
+// Name forwards to the underlying c.sub Command.
+func (c *harnessed) Name() string {
+	return c.sub.Name()
+}
+
+// Usage forwards to the underlying c.sub Command.
+func (c *harnessed) Usage() string {
+	return c.sub.Usage()
+}
+
+// Synopsis forwards to the underlying c.sub Command.
+func (c *harnessed) Synopsis() string {
+	return c.sub.Synopsis()
+}
+
+// Unwrap returns the underlying c.sub Command.
+func (c *harnessed) Unwrap() subcommands.Command {
+	return c.sub
+}
+
+// SetFlags sets the output of f to the Stderr buffer and forwards to the underlying c.sub Command.
+func (c *harnessed) SetFlags(f *flag.FlagSet) {
+	f.SetOutput(c.stderr)
+	c.sub.SetFlags(f)
+}
+
+// Execute forwards to the underlying c.sub Command.
+func (c *harnessed) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	return c.sub.Execute(ctx, f, args...)
+}
@@ -0,0 +1,56 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package testcmd_test
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+func TestHarness(t *testing.T) {
+	printer := func(name string) testcmd.Option {
+		return testcmd.WithExecute(func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+			fmt.Fprintf(subcommandsutil.Stdout(ctx), "%s %s\n", name, strings.Join(f.Args(), " "))
+			return subcommands.ExitSuccess
+		})
+	}
+
+	h := testcmd.NewHarness(t)
+	push := testcmd.NewRecording("push", printer("push"))
+	pull := testcmd.NewRecording("pull", printer("pull"), testcmd.WithFlags(func(f *flag.FlagSet) {
+		f.Bool("rebase", false, "rebase")
+	}))
+	h.Register(push, "remote")
+	h.Register(pull, "remote")
+
+	testcmd.RequireSuccess(t, h.Execute(context.Background(), "push", "origin"))
+	testcmd.RequireSuccess(t, h.Execute(context.Background(), "pull", "-rebase", "upstream"))
+
+	if push.CallCount() != 1 || pull.CallCount() != 1 {
+		t.Fatalf("wanted each command to run once but got push=%d pull=%d", push.CallCount(), pull.CallCount())
+	}
+	if got, want := h.Stdout.String(), "push origin\npull upstream\n"; got != want {
+		t.Fatalf("wanted stdout %q but got %q", want, got)
+	}
+	if got := h.Stderr.String(); got != "" {
+		t.Fatalf("wanted empty stderr but got %q", got)
+	}
+
+	h.Stdout.Reset()
+	testcmd.AssertStatus(t, h.Execute(context.Background(), "pull", "-unknown"), subcommands.ExitUsageError)
+	if !strings.Contains(h.Stderr.String(), "-unknown") {
+		t.Fatalf("wanted the parse error on stderr but got %q", h.Stderr.String())
+	}
+	if got := h.Stdout.String(); got != "" {
+		t.Fatalf("wanted empty stdout but got %q", got)
+	}
+}
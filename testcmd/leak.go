@@ -0,0 +1,94 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package testcmd
+
+import (
+	"bytes"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// knownGoroutines are the stack substrings of goroutines owned by the testing and os/signal
+// packages, which VerifyNoLeaks never reports.
+var knownGoroutines = []string{
+	"testing.(*M).",
+	"testing.(*T).Run(",
+	"testing.(*T).Parallel(",
+	"testing.tRunner.func1(",
+	"testing.runTests(",
+	"os/signal.signal_recv(",
+	"os/signal.loop(",
+}
+
+// leakTimeout is how long VerifyNoLeaks waits for goroutines to exit.
+var leakTimeout = 2 * time.Second
+
+// VerifyNoLeaks fails t if goroutines other than the calling one are still running, waiting up to
+// a short timeout for them to exit. It is intended to be deferred at the top of a test:
+//
+//	defer testcmd.VerifyNoLeaks(t)
+//
+// Goroutines of the testing and os/signal packages are ignored, as are goroutines whose stack
+// contains one of ignore. Tests calling t.Parallel must not use VerifyNoLeaks, since the
+// goroutines of other tests are reported as leaks.
+func VerifyNoLeaks(t testing.TB, ignore ...string) {
+	t.Helper()
+
+	var leaked []string
+	for delay, deadline := time.Millisecond, time.Now().Add(leakTimeout); ; delay *= 2 {
+		leaked = leakedGoroutines(ignore)
+		if len(leaked) == 0 {
+			return
+		}
+		if time.Now().After(deadline) {
+			break
+		}
+		if delay > 100*time.Millisecond {
+			delay = 100 * time.Millisecond
+		}
+		time.Sleep(delay)
+	}
+
+	t.Errorf("found %d leaked goroutine(s):\n\n%s", len(leaked), strings.Join(leaked, "\n\n"))
+}
+
+// leakedGoroutines returns the stacks of the running goroutines, except the calling one and the
+// known and ignored ones.
+func leakedGoroutines(ignore []string) []string {
+	buf := make([]byte, 64<<10)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+
+	// the first stack is the one of the calling goroutine
+	stacks := bytes.Split(buf, []byte("\n\n"))[1:]
+
+	var leaked []string
+	for _, stack := range stacks {
+		s := string(stack)
+		if !containsAny(s, knownGoroutines) && !containsAny(s, ignore) {
+			leaked = append(leaked, s)
+		}
+	}
+
+	return leaked
+}
+
+// containsAny reports whether s contains any of substrs.
+func containsAny(s string, substrs []string) bool {
+	for _, substr := range substrs {
+		if strings.Contains(s, substr) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,52 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package testcmd_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+func TestVerifyNoLeaks(t *testing.T) {
+	defer testcmd.VerifyNoLeaks(t)
+
+	t.Run("when a goroutine exits", func(t *testing.T) {
+		tb := &fakeTB{}
+		done := make(chan struct{})
+		go func() { <-done }()
+		close(done)
+
+		testcmd.VerifyNoLeaks(tb)
+		if len(tb.errors) != 0 {
+			t.Fatalf("wanted no leaks but got %q", tb.errors)
+		}
+	})
+
+	t.Run("when a goroutine leaks", func(t *testing.T) {
+		defer testcmd.SetLeakTimeout(10 * time.Millisecond)()
+
+		tb := &fakeTB{}
+		block := make(chan struct{})
+		defer close(block)
+		go leakingGoroutine(block)
+
+		testcmd.VerifyNoLeaks(tb)
+		if len(tb.errors) != 1 || !strings.Contains(tb.errors[0], "leakingGoroutine") {
+			t.Fatalf("wanted the leaked goroutine to be reported but got %q", tb.errors)
+		}
+
+		tb = &fakeTB{}
+		testcmd.VerifyNoLeaks(tb, "leakingGoroutine")
+		if len(tb.errors) != 0 {
+			t.Fatalf("wanted the ignored goroutine not to be reported but got %q", tb.errors)
+		}
+	})
+}
+
+func leakingGoroutine(block chan struct{}) {
+	<-block
+}
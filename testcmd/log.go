@@ -0,0 +1,188 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package testcmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zchee/subcommandsutil"
+)
+
+// Record is a log entry recorded by LogRecorder.
+type Record struct {
+	// Time is when the entry was logged. It is zero for entries logged through Printf.
+	Time time.Time
+
+	// Level is the level of the entry. Entries logged through Printf have slog.LevelInfo.
+	Level slog.Level
+
+	// Message is the formatted message of the entry.
+	Message string
+
+	// Attrs are the attributes of a structured entry, with the keys qualified by their groups.
+	Attrs []slog.Attr
+}
+
+// String returns the message of r followed by its attributes in key=value form.
+func (r Record) String() string {
+	var b strings.Builder
+	b.WriteString(r.Message)
+	for _, a := range r.Attrs {
+		fmt.Fprintf(&b, " %s=%v", a.Key, a.Value)
+	}
+
+	return b.String()
+}
+
+// LogRecorder is a subcommandsutil.Logger recording every entry in memory. Handler returns an
+// slog.Handler recording into the same LogRecorder. The zero value is ready to use and safe for
+// concurrent use.
+type LogRecorder struct {
+	mu      sync.Mutex
+	records []Record
+}
+
+// make sure LogRecorder implements the subcommandsutil.Logger interface.
+var _ subcommandsutil.Logger = (*LogRecorder)(nil)
+
+// Printf implements subcommandsutil.Logger.
+func (l *LogRecorder) Printf(format string, v ...interface{}) {
+	l.add(Record{
+		Level:   slog.LevelInfo,
+		Message: strings.TrimSuffix(fmt.Sprintf(format, v...), "\n"),
+	})
+}
+
+// add records r.
+func (l *LogRecorder) add(r Record) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.records = append(l.records, r)
+}
+
+// Records returns the recorded entries in order.
+func (l *LogRecorder) Records() []Record {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return append([]Record(nil), l.records...)
+}
+
+// Lines returns the recorded entries formatted by Record.String.
+func (l *LogRecorder) Lines() []string {
+	records := l.Records()
+	lines := make([]string, len(records))
+	for i, r := range records {
+		lines[i] = r.String()
+	}
+
+	return lines
+}
+
+// Contains reports whether any of Lines contains substr.
+func (l *LogRecorder) Contains(substr string) bool {
+	for _, line := range l.Lines() {
+		if strings.Contains(line, substr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Reset removes every recorded entry.
+func (l *LogRecorder) Reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.records = nil
+}
+
+// Handler returns an slog.Handler recording every entry of every level into l.
+func (l *LogRecorder) Handler() slog.Handler {
+	return &logHandler{rec: l}
+}
+
+// logHandler is the slog.Handler of a LogRecorder.
+type logHandler struct {
+	rec    *LogRecorder
+	attrs  []slog.Attr
+	groups []string
+}
+
+// make sure logHandler implements the slog.Handler interface.
+var _ slog.Handler = (*logHandler)(nil)
+
+// Enabled implements slog.Handler.
+func (h *logHandler) Enabled(context.Context, slog.Level) bool {
+	return true
+}
+
+// Handle implements slog.Handler.
+func (h *logHandler) Handle(_ context.Context, r slog.Record) error {
+	attrs := append([]slog.Attr(nil), h.attrs...)
+	r.Attrs(func(a slog.Attr) bool {
+		attrs = append(attrs, qualifyAttr(h.groups, a)...)
+		return true
+	})
+	h.rec.add(Record{
+		Time:    r.Time,
+		Level:   r.Level,
+		Message: r.Message,
+		Attrs:   attrs,
+	})
+
+	return nil
+}
+
+// WithAttrs implements slog.Handler.
+func (h *logHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	h2 := *h
+	h2.attrs = append([]slog.Attr(nil), h.attrs...)
+	for _, a := range attrs {
+		h2.attrs = append(h2.attrs, qualifyAttr(h.groups, a)...)
+	}
+
+	return &h2
+}
+
+// WithGroup implements slog.Handler.
+func (h *logHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.groups = append(append([]string(nil), h.groups...), name)
+
+	return &h2
+}
+
+// qualifyAttr flattens a into attributes whose keys are prefixed by groups, joined with dots.
+func qualifyAttr(groups []string, a slog.Attr) []slog.Attr {
+	a.Value = a.Value.Resolve()
+	if a.Value.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			groups = append(append([]string(nil), groups...), a.Key)
+		}
+		var attrs []slog.Attr
+		for _, ga := range a.Value.Group() {
+			attrs = append(attrs, qualifyAttr(groups, ga)...)
+		}
+		return attrs
+	}
+	if a.Equal(slog.Attr{}) {
+		return nil
+	}
+	if len(groups) > 0 {
+		a.Key = strings.Join(groups, ".") + "." + a.Key
+	}
+
+	return []slog.Attr{a}
+}
@@ -0,0 +1,71 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package testcmd_test
+
+import (
+	"log/slog"
+	"sync"
+	"testing"
+
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+func TestLogRecorder(t *testing.T) {
+	var l testcmd.LogRecorder
+	l.Printf("%s: running %s\n", "push", "origin")
+
+	logger := slog.New(l.Handler()).With("cmd", "push").WithGroup("req")
+	logger.Warn("retrying", "attempt", 2, slog.Group("backoff", "delay", "1s"))
+
+	lines := l.Lines()
+	want := []string{
+		"push: running origin",
+		"retrying cmd=push req.attempt=2 req.backoff.delay=1s",
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("wanted %q but got %q", want, lines)
+	}
+	for i := range want {
+		if lines[i] != want[i] {
+			t.Fatalf("wanted line %d to be %q but got %q", i, want[i], lines[i])
+		}
+	}
+
+	records := l.Records()
+	if records[0].Level != slog.LevelInfo || !records[0].Time.IsZero() {
+		t.Fatalf("wanted a Printf record at info level without time but got %+v", records[0])
+	}
+	if records[1].Level != slog.LevelWarn || records[1].Time.IsZero() {
+		t.Fatalf("wanted a timed slog record at warn level but got %+v", records[1])
+	}
+
+	if !l.Contains("req.attempt=2") || l.Contains("denied") {
+		t.Fatal("wanted Contains to match substrings of the recorded lines only")
+	}
+
+	l.Reset()
+	if len(l.Records()) != 0 {
+		t.Fatalf("wanted no records after Reset but got %d", len(l.Records()))
+	}
+}
+
+func TestLogRecorderConcurrent(t *testing.T) {
+	var l testcmd.LogRecorder
+	logger := slog.New(l.Handler())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			l.Printf("line %d", i)
+			logger.Info("record", "i", i)
+		}(i)
+	}
+	wg.Wait()
+
+	if got := len(l.Records()); got != 20 {
+		t.Fatalf("wanted 20 records but got %d", got)
+	}
+}
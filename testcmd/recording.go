@@ -0,0 +1,195 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package testcmd
+
+import (
+	"context"
+	"flag"
+	"sync"
+	"time"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+)
+
+// Call is a recorded call of Recording.Execute.
+type Call struct {
+	// Ctx is the context Execute was called with.
+	Ctx context.Context
+
+	// FlagSetName is the name of the FlagSet Execute was called with.
+	FlagSetName string
+
+	// Args are the positional arguments of the FlagSet.
+	Args []string
+
+	// Varargs are the additional arguments passed to Execute.
+	Varargs []interface{}
+}
+
+// Option is an option of NewRecording.
+type Option func(*Recording)
+
+// WithSynopsis sets the Synopsis of the command.
+func WithSynopsis(synopsis string) Option {
+	return func(r *Recording) {
+		r.synopsis = synopsis
+	}
+}
+
+// WithUsage sets the Usage of the command.
+func WithUsage(usage string) Option {
+	return func(r *Recording) {
+		r.usage = usage
+	}
+}
+
+// WithFlags sets the function defining the flags of the command in SetFlags.
+func WithFlags(setFlags func(f *flag.FlagSet)) Option {
+	return func(r *Recording) {
+		r.setFlags = setFlags
+	}
+}
+
+// WithStatus sets the ExitStatus returned by Execute.
+func WithStatus(status subcommands.ExitStatus) Option {
+	return func(r *Recording) {
+		r.status = status
+	}
+}
+
+// WithDelay makes Execute sleep for d, ignoring the context, before it finishes.
+func WithDelay(d time.Duration) Option {
+	return func(r *Recording) {
+		r.delay = d
+	}
+}
+
+// WithExecute makes Execute call fn after the delay and return its ExitStatus.
+func WithExecute(fn func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus) Option {
+	return func(r *Recording) {
+		r.execute = fn
+	}
+}
+
+// WithDisposeError sets the error returned by Dispose.
+func WithDisposeError(err error) Option {
+	return func(r *Recording) {
+		r.SetDisposeError(err)
+	}
+}
+
+// Recording is a subcommandsutil.CancelableCommand recording its Execute and Dispose calls. It is
+// safe for concurrent use.
+type Recording struct {
+	DisposeRecorder
+
+	name     string
+	synopsis string
+	usage    string
+	setFlags func(f *flag.FlagSet)
+	status   subcommands.ExitStatus
+	delay    time.Duration
+	execute  func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus
+
+	mu       sync.RWMutex
+	calls    []Call
+	finished int
+}
+
+// make sure Recording implements the subcommandsutil.CancelableCommand interface.
+var _ subcommandsutil.CancelableCommand = (*Recording)(nil)
+
+// NewRecording returns a new Recording command named name.
+func NewRecording(name string, opts ...Option) *Recording {
+	r := &Recording{
+		name: name,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	return r
+}
+
+// Name implements subcommands.Command.
+func (r *Recording) Name() string { return r.name }
+
+// Synopsis implements subcommands.Command.
+func (r *Recording) Synopsis() string { return r.synopsis }
+
+// Usage implements subcommands.Command.
+func (r *Recording) Usage() string { return r.usage }
+
+// SetFlags implements subcommands.Command.
+func (r *Recording) SetFlags(f *flag.FlagSet) {
+	if r.setFlags != nil {
+		r.setFlags(f)
+	}
+}
+
+// Execute implements subcommands.Command. It records the call, sleeps for the configured delay, and
+// returns the configured ExitStatus.
+func (r *Recording) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	r.mu.Lock()
+	r.calls = append(r.calls, Call{
+		Ctx:         ctx,
+		FlagSetName: f.Name(),
+		Args:        append([]string(nil), f.Args()...),
+		Varargs:     append([]interface{}(nil), args...),
+	})
+	r.mu.Unlock()
+
+	if r.delay > 0 {
+		time.Sleep(r.delay)
+	}
+
+	status := r.status
+	if r.execute != nil {
+		status = r.execute(ctx, f, args...)
+	}
+
+	r.mu.Lock()
+	r.finished++
+	r.mu.Unlock()
+
+	return status
+}
+
+// DidFinish reports whether any call of Execute has finished.
+func (r *Recording) DidFinish() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.finished > 0
+}
+
+// CallCount returns the number of calls of Execute, including unfinished ones.
+func (r *Recording) CallCount() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return len(r.calls)
+}
+
+// Calls returns the recorded calls of Execute.
+func (r *Recording) Calls() []Call {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return append([]Call(nil), r.calls...)
+}
+
+// LastCall returns the last recorded call of Execute.
+func (r *Recording) LastCall() (Call, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if len(r.calls) == 0 {
+		return Call{}, false
+	}
+
+	return r.calls[len(r.calls)-1], true
+}
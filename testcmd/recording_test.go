@@ -0,0 +1,89 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package testcmd_test
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+type ctxKey struct{}
+
+func TestRecording(t *testing.T) {
+	errDispose := errors.New("dispose failed")
+	r := testcmd.NewRecording("push",
+		testcmd.WithSynopsis("push changes"),
+		testcmd.WithUsage("push [-f] REMOTE\n"),
+		testcmd.WithFlags(func(f *flag.FlagSet) { f.Bool("f", false, "force") }),
+		testcmd.WithStatus(subcommands.ExitFailure),
+		testcmd.WithDisposeError(errDispose),
+	)
+	if r.Name() != "push" || r.Synopsis() != "push changes" || r.Usage() != "push [-f] REMOTE\n" {
+		t.Fatalf("unexpected metadata %q %q %q", r.Name(), r.Synopsis(), r.Usage())
+	}
+	if r.DidFinish() || r.CallCount() != 0 {
+		t.Fatal("wanted no calls yet")
+	}
+
+	f := flag.NewFlagSet("push", flag.ContinueOnError)
+	r.SetFlags(f)
+	if err := f.Parse([]string{"-f", "origin"}); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.WithValue(context.Background(), ctxKey{}, "value")
+	if status := r.Execute(ctx, f, 42); status != subcommands.ExitFailure {
+		t.Fatalf("wanted %v but got %v", subcommands.ExitFailure, status)
+	}
+	if err := r.Dispose(); !errors.Is(err, errDispose) {
+		t.Fatalf("wanted %v but got %v", errDispose, err)
+	}
+
+	call, ok := r.LastCall()
+	if !ok {
+		t.Fatal("wanted a recorded call")
+	}
+	if call.Ctx.Value(ctxKey{}) != "value" {
+		t.Fatal("wanted the context to be recorded")
+	}
+	if call.FlagSetName != "push" {
+		t.Fatalf("wanted FlagSet name %q but got %q", "push", call.FlagSetName)
+	}
+	if !reflect.DeepEqual(call.Args, []string{"origin"}) {
+		t.Fatalf("wanted args %q but got %q", []string{"origin"}, call.Args)
+	}
+	if !reflect.DeepEqual(call.Varargs, []interface{}{42}) {
+		t.Fatalf("wanted varargs %v but got %v", []interface{}{42}, call.Varargs)
+	}
+	if !r.DidFinish() || r.CallCount() != 1 || r.DisposeCount() != 1 {
+		t.Fatalf("wanted one finished call and one Dispose, got %d calls and %d disposes", r.CallCount(), r.DisposeCount())
+	}
+}
+
+func TestRecordingConcurrent(t *testing.T) {
+	r := testcmd.NewRecording("concurrent")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.Execute(context.Background(), flag.NewFlagSet("concurrent", flag.ContinueOnError))
+			r.Dispose()
+		}()
+	}
+	wg.Wait()
+
+	if r.CallCount() != 10 || r.DisposeCount() != 10 || len(r.Calls()) != 10 {
+		t.Fatalf("wanted 10 calls and disposes, got %d and %d", r.CallCount(), r.DisposeCount())
+	}
+}
@@ -0,0 +1,29 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package testcmd
+
+import (
+	"context"
+	"flag"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+)
+
+// Run executes cmd with the raw command line args the way subcommands.Commander does: it creates a
+// FlagSet named after cmd, calls SetFlags, parses args, and calls Execute.
+//
+// If parsing args fails, Run returns subcommands.ExitUsageError and the parse error without
+// executing cmd. The FlagSet is returned for inspection after the execution.
+func Run(ctx context.Context, cmd subcommands.Command, args ...string) (subcommands.ExitStatus, *flag.FlagSet, error) {
+	f := flag.NewFlagSet(cmd.Name(), flag.ContinueOnError)
+	f.Usage = func() { subcommandsutil.ExplainCommand(f.Output(), cmd) }
+	cmd.SetFlags(f)
+	if err := f.Parse(args); err != nil {
+		return subcommands.ExitUsageError, f, err
+	}
+
+	return cmd.Execute(ctx, f), f, nil
+}
@@ -0,0 +1,82 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package testcmd_test
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+func TestRun(t *testing.T) {
+	tests := map[string]struct {
+		args       []string
+		wantStatus subcommands.ExitStatus
+		wantErr    bool
+		wantCalls  int
+	}{
+		"when the arguments parse": {
+			args:       []string{"-n", "3", "a", "b"},
+			wantStatus: subcommands.ExitFailure,
+			wantCalls:  1,
+		},
+		"when a flag is undefined": {
+			args:       []string{"-x"},
+			wantStatus: subcommands.ExitUsageError,
+			wantErr:    true,
+		},
+		"when a flag value is invalid": {
+			args:       []string{"-n", "many"},
+			wantStatus: subcommands.ExitUsageError,
+			wantErr:    true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var out bytes.Buffer
+			r := testcmd.NewRecording("count",
+				testcmd.WithUsage("count [-n N] ARGS...\n"),
+				testcmd.WithStatus(subcommands.ExitFailure),
+				testcmd.WithFlags(func(f *flag.FlagSet) {
+					f.SetOutput(&out)
+					f.Int("n", 1, "count")
+				}),
+			)
+
+			status, f, err := testcmd.Run(context.Background(), r, tt.args...)
+			if status != tt.wantStatus {
+				t.Fatalf("wanted %v but got %v", tt.wantStatus, status)
+			}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("wanted error %t but got %v", tt.wantErr, err)
+			}
+			if r.CallCount() != tt.wantCalls {
+				t.Fatalf("wanted %d calls but got %d", tt.wantCalls, r.CallCount())
+			}
+			if f.Name() != "count" {
+				t.Fatalf("wanted the FlagSet to be named %q but got %q", "count", f.Name())
+			}
+
+			if tt.wantErr {
+				if !strings.Contains(out.String(), "count [-n N] ARGS...") {
+					t.Fatalf("wanted the usage to be printed but got %q", out.String())
+				}
+				return
+			}
+			if got := f.Lookup("n").Value.String(); got != "3" {
+				t.Fatalf("wanted -n to be 3 but got %s", got)
+			}
+			if call, _ := r.LastCall(); !reflect.DeepEqual(call.Args, []string{"a", "b"}) {
+				t.Fatalf("wanted args %q but got %q", []string{"a", "b"}, call.Args)
+			}
+		})
+	}
+}
@@ -0,0 +1,319 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package testcmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+)
+
+// RunScript runs the end-to-end test script against h. A script is a list of directives, one per
+// line; blank lines and lines starting with # are ignored:
+//
+//	env NAME=VALUE            set an environment variable for the rest of the test
+//	cancel-after DURATION     cancel the context of the next run once DURATION passed on the clock
+//	run ARG...                execute the command line ARG... through h
+//	status N                  assert the exit status of the last run
+//	stdout contains TEXT      assert the stdout of the last run contains TEXT
+//	stdout regexp PATTERN     assert the stdout of the last run matches PATTERN
+//	stdout empty              assert the stdout of the last run is empty
+//
+// stderr takes the same assertions as stdout. Arguments containing spaces are double-quoted, with
+// the escapes of Go string literals.
+//
+// The commands run with a FakeClock in their context. While a command runs, the clock is advanced
+// to its next timer whenever the command does not finish within a millisecond, so commands sleeping
+// on the clock of their context finish without waiting.
+//
+// The whole script is parsed before any directive runs; errors report the script line.
+func RunScript(t testing.TB, h *Harness, script string) {
+	t.Helper()
+
+	runScript(t, h, "script", script)
+}
+
+// RunScripts runs each file matching pattern, such as "testdata/*.txt", as a script in its own
+// subtest of t, against the Harness returned by setup.
+func RunScripts(t *testing.T, pattern string, setup func(t *testing.T) *Harness) {
+	t.Helper()
+
+	files, err := filepath.Glob(pattern)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatalf("wanted scripts matching %q but got none", pattern)
+	}
+
+	for _, file := range files {
+		file := file
+		t.Run(strings.TrimSuffix(filepath.Base(file), filepath.Ext(file)), func(t *testing.T) {
+			script, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			runScript(t, setup(t), file, string(script))
+		})
+	}
+}
+
+// scriptDirective is a parsed line of a script.
+type scriptDirective struct {
+	line int
+	verb string
+	args []string
+
+	// parsed arguments
+	status   subcommands.ExitStatus
+	duration time.Duration
+	re       *regexp.Regexp
+}
+
+// scriptStart is the time the FakeClock of a script starts at.
+var scriptStart = time.Date(2021, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// runScript parses and runs script, whose name prefixes the reported errors.
+func runScript(t testing.TB, h *Harness, name, script string) {
+	t.Helper()
+
+	directives, err := parseScript(script)
+	if err != nil {
+		t.Fatalf("%s:%v", name, err)
+		return
+	}
+
+	clk := NewFakeClock(scriptStart)
+	var (
+		ran         bool
+		status      subcommands.ExitStatus
+		stdout      string
+		stderr      string
+		cancelAfter time.Duration
+	)
+	for _, d := range directives {
+		switch d.verb {
+		case "env":
+			kv := strings.SplitN(d.args[0], "=", 2)
+			t.Setenv(kv[0], kv[1])
+
+		case "cancel-after":
+			cancelAfter = d.duration
+
+		case "run":
+			h.Stdout.Reset()
+			h.Stderr.Reset()
+			status = runScriptCommand(h, clk, cancelAfter, d.args)
+			stdout, stderr = h.Stdout.String(), h.Stderr.String()
+			ran, cancelAfter = true, 0
+
+		default:
+			if !ran {
+				t.Fatalf("%s:%d: %s before any run", name, d.line, d.verb)
+				return
+			}
+			if err := checkScript(d, status, stdout, stderr); err != nil {
+				t.Errorf("%s:%d: %v", name, d.line, err)
+			}
+		}
+	}
+}
+
+// runScriptCommand executes args through h with clk in the context, canceling the context after
+// cancelAfter if it is positive.
+func runScriptCommand(h *Harness, clk *FakeClock, cancelAfter time.Duration, args []string) subcommands.ExitStatus {
+	ctx, cancel := context.WithCancel(subcommandsutil.WithClock(context.Background(), clk))
+	defer cancel()
+
+	if cancelAfter > 0 {
+		timer := clk.NewTimer(cancelAfter)
+		defer timer.Stop()
+		go func() {
+			select {
+			case <-timer.C():
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+	}
+
+	done := make(chan subcommands.ExitStatus, 1)
+	go func() {
+		done <- h.Execute(ctx, args...)
+	}()
+	for {
+		select {
+		case status := <-done:
+			return status
+		case <-time.After(time.Millisecond):
+			clk.advanceToNext()
+		}
+	}
+}
+
+// checkScript checks the assertion d against the result of the last run.
+func checkScript(d scriptDirective, status subcommands.ExitStatus, stdout, stderr string) error {
+	if d.verb == "status" {
+		if status != d.status {
+			return fmt.Errorf("wanted status %s but got %s", subcommandsutil.StatusString(d.status), subcommandsutil.StatusString(status))
+		}
+		return nil
+	}
+
+	got := stdout
+	if d.verb == "stderr" {
+		got = stderr
+	}
+	switch d.args[0] {
+	case "contains":
+		if !strings.Contains(got, d.args[1]) {
+			return fmt.Errorf("wanted %s to contain %q but got %q", d.verb, d.args[1], got)
+		}
+	case "regexp":
+		if !d.re.MatchString(got) {
+			return fmt.Errorf("wanted %s to match %q but got %q", d.verb, d.args[1], got)
+		}
+	case "empty":
+		if got != "" {
+			return fmt.Errorf("wanted %s to be empty but got %q", d.verb, got)
+		}
+	}
+
+	return nil
+}
+
+// parseScript parses script into its directives.
+func parseScript(script string) ([]scriptDirective, error) {
+	var directives []scriptDirective
+	for i, line := range strings.Split(script, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields, err := splitScriptLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("%d: %v", i+1, err)
+		}
+		d := scriptDirective{line: i + 1, verb: fields[0], args: fields[1:]}
+		if err := d.parseArgs(); err != nil {
+			return nil, fmt.Errorf("%d: %s: %v", i+1, d.verb, err)
+		}
+		directives = append(directives, d)
+	}
+
+	return directives, nil
+}
+
+// parseArgs validates and parses the arguments of d.
+func (d *scriptDirective) parseArgs() error {
+	switch d.verb {
+	case "env":
+		if len(d.args) != 1 || !strings.Contains(d.args[0], "=") {
+			return errors.New("expected NAME=VALUE")
+		}
+
+	case "cancel-after":
+		if len(d.args) != 1 {
+			return errors.New("expected a duration")
+		}
+		dur, err := time.ParseDuration(d.args[0])
+		if err != nil {
+			return err
+		}
+		if dur <= 0 {
+			return fmt.Errorf("non-positive duration %v", dur)
+		}
+		d.duration = dur
+
+	case "run":
+		if len(d.args) == 0 {
+			return errors.New("expected a command line")
+		}
+
+	case "status":
+		if len(d.args) != 1 {
+			return errors.New("expected an exit status")
+		}
+		n, err := strconv.Atoi(d.args[0])
+		if err != nil {
+			return fmt.Errorf("invalid exit status %q", d.args[0])
+		}
+		d.status = subcommands.ExitStatus(n)
+
+	case "stdout", "stderr":
+		if len(d.args) == 0 {
+			return errors.New("expected contains, regexp or empty")
+		}
+		switch d.args[0] {
+		case "contains", "regexp":
+			if len(d.args) != 2 {
+				return fmt.Errorf("%s expects one argument", d.args[0])
+			}
+			if d.args[0] == "regexp" {
+				re, err := regexp.Compile(d.args[1])
+				if err != nil {
+					return err
+				}
+				d.re = re
+			}
+		case "empty":
+			if len(d.args) != 1 {
+				return errors.New("empty expects no argument")
+			}
+		default:
+			return fmt.Errorf("unknown assertion %q", d.args[0])
+		}
+
+	default:
+		return fmt.Errorf("unknown directive")
+	}
+
+	return nil
+}
+
+// splitScriptLine splits line into fields separated by spaces, unquoting double-quoted fields.
+func splitScriptLine(line string) ([]string, error) {
+	var fields []string
+	for line != "" {
+		if line[0] != '"' {
+			i := strings.IndexAny(line, " \t")
+			if i < 0 {
+				i = len(line)
+			}
+			fields = append(fields, line[:i])
+			line = strings.TrimLeft(line[i:], " \t")
+			continue
+		}
+
+		end := 1
+		for ; end < len(line) && line[end] != '"'; end++ {
+			if line[end] == '\\' {
+				end++
+			}
+		}
+		if end >= len(line) {
+			return nil, errors.New("unterminated quoted string")
+		}
+		field, err := strconv.Unquote(line[:end+1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid quoted string %s", line[:end+1])
+		}
+		fields = append(fields, field)
+		line = strings.TrimLeft(line[end+1:], " \t")
+	}
+
+	return fields, nil
+}
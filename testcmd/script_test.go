@@ -0,0 +1,118 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package testcmd_test
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+// newScriptHarness returns a Harness with the commands used by the test scripts.
+func newScriptHarness(t *testing.T) *testcmd.Harness {
+	h := testcmd.NewHarness(t)
+	h.Register(testcmd.NewRecording("echo", testcmd.WithExecute(func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+		fmt.Fprintln(subcommandsutil.Stdout(ctx), strings.Join(f.Args(), " "))
+		return subcommands.ExitSuccess
+	})), "")
+	h.Register(testcmd.NewRecording("fail", testcmd.WithExecute(func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+		fmt.Fprintln(subcommandsutil.Stderr(ctx), "permission denied")
+		return subcommands.ExitFailure
+	})), "")
+	h.Register(testcmd.NewRecording("getenv", testcmd.WithExecute(func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+		fmt.Fprintln(subcommandsutil.Stdout(ctx), os.Getenv(f.Arg(0)))
+		return subcommands.ExitSuccess
+	})), "")
+	h.Register(subcommandsutil.Timeout(testcmd.NewRecording("sleep", testcmd.WithExecute(func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+		d, err := time.ParseDuration(f.Arg(0))
+		if err != nil {
+			fmt.Fprintln(subcommandsutil.Stderr(ctx), err)
+			return subcommands.ExitUsageError
+		}
+		timer := subcommandsutil.ClockFromContext(ctx).NewTimer(d)
+		defer timer.Stop()
+		select {
+		case <-timer.C():
+			fmt.Fprintln(subcommandsutil.Stdout(ctx), "slept")
+			return subcommands.ExitSuccess
+		case <-ctx.Done():
+			fmt.Fprintln(subcommandsutil.Stderr(ctx), "interrupted")
+			return subcommands.ExitFailure
+		}
+	})), 0), "")
+
+	return h
+}
+
+func TestRunScripts(t *testing.T) {
+	defer testcmd.VerifyNoLeaks(t)
+
+	testcmd.RunScripts(t, "testdata/scripts/*.txt", newScriptHarness)
+}
+
+func TestRunScriptErrors(t *testing.T) {
+	tests := map[string]struct {
+		script string
+		fatal  string
+		errors []string
+	}{
+		"when the directive is unknown": {
+			script: "run echo\n\nfrobnicate\n",
+			fatal:  "script:3: frobnicate: unknown directive",
+		},
+		"when a quoted string is unterminated": {
+			script: "# comment\nrun echo \"hello\n",
+			fatal:  "script:2: unterminated quoted string",
+		},
+		"when the status is invalid": {
+			script: "run echo\nstatus ok\n",
+			fatal:  `script:2: status: invalid exit status "ok"`,
+		},
+		"when the regexp is invalid": {
+			script: "run echo\nstdout regexp (\n",
+			fatal:  "script:2: stdout: error parsing regexp",
+		},
+		"when asserting before any run": {
+			script: "status 0\n",
+			fatal:  "script:1: status before any run",
+		},
+		"when assertions fail": {
+			script: "run echo hello\nstatus 1\nstdout contains bye\nstderr empty\n",
+			errors: []string{
+				"script:2: wanted status ExitFailure(1) but got ExitSuccess(0)",
+				`script:3: wanted stdout to contain "bye" but got "hello\n"`,
+			},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			tb := &fakeTB{}
+			testcmd.RunScript(tb, newScriptHarness(t), tt.script)
+
+			switch {
+			case tt.fatal != "" && (len(tb.fatals) != 1 || !strings.HasPrefix(tb.fatals[0], tt.fatal)):
+				t.Fatalf("wanted a failure starting with %q but got %q", tt.fatal, tb.fatals)
+			case tt.fatal == "" && len(tb.fatals) != 0:
+				t.Fatalf("wanted no failures but got %q", tb.fatals)
+			}
+			if len(tb.errors) != len(tt.errors) {
+				t.Fatalf("wanted errors %q but got %q", tt.errors, tb.errors)
+			}
+			for i := range tt.errors {
+				if tb.errors[i] != tt.errors[i] {
+					t.Fatalf("wanted error %q but got %q", tt.errors[i], tb.errors[i])
+				}
+			}
+		})
+	}
+}
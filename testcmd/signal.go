@@ -0,0 +1,126 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package testcmd
+
+import (
+	"os"
+	"slices"
+	"sync"
+
+	"github.com/zchee/subcommandsutil"
+)
+
+// SignalSource is a subcommandsutil.SignalSource whose signals are only the ones sent by Send,
+// leaving the signal handlers of the process alone. It is safe for concurrent use.
+//
+//	src := testcmd.NewSignalSource()
+//	ctx := subcommandsutil.WithSignalSource(context.Background(), src)
+type SignalSource struct {
+	mu        sync.Mutex
+	cond      *sync.Cond
+	listeners []*signalListener
+}
+
+// make sure SignalSource implements the subcommandsutil.SignalSource interface.
+var _ subcommandsutil.SignalSource = (*SignalSource)(nil)
+
+// NewSignalSource returns a new SignalSource with no channel notified.
+func NewSignalSource() *SignalSource {
+	s := &SignalSource{}
+	s.cond = sync.NewCond(&s.mu)
+
+	return s
+}
+
+// Notify implements subcommandsutil.SignalSource.
+func (s *SignalSource) Notify(c chan<- os.Signal, sigs ...os.Signal) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	l := s.listener(c)
+	if l == nil {
+		l = &signalListener{c: c, stopped: make(chan struct{})}
+		s.listeners = append(s.listeners, l)
+	}
+	if len(sigs) == 0 {
+		l.all = true
+	}
+	l.sigs = append(l.sigs, sigs...)
+	s.cond.Broadcast()
+}
+
+// Stop implements subcommandsutil.SignalSource.
+func (s *SignalSource) Stop(c chan<- os.Signal) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, l := range s.listeners {
+		if l.c == c {
+			s.listeners = append(s.listeners[:i], s.listeners[i+1:]...)
+			close(l.stopped)
+			s.cond.Broadcast()
+			return
+		}
+	}
+}
+
+// listener returns the listener of c, if any. s.mu must be held.
+func (s *SignalSource) listener(c chan<- os.Signal) *signalListener {
+	for _, l := range s.listeners {
+		if l.c == c {
+			return l
+		}
+	}
+
+	return nil
+}
+
+// Send delivers sig to every channel notified of it, blocking until at least one is, so that a
+// test can send a signal before the code under test has started listening. Unlike the os/signal
+// package, Send never drops sig: it waits for each channel to accept it, or to be stopped.
+func (s *SignalSource) Send(sig os.Signal) {
+	s.mu.Lock()
+	var listeners []*signalListener
+	for {
+		for _, l := range s.listeners {
+			if l.relays(sig) {
+				listeners = append(listeners, l)
+			}
+		}
+		if len(listeners) > 0 {
+			break
+		}
+		s.cond.Wait()
+	}
+	s.mu.Unlock()
+
+	for _, l := range listeners {
+		select {
+		case l.c <- sig:
+		case <-l.stopped:
+		}
+	}
+}
+
+// Listeners returns the number of channels notified of signals, which is back to zero once the code
+// under test has stopped listening.
+func (s *SignalSource) Listeners() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.listeners)
+}
+
+// signalListener is a channel notified of signals by a SignalSource.
+type signalListener struct {
+	c       chan<- os.Signal
+	sigs    []os.Signal
+	all     bool // whether c is notified of every signal
+	stopped chan struct{}
+}
+
+// relays reports whether sig is relayed to l.
+func (l *signalListener) relays(sig os.Signal) bool {
+	return l.all || slices.Contains(l.sigs, sig)
+}
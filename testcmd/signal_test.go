@@ -0,0 +1,61 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package testcmd_test
+
+import (
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+func TestSignalSource(t *testing.T) {
+	t.Parallel()
+
+	src := testcmd.NewSignalSource()
+	term := make(chan os.Signal, 1)
+	all := make(chan os.Signal, 2)
+
+	// sent before any channel is notified, delivered once one is
+	sent := make(chan struct{})
+	go func() {
+		defer close(sent)
+		src.Send(syscall.SIGTERM)
+	}()
+	src.Notify(term, syscall.SIGTERM)
+	<-sent
+	if got := <-term; got != syscall.SIGTERM {
+		t.Fatalf("wanted %v but got %v", syscall.SIGTERM, got)
+	}
+
+	src.Notify(all)
+	src.Send(os.Interrupt)
+	if got := <-all; got != os.Interrupt {
+		t.Fatalf("wanted %v on the channel notified of every signal but got %v", os.Interrupt, got)
+	}
+	select {
+	case got := <-term:
+		t.Fatalf("wanted no signal on the channel notified of SIGTERM only but got %v", got)
+	default:
+	}
+	if n := src.Listeners(); n != 2 {
+		t.Fatalf("wanted 2 listeners but got %d", n)
+	}
+
+	// a full channel blocks Send until it is stopped
+	src.Send(syscall.SIGTERM)
+	sent = make(chan struct{})
+	go func() {
+		defer close(sent)
+		src.Send(syscall.SIGTERM)
+	}()
+	<-all
+	src.Stop(term)
+	<-sent
+	src.Stop(all)
+	if n := src.Listeners(); n != 0 {
+		t.Fatalf("wanted no listener but got %d", n)
+	}
+}
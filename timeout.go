@@ -0,0 +1,136 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/google/subcommands"
+)
+
+// timeout wraps a subcommands.Command so that its execution context times out.
+type timeout struct {
+	sub subcommands.Command
+	d   time.Duration
+
+	timeout   time.Duration
+	deadline  string
+	executing executing
+}
+
+// make sure timeout implements the subcommands.Command interface.
+var _ subcommands.Command = (*timeout)(nil)
+
+// Timeout wraps sub so that its execution context is canceled after a timeout. The timeout defaults
+// to d and can be changed by the -timeout flag registered by the wrapper; 0 disables it.
+//
+// The -deadline flag, also registered, cancels the execution context at an absolute time instead,
+// an RFC 3339 timestamp like 2021-01-02T14:05:00Z or a time of the current day like 14:05:00, in
+// the location of the Clock, local for the real one. The remaining time is computed when Execute
+// is called: a deadline already past is reported to Stderr and Execute returns
+// subcommands.ExitUsageError. With both flags, the earliest wins. The effective deadline is
+// reported by the Deadline method of the execution context.
+//
+// The timeout is measured on the Clock carried by the execution context. When it expires,
+// the timeout is reported to Stderr and Execute returns subcommands.ExitFailure once sub returns.
+// Wrap a Cancelable Command to stop waiting for sub when the timeout expires.
+func Timeout(sub subcommands.Command, d time.Duration) subcommands.Command {
+	return &timeout{
+		sub: sub,
+		d:   d,
+	}
+}
+
+// Name forwards to the underlying c.sub Command.
+func (c *timeout) Name() string {
+	return c.sub.Name()
+}
+
+// Usage forwards to the underlying c.sub Command.
+func (c *timeout) Usage() string {
+	return c.sub.Usage()
+}
+
+// Synopsis forwards to the underlying c.sub Command.
+func (c *timeout) Synopsis() string {
+	return c.sub.Synopsis()
+}
+
+// Unwrap returns the underlying c.sub Command.
+func (c *timeout) Unwrap() subcommands.Command {
+	return c.sub
+}
+
+// SetFlags forwards to the underlying c.sub Command and registers the -timeout and -deadline flags.
+func (c *timeout) SetFlags(f *flag.FlagSet) {
+	c.sub.SetFlags(f)
+	f.DurationVar(&c.timeout, "timeout", c.d, "cancel the command after `duration` (0 disables the timeout)")
+	c.deadline = ""
+	f.Func("deadline", "cancel the command at `time`, RFC 3339 or 15:04:05 today", func(s string) error {
+		// only the syntax: Execute resolves the deadline on the Clock of the execution context
+		if _, err := parseDeadline(s, time.Time{}); err != nil {
+			return err
+		}
+		c.deadline = s
+		return nil
+	})
+}
+
+// parseDeadline parses the -deadline s, an RFC 3339 timestamp or a time of the day of now, in its
+// location.
+func parseDeadline(s string, now time.Time) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation(time.TimeOnly, s, now.Location())
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid deadline %q: want an RFC 3339 timestamp or %s", s, time.TimeOnly)
+	}
+	year, month, day := now.Date()
+
+	return time.Date(year, month, day, t.Hour(), t.Minute(), t.Second(), 0, now.Location()), nil
+}
+
+// Execute forwards to the underlying c.sub Command with a context timing out after the timeout, or
+// at the deadline.
+func (c *timeout) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	if !c.executing.enter(ctx, stdLogger{}, c.sub.Name()) {
+		return subcommands.ExitFailure
+	}
+	defer c.executing.exit()
+
+	d, now := c.timeout, ClockFromContext(ctx).Now()
+	if c.deadline != "" {
+		deadline, _ := parseDeadline(c.deadline, now)
+		remaining := deadline.Sub(now)
+		if remaining <= 0 {
+			fmt.Fprintln(Stderr(ctx), MessagesFromContext(ctx).Sprintf(MessageDeadlinePassed, c.sub.Name(), deadline.Format(time.RFC3339)))
+			return subcommands.ExitUsageError
+		}
+		if d <= 0 || remaining < d {
+			d = remaining
+		}
+	}
+
+	// without a timer when the deadline of ctx expires first, as it would be the one reported
+	if deadline, ok := ctx.Deadline(); d <= 0 || ok && !deadline.After(now.Add(d)) {
+		return c.sub.Execute(ctx, f, args...)
+	}
+
+	tctx, cancel := withTimeout(ctx, d)
+	defer cancel()
+
+	status := c.sub.Execute(tctx, f, args...)
+	if errors.Is(tctx.Err(), context.DeadlineExceeded) && ctx.Err() == nil {
+		publish(ctx, TimeoutWarning{Command: c.sub.Name(), Time: ClockFromContext(ctx).Now(), Timeout: d})
+		fmt.Fprintln(Stderr(ctx), MessagesFromContext(ctx).Sprintf(MessageTimedOut, c.sub.Name(), d))
+		return subcommands.ExitFailure
+	}
+
+	return status
+}
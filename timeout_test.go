@@ -0,0 +1,190 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+func TestTimeout(t *testing.T) {
+	defer testcmd.VerifyNoLeaks(t)
+
+	clk := testcmd.NewFakeClock(time.Now())
+
+	var gotErr error
+	var gotDeadline time.Time
+	cmd := subcommandsutil.Timeout(testcmd.NewRecording("slow",
+		testcmd.WithExecute(func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+			gotDeadline, _ = ctx.Deadline()
+			<-ctx.Done()
+			gotErr = ctx.Err()
+			return subcommands.ExitSuccess
+		}),
+	), time.Hour)
+
+	var stderr testcmd.Buffer
+	ctx := subcommandsutil.WithOutput(subcommandsutil.WithClock(context.Background(), clk), nil, &stderr)
+
+	done := make(chan subcommands.ExitStatus)
+	go func() {
+		status, _, _ := testcmd.Run(ctx, cmd, "-timeout", "30s")
+		done <- status
+	}()
+
+	clk.BlockUntil(1)
+	clk.Advance(30 * time.Second)
+
+	if status := <-done; status != subcommands.ExitFailure {
+		t.Fatalf("wanted %v but got %v", subcommands.ExitFailure, status)
+	}
+	if !errors.Is(gotErr, context.DeadlineExceeded) {
+		t.Fatalf("wanted %v but got %v", context.DeadlineExceeded, gotErr)
+	}
+	if want := clk.Now(); !gotDeadline.Equal(want) {
+		t.Fatalf("wanted deadline %v but got %v", want, gotDeadline)
+	}
+	if want := "slow: timed out after 30s\n"; stderr.String() != want {
+		t.Fatalf("wanted stderr %q but got %q", want, stderr.String())
+	}
+}
+
+func TestTimeoutNotExpired(t *testing.T) {
+	defer testcmd.VerifyNoLeaks(t)
+
+	var stderr testcmd.Buffer
+	ctx := subcommandsutil.WithOutput(context.Background(), nil, &stderr)
+
+	r := testcmd.NewRecording("fast")
+	status, _, _ := testcmd.Run(ctx, subcommandsutil.Timeout(r, time.Hour))
+	if status != subcommands.ExitSuccess {
+		t.Fatalf("wanted %v but got %v", subcommands.ExitSuccess, status)
+	}
+	if strings.Contains(stderr.String(), "timed out") {
+		t.Fatalf("wanted no timeout but got %q", stderr.String())
+	}
+}
+
+func TestTimeoutEarlierDeadline(t *testing.T) {
+	defer testcmd.VerifyNoLeaks(t)
+
+	clk := testcmd.NewFakeClock(time.Now())
+	var gotDeadline time.Time
+	cmd := subcommandsutil.Timeout(testcmd.NewRecording("slow",
+		testcmd.WithExecute(func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+			gotDeadline, _ = ctx.Deadline()
+			return subcommands.ExitSuccess
+		}),
+	), time.Hour)
+
+	want := clk.Now().Add(time.Minute)
+	ctx, cancel := context.WithDeadline(subcommandsutil.WithClock(context.Background(), clk), want)
+	defer cancel()
+	status, _, _ := testcmd.Run(ctx, cmd)
+	testcmd.RequireSuccess(t, status)
+	if !gotDeadline.Equal(want) {
+		t.Fatalf("wanted the deadline of the context %v but got %v", want, gotDeadline)
+	}
+}
+
+func TestTimeoutDeadline(t *testing.T) {
+	tokyo := time.FixedZone("JST", 9*60*60)
+	now := time.Date(2021, 1, 2, 14, 0, 0, 0, tokyo)
+
+	tests := map[string]struct {
+		args         []string
+		wantStatus   subcommands.ExitStatus
+		wantDeadline time.Time
+		wantStderr   string
+	}{
+		"when the deadline is an RFC 3339 timestamp": {
+			args:         []string{"-deadline", "2021-01-02T05:05:00Z"},
+			wantDeadline: now.Add(5 * time.Minute),
+		},
+		"when the deadline is a time of the day": {
+			args:         []string{"-deadline", "14:30:00"},
+			wantDeadline: now.Add(30 * time.Minute),
+		},
+		"when the deadline is past": {
+			args:       []string{"-deadline", "13:59:59"},
+			wantStatus: subcommands.ExitUsageError,
+			wantStderr: "deploy: deadline 2021-01-02T13:59:59+09:00 already passed\n",
+		},
+		"when the timeout expires before the deadline": {
+			args:         []string{"-deadline", "15:00:00", "-timeout", "10m"},
+			wantDeadline: now.Add(10 * time.Minute),
+		},
+		"when the deadline expires before the timeout": {
+			args:         []string{"-timeout", "2h", "-deadline", "2021-01-02T07:00:00+01:00"},
+			wantDeadline: now.Add(time.Hour),
+		},
+		"when the timeout is disabled": {
+			args:         []string{"-timeout", "0", "-deadline", "14:00:01"},
+			wantDeadline: now.Add(time.Second),
+		},
+		"when the deadline is invalid": {
+			args:       []string{"-deadline", "tomorrow"},
+			wantStatus: subcommands.ExitUsageError,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			defer testcmd.VerifyNoLeaks(t)
+
+			clk := testcmd.NewFakeClock(now)
+			var gotDeadline time.Time
+			sub := testcmd.NewRecording("deploy", testcmd.WithExecute(func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+				gotDeadline, _ = ctx.Deadline()
+				return subcommands.ExitSuccess
+			}))
+			var stderr testcmd.Buffer
+			ctx := subcommandsutil.WithOutput(subcommandsutil.WithClock(context.Background(), clk), nil, &stderr)
+
+			status, _, _ := testcmd.Run(ctx, subcommandsutil.Timeout(sub, time.Hour), tt.args...)
+			testcmd.AssertStatus(t, status, tt.wantStatus)
+			if tt.wantStderr != "" && stderr.String() != tt.wantStderr {
+				t.Fatalf("wanted stderr %q but got %q", tt.wantStderr, stderr.String())
+			}
+			if tt.wantStatus != subcommands.ExitSuccess {
+				if sub.CallCount() != 0 {
+					t.Fatalf("wanted the command not executed but got %d calls", sub.CallCount())
+				}
+				return
+			}
+			if !gotDeadline.Equal(tt.wantDeadline) {
+				t.Fatalf("wanted the deadline %v but got %v", tt.wantDeadline, gotDeadline)
+			}
+		})
+	}
+}
+
+func TestTimeoutDeadlineClock(t *testing.T) {
+	defer testcmd.VerifyNoLeaks(t)
+
+	// years before the wall clock, which the deadline is not resolved on
+	now := time.Date(2001, 1, 2, 14, 0, 0, 0, time.UTC)
+	clk := testcmd.NewFakeClock(now)
+	var stderr testcmd.Buffer
+	ctx := subcommandsutil.WithOutput(subcommandsutil.WithClock(context.Background(), clk), nil, &stderr)
+	cmd := subcommandsutil.Timeout(testcmd.NewRecording("deploy"), 0)
+
+	status, _, _ := testcmd.Run(ctx, cmd, "-deadline", "2001-01-02T14:30:00Z")
+	testcmd.RequireSuccess(t, status)
+
+	clk.Advance(time.Hour)
+	status, _, _ = testcmd.Run(ctx, cmd, "-deadline", "2001-01-02T14:30:00Z")
+	testcmd.AssertStatus(t, status, subcommands.ExitUsageError)
+	if want := "deploy: deadline 2001-01-02T14:30:00Z already passed\n"; stderr.String() != want {
+		t.Fatalf("wanted stderr %q but got %q", want, stderr.String())
+	}
+}
@@ -0,0 +1,186 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/google/subcommands"
+)
+
+// ExplainCommand prints the usage of cmd to w with the template set by SetUsageTemplate. The
+// default template prints the usage of cmd followed by its flag defaults, like the default
+// explanation of subcommands.Commander, but renders the flags with PrintDefaults and lists the
+// examples of an Exampler. Install it with:
+//
+//	cdr.ExplainCommand = subcommandsutil.ExplainCommand
+func ExplainCommand(w io.Writer, cmd subcommands.Command) {
+	renderUsage(w, cmd)
+}
+
+// ExplainGroup returns a replacement of the group explanation of cdr which omits the commands
+// reported by IsHiddenCommand, and lists the commands created by Alias on the line of the command
+// they alias. Install it with:
+//
+//	cdr.ExplainGroup = subcommandsutil.ExplainGroup(cdr)
+func ExplainGroup(cdr *subcommands.Commander) func(w io.Writer, g *subcommands.CommandGroup) {
+	return func(w io.Writer, g *subcommands.CommandGroup) {
+		var cmds []subcommands.Command
+		cdr.VisitCommands(func(cg *subcommands.CommandGroup, cmd subcommands.Command) {
+			if cg == g && !IsHiddenCommand(cmd) {
+				cmds = append(cmds, cmd)
+			}
+		})
+		if len(cmds) == 0 {
+			return
+		}
+		sort.SliceStable(cmds, func(i, j int) bool { return cmds[i].Name() < cmds[j].Name() })
+
+		writeGroup(w, g.Name(), cmds, TerminalWidth(w))
+	}
+}
+
+// writeGroup writes the listing of the commands cmds of the group named name in the format of
+// subcommands.Commander, listing the commands created by Alias on the line of the command they
+// alias. The synopses are translated by the Messages set by SetMessages, and wrapped to width.
+func writeGroup(w io.Writer, name string, cmds []subcommands.Command, width int) {
+	m := defaultMessagesCatalog()
+	listed := make(map[string]bool, len(cmds))
+	for _, cmd := range cmds {
+		if _, ok := commandAlias(cmd); !ok {
+			listed[cmd.Name()] = true
+		}
+	}
+	aliases := make(map[string][]string)
+	for _, cmd := range cmds {
+		if a, ok := commandAlias(cmd); ok && listed[a.sub.Name()] {
+			aliases[a.sub.Name()] = append(aliases[a.sub.Name()], cmd.Name())
+		}
+	}
+
+	if name == "" {
+		fmt.Fprintf(w, "Subcommands:\n")
+	} else {
+		fmt.Fprintf(w, "Subcommands for %s:\n", name)
+	}
+	for _, cmd := range cmds {
+		if a, ok := commandAlias(cmd); ok && listed[a.sub.Name()] {
+			continue
+		}
+		names := strings.Join(append([]string{cmd.Name()}, aliases[cmd.Name()]...), ", ")
+		// the synopsis column starts after the tab, 8 columns wide, and the padded names
+		col := 8 + len(fmt.Sprintf("%-15s  ", names))
+		synopsis := wrapColumn(m.translate(cmd.Synopsis()), col, width, "\n\t"+strings.Repeat(" ", col-8))
+		fmt.Fprintf(w, "\t%-15s  %s\n", names, synopsis)
+	}
+	fmt.Fprintln(w)
+}
+
+// PrintDefaults prints the default values of the flags in f to f.Output(), in the same format as
+// flag.FlagSet.PrintDefaults.
+//
+// Flags hidden by HideFlags are skipped unless they are named in show. Aliases registered by
+// AliasFlag are rendered on the line of their canonical flag. The defaults of flags marked by
+// MarkSensitive are omitted. The flags put in groups by FlagGroup are listed under the titles of
+// their groups.
+func PrintDefaults(f *flag.FlagSet, show ...string) {
+	explicit := make(map[string]bool, len(show))
+	for _, name := range show {
+		explicit[name] = true
+	}
+
+	w := f.Output()
+	width := TerminalWidth(w)
+	var flags []*flag.Flag
+	var lines []string
+	f.VisitAll(func(fl *flag.Flag) {
+		if isAliasFlag(f, fl.Name) || (IsHiddenFlag(f, fl.Name) && !explicit[fl.Name]) {
+			return
+		}
+		flags = append(flags, fl)
+		lines = append(lines, formatFlag(fl, flagNames(f, fl), IsSensitiveFlag(f, fl.Name), width))
+	})
+
+	for i, s := range flagSections(f, flags, lines) {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		if s.title != "" {
+			fmt.Fprintf(w, "%s:\n", s.title)
+		}
+		for _, line := range s.items {
+			fmt.Fprint(w, line, "\n")
+		}
+	}
+}
+
+// formatFlag formats fl the same way flag.FlagSet.PrintDefaults does, listing all of names, with
+// the description wrapped to width. The default value of a sensitive flag is omitted.
+func formatFlag(fl *flag.Flag, names []string, sensitive bool, width int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "  -%s", strings.Join(names, ", -")) // two spaces before -, same as the flag package
+	name, usage := flag.UnquoteUsage(fl)
+	if len(name) > 0 {
+		b.WriteString(" ")
+		b.WriteString(name)
+	}
+
+	// boolean flags of one ASCII letter put their usage on the same line
+	if b.Len() <= 4 {
+		b.WriteString("\t")
+	} else {
+		b.WriteString("\n    \t")
+	}
+
+	if !sensitive && !isZeroValue(fl) {
+		if isStringValue(fl.Value) {
+			usage += fmt.Sprintf(" (default %q)", fl.DefValue)
+		} else {
+			usage += fmt.Sprintf(" (default %v)", fl.DefValue)
+		}
+	}
+	// the description column starts after the tab, 8 columns wide
+	b.WriteString(wrapColumn(usage, 8, width, "\n    \t"))
+
+	return b.String()
+}
+
+// isZeroValue reports whether the default value of fl is the zero value of its flag.Value type.
+func isZeroValue(fl *flag.Flag) (zero bool) {
+	defer func() {
+		if recover() != nil {
+			zero = false
+		}
+	}()
+
+	typ := reflect.TypeOf(fl.Value)
+	var z reflect.Value
+	if typ.Kind() == reflect.Ptr {
+		z = reflect.New(typ.Elem())
+	} else {
+		z = reflect.Zero(typ)
+	}
+
+	return fl.DefValue == z.Interface().(flag.Value).String()
+}
+
+// isStringValue reports whether v is the flag.Value created by flag.String or flag.StringVar.
+func isStringValue(v flag.Value) bool {
+	return reflect.TypeOf(v).String() == "*flag.stringValue"
+}
+
+// commandAlias returns the alias created by Alias that cmd is or wraps.
+func commandAlias(cmd subcommands.Command) (a *alias, ok bool) {
+	walkCommand(cmd, func(cmd subcommands.Command) bool {
+		a, ok = cmd.(*alias)
+		return ok
+	})
+
+	return a, ok
+}
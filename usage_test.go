@@ -0,0 +1,34 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"bytes"
+	"flag"
+	"testing"
+	"time"
+
+	"github.com/zchee/subcommandsutil"
+)
+
+// TestPrintDefaults verifies that PrintDefaults renders the same output as flag.FlagSet.PrintDefaults
+// when no flag carries any metadata.
+func TestPrintDefaults(t *testing.T) {
+	f := flag.NewFlagSet("test", flag.ContinueOnError)
+	f.Bool("v", false, "verbose output")
+	f.Bool("force", true, "force the operation")
+	f.String("output", "out.txt", "write the result to `file`")
+	f.Int("count", 0, "number of iterations")
+	f.Duration("timeout", time.Minute, "timeout of the operation\nincluding retries")
+
+	var want, got bytes.Buffer
+	f.SetOutput(&want)
+	f.PrintDefaults()
+	f.SetOutput(&got)
+	subcommandsutil.PrintDefaults(f)
+
+	if got.String() != want.String() {
+		t.Fatalf("wanted\n%s\nbut got\n%s", want.String(), got.String())
+	}
+}
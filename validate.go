@@ -0,0 +1,114 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"strconv"
+)
+
+// ValidatedVar defines a flag named name in f, with the usage usage, like f.Var, whose values are
+// checked by validate before they are passed to value. A value rejected by validate fails the
+// parsing of the command line, like "invalid value "70000" for flag -port: ...", so that
+// subcommands.Commander exits with subcommands.ExitUsageError.
+//
+// The values set by f.Set are validated too, like the ones prompted by RequireFlags or expanded by
+// ExpandEnvArgs.
+func ValidatedVar(f *flag.FlagSet, value flag.Value, name, usage string, validate func(string) error) {
+	f.Var(&validatedValue{Value: value, validate: validate}, name, usage)
+}
+
+// validatedValue is a flag.Value whose values are validated before they are set.
+type validatedValue struct {
+	flag.Value
+	validate func(string) error
+}
+
+// make sure validatedValue implements the flag.Getter interface.
+var _ flag.Getter = (*validatedValue)(nil)
+
+// Set implements flag.Value.
+func (v *validatedValue) Set(s string) error {
+	if err := v.validate(s); err != nil {
+		return err
+	}
+
+	return v.Value.Set(s)
+}
+
+// String implements flag.Value.
+func (v *validatedValue) String() string {
+	if v == nil || v.Value == nil {
+		return ""
+	}
+
+	return v.Value.String()
+}
+
+// Get implements flag.Getter, returning the value of the underlying flag.Value if it is a
+// flag.Getter.
+func (v *validatedValue) Get() interface{} {
+	if g, ok := v.Value.(flag.Getter); ok {
+		return g.Get()
+	}
+
+	return v.Value.String()
+}
+
+// IsBoolFlag reports whether the underlying flag.Value is a bool flag, which the flag package
+// accepts without a value.
+func (v *validatedValue) IsBoolFlag() bool {
+	b, ok := v.Value.(interface{ IsBoolFlag() bool })
+
+	return ok && b.IsBoolFlag()
+}
+
+// ValidateURL is a validator of ValidatedVar accepting the absolute URLs, with a scheme and a host.
+func ValidateURL(s string) error {
+	u, err := url.Parse(s)
+	switch {
+	case err != nil:
+		return fmt.Errorf("invalid URL %q", s)
+	case u.Scheme == "":
+		return fmt.Errorf("invalid URL %q: missing scheme", s)
+	case u.Host == "":
+		return fmt.Errorf("invalid URL %q: missing host", s)
+	}
+
+	return nil
+}
+
+// ValidatePort is a validator of ValidatedVar accepting the TCP and UDP port numbers, from 1 to
+// 65535.
+func ValidatePort(s string) error {
+	port, err := strconv.Atoi(s)
+	switch {
+	case err != nil:
+		return fmt.Errorf("invalid port %q", s)
+	case port < 1 || port > 65535:
+		return fmt.Errorf("port %d out of range 1-65535", port)
+	}
+
+	return nil
+}
+
+// ValidateDirExists is a validator of ValidatedVar accepting the paths of existing directories.
+func ValidateDirExists(s string) error {
+	fi, err := os.Stat(s)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return fmt.Errorf("directory %q does not exist", s)
+	case err != nil:
+		return err
+	case !fi.IsDir():
+		return fmt.Errorf("%q is not a directory", s)
+	}
+
+	return nil
+}
@@ -0,0 +1,127 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+func TestValidatedVar(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file")
+	if err := os.WriteFile(file, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]struct {
+		value      string
+		validate   func(string) error
+		wantStatus subcommands.ExitStatus
+		wantStderr string
+	}{
+		"when the URL is valid": {
+			value:    "https://example.com/api",
+			validate: subcommandsutil.ValidateURL,
+		},
+		"when the URL has no scheme": {
+			value:      "example.com/api",
+			validate:   subcommandsutil.ValidateURL,
+			wantStatus: subcommands.ExitUsageError,
+			wantStderr: `invalid value "example.com/api" for flag -value: invalid URL "example.com/api": missing scheme`,
+		},
+		"when the URL has no host": {
+			value:      "file:///etc/hosts",
+			validate:   subcommandsutil.ValidateURL,
+			wantStatus: subcommands.ExitUsageError,
+			wantStderr: `invalid value "file:///etc/hosts" for flag -value: invalid URL "file:///etc/hosts": missing host`,
+		},
+		"when the port is valid": {
+			value:    "8080",
+			validate: subcommandsutil.ValidatePort,
+		},
+		"when the port is not a number": {
+			value:      "http",
+			validate:   subcommandsutil.ValidatePort,
+			wantStatus: subcommands.ExitUsageError,
+			wantStderr: `invalid value "http" for flag -value: invalid port "http"`,
+		},
+		"when the port is out of range": {
+			value:      "70000",
+			validate:   subcommandsutil.ValidatePort,
+			wantStatus: subcommands.ExitUsageError,
+			wantStderr: `invalid value "70000" for flag -value: port 70000 out of range 1-65535`,
+		},
+		"when the directory exists": {
+			value:    dir,
+			validate: subcommandsutil.ValidateDirExists,
+		},
+		"when the directory does not exist": {
+			value:      filepath.Join(dir, "missing"),
+			validate:   subcommandsutil.ValidateDirExists,
+			wantStatus: subcommands.ExitUsageError,
+			wantStderr: fmt.Sprintf("invalid value %q for flag -value: directory %q does not exist", filepath.Join(dir, "missing"), filepath.Join(dir, "missing")),
+		},
+		"when the path is a file": {
+			value:      file,
+			validate:   subcommandsutil.ValidateDirExists,
+			wantStatus: subcommands.ExitUsageError,
+			wantStderr: fmt.Sprintf("invalid value %q for flag -value: %q is not a directory", file, file),
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			h := testcmd.NewHarness(t)
+			var value formatValue
+			sub := testcmd.NewRecording("serve", testcmd.WithFlags(func(f *flag.FlagSet) {
+				subcommandsutil.ValidatedVar(f, &value, "value", "the value", tt.validate)
+			}))
+			h.Register(sub, "")
+			if err := h.Flags.Parse([]string{"serve", "-value", tt.value}); err != nil {
+				t.Fatal(err)
+			}
+
+			status := h.Commander.Execute(context.Background())
+			testcmd.AssertStatus(t, status, tt.wantStatus)
+			if tt.wantStatus != subcommands.ExitSuccess {
+				if !strings.HasPrefix(h.Stderr.String(), tt.wantStderr+"\n") {
+					t.Fatalf("wanted the stderr to start with %q but got %q", tt.wantStderr, h.Stderr)
+				}
+				if value != "" {
+					t.Fatalf("wanted the value left unset but got %q", value)
+				}
+				return
+			}
+			if string(value) != tt.value {
+				t.Fatalf("wanted %q but got %q", tt.value, value)
+			}
+		})
+	}
+}
+
+func TestValidatedVarFromEnv(t *testing.T) {
+	t.Setenv("APP_PORT", "70000")
+
+	f := flag.NewFlagSet("serve", flag.ContinueOnError)
+	var port formatValue
+	subcommandsutil.ValidatedVar(f, &port, "port", "listen on `port`", subcommandsutil.ValidatePort)
+
+	err := f.Set("port", os.Getenv("APP_PORT"))
+	if want := "port 70000 out of range 1-65535"; err == nil || err.Error() != want {
+		t.Fatalf("wanted the error %q but got %v", want, err)
+	}
+	if port != "" {
+		t.Fatalf("wanted the port left unset but got %q", port)
+	}
+}
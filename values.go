@@ -0,0 +1,53 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+)
+
+// valueKey is the context key of the value of type T set by WithValue.
+type valueKey[T any] struct{}
+
+// WithValue returns a copy of ctx carrying v as the value of type T, replacing the value of type T
+// ctx already carries. It shares dependencies, like an API client or a configuration, with the
+// commands without passing them positionally as the args of Execute:
+//
+//	ctx = subcommandsutil.WithValue(ctx, client)
+//	...
+//	client := subcommandsutil.MustValue[*api.Client](ctx)
+//
+// The values of distinct types never collide, even when they share an underlying type.
+func WithValue[T any](ctx context.Context, v T) context.Context {
+	return context.WithValue(ctx, valueKey[T]{}, v)
+}
+
+// Value returns the value of type T carried by ctx, and whether there is one.
+func Value[T any](ctx context.Context) (T, bool) {
+	v, ok := ctx.Value(valueKey[T]{}).(T)
+
+	return v, ok
+}
+
+// MustValue is like Value, but panics with the name of T if ctx carries no value of type T.
+func MustValue[T any](ctx context.Context) T {
+	v, ok := Value[T](ctx)
+	if !ok {
+		panic(fmt.Sprintf("subcommandsutil: no value of type %v in the context", reflect.TypeOf((*T)(nil)).Elem()))
+	}
+
+	return v
+}
+
+// Injector adds values to a context. It is created by Inject.
+type Injector func(ctx context.Context) context.Context
+
+// Inject returns an Injector setting v as the value of type T with WithValue.
+func Inject[T any](v T) Injector {
+	return func(ctx context.Context) context.Context {
+		return WithValue(ctx, v)
+	}
+}
@@ -0,0 +1,90 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"context"
+	"flag"
+	"testing"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+// apiClient and config are dependencies shared with the commands.
+type (
+	apiClient struct{ endpoint string }
+	config    struct{ region string }
+)
+
+// userName and hostName share the underlying type string.
+type (
+	userName string
+	hostName string
+)
+
+func TestValue(t *testing.T) {
+	ctx := context.Background()
+	ctx = subcommandsutil.WithValue(ctx, &apiClient{endpoint: "https://api.example.com"})
+	ctx = subcommandsutil.WithValue(ctx, config{region: "us-east-1"})
+	ctx = subcommandsutil.WithValue(ctx, userName("gopher"))
+
+	if got, ok := subcommandsutil.Value[*apiClient](ctx); !ok || got.endpoint != "https://api.example.com" {
+		t.Fatalf("wanted the API client but got %v, %v", got, ok)
+	}
+	if got, ok := subcommandsutil.Value[config](ctx); !ok || got.region != "us-east-1" {
+		t.Fatalf("wanted the config but got %v, %v", got, ok)
+	}
+	if got, ok := subcommandsutil.Value[userName](ctx); !ok || got != "gopher" {
+		t.Fatalf("wanted the user name but got %q, %v", got, ok)
+	}
+	if got, ok := subcommandsutil.Value[hostName](ctx); ok {
+		t.Fatalf("wanted no host name as it shares the type of the user name only underneath but got %q", got)
+	}
+	if got, ok := subcommandsutil.Value[string](ctx); ok {
+		t.Fatalf("wanted no plain string but got %q", got)
+	}
+
+	ctx = subcommandsutil.WithValue(ctx, config{region: "eu-west-1"})
+	if got := subcommandsutil.MustValue[config](ctx); got.region != "eu-west-1" {
+		t.Fatalf("wanted the config to be replaced but got %v", got)
+	}
+}
+
+func TestMustValue(t *testing.T) {
+	defer func() {
+		want := "subcommandsutil: no value of type subcommandsutil_test.hostName in the context"
+		if got := recover(); got != want {
+			t.Fatalf("wanted the panic %q but got %v", want, got)
+		}
+	}()
+
+	subcommandsutil.MustValue[hostName](context.Background())
+}
+
+func TestCancelableCommanderProvide(t *testing.T) {
+	top := flag.NewFlagSet("tool", flag.ContinueOnError)
+	cdr := subcommandsutil.NewCancelableCommander(top, "tool")
+	cdr.Provide(subcommandsutil.Inject(&apiClient{endpoint: "https://api.example.com"}), subcommandsutil.Inject(config{region: "us-east-1"}))
+
+	var got []string
+	for _, name := range []string{"deploy", "status"} {
+		cdr.Register(testcmd.NewRecording(name, testcmd.WithExecute(func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+			got = append(got, subcommandsutil.MustValue[*apiClient](ctx).endpoint+" "+subcommandsutil.MustValue[config](ctx).region)
+			return subcommands.ExitSuccess
+		})), "")
+	}
+
+	for _, name := range []string{"deploy", "status"} {
+		if err := top.Parse([]string{name}); err != nil {
+			t.Fatal(err)
+		}
+		testcmd.RequireSuccess(t, cdr.Execute(context.Background()))
+	}
+	if len(got) != 2 || got[0] != "https://api.example.com us-east-1" || got[1] != got[0] {
+		t.Fatalf("wanted every dispatch to get the provided values but got %q", got)
+	}
+}
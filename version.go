@@ -0,0 +1,175 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"runtime"
+	"runtime/debug"
+
+	"github.com/google/subcommands"
+)
+
+// VersionInfo describes the build of the program, as printed by VersionCommand.
+type VersionInfo struct {
+	// Version is the version set by WithVersion, or the version of the main module.
+	Version string `json:"version"`
+
+	// Module is the path of the main module.
+	Module string `json:"module,omitempty"`
+
+	// Revision is the VCS revision the program was built from.
+	Revision string `json:"revision,omitempty"`
+
+	// Time is the time of the VCS revision, in RFC 3339 format.
+	Time string `json:"time,omitempty"`
+
+	// Modified reports whether the working tree had local modifications at build time.
+	Modified bool `json:"modified,omitempty"`
+
+	// GoVersion is the version of the Go toolchain which built the program.
+	GoVersion string `json:"go"`
+}
+
+// newVersionInfo returns the VersionInfo of bi, with version overriding the version of the main
+// module unless it is empty.
+func newVersionInfo(bi *debug.BuildInfo, version string) VersionInfo {
+	v := VersionInfo{
+		Version:   bi.Main.Version,
+		Module:    bi.Main.Path,
+		GoVersion: bi.GoVersion,
+	}
+	if version != "" {
+		v.Version = version
+	}
+	if v.Version == "" {
+		v.Version = "(devel)"
+	}
+	for _, s := range bi.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			v.Revision = s.Value
+		case "vcs.time":
+			v.Time = s.Value
+		case "vcs.modified":
+			v.Modified = s.Value == "true"
+		}
+	}
+
+	return v
+}
+
+// String renders v on a line per field, like:
+//
+//	version:  v1.2.0
+//	module:   example.com/tool
+//	revision: 0123456789ab (dirty)
+//	time:     2021-01-02T03:04:05Z
+//	go:       go1.21.0
+func (v VersionInfo) String() string {
+	s := fmt.Sprintf("version:  %s\n", v.Version)
+	if v.Module != "" {
+		s += fmt.Sprintf("module:   %s\n", v.Module)
+	}
+	if v.Revision != "" {
+		s += fmt.Sprintf("revision: %s", v.Revision)
+		if v.Modified {
+			s += " (dirty)"
+		}
+		s += "\n"
+	}
+	if v.Time != "" {
+		s += fmt.Sprintf("time:     %s\n", v.Time)
+	}
+
+	return s + fmt.Sprintf("go:       %s\n", v.GoVersion)
+}
+
+// VersionOption is an option of the VersionCommand command.
+type VersionOption interface {
+	applyVersion(*version)
+}
+
+// versionOptionFunc is a VersionOption implemented by a function.
+type versionOptionFunc func(*version)
+
+// applyVersion implements VersionOption.
+func (fn versionOptionFunc) applyVersion(c *version) { fn(c) }
+
+// WithVersion sets the version printed by VersionCommand instead of the version of the main
+// module, usually a variable set with -ldflags "-X".
+func WithVersion(v string) VersionOption {
+	return versionOptionFunc(func(c *version) {
+		c.version = v
+	})
+}
+
+// WithBuildInfo sets the build information printed by VersionCommand instead of the one returned by
+// debug.ReadBuildInfo.
+func WithBuildInfo(bi *debug.BuildInfo) VersionOption {
+	return versionOptionFunc(func(c *version) {
+		c.readBuildInfo = func() (*debug.BuildInfo, bool) { return bi, true }
+	})
+}
+
+// version is the command printing the VersionInfo of the program.
+type version struct {
+	version       string
+	readBuildInfo func() (*debug.BuildInfo, bool)
+}
+
+// make sure version implements the subcommands.Command interface.
+var _ subcommands.Command = (*version)(nil)
+
+// VersionCommand returns a command named "version" printing the VersionInfo of the program: its
+// version, the VCS revision and time it was built from, marked dirty if the working tree was
+// modified, and the Go version, read by debug.ReadBuildInfo. With -json, the VersionInfo is
+// printed as JSON.
+func VersionCommand(opts ...VersionOption) subcommands.Command {
+	c := &version{
+		readBuildInfo: debug.ReadBuildInfo,
+	}
+	for _, opt := range opts {
+		opt.applyVersion(c)
+	}
+
+	return ResultCommand(c)
+}
+
+// Name implements subcommands.Command.
+func (c *version) Name() string {
+	return "version"
+}
+
+// Synopsis implements subcommands.Command.
+func (c *version) Synopsis() string {
+	return "print the version"
+}
+
+// Usage implements subcommands.Command.
+func (c *version) Usage() string {
+	return "version [-json]:\n  Print the version of the program.\n"
+}
+
+// SetFlags implements subcommands.Command. The -json flag is registered by ResultCommand.
+func (c *version) SetFlags(*flag.FlagSet) {}
+
+// Execute implements subcommands.Command.
+func (c *version) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	bi, ok := c.readBuildInfo()
+	if !ok {
+		bi = &debug.BuildInfo{GoVersion: runtime.Version()}
+	}
+	v := newVersionInfo(bi, c.version)
+
+	if JSONRequested(ctx) {
+		EmitResult(ctx, v)
+		return subcommands.ExitSuccess
+	}
+	fmt.Fprint(Stdout(ctx), v)
+
+	return subcommands.ExitSuccess
+}
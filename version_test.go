@@ -0,0 +1,69 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"context"
+	"runtime/debug"
+	"testing"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+func TestVersionCommand(t *testing.T) {
+	bi := &debug.BuildInfo{
+		GoVersion: "go1.21.0",
+		Main:      debug.Module{Path: "example.com/tool", Version: "v1.2.0"},
+		Settings: []debug.BuildSetting{
+			{Key: "vcs", Value: "git"},
+			{Key: "vcs.revision", Value: "0123456789abcdef"},
+			{Key: "vcs.time", Value: "2021-01-02T03:04:05Z"},
+			{Key: "vcs.modified", Value: "true"},
+		},
+	}
+	tests := map[string]struct {
+		bi         *debug.BuildInfo
+		opts       []subcommandsutil.VersionOption
+		args       []string
+		wantStdout string
+	}{
+		"when printing the build info": {
+			bi: bi,
+			wantStdout: "version:  v1.2.0\n" +
+				"module:   example.com/tool\n" +
+				"revision: 0123456789abcdef (dirty)\n" +
+				"time:     2021-01-02T03:04:05Z\n" +
+				"go:       go1.21.0\n",
+		},
+		"when the version is set by the application": {
+			bi:   bi,
+			opts: []subcommandsutil.VersionOption{subcommandsutil.WithVersion("1.2.0-rc.1")},
+			args: []string{"-json"},
+			wantStdout: `{"version":"1.2.0-rc.1","module":"example.com/tool","revision":"0123456789abcdef",` +
+				`"time":"2021-01-02T03:04:05Z","modified":true,"go":"go1.21.0"}` + "\n",
+		},
+		"when built without VCS information": {
+			bi: &debug.BuildInfo{
+				GoVersion: "go1.21.0",
+				Main:      debug.Module{Path: "example.com/tool"},
+			},
+			wantStdout: "version:  (devel)\n" +
+				"module:   example.com/tool\n" +
+				"go:       go1.21.0\n",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			cmd := subcommandsutil.VersionCommand(append(tt.opts, subcommandsutil.WithBuildInfo(tt.bi))...)
+
+			var stdout, stderr testcmd.Buffer
+			status, _, _ := testcmd.Run(subcommandsutil.WithOutput(context.Background(), &stdout, &stderr), cmd, tt.args...)
+			testcmd.RequireSuccess(t, status)
+			if got := stdout.String(); got != tt.wantStdout {
+				t.Fatalf("wanted the output %q but got %q", tt.wantStdout, got)
+			}
+		})
+	}
+}
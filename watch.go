@@ -0,0 +1,404 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/subcommands"
+)
+
+// Watcher reports the changes to a set of files. It can be backed by fsnotify, or by the polling
+// of NewPollingWatcher.
+type Watcher interface {
+	// Events returns the channel receiving the path of each file created, changed or removed. The
+	// channel is closed when the watcher stops.
+	Events() <-chan string
+
+	// Close stops the watcher.
+	Close() error
+}
+
+// WatcherFunc creates a Watcher of the files matched by patterns, stopping when ctx is done. See
+// WithWatchPaths for the format of patterns.
+type WatcherFunc func(ctx context.Context, patterns []string) (Watcher, error)
+
+// WatchOption is an option of the Watch wrapper.
+type WatchOption interface {
+	applyWatch(*watch)
+}
+
+// watchOptionFunc is a WatchOption implemented by a function.
+type watchOptionFunc func(*watch)
+
+// applyWatch implements WatchOption.
+func (fn watchOptionFunc) applyWatch(c *watch) { fn(c) }
+
+// WithWatchPaths sets the files watched by Watch, "./..." by default. A pattern is a file, a
+// directory whose files are watched, a directory followed by "/..." whose files are watched
+// recursively, or a glob in the syntax of filepath.Match. Recursive patterns skip the directories
+// whose name starts with a dot.
+func WithWatchPaths(patterns ...string) WatchOption {
+	return watchOptionFunc(func(c *watch) {
+		c.patterns = append(c.patterns, patterns...)
+	})
+}
+
+// WithWatcher sets the function creating the Watcher of Watch. It defaults to a polling watcher
+// checking the files every second.
+func WithWatcher(fn WatcherFunc) WatchOption {
+	return watchOptionFunc(func(c *watch) {
+		c.newWatcher = fn
+	})
+}
+
+// WithDebounce sets how long Watch waits without further changes before rerunning the command,
+// 100 milliseconds by default.
+func WithDebounce(d time.Duration) WatchOption {
+	return watchOptionFunc(func(c *watch) {
+		c.debounce = d
+	})
+}
+
+// watchIterationKey is the context key of the iteration of Watch.
+type watchIterationKey struct{}
+
+// WatchIteration returns the iteration of the run of the command by Watch carried by ctx, starting
+// at 1, or 0 outside of Watch.
+func WatchIteration(ctx context.Context) int {
+	n, _ := ctx.Value(watchIterationKey{}).(int)
+
+	return n
+}
+
+// watch wraps a CancelableCommand so that it reruns on file changes.
+type watch struct {
+	sub CancelableCommand
+
+	patterns   []string
+	newWatcher WatcherFunc
+	debounce   time.Duration
+
+	watch bool
+}
+
+// make sure watch implements the CancelableCommand interface.
+var _ CancelableCommand = (*watch)(nil)
+
+// Watch wraps sub with the -watch flag. When set, sub is run, then the files set by WithWatchPaths
+// are watched, and sub is rerun once they stop changing for the duration set by WithDebounce. An
+// execution still running when the files change is canceled and disposed first, and waited for.
+// Each execution gets a fresh child context carrying its iteration, returned by WatchIteration.
+//
+// Watch returns when the execution context is done or the Watcher stops, canceling and disposing
+// the running execution, with the status of the last execution that finished, or subcommands.ExitFailure if
+// none did.
+func Watch(sub CancelableCommand, opts ...WatchOption) CancelableCommand {
+	c := &watch{
+		sub:      sub,
+		debounce: 100 * time.Millisecond,
+		newWatcher: func(ctx context.Context, patterns []string) (Watcher, error) {
+			return NewPollingWatcher(ctx, patterns, time.Second)
+		},
+	}
+	for _, opt := range opts {
+		opt.applyWatch(c)
+	}
+	if len(c.patterns) == 0 {
+		c.patterns = []string{"./..."}
+	}
+
+	return c
+}
+
+// Name forwards to the underlying c.sub Command.
+func (c *watch) Name() string {
+	return c.sub.Name()
+}
+
+// Usage forwards to the underlying c.sub Command.
+func (c *watch) Usage() string {
+	return c.sub.Usage()
+}
+
+// Synopsis forwards to the underlying c.sub Command.
+func (c *watch) Synopsis() string {
+	return c.sub.Synopsis()
+}
+
+// Unwrap returns the underlying c.sub Command.
+func (c *watch) Unwrap() subcommands.Command {
+	return c.sub
+}
+
+// SetFlags forwards to the underlying c.sub Command and registers the -watch flag.
+func (c *watch) SetFlags(f *flag.FlagSet) {
+	c.sub.SetFlags(f)
+	f.BoolVar(&c.watch, "watch", false, "rerun when the watched files change")
+}
+
+// Dispose forwards to the underlying c.sub Command.
+func (c *watch) Dispose() error {
+	return c.sub.Dispose()
+}
+
+// Execute forwards to the underlying c.sub Command, rerunning it on file changes if the -watch flag
+// is set.
+func (c *watch) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	if !c.watch {
+		return c.sub.Execute(ctx, f, args...)
+	}
+
+	watchCtx, stopWatch := context.WithCancel(ctx)
+	defer stopWatch()
+	w, err := c.newWatcher(watchCtx, c.patterns)
+	if err != nil {
+		fmt.Fprintf(Stderr(ctx), "%s: watching: %v\n", c.sub.Name(), err)
+		return subcommands.ExitFailure
+	}
+	defer w.Close()
+
+	clk := ClockFromContext(ctx)
+	status := subcommands.ExitFailure
+	iteration := 0
+
+	var (
+		cancel context.CancelFunc
+		done   chan subcommands.ExitStatus // nil when no execution is running
+	)
+	run := func() {
+		iteration++
+		var runCtx context.Context
+		runCtx, cancel = context.WithCancel(context.WithValue(ctx, watchIterationKey{}, iteration))
+		done = make(chan subcommands.ExitStatus, 1)
+		go func(done chan<- subcommands.ExitStatus) {
+			done <- c.sub.Execute(runCtx, f, args...)
+		}(done)
+	}
+	stop := func() {
+		if done == nil {
+			return
+		}
+		cancel()
+		_ = c.sub.Dispose()
+		<-done
+		done = nil
+	}
+	defer stop()
+
+	var (
+		changed  []string
+		debounce Timer
+		fire     <-chan time.Time
+	)
+	defer func() {
+		if debounce != nil {
+			debounce.Stop()
+		}
+	}()
+	run()
+	for {
+		select {
+		case <-ctx.Done():
+			return status
+
+		case status = <-done:
+			cancel()
+			done = nil
+			fmt.Fprintf(Info(ctx), "%s: watching for changes\n", c.sub.Name())
+
+		case path, ok := <-w.Events():
+			if !ok {
+				return status
+			}
+			changed = append(changed, path)
+			if debounce == nil {
+				debounce = clk.NewTimer(c.debounce)
+			} else {
+				if !debounce.Stop() {
+					select {
+					case <-debounce.C():
+					default:
+					}
+				}
+				debounce.Reset(c.debounce)
+			}
+			fire = debounce.C()
+
+		case <-fire:
+			fire = nil
+			stop()
+			fmt.Fprintf(Info(ctx), "%s: %s changed; rerunning\n", c.sub.Name(), describeChanges(changed))
+			changed = nil
+			run()
+		}
+	}
+}
+
+// describeChanges describes the changed paths, naming at most three of them.
+func describeChanges(paths []string) string {
+	seen := make(map[string]bool, len(paths))
+	var unique []string
+	for _, p := range paths {
+		if !seen[p] {
+			seen[p] = true
+			unique = append(unique, p)
+		}
+	}
+	sort.Strings(unique)
+	if len(unique) > 3 {
+		return fmt.Sprintf("%s and %d more", strings.Join(unique[:3], ", "), len(unique)-3)
+	}
+
+	return strings.Join(unique, ", ")
+}
+
+// pollingWatcher is a Watcher polling the modification times and sizes of the files.
+type pollingWatcher struct {
+	patterns []string
+	events   chan string
+
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+// fileStamp identifies a version of a file.
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// NewPollingWatcher returns a Watcher checking the files matched by patterns every interval,
+// measured on the Clock of ctx, until ctx is done or the Watcher is closed. See WithWatchPaths for
+// the format of patterns.
+func NewPollingWatcher(ctx context.Context, patterns []string, interval time.Duration) (Watcher, error) {
+	for _, pattern := range patterns {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+	}
+
+	w := &pollingWatcher{
+		patterns: patterns,
+		events:   make(chan string),
+		closed:   make(chan struct{}),
+	}
+	stamps := w.scan()
+	ticker := ClockFromContext(ctx).NewTicker(interval)
+	go func() {
+		defer close(w.events)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-w.closed:
+				return
+			case <-ticker.C():
+			}
+
+			next := w.scan()
+			for _, path := range diffStamps(stamps, next) {
+				select {
+				case w.events <- path:
+				case <-ctx.Done():
+					return
+				case <-w.closed:
+					return
+				}
+			}
+			stamps = next
+		}
+	}()
+
+	return w, nil
+}
+
+// Events implements Watcher.
+func (w *pollingWatcher) Events() <-chan string {
+	return w.events
+}
+
+// Close implements Watcher.
+func (w *pollingWatcher) Close() error {
+	w.closeOnce.Do(func() { close(w.closed) })
+
+	return nil
+}
+
+// scan returns the stamps of the files matched by the patterns.
+func (w *pollingWatcher) scan() map[string]fileStamp {
+	stamps := make(map[string]fileStamp)
+	add := func(path string, fi fs.FileInfo) {
+		if !fi.IsDir() {
+			stamps[path] = fileStamp{modTime: fi.ModTime(), size: fi.Size()}
+		}
+	}
+
+	for _, pattern := range w.patterns {
+		if dir, ok := strings.CutSuffix(pattern, "/..."); ok {
+			_ = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+				if err != nil {
+					return nil
+				}
+				if d.IsDir() && path != dir && strings.HasPrefix(d.Name(), ".") {
+					return filepath.SkipDir
+				}
+				if fi, err := d.Info(); err == nil {
+					add(path, fi)
+				}
+				return nil
+			})
+			continue
+		}
+
+		matches, _ := filepath.Glob(pattern)
+		for _, path := range matches {
+			fi, err := os.Stat(path)
+			if err != nil {
+				continue
+			}
+			if !fi.IsDir() {
+				add(path, fi)
+				continue
+			}
+			entries, _ := os.ReadDir(path)
+			for _, e := range entries {
+				if fi, err := e.Info(); err == nil {
+					add(filepath.Join(path, e.Name()), fi)
+				}
+			}
+		}
+	}
+
+	return stamps
+}
+
+// diffStamps returns the sorted paths created, changed or removed between prev and next.
+func diffStamps(prev, next map[string]fileStamp) []string {
+	var changed []string
+	for path, stamp := range next {
+		if old, ok := prev[path]; !ok || !old.modTime.Equal(stamp.modTime) || old.size != stamp.size {
+			changed = append(changed, path)
+		}
+	}
+	for path := range prev {
+		if _, ok := next[path]; !ok {
+			changed = append(changed, path)
+		}
+	}
+	sort.Strings(changed)
+
+	return changed
+}
@@ -0,0 +1,192 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"context"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+// waitFor polls cond until it reports true, failing the test after a few seconds.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// iterations records the iterations passed to a command run by Watch.
+type iterations struct {
+	mu   sync.Mutex
+	seen []int
+}
+
+// add records the iteration carried by ctx.
+func (it *iterations) add(ctx context.Context) {
+	it.mu.Lock()
+	defer it.mu.Unlock()
+
+	it.seen = append(it.seen, subcommandsutil.WatchIteration(ctx))
+}
+
+// get returns the iterations recorded.
+func (it *iterations) get() []int {
+	it.mu.Lock()
+	defer it.mu.Unlock()
+
+	return append([]int(nil), it.seen...)
+}
+
+func TestWatch(t *testing.T) {
+	tests := map[string]struct {
+		// block makes the first execution run until its context is canceled.
+		block bool
+	}{
+		"when the execution finished before the change": {},
+		"when the execution is running at the change": {
+			block: true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			defer testcmd.VerifyNoLeaks(t)
+
+			dir := t.TempDir()
+			src := filepath.Join(dir, "main.go")
+			writeFile(t, src, "package main\n")
+
+			var it iterations
+			sub := testcmd.NewRecording("build", testcmd.WithExecute(func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+				it.add(ctx)
+				if tt.block && subcommandsutil.WatchIteration(ctx) == 1 {
+					<-ctx.Done()
+					return subcommands.ExitFailure
+				}
+				return subcommands.ExitSuccess
+			}))
+			cmd := subcommandsutil.Watch(sub,
+				subcommandsutil.WithWatchPaths(dir+"/..."),
+				subcommandsutil.WithDebounce(20*time.Millisecond),
+				subcommandsutil.WithWatcher(func(ctx context.Context, patterns []string) (subcommandsutil.Watcher, error) {
+					return subcommandsutil.NewPollingWatcher(ctx, patterns, 10*time.Millisecond)
+				}),
+			)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			var stdout, stderr testcmd.Buffer
+			ctx = subcommandsutil.WithOutput(ctx, &stdout, &stderr)
+			done := make(chan subcommands.ExitStatus, 1)
+			go func() {
+				status, _, _ := testcmd.Run(ctx, cmd, "-watch", "./...")
+				done <- status
+			}()
+
+			waitFor(t, "the first execution", func() bool { return len(it.get()) == 1 })
+			writeFile(t, src, "package main\n\nfunc main() {}\n")
+			waitFor(t, "the rerun", func() bool { return len(it.get()) == 2 })
+			cancel()
+			testcmd.RequireSuccess(t, <-done)
+
+			if got := it.get(); got[0] != 1 || got[1] != 2 {
+				t.Fatalf("wanted the iterations [1 2] but got %v", got)
+			}
+			if call, _ := sub.LastCall(); len(call.Args) != 1 || call.Args[0] != "./..." {
+				t.Fatalf("wanted the rerun to get the arguments [./...] but got %v", call.Args)
+			}
+			if tt.block && sub.DisposeCount() != 1 {
+				t.Fatalf("wanted the running execution to be disposed once but got %d", sub.DisposeCount())
+			}
+			if want := "build: " + src + " changed; rerunning\n"; !strings.Contains(stdout.String(), want) {
+				t.Fatalf("wanted stdout to hold %q but got %q", want, stdout.String())
+			}
+		})
+	}
+}
+
+// fakeWatcher is a Watcher whose events are sent by the test.
+type fakeWatcher struct {
+	events chan string
+}
+
+// Events implements subcommandsutil.Watcher.
+func (w *fakeWatcher) Events() <-chan string { return w.events }
+
+// Close implements subcommandsutil.Watcher.
+func (w *fakeWatcher) Close() error { return nil }
+
+func TestWatchDebounce(t *testing.T) {
+	defer testcmd.VerifyNoLeaks(t)
+
+	w := &fakeWatcher{events: make(chan string)}
+	var it iterations
+	sub := testcmd.NewRecording("build", testcmd.WithExecute(func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+		it.add(ctx)
+		return subcommands.ExitSuccess
+	}))
+	cmd := subcommandsutil.Watch(sub,
+		subcommandsutil.WithDebounce(50*time.Millisecond),
+		subcommandsutil.WithWatcher(func(ctx context.Context, patterns []string) (subcommandsutil.Watcher, error) {
+			if len(patterns) != 1 || patterns[0] != "./..." {
+				t.Errorf("wanted the default patterns [./...] but got %q", patterns)
+			}
+			return w, nil
+		}),
+	)
+
+	var stdout, stderr testcmd.Buffer
+	ctx := subcommandsutil.WithOutput(context.Background(), &stdout, &stderr)
+	done := make(chan subcommands.ExitStatus, 1)
+	go func() {
+		status, _, _ := testcmd.Run(ctx, cmd, "-watch")
+		done <- status
+	}()
+
+	waitFor(t, "the first execution", func() bool { return len(it.get()) == 1 })
+	for _, path := range []string{"b.go", "a.go", "b.go", "c.go", "d.go"} {
+		w.events <- path
+	}
+	waitFor(t, "the rerun", func() bool { return len(it.get()) == 2 })
+	close(w.events)
+	testcmd.RequireSuccess(t, <-done)
+
+	if got := len(it.get()); got != 2 {
+		t.Fatalf("wanted the burst of changes to rerun once but got %d executions", got)
+	}
+	if want := "build: a.go, b.go, c.go and 1 more changed; rerunning\n"; !strings.Contains(stdout.String(), want) {
+		t.Fatalf("wanted stdout to hold %q but got %q", want, stdout.String())
+	}
+}
+
+func TestWatchDisabled(t *testing.T) {
+	var it iterations
+	sub := testcmd.NewRecording("build", testcmd.WithExecute(func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+		it.add(ctx)
+		return subcommands.ExitSuccess
+	}))
+	status, _, err := testcmd.Run(context.Background(), subcommandsutil.Watch(sub, subcommandsutil.WithWatchPaths(os.DevNull)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	testcmd.RequireSuccess(t, status)
+	if got := it.get(); len(got) != 1 || got[0] != 0 {
+		t.Fatalf("wanted a single execution outside of watch mode but got the iterations %v", got)
+	}
+}
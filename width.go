@@ -0,0 +1,58 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ColumnsEnv is the environment variable overriding the width usage text is wrapped to.
+const ColumnsEnv = "COLUMNS"
+
+// DefaultWidth is the width usage text is wrapped to when w is not a terminal and COLUMNS is not
+// set.
+const DefaultWidth = 80
+
+// minWrapWidth is the narrowest a column of usage text is wrapped to.
+const minWrapWidth = 20
+
+// TerminalWidth returns the width usage text written to w is wrapped to: the value of COLUMNS if
+// set to a positive number, else the width of the terminal w is, else DefaultWidth.
+func TerminalWidth(w io.Writer) int {
+	if n, err := strconv.Atoi(os.Getenv(ColumnsEnv)); err == nil && n > 0 {
+		return n
+	}
+	if f, ok := w.(*os.File); ok {
+		if n, ok := terminalWidthFile(f); ok && n > 0 {
+			return n
+		}
+	}
+
+	return DefaultWidth
+}
+
+// wrapColumn wraps s to fit in a column starting at byte offset col of lines of width bytes, and
+// joins the lines with sep. The line breaks of s are kept, and lines fitting the column are kept
+// as is.
+func wrapColumn(s string, col, width int, sep string) string {
+	width -= col
+	if width < minWrapWidth {
+		width = minWrapWidth
+	}
+
+	var lines []string
+	for _, para := range strings.Split(s, "\n") {
+		if len(para) <= width {
+			lines = append(lines, para)
+			continue
+		}
+		wrapped := wrapText(para, width)
+		lines = append(lines, wrapped...)
+	}
+
+	return strings.Join(lines, sep)
+}
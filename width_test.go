@@ -0,0 +1,108 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"bytes"
+	"flag"
+	"strings"
+	"testing"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+func TestTerminalWidth(t *testing.T) {
+	tests := map[string]struct {
+		columns string
+		want    int
+	}{
+		"when COLUMNS is not set": {
+			want: subcommandsutil.DefaultWidth,
+		},
+		"when COLUMNS is set": {
+			columns: "120",
+			want:    120,
+		},
+		"when COLUMNS is invalid": {
+			columns: "wide",
+			want:    subcommandsutil.DefaultWidth,
+		},
+		"when COLUMNS is not positive": {
+			columns: "0",
+			want:    subcommandsutil.DefaultWidth,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv(subcommandsutil.ColumnsEnv, tt.columns)
+
+			if got := subcommandsutil.TerminalWidth(&bytes.Buffer{}); got != tt.want {
+				t.Fatalf("wanted %d but got %d", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestPrintDefaultsWrapping(t *testing.T) {
+	const usage = "the address to listen on for incoming connections of the clients, as host:port"
+
+	tests := map[string]struct {
+		columns string
+		want    string
+	}{
+		"when the width is 40": {
+			columns: "40",
+			want: "  -addr string\n" +
+				"    \tthe address to listen on for\n" +
+				"    \tincoming connections of the\n" +
+				"    \tclients, as host:port (default\n" +
+				"    \t\":8080\")\n" +
+				"  -v\tverbose output\n",
+		},
+		"when the width is 120": {
+			columns: "120",
+			want: "  -addr string\n" +
+				"    \t" + usage + " (default \":8080\")\n" +
+				"  -v\tverbose output\n",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv(subcommandsutil.ColumnsEnv, tt.columns)
+
+			var buf bytes.Buffer
+			f := flag.NewFlagSet("serve", flag.ContinueOnError)
+			f.SetOutput(&buf)
+			f.String("addr", ":8080", usage)
+			f.Bool("v", false, "verbose output")
+			subcommandsutil.PrintDefaults(f)
+			if got := buf.String(); got != tt.want {
+				t.Fatalf("wanted %q but got %q", tt.want, got)
+			}
+			for _, line := range strings.Split(buf.String(), "\n") {
+				// the tab expands to 4 columns after the 4 spaces
+				if n := len(strings.Replace(line, "\t", "    ", 1)); n > 40 && tt.columns == "40" {
+					t.Fatalf("wanted lines of at most 40 columns but got %d in %q", n, line)
+				}
+			}
+		})
+	}
+}
+
+func TestExplainGroupWrapping(t *testing.T) {
+	t.Setenv(subcommandsutil.ColumnsEnv, "50")
+
+	h := testcmd.NewHarness(t)
+	h.Commander.ExplainGroup = subcommandsutil.ExplainGroup(h.Commander)
+	h.Register(testcmd.NewRecording("serve", testcmd.WithSynopsis("serve the application over HTTP on the listen address")), "")
+
+	h.Commander.Explain(h.Stdout)
+	want := "\tserve            serve the application\n" +
+		"\t                 over HTTP on the listen\n" +
+		"\t                 address\n"
+	if !strings.Contains(h.Stdout.String(), want) {
+		t.Fatalf("wanted the synopsis wrapped as %q but got %q", want, h.Stdout.String())
+	}
+}
@@ -0,0 +1,303 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"github.com/google/subcommands"
+)
+
+// serviceState is a state of a Windows service reported to the service control manager.
+type serviceState int
+
+const (
+	serviceStartPending serviceState = iota + 1
+	serviceRunning
+	serviceStopPending
+)
+
+// String returns the name of s.
+func (s serviceState) String() string {
+	switch s {
+	case serviceStartPending:
+		return "start pending"
+	case serviceRunning:
+		return "running"
+	case serviceStopPending:
+		return "stop pending"
+	default:
+		return fmt.Sprintf("serviceState(%d)", int(s))
+	}
+}
+
+// serviceRequest is a control request of the service control manager stopping a Windows service.
+type serviceRequest int
+
+const (
+	serviceStop serviceRequest = iota + 1
+	serviceShutdown
+)
+
+// String returns the name of r.
+func (r serviceRequest) String() string {
+	switch r {
+	case serviceStop:
+		return "stop"
+	case serviceShutdown:
+		return "shutdown"
+	default:
+		return fmt.Sprintf("serviceRequest(%d)", int(r))
+	}
+}
+
+// serviceHandler runs a Windows service: it receives the control requests on requests and reports
+// its states to report until it returns its exit code.
+type serviceHandler func(requests <-chan serviceRequest, report func(serviceState)) (exitCode uint32)
+
+// serviceConfig is the configuration of a Windows service installed by InstallServiceCommand.
+type serviceConfig struct {
+	displayName string
+	description string
+	manual      bool     // whether the service is started manually rather than at boot
+	args        []string // the arguments the program is started with
+}
+
+// serviceControl is the API of the service control manager, the svc and mgr packages of
+// golang.org/x/sys/windows on Windows.
+type serviceControl interface {
+	// isService reports whether the process runs as a Windows service.
+	isService() (bool, error)
+
+	// run runs the service name with handler until it returns.
+	run(name string, handler serviceHandler) error
+
+	// install installs the service name running the current executable.
+	install(name string, cfg serviceConfig) error
+
+	// uninstall removes the service name.
+	uninstall(name string) error
+}
+
+// services is the service control manager of the platform, replaced in the tests.
+var services serviceControl = platformServices{}
+
+// windowsService wraps a subcommands.Command so that it runs as a Windows service when started by
+// the service control manager.
+type windowsService struct {
+	sub    CancelableCommand
+	name   string
+	logger Logger
+}
+
+// make sure windowsService implements the CancelableCommand interface.
+var _ CancelableCommand = (*windowsService)(nil)
+
+// WindowsService wraps sub so that it runs as the Windows service serviceName when the process is
+// started by the service control manager: the service is reported start pending, then running
+// while sub executes, and a Stop or Shutdown control request reports it stop pending and cancels
+// the execution context of sub, with a context.Cause naming the request, which is logged to the
+// standard logger. The exit code of the service is the ExitStatus of sub.
+//
+// Outside of a service, like from a console, and on the other platforms, sub is executed as is.
+// Dispose forwards to the Dispose method of sub. InstallServiceCommand and UninstallServiceCommand
+// manage the service entry.
+func WindowsService(sub CancelableCommand, serviceName string) CancelableCommand {
+	return &windowsService{
+		sub:    sub,
+		name:   serviceName,
+		logger: stdLogger{},
+	}
+}
+
+// Name forwards to the underlying c.sub Command.
+func (c *windowsService) Name() string {
+	return c.sub.Name()
+}
+
+// Usage forwards to the underlying c.sub Command.
+func (c *windowsService) Usage() string {
+	return c.sub.Usage()
+}
+
+// Synopsis forwards to the underlying c.sub Command.
+func (c *windowsService) Synopsis() string {
+	return c.sub.Synopsis()
+}
+
+// Unwrap returns the underlying c.sub Command.
+func (c *windowsService) Unwrap() subcommands.Command {
+	return c.sub
+}
+
+// SetFlags forwards to the underlying c.sub Command.
+func (c *windowsService) SetFlags(f *flag.FlagSet) {
+	c.sub.SetFlags(f)
+}
+
+// Dispose forwards to the underlying c.sub Command.
+func (c *windowsService) Dispose() error {
+	return c.sub.Dispose()
+}
+
+// Execute forwards to the underlying c.sub Command, as a Windows service if the process is one.
+func (c *windowsService) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	isService, err := services.isService()
+	if err != nil {
+		fmt.Fprintf(Stderr(ctx), "%s: detecting the Windows service: %v\n", c.sub.Name(), err)
+		return subcommands.ExitFailure
+	}
+	if !isService {
+		return c.sub.Execute(ctx, f, args...)
+	}
+
+	status := subcommands.ExitFailure
+	handler := func(requests <-chan serviceRequest, report func(serviceState)) uint32 {
+		status = c.serve(ctx, f, args, requests, report)
+		return uint32(status)
+	}
+	if err := services.run(c.name, handler); err != nil {
+		fmt.Fprintf(Stderr(ctx), "%s: running the Windows service %s: %v\n", c.sub.Name(), c.name, err)
+		return subcommands.ExitFailure
+	}
+
+	return status
+}
+
+// serve executes the underlying c.sub Command as the service, canceling its execution context on
+// the first request received on requests, and reports the states of the service to report.
+func (c *windowsService) serve(ctx context.Context, f *flag.FlagSet, args []interface{}, requests <-chan serviceRequest, report func(serviceState)) subcommands.ExitStatus {
+	report(serviceStartPending)
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(context.Canceled)
+	ctx, stopping := withStopping(ctx)
+
+	result := make(chan subcommands.ExitStatus, 1)
+	go func() {
+		result <- c.sub.Execute(ctx, f, args...)
+	}()
+	report(serviceRunning)
+
+	stopped := false
+	for {
+		select {
+		case status := <-result:
+			return status
+		case req := <-requests:
+			if stopped {
+				continue
+			}
+			stopped = true
+			contextLogger(ctx, c.logger).Printf("%s: received the %v request of the service %s, canceling", c.sub.Name(), req, c.name)
+			report(serviceStopPending)
+			stopping.stop()
+			cancel(fmt.Errorf("%w: received the %v request of the service %s", context.Canceled, req, c.name))
+		}
+	}
+}
+
+// installServiceCommand is the command installing a Windows service.
+type installServiceCommand struct {
+	name string
+	cfg  serviceConfig
+}
+
+// make sure installServiceCommand implements the subcommands.Command interface.
+var _ subcommands.Command = (*installServiceCommand)(nil)
+
+// InstallServiceCommand returns a command named "install-service" installing the Windows service
+// serviceName, started at boot unless -manual is given, which runs the current executable with the
+// arguments of the command, like the name of the subcommand wrapped by WindowsService:
+//
+//	myagent install-service -display-name "My Agent" agent -config C:\agent.toml
+//
+// It fails on the other platforms.
+func InstallServiceCommand(serviceName string) subcommands.Command {
+	return &installServiceCommand{name: serviceName}
+}
+
+// Name implements subcommands.Command.
+func (c *installServiceCommand) Name() string {
+	return "install-service"
+}
+
+// Synopsis implements subcommands.Command.
+func (c *installServiceCommand) Synopsis() string {
+	return fmt.Sprintf("install the Windows service %s", c.name)
+}
+
+// Usage implements subcommands.Command.
+func (c *installServiceCommand) Usage() string {
+	return fmt.Sprintf("install-service [-display-name name] [-description text] [-manual] [arg...]:\n  Install the Windows service %s running the program with the arguments.\n", c.name)
+}
+
+// SetFlags implements subcommands.Command.
+func (c *installServiceCommand) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&c.cfg.displayName, "display-name", c.name, "the name of the service displayed to the users")
+	f.StringVar(&c.cfg.description, "description", "", "the description of the service")
+	f.BoolVar(&c.cfg.manual, "manual", false, "start the service manually rather than at boot")
+}
+
+// Execute implements subcommands.Command.
+func (c *installServiceCommand) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	cfg := c.cfg
+	cfg.args = f.Args()
+	if err := services.install(c.name, cfg); err != nil {
+		fmt.Fprintf(Stderr(ctx), "%s: installing the Windows service %s: %v\n", c.Name(), c.name, err)
+		return subcommands.ExitFailure
+	}
+	fmt.Fprintf(Stdout(ctx), "installed the Windows service %s\n", c.name)
+
+	return subcommands.ExitSuccess
+}
+
+// uninstallServiceCommand is the command removing a Windows service.
+type uninstallServiceCommand struct {
+	name string
+}
+
+// make sure uninstallServiceCommand implements the subcommands.Command interface.
+var _ subcommands.Command = (*uninstallServiceCommand)(nil)
+
+// UninstallServiceCommand returns a command named "uninstall-service" removing the Windows service
+// serviceName installed by InstallServiceCommand. It fails on the other platforms.
+func UninstallServiceCommand(serviceName string) subcommands.Command {
+	return &uninstallServiceCommand{name: serviceName}
+}
+
+// Name implements subcommands.Command.
+func (c *uninstallServiceCommand) Name() string {
+	return "uninstall-service"
+}
+
+// Synopsis implements subcommands.Command.
+func (c *uninstallServiceCommand) Synopsis() string {
+	return fmt.Sprintf("uninstall the Windows service %s", c.name)
+}
+
+// Usage implements subcommands.Command.
+func (c *uninstallServiceCommand) Usage() string {
+	return fmt.Sprintf("uninstall-service:\n  Remove the Windows service %s.\n", c.name)
+}
+
+// SetFlags implements subcommands.Command.
+func (c *uninstallServiceCommand) SetFlags(*flag.FlagSet) {}
+
+// Execute implements subcommands.Command.
+func (c *uninstallServiceCommand) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	if f.NArg() > 0 {
+		fmt.Fprintf(Stderr(ctx), "%s: unexpected arguments %q\n", c.Name(), f.Args())
+		return subcommands.ExitUsageError
+	}
+	if err := services.uninstall(c.name); err != nil {
+		fmt.Fprintf(Stderr(ctx), "%s: removing the Windows service %s: %v\n", c.Name(), c.name, err)
+		return subcommands.ExitFailure
+	}
+	fmt.Fprintf(Stdout(ctx), "removed the Windows service %s\n", c.name)
+
+	return subcommands.ExitSuccess
+}
@@ -0,0 +1,26 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+//go:build !windows
+
+package subcommandsutil
+
+import "errors"
+
+// errWindowsServiceUnsupported is the error of the Windows services on the other platforms.
+var errWindowsServiceUnsupported = errors.New("the Windows services are not supported on this platform")
+
+// platformServices is the serviceControl of the platforms without Windows services.
+type platformServices struct{}
+
+// isService reports that the process is never a Windows service.
+func (platformServices) isService() (bool, error) { return false, nil }
+
+// run reports that Windows services are not supported.
+func (platformServices) run(string, serviceHandler) error { return errWindowsServiceUnsupported }
+
+// install reports that Windows services are not supported.
+func (platformServices) install(string, serviceConfig) error { return errWindowsServiceUnsupported }
+
+// uninstall reports that Windows services are not supported.
+func (platformServices) uninstall(string) error { return errWindowsServiceUnsupported }
@@ -0,0 +1,131 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+func TestWindowsService(t *testing.T) {
+	tests := map[string]struct {
+		service bool
+		request string // the control request sent once the service is running, if any
+		status  subcommands.ExitStatus
+		// wantCause is the context.Cause of the execution context of sub once it was done
+		wantCause    string
+		wantStates   []string
+		wantExitCode uint32
+		wantLog      []string
+	}{
+		"when the process is not a service": {
+			status: subcommands.ExitFailure,
+		},
+		"when the service is stopped": {
+			service:    true,
+			request:    "stop",
+			wantCause:  "context canceled: received the stop request of the service agentd",
+			wantStates: []string{"start pending", "running", "stop pending"},
+			wantLog:    []string{"agent: received the stop request of the service agentd, canceling"},
+		},
+		"when the system shuts down": {
+			service:    true,
+			request:    "shutdown",
+			wantCause:  "context canceled: received the shutdown request of the service agentd",
+			wantStates: []string{"start pending", "running", "stop pending"},
+			wantLog:    []string{"agent: received the shutdown request of the service agentd, canceling"},
+		},
+		"when the command of the service finishes": {
+			service:      true,
+			status:       subcommands.ExitFailure,
+			wantStates:   []string{"start pending", "running"},
+			wantExitCode: 1,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			defer testcmd.VerifyNoLeaks(t)
+
+			fake := &subcommandsutil.FakeServices{
+				Service:  tt.service,
+				Requests: make(chan string, 1),
+				States:   make(chan string, 3),
+			}
+			defer subcommandsutil.SetServices(fake)()
+
+			var cause string
+			sub := testcmd.NewRecording("agent", testcmd.WithExecute(func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+				if tt.request == "" {
+					return tt.status
+				}
+				fake.Requests <- tt.request
+				<-ctx.Done()
+				cause = fmt.Sprint(context.Cause(ctx))
+				return tt.status
+			}))
+
+			var logs testcmd.LogRecorder
+			ctx := subcommandsutil.NewContextWithLogger(context.Background(), &logs)
+			status, _, _ := testcmd.Run(ctx, subcommandsutil.WindowsService(sub, "agentd"))
+			testcmd.AssertStatus(t, status, tt.status)
+			if sub.CallCount() != 1 {
+				t.Fatalf("wanted 1 call but got %d", sub.CallCount())
+			}
+			if cause != tt.wantCause {
+				t.Fatalf("wanted the cause %q but got %q", tt.wantCause, cause)
+			}
+			close(fake.States)
+			var states []string
+			for state := range fake.States {
+				states = append(states, state)
+			}
+			if !reflect.DeepEqual(states, tt.wantStates) {
+				t.Fatalf("wanted the states %q but got %q", tt.wantStates, states)
+			}
+			if fake.ExitCode != tt.wantExitCode {
+				t.Fatalf("wanted the exit code %d but got %d", tt.wantExitCode, fake.ExitCode)
+			}
+			if got := logs.Lines(); strings.Join(got, "\n") != strings.Join(tt.wantLog, "\n") {
+				t.Fatalf("wanted the log %q but got %q", tt.wantLog, got)
+			}
+		})
+	}
+}
+
+func TestInstallServiceCommand(t *testing.T) {
+	fake := &subcommandsutil.FakeServices{Installed: map[string]string{}}
+	defer subcommandsutil.SetServices(fake)()
+
+	h := testcmd.NewHarness(t)
+	h.Register(subcommandsutil.InstallServiceCommand("agentd"), "")
+	h.Register(subcommandsutil.UninstallServiceCommand("agentd"), "")
+
+	testcmd.RequireSuccess(t, h.Execute(context.Background(), "install-service", "-manual", "agent", "-config", `C:\agent.toml`))
+	want := map[string]string{"agentd": `display-name="agentd" description="" manual=true args=["agent" "-config" "C:\\agent.toml"]`}
+	if !reflect.DeepEqual(fake.Installed, want) {
+		t.Fatalf("wanted the services %q but got %q", want, fake.Installed)
+	}
+	if got := h.Stdout.String(); got != "installed the Windows service agentd\n" {
+		t.Fatalf("wanted the installation printed but got %q", got)
+	}
+
+	testcmd.AssertStatus(t, h.Execute(context.Background(), "install-service"), subcommands.ExitFailure)
+	if got, want := h.Stderr.String(), "install-service: installing the Windows service agentd: the service agentd already exists\n"; got != want {
+		t.Fatalf("wanted stderr %q but got %q", want, got)
+	}
+
+	testcmd.RequireSuccess(t, h.Execute(context.Background(), "uninstall-service"))
+	if len(fake.Installed) != 0 {
+		t.Fatalf("wanted the service removed but got %q", fake.Installed)
+	}
+}
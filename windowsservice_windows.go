@@ -0,0 +1,125 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+// platformServices is the serviceControl of the svc and mgr packages.
+type platformServices struct{}
+
+// isService implements serviceControl.
+func (platformServices) isService() (bool, error) {
+	return svc.IsWindowsService()
+}
+
+// run implements serviceControl.
+func (platformServices) run(name string, handler serviceHandler) error {
+	return svc.Run(name, svcHandler(handler))
+}
+
+// install implements serviceControl.
+func (platformServices) install(name string, cfg serviceConfig) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	m, err := mgr.Connect()
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect()
+
+	if s, err := m.OpenService(name); err == nil {
+		s.Close()
+		return fmt.Errorf("the service %s already exists", name)
+	}
+	startType := uint32(mgr.StartAutomatic)
+	if cfg.manual {
+		startType = mgr.StartManual
+	}
+	s, err := m.CreateService(name, exe, mgr.Config{
+		DisplayName: cfg.displayName,
+		Description: cfg.description,
+		StartType:   startType,
+	}, cfg.args...)
+	if err != nil {
+		return err
+	}
+
+	return s.Close()
+}
+
+// uninstall implements serviceControl.
+func (platformServices) uninstall(name string) error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect()
+
+	s, err := m.OpenService(name)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	return s.Delete()
+}
+
+// svcHandler is the svc.Handler running a serviceHandler.
+type svcHandler serviceHandler
+
+// make sure svcHandler implements the svc.Handler interface.
+var _ svc.Handler = svcHandler(nil)
+
+// Execute implements svc.Handler. The Interrogate requests are answered with the current status,
+// and the Stop and Shutdown ones relayed to the serviceHandler.
+func (h svcHandler) Execute(args []string, r <-chan svc.ChangeRequest, s chan<- svc.Status) (bool, uint32) {
+	requests := make(chan serviceRequest, 1)
+	done := make(chan uint32, 1)
+	go func() {
+		done <- h(requests, func(state serviceState) { s <- svcStatus(state) })
+	}()
+
+	for {
+		select {
+		case code := <-done:
+			// the ExitStatus of the command, rather than a Win32 error code
+			return code != 0, code
+		case req := <-r:
+			switch req.Cmd {
+			case svc.Interrogate:
+				s <- req.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				sr := serviceStop
+				if req.Cmd == svc.Shutdown {
+					sr = serviceShutdown
+				}
+				// a pending request already stops the service
+				select {
+				case requests <- sr:
+				default:
+				}
+			}
+		}
+	}
+}
+
+// svcStatus returns the svc.Status of state.
+func svcStatus(state serviceState) svc.Status {
+	switch state {
+	case serviceStartPending:
+		return svc.Status{State: svc.StartPending}
+	case serviceRunning:
+		return svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+	default:
+		return svc.Status{State: svc.StopPending}
+	}
+}
@@ -0,0 +1,168 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/google/subcommands"
+)
+
+// workDirKey is the context key of the working directory.
+type workDirKey struct{}
+
+// WithWorkDir returns a copy of ctx whose working directory is dir.
+func WithWorkDir(ctx context.Context, dir string) context.Context {
+	return context.WithValue(ctx, workDirKey{}, dir)
+}
+
+// WorkDir returns the working directory of the execution of ctx: the absolute directory set by the
+// -C flag of WorkDirCommand, or the current directory of the process. Commands resolve their
+// relative paths against it.
+func WorkDir(ctx context.Context) string {
+	if dir, ok := ctx.Value(workDirKey{}).(string); ok {
+		return dir
+	}
+	dir, _ := os.Getwd()
+
+	return dir
+}
+
+// WorkDirOption is an option of the WorkDirCommand wrapper.
+type WorkDirOption interface {
+	applyWorkDir(*workDir)
+}
+
+// workDirOptionFunc is a WorkDirOption implemented by a function.
+type workDirOptionFunc func(*workDir)
+
+// applyWorkDir implements WorkDirOption.
+func (fn workDirOptionFunc) applyWorkDir(c *workDir) { fn(c) }
+
+// WithChdir makes WorkDirCommand also change the current directory of the process to the directory
+// set by the -C flag for the duration of Execute. As the current directory is global to the
+// process, the commands running concurrently must not depend on it.
+func WithChdir() WorkDirOption {
+	return workDirOptionFunc(func(c *workDir) {
+		c.chdir = true
+	})
+}
+
+// workDir wraps a subcommands.Command so that it runs in another working directory.
+type workDir struct {
+	sub   subcommands.Command
+	chdir bool
+
+	dir string
+}
+
+// make sure workDir implements the CancelableCommand interface.
+var _ CancelableCommand = (*workDir)(nil)
+
+// WorkDirCommand wraps sub with the -C flag. When set, its directory, relative to the WorkDir of
+// the execution context, is the WorkDir of sub; a directory which does not exist is a usage error.
+//
+// With WithChdir, the current directory of the process is changed too, and restored when Execute
+// returns or panics, or when a Cancelable wrapper stops waiting for sub. Dispose forwards to the
+// Dispose method of sub, if any.
+func WorkDirCommand(sub subcommands.Command, opts ...WorkDirOption) CancelableCommand {
+	c := &workDir{
+		sub: sub,
+	}
+	for _, opt := range opts {
+		opt.applyWorkDir(c)
+	}
+
+	return c
+}
+
+// Name forwards to the underlying c.sub Command.
+func (c *workDir) Name() string {
+	return c.sub.Name()
+}
+
+// Usage forwards to the underlying c.sub Command.
+func (c *workDir) Usage() string {
+	return c.sub.Usage()
+}
+
+// Synopsis forwards to the underlying c.sub Command.
+func (c *workDir) Synopsis() string {
+	return c.sub.Synopsis()
+}
+
+// Unwrap returns the underlying c.sub Command.
+func (c *workDir) Unwrap() subcommands.Command {
+	return c.sub
+}
+
+// SetFlags forwards to the underlying c.sub Command and registers the -C flag.
+func (c *workDir) SetFlags(f *flag.FlagSet) {
+	c.sub.SetFlags(f)
+	f.StringVar(&c.dir, "C", "", "run as if started in `dir`")
+}
+
+// Dispose forwards to the underlying c.sub Command if it is a CancelableCommand.
+func (c *workDir) Dispose() error {
+	if sub, ok := c.sub.(CancelableCommand); ok {
+		return sub.Dispose()
+	}
+
+	return nil
+}
+
+// Execute forwards to the underlying c.sub Command, in the directory set by the -C flag.
+func (c *workDir) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	if c.dir == "" {
+		return c.sub.Execute(ctx, f, args...)
+	}
+
+	dir := c.dir
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(WorkDir(ctx), dir)
+	}
+	fi, err := os.Stat(dir)
+	if err != nil {
+		fmt.Fprintf(Stderr(ctx), "%s: -C: %v\n", c.sub.Name(), err)
+		return subcommands.ExitUsageError
+	}
+	if !fi.IsDir() {
+		fmt.Fprintf(Stderr(ctx), "%s: -C: %s is not a directory\n", c.sub.Name(), dir)
+		return subcommands.ExitUsageError
+	}
+
+	if c.chdir {
+		restore, err := chdir(dir)
+		if err != nil {
+			fmt.Fprintf(Stderr(ctx), "%s: -C: %v\n", c.sub.Name(), err)
+			return subcommands.ExitFailure
+		}
+		defer restore()
+		defer onCancel(ctx, restore)()
+	}
+
+	return c.sub.Execute(WithWorkDir(ctx, dir), f, args...)
+}
+
+// chdir changes the current directory to dir. The returned restore function changes it back to the
+// previous one, once.
+func chdir(dir string) (restore func(), err error) {
+	prev, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chdir(dir); err != nil {
+		return nil, err
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { _ = os.Chdir(prev) })
+	}, nil
+}
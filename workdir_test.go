@@ -0,0 +1,184 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil_test
+
+import (
+	"context"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/subcommands"
+
+	"github.com/zchee/subcommandsutil"
+	"github.com/zchee/subcommandsutil/testcmd"
+)
+
+// getwd returns the current directory with its symbolic links resolved.
+func getwd(t *testing.T) string {
+	t.Helper()
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return evalSymlinks(t, wd)
+}
+
+// evalSymlinks returns path with its symbolic links resolved.
+func evalSymlinks(t *testing.T, path string) string {
+	t.Helper()
+
+	path, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestWorkDirCommand(t *testing.T) {
+	base := t.TempDir()
+	if err := os.Mkdir(filepath.Join(base, "project"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(base, "file"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cwd := getwd(t)
+
+	tests := map[string]struct {
+		ctx         context.Context
+		args        []string
+		opts        []subcommandsutil.WorkDirOption
+		wantStatus  subcommands.ExitStatus
+		wantWorkDir string
+		wantGetwd   string
+		wantStderr  string
+	}{
+		"when -C is not set": {
+			ctx:         context.Background(),
+			wantStatus:  subcommands.ExitSuccess,
+			wantWorkDir: cwd,
+			wantGetwd:   cwd,
+		},
+		"when -C is an absolute directory": {
+			ctx:         context.Background(),
+			args:        []string{"-C", filepath.Join(base, "project")},
+			wantStatus:  subcommands.ExitSuccess,
+			wantWorkDir: filepath.Join(base, "project"),
+			wantGetwd:   cwd,
+		},
+		"when -C is relative to the working directory of the context": {
+			ctx:         subcommandsutil.WithWorkDir(context.Background(), base),
+			args:        []string{"-C", "project"},
+			wantStatus:  subcommands.ExitSuccess,
+			wantWorkDir: filepath.Join(base, "project"),
+			wantGetwd:   cwd,
+		},
+		"when -C changes the current directory": {
+			ctx:         context.Background(),
+			args:        []string{"-C", filepath.Join(base, "project")},
+			opts:        []subcommandsutil.WorkDirOption{subcommandsutil.WithChdir()},
+			wantStatus:  subcommands.ExitSuccess,
+			wantWorkDir: filepath.Join(base, "project"),
+			wantGetwd:   evalSymlinks(t, filepath.Join(base, "project")),
+		},
+		"when -C does not exist": {
+			ctx:        context.Background(),
+			args:       []string{"-C", filepath.Join(base, "missing")},
+			opts:       []subcommandsutil.WorkDirOption{subcommandsutil.WithChdir()},
+			wantStatus: subcommands.ExitUsageError,
+			wantStderr: filepath.Join(base, "missing"),
+		},
+		"when -C is not a directory": {
+			ctx:        context.Background(),
+			args:       []string{"-C", filepath.Join(base, "file")},
+			wantStatus: subcommands.ExitUsageError,
+			wantStderr: filepath.Join(base, "file") + " is not a directory",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var gotWorkDir, gotGetwd string
+			sub := testcmd.NewRecording("build", testcmd.WithExecute(func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+				gotWorkDir = subcommandsutil.WorkDir(ctx)
+				gotGetwd = getwd(t)
+				return subcommands.ExitSuccess
+			}))
+
+			var stderr testcmd.Buffer
+			ctx := subcommandsutil.WithOutput(tt.ctx, &testcmd.Buffer{}, &stderr)
+			status, _, _ := testcmd.Run(ctx, subcommandsutil.WorkDirCommand(sub, tt.opts...), tt.args...)
+			testcmd.AssertStatus(t, status, tt.wantStatus)
+			if gotWorkDir != tt.wantWorkDir {
+				t.Fatalf("wanted the working directory %q but got %q", tt.wantWorkDir, gotWorkDir)
+			}
+			if gotGetwd != tt.wantGetwd {
+				t.Fatalf("wanted the current directory %q during the execution but got %q", tt.wantGetwd, gotGetwd)
+			}
+			if !strings.Contains(stderr.String(), tt.wantStderr) {
+				t.Fatalf("wanted the stderr to contain %q but got %q", tt.wantStderr, stderr.String())
+			}
+			if got := getwd(t); got != cwd {
+				t.Fatalf("wanted the current directory to be restored to %q but got %q", cwd, got)
+			}
+		})
+	}
+}
+
+func TestWorkDirCommandRestore(t *testing.T) {
+	dir := t.TempDir()
+	cwd := getwd(t)
+
+	t.Run("when the command panics", func(t *testing.T) {
+		sub := testcmd.NewRecording("build", testcmd.WithExecute(func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+			panic("boom")
+		}))
+
+		func() {
+			defer func() {
+				if got := recover(); got != "boom" {
+					t.Fatalf("wanted the panic to propagate but got %v", got)
+				}
+			}()
+			testcmd.Run(context.Background(), subcommandsutil.WorkDirCommand(sub, subcommandsutil.WithChdir()), "-C", dir)
+		}()
+		if got := getwd(t); got != cwd {
+			t.Fatalf("wanted the current directory to be restored to %q but got %q", cwd, got)
+		}
+	})
+
+	t.Run("when the execution is canceled", func(t *testing.T) {
+		defer testcmd.VerifyNoLeaks(t)
+
+		started := make(chan struct{})
+		release := make(chan struct{})
+		finished := make(chan struct{})
+		sub := testcmd.NewRecording("build", testcmd.WithExecute(func(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+			defer close(finished)
+			close(started)
+			<-release
+			return subcommands.ExitSuccess
+		}))
+		cmd := subcommandsutil.Cancelable(subcommandsutil.WorkDirCommand(sub, subcommandsutil.WithChdir()), subcommandsutil.WithLogger(&testcmd.LogRecorder{}))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			<-started
+			cancel()
+		}()
+		status, _, _ := testcmd.Run(ctx, cmd, "-C", dir)
+		testcmd.AssertStatus(t, status, subcommands.ExitFailure)
+		if got := getwd(t); got != cwd {
+			t.Fatalf("wanted the current directory to be restored to %q but got %q", cwd, got)
+		}
+
+		close(release)
+		<-finished
+	})
+}
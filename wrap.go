@@ -0,0 +1,32 @@
+// SPDX-FileCopyrightText: Copyright 2021 The subcommandsutil Authors
+// SPDX-License-Identifier: BSD-3-Clause
+
+package subcommandsutil
+
+import (
+	"github.com/google/subcommands"
+)
+
+// Unwrap returns the Command wrapped by cmd if cmd has an Unwrap method returning a
+// subcommands.Command. Otherwise, Unwrap returns nil.
+//
+// Every wrapper in this package implements the Unwrap method, so optional interfaces of a Command
+// can be found through any number of wrappers.
+func Unwrap(cmd subcommands.Command) subcommands.Command {
+	u, ok := cmd.(interface{ Unwrap() subcommands.Command })
+	if !ok {
+		return nil
+	}
+
+	return u.Unwrap()
+}
+
+// walkCommand calls fn with cmd and then with each Command it wraps, until fn returns true.
+func walkCommand(cmd subcommands.Command, fn func(subcommands.Command) bool) {
+	for cmd != nil {
+		if fn(cmd) {
+			return
+		}
+		cmd = Unwrap(cmd)
+	}
+}